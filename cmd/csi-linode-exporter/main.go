@@ -0,0 +1,133 @@
+/*
+Command csi-linode-exporter exports the Block Storage inventory of a Linode
+account as Prometheus metrics, without serving CSI requests.
+
+It reports the number and total size of the volumes of the account, and the
+number of orphan candidates, volumes attached to no instance and not updated
+for ORPHAN_CANDIDATE_AGE, in each region, on the /metrics endpoint of
+METRICS_PORT. It only needs a LINODE_TOKEN with read access to the volumes,
+and can run on clusters without the driver, e.g. management clusters.
+*/
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ianschenck/envflag"
+	"github.com/linode/linodego"
+	"k8s.io/klog/v2"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/inventory"
+	linodeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-client"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+var vendorVersion string // set by the linker
+
+type configuration struct {
+	linodeToken      string
+	linodeURL        string
+	metricsPort      string
+	metricsNamespace string
+
+	// How the requests reach the Linode API
+	transport linodeclient.Transport
+
+	// How often the inventory is collected
+	interval time.Duration
+
+	// How long detached volumes must not have been updated to be counted
+	// as orphan candidates
+	orphanAge time.Duration
+}
+
+func main() {
+	ctx := context.Background()
+	log := logger.NewLogger(ctx)
+	ctx = context.WithValue(ctx, logger.LoggerKey{}, log)
+
+	klog.InitFlags(nil)
+	if err := flag.Set("logtostderr", "true"); err != nil {
+		log.Error(err, "Fatal error")
+		os.Exit(1)
+	}
+	flag.Parse()
+
+	var cfg configuration
+	var interval, orphanAge string
+	envflag.StringVar(&cfg.linodeToken, "LINODE_TOKEN", "", "Linode API token")
+	envflag.StringVar(&cfg.linodeURL, "LINODE_URL", linodego.APIHost, "Linode API URL")
+	envflag.StringVar(&cfg.transport.ProxyURL, "LINODE_API_PROXY", "", "URL of the HTTP or HTTPS proxy the requests to the Linode API are sent through, instead of the proxy of $HTTPS_PROXY")
+	envflag.StringVar(&cfg.transport.CABundlePath, "LINODE_API_CA_BUNDLE", "", "Path of a PEM bundle of CA certificates trusted for the Linode API in addition to the system roots")
+	envflag.StringVar(&cfg.metricsPort, "METRICS_PORT", "8081", "Port of the metrics endpoint")
+	envflag.StringVar(&cfg.metricsNamespace, "METRICS_NAMESPACE", observability.DefaultMetricsNamespace, "Prefix of the names of the metrics")
+	envflag.StringVar(&interval, "INVENTORY_INTERVAL", "5m", "How often the inventory is collected")
+	envflag.StringVar(&orphanAge, "ORPHAN_CANDIDATE_AGE", "24h", "How long detached volumes must not have been updated to be counted as orphan candidates")
+	envflag.Parse()
+
+	var err error
+	if cfg.interval, err = time.ParseDuration(interval); err != nil || cfg.interval <= 0 {
+		log.Error(fmt.Errorf("invalid inventory interval %q", interval), "Fatal error")
+		os.Exit(1)
+	}
+	if cfg.orphanAge, err = time.ParseDuration(orphanAge); err != nil || cfg.orphanAge < 0 {
+		log.Error(fmt.Errorf("invalid orphan candidate age %q", orphanAge), "Fatal error")
+		os.Exit(1)
+	}
+
+	if err := run(ctx, cfg); err != nil {
+		log.Error(err, "Fatal error")
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, cfg configuration) error {
+	log := logger.GetLogger(ctx)
+
+	if cfg.linodeToken == "" {
+		return errors.New("linode token required")
+	}
+	client, err := linodeclient.NewLinodeClient(cfg.linodeToken, fmt.Sprintf("LinodeCSIExporter/%s", vendorVersion), cfg.linodeURL, cfg.transport, linodeclient.DebugDump{})
+	if err != nil {
+		return fmt.Errorf("failed to set up linode client: %w", err)
+	}
+	if err := observability.ConfigureMetrics(observability.MetricsConfig{Namespace: cfg.metricsNamespace}); err != nil {
+		return fmt.Errorf("failed to configure metrics: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go inventory.Run(ctx, client, cfg.interval, cfg.orphanAge)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", observability.MetricsHandler())
+	server := &http.Server{
+		Addr:              ":" + cfg.metricsPort,
+		Handler:           mux,
+		ReadTimeout:       10 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		IdleTimeout:       15 * time.Second,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		if err := server.Shutdown(context.Background()); err != nil {
+			log.Error(err, "Failed to stop metrics server")
+		}
+	}()
+
+	log.V(2).Info("Serving inventory metrics", "port", cfg.metricsPort, "interval", cfg.interval)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("serve metrics: %w", err)
+	}
+	return nil
+}
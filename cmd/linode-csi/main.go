@@ -0,0 +1,185 @@
+/*
+Command linode-csi runs maintenance tasks on the volumes of the Linode Block
+Storage CSI driver.
+
+	linode-csi luks restore-header --device <path> (--volume <pv> | --file <path>)
+	linode-csi volumes migrate-label-prefix --from <prefix> [--to <prefix>] [--apply]
+
+luks restore-header replaces the corrupted LUKS header of an encrypted
+volume with the backup made by the node plugin when it formatted the volume,
+with LUKS_HEADER_BACKUP set. With --volume, the backup is read from the
+Secret of the PersistentVolume in --namespace, the namespace of the plugin by
+default; with --file, from a backup file. It runs in the node plugin
+container of the node the volume is attached to. The volume must be attached
+to the node, and not staged.
+
+volumes migrate-label-prefix renames the volumes of the PersistentVolumes of
+the driver whose labels were made with the volume label prefix --from after
+the prefix --to, LINODE_VOLUME_LABEL_PREFIX by default, as the controller
+names the volumes it creates. It runs in the controller container, whose
+LINODE_TOKEN, LINODE_URL, CLUSTER_NAME and VOLUME_NAME_TEMPLATE it uses. The
+volumes are only listed unless --apply is set.
+*/
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/linode/linodego"
+	utilexec "k8s.io/utils/exec"
+
+	"github.com/linode/linode-blockstorage-csi-driver/internal/driver"
+	cryptsetupclient "github.com/linode/linode-blockstorage-csi-driver/pkg/cryptsetup-client"
+	kubeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/kube-client"
+	linodeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-client"
+)
+
+const (
+	restoreHeaderUsage      = "usage: linode-csi luks restore-header --device <path> (--volume <pv> | --file <path>)"
+	migrateLabelPrefixUsage = "usage: linode-csi volumes migrate-label-prefix --from <prefix> [--to <prefix>] [--apply]"
+	usage                   = "usage: linode-csi luks restore-header --device <path> (--volume <pv> | --file <path>)\n" +
+		"       linode-csi volumes migrate-label-prefix --from <prefix> [--to <prefix>] [--apply]"
+)
+
+func main() {
+	if len(os.Args) < 3 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] + " " + os.Args[2] {
+	case "luks restore-header":
+		err = restoreHeader(context.Background(), os.Args[3:])
+	case "volumes migrate-label-prefix":
+		err = migrateLabelPrefix(context.Background(), os.Args[3:])
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "linode-csi: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// restoreHeader runs luks restore-header with args.
+func restoreHeader(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("luks restore-header", flag.ContinueOnError)
+	device := flags.String("device", "", "Path of the device of the volume, e.g. /dev/disk/by-id/scsi-0Linode_Volume_<label>")
+	volume := flags.String("volume", "", "Name of the PersistentVolume of the volume, whose backup is read from its Secret")
+	namespace := flags.String("namespace", "", "Namespace of the Secrets of the backups, the namespace of the plugin by default")
+	file := flags.String("file", "", "Path of the backup file of the header")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *device == "" || (*volume == "") == (*file == "") {
+		return errors.New(restoreHeaderUsage)
+	}
+
+	var data []byte
+	var err error
+	if *file != "" {
+		if data, err = os.ReadFile(*file); err != nil {
+			return err
+		}
+	} else if data, err = readSecretBackup(ctx, *namespace, *volume); err != nil {
+		return err
+	}
+	header, err := driver.DecodeLUKSHeader(data)
+	if err != nil {
+		return err
+	}
+
+	if err := cryptsetupclient.RestoreHeader(utilexec.New(), *device, header); err != nil {
+		return err
+	}
+	fmt.Printf("Restored the LUKS header of %s\n", *device)
+	return nil
+}
+
+// readSecretBackup returns the backup of the LUKS header of volume held by
+// its Secret in namespace.
+func readSecretBackup(ctx context.Context, namespace, volume string) ([]byte, error) {
+	client, err := kubeclient.NewInClusterClient()
+	if err != nil {
+		return nil, err
+	}
+	if namespace == "" {
+		if namespace, err = kubeclient.InClusterNamespace(); err != nil {
+			return nil, err
+		}
+	}
+	name := driver.LUKSHeaderSecretName(volume)
+	secret, err := client.GetSecret(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := secret[driver.LUKSHeaderSecretKey]
+	if !ok {
+		return nil, fmt.Errorf("secret %s/%s has no %q key", namespace, name, driver.LUKSHeaderSecretKey)
+	}
+	return data, nil
+}
+
+// migrateLabelPrefix runs volumes migrate-label-prefix with args.
+func migrateLabelPrefix(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("volumes migrate-label-prefix", flag.ContinueOnError)
+	from := flags.String("from", "", "Previous volume label prefix")
+	to := flags.String("to", os.Getenv("LINODE_VOLUME_LABEL_PREFIX"), "Current volume label prefix")
+	apply := flags.Bool("apply", false, "Rename the volumes, instead of only listing them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *from == *to || flags.NArg() > 0 {
+		return errors.New(migrateLabelPrefixUsage)
+	}
+
+	opts := driver.Options{
+		PreviousVolumeLabelPrefix: *from,
+		ClusterName:               os.Getenv("CLUSTER_NAME"),
+	}
+	if template := os.Getenv("VOLUME_NAME_TEMPLATE"); template != "" {
+		var err error
+		if opts.VolumeNameTemplate, err = driver.ParseVolumeNameTemplate(template); err != nil {
+			return err
+		}
+	}
+	apiURL := os.Getenv("LINODE_URL")
+	if apiURL == "" {
+		apiURL = linodego.APIHost
+	}
+	transport := linodeclient.Transport{ProxyURL: os.Getenv("LINODE_API_PROXY"), CABundlePath: os.Getenv("LINODE_API_CA_BUNDLE")}
+	client, err := linodeclient.NewLinodeClient(os.Getenv("LINODE_TOKEN"), "LinodeCSI/linode-csi", apiURL, transport, linodeclient.DebugDump{})
+	if err != nil {
+		return err
+	}
+	kube, err := kubeclient.NewInClusterClient()
+	if err != nil {
+		return err
+	}
+	migration, err := driver.NewLabelPrefixMigration(client, kube, *to, opts)
+	if err != nil {
+		return err
+	}
+
+	migrations, err := migration.Plan(ctx)
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if !*apply {
+			fmt.Printf("Would rename volume %d of %s from %s to %s\n", m.VolumeID, m.PersistentVolume, m.Label, m.NewLabel)
+			continue
+		}
+		if err := migration.Apply(ctx, m); err != nil {
+			return fmt.Errorf("rename volume %d of %s: %w", m.VolumeID, m.PersistentVolume, err)
+		}
+		fmt.Printf("Renamed volume %d of %s from %s to %s\n", m.VolumeID, m.PersistentVolume, m.Label, m.NewLabel)
+	}
+	fmt.Printf("%d volumes with the label prefix %q\n", len(migrations), *from)
+	return nil
+}
@@ -0,0 +1,95 @@
+/*
+Command linode-host-helper is a minimal privileged helper for the Linode Block
+Storage CSI node plugin.
+
+It performs mount, unmount, mkfs and cryptsetup operations on behalf of the
+node plugin, which lets the node plugin container run without CAP_SYS_ADMIN.
+Point the node plugin at the helper's socket with the HOST_HELPER_SOCKET
+environment variable.
+*/
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ianschenck/envflag"
+	"k8s.io/klog/v2"
+	"k8s.io/mount-utils"
+	utilexec "k8s.io/utils/exec"
+
+	cryptsetupclient "github.com/linode/linode-blockstorage-csi-driver/pkg/cryptsetup-client"
+	hosthelper "github.com/linode/linode-blockstorage-csi-driver/pkg/host-helper"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+// socketPermissions only allows the owner of the socket (root) to connect.
+const socketPermissions = os.FileMode(0o600)
+
+func main() {
+	ctx := context.Background()
+	log := logger.NewLogger(ctx)
+
+	klog.InitFlags(nil)
+	if err := flag.Set("logtostderr", "true"); err != nil {
+		log.Error(err, "Fatal error")
+		os.Exit(1)
+	}
+	flag.Parse()
+
+	var socketPath, kubeletDir string
+	envflag.StringVar(&socketPath, "HOST_HELPER_SOCKET", "/csi/host-helper.sock", "Path to the unix socket to listen on")
+	envflag.StringVar(&kubeletDir, "KUBELET_DIR", hosthelper.DefaultKubeletDir, "Root directory of the kubelet, the only one volumes can be mounted to")
+	envflag.Parse()
+
+	if err := run(ctx, socketPath, kubeletDir); err != nil {
+		log.Error(err, "Fatal error")
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, socketPath, kubeletDir string) error {
+	log := logger.GetLogger(ctx)
+
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove stale socket %s: %w", socketPath, err)
+	}
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %w", socketPath, err)
+	}
+	defer func() {
+		if err := listener.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			log.Error(err, "Failed to close listener")
+		}
+	}()
+	if err := os.Chmod(socketPath, socketPermissions); err != nil {
+		return fmt.Errorf("chmod %s: %w", socketPath, err)
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		if err := listener.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			log.Error(err, "Failed to close listener")
+		}
+	}()
+
+	server := hosthelper.NewServer(
+		mount.New(""),
+		utilexec.New(),
+		cryptsetupclient.NewCryptSetup(),
+		hosthelper.DefaultAllowedCommands,
+		kubeletDir,
+	)
+
+	log.V(2).Info("Serving host helper", "socket", socketPath)
+	return server.Serve(listener)
+}
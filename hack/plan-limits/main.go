@@ -0,0 +1,166 @@
+/*
+Command plan-limits generates a table-driven test checking
+maxVolumeAttachments against the memory of every Linode plan.
+
+It lists the plans with the Linode API, which does not require a token, and
+expects the number of volume attachments each plan is documented to allow,
+read from a checked-in table rather than computed, so that the test does not
+check the driver against its own formula. Run it again with
+`make generate-plan-limits` when Linode introduces new plans, after adding
+their documented limits to documented_limits.csv: it fails on plans missing
+from the table.
+*/
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"go/format"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/linode/linodego"
+)
+
+var testTemplate = template.Must(template.New("test").Parse(`// Code generated by hack/plan-limits from hack/plan-limits/documented_limits.csv; DO NOT EDIT.
+
+package driver
+
+import "testing"
+
+func TestMaxVolumeAttachmentsPlans(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		plan     string
+		memoryMB int
+		want     int
+	}{
+{{- range .}}
+		{plan: {{printf "%q" .ID}}, memoryMB: {{.Memory}}, want: {{.Want}}},
+{{- end}}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.plan, func(t *testing.T) {
+			t.Parallel()
+
+			// As computed by the controller, from the instance specs
+			if got := maxVolumeAttachments(uint(tt.memoryMB) << 20); got != tt.want {
+				t.Errorf("controller: want=%d got=%d", tt.want, got)
+			}
+			// As computed by the node plugin, from the metadata service
+			if got := maxVolumeAttachments(memoryToBytes(tt.memoryMB)); got != tt.want {
+				t.Errorf("node: want=%d got=%d", tt.want, got)
+			}
+		})
+	}
+}
+`))
+
+// plan is a Linode plan and the number of volume attachments it allows.
+type plan struct {
+	ID     string
+	Memory int
+	Want   int
+}
+
+// readDocumentedLimits reads the table of documented volume attachment
+// limits at path, by plan ID.
+func readDocumentedLimits(path string) (map[string]int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 2
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("read %s: missing header", path)
+	}
+
+	limits := make(map[string]int, len(records)-1)
+	for _, record := range records[1:] {
+		attachments, err := strconv.Atoi(record[1])
+		if err != nil || attachments <= 0 {
+			return nil, fmt.Errorf("read %s: invalid limit %q of plan %s", path, record[1], record[0])
+		}
+		limits[record[0]] = attachments
+	}
+	return limits, nil
+}
+
+func main() {
+	var (
+		output = flag.String("o", "limits_plans_test.go", "Path of the generated test file")
+		limits = flag.String("limits", "documented_limits.csv", "Path of the table of documented limits")
+		apiURL = flag.String("url", "", "Linode API URL, if not the default one")
+	)
+	flag.Parse()
+
+	if err := run(context.Background(), *apiURL, *limits, *output); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, apiURL, limitsPath, output string) error {
+	limits, err := readDocumentedLimits(limitsPath)
+	if err != nil {
+		return err
+	}
+
+	client := linodego.NewClient(http.DefaultClient)
+	if apiURL != "" {
+		client.SetBaseURL(apiURL)
+	}
+
+	types, err := client.ListTypes(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("list types: %w", err)
+	}
+
+	plans := make([]plan, 0, len(types))
+	var missing []string
+	for _, typ := range types {
+		want, ok := limits[typ.ID]
+		if !ok {
+			missing = append(missing, typ.ID)
+			continue
+		}
+		plans = append(plans, plan{ID: typ.ID, Memory: typ.Memory, Want: want})
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("no documented limit in %s for plans: %s", limitsPath, strings.Join(missing, ", "))
+	}
+	slices.SortFunc(plans, func(a, b plan) int {
+		return cmp.Or(cmp.Compare(a.Memory, b.Memory), cmp.Compare(a.ID, b.ID))
+	})
+
+	var buf bytes.Buffer
+	if err := testTemplate.Execute(&buf, plans); err != nil {
+		return fmt.Errorf("execute template: %w", err)
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("format test: %w", err)
+	}
+	if err := os.WriteFile(output, src, 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", output, err)
+	}
+	return nil
+}
@@ -0,0 +1,333 @@
+package driver
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linode/linodego"
+
+	linodeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-client"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+// AccountEventsPath is the path the controller accepts the events of the
+// Linode account pushed to it on.
+const AccountEventsPath = "/events"
+
+const (
+	// accountEventsRecheckInterval is how often the waits for volumes fed
+	// by account events get the volume even without events, in case an
+	// event was missed.
+	accountEventsRecheckInterval = time.Minute
+
+	// maxPendingAccountEvents is the number of events in progress that are
+	// listed again until they complete. The oldest are dropped past it;
+	// the waits for their volumes then rely on the recheck.
+	maxPendingAccountEvents = 25
+
+	// maxAccountEventSize is the maximum size of a pushed event.
+	maxAccountEventSize = 64 << 10
+)
+
+// Sources of the account events, used as the "source" label of the
+// csi_account_events_total metric.
+const (
+	accountEventSourcePoll = "poll"
+	accountEventSourcePush = "push"
+)
+
+// accountEvents feeds the volume events of the Linode account, polled from
+// the events API with a cursor or pushed to the controller, to the waits for
+// volumes and the volume conditions, so that the controller gets a volume
+// when an event about it happens instead of polling each volume it waits
+// for.
+type accountEvents struct {
+	client linodeclient.LinodeClient
+
+	mu sync.Mutex // protects the fields below
+	// subscribers are notified of the events of their volume.
+	subscribers map[int]map[chan struct{}]struct{}
+	// latest is the latest event of each volume whose action is one of
+	// [volumeConditionActions].
+	latest map[int]*linodego.Event
+
+	// cursor is the ID of the latest event polled, and pending the IDs of
+	// the events polled in progress, which are polled again until they
+	// complete. They are only set once polled is true.
+	polled  bool
+	cursor  int
+	pending []int
+}
+
+func newAccountEvents(client linodeclient.LinodeClient) *accountEvents {
+	return &accountEvents{
+		client:      client,
+		subscribers: make(map[int]map[chan struct{}]struct{}),
+		latest:      make(map[int]*linodego.Event),
+	}
+}
+
+// subscribe returns a channel receiving a value when an event about
+// volumeID is published, and a function unsubscribing it.
+func (e *accountEvents) subscribe(volumeID int) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.subscribers[volumeID] == nil {
+		e.subscribers[volumeID] = make(map[chan struct{}]struct{})
+	}
+	e.subscribers[volumeID][ch] = struct{}{}
+
+	return ch, func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		delete(e.subscribers[volumeID], ch)
+		if len(e.subscribers[volumeID]) == 0 {
+			delete(e.subscribers, volumeID)
+		}
+	}
+}
+
+// publish notifies the subscribers of the volume of event, and records it
+// as the latest event of the volume.
+func (e *accountEvents) publish(event *linodego.Event, source string) {
+	if event.Entity == nil || event.Entity.Type != linodego.EntityVolume {
+		return
+	}
+	volumeID, ok := eventVolumeID(event)
+	if !ok {
+		return
+	}
+	observability.AccountEventsTotal.WithLabelValues(source).Inc()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch {
+	case event.Action == linodego.ActionVolumeDelete && event.Status == linodego.EventFinished:
+		delete(e.latest, volumeID)
+	case slices.Contains(volumeConditionActions, event.Action):
+		// Events are published again when their status changes
+		if latest, ok := e.latest[volumeID]; !ok || latest.ID <= event.ID {
+			e.latest[volumeID] = event
+		}
+	}
+	for ch := range e.subscribers[volumeID] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// latestEvent returns the latest event of volumeID whose action is one of
+// [volumeConditionActions] published since the controller started, if any.
+func (e *accountEvents) latestEvent(volumeID int) *linodego.Event {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.latest[volumeID]
+}
+
+// waitForVolume gets volumeID with client when an event about it is
+// published, and every [accountEventsRecheckInterval], until done returns
+// true for it or timeoutSeconds elapsed.
+func (e *accountEvents) waitForVolume(ctx context.Context, client linodeclient.LinodeClient, volumeID, timeoutSeconds int, done func(*linodego.Volume) bool) (*linodego.Volume, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeoutSeconds)*time.Second)
+	defer cancel()
+
+	// Before getting the volume, so that no event is missed
+	notify, unsubscribe := e.subscribe(volumeID)
+	defer unsubscribe()
+	recheck := time.NewTicker(accountEventsRecheckInterval)
+	defer recheck.Stop()
+
+	for {
+		vol, err := client.GetVolume(ctx, volumeID)
+		if err != nil {
+			return vol, err
+		}
+		if done(vol) {
+			return vol, nil
+		}
+		select {
+		case <-notify:
+		case <-recheck.C:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("wait for an event of volume %d: %w", volumeID, ctx.Err())
+		}
+	}
+}
+
+// run polls the events of the account every interval until ctx is
+// canceled.
+func (e *accountEvents) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := e.poll(ctx); err != nil {
+			logger.GetLogger(ctx).Error(err, "Failed to poll the events of the account")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll publishes the volume events of the account created after the cursor
+// and the events in progress that changed, and moves the cursor to the
+// latest of them. The first poll only sets the cursor.
+func (e *accountEvents) poll(ctx context.Context) error {
+	e.mu.Lock()
+	polled, cursor, pending := e.polled, e.cursor, slices.Clone(e.pending)
+	e.mu.Unlock()
+
+	if !polled {
+		events, err := e.listEvents(ctx, map[string]any{"+order_by": "id", "+order": "desc"}, minListPageSize)
+		if err != nil {
+			return err
+		}
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		e.polled = true
+		if len(events) > 0 {
+			e.cursor = events[0].ID
+		}
+		return nil
+	}
+
+	// The next pages are listed by the next polls
+	events, err := e.listEvents(ctx, map[string]any{"id": map[string]any{"+gt": cursor}, "+order_by": "id", "+order": "asc"}, maxListPageSize)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		anyID := make([]map[string]any, 0, len(pending))
+		for _, id := range pending {
+			anyID = append(anyID, map[string]any{"id": id})
+		}
+		updated, err := e.listEvents(ctx, map[string]any{"+or": anyID}, maxListPageSize)
+		if err != nil {
+			return err
+		}
+		events = append(updated, events...)
+	}
+
+	var stillPending []int
+	for i := range events {
+		event := &events[i]
+		if event.ID > cursor {
+			cursor = event.ID
+		}
+		if event.Status == linodego.EventStarted || event.Status == linodego.EventScheduled {
+			stillPending = append(stillPending, event.ID)
+			// Pending events are only published again once they complete
+			if slices.Contains(pending, event.ID) {
+				continue
+			}
+		}
+		e.publish(event, accountEventSourcePoll)
+	}
+	slices.Sort(stillPending)
+	stillPending = slices.Compact(stillPending)
+	if len(stillPending) > maxPendingAccountEvents {
+		stillPending = stillPending[len(stillPending)-maxPendingAccountEvents:]
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.cursor, e.pending = cursor, stillPending
+	return nil
+}
+
+// listEvents returns the first page of pageSize volume events of the
+// account matching the conditions in filter.
+func (e *accountEvents) listEvents(ctx context.Context, filter map[string]any, pageSize int) ([]linodego.Event, error) {
+	conditions := map[string]any{"entity.type": linodego.EntityVolume}
+	for key, value := range filter {
+		conditions[key] = value
+	}
+	jsonFilter, err := json.Marshal(conditions)
+	if err != nil {
+		return nil, fmt.Errorf("marshal json filter: %w", err)
+	}
+	events, err := e.client.ListEvents(ctx, &linodego.ListOptions{
+		PageOptions: &linodego.PageOptions{Page: 1},
+		PageSize:    pageSize,
+		Filter:      string(jsonFilter),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list events: %w", err)
+	}
+	return events, nil
+}
+
+// accountEventsHandler accepts the events of the account pushed to the
+// controller, one JSON event of the Linode API per request, from clients
+// sending token as a bearer token.
+type accountEventsHandler struct {
+	events *accountEvents
+	token  string
+}
+
+func (h *accountEventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var event linodego.Event
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxAccountEventSize)).Decode(&event); err != nil {
+		http.Error(w, "invalid event", http.StatusBadRequest)
+		return
+	}
+	h.events.publish(&event, accountEventSourcePush)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serve accepts the events of the account pushed to address until ctx is
+// canceled, from clients sending token as a bearer token.
+func (e *accountEvents) serve(ctx context.Context, address, token string) {
+	log := logger.GetLogger(ctx)
+
+	mux := http.NewServeMux()
+	mux.Handle(AccountEventsPath, &accountEventsHandler{events: e, token: token})
+	server := &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadTimeout:       10 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		IdleTimeout:       60 * time.Second,
+		ReadHeaderTimeout: 5 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		if err := server.Shutdown(context.Background()); err != nil {
+			log.Error(err, "Failed to stop the account events server")
+		}
+	}()
+
+	log.V(2).Info("Accepting pushed account events", "address", address, "path", AccountEventsPath)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Error(err, "Failed to serve the account events")
+	}
+}
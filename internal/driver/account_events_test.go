@@ -0,0 +1,144 @@
+package driver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/linode/linodego"
+	"go.uber.org/mock/gomock"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+)
+
+func volumeEvent(id, volumeID int, action linodego.EventAction, status linodego.EventStatus) linodego.Event {
+	return linodego.Event{
+		ID:     id,
+		Action: action,
+		Status: status,
+		Entity: &linodego.EventEntity{ID: volumeID, Type: linodego.EntityVolume},
+	}
+}
+
+func TestAccountEventsPoll(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockClient := mocks.NewMockLinodeClient(ctrl)
+
+	gomock.InOrder(
+		// The first poll only sets the cursor
+		mockClient.EXPECT().ListEvents(gomock.Any(), gomock.Any()).Return([]linodego.Event{volumeEvent(10, 1001, linodego.ActionVolumeCreate, linodego.EventFinished)}, nil),
+		mockClient.EXPECT().ListEvents(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, opts *linodego.ListOptions) ([]linodego.Event, error) {
+			if !strings.Contains(opts.Filter, `"id":{"+gt":10}`) {
+				t.Errorf("filter = %s, want the events after the cursor", opts.Filter)
+			}
+			return []linodego.Event{
+				volumeEvent(11, 1001, linodego.ActionVolumeAttach, linodego.EventStarted),
+				volumeEvent(12, 1002, linodego.ActionVolumeDetach, linodego.EventFinished),
+			}, nil
+		}),
+		// The pending event is listed again until it completes
+		mockClient.EXPECT().ListEvents(gomock.Any(), gomock.Any()).Return(nil, nil),
+		mockClient.EXPECT().ListEvents(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, opts *linodego.ListOptions) ([]linodego.Event, error) {
+			if !strings.Contains(opts.Filter, `"+or":[{"id":11}]`) {
+				t.Errorf("filter = %s, want the pending events", opts.Filter)
+			}
+			return []linodego.Event{volumeEvent(11, 1001, linodego.ActionVolumeAttach, linodego.EventFinished)}, nil
+		}),
+	)
+
+	e := newAccountEvents(mockClient)
+	notify, unsubscribe := e.subscribe(1001)
+	defer unsubscribe()
+
+	ctx := context.Background()
+	if err := e.poll(ctx); err != nil {
+		t.Fatalf("poll() error = %v", err)
+	}
+	if e.latestEvent(1001) != nil {
+		t.Error("the first poll published events")
+	}
+
+	if err := e.poll(ctx); err != nil {
+		t.Fatalf("poll() error = %v", err)
+	}
+	select {
+	case <-notify:
+	default:
+		t.Error("subscriber of volume 1001 was not notified")
+	}
+	if e.cursor != 12 || len(e.pending) != 1 {
+		t.Errorf("cursor = %d, pending = %v, want 12 and [11]", e.cursor, e.pending)
+	}
+
+	if err := e.poll(ctx); err != nil {
+		t.Fatalf("poll() error = %v", err)
+	}
+	if event := e.latestEvent(1001); event == nil || event.Status != linodego.EventFinished {
+		t.Errorf("latestEvent(1001) = %+v, want the finished attach", event)
+	}
+	if len(e.pending) != 0 {
+		t.Errorf("pending = %v, want none", e.pending)
+	}
+}
+
+func TestAccountEventsWaitForVolume(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockClient := mocks.NewMockLinodeClient(ctrl)
+
+	e := newAccountEvents(mockClient)
+	gomock.InOrder(
+		mockClient.EXPECT().GetVolume(gomock.Any(), 1001).DoAndReturn(func(context.Context, int) (*linodego.Volume, error) {
+			event := volumeEvent(11, 1001, linodego.ActionVolumeAttach, linodego.EventFinished)
+			e.publish(&event, accountEventSourcePush)
+			return &linodego.Volume{ID: 1001}, nil
+		}),
+		// Got again on the event, rather than after the recheck interval
+		mockClient.EXPECT().GetVolume(gomock.Any(), 1001).Return(&linodego.Volume{ID: 1001, LinodeID: createLinodeID(1003)}, nil),
+	)
+
+	vol, err := e.waitForVolume(context.Background(), mockClient, 1001, 5, func(vol *linodego.Volume) bool {
+		return vol.LinodeID != nil
+	})
+	if err != nil || vol.LinodeID == nil {
+		t.Errorf("waitForVolume() = %+v, %v, want the attached volume", vol, err)
+	}
+	if len(e.subscribers) != 0 {
+		t.Errorf("subscribers left = %v", e.subscribers)
+	}
+}
+
+func TestAccountEventsHandler(t *testing.T) {
+	e := newAccountEvents(nil)
+	handler := &accountEventsHandler{events: e, token: "secret"}
+	event := `{"id": 11, "action": "volume_attach", "status": "failed", "entity": {"id": 1001, "type": "volume"}}`
+
+	tests := []struct {
+		name   string
+		token  string
+		body   string
+		status int
+	}{
+		{name: "Unauthorized", token: "wrong", body: event, status: http.StatusUnauthorized},
+		{name: "Invalid", token: "secret", body: "{", status: http.StatusBadRequest},
+		{name: "Accepted", token: "secret", body: event, status: http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, AccountEventsPath, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+
+	if got := e.latestEvent(1001); got == nil || got.Status != linodego.EventFailed {
+		t.Errorf("latestEvent(1001) = %+v, want the failed attach", got)
+	}
+}
@@ -0,0 +1,145 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/linode/linodego"
+)
+
+// AttributeTagPrefix starts the tags of the attributes the driver stores on
+// the volumes it manages, as "csi-<key>:<value>", or "csi-<key>" for flags.
+// Keeping them in the tags of the volumes lets the controller find them again
+// after a restart, without another store.
+const AttributeTagPrefix = "csi-"
+
+const (
+	// minTagLength and maxTagLength bound the length of the tags accepted
+	// by the Linode API.
+	minTagLength = 3
+	maxTagLength = 50
+)
+
+// attributeKey is the key of a volume attribute.
+type attributeKey string
+
+const (
+	// attributeCluster is the hash of the [Options.ClusterName] of the
+	// cluster that created the volume.
+	attributeCluster attributeKey = "cluster"
+
+	// attributeCloneOf is the ID of the volume a volume is being cloned
+	// from, until the clone is active.
+	attributeCloneOf attributeKey = "clone-of"
+
+	// attributeProvisioning flags the volumes created by CreateVolume until
+	// they are active.
+	attributeProvisioning attributeKey = "provisioning"
+
+	// attributeDriver is the version of the driver that created or last
+	// modified the volume, and the hash of the features it had enabled, as
+	// "<version>/<hash>".
+	attributeDriver attributeKey = "driver"
+)
+
+// attributeKeys lists the keys of the attributes. The tags starting with
+// [AttributeTagPrefix] but another key are not attributes.
+var attributeKeys = []attributeKey{attributeCluster, attributeCloneOf, attributeProvisioning, attributeDriver}
+
+// attribute is a volume attribute. Flags have an empty value.
+type attribute struct {
+	key   attributeKey
+	value string
+}
+
+// tag encodes a as a tag, and fails if the tag would not fit the length or
+// characters of Linode tags.
+func (a attribute) tag() (string, error) {
+	tag := AttributeTagPrefix + string(a.key)
+	if a.value != "" {
+		tag += ":" + a.value
+	}
+	// Tags are joined with commas in the volumeTags parameter
+	if strings.Contains(a.value, ",") {
+		return "", fmt.Errorf("value %q of attribute %s contains a comma", a.value, a.key)
+	}
+	if len(tag) < minTagLength || len(tag) > maxTagLength {
+		return "", fmt.Errorf("attribute %s is encoded as a %d character tag, tags must have %d to %d characters", a.key, len(tag), minTagLength, maxTagLength)
+	}
+	return tag, nil
+}
+
+// parseAttribute decodes tag, or returns false if it is not an attribute.
+func parseAttribute(tag string) (attribute, bool) {
+	rest, ok := strings.CutPrefix(tag, AttributeTagPrefix)
+	if !ok {
+		return attribute{}, false
+	}
+	key, value, _ := strings.Cut(rest, ":")
+	if !slices.Contains(attributeKeys, attributeKey(key)) {
+		return attribute{}, false
+	}
+	return attribute{key: attributeKey(key), value: value}, true
+}
+
+// isAttributeTag reports whether tag is a volume attribute.
+func isAttributeTag(tag string) bool {
+	_, ok := parseAttribute(tag)
+	return ok
+}
+
+// getAttribute returns the value of the attribute with key in tags, or false
+// if tags do not have it.
+func getAttribute(tags []string, key attributeKey) (string, bool) {
+	for _, tag := range tags {
+		if a, ok := parseAttribute(tag); ok && a.key == key {
+			return a.value, true
+		}
+	}
+	return "", false
+}
+
+// withAttributes returns a copy of tags with the attributes of set, replacing
+// those with the same keys, and without the attributes with the keys of
+// remove. The other tags are kept in order, and the attributes of set are
+// appended in order.
+func withAttributes(tags []string, set []attribute, remove ...attributeKey) ([]string, error) {
+	replaced := slices.Clone(remove)
+	added := make([]string, 0, len(set))
+	for _, a := range set {
+		tag, err := a.tag()
+		if err != nil {
+			return nil, err
+		}
+		replaced = append(replaced, a.key)
+		added = append(added, tag)
+	}
+
+	result := make([]string, 0, len(tags)+len(added))
+	for _, tag := range tags {
+		if a, ok := parseAttribute(tag); ok && slices.Contains(replaced, a.key) {
+			continue
+		}
+		result = append(result, tag)
+	}
+	return append(result, added...), nil
+}
+
+// updateVolumeAttributes sets the attributes of set on vol and removes those
+// with the keys of remove, updating the tags of vol if they changed.
+func (cs *ControllerServer) updateVolumeAttributes(ctx context.Context, vol *linodego.Volume, set []attribute, remove ...attributeKey) error {
+	tags, err := withAttributes(vol.Tags, set, remove...)
+	if err != nil {
+		return errInternal("encode attributes of volume %d: %v", vol.ID, err)
+	}
+	if slices.Equal(tags, vol.Tags) {
+		return nil
+	}
+	if _, err := cs.linodeClient(ctx).UpdateVolume(ctx, vol.ID, linodego.VolumeUpdateOptions{Tags: &tags}); err != nil {
+		return errInternal("tag volume %d: %v", vol.ID, err)
+	}
+	vol.Tags = tags
+	return nil
+}
@@ -0,0 +1,80 @@
+package driver
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAttributeTag(t *testing.T) {
+	tests := []struct {
+		name      string
+		attribute attribute
+		want      string
+		wantErr   bool
+	}{
+		{
+			name:      "Value",
+			attribute: attribute{key: attributeCloneOf, value: "1001"},
+			want:      "csi-clone-of:1001",
+		},
+		{
+			name:      "Flag",
+			attribute: attribute{key: attributeProvisioning},
+			want:      ProvisioningTag,
+		},
+		{
+			name:      "Too long",
+			attribute: attribute{key: attributeCluster, value: strings.Repeat("a", 40)},
+			wantErr:   true,
+		},
+		{
+			name:      "Comma",
+			attribute: attribute{key: attributeCluster, value: "a,b"},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.attribute.tag()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("tag() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("tag() = %q, want %q", got, tt.want)
+			}
+			if err == nil {
+				if parsed, ok := parseAttribute(got); !ok || parsed != tt.attribute {
+					t.Errorf("parseAttribute(%q) = %+v, %v, want %+v", got, parsed, ok, tt.attribute)
+				}
+			}
+		})
+	}
+}
+
+func TestWithAttributes(t *testing.T) {
+	tags := []string{"team", "csi-cluster:34ab3e", "csi-unknown:1", ProvisioningTag}
+
+	got, err := withAttributes(tags, []attribute{{key: attributeCluster, value: "c0ffee"}, {key: attributeCloneOf, value: "2"}}, attributeProvisioning)
+	if err != nil {
+		t.Fatalf("withAttributes() error = %v", err)
+	}
+	if want := []string{"team", "csi-unknown:1", "csi-cluster:c0ffee", "csi-clone-of:2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("withAttributes() = %v, want %v", got, want)
+	}
+	if value, ok := getAttribute(got, attributeCluster); !ok || value != "c0ffee" {
+		t.Errorf("getAttribute(cluster) = %q, %v, want c0ffee", value, ok)
+	}
+	if _, ok := getAttribute(got, attributeProvisioning); ok {
+		t.Error("getAttribute(provisioning) = true after it was removed")
+	}
+	// The input tags are left unchanged
+	if tags[1] != "csi-cluster:34ab3e" {
+		t.Errorf("withAttributes() changed its input: %v", tags)
+	}
+
+	if _, err := withAttributes(tags, []attribute{{key: attributeCloneOf, value: strings.Repeat("1", 50)}}); err == nil {
+		t.Error("withAttributes() error = nil, want the attribute to exceed the tag length")
+	}
+}
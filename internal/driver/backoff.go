@@ -0,0 +1,160 @@
+package driver
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+// maxVolumeFailureBackoff bounds how long the requests for a failing volume
+// are refused, however long its failure streak.
+const maxVolumeFailureBackoff = 10 * time.Minute
+
+// volumeBackoffPath is the path of the backoff table on the observability
+// server.
+const volumeBackoffPath = "/debug/volume-backoff"
+
+// backoffKey identifies the requests of an operation on a volume: the name
+// of the volume to create, or the volume and node of an attachment.
+type backoffKey struct {
+	operation string
+	volume    string
+	node      string
+}
+
+// failureStreak is the consecutive failures of the requests of a key.
+type failureStreak struct {
+	Operation string    `json:"operation"`
+	Volume    string    `json:"volume"`
+	Node      string    `json:"node,omitempty"`
+	Failures  int       `json:"failures"`
+	LastError string    `json:"lastError"`
+	Until     time.Time `json:"until"`
+}
+
+// volumeBackoff refuses the requests for the volumes whose previous
+// requests kept failing, for a delay doubling with each failure, so that the
+// sidecars back off from volumes that cannot be provisioned or attached
+// (e.g. in a region without capacity) without delaying the others. Only the
+// failures that may be transient are counted: requests that are invalid
+// fail the same way without reaching the Linode API.
+//
+// The zero value is ready to use, and never refuses requests.
+type volumeBackoff struct {
+	// base is the delay after the first failure, none when it is zero.
+	base time.Duration
+
+	mu      sync.Mutex // protects streaks
+	streaks map[backoffKey]*failureStreak
+}
+
+// check returns [errVolumeBackoff] if the requests for key are refused.
+func (b *volumeBackoff) check(key backoffKey) error {
+	if b.base <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	streak, ok := b.streaks[key]
+	if !ok {
+		return nil
+	}
+	if delay := time.Until(streak.Until); delay > 0 {
+		return errVolumeBackoff(key, streak.Failures, delay)
+	}
+	return nil
+}
+
+// record ends the failure streak of key if err is nil, or extends it if err
+// may be transient.
+func (b *volumeBackoff) record(ctx context.Context, key backoffKey, err error) {
+	if b.base <= 0 {
+		return
+	}
+	switch status.Code(err) {
+	case codes.OK, codes.Internal, codes.Unknown, codes.DeadlineExceeded, codes.Unavailable, codes.ResourceExhausted:
+	default:
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.prune(now)
+	if err == nil {
+		delete(b.streaks, key)
+		return
+	}
+
+	streak, ok := b.streaks[key]
+	if !ok {
+		streak = &failureStreak{Operation: key.operation, Volume: key.volume, Node: key.node}
+		if b.streaks == nil {
+			b.streaks = make(map[backoffKey]*failureStreak)
+		}
+		b.streaks[key] = streak
+	}
+	streak.Failures++
+	streak.LastError = err.Error()
+	delay := maxVolumeFailureBackoff
+	if streak.Failures <= 32 {
+		delay = min(b.base<<(streak.Failures-1), maxVolumeFailureBackoff)
+	}
+	streak.Until = now.Add(delay)
+	logger.GetLogger(ctx).V(2).Info("Backing off from failing volume", "operation", key.operation, "volume", key.volume, "node", key.node, "failures", streak.Failures, "retry_after", delay)
+}
+
+// prune forgets the streaks whose volumes were not retried for
+// [maxVolumeFailureBackoff] after their delay, e.g. because they were
+// deleted.
+func (b *volumeBackoff) prune(now time.Time) {
+	for key, streak := range b.streaks {
+		if now.Sub(streak.Until) > maxVolumeFailureBackoff {
+			delete(b.streaks, key)
+		}
+	}
+}
+
+// table returns the failure streaks, by operation and volume.
+func (b *volumeBackoff) table() []failureStreak {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	streaks := make([]failureStreak, 0, len(b.streaks))
+	for _, streak := range b.streaks {
+		streaks = append(streaks, *streak)
+	}
+	slices.SortFunc(streaks, func(a, b failureStreak) int {
+		return cmp.Or(cmp.Compare(a.Operation, b.Operation), cmp.Compare(a.Volume, b.Volume), cmp.Compare(a.Node, b.Node))
+	})
+	return streaks
+}
+
+// ServeHTTP writes the failure streaks as JSON.
+func (b *volumeBackoff) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(b.table()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// DebugHandlers returns the handlers of the state of the controller served
+// by the observability server.
+func (cs *ControllerServer) DebugHandlers() map[string]http.Handler {
+	if cs == nil {
+		return nil
+	}
+	return map[string]http.Handler{volumeBackoffPath: &cs.backoff}
+}
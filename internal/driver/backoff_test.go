@@ -0,0 +1,115 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+)
+
+func TestVolumeBackoff(t *testing.T) {
+	ctx := context.Background()
+	key := backoffKey{operation: "CreateVolume", volume: "pvc-1"}
+	other := backoffKey{operation: "CreateVolume", volume: "pvc-2"}
+	b := &volumeBackoff{base: time.Minute}
+
+	// Invalid requests are not counted
+	b.record(ctx, key, status.Error(codes.InvalidArgument, "invalid region"))
+	if err := b.check(key); err != nil {
+		t.Fatalf("check() after an invalid request error = %v, want nil", err)
+	}
+
+	for failures, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute} {
+		b.record(ctx, key, status.Error(codes.Internal, "volume creation timed out"))
+		err := b.check(key)
+		if status.Code(err) != codes.Unavailable {
+			t.Fatalf("check() after %d failures error = %v, want code %v", failures+1, err, codes.Unavailable)
+		}
+		var retryDelay time.Duration
+		for _, detail := range status.Convert(err).Details() {
+			if info, ok := detail.(*errdetails.RetryInfo); ok {
+				retryDelay = info.GetRetryDelay().AsDuration()
+			}
+		}
+		if retryDelay != want {
+			t.Errorf("check() after %d failures retry delay = %s, want %s", failures+1, retryDelay, want)
+		}
+	}
+	if err := b.check(other); err != nil {
+		t.Errorf("check() of another volume error = %v, want nil", err)
+	}
+
+	// The delay is capped
+	b.streaks[key].Failures = 40
+	b.record(ctx, key, status.Error(codes.Internal, "volume creation timed out"))
+	if delay := time.Until(b.streaks[key].Until); delay > maxVolumeFailureBackoff {
+		t.Errorf("delay after 41 failures = %s, want at most %s", delay, maxVolumeFailureBackoff)
+	}
+
+	// The streak ends with a successful request, and old streaks are pruned
+	b.record(ctx, key, nil)
+	if err := b.check(key); err != nil {
+		t.Errorf("check() after a success error = %v, want nil", err)
+	}
+	b.record(ctx, other, status.Error(codes.Unavailable, "unavailable"))
+	b.streaks[other].Until = time.Now().Add(-2 * maxVolumeFailureBackoff)
+	b.record(ctx, key, nil)
+	if len(b.streaks) != 0 {
+		t.Errorf("streaks = %v, want the old streak pruned", b.streaks)
+	}
+
+	var disabled volumeBackoff
+	disabled.record(ctx, key, status.Error(codes.Internal, "volume creation timed out"))
+	if err := disabled.check(key); err != nil {
+		t.Errorf("check() with no base delay error = %v, want nil", err)
+	}
+}
+
+func TestVolumeBackoff_ServeHTTP(t *testing.T) {
+	cs := &ControllerServer{}
+	b := &cs.backoff
+	b.base = time.Minute
+	b.record(context.Background(), backoffKey{operation: "ControllerPublishVolume", volume: "1001-pvc", node: "12345"}, status.Error(codes.Internal, "attach failed"))
+	b.record(context.Background(), backoffKey{operation: "CreateVolume", volume: "pvc-1"}, status.Error(codes.Internal, "create failed"))
+
+	w := httptest.NewRecorder()
+	cs.DebugHandlers()[volumeBackoffPath].ServeHTTP(w, httptest.NewRequest("GET", volumeBackoffPath, nil))
+
+	var table []failureStreak
+	if err := json.NewDecoder(w.Body).Decode(&table); err != nil {
+		t.Fatalf("decode backoff table: %v", err)
+	}
+	if len(table) != 2 || table[0].Operation != "ControllerPublishVolume" || table[0].Node != "12345" || table[1].Volume != "pvc-1" || table[1].Failures != 1 {
+		t.Errorf("backoff table = %+v", table)
+	}
+}
+
+func TestCreateVolume_backoff(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The Linode API is not called while backing off
+	cs := &ControllerServer{
+		driver: &LinodeDriver{volumeLabelPrefix: "csi-linode-pv-"},
+		client: mocks.NewMockLinodeClient(ctrl),
+	}
+	cs.backoff.base = time.Minute
+	cs.backoff.record(context.Background(), backoffKey{operation: "CreateVolume", volume: "pvc-1"}, status.Error(codes.Internal, "create failed"))
+
+	_, err := cs.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+		Name:               "pvc-1",
+		VolumeCapabilities: []*csi.VolumeCapability{{AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER}}},
+	})
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("CreateVolume() error = %v, want code %v", err, codes.Unavailable)
+	}
+}
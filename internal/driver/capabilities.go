@@ -14,6 +14,8 @@ func ControllerServiceCapabilities() []*csi.ControllerServiceCapability {
 		csi.ControllerServiceCapability_RPC_LIST_VOLUMES,
 		csi.ControllerServiceCapability_RPC_LIST_VOLUMES_PUBLISHED_NODES,
 		csi.ControllerServiceCapability_RPC_VOLUME_CONDITION,
+		csi.ControllerServiceCapability_RPC_GET_VOLUME,
+		csi.ControllerServiceCapability_RPC_MODIFY_VOLUME,
 	}
 
 	cc := make([]*csi.ControllerServiceCapability, 0, len(capabilities))
@@ -35,8 +37,15 @@ func NodeServiceCapabilities() []*csi.NodeServiceCapability {
 	capabilities := []csi.NodeServiceCapability_RPC_Type{
 		csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME,
 		csi.NodeServiceCapability_RPC_EXPAND_VOLUME,
-		csi.NodeServiceCapability_RPC_GET_VOLUME_STATS,
-		csi.NodeServiceCapability_RPC_VOLUME_CONDITION,
+	}
+	// The kubelet calls NodeGetVolumeStats for every published volume when
+	// they are advertised, so they are only advertised where it is
+	// implemented.
+	if volumeStatsSupported {
+		capabilities = append(capabilities,
+			csi.NodeServiceCapability_RPC_GET_VOLUME_STATS,
+			csi.NodeServiceCapability_RPC_VOLUME_CONDITION,
+		)
 	}
 
 	cc := make([]*csi.NodeServiceCapability, 0, len(capabilities))
@@ -0,0 +1,212 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/linode/linodego"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	utilexec "k8s.io/utils/exec"
+
+	kubeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/kube-client"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+const (
+	// VerifyCloneAttribute is the StorageClass parameter key used to verify
+	// volumes cloned from another volume. It is passed to the node plugin
+	// through the volume context.
+	VerifyCloneAttribute = Name + "/verify-clone"
+
+	// CloneVerificationAnnotation is the PersistentVolume annotation the
+	// node plugin writes the result of the verification of a cloned volume
+	// to, when [Options.AnnotateCloneVerification] is enabled.
+	CloneVerificationAnnotation = Name + "/clone-verification"
+
+	// PVNameParameter is set by the external provisioner when it runs with
+	// --extra-create-metadata. It is passed to the node plugin through the
+	// volume context of verified clones, so it knows which volume to
+	// annotate.
+	PVNameParameter = "csi.storage.k8s.io/pv/name"
+)
+
+// Values of the [CloneVerificationAnnotation].
+const (
+	cloneVerified           = "verified"
+	cloneVerificationFailed = "failed"
+)
+
+// errCloneVerification indicates a cloned volume failed verification.
+func errCloneVerification(format string, args ...any) error {
+	return status.Errorf(codes.FailedPrecondition, "clone verification failed: "+format, args...)
+}
+
+// verifyClone checks that the clone vol has the same size as the volume with
+// sourceID it was cloned from. The contents of the clone are checked by the
+// node plugin, the first time it is staged.
+func (cs *ControllerServer) verifyClone(ctx context.Context, vol *linodego.Volume, sourceID int) error {
+	log := logger.GetLogger(ctx)
+	log.V(4).Info("Entering verifyClone()", "volume_id", vol.ID, "source_vol_id", sourceID)
+	defer log.V(4).Info("Exiting verifyClone()")
+
+	source, err := cs.linodeClient(ctx).GetVolume(ctx, sourceID)
+	if err != nil {
+		return errInternal("get volume %d: %v", sourceID, err)
+	}
+	if vol.Size != source.Size {
+		return errCloneVerification("volume %d is %d GB, but its source volume %d is %d GB", vol.ID, vol.Size, sourceID, source.Size)
+	}
+
+	log.V(2).Info("Clone size verified", "volume_id", vol.ID, "source_vol_id", sourceID, "size", vol.Size)
+	return nil
+}
+
+// needsCloneVerification reports whether the volume with volumeContext is a
+// clone whose contents must be verified before it is mounted. Clones are
+// only verified once when [Options.AnnotateCloneVerification] is enabled;
+// otherwise they are verified each time they are staged.
+func (ns *NodeServer) needsCloneVerification(ctx context.Context, volumeContext map[string]string) bool {
+	if volumeContext[VerifyCloneAttribute] != True {
+		return false
+	}
+
+	client := ns.cloneVerificationClient()
+	pvName := volumeContext[PVNameParameter]
+	if client == nil || pvName == "" {
+		return true
+	}
+
+	annotations, err := client.GetPersistentVolumeAnnotations(ctx, pvName)
+	if err != nil && !errors.Is(err, kubeclient.ErrNotFound) {
+		logger.GetLogger(ctx).Error(err, "Failed to get clone verification status", "pv", pvName)
+	}
+	return annotations[CloneVerificationAnnotation] != cloneVerified
+}
+
+// checkCloneDevice fails if the device at devicePath of a cloned volume does
+// not hold any data, so it is not formatted over.
+func (ns *NodeServer) checkCloneDevice(ctx context.Context, devicePath string) error {
+	format, err := ns.diskFormat(ctx, devicePath)
+	if err != nil {
+		return errInternal("get disk format of %s: %v", devicePath, err)
+	}
+	if format == "" {
+		return errCloneVerification("device %s of the cloned volume holds no file system", devicePath)
+	}
+	return nil
+}
+
+// checkCloneFilesystem runs a read-only check of the file system of a cloned
+// volume at source, before it is mounted and repaired.
+func (ns *NodeServer) checkCloneFilesystem(ctx context.Context, source, fsType string) error {
+	log := logger.GetLogger(ctx)
+	log.V(4).Info("Entering checkCloneFilesystem()", "source", source, "fsType", fsType)
+	defer log.V(4).Info("Exiting checkCloneFilesystem()")
+
+	format, err := ns.diskFormat(ctx, source)
+	if err != nil {
+		return errInternal("get disk format of %s: %v", source, err)
+	}
+	if format != fsType {
+		return errCloneVerification("cloned volume holds a %q file system, expected %q", format, fsType)
+	}
+
+	if fsType == "xfs" {
+		args := []string{"-n", source}
+		if out, err := ns.mounter.Exec.Command("xfs_repair", args...).CombinedOutput(); err != nil {
+			return errCloneVerification("xfs_repair %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+	return ns.checkCloneExtFilesystem(ctx, source)
+}
+
+// e2fsck exit codes, which are bits of the exit status.
+const (
+	e2fsckErrorsCorrected   = 1
+	e2fsckRebootRequired    = 2
+	e2fsckErrorsUncorrected = 4
+)
+
+// e2fsckJournalSkipped is printed by e2fsck -n when the journal of the file
+// system needs to be replayed, which it does not do in read-only mode.
+const e2fsckJournalSkipped = "skipping journal recovery"
+
+// checkCloneExtFilesystem runs a read-only check of the ext file system of a
+// cloned volume at source.
+//
+// A volume cloned while it was mounted has a journal to replay, and e2fsck -n
+// reports the blocks it has not replayed yet as errors. In that case the
+// journal is replayed, as mounting the volume would, and the file system is
+// checked again. Errors left after that, and e2fsck failures, fail the
+// check.
+func (ns *NodeServer) checkCloneExtFilesystem(ctx context.Context, source string) error {
+	log := logger.GetLogger(ctx)
+
+	check := func() (int, string, error) {
+		out, err := ns.mounter.Exec.Command("e2fsck", "-n", source).CombinedOutput()
+		if err == nil {
+			return 0, string(out), nil
+		}
+		var exitErr utilexec.ExitError
+		if !errors.As(err, &exitErr) {
+			return 0, string(out), errCloneVerification("e2fsck -n %s: %v", source, err)
+		}
+		return exitErr.ExitStatus(), string(out), nil
+	}
+
+	code, out, err := check()
+	if err != nil {
+		return err
+	}
+	if code == e2fsckErrorsUncorrected && strings.Contains(out, e2fsckJournalSkipped) {
+		log.V(2).Info("Replaying the journal of the cloned volume before checking it again", "source", source)
+		if out, err := ns.mounter.Exec.Command("e2fsck", "-p", "-E", "journal_only", source).CombinedOutput(); err != nil {
+			return errCloneVerification("replay journal of %s: %v: %s", source, err, strings.TrimSpace(string(out)))
+		}
+		if code, out, err = check(); err != nil {
+			return err
+		}
+	}
+	// Only the bits reporting corrected errors are harmless: e2fsck -n does
+	// not correct anything, but they do not mean the file system is damaged.
+	if code&^(e2fsckErrorsCorrected|e2fsckRebootRequired) != 0 {
+		return errCloneVerification("e2fsck -n %s: exit status %d: %s", source, code, strings.TrimSpace(out))
+	}
+	return nil
+}
+
+// recordCloneVerification logs the result of the verification of a cloned
+// volume, and writes it to its PersistentVolume if
+// [Options.AnnotateCloneVerification] is enabled.
+func (ns *NodeServer) recordCloneVerification(ctx context.Context, volumeContext map[string]string, verifyErr error) {
+	log := logger.GetLogger(ctx)
+
+	result := cloneVerified
+	if verifyErr != nil {
+		result = cloneVerificationFailed
+		log.Error(verifyErr, "Cloned volume failed verification", "pv", volumeContext[PVNameParameter])
+	} else {
+		log.V(2).Info("Cloned volume verified", "pv", volumeContext[PVNameParameter])
+	}
+
+	client := ns.cloneVerificationClient()
+	pvName := volumeContext[PVNameParameter]
+	if client == nil || pvName == "" {
+		return
+	}
+	if err := client.PatchPersistentVolumeAnnotations(ctx, pvName, map[string]string{CloneVerificationAnnotation: result}); err != nil {
+		log.Error(err, "Failed to record clone verification", "pv", pvName)
+	}
+}
+
+// cloneVerificationClient returns the client used to record the
+// verification of cloned volumes, or nil if it is disabled.
+func (ns *NodeServer) cloneVerificationClient() kubeclient.KubeClient {
+	if ns.driver == nil || !ns.driver.opts.AnnotateCloneVerification {
+		return nil
+	}
+	return ns.driver.opts.KubeClient
+}
@@ -0,0 +1,172 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	kubeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/kube-client"
+	linodevolumes "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-volumes"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+// CrossNamespaceClonePolicy is whether CreateVolume clones volumes whose
+// PersistentVolumeClaim is in another namespace than the claim of the new
+// volume, as requested with the data sources of kind PersistentVolumeClaim
+// referring to another namespace.
+type CrossNamespaceClonePolicy string
+
+const (
+	// CrossNamespaceClonesAllow clones volumes across namespaces, leaving
+	// the authorization of the clones to the external provisioner. It is
+	// the default.
+	CrossNamespaceClonesAllow CrossNamespaceClonePolicy = "allow"
+
+	// CrossNamespaceClonesReferenceGrant only clones volumes across
+	// namespaces when a ReferenceGrant in the namespace of the source claim
+	// allows the claims of the namespace of the new claim to refer to it.
+	CrossNamespaceClonesReferenceGrant CrossNamespaceClonePolicy = "referencegrant"
+
+	// CrossNamespaceClonesDeny never clones volumes across namespaces.
+	CrossNamespaceClonesDeny CrossNamespaceClonePolicy = "deny"
+)
+
+// ParseCrossNamespaceClonePolicy parses a cross-namespace clone policy. The
+// empty string is [CrossNamespaceClonesAllow].
+func ParseCrossNamespaceClonePolicy(s string) (CrossNamespaceClonePolicy, error) {
+	switch policy := CrossNamespaceClonePolicy(s); policy {
+	case "":
+		return CrossNamespaceClonesAllow, nil
+	case CrossNamespaceClonesAllow, CrossNamespaceClonesReferenceGrant, CrossNamespaceClonesDeny:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid cross-namespace clone policy %q, must be %q, %q or %q",
+			s, CrossNamespaceClonesAllow, CrossNamespaceClonesReferenceGrant, CrossNamespaceClonesDeny)
+	}
+}
+
+// persistentVolumeClaimKind is the kind of the objects ReferenceGrants must
+// allow to refer to each other for cross-namespace clones, in the core API
+// group.
+const persistentVolumeClaimKind = "PersistentVolumeClaim"
+
+// errCrossNamespaceClone indicates a clone across namespaces was refused.
+func errCrossNamespaceClone(format string, args ...any) error {
+	return status.Errorf(codes.PermissionDenied, "cross-namespace clone refused: "+format, args...)
+}
+
+// checkCloneNamespace applies the cross-namespace clone policy of the driver
+// to the clone of the volume source requested with parameters, and writes
+// the audit record of the clone with the namespaces and names of the source
+// and target claims.
+//
+// The namespace of the target claim is passed in parameters by the external
+// provisioner with --extra-create-metadata, and the source
+// claim is the one bound to the PersistentVolume of source. When either is
+// unknown, the clone is only refused if the policy is not
+// [CrossNamespaceClonesAllow].
+func (cs *ControllerServer) checkCloneNamespace(ctx context.Context, parameters map[string]string, source *linodevolumes.LinodeVolumeKey) error {
+	log := logger.GetLogger(ctx)
+	log.V(4).Info("Entering checkCloneNamespace()", "source_vol_id", source.VolumeID)
+	defer log.V(4).Info("Exiting checkCloneNamespace()")
+
+	policy := CrossNamespaceClonesAllow
+	var kube kubeclient.KubeClient
+	if cs.driver != nil {
+		if cs.driver.opts.CrossNamespaceClones != "" {
+			policy = cs.driver.opts.CrossNamespaceClones
+		}
+		kube = cs.driver.opts.KubeClient
+	}
+	targetNamespace, targetName := parameters[PVCNamespaceParameter], parameters[PVCNameParameter]
+
+	var claim kubeclient.PersistentVolume
+	var err error
+	if kube == nil {
+		err = errors.New("no Kubernetes client")
+	} else {
+		claim, err = cs.sourceClaim(ctx, kube, source)
+	}
+
+	log.V(2).Info("Clone requested",
+		"source_vol_id", source.VolumeID,
+		"sourceNamespace", claim.ClaimNamespace,
+		"sourceName", claim.ClaimName,
+		"targetNamespace", targetNamespace,
+		"targetName", targetName,
+		"policy", policy)
+
+	if policy == CrossNamespaceClonesAllow {
+		if err != nil {
+			log.V(4).Info("Source claim of clone unknown", "source_vol_id", source.VolumeID, "error", err.Error())
+		}
+		return nil
+	}
+	if err != nil {
+		return errInternal("find claim of source volume %d: %v", source.VolumeID, err)
+	}
+	if claim.ClaimNamespace == "" || targetNamespace == "" {
+		return status.Errorf(codes.FailedPrecondition,
+			"cannot tell whether the clone of volume %d crosses namespaces, the external provisioner must run with --extra-create-metadata and the source volume must be bound",
+			source.VolumeID)
+	}
+	if claim.ClaimNamespace == targetNamespace {
+		return nil
+	}
+	if policy == CrossNamespaceClonesDeny {
+		return errCrossNamespaceClone("claims in namespace %s cannot clone %s/%s", targetNamespace, claim.ClaimNamespace, claim.ClaimName)
+	}
+
+	grants, err := kube.ListReferenceGrants(ctx, claim.ClaimNamespace)
+	if err != nil && !errors.Is(err, kubeclient.ErrNotFound) {
+		return errInternal("list reference grants in %s: %v", claim.ClaimNamespace, err)
+	}
+	for _, grant := range grants {
+		if referenceGrantAllowsClone(grant, targetNamespace, claim.ClaimName) {
+			log.V(2).Info("Cross-namespace clone allowed", "referenceGrant", claim.ClaimNamespace+"/"+grant.Name)
+			return nil
+		}
+	}
+	return errCrossNamespaceClone("no ReferenceGrant in namespace %s allows claims in namespace %s to clone %s",
+		claim.ClaimNamespace, targetNamespace, claim.ClaimName)
+}
+
+// sourceClaim returns the PersistentVolume of the driver whose volume is
+// source, with the claim bound to it.
+func (cs *ControllerServer) sourceClaim(ctx context.Context, kube kubeclient.KubeClient, source *linodevolumes.LinodeVolumeKey) (kubeclient.PersistentVolume, error) {
+	pvs, err := kube.ListPersistentVolumes(ctx, cs.driver.name)
+	if err != nil {
+		return kubeclient.PersistentVolume{}, err
+	}
+	for _, pv := range pvs {
+		key, err := linodevolumes.ParseLinodeVolumeKey(pv.VolumeHandle)
+		if err == nil && key.VolumeID == source.VolumeID {
+			return pv, nil
+		}
+	}
+	return kubeclient.PersistentVolume{}, fmt.Errorf("no persistent volume of volume %d", source.VolumeID)
+}
+
+// referenceGrantAllowsClone reports whether grant allows the claims in
+// namespace to refer to the claim named name, in the namespace of grant.
+func referenceGrantAllowsClone(grant kubeclient.ReferenceGrant, namespace, name string) bool {
+	from := false
+	for _, f := range grant.From {
+		if f.Group == "" && f.Kind == persistentVolumeClaimKind && f.Namespace == namespace {
+			from = true
+			break
+		}
+	}
+	if !from {
+		return false
+	}
+	for _, t := range grant.To {
+		if t.Group == "" && t.Kind == persistentVolumeClaimKind && (t.Name == "" || t.Name == name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package driver
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+	kubeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/kube-client"
+	linodevolumes "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-volumes"
+)
+
+func TestParseCrossNamespaceClonePolicy(t *testing.T) {
+	for s, want := range map[string]CrossNamespaceClonePolicy{
+		"":               CrossNamespaceClonesAllow,
+		"allow":          CrossNamespaceClonesAllow,
+		"referencegrant": CrossNamespaceClonesReferenceGrant,
+		"deny":           CrossNamespaceClonesDeny,
+	} {
+		if got, err := ParseCrossNamespaceClonePolicy(s); err != nil || got != want {
+			t.Errorf("ParseCrossNamespaceClonePolicy(%q) = %q, %v, want %q", s, got, err, want)
+		}
+	}
+	if _, err := ParseCrossNamespaceClonePolicy("grant"); err == nil {
+		t.Error("ParseCrossNamespaceClonePolicy(\"grant\") succeeded, want error")
+	}
+}
+
+func TestCheckCloneNamespace(t *testing.T) {
+	sourcePVs := []kubeclient.PersistentVolume{
+		{Name: "pvc-other", VolumeHandle: "1002-pvcother", ClaimNamespace: "prod", ClaimName: "other"},
+		{Name: "pvc-data", VolumeHandle: "1001-pvcdata", ClaimNamespace: "prod", ClaimName: "data"},
+	}
+	grant := func(fromNamespace, toName string) kubeclient.ReferenceGrant {
+		return kubeclient.ReferenceGrant{
+			Name: "clones",
+			From: []kubeclient.ReferenceGrantFrom{{Kind: "PersistentVolumeClaim", Namespace: fromNamespace}},
+			To:   []kubeclient.ReferenceGrantTo{{Kind: "PersistentVolumeClaim", Name: toName}},
+		}
+	}
+
+	tests := []struct {
+		name            string
+		policy          CrossNamespaceClonePolicy
+		noKube          bool
+		targetNamespace string
+		grants          []kubeclient.ReferenceGrant
+		grantsErr       error
+		wantCode        codes.Code
+	}{
+		{
+			name:            "Allowed without client",
+			noKube:          true,
+			targetNamespace: "dev",
+		},
+		{
+			name:            "Allowed across namespaces",
+			policy:          CrossNamespaceClonesAllow,
+			targetNamespace: "dev",
+		},
+		{
+			name:            "Same namespace with deny",
+			policy:          CrossNamespaceClonesDeny,
+			targetNamespace: "prod",
+		},
+		{
+			name:            "Denied across namespaces",
+			policy:          CrossNamespaceClonesDeny,
+			targetNamespace: "dev",
+			wantCode:        codes.PermissionDenied,
+		},
+		{
+			name:     "Unknown target namespace",
+			policy:   CrossNamespaceClonesDeny,
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			name:            "Granted for the source claim",
+			policy:          CrossNamespaceClonesReferenceGrant,
+			targetNamespace: "dev",
+			grants:          []kubeclient.ReferenceGrant{grant("dev", "data")},
+		},
+		{
+			name:            "Granted for every claim",
+			policy:          CrossNamespaceClonesReferenceGrant,
+			targetNamespace: "dev",
+			grants:          []kubeclient.ReferenceGrant{grant("dev", "")},
+		},
+		{
+			name:            "Granted for another claim",
+			policy:          CrossNamespaceClonesReferenceGrant,
+			targetNamespace: "dev",
+			grants:          []kubeclient.ReferenceGrant{grant("dev", "other")},
+			wantCode:        codes.PermissionDenied,
+		},
+		{
+			name:            "Granted to another namespace",
+			policy:          CrossNamespaceClonesReferenceGrant,
+			targetNamespace: "dev",
+			grants:          []kubeclient.ReferenceGrant{grant("staging", "data")},
+			wantCode:        codes.PermissionDenied,
+		},
+		{
+			name:            "ReferenceGrants not installed",
+			policy:          CrossNamespaceClonesReferenceGrant,
+			targetNamespace: "dev",
+			grantsErr:       kubeclient.ErrNotFound,
+			wantCode:        codes.PermissionDenied,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			driver := &LinodeDriver{name: Name, opts: Options{CrossNamespaceClones: tt.policy}}
+			if !tt.noKube {
+				kube := mocks.NewMockKubeClient(ctrl)
+				kube.EXPECT().ListPersistentVolumes(gomock.Any(), Name).Return(sourcePVs, nil)
+				if tt.grants != nil || tt.grantsErr != nil {
+					kube.EXPECT().ListReferenceGrants(gomock.Any(), "prod").Return(tt.grants, tt.grantsErr)
+				}
+				driver.opts.KubeClient = kube
+			}
+
+			cs := &ControllerServer{driver: driver}
+			parameters := map[string]string{PVCNameParameter: "clone"}
+			if tt.targetNamespace != "" {
+				parameters[PVCNamespaceParameter] = tt.targetNamespace
+			}
+			err := cs.checkCloneNamespace(context.Background(), parameters, &linodevolumes.LinodeVolumeKey{VolumeID: 1001, Label: "pvc-data"})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("checkCloneNamespace() error = %v, want code %v", err, tt.wantCode)
+			}
+		})
+	}
+}
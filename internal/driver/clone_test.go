@@ -0,0 +1,290 @@
+//go:build linux
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/linode/linodego"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/mount-utils"
+	"k8s.io/utils/exec"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+)
+
+func TestVerifyClone(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(*mocks.MockLinodeClient)
+		wantCode codes.Code
+	}{
+		{
+			name: "Same size",
+			setup: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(&linodego.Volume{ID: 1001, Size: 20}, nil)
+			},
+			wantCode: codes.OK,
+		},
+		{
+			name: "Size mismatch",
+			setup: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(&linodego.Volume{ID: 1001, Size: 30}, nil)
+			},
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			name: "Source volume error",
+			setup: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(nil, errors.New("API error"))
+			},
+			wantCode: codes.Internal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			client := mocks.NewMockLinodeClient(ctrl)
+			tt.setup(client)
+			cs := &ControllerServer{client: client}
+
+			err := cs.verifyClone(context.Background(), &linodego.Volume{ID: 1002, Size: 20}, 1001)
+			if status.Code(err) != tt.wantCode {
+				t.Errorf("verifyClone() error = %v, want code %v", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestNeedsCloneVerification(t *testing.T) {
+	tests := []struct {
+		name          string
+		volumeContext map[string]string
+		annotate      bool
+		setup         func(*mocks.MockKubeClient)
+		want          bool
+	}{
+		{
+			name:          "Not a verified clone",
+			volumeContext: map[string]string{},
+			want:          false,
+		},
+		{
+			name:          "Verified clone without annotations",
+			volumeContext: map[string]string{VerifyCloneAttribute: True, PVNameParameter: "pv-1"},
+			want:          true,
+		},
+		{
+			name:          "Clone not verified yet",
+			volumeContext: map[string]string{VerifyCloneAttribute: True, PVNameParameter: "pv-1"},
+			annotate:      true,
+			setup: func(m *mocks.MockKubeClient) {
+				m.EXPECT().GetPersistentVolumeAnnotations(gomock.Any(), "pv-1").Return(nil, nil)
+			},
+			want: true,
+		},
+		{
+			name:          "Clone already verified",
+			volumeContext: map[string]string{VerifyCloneAttribute: True, PVNameParameter: "pv-1"},
+			annotate:      true,
+			setup: func(m *mocks.MockKubeClient) {
+				m.EXPECT().GetPersistentVolumeAnnotations(gomock.Any(), "pv-1").Return(map[string]string{CloneVerificationAnnotation: cloneVerified}, nil)
+			},
+			want: false,
+		},
+		{
+			name:          "Clone failed verification",
+			volumeContext: map[string]string{VerifyCloneAttribute: True, PVNameParameter: "pv-1"},
+			annotate:      true,
+			setup: func(m *mocks.MockKubeClient) {
+				m.EXPECT().GetPersistentVolumeAnnotations(gomock.Any(), "pv-1").Return(map[string]string{CloneVerificationAnnotation: cloneVerificationFailed}, nil)
+			},
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			kubeClient := mocks.NewMockKubeClient(ctrl)
+			if tt.setup != nil {
+				tt.setup(kubeClient)
+			}
+			ns := &NodeServer{
+				driver: &LinodeDriver{opts: Options{AnnotateCloneVerification: tt.annotate, KubeClient: kubeClient}},
+			}
+
+			if got := ns.needsCloneVerification(context.Background(), tt.volumeContext); got != tt.want {
+				t.Errorf("needsCloneVerification() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckCloneFilesystem(t *testing.T) {
+	blkid := func(m *mocks.MockExecutor, c *mocks.MockCommand, output string, err error) {
+		m.EXPECT().Command("blkid", gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "/dev/sdb").Return(c)
+		c.EXPECT().CombinedOutput().Return([]byte(output), err)
+	}
+
+	tests := []struct {
+		name     string
+		fsType   string
+		expect   func(*mocks.MockExecutor, *mocks.MockCommand)
+		wantCode codes.Code
+	}{
+		{
+			name:   "Clean ext4 file system",
+			fsType: "ext4",
+			expect: func(m *mocks.MockExecutor, c *mocks.MockCommand) {
+				blkid(m, c, "DEVNAME=/dev/sdb\nTYPE=ext4\n", nil)
+				m.EXPECT().Command("e2fsck", "-n", "/dev/sdb").Return(c)
+				c.EXPECT().CombinedOutput().Return([]byte("clean"), nil)
+			},
+			wantCode: codes.OK,
+		},
+		{
+			name:   "Journal to replay",
+			fsType: "ext4",
+			expect: func(m *mocks.MockExecutor, c *mocks.MockCommand) {
+				blkid(m, c, "DEVNAME=/dev/sdb\nTYPE=ext4\n", nil)
+				gomock.InOrder(
+					m.EXPECT().Command("e2fsck", "-n", "/dev/sdb").Return(c),
+					c.EXPECT().CombinedOutput().Return([]byte("Warning: skipping journal recovery because doing a read-only filesystem check.\nFree blocks count wrong"), exec.CodeExitError{Code: 4, Err: fmt.Errorf("exit status 4")}),
+					m.EXPECT().Command("e2fsck", "-p", "-E", "journal_only", "/dev/sdb").Return(c),
+					c.EXPECT().CombinedOutput().Return(nil, nil),
+					m.EXPECT().Command("e2fsck", "-n", "/dev/sdb").Return(c),
+					c.EXPECT().CombinedOutput().Return([]byte("clean"), nil),
+				)
+			},
+			wantCode: codes.OK,
+		},
+		{
+			name:   "Errors left after replaying the journal",
+			fsType: "ext4",
+			expect: func(m *mocks.MockExecutor, c *mocks.MockCommand) {
+				blkid(m, c, "DEVNAME=/dev/sdb\nTYPE=ext4\n", nil)
+				gomock.InOrder(
+					m.EXPECT().Command("e2fsck", "-n", "/dev/sdb").Return(c),
+					c.EXPECT().CombinedOutput().Return([]byte("Warning: skipping journal recovery because doing a read-only filesystem check."), exec.CodeExitError{Code: 4, Err: fmt.Errorf("exit status 4")}),
+					m.EXPECT().Command("e2fsck", "-p", "-E", "journal_only", "/dev/sdb").Return(c),
+					c.EXPECT().CombinedOutput().Return(nil, nil),
+					m.EXPECT().Command("e2fsck", "-n", "/dev/sdb").Return(c),
+					c.EXPECT().CombinedOutput().Return([]byte("Inode 12 has illegal blocks"), exec.CodeExitError{Code: 4, Err: fmt.Errorf("exit status 4")}),
+				)
+			},
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			name:   "Corrupted ext4 file system",
+			fsType: "ext4",
+			expect: func(m *mocks.MockExecutor, c *mocks.MockCommand) {
+				blkid(m, c, "DEVNAME=/dev/sdb\nTYPE=ext4\n", nil)
+				m.EXPECT().Command("e2fsck", "-n", "/dev/sdb").Return(c)
+				c.EXPECT().CombinedOutput().Return([]byte("Inode 12 has illegal blocks"), exec.CodeExitError{Code: 4, Err: fmt.Errorf("exit status 4")})
+			},
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			name:   "e2fsck failure",
+			fsType: "ext4",
+			expect: func(m *mocks.MockExecutor, c *mocks.MockCommand) {
+				blkid(m, c, "DEVNAME=/dev/sdb\nTYPE=ext4\n", nil)
+				m.EXPECT().Command("e2fsck", "-n", "/dev/sdb").Return(c)
+				c.EXPECT().CombinedOutput().Return([]byte("Bad magic number in super-block"), exec.CodeExitError{Code: 8, Err: fmt.Errorf("exit status 8")})
+			},
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			name:   "Corrupted xfs file system",
+			fsType: "xfs",
+			expect: func(m *mocks.MockExecutor, c *mocks.MockCommand) {
+				blkid(m, c, "DEVNAME=/dev/sdb\nTYPE=xfs\n", nil)
+				m.EXPECT().Command("xfs_repair", "-n", "/dev/sdb").Return(c)
+				c.EXPECT().CombinedOutput().Return([]byte("bad superblock"), exec.CodeExitError{Code: 1, Err: fmt.Errorf("exit status 1")})
+			},
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			name:   "Unexpected file system",
+			fsType: "ext4",
+			expect: func(m *mocks.MockExecutor, c *mocks.MockCommand) {
+				blkid(m, c, "DEVNAME=/dev/sdb\nTYPE=xfs\n", nil)
+			},
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			name:   "No file system",
+			fsType: "ext4",
+			expect: func(m *mocks.MockExecutor, c *mocks.MockCommand) {
+				blkid(m, c, "", exec.CodeExitError{Code: 2, Err: fmt.Errorf("not formatted")})
+			},
+			wantCode: codes.FailedPrecondition,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockExec := mocks.NewMockExecutor(ctrl)
+			mockCommand := mocks.NewMockCommand(ctrl)
+			tt.expect(mockExec, mockCommand)
+
+			ns := &NodeServer{
+				mounter: &mount.SafeFormatAndMount{
+					Interface: mocks.NewMockMounter(ctrl),
+					Exec:      mockExec,
+				},
+			}
+			err := ns.checkCloneFilesystem(context.Background(), "/dev/sdb", tt.fsType)
+			if status.Code(err) != tt.wantCode {
+				t.Errorf("checkCloneFilesystem() error = %v, want code %v", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestRecordCloneVerification(t *testing.T) {
+	tests := []struct {
+		name      string
+		verifyErr error
+		want      string
+	}{
+		{
+			name: "Verified",
+			want: cloneVerified,
+		},
+		{
+			name:      "Failed",
+			verifyErr: errCloneVerification("bad superblock"),
+			want:      cloneVerificationFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			kubeClient := mocks.NewMockKubeClient(ctrl)
+			kubeClient.EXPECT().PatchPersistentVolumeAnnotations(gomock.Any(), "pv-1", map[string]string{CloneVerificationAnnotation: tt.want}).Return(nil)
+			ns := &NodeServer{
+				driver: &LinodeDriver{opts: Options{AnnotateCloneVerification: true, KubeClient: kubeClient}},
+			}
+
+			ns.recordCloneVerification(context.Background(), map[string]string{VerifyCloneAttribute: True, PVNameParameter: "pv-1"}, tt.verifyErr)
+		})
+	}
+}
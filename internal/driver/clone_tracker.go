@@ -0,0 +1,148 @@
+package driver
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/linode/linodego"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+const (
+	// CloneSourceTagPrefix prefixes the tag of the volumes being cloned,
+	// followed by the ID of the volume they are cloned from. The tag is
+	// removed once the clone is active. It lets CreateVolume find the clones
+	// in progress when it is retried after the controller restarted.
+	CloneSourceTagPrefix = AttributeTagPrefix + string(attributeCloneOf) + ":"
+
+	// cloneWaitMargin is how long before the deadline of a CreateVolume
+	// request waiting for a clone, or a new volume, stops, to report that
+	// the volume is still being created before the request times out.
+	cloneWaitMargin = 5 * time.Second
+)
+
+// cloneOperation is the clone of a volume started by CreateVolume.
+type cloneOperation struct {
+	volumeID int
+	sourceID int
+	started  time.Time
+}
+
+// cloneTracker keeps track of the clones in progress by the label of the
+// volume being created, so that CreateVolume requests retried while a clone
+// takes longer than their timeout find it without listing volumes.
+//
+// The zero value is ready to use.
+type cloneTracker struct {
+	mu     sync.Mutex // protects clones
+	clones map[string]cloneOperation
+}
+
+// start records that the volume with label is being cloned.
+func (c *cloneTracker) start(label string, op cloneOperation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.clones == nil {
+		c.clones = make(map[string]cloneOperation)
+	}
+	c.clones[label] = op
+}
+
+// get returns the clone in progress of the volume with label.
+func (c *cloneTracker) get(label string) (cloneOperation, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	op, ok := c.clones[label]
+	return op, ok
+}
+
+// finish forgets about the clone of the volume with label.
+func (c *cloneTracker) finish(label string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.clones, label)
+}
+
+// cloneSourceAttribute returns the attribute of a volume being cloned from
+// sourceID.
+func cloneSourceAttribute(sourceID int) attribute {
+	return attribute{key: attributeCloneOf, value: strconv.Itoa(sourceID)}
+}
+
+// cloneSource returns the ID of the volume vol is being cloned from, from its
+// [CloneSourceTagPrefix] tag, or false if it is not being cloned.
+func cloneSource(vol *linodego.Volume) (int, bool) {
+	value, ok := getAttribute(vol.Tags, attributeCloneOf)
+	if !ok {
+		return 0, false
+	}
+	sourceID, err := strconv.Atoi(value)
+	return sourceID, err == nil
+}
+
+// trackedClone returns the volume with label if it is being cloned by an
+// earlier request, or false if there is no such clone.
+func (cs *ControllerServer) trackedClone(ctx context.Context, label string) (*linodego.Volume, bool, error) {
+	op, ok := cs.clones.get(label)
+	if !ok {
+		return nil, false, nil
+	}
+	vol, err := cs.linodeClient(ctx).GetVolume(ctx, op.volumeID)
+	if linodego.IsNotFound(err) {
+		cs.clones.finish(label)
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, errInternal("get volume %d: %v", op.volumeID, err)
+	}
+	logger.GetLogger(ctx).V(4).Info("Found clone in progress", "volume_id", vol.ID, "source_vol_id", op.sourceID, "elapsed", time.Since(op.started).Round(time.Second))
+	return vol, true, nil
+}
+
+// waitForClone waits for vol, cloned from the volume with sourceID, to be
+// active, and removes its [CloneSourceTagPrefix] tag. It stops waiting shortly
+// before the deadline of ctx, and returns [errCloneInProgress] if the clone is
+// not active by then, so that CreateVolume is retried until it is.
+func (cs *ControllerServer) waitForClone(ctx context.Context, label string, vol *linodego.Volume, sourceID int) (*linodego.Volume, error) {
+	log := logger.GetLogger(ctx)
+	log.V(4).Info("Entering waitForClone()", "volume_id", vol.ID, "source_vol_id", sourceID)
+	defer log.V(4).Info("Exiting waitForClone()")
+
+	if vol.Status == linodego.VolumeContactSupport {
+		cs.clones.finish(label)
+		return nil, errInternal("clone %d of volume %d failed with status %s", vol.ID, sourceID, vol.Status)
+	}
+	if vol.Status != linodego.VolumeActive {
+		waitCtx := ctx
+		if deadline, ok := ctx.Deadline(); ok {
+			var cancel context.CancelFunc
+			waitCtx, cancel = context.WithDeadline(ctx, deadline.Add(-cloneWaitMargin))
+			defer cancel()
+		}
+
+		log.V(4).Info("Waiting for clone to be active", "volume_id", vol.ID)
+		setRPCPhase(ctx, rpcPhaseWaitForClone)
+		active, err := cs.waitForVolumeActive(waitCtx, vol.ID, cloneTimeout())
+		if err != nil {
+			if waitCtx.Err() != nil {
+				log.V(2).Info("Clone is still in progress", "volume_id", vol.ID, "source_vol_id", sourceID, "status", vol.Status)
+				return nil, errCloneInProgress(vol.ID, sourceID)
+			}
+			cs.clones.finish(label)
+			return nil, errInternal("Timed out waiting for volume %d to be active: %v", vol.ID, err)
+		}
+		vol = active
+	}
+	cs.clones.finish(label)
+
+	// The tag only matters while the clone is in progress
+	if err := cs.updateVolumeAttributes(ctx, vol, nil, attributeCloneOf); err != nil {
+		log.Error(err, "Failed to remove the clone tag of the volume", "volume_id", vol.ID)
+	}
+	return vol, nil
+}
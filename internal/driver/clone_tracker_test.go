@@ -0,0 +1,116 @@
+package driver
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/linode/linodego"
+	"go.uber.org/mock/gomock"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+	linodevolumes "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-volumes"
+)
+
+func TestCreateAndWaitForClone(t *testing.T) {
+	source := &linodevolumes.LinodeVolumeKey{VolumeID: 2}
+
+	tests := []struct {
+		name           string
+		sourceInfo     *linodevolumes.LinodeVolumeKey
+		tracked        bool
+		setupMocks     func(*mocks.MockLinodeClient)
+		expectedVolume *linodego.Volume
+		expectedError  error
+		expectTracked  bool
+	}{
+		{
+			name:       "Clone in progress",
+			sourceInfo: source,
+			setupMocks: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().ListVolumes(gomock.Any(), gomock.Any()).Return(nil, nil)
+				m.EXPECT().CloneVolume(gomock.Any(), 2, "clone").Return(&linodego.Volume{ID: 3, Size: 10, Status: linodego.VolumeCreating}, nil)
+				m.EXPECT().UpdateVolume(gomock.Any(), 3, linodego.VolumeUpdateOptions{Tags: &[]string{"csi-clone-of:2"}}).Return(&linodego.Volume{}, nil)
+				m.EXPECT().WaitForVolumeStatus(gomock.Any(), 3, linodego.VolumeActive, gomock.Any()).DoAndReturn(
+					func(ctx context.Context, _ int, _ linodego.VolumeStatus, _ int) (*linodego.Volume, error) {
+						<-ctx.Done()
+						return nil, ctx.Err()
+					})
+			},
+			expectedError: errCloneInProgress(3, 2),
+			expectTracked: true,
+		},
+		{
+			name:       "Tracked clone still in progress",
+			sourceInfo: source,
+			tracked:    true,
+			setupMocks: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 3).Return(&linodego.Volume{ID: 3, Size: 10, Status: linodego.VolumeCreating, Tags: []string{"csi-clone-of:2"}}, nil)
+				m.EXPECT().WaitForVolumeStatus(gomock.Any(), 3, linodego.VolumeActive, gomock.Any()).DoAndReturn(
+					func(ctx context.Context, _ int, _ linodego.VolumeStatus, _ int) (*linodego.Volume, error) {
+						<-ctx.Done()
+						return nil, ctx.Err()
+					})
+			},
+			expectedError: errCloneInProgress(3, 2),
+			expectTracked: true,
+		},
+		{
+			name:       "Tracked clone active",
+			sourceInfo: source,
+			tracked:    true,
+			setupMocks: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 3).Return(&linodego.Volume{ID: 3, Size: 10, Status: linodego.VolumeActive, Tags: []string{"team", "csi-clone-of:2"}}, nil)
+				m.EXPECT().UpdateVolume(gomock.Any(), 3, linodego.VolumeUpdateOptions{Tags: &[]string{"team"}}).Return(&linodego.Volume{}, nil)
+			},
+			expectedVolume: &linodego.Volume{ID: 3, Size: 10, Status: linodego.VolumeActive, Tags: []string{"team"}},
+		},
+		{
+			name: "Clone found after a restart",
+			setupMocks: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().ListVolumes(gomock.Any(), gomock.Any()).Return([]linodego.Volume{{ID: 3, Size: 10, Status: linodego.VolumeActive, Tags: []string{"csi-clone-of:2"}}}, nil)
+				m.EXPECT().UpdateVolume(gomock.Any(), 3, linodego.VolumeUpdateOptions{Tags: &[]string{}}).Return(&linodego.Volume{}, nil)
+			},
+			expectedVolume: &linodego.Volume{ID: 3, Size: 10, Status: linodego.VolumeActive, Tags: []string{}},
+		},
+		{
+			name:       "Clone failed",
+			sourceInfo: source,
+			tracked:    true,
+			setupMocks: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 3).Return(&linodego.Volume{ID: 3, Size: 10, Status: linodego.VolumeContactSupport}, nil)
+			},
+			expectedError: errInternal("clone 3 of volume 2 failed with status contact_support"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockClient := mocks.NewMockLinodeClient(ctrl)
+			tt.setupMocks(mockClient)
+			cs := &ControllerServer{client: mockClient}
+			if tt.tracked {
+				cs.clones.start("clone", cloneOperation{volumeID: 3, sourceID: 2, started: time.Now()})
+			}
+
+			// Leave the clones a fraction of a second past the wait margin
+			ctx, cancel := context.WithTimeout(context.Background(), cloneWaitMargin+100*time.Millisecond)
+			defer cancel()
+
+			vol, err := cs.createAndWaitForVolume(ctx, "clone", nil, "", 20, tt.sourceInfo, "us-east")
+			if !reflect.DeepEqual(err, tt.expectedError) {
+				t.Errorf("createAndWaitForVolume() error = %v, want %v", err, tt.expectedError)
+			}
+			if !reflect.DeepEqual(vol, tt.expectedVolume) {
+				t.Errorf("createAndWaitForVolume() = %+v, want %+v", vol, tt.expectedVolume)
+			}
+			if _, tracked := cs.clones.get("clone"); tracked != tt.expectTracked {
+				t.Errorf("clone tracked = %v, want %v", tracked, tt.expectTracked)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package driver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/linode/linodego"
+
+	linodevolumes "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-volumes"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+const (
+	// ClusterTagPrefix prefixes the tag identifying the cluster that created
+	// a volume when [Options.ClusterName] is set, followed by the hash of
+	// the cluster name also appended to the label of the volume.
+	ClusterTagPrefix = AttributeTagPrefix + string(attributeCluster) + ":"
+
+	// shortHashLength is the number of hexadecimal characters of the short
+	// hashes of cluster and volume names.
+	shortHashLength = 6
+)
+
+// shortHash returns the short hash of s, which is appended to volume labels
+// to tell them apart.
+func shortHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])[:shortHashLength]
+}
+
+// clusterHash returns the short hash of a cluster name, which tells apart the
+// volumes of clusters sharing a Linode account.
+func clusterHash(clusterName string) string {
+	return shortHash(clusterName)
+}
+
+// clusterAttribute returns the attribute of the volumes created by the
+// driver, or false if [Options.ClusterName] is not set.
+func (d *LinodeDriver) clusterAttribute() (attribute, bool) {
+	if d == nil || d.opts.ClusterName == "" {
+		return attribute{}, false
+	}
+	return attribute{key: attributeCluster, value: clusterHash(d.opts.ClusterName)}, true
+}
+
+// volumeLabels returns the label of the volume created for the CSI volume
+// name, of the claim pvcNamespace/pvcName if known, and the label the
+// volume had before [Options.ClusterName] was set, or "" if it is not set.
+//
+// With a cluster name, the label ends with "-" and the hash of the cluster
+// name, so that clusters sharing a label prefix do not create volumes with
+// the same label. The rest of the label is truncated to make room for it.
+//
+// With a [Options.VolumeNameTemplate], the label is made from it instead,
+// unless it fails.
+func (d *LinodeDriver) volumeLabels(name, pvcNamespace, pvcName string) (label, legacyLabel string) {
+	return d.volumeLabelsWithPrefix(d.volumeLabelPrefix, name, pvcNamespace, pvcName)
+}
+
+// volumeLabelsWithPrefix returns the labels of volumeLabels, made with the
+// volume label prefix instead of the prefix of the driver.
+func (d *LinodeDriver) volumeLabelsWithPrefix(prefix, name, pvcNamespace, pvcName string) (label, legacyLabel string) {
+	if d.opts.VolumeNameTemplate != nil {
+		if label, err := d.templateVolumeLabel(prefix, name, pvcNamespace, pvcName); err == nil {
+			return label, ""
+		}
+	}
+
+	key := linodevolumes.CreateLinodeVolumeKey(0, name)
+	label = key.GetNormalizedLabelWithPrefix(prefix)
+	if d.opts.ClusterName == "" {
+		return label, ""
+	}
+
+	suffix := "-" + clusterHash(d.opts.ClusterName)
+	legacyLabel = label
+	label = prefix + key.GetNormalizedLabel()
+	if maxLength := linodevolumes.LinodeVolumeLabelLength - len(suffix); len(label) > maxLength {
+		label = label[:maxLength]
+	}
+	return label + suffix, legacyLabel
+}
+
+// adoptLegacyVolume returns legacyLabel if a volume with that label was
+// created for the request before [Options.ClusterName] was set, e.g. by a
+// request retried across the upgrade, and tags it as a volume of the cluster.
+// It returns label otherwise, including when the volume with legacyLabel
+// belongs to another cluster.
+func (cs *ControllerServer) adoptLegacyVolume(ctx context.Context, label, legacyLabel string) (string, error) {
+	if legacyLabel == "" {
+		return label, nil
+	}
+	log := logger.GetLogger(ctx)
+	log.V(4).Info("Entering adoptLegacyVolume()", "label", label, "legacyLabel", legacyLabel)
+	defer log.V(4).Info("Exiting adoptLegacyVolume()")
+
+	volumes, err := cs.listVolumesByLabel(ctx, label)
+	if err != nil || len(volumes) > 0 {
+		return label, err
+	}
+	volumes, err = cs.listVolumesByLabel(ctx, legacyLabel)
+	if err != nil || len(volumes) != 1 {
+		return label, err
+	}
+
+	vol := volumes[0]
+	cluster, _ := cs.driver.clusterAttribute()
+	if hash, ok := getAttribute(vol.Tags, attributeCluster); ok && hash != cluster.value {
+		log.V(4).Info("Volume with the legacy label belongs to another cluster", "volume_id", vol.ID, "tags", vol.Tags)
+		return label, nil
+	}
+	if err := cs.updateVolumeAttributes(ctx, &vol, []attribute{cluster}); err != nil {
+		return label, err
+	}
+	log.V(2).Info("Adopted volume created before the cluster name was set", "volume_id", vol.ID, "label", legacyLabel)
+	return legacyLabel, nil
+}
+
+// listVolumesByLabel returns the volumes with label.
+func (cs *ControllerServer) listVolumesByLabel(ctx context.Context, label string) ([]linodego.Volume, error) {
+	jsonFilter, err := json.Marshal(map[string]string{"label": label})
+	if err != nil {
+		return nil, errInternal("marshal json filter: %v", err)
+	}
+	volumes, err := cs.linodeClient(ctx).ListVolumes(ctx, linodego.NewListOptions(0, string(jsonFilter)))
+	if err != nil {
+		return nil, errInternal("list volumes: %v", err)
+	}
+	return volumes, nil
+}
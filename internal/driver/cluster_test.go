@@ -0,0 +1,207 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/linode/linodego"
+	"go.uber.org/mock/gomock"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+	linodevolumes "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-volumes"
+)
+
+func TestVolumeLabels(t *testing.T) {
+	tests := []struct {
+		name        string
+		prefix      string
+		clusterName string
+		volumeName  string
+		wantLabel   string
+		wantLegacy  string
+	}{
+		{
+			name:       "No cluster name",
+			prefix:     "prod-",
+			volumeName: "pvc-0a1b2c3d",
+			wantLabel:  "prod-pvc-0a1b2c3d",
+		},
+		{
+			name:        "Cluster name",
+			prefix:      "prod-",
+			clusterName: "cluster-a",
+			volumeName:  "pvc-0a1b2c3d",
+			wantLabel:   "prod-pvc-0a1b2c3d-34ab3e",
+			wantLegacy:  "prod-pvc-0a1b2c3d",
+		},
+		{
+			name:        "Other cluster name",
+			prefix:      "prod-",
+			clusterName: "cluster-b",
+			volumeName:  "pvc-0a1b2c3d",
+			wantLabel:   "prod-pvc-0a1b2c3d-ebe9fb",
+			wantLegacy:  "prod-pvc-0a1b2c3d",
+		},
+		{
+			name:        "Truncated label",
+			prefix:      "prod-",
+			clusterName: "cluster-a",
+			volumeName:  "pvc-0a1b2c3d-4e5f-6789-abcd-ef0123456789",
+			wantLabel:   "prod-pvc-0a1b2c3d-4e5f-67-34ab3e",
+			wantLegacy:  "prod-pvc-0a1b2c3d-4e5f-6789-abcd",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &LinodeDriver{volumeLabelPrefix: tt.prefix, opts: Options{ClusterName: tt.clusterName}}
+			label, legacy := d.volumeLabels(tt.volumeName, "", "")
+			if label != tt.wantLabel || legacy != tt.wantLegacy {
+				t.Errorf("volumeLabels() = %q, %q, want %q, %q", label, legacy, tt.wantLabel, tt.wantLegacy)
+			}
+			if len(label) > linodevolumes.LinodeVolumeLabelLength {
+				t.Errorf("label %q is longer than %d characters", label, linodevolumes.LinodeVolumeLabelLength)
+			}
+		})
+	}
+}
+
+func TestAdoptLegacyVolume(t *testing.T) {
+	const (
+		label       = "pvc-0a1b2c3d-34ab3e"
+		legacyLabel = "pvc-0a1b2c3d"
+	)
+	labelFilter := linodego.NewListOptions(0, `{"label":"`+label+`"}`)
+	legacyFilter := linodego.NewListOptions(0, `{"label":"`+legacyLabel+`"}`)
+
+	tests := []struct {
+		name          string
+		legacyLabel   string
+		setupMocks    func(*mocks.MockLinodeClient)
+		expectedLabel string
+		expectedError error
+	}{
+		{
+			name:          "No cluster name",
+			expectedLabel: label,
+		},
+		{
+			name:        "Volume with the label",
+			legacyLabel: legacyLabel,
+			setupMocks: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().ListVolumes(gomock.Any(), labelFilter).Return([]linodego.Volume{{ID: 1, Label: label}}, nil)
+			},
+			expectedLabel: label,
+		},
+		{
+			name:        "No volume",
+			legacyLabel: legacyLabel,
+			setupMocks: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().ListVolumes(gomock.Any(), labelFilter).Return(nil, nil)
+				m.EXPECT().ListVolumes(gomock.Any(), legacyFilter).Return(nil, nil)
+			},
+			expectedLabel: label,
+		},
+		{
+			name:        "Untagged legacy volume",
+			legacyLabel: legacyLabel,
+			setupMocks: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().ListVolumes(gomock.Any(), labelFilter).Return(nil, nil)
+				m.EXPECT().ListVolumes(gomock.Any(), legacyFilter).Return([]linodego.Volume{{ID: 1, Label: legacyLabel, Tags: []string{"team"}}}, nil)
+				m.EXPECT().UpdateVolume(gomock.Any(), 1, linodego.VolumeUpdateOptions{Tags: &[]string{"team", "csi-cluster:34ab3e"}}).Return(&linodego.Volume{}, nil)
+			},
+			expectedLabel: legacyLabel,
+		},
+		{
+			name:        "Legacy volume of the cluster",
+			legacyLabel: legacyLabel,
+			setupMocks: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().ListVolumes(gomock.Any(), labelFilter).Return(nil, nil)
+				m.EXPECT().ListVolumes(gomock.Any(), legacyFilter).Return([]linodego.Volume{{ID: 1, Label: legacyLabel, Tags: []string{"csi-cluster:34ab3e"}}}, nil)
+			},
+			expectedLabel: legacyLabel,
+		},
+		{
+			name:        "Legacy volume of another cluster",
+			legacyLabel: legacyLabel,
+			setupMocks: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().ListVolumes(gomock.Any(), labelFilter).Return(nil, nil)
+				m.EXPECT().ListVolumes(gomock.Any(), legacyFilter).Return([]linodego.Volume{{ID: 1, Label: legacyLabel, Tags: []string{"csi-cluster:ebe9fb"}}}, nil)
+			},
+			expectedLabel: label,
+		},
+		{
+			name:        "Tag error",
+			legacyLabel: legacyLabel,
+			setupMocks: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().ListVolumes(gomock.Any(), labelFilter).Return(nil, nil)
+				m.EXPECT().ListVolumes(gomock.Any(), legacyFilter).Return([]linodego.Volume{{ID: 1, Label: legacyLabel}}, nil)
+				m.EXPECT().UpdateVolume(gomock.Any(), 1, gomock.Any()).Return(nil, errors.New("API error"))
+			},
+			expectedLabel: label,
+			expectedError: errInternal("tag volume 1: API error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockClient := mocks.NewMockLinodeClient(ctrl)
+			if tt.setupMocks != nil {
+				tt.setupMocks(mockClient)
+			}
+			cs := &ControllerServer{
+				client: mockClient,
+				driver: &LinodeDriver{opts: Options{ClusterName: "cluster-a"}},
+			}
+
+			got, err := cs.adoptLegacyVolume(context.Background(), label, tt.legacyLabel)
+			if !reflect.DeepEqual(err, tt.expectedError) {
+				t.Errorf("adoptLegacyVolume() error = %v, want %v", err, tt.expectedError)
+			}
+			if got != tt.expectedLabel {
+				t.Errorf("adoptLegacyVolume() = %q, want %q", got, tt.expectedLabel)
+			}
+		})
+	}
+}
+
+func TestCreateVolumeClusterTag(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mocks.NewMockLinodeClient(ctrl)
+	cs := &ControllerServer{
+		client: mockClient,
+		driver: &LinodeDriver{opts: Options{ClusterName: "cluster-a"}},
+	}
+
+	t.Run("New volume", func(t *testing.T) {
+		mockClient.EXPECT().ListVolumes(gomock.Any(), gomock.Any()).Return(nil, nil)
+		mockClient.EXPECT().CreateVolume(gomock.Any(), linodego.VolumeCreateOptions{
+			Region: "us-east",
+			Label:  "pvc-0a1b2c3d-34ab3e",
+			Size:   10,
+			Tags:   []string{"team", "csi-cluster:34ab3e", ProvisioningTag},
+		}).Return(&linodego.Volume{ID: 1}, nil)
+
+		if _, err := cs.attemptCreateLinodeVolume(context.Background(), "pvc-0a1b2c3d-34ab3e", map[string]string{VolumeTags: "team"}, "", 10, nil, "us-east"); err != nil {
+			t.Errorf("attemptCreateLinodeVolume() error = %v", err)
+		}
+	})
+
+	t.Run("Clone", func(t *testing.T) {
+		mockClient.EXPECT().ListVolumes(gomock.Any(), gomock.Any()).Return(nil, nil)
+		mockClient.EXPECT().CloneVolume(gomock.Any(), 2, "pvc-0a1b2c3d-34ab3e").Return(&linodego.Volume{ID: 3, Tags: []string{"team"}}, nil)
+		mockClient.EXPECT().UpdateVolume(gomock.Any(), 3, linodego.VolumeUpdateOptions{Tags: &[]string{"team", "csi-clone-of:2", "csi-cluster:34ab3e"}}).Return(&linodego.Volume{}, nil)
+
+		source := &linodevolumes.LinodeVolumeKey{VolumeID: 2}
+		if _, err := cs.attemptCreateLinodeVolume(context.Background(), "pvc-0a1b2c3d-34ab3e", nil, "", 10, source, "us-east"); err != nil {
+			t.Errorf("attemptCreateLinodeVolume() error = %v", err)
+		}
+	})
+}
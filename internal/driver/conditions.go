@@ -0,0 +1,171 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	linodeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-client"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+// The conditions of the readiness of the plugin, reported in the manifest of
+// GetPluginInfo as "condition.<name>", "true" or "false", with the reason of
+// the unmet conditions in "condition.<name>.message".
+const (
+	// ConditionAPIReachable is met when the last request checking that the
+	// Linode API is reachable succeeded.
+	ConditionAPIReachable = "api-reachable"
+
+	// ConditionMetadata is met when the ID and region of the instance the
+	// plugin runs on are known.
+	ConditionMetadata = "metadata"
+
+	// ConditionMountsHealthy is met when the mount watchdog could mount
+	// again the volumes whose mounts vanished during its last check.
+	ConditionMountsHealthy = "mounts-healthy"
+)
+
+// conditionManifestPrefix starts the keys of the conditions in the manifest
+// of GetPluginInfo.
+const conditionManifestPrefix = "condition."
+
+// fatalConditions are the conditions that make Probe report the plugin as
+// not ready when they are unmet, so that it is restarted by the liveness
+// probe. The other conditions are transient, or would not be fixed by a
+// restart, and are only reported.
+var fatalConditions = []string{ConditionMetadata}
+
+// apiCheckInterval and apiCheckTimeout are how often, and for how long at
+// most, the plugin checks that the Linode API is reachable.
+const (
+	apiCheckInterval = time.Minute
+	apiCheckTimeout  = 10 * time.Second
+)
+
+// readinessConditions are the conditions of the readiness of the plugin. The
+// zero value is ready to use, and has all the conditions met.
+type readinessConditions struct {
+	mu    sync.Mutex
+	unmet map[string]string // reasons of the unmet conditions, by name
+}
+
+// set marks the condition name as met if err is nil, and unmet for err
+// otherwise. It returns whether the condition changed.
+func (c *readinessConditions) set(name string, err error) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	previous, wasUnmet := c.unmet[name]
+	if err == nil {
+		delete(c.unmet, name)
+		return wasUnmet
+	}
+	if c.unmet == nil {
+		c.unmet = make(map[string]string)
+	}
+	c.unmet[name] = err.Error()
+	return !wasUnmet || previous != err.Error()
+}
+
+// manifest returns the conditions as entries of the manifest of
+// GetPluginInfo.
+func (c *readinessConditions) manifest() map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	manifest := make(map[string]string)
+	for _, name := range []string{ConditionAPIReachable, ConditionMetadata, ConditionMountsHealthy} {
+		key := conditionManifestPrefix + name
+		reason, unmet := c.unmet[name]
+		manifest[key] = fmt.Sprint(!unmet)
+		if unmet {
+			manifest[key+".message"] = reason
+		}
+	}
+	return manifest
+}
+
+// unmetFatal returns the names of the unmet [fatalConditions], sorted.
+func (c *readinessConditions) unmetFatal() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var names []string
+	for _, name := range slices.Sorted(maps.Keys(c.unmet)) {
+		if slices.Contains(fatalConditions, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// setCondition marks the condition name as met if err is nil, and unmet for
+// err otherwise, exporting it and logging its changes.
+func (linodeDriver *LinodeDriver) setCondition(ctx context.Context, name string, err error) {
+	if linodeDriver == nil {
+		return
+	}
+	met := 1.0
+	if err != nil {
+		met = 0
+	}
+	observability.PluginConditionMet.WithLabelValues(name).Set(met)
+	if !linodeDriver.conditions.set(name, err) {
+		return
+	}
+
+	log := logger.GetLogger(ctx)
+	if err != nil {
+		log.Error(err, "Readiness condition is not met", "condition", name)
+		return
+	}
+	log.V(2).Info("Readiness condition is met again", "condition", name)
+}
+
+// checkMetadata sets the [ConditionMetadata] from metadata.
+func (linodeDriver *LinodeDriver) checkMetadata(ctx context.Context, metadata Metadata) {
+	var err error
+	switch {
+	case metadata.ID == 0:
+		err = errors.New("the ID of the instance is unknown")
+	case metadata.Region == "":
+		err = fmt.Errorf("the region of instance %d is unknown", metadata.ID)
+	}
+	linodeDriver.setCondition(ctx, ConditionMetadata, err)
+}
+
+// watchAPI sets the [ConditionAPIReachable] from a request for region made
+// with client every interval, until ctx is canceled.
+func (linodeDriver *LinodeDriver) watchAPI(ctx context.Context, client linodeclient.LinodeClient, region string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		linodeDriver.checkAPI(ctx, client, region)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkAPI sets the [ConditionAPIReachable] from a request for region made
+// with client.
+func (linodeDriver *LinodeDriver) checkAPI(ctx context.Context, client linodeclient.LinodeClient, region string) {
+	checkCtx, cancel := context.WithTimeout(ctx, apiCheckTimeout)
+	defer cancel()
+
+	_, err := client.GetRegion(checkCtx, region)
+	if err != nil {
+		err = fmt.Errorf("get region %q: %w", region, err)
+	}
+	linodeDriver.setCondition(ctx, ConditionAPIReachable, err)
+}
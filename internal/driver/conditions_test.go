@@ -0,0 +1,85 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/linode/linodego"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/mock/gomock"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+func TestLinodeDriver_checkAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	client := mocks.NewMockLinodeClient(ctrl)
+	driver := &LinodeDriver{}
+
+	client.EXPECT().GetRegion(gomock.Any(), "us-east").Return(nil, errors.New("connection refused"))
+	driver.checkAPI(context.Background(), client, "us-east")
+
+	manifest := driver.conditions.manifest()
+	if got := manifest["condition.api-reachable"]; got != "false" {
+		t.Errorf("condition.api-reachable = %q, want %q", got, "false")
+	}
+	if got, want := manifest["condition.api-reachable.message"], `get region "us-east": connection refused`; got != want {
+		t.Errorf("condition.api-reachable.message = %q, want %q", got, want)
+	}
+	if got := testutil.ToFloat64(observability.PluginConditionMet.WithLabelValues(ConditionAPIReachable)); got != 0 {
+		t.Errorf("csi_plugin_condition_met = %v, want 0", got)
+	}
+	if unmet := driver.conditions.unmetFatal(); len(unmet) != 0 {
+		t.Errorf("unmetFatal() = %v, want none", unmet)
+	}
+
+	client.EXPECT().GetRegion(gomock.Any(), "us-east").Return(&linodego.Region{ID: "us-east"}, nil)
+	driver.checkAPI(context.Background(), client, "us-east")
+
+	manifest = driver.conditions.manifest()
+	if got := manifest["condition.api-reachable"]; got != "true" {
+		t.Errorf("condition.api-reachable = %q, want %q", got, "true")
+	}
+	if _, ok := manifest["condition.api-reachable.message"]; ok {
+		t.Errorf("condition.api-reachable.message is set for a met condition")
+	}
+	if got := testutil.ToFloat64(observability.PluginConditionMet.WithLabelValues(ConditionAPIReachable)); got != 1 {
+		t.Errorf("csi_plugin_condition_met = %v, want 1", got)
+	}
+}
+
+func TestLinodeDriver_checkMetadata(t *testing.T) {
+	tests := []struct {
+		name      string
+		metadata  Metadata
+		wantUnmet bool
+	}{
+		{
+			name:     "Instance is known",
+			metadata: Metadata{ID: 123, Region: "us-east"},
+		},
+		{
+			name:      "Unknown ID",
+			metadata:  Metadata{Region: "us-east"},
+			wantUnmet: true,
+		},
+		{
+			name:      "Unknown region",
+			metadata:  Metadata{ID: 123},
+			wantUnmet: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			driver := &LinodeDriver{}
+			driver.checkMetadata(context.Background(), tt.metadata)
+
+			if got := len(driver.conditions.unmetFatal()) > 0; got != tt.wantUnmet {
+				t.Errorf("metadata condition unmet = %v, want %v", got, tt.wantUnmet)
+			}
+		})
+	}
+}
@@ -5,12 +5,14 @@ import (
 	"errors"
 	"math"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/linode/linodego"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 
 	linodeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-client"
 	linodevolumes "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-volumes"
@@ -23,6 +25,50 @@ type ControllerServer struct {
 	client   linodeclient.LinodeClient
 	metadata Metadata
 
+	// detaches tracks the volumes being detached in the background.
+	detaches detachTracker
+
+	// clones tracks the volumes being cloned by CreateVolume.
+	clones cloneTracker
+
+	// maintenance pauses the requests changing resources while the Linode
+	// API is in maintenance mode.
+	maintenance maintenanceBreaker
+
+	// labelSync renames the volumes of annotated PersistentVolumes after
+	// them. It is nil unless enabled.
+	labelSync *volumeLabelSyncer
+
+	// tokenCheck exports the validity and expiry of the token of the
+	// controller. It is nil unless enabled.
+	tokenCheck *tokenCheck
+
+	// deletedNodes detaches the volumes of deleted nodes. It is nil unless
+	// enabled.
+	deletedNodes *deletedNodeDetacher
+
+	// cordonedNodes detaches the read-only volumes of cordoned nodes. It is
+	// nil unless enabled.
+	cordonedNodes *cordonedNodeDetacher
+
+	// shards shares the volumes with the other replicas of the
+	// controller. It is nil unless enabled.
+	shards *volumeShards
+
+	// tokenClients holds the Linode clients for the tokens of request
+	// secrets.
+	tokenClients tokenClientCache
+
+	// backoff refuses the requests for the volumes that keep failing.
+	backoff volumeBackoff
+
+	// poller runs the waits for volumes shared by retried requests.
+	poller volumePoller
+
+	// events feeds the volume events of the account to the waits for
+	// volumes and the volume conditions. It is nil unless enabled.
+	events *accountEvents
+
 	csi.UnimplementedControllerServer
 }
 
@@ -48,9 +94,10 @@ func NewControllerServer(ctx context.Context, driver *LinodeDriver, client linod
 
 	cs := &ControllerServer{
 		driver:   driver,
-		client:   client,
 		metadata: metadata,
 	}
+	cs.client = &maintenanceClient{LinodeClient: client, breaker: &cs.maintenance}
+	cs.backoff.base = driver.opts.VolumeFailureBackoff
 
 	log.V(4).Info("ControllerServer created successfully")
 	return cs, nil
@@ -59,12 +106,19 @@ func NewControllerServer(ctx context.Context, driver *LinodeDriver, client linod
 // CreateVolume provisions a new volume on behalf of a user, which can be used as a block device or mounted filesystem.
 // This operation is idempotent, meaning multiple calls with the same parameters will not create duplicate volumes.
 // For more details, refer to the CSI Driver Spec documentation.
-func (cs *ControllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest) (*csi.CreateVolumeResponse, error) {
+func (cs *ControllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest) (resp *csi.CreateVolumeResponse, err error) {
 	log, _, done := logger.GetLogger(ctx).WithMethod("CreateVolume")
 	defer done()
+	defer func() { err = cs.maintenance.unavailable(err) }()
 
 	functionStartTime := time.Now()
-	log.V(2).Info("Processing request", "req", req)
+	log.V(2).Info("Processing request", "req", redactSecrets(req))
+
+	ctx, err = cs.withSecrets(ctx, req.GetSecrets())
+	if err != nil {
+		observability.RecordMetrics(observability.ControllerCreateVolumeTotal, observability.ControllerCreateVolumeDuration, observability.Failed, functionStartTime)
+		return &csi.CreateVolumeResponse{}, err
+	}
 
 	// Validate the incoming request to ensure it meets the necessary criteria.
 	// This includes checking for required fields and valid volume capabilities.
@@ -73,6 +127,14 @@ func (cs *ControllerServer) CreateVolume(ctx context.Context, req *csi.CreateVol
 		return &csi.CreateVolumeResponse{}, err
 	}
 
+	// Back off from a volume whose creation keeps failing
+	backoffKey := backoffKey{operation: "CreateVolume", volume: req.GetName()}
+	if err := cs.backoff.check(backoffKey); err != nil {
+		observability.RecordMetrics(observability.ControllerCreateVolumeTotal, observability.ControllerCreateVolumeDuration, observability.Failed, functionStartTime)
+		return &csi.CreateVolumeResponse{}, err
+	}
+	defer func() { cs.backoff.record(ctx, backoffKey, err) }()
+
 	// Prepare the volume parameters such as name and SizeGB from the request.
 	// This step may involve calculations or adjustments based on the request's content.
 	params, err := cs.prepareVolumeParams(ctx, req)
@@ -91,22 +153,54 @@ func (cs *ControllerServer) CreateVolume(ctx context.Context, req *csi.CreateVol
 		observability.RecordMetrics(observability.ControllerCreateVolumeTotal, observability.ControllerCreateVolumeDuration, observability.Failed, functionStartTime)
 		return &csi.CreateVolumeResponse{}, err
 	}
+	if sourceVolInfo != nil {
+		if err := cs.checkCloneNamespace(ctx, req.GetParameters(), sourceVolInfo); err != nil {
+			observability.RecordMetrics(observability.ControllerCreateVolumeTotal, observability.ControllerCreateVolumeDuration, observability.Failed, functionStartTime)
+			return &csi.CreateVolumeResponse{}, err
+		}
+	}
+
+	// Keep the label of a volume created for the request before the cluster
+	// name was set
+	volumeName, err := cs.adoptLegacyVolume(ctx, params.VolumeName, params.LegacyVolumeName)
+	if err != nil {
+		observability.RecordMetrics(observability.ControllerCreateVolumeTotal, observability.ControllerCreateVolumeDuration, observability.Failed, functionStartTime)
+		return &csi.CreateVolumeResponse{}, err
+	}
+
+	// Keep the label of a volume created for the request before the volume
+	// label prefix changed
+	volumeName, err = cs.previousPrefixVolume(ctx, volumeName, params.PreviousPrefixVolumeNames)
+	if err != nil {
+		observability.RecordMetrics(observability.ControllerCreateVolumeTotal, observability.ControllerCreateVolumeDuration, observability.Failed, functionStartTime)
+		return &csi.CreateVolumeResponse{}, err
+	}
 
 	// Create the volume
-	vol, err := cs.createAndWaitForVolume(ctx, params.VolumeName, req.GetParameters(), params.EncryptionStatus, params.TargetSizeGB, sourceVolInfo, params.Region)
+	vol, err := cs.createAndWaitForVolume(ctx, volumeName, createParameters(req), params.EncryptionStatus, params.TargetSizeGB, sourceVolInfo, params.Region)
 	if err != nil {
 		observability.RecordMetrics(observability.ControllerCreateVolumeTotal, observability.ControllerCreateVolumeDuration, observability.Failed, functionStartTime)
 		return &csi.CreateVolumeResponse{}, err
 	}
 
+	// Verify the clone, if requested
+	if sourceVolInfo != nil && req.GetParameters()[VerifyCloneAttribute] == True {
+		if err := cs.verifyClone(ctx, vol, sourceVolInfo.VolumeID); err != nil {
+			observability.RecordMetrics(observability.ControllerCreateVolumeTotal, observability.ControllerCreateVolumeDuration, observability.Failed, functionStartTime)
+			return &csi.CreateVolumeResponse{}, err
+		}
+	}
+
 	// Create volume context
 	volContext := cs.createVolumeContext(ctx, req, vol)
 
 	// Prepare and return response
-	resp := cs.prepareCreateVolumeResponse(ctx, vol, params.Size, volContext, sourceVolInfo, contentSource)
+	resp = cs.prepareCreateVolumeResponse(ctx, vol, volContext, sourceVolInfo, contentSource)
+	recordSizeRoundUp(ctx, "CreateVolume", req.GetCapacityRange().GetRequiredBytes(), resp.GetVolume().GetCapacityBytes())
 
 	// Record function completion
 	observability.RecordMetrics(observability.ControllerCreateVolumeTotal, observability.ControllerCreateVolumeDuration, observability.Completed, functionStartTime)
+	cs.recordFeatureUsage(ctx, req, params.EncryptionStatus)
 
 	log.V(2).Info("CreateVolume response", "response", resp)
 	return resp, nil
@@ -117,22 +211,29 @@ func (cs *ControllerServer) CreateVolume(ctx context.Context, req *csi.CreateVol
 // the same effect as calling it once. If the volume does not exist, the
 // function will return a success response without any error.
 // For more details, refer to the CSI Driver Spec documentation.
-func (cs *ControllerServer) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequest) (*csi.DeleteVolumeResponse, error) {
+func (cs *ControllerServer) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequest) (resp *csi.DeleteVolumeResponse, err error) {
 	log, _, done := logger.GetLogger(ctx).WithMethod("DeleteVolume")
 	defer done()
+	defer func() { err = cs.maintenance.unavailable(err) }()
 
 	functionStartTime := time.Now()
-	volID, statusErr := linodevolumes.VolumeIdAsInt("DeleteVolume", req)
+	volID, statusErr := cs.volumeIDFromRequest(ctx, "DeleteVolume", req)
 	if statusErr != nil {
 		observability.RecordMetrics(observability.ControllerDeleteVolumeTotal, observability.ControllerDeleteVolumeDuration, observability.Failed, functionStartTime)
 		return &csi.DeleteVolumeResponse{}, statusErr
 	}
 
-	log.V(2).Info("Processing request", "req", req)
+	log.V(2).Info("Processing request", "req", redactSecrets(req))
+
+	ctx, err = cs.withSecrets(ctx, req.GetSecrets())
+	if err != nil {
+		observability.RecordMetrics(observability.ControllerDeleteVolumeTotal, observability.ControllerDeleteVolumeDuration, observability.Failed, functionStartTime)
+		return &csi.DeleteVolumeResponse{}, err
+	}
 
 	// Check if the volume exists
 	log.V(4).Info("Checking if volume exists", "volume_id", volID)
-	vol, err := cs.client.GetVolume(ctx, volID)
+	vol, err := cs.linodeClient(ctx).GetVolume(ctx, volID)
 	if linodego.IsNotFound(err) {
 		observability.RecordMetrics(observability.ControllerDeleteVolumeTotal, observability.ControllerDeleteVolumeDuration, observability.Failed, functionStartTime)
 		return &csi.DeleteVolumeResponse{}, nil
@@ -141,13 +242,15 @@ func (cs *ControllerServer) DeleteVolume(ctx context.Context, req *csi.DeleteVol
 		return &csi.DeleteVolumeResponse{}, errInternal("get volume %d: %v", volID, err)
 	}
 	if vol.LinodeID != nil {
-		observability.RecordMetrics(observability.ControllerDeleteVolumeTotal, observability.ControllerDeleteVolumeDuration, observability.Failed, functionStartTime)
-		return &csi.DeleteVolumeResponse{}, errVolumeInUse
+		if err := cs.waitForDeleteDetach(ctx, vol); err != nil {
+			observability.RecordMetrics(observability.ControllerDeleteVolumeTotal, observability.ControllerDeleteVolumeDuration, observability.Failed, functionStartTime)
+			return &csi.DeleteVolumeResponse{}, err
+		}
 	}
 
 	// Delete the volume
 	log.V(4).Info("Deleting volume", "volume_id", volID)
-	if err := cs.client.DeleteVolume(ctx, volID); err != nil {
+	if err := cs.linodeClient(ctx).DeleteVolume(ctx, volID); err != nil {
 		observability.RecordMetrics(observability.ControllerDeleteVolumeTotal, observability.ControllerDeleteVolumeDuration, observability.Failed, functionStartTime)
 		return &csi.DeleteVolumeResponse{}, errInternal("delete volume %d: %v", volID, err)
 	}
@@ -167,9 +270,10 @@ func (cs *ControllerServer) DeleteVolume(ctx context.Context, req *csi.DeleteVol
 func (cs *ControllerServer) ControllerPublishVolume(ctx context.Context, req *csi.ControllerPublishVolumeRequest) (resp *csi.ControllerPublishVolumeResponse, err error) {
 	log, _, done := logger.GetLogger(ctx).WithMethod("ControllerPublishVolume")
 	defer done()
+	defer func() { err = cs.maintenance.unavailable(err) }()
 
 	functionStartTime := time.Now()
-	log.V(2).Info("Processing request", "req", req)
+	log.V(2).Info("Processing request", "req", redactSecrets(req))
 
 	// Validate the request and get Linode ID and Volume ID
 	linodeID, volumeID, err := cs.validateControllerPublishVolumeRequest(ctx, req)
@@ -178,6 +282,32 @@ func (cs *ControllerServer) ControllerPublishVolume(ctx context.Context, req *cs
 		return resp, err
 	}
 
+	// The volume is attached for real from now on
+	if cs.cordonedNodes != nil {
+		cs.cordonedNodes.forget(volumeID, linodeID)
+	}
+
+	// Back off from an attachment that keeps failing
+	backoffKey := backoffKey{operation: "ControllerPublishVolume", volume: req.GetVolumeId(), node: req.GetNodeId()}
+	if err := cs.backoff.check(backoffKey); err != nil {
+		observability.RecordMetrics(observability.ControllerPublishVolumeTotal, observability.ControllerPublishVolumeDuration, observability.Failed, functionStartTime)
+		return resp, err
+	}
+	defer func() { cs.backoff.record(ctx, backoffKey, err) }()
+
+	// Refuse to attach a volume that is still being detached in the
+	// background; the CO will retry.
+	if _, ok := cs.detaches.inProgress(volumeID); ok {
+		observability.RecordMetrics(observability.ControllerPublishVolumeTotal, observability.ControllerPublishVolumeDuration, observability.Failed, functionStartTime)
+		return resp, errDetachInProgress(volumeID)
+	}
+	// Report a failed background detach once; the next attempt attaches
+	// the volume if it was detached since.
+	if err := cs.detaches.takeFailure(volumeID); err != nil {
+		observability.RecordMetrics(observability.ControllerPublishVolumeTotal, observability.ControllerPublishVolumeDuration, observability.Failed, functionStartTime)
+		return resp, err
+	}
+
 	// Retrieve and validate the instance associated with the Linode ID
 	instance, err := cs.getInstance(ctx, linodeID)
 	if err != nil {
@@ -194,6 +324,7 @@ func (cs *ControllerServer) ControllerPublishVolume(ctx context.Context, req *cs
 	}
 	// If devicePath is not empty, the volume is already attached
 	if devicePath != "" {
+		cs.checkPersistedAttachment(ctx, volumeID, instance)
 		observability.RecordMetrics(observability.ControllerPublishVolumeTotal, observability.ControllerPublishVolumeDuration, observability.Failed, functionStartTime)
 		return &csi.ControllerPublishVolumeResponse{
 			PublishContext: map[string]string{
@@ -208,16 +339,33 @@ func (cs *ControllerServer) ControllerPublishVolume(ctx context.Context, req *cs
 		return resp, capErr
 	}
 
+	// Wait for the migrations of the instance to complete
+	if err := cs.checkInstanceMigration(ctx, instance); err != nil {
+		observability.RecordMetrics(observability.ControllerPublishVolumeTotal, observability.ControllerPublishVolumeDuration, observability.Failed, functionStartTime)
+		return resp, err
+	}
+
+	// Select the configuration profile to attach the volume to, if any
+	configID, err := cs.attachConfigID(ctx, instance)
+	if err != nil {
+		observability.RecordMetrics(observability.ControllerPublishVolumeTotal, observability.ControllerPublishVolumeDuration, observability.Failed, functionStartTime)
+		return resp, err
+	}
+
 	// Attach the volume to the specified instance
-	if attachErr := cs.attachVolume(ctx, volumeID, linodeID); attachErr != nil {
+	if attachErr := cs.attachVolume(ctx, volumeID, linodeID, configID); attachErr != nil {
 		observability.RecordMetrics(observability.ControllerPublishVolumeTotal, observability.ControllerPublishVolumeDuration, observability.Failed, functionStartTime)
 		return resp, attachErr
 	}
 
 	log.V(4).Info("Waiting for volume to attach", "volume_id", volumeID)
+	setRPCPhase(ctx, rpcPhaseWaitForAttach)
 	// Wait for the volume to be successfully attached to the instance
-	volume, err := cs.client.WaitForVolumeLinodeID(ctx, volumeID, &linodeID, waitTimeout())
+	volume, err := cs.waitForVolumeLinodeID(ctx, volumeID, &linodeID, waitTimeout())
 	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			cs.logAttachTimeout(ctx, err, volumeID, linodeID)
+		}
 		observability.RecordMetrics(observability.ControllerPublishVolumeTotal, observability.ControllerPublishVolumeDuration, observability.Failed, functionStartTime)
 		return resp, err
 	}
@@ -225,14 +373,18 @@ func (cs *ControllerServer) ControllerPublishVolume(ctx context.Context, req *cs
 	// Record function completion
 	observability.RecordMetrics(observability.ControllerPublishVolumeTotal, observability.ControllerPublishVolumeDuration, observability.Completed, functionStartTime)
 
-	log.V(2).Info("Volume attached successfully", "volume_id", volume.ID, "node_id", *volume.LinodeID, "device_path", volume.FilesystemPath)
+	log.V(2).Info("Volume attached successfully", "volume_id", volume.ID, "node_id", *volume.LinodeID, "config_id", configID, "device_path", volume.FilesystemPath)
 
 	// Return the response with the device path of the attached volume
 	resp = &csi.ControllerPublishVolumeResponse{
 		PublishContext: map[string]string{
 			devicePathKey: volume.FilesystemPath,
+			attachedAtKey: formatTimestamp(time.Now()),
 		},
 	}
+	if configID != 0 {
+		resp.PublishContext[configIDKey] = strconv.Itoa(configID)
+	}
 	return resp, nil
 }
 
@@ -243,14 +395,15 @@ func (cs *ControllerServer) ControllerPublishVolume(ctx context.Context, req *cs
 // If the volume is not found or is already detached, it will
 // return a successful response without error.
 // For more details, refer to the CSI Driver Spec documentation.
-func (cs *ControllerServer) ControllerUnpublishVolume(ctx context.Context, req *csi.ControllerUnpublishVolumeRequest) (*csi.ControllerUnpublishVolumeResponse, error) {
+func (cs *ControllerServer) ControllerUnpublishVolume(ctx context.Context, req *csi.ControllerUnpublishVolumeRequest) (resp *csi.ControllerUnpublishVolumeResponse, err error) {
 	log, _, done := logger.GetLogger(ctx).WithMethod("ControllerUnpublishVolume")
 	defer done()
+	defer func() { err = cs.maintenance.unavailable(err) }()
 
 	functionStartTime := time.Now()
-	log.V(2).Info("Processing request", "req", req)
+	log.V(2).Info("Processing request", "req", redactSecrets(req))
 
-	volumeID, statusErr := linodevolumes.VolumeIdAsInt("ControllerUnpublishVolume", req)
+	volumeID, statusErr := cs.volumeIDFromRequest(ctx, "ControllerUnpublishVolume", req)
 	if statusErr != nil {
 		observability.RecordMetrics(observability.ControllerUnpublishVolumeTotal, observability.ControllerUnpublishVolumeDuration, observability.Failed, functionStartTime)
 		return &csi.ControllerUnpublishVolumeResponse{}, statusErr
@@ -262,8 +415,24 @@ func (cs *ControllerServer) ControllerUnpublishVolume(ctx context.Context, req *
 		return &csi.ControllerUnpublishVolumeResponse{}, statusErr
 	}
 
+	if cs.cordonedNodes != nil {
+		cs.cordonedNodes.forget(volumeID, linodeID)
+	}
+
+	if _, ok := cs.detaches.inProgress(volumeID); ok {
+		observability.RecordMetrics(observability.ControllerUnpublishVolumeTotal, observability.ControllerUnpublishVolumeDuration, observability.Completed, functionStartTime)
+		log.V(4).Info("Volume is already being detached, skipping", "volume_id", volumeID)
+		return &csi.ControllerUnpublishVolumeResponse{}, nil
+	}
+	// Report a failed background detach once; the retry detaches the volume
+	// again.
+	if err := cs.detaches.takeFailure(volumeID); err != nil {
+		observability.RecordMetrics(observability.ControllerUnpublishVolumeTotal, observability.ControllerUnpublishVolumeDuration, observability.Failed, functionStartTime)
+		return &csi.ControllerUnpublishVolumeResponse{}, err
+	}
+
 	log.V(4).Info("Checking if volume is attached", "volume_id", volumeID, "node_id", linodeID)
-	volume, err := cs.client.GetVolume(ctx, volumeID)
+	volume, err := cs.linodeClient(ctx).GetVolume(ctx, volumeID)
 	if linodego.IsNotFound(err) {
 		observability.RecordMetrics(observability.ControllerUnpublishVolumeTotal, observability.ControllerUnpublishVolumeDuration, observability.Failed, functionStartTime)
 		log.V(4).Info("Volume not found, skipping", "volume_id", volumeID)
@@ -272,14 +441,20 @@ func (cs *ControllerServer) ControllerUnpublishVolume(ctx context.Context, req *
 		observability.RecordMetrics(observability.ControllerUnpublishVolumeTotal, observability.ControllerUnpublishVolumeDuration, observability.Failed, functionStartTime)
 		return &csi.ControllerUnpublishVolumeResponse{}, errInternal("get volume %d: %v", volumeID, err)
 	}
-	if volume.LinodeID != nil && *volume.LinodeID != linodeID {
+	// Retried requests and node drains find most volumes already detached
+	if volume.LinodeID == nil {
+		observability.RecordMetrics(observability.ControllerUnpublishVolumeTotal, observability.ControllerUnpublishVolumeDuration, observability.Completed, functionStartTime)
+		log.V(4).Info("Volume already detached, skipping", "volume_id", volumeID)
+		return &csi.ControllerUnpublishVolumeResponse{}, nil
+	}
+	if *volume.LinodeID != linodeID {
 		observability.RecordMetrics(observability.ControllerUnpublishVolumeTotal, observability.ControllerUnpublishVolumeDuration, observability.Failed, functionStartTime)
 		log.V(4).Info("Volume attached to different instance, skipping", "volume_id", volumeID, "attached_node_id", *volume.LinodeID, "requested_node_id", linodeID)
 		return &csi.ControllerUnpublishVolumeResponse{}, nil
 	}
 
 	log.V(4).Info("Executing detach volume", "volume_id", volumeID, "node_id", linodeID)
-	if err := cs.client.DetachVolume(ctx, volumeID); linodego.IsNotFound(err) {
+	if err := cs.linodeClient(ctx).DetachVolume(ctx, volumeID); linodego.IsNotFound(err) {
 		observability.RecordMetrics(observability.ControllerUnpublishVolumeTotal, observability.ControllerUnpublishVolumeDuration, observability.Failed, functionStartTime)
 		return &csi.ControllerUnpublishVolumeResponse{}, nil
 	} else if err != nil {
@@ -287,8 +462,20 @@ func (cs *ControllerServer) ControllerUnpublishVolume(ctx context.Context, req *
 		return &csi.ControllerUnpublishVolumeResponse{}, errInternal("detach volume %d: %v", volumeID, err)
 	}
 
+	// When asynchronous unpublishing is enabled, return as soon as the
+	// detach was accepted, and confirm it in the background.
+	if cs.driver.opts.AsyncControllerUnpublish {
+		if cs.detaches.start(volumeID, linodeID) {
+			go cs.reconcileDetach(context.WithoutCancel(ctx), volumeID, linodeID)
+		}
+		observability.RecordMetrics(observability.ControllerUnpublishVolumeTotal, observability.ControllerUnpublishVolumeDuration, observability.Completed, functionStartTime)
+		log.V(2).Info("Volume detach accepted", "volume_id", volumeID)
+		return &csi.ControllerUnpublishVolumeResponse{}, nil
+	}
+
 	log.V(4).Info("Waiting for volume to detach", "volume_id", volumeID, "node_id", linodeID)
-	if _, err := cs.client.WaitForVolumeLinodeID(ctx, volumeID, nil, waitTimeout()); err != nil {
+	setRPCPhase(ctx, rpcPhaseWaitForDetach)
+	if _, err := cs.waitForVolumeLinodeID(ctx, volumeID, nil, waitTimeout()); err != nil {
 		observability.RecordMetrics(observability.ControllerUnpublishVolumeTotal, observability.ControllerUnpublishVolumeDuration, observability.Failed, functionStartTime)
 		return &csi.ControllerUnpublishVolumeResponse{}, errInternal("wait for volume %d to detach: %v", volumeID, err)
 	}
@@ -307,9 +494,9 @@ func (cs *ControllerServer) ValidateVolumeCapabilities(ctx context.Context, req
 	log, _, done := logger.GetLogger(ctx).WithMethod("ValidateVolumeCapabilities")
 	defer done()
 
-	log.V(2).Info("Processing request", "req", req)
+	log.V(2).Info("Processing request", "req", redactSecrets(req))
 
-	volumeID, statusErr := linodevolumes.VolumeIdAsInt("ControllerValidateVolumeCapabilities", req)
+	volumeID, statusErr := cs.volumeIDFromRequest(ctx, "ControllerValidateVolumeCapabilities", req)
 	if statusErr != nil {
 		return &csi.ValidateVolumeCapabilitiesResponse{}, statusErr
 	}
@@ -319,15 +506,24 @@ func (cs *ControllerServer) ValidateVolumeCapabilities(ctx context.Context, req
 		return &csi.ValidateVolumeCapabilitiesResponse{}, errNoVolumeCapabilities
 	}
 
-	if _, err := cs.client.GetVolume(ctx, volumeID); linodego.IsNotFound(err) {
+	if _, err := cs.linodeClient(ctx).GetVolume(ctx, volumeID); linodego.IsNotFound(err) {
 		return &csi.ValidateVolumeCapabilitiesResponse{}, errVolumeNotFound(volumeID)
 	} else if err != nil {
 		return &csi.ValidateVolumeCapabilitiesResponse{}, errInternal("get volume: %v", err)
 	}
 
+	// Confirm the request with what it asked for, or say why it is not
+	// supported, so that users know what to change in their claims
 	resp = &csi.ValidateVolumeCapabilitiesResponse{}
-	if validVolumeCapabilities(volumeCapabilities) {
-		resp.Confirmed = &csi.ValidateVolumeCapabilitiesResponse_Confirmed{VolumeCapabilities: volumeCapabilities}
+	if problems := validateVolumeCapabilitiesProblems(req); len(problems) > 0 {
+		resp.Message = strings.Join(problems, "; ")
+	} else {
+		resp.Confirmed = &csi.ValidateVolumeCapabilitiesResponse_Confirmed{
+			VolumeContext:      req.GetVolumeContext(),
+			VolumeCapabilities: volumeCapabilities,
+			Parameters:         req.GetParameters(),
+			MutableParameters:  req.GetMutableParameters(),
+		}
 	}
 	log.V(2).Info("Supported capabilities", "response", resp)
 
@@ -343,81 +539,202 @@ func (cs *ControllerServer) ListVolumes(ctx context.Context, req *csi.ListVolume
 	log, _, done := logger.GetLogger(ctx).WithMethod("ListVolumes")
 	defer done()
 
-	log.V(2).Info("Processing request", "req", req)
-
-	startingToken := req.GetStartingToken()
-	nextToken := ""
+	log.V(2).Info("Processing request", "req", redactSecrets(req))
 
-	listOpts := linodego.NewListOptions(0, "")
-	if req.GetMaxEntries() > 0 {
-		listOpts.PageSize = int(req.GetMaxEntries())
+	if req.GetMaxEntries() < 0 {
+		return &csi.ListVolumesResponse{}, status.Errorf(codes.InvalidArgument,
+			"invalid max entries: %d", req.GetMaxEntries())
 	}
 
-	if startingToken != "" {
-		startingPage, errParse := strconv.ParseInt(startingToken, 10, 0)
+	// The starting token is the number of volumes returned by the previous
+	// calls.
+	offset := 0
+	if startingToken := req.GetStartingToken(); startingToken != "" {
+		parsed, errParse := strconv.ParseInt(startingToken, 10, 0)
 		if errParse != nil {
 			return &csi.ListVolumesResponse{}, status.Errorf(codes.Aborted,
 				"invalid starting token: %q", startingToken)
 		}
 
-		if startingPage < math.MinInt || startingPage > math.MaxInt {
+		if parsed < 0 || parsed > math.MaxInt {
 			return &csi.ListVolumesResponse{}, status.Errorf(codes.Aborted,
 				"starting token out of bounds: %q", startingToken)
 		}
-		listOpts.Page = int(startingPage)
-		nextToken = strconv.Itoa(listOpts.Page + 1)
+		offset = int(parsed)
 	}
 
-	// List all volumes
-	log.V(4).Info("Listing volumes", "list_opts", listOpts)
-	volumes, err := cs.client.ListVolumes(ctx, listOpts)
+	filter, err := cs.listVolumesFilter()
 	if err != nil {
 		return &csi.ListVolumesResponse{}, errInternal("list volumes: %v", err)
 	}
 
-	entries := make([]*csi.ListVolumesResponse_Entry, 0, len(volumes))
-	for volNum := range volumes {
-		key := linodevolumes.CreateLinodeVolumeKey(volumes[volNum].ID, volumes[volNum].Label)
-
-		// If the volume is attached to a Linode instance, add it to the
-		// list. Note that in the Linode API, volumes can only be
-		// attached to a single Linode at a time. We are storing it in
-		// a []string here, since that is what the response struct
-		// returns. We do not need to pre-allocate the slice with
-		// make(), since the CSI specification says this response field
-		// is optional, and thus it should tolerate a nil slice.
-		var publishedNodeIDs []string
-		if volumes[volNum].LinodeID != nil {
-			publishedNodeIDs = append(publishedNodeIDs, strconv.Itoa(*volumes[volNum].LinodeID))
+	limit := int(req.GetMaxEntries())
+	if limit == 0 {
+		limit = math.MaxInt
+	}
+
+	// The volumes are converted to entries page by page, and the conditions
+	// of the volumes are described by their latest event, found in a single
+	// request for all of them.
+	var (
+		entries       []*csi.ListVolumesResponse_Entry
+		size          int
+		events        map[int]*linodego.Event
+		eventsFetched bool
+	)
+	add := func(vol *linodego.Volume) bool {
+		if !eventsFetched {
+			var eventsErr error
+			if events, eventsErr = cs.latestVolumeEvents(ctx); eventsErr != nil {
+				log.Error(eventsErr, "Failed to get the latest volume events")
+			}
+			eventsFetched = true
+		}
+		entry := listVolumesEntry(vol, events[vol.ID])
+		entry.Status.PublishedNodeIds = cs.withCordonedNode(ctx, vol.ID, entry.Status.PublishedNodeIds)
+		entrySize := proto.Size(entry)
+		// Leave the remaining entries to the next call rather than send a
+		// response the CO would refuse, keeping at least one entry so that
+		// the listing progresses
+		if len(entries) > 0 && size+entrySize > maxListVolumesResponseBytes {
+			return false
 		}
+		entries = append(entries, entry)
+		size += entrySize
+		return true
+	}
 
-		entries = append(entries, &csi.ListVolumesResponse_Entry{
-			Volume: &csi.Volume{
-				VolumeId:      key.GetVolumeKey(),
-				CapacityBytes: gbToBytes(volumes[volNum].Size),
-				AccessibleTopology: []*csi.Topology{
-					{
-						Segments: map[string]string{
-							VolumeTopologyRegion: volumes[volNum].Region,
-						},
-					},
-				},
-			},
-			Status: &csi.ListVolumesResponse_VolumeStatus{
-				PublishedNodeIds: publishedNodeIDs,
-				VolumeCondition: &csi.VolumeCondition{
-					Abnormal: false,
-				},
-			},
-		})
+	// List the volumes, restricted to the configured regions and tag
+	log.V(4).Info("Listing volumes", "filter", filter, "offset", offset, "max_entries", req.GetMaxEntries())
+	added, more, err := cs.listVolumes(ctx, filter, offset, limit, add)
+	if err != nil {
+		return &csi.ListVolumesResponse{}, errInternal("list volumes: %v", err)
+	}
+
+	nextToken := ""
+	if more {
+		nextToken = strconv.Itoa(offset + added)
 	}
 
 	resp := &csi.ListVolumesResponse{
 		Entries:   entries,
 		NextToken: nextToken,
 	}
+	observability.ListVolumesResponseBytes.Observe(float64(proto.Size(resp)))
 
-	log.V(2).Info("Volumes listed", "response", resp)
+	// The response of large accounts is too large to be logged
+	log.V(2).Info("Volumes listed", "entries", len(entries), "next_token", nextToken)
+	return resp, nil
+}
+
+// listVolumesEntry returns the ListVolumes entry of vol, whose condition is
+// described by its latest event, if any.
+func listVolumesEntry(vol *linodego.Volume, event *linodego.Event) *csi.ListVolumesResponse_Entry {
+	key := linodevolumes.CreateLinodeVolumeKey(vol.ID, vol.Label)
+
+	// If the volume is attached to a Linode instance, add it to the
+	// list. Note that in the Linode API, volumes can only be
+	// attached to a single Linode at a time. We are storing it in
+	// a []string here, since that is what the response struct
+	// returns. We do not need to pre-allocate the slice with
+	// make(), since the CSI specification says this response field
+	// is optional, and thus it should tolerate a nil slice.
+	var publishedNodeIDs []string
+	if vol.LinodeID != nil {
+		publishedNodeIDs = append(publishedNodeIDs, strconv.Itoa(*vol.LinodeID))
+	}
+
+	return &csi.ListVolumesResponse_Entry{
+		Volume: &csi.Volume{
+			VolumeId:      key.GetVolumeKey(),
+			CapacityBytes: gbToBytes(vol.Size),
+			VolumeContext: listedVolumeContext(vol),
+			AccessibleTopology: []*csi.Topology{
+				{
+					Segments: map[string]string{
+						VolumeTopologyRegion: vol.Region,
+					},
+				},
+			},
+		},
+		Status: &csi.ListVolumesResponse_VolumeStatus{
+			PublishedNodeIds: publishedNodeIDs,
+			VolumeCondition:  volumeEventCondition(vol, event),
+		},
+	}
+}
+
+// listedVolumeContext returns the context of vol reported by ListVolumes and
+// ControllerGetVolume: the mutable parameters applied to it, with its region,
+// creation time and encryption. They are read from the fields of the volume
+// in the Linode API rather than from its tags or the context it was created
+// with, so that the volumes created by older versions of the driver, or
+// adopted by it, are reported with the same keys.
+func listedVolumeContext(vol *linodego.Volume) map[string]string {
+	volumeContext := appliedParameters(vol)
+	if volumeContext == nil {
+		volumeContext = make(map[string]string)
+	}
+	if vol.Region != "" {
+		volumeContext[VolumeTopologyRegion] = vol.Region
+	}
+	if vol.Created != nil {
+		volumeContext[VolumeCreatedAtAttribute] = formatTimestamp(*vol.Created)
+	}
+	volumeContext[VolumeEncryption] = strconv.FormatBool(vol.Encryption == "enabled")
+	return volumeContext
+}
+
+// ControllerGetVolume returns the current state of a volume. Its condition
+// is abnormal if the volume is not active, or if the latest attach, detach,
+// resize or clone of the volume failed; the condition's message describes
+// that event.
+// For more details, refer to the CSI Driver Spec documentation.
+func (cs *ControllerServer) ControllerGetVolume(ctx context.Context, req *csi.ControllerGetVolumeRequest) (*csi.ControllerGetVolumeResponse, error) {
+	log, _, done := logger.GetLogger(ctx).WithMethod("ControllerGetVolume")
+	defer done()
+
+	log.V(2).Info("Processing request", "req", redactSecrets(req))
+
+	volumeID, err := cs.volumeIDFromRequest(ctx, "ControllerGetVolume", req)
+	if err != nil {
+		return &csi.ControllerGetVolumeResponse{}, err
+	}
+
+	vol, err := cs.linodeClient(ctx).GetVolume(ctx, volumeID)
+	if linodego.IsNotFound(err) {
+		return &csi.ControllerGetVolumeResponse{}, errVolumeNotFound(volumeID)
+	} else if err != nil {
+		return &csi.ControllerGetVolumeResponse{}, errInternal("get volume %d: %v", volumeID, err)
+	}
+
+	var publishedNodeIDs []string
+	if vol.LinodeID != nil {
+		publishedNodeIDs = append(publishedNodeIDs, strconv.Itoa(*vol.LinodeID))
+	}
+	publishedNodeIDs = cs.withCordonedNode(ctx, vol.ID, publishedNodeIDs)
+
+	key := linodevolumes.CreateLinodeVolumeKey(vol.ID, vol.Label)
+	resp := &csi.ControllerGetVolumeResponse{
+		Volume: &csi.Volume{
+			VolumeId:      key.GetVolumeKey(),
+			CapacityBytes: gbToBytes(vol.Size),
+			VolumeContext: listedVolumeContext(vol),
+			AccessibleTopology: []*csi.Topology{
+				{
+					Segments: map[string]string{
+						VolumeTopologyRegion: vol.Region,
+					},
+				},
+			},
+		},
+		Status: &csi.ControllerGetVolumeResponse_VolumeStatus{
+			PublishedNodeIds: publishedNodeIDs,
+			VolumeCondition:  cs.volumeCondition(ctx, vol),
+		},
+	}
+
+	log.V(2).Info("Volume retrieved", "response", resp)
 	return resp, nil
 }
 
@@ -429,7 +746,7 @@ func (cs *ControllerServer) ControllerGetCapabilities(ctx context.Context, req *
 	log, _, done := logger.GetLogger(ctx).WithMethod("ControllerGetCapabilities")
 	defer done()
 
-	log.V(2).Info("Processing request", "req", req)
+	log.V(2).Info("Processing request", "req", redactSecrets(req))
 
 	resp := &csi.ControllerGetCapabilitiesResponse{
 		Capabilities: cs.driver.cscap,
@@ -447,24 +764,30 @@ func (cs *ControllerServer) ControllerGetCapabilities(ctx context.Context, req *
 func (cs *ControllerServer) ControllerExpandVolume(ctx context.Context, req *csi.ControllerExpandVolumeRequest) (resp *csi.ControllerExpandVolumeResponse, err error) {
 	log, _, done := logger.GetLogger(ctx).WithMethod("ControllerExpandVolume")
 	defer done()
+	defer func() { err = cs.maintenance.unavailable(err) }()
 
-	log.V(2).Info("Processing request", "req", req)
+	log.V(2).Info("Processing request", "req", redactSecrets(req))
 
-	volumeID, statusErr := linodevolumes.VolumeIdAsInt("ControllerExpandVolume", req)
+	volumeID, statusErr := cs.volumeIDFromRequest(ctx, "ControllerExpandVolume", req)
 	if statusErr != nil {
 		return nil, statusErr
 	}
 
-	size, err := getRequestCapacitySize(req.GetCapacityRange())
+	ctx, err = cs.withSecrets(ctx, req.GetSecrets())
 	if err != nil {
-		return resp, errInternal("get requested size from capacity range: %v", err)
+		return nil, err
+	}
+
+	size, err := getRequestCapacitySize(ctx, cs.driver, req.GetCapacityRange())
+	if err != nil {
+		return resp, err
 	}
 
 	// Get the volume
 	log.V(4).Info("Checking if volume exists", "volume_id", volumeID)
-	vol, err := cs.client.GetVolume(ctx, volumeID)
+	vol, err := cs.linodeClient(ctx).GetVolume(ctx, volumeID)
 	if err != nil {
-		return resp, errInternal("get volume: %v", err)
+		return resp, errGetVolume(volumeID, err)
 	}
 
 	// Is the caller trying to resize the volume to be smaller than it currently is?
@@ -474,21 +797,24 @@ func (cs *ControllerServer) ControllerExpandVolume(ctx context.Context, req *csi
 
 	// Resize the volume
 	log.V(4).Info("Calling API to resize volume", "volume_id", volumeID)
-	if err = cs.client.ResizeVolume(ctx, volumeID, bytesToGB(size)); err != nil {
+	if err = cs.linodeClient(ctx).ResizeVolume(ctx, volumeID, bytesToGB(size)); err != nil {
 		return resp, errInternal("resize volume %d: %v", volumeID, err)
 	}
 
 	// Wait for the volume to become active
 	log.V(4).Info("Waiting for volume to become active", "volume_id", volumeID)
-	vol, err = cs.client.WaitForVolumeStatus(ctx, vol.ID, linodego.VolumeActive, waitTimeout())
+	setRPCPhase(ctx, rpcPhaseWaitForResize)
+	vol, err = cs.waitForVolumeActive(ctx, vol.ID, waitTimeout())
 	if err != nil {
 		return resp, errInternal("timed out waiting for volume %d to become active: %v", volumeID, err)
 	}
 	log.V(4).Info("Volume active", "vol", vol)
+	cs.stampVolume(ctx, vol)
 
 	log.V(2).Info("Volume resized successfully", "volume_id", volumeID)
+	recordSizeRoundUp(ctx, "ControllerExpandVolume", req.GetCapacityRange().GetRequiredBytes(), gbToBytes(vol.Size))
 	resp = &csi.ControllerExpandVolumeResponse{
-		CapacityBytes:         size,
+		CapacityBytes:         gbToBytes(vol.Size),
 		NodeExpansionRequired: false,
 	}
 	return resp, nil
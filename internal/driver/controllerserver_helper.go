@@ -5,6 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,6 +16,7 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
+	kubeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/kube-client"
 	linodevolumes "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-volumes"
 	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
 	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
@@ -69,19 +73,40 @@ const (
 	// of tags to the Linode API.
 	VolumeTags = Name + "/volumeTags"
 
+	// FilesystemTypeAttribute is the StorageClass parameter key used to
+	// choose the file system volumes are formatted with, when the volume
+	// capability does not specify one. It is passed to the node plugin
+	// through the volume context.
+	FilesystemTypeAttribute = Name + "/fs-type"
+
 	// PublishInfoVolumeName is used to pass the name of the volume as it exists
 	// in the Linode API (the "label") to [NodeStageVolume] and
 	// [NodePublishVolume].
 	PublishInfoVolumeName = Name + "/volume-name"
 
+	// RequestedBytesAttribute is the volume context key of the capacity a
+	// volume was requested with, set when the volume is larger, e.g. since
+	// it was rounded up to the minimum volume size or to a whole number of
+	// GiB, so that the allocated and requested storage can be told apart.
+	RequestedBytesAttribute = Name + "/requestedBytes"
+
 	// VolumeTopologyRegion is the parameter key used to indicate the region
 	// the volume exists in.
 	VolumeTopologyRegion string = "topology.linode.com/region"
 
+	// AttachConfigAnnotation is the Node annotation holding the ID of the
+	// configuration profile volumes are attached to, when
+	// [Options.AttachConfigFromNodeAnnotation] is enabled.
+	AttachConfigAnnotation = Name + "/attach-config-id"
+
 	// devicePathKey is the key used in the publish context map when a volume is
 	// published/attached to an instance.
 	devicePathKey = "devicePath"
 
+	// configIDKey is the key used in the publish context map to report the
+	// configuration profile a volume was attached to, if one was selected.
+	configIDKey = "configID"
+
 	// volumeEncryption is the key used in the context map for encryption
 	VolumeEncryption = Name + "/encrypted"
 )
@@ -89,19 +114,22 @@ const (
 // Struct to return volume parameters when prepareVolumeParams is called
 
 type VolumeParams struct {
-	VolumeName       string
-	TargetSizeGB     int
-	Size             int64
-	EncryptionStatus string
-	Region           string
+	VolumeName string
+	// LegacyVolumeName is the name the volume had before
+	// [Options.ClusterName] was set, or "" if it is not set.
+	LegacyVolumeName string
+	// PreviousPrefixVolumeNames are the names the volume had with
+	// [Options.PreviousVolumeLabelPrefix], or nil if it is not set.
+	PreviousPrefixVolumeNames []string
+	TargetSizeGB              int
+	Size                      int64
+	EncryptionStatus          string
+	Region                    string
 }
 
-// canAttach indicates whether or not another volume can be attached to the
-// Linode with the given ID.
-//
-// Whether or not another volume can be attached is based on how many instance
-// disks and block storage volumes are currently attached to the instance.
-func (cs *ControllerServer) canAttach(ctx context.Context, instance *linodego.Instance) (canAttach bool, err error) {
+// attachmentCapacity returns the maximum number of volumes that can be
+// attached to instance, and the number of volumes attached to it.
+func (cs *ControllerServer) attachmentCapacity(ctx context.Context, instance *linodego.Instance) (limit, attached int, err error) {
 	log := logger.GetLogger(ctx)
 	log.V(4).Info("Checking if volume can be attached", "instance_id", instance.ID)
 	if !observability.SkipObservability {
@@ -110,23 +138,21 @@ func (cs *ControllerServer) canAttach(ctx context.Context, instance *linodego.In
 	}
 
 	// Get the maximum number of volume attachments allowed for the instance
-	limit, err := cs.maxAllowedVolumeAttachments(ctx, instance)
+	limit, err = cs.maxAllowedVolumeAttachments(ctx, instance)
 	if err != nil {
-		return false, err
+		return 0, 0, err
 	}
 
 	// List the volumes currently attached to the instance
-	volumes, err := cs.client.ListInstanceVolumes(ctx, instance.ID, nil)
+	volumes, err := cs.linodeClient(ctx).ListInstanceVolumes(ctx, instance.ID, nil)
 	if err != nil {
-		return false, errInternal("list instance volumes: %v", err)
+		return 0, 0, errInternal("list instance volumes: %v", err)
 	}
-
-	// Return true if the number of attached volumes is less than the limit
-	return len(volumes) < limit, nil
+	return limit, len(volumes), nil
 }
 
 // maxAllowedVolumeAttachments calculates the maximum number of volumes that can be attached to a Linode instance,
-// taking into account the instance's memory and currently attached disks.
+// taking into account the instance's memory and currently attached disks, except the excluded ones.
 func (cs *ControllerServer) maxAllowedVolumeAttachments(ctx context.Context, instance *linodego.Instance) (int, error) {
 	log := logger.GetLogger(ctx)
 	log.V(4).Info("Calculating max volume attachments")
@@ -141,14 +167,14 @@ func (cs *ControllerServer) maxAllowedVolumeAttachments(ctx context.Context, ins
 	}
 
 	// Retrieve the list of disks currently attached to the instance
-	disks, err := cs.client.ListInstanceDisks(ctx, instance.ID, nil)
+	disks, err := cs.linodeClient(ctx).ListInstanceDisks(ctx, instance.ID, nil)
 	if err != nil {
 		return 0, errInternal("list instance disks: %v", err)
 	}
 
 	// Convert the reported memory from MB to bytes
 	memBytes := uint(instance.Specs.Memory) << 20
-	return maxVolumeAttachments(memBytes) - len(disks), nil
+	return cs.driver.volumeAttachmentLimit(ctx, instance.ID, memBytes, disks), nil
 }
 
 // getContentSourceVolume retrieves information about the Linode volume to clone from.
@@ -185,7 +211,7 @@ func (cs *ControllerServer) getContentSourceVolume(ctx context.Context, contentS
 	}
 
 	// Retrieve the volume data using the parsed volume ID
-	volumeData, err := cs.client.GetVolume(ctx, volKey.VolumeID)
+	volumeData, err := cs.linodeClient(ctx).GetVolume(ctx, volKey.VolumeID)
 	if err != nil {
 		return nil, errInternal("get volume %d: %v", volKey.VolumeID, err)
 	}
@@ -194,11 +220,9 @@ func (cs *ControllerServer) getContentSourceVolume(ctx context.Context, contentS
 	}
 
 	// Check if the source volume's region matches the required region
-	requiredRegion := cs.metadata.Region
-	if accessibilityRequirements != nil {
-		if topologyRegion := getRegionFromTopology(accessibilityRequirements); topologyRegion != "" {
-			requiredRegion = topologyRegion
-		}
+	requiredRegion, err := cs.volumeRegion(ctx, accessibilityRequirements)
+	if err != nil {
+		return nil, err
 	}
 
 	if volumeData.Region != requiredRegion {
@@ -212,7 +236,8 @@ func (cs *ControllerServer) getContentSourceVolume(ctx context.Context, contentS
 // attemptCreateLinodeVolume creates a Linode volume while ensuring idempotency.
 // It checks for existing volumes with the same label and either returns the existing
 // volume or creates a new one, optionally cloning from a source volume.
-func (cs *ControllerServer) attemptCreateLinodeVolume(ctx context.Context, label, tags, volumeEncryption string, sizeGB int, sourceVolume *linodevolumes.LinodeVolumeKey, region string) (*linodego.Volume, error) {
+func (cs *ControllerServer) attemptCreateLinodeVolume(ctx context.Context, label string, parameters map[string]string, volumeEncryption string, sizeGB int, sourceVolume *linodevolumes.LinodeVolumeKey, region string) (*linodego.Volume, error) {
+	tags := parameters[VolumeTags]
 	log := logger.GetLogger(ctx)
 	log.V(4).Info("Attempting to create Linode volume", "label", label, "sizeGB", sizeGB, "tags", tags, "encryptionStatus", volumeEncryption, "region", region)
 	if !observability.SkipObservability {
@@ -220,15 +245,15 @@ func (cs *ControllerServer) attemptCreateLinodeVolume(ctx context.Context, label
 		defer span.End()
 	}
 
-	// List existing volumes with the specified label
-	jsonFilter, err := json.Marshal(map[string]string{"label": label})
-	if err != nil {
-		return nil, errInternal("marshal json filter: %v", err)
+	// Return the volume being cloned by an earlier request, if any
+	if vol, ok, err := cs.trackedClone(ctx, label); ok || err != nil {
+		return vol, err
 	}
 
-	volumes, err := cs.client.ListVolumes(ctx, linodego.NewListOptions(0, string(jsonFilter)))
+	// List existing volumes with the specified label
+	volumes, err := cs.listVolumesByLabel(ctx, label)
 	if err != nil {
-		return nil, errInternal("list volumes: %v", err)
+		return nil, err
 	}
 
 	// Raise an error if more than one volume with the same label exists
@@ -241,33 +266,150 @@ func (cs *ControllerServer) attemptCreateLinodeVolume(ctx context.Context, label
 		return &volumes[0], nil
 	}
 
+	// Refuse to go over the volume limit of the account, if it is enforced
+	if err := cs.checkAccountVolumeLimit(ctx, parameters); err != nil {
+		return nil, err
+	}
+
+	// Tag the volume with the cluster and the driver that created it
+	var attributes []attribute
+	if cluster, ok := cs.driver.clusterAttribute(); ok {
+		attributes = append(attributes, cluster)
+	}
+	if stamp, ok := cs.driver.driverAttribute(); ok {
+		attributes = append(attributes, stamp)
+	}
+
 	// Clone the source volume if provided, otherwise create a new volume
 	if sourceVolume != nil {
-		return cs.cloneLinodeVolume(ctx, label, sourceVolume.VolumeID)
+		vol, err := cs.cloneLinodeVolume(ctx, label, sourceVolume.VolumeID)
+		if err != nil {
+			return nil, err
+		}
+		cs.clones.start(label, cloneOperation{volumeID: vol.ID, sourceID: sourceVolume.VolumeID, started: time.Now()})
+
+		// Tag the clone as in progress until it is active, so that a request
+		// retried after a restart of the controller finds it
+		attributes = append([]attribute{cloneSourceAttribute(sourceVolume.VolumeID)}, attributes...)
+		if err := cs.updateVolumeAttributes(ctx, vol, attributes); err != nil {
+			return nil, err
+		}
+		return vol, nil
 	}
 
-	return cs.createLinodeVolume(ctx, label, tags, volumeEncryption, sizeGB, region)
+	// Tag the volume as provisioning until it is active, so that a request
+	// retried after this one timed out waits for it
+	userTags, err := userVolumeTags(parameters)
+	if err != nil {
+		return nil, err
+	}
+	volumeTags, err := withAttributes(userTags, append(attributes, attribute{key: attributeProvisioning}))
+	if err != nil {
+		return nil, errInternal("encode attributes of volume %s: %v", label, err)
+	}
+	return cs.createLinodeVolume(ctx, label, volumeTags, volumeEncryption, sizeGB, region)
 }
 
-// Helper function to extract region from topology
-func getRegionFromTopology(requirements *csi.TopologyRequirement) string {
-	topologies := requirements.GetPreferred()
-	if len(topologies) == 0 {
-		topologies = requirements.GetRequisite()
+// checkAccountVolumeLimit fails with ResourceExhausted if the account already
+// has [Options.AccountVolumeLimit] volumes, so the request fails clearly
+// instead of with an error from the Linode API, and records a warning event on
+// the claim of the volume. Volumes are not counted if the limit is not set.
+func (cs *ControllerServer) checkAccountVolumeLimit(ctx context.Context, parameters map[string]string) error {
+	if cs.driver == nil || cs.driver.opts.AccountVolumeLimit <= 0 {
+		return nil
+	}
+	log := logger.GetLogger(ctx)
+	log.V(4).Info("Entering checkAccountVolumeLimit()", "limit", cs.driver.opts.AccountVolumeLimit)
+	defer log.V(4).Info("Exiting checkAccountVolumeLimit()")
+
+	// Only fetch the first page; the total number of volumes is returned
+	// with it
+	opts := &linodego.ListOptions{
+		PageOptions: &linodego.PageOptions{Page: 1},
+		PageSize:    minListPageSize,
+	}
+	if _, err := cs.linodeClient(ctx).ListVolumes(ctx, opts); err != nil {
+		return errInternal("list volumes: %v", err)
+	}
+
+	count, limit := opts.Results, cs.driver.opts.AccountVolumeLimit
+	if count >= limit {
+		log.V(0).Info("Refusing to create a volume over the account volume limit", "volumes", count, "limit", limit)
+		observability.AccountVolumeLimitExceededTotal.Inc()
+		err := errAccountVolumeLimit(count, limit)
+		cs.recordClaimWarning(ctx, parameters, "AccountVolumeLimitExceeded", status.Convert(err).Message())
+		return err
+	}
+	return nil
+}
+
+// recordClaimWarning records a warning event on the claim a volume is created
+// for, when the external provisioner passes it in the parameters of the
+// request and a Kubernetes client is set. Failures are only logged, since the
+// event is informational.
+func (cs *ControllerServer) recordClaimWarning(ctx context.Context, parameters map[string]string, reason, message string) {
+	namespace, name := parameters[PVCNamespaceParameter], parameters[PVCNameParameter]
+	if cs.driver == nil || cs.driver.opts.KubeClient == nil || namespace == "" || name == "" {
+		return
+	}
+	if err := cs.driver.opts.KubeClient.CreatePersistentVolumeClaimEvent(ctx, namespace, name, "Warning", reason, message); err != nil {
+		logger.GetLogger(ctx).Error(err, "Failed to record event", "namespace", namespace, "name", name, "reason", reason)
+	}
+}
+
+// getRegionFromTopology returns the region to create a volume in for
+// requirements, as the CSI spec asks: the first preferred topology also in
+// the requisite topologies, if any, then the first requisite topology, whose
+// region is accepted by supported. It returns an empty region if no topology
+// has a region, and errNoSupportedTopologyRegion if none is accepted.
+func getRegionFromTopology(requirements *csi.TopologyRequirement, supported func(region string) bool) (string, error) {
+	requisite := topologyRegions(requirements.GetRequisite())
+	var candidates []string
+	for _, region := range topologyRegions(requirements.GetPreferred()) {
+		if len(requisite) == 0 || slices.Contains(requisite, region) {
+			candidates = append(candidates, region)
+		}
+	}
+	for _, region := range requisite {
+		if !slices.Contains(candidates, region) {
+			candidates = append(candidates, region)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", nil
 	}
 
-	if len(topologies) > 0 {
-		if value, ok := topologies[0].GetSegments()[VolumeTopologyRegion]; ok {
-			return value
+	for _, region := range candidates {
+		if supported(region) {
+			return region, nil
 		}
 	}
+	return "", errNoSupportedTopologyRegion(candidates)
+}
 
-	return ""
+// topologyRegions returns the regions of topologies, in order.
+func topologyRegions(topologies []*csi.Topology) []string {
+	var regions []string
+	for _, topology := range topologies {
+		if region := topology.GetSegments()[VolumeTopologyRegion]; region != "" {
+			regions = append(regions, region)
+		}
+	}
+	return regions
+}
+
+// supportsBlockStorage reports whether volumes can be created in region,
+// from the capabilities discovered at startup. Regions whose capabilities
+// are unknown are assumed to support them, and fail when the volume is
+// created otherwise.
+func (cs *ControllerServer) supportsBlockStorage(region string) bool {
+	supported, known := cs.driver.regionSupports(region, linodego.CapabilityBlockStorage)
+	return supported || !known
 }
 
 // createLinodeVolume creates a new Linode volume with the specified label, size, and tags.
 // It returns the created volume or an error if the creation fails.
-func (cs *ControllerServer) createLinodeVolume(ctx context.Context, label, tags, encryptionStatus string, sizeGB int, region string) (*linodego.Volume, error) {
+func (cs *ControllerServer) createLinodeVolume(ctx context.Context, label string, tags []string, encryptionStatus string, sizeGB int, region string) (*linodego.Volume, error) {
 	log := logger.GetLogger(ctx)
 	log.V(4).Info("Creating Linode volume", "label", label, "sizeGB", sizeGB, "tags", tags, "encryptionStatus", encryptionStatus, "region", region)
 	if !observability.SkipObservability {
@@ -275,21 +417,17 @@ func (cs *ControllerServer) createLinodeVolume(ctx context.Context, label, tags,
 		defer span.End()
 	}
 
-	// Prepare the volume creation request with region, label, and size.
+	// Prepare the volume creation request with region, label, size, and tags.
 	volumeReq := linodego.VolumeCreateOptions{
 		Region:     region,
 		Label:      label,
 		Size:       sizeGB,
 		Encryption: encryptionStatus,
-	}
-
-	// If tags are provided, split them into a slice for the request.
-	if tags != "" {
-		volumeReq.Tags = strings.Split(tags, ",")
+		Tags:       tags,
 	}
 
 	// Attempt to create the volume using the client and handle any errors.
-	result, err := cs.client.CreateVolume(ctx, volumeReq)
+	result, err := cs.linodeClient(ctx).CreateVolume(ctx, volumeReq)
 	if err != nil {
 		return nil, errInternal("create volume: %v", err)
 	}
@@ -298,6 +436,10 @@ func (cs *ControllerServer) createLinodeVolume(ctx context.Context, label, tags,
 	return result, nil
 }
 
+// maxSuggestedRegions is the number of regions supporting encryption that
+// are suggested when it is requested in a region that does not.
+const maxSuggestedRegions = 5
+
 // isEncryptionSupported is a helper function that checks if the specified region supports volume encryption.
 // It returns true or false based on the support for encryption in that region.
 func (cs *ControllerServer) isEncryptionSupported(ctx context.Context, region string) (bool, error) {
@@ -308,15 +450,21 @@ func (cs *ControllerServer) isEncryptionSupported(ctx context.Context, region st
 		defer span.End()
 	}
 
+	// Use the capabilities discovered at startup, unless the region was
+	// added since
+	if supported, known := cs.driver.regionSupports(region, linodego.CapabilityBlockStorageEncryption); known {
+		return supported, nil
+	}
+
 	// Get the specifications of specified region from Linode API
-	regionDetails, err := cs.client.GetRegion(ctx, region)
+	regionDetails, err := cs.linodeClient(ctx).GetRegion(ctx, region)
 	if err != nil {
 		return false, errInternal("failed to fetch region %s: %v", region, err)
 	}
 
 	// Check if encryption is supported in the specified region
 	for _, capability := range regionDetails.Capabilities {
-		if capability == "Block Storage Encryption" {
+		if capability == linodego.CapabilityBlockStorageEncryption {
 			return true, nil
 		}
 	}
@@ -336,7 +484,7 @@ func (cs *ControllerServer) cloneLinodeVolume(ctx context.Context, label string,
 		defer span.End()
 	}
 
-	result, err := cs.client.CloneVolume(ctx, sourceID, label)
+	result, err := cs.linodeClient(ctx).CloneVolume(ctx, sourceID, label)
 	if err != nil {
 		return nil, errInternal("clone volume %d: %v", sourceID, err)
 	}
@@ -346,9 +494,14 @@ func (cs *ControllerServer) cloneLinodeVolume(ctx context.Context, label string,
 }
 
 // getRequestCapacitySize validates the CapacityRange and determines the optimal volume size.
-// It returns the minimum size if no range is provided, or the required size if specified.
-// It ensures that the size is not negative and does not exceed the maximum limit.
-func getRequestCapacitySize(capRange *csi.CapacityRange) (int64, error) {
+// It returns the minimum size if no range is provided, the limit size if one is
+// specified, or the required size otherwise. Linode volumes are a whole number of
+// GiB, so the size is rounded down to whole GiB below the limit, or up above the
+// required size, and the range must allow such a size: it fails with OutOfRange
+// otherwise, or if the required size is above the limit. Earlier releases created
+// volumes of the required size in the latter case, which they still do when
+// validations are not enforced.
+func getRequestCapacitySize(ctx context.Context, linodeDriver *LinodeDriver, capRange *csi.CapacityRange) (int64, error) {
 	// If no capacity range is provided, return the minimum volume size
 	if capRange == nil {
 		return MinVolumeSizeBytes, nil
@@ -362,26 +515,38 @@ func getRequestCapacitySize(capRange *csi.CapacityRange) (int64, error) {
 
 	// Validate that at least one size is specified
 	if reqSize == 0 && maxSize == 0 {
-		return 0, errors.New("either RequiredBytes or LimitBytes must be set")
+		return 0, errInvalidCapacityRange("either RequiredBytes or LimitBytes must be set")
 	}
 
 	// Check for negative values
 	if reqSize < 0 || maxSize < 0 {
-		return 0, errors.New("RequiredBytes and LimitBytes must not be negative")
+		return 0, errInvalidCapacityRange("RequiredBytes and LimitBytes must not be negative")
+	}
+
+	// Handle case where max size is less than minimum allowed
+	if maxSize != 0 && maxSize < MinVolumeSizeBytes {
+		return 0, errCapacityOutOfRange(reqSize, maxSize)
+	}
+
+	// The smallest volume of at least the required size
+	size, ok := roundUpToGiB(adjustToMinimumSize(reqSize))
+	if !ok {
+		return 0, errCapacityOutOfRange(reqSize, maxSize)
 	}
 
 	// Handle case where only required size is specified
 	if maxSize == 0 {
-		return adjustToMinimumSize(reqSize), nil
+		return size, nil
 	}
 
-	// Handle case where max size is less than minimum allowed
-	if maxSize < MinVolumeSizeBytes {
-		return 0, fmt.Errorf("limit bytes %v is less than minimum allowed bytes %v", maxSize, MinVolumeSizeBytes)
+	// Determine the final size: the largest volume within the limit
+	if limit := roundDownToGiB(maxSize); limit >= size {
+		return limit, nil
 	}
-
-	// Determine the final size
-	return determineOptimalSize(reqSize, maxSize), nil
+	if err := linodeDriver.enforce(ctx, validationCapacityRange, errCapacityOutOfRange(reqSize, maxSize), "required_bytes", reqSize, "limit_bytes", maxSize); err != nil {
+		return 0, err
+	}
+	return size, nil
 }
 
 // adjustToMinimumSize ensures that the provided size is at least the minimum volume size.
@@ -393,41 +558,122 @@ func adjustToMinimumSize(size int64) int64 {
 	return size
 }
 
-// determineOptimalSize calculates the optimal size for a volume based on the required size and maximum size.
-// If the required size is zero or less than the maximum size, it returns the maximum size.
-// Otherwise, it returns the required size.
-func determineOptimalSize(reqSize, maxSize int64) int64 {
-	if reqSize == 0 || reqSize < maxSize {
-		return maxSize
+// roundUpToGiB rounds size up to a whole number of GiB. It returns false if
+// the rounded size overflows.
+func roundUpToGiB(size int64) (int64, bool) {
+	if size > math.MaxInt64-(1<<30-1) {
+		return 0, false
+	}
+	return roundDownToGiB(size + 1<<30 - 1), true
+}
+
+// roundDownToGiB rounds size down to a whole number of GiB.
+func roundDownToGiB(size int64) int64 {
+	return size &^ (1<<30 - 1)
+}
+
+// Reasons volumes are larger than the capacity they were requested with,
+// used as the "reason" label of the csi_volume_size_round_ups_total metric.
+const (
+	sizeRoundUpMinimum = "minimum"
+	sizeRoundUpGiB     = "gib"
+)
+
+// sizeRoundUp returns why a volume of allocatedBytes is larger than the
+// requiredBytes it was requested with: it was rounded up to the minimum
+// volume size, or to a whole number of GiB. It returns "" if it is not
+// larger, or larger for another reason, e.g. it was cloned from a larger
+// volume or sized after the limit of the capacity range.
+func sizeRoundUp(requiredBytes, allocatedBytes int64) string {
+	switch {
+	case requiredBytes <= 0 || allocatedBytes <= requiredBytes:
+		return ""
+	case requiredBytes < MinVolumeSizeBytes && allocatedBytes == MinVolumeSizeBytes:
+		return sizeRoundUpMinimum
+	case allocatedBytes-requiredBytes < 1<<30:
+		return sizeRoundUpGiB
+	}
+	return ""
+}
+
+// recordSizeRoundUp counts the volume of allocatedBytes that method created
+// or expanded for requiredBytes if it was rounded up.
+func recordSizeRoundUp(ctx context.Context, method string, requiredBytes, allocatedBytes int64) {
+	reason := sizeRoundUp(requiredBytes, allocatedBytes)
+	if reason == "" {
+		return
 	}
-	return reqSize
+	logger.GetLogger(ctx).V(4).Info("Volume size rounded up", "method", method, "reason", reason, "requiredBytes", requiredBytes, "allocatedBytes", allocatedBytes)
+	observability.VolumeSizeRoundUpsTotal.WithLabelValues(method, reason).Inc()
 }
 
 // validVolumeCapabilities checks if the provided volume capabilities are valid.
 // It ensures that each capability is non-nil and that the access mode is set to
 // SINGLE_NODE_WRITER.
 func validVolumeCapabilities(caps []*csi.VolumeCapability) bool {
-	// Iterate through each capability in the provided slice
-	for _, cap := range caps {
-		// Check if the capability is nil; if so, return false
-		if cap == nil {
+	for i, cap := range caps {
+		if volumeCapabilityProblem(i, cap) != "" {
 			return false
 		}
-		// Retrieve the access mode for the capability
-		accMode := cap.GetAccessMode()
+	}
+	return true
+}
 
-		// Check if the access mode is nil; if so, return false
-		if accMode == nil {
-			return false
+// volumeCapabilityProblem returns why cap, the volume capability at index i
+// of a request, is not valid, or "" if it is.
+func volumeCapabilityProblem(i int, cap *csi.VolumeCapability) string {
+	switch {
+	case cap == nil:
+		return fmt.Sprintf("volume capability %d is not set", i)
+	case cap.GetAccessMode() == nil:
+		return fmt.Sprintf("volume capability %d has no access mode", i)
+	case cap.GetAccessMode().GetMode() != csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER:
+		return fmt.Sprintf("access mode %s of volume capability %d is not supported, only %s is",
+			cap.GetAccessMode().GetMode(), i, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER)
+	}
+	return ""
+}
+
+// validateVolumeCapabilitiesProblems returns the reasons the volume
+// capabilities, parameters and mutable parameters of a
+// ValidateVolumeCapabilities request are not supported, naming the
+// capabilities by their index in the request.
+func validateVolumeCapabilitiesProblems(req *csi.ValidateVolumeCapabilitiesRequest) []string {
+	var problems []string
+	problem := func(err error) {
+		problems = append(problems, status.Convert(err).Message())
+	}
+
+	for i, cap := range req.GetVolumeCapabilities() {
+		if p := volumeCapabilityProblem(i, cap); p != "" {
+			problems = append(problems, p)
+			continue
 		}
+		if fsType := cap.GetMount().GetFsType(); fsType != "" && !supportedFSType(fsType) {
+			problems = append(problems, fmt.Sprintf("volume capability %d: %s", i, status.Convert(errUnsupportedFSType(fsType)).Message()))
+		}
+	}
 
-		// Ensure the access mode is SINGLE_NODE_WRITER; if not, return false
-		if accMode.GetMode() != csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER {
-			return false
+	parameters := req.GetParameters()
+	if fsType, ok := parameters[FilesystemTypeAttribute]; ok && !supportedFSType(fsType) {
+		problem(errUnsupportedFSType(fsType))
+	}
+	if parameters[ProjectQuotaAttribute] == True {
+		if fsType := parameters[FilesystemTypeAttribute]; fsType != "" {
+			if _, ok := projectQuotas[fsType]; !ok {
+				problem(errUnsupportedProjectQuota(fsType))
+			}
 		}
 	}
-	// All capabilities are valid; return true
-	return true
+	for _, validate := range []func(map[string]string) error{validateDeviceTuning, validatePartition, validateIOThrottle} {
+		if err := validate(parameters); err != nil {
+			problem(err)
+		}
+	}
+	if err := validateMutableParameters(req.GetMutableParameters()); err != nil {
+		problem(err)
+	}
+	return problems
 }
 
 // validateCreateVolumeRequest checks if the provided CreateVolumeRequest is valid.
@@ -458,6 +704,36 @@ func (cs *ControllerServer) validateCreateVolumeRequest(ctx context.Context, req
 		return errInvalidVolumeCapability(volCaps)
 	}
 
+	// Validate the file system type requested through the StorageClass, if any.
+	if fsType, ok := req.GetParameters()[FilesystemTypeAttribute]; ok && !supportedFSType(fsType) {
+		return errUnsupportedFSType(fsType)
+	}
+
+	// Validate the file system of volumes whose usage is capped, if it is
+	// known before they are staged.
+	if req.GetParameters()[ProjectQuotaAttribute] == True {
+		if fsType := req.GetParameters()[FilesystemTypeAttribute]; fsType != "" {
+			if _, ok := projectQuotas[fsType]; !ok {
+				return errUnsupportedProjectQuota(fsType)
+			}
+		}
+	}
+
+	if err := validateDeviceTuning(req.GetParameters()); err != nil {
+		return err
+	}
+	if err := validatePartition(req.GetParameters()); err != nil {
+		return err
+	}
+	if err := validateIOThrottle(req.GetParameters()); err != nil {
+		return err
+	}
+
+	// Validate the parameters of the VolumeAttributesClass of the claim, if any.
+	if err := validateMutableParameters(req.GetMutableParameters()); err != nil {
+		return err
+	}
+
 	// If all checks pass, return nil indicating the request is valid.
 	return nil
 }
@@ -479,23 +755,22 @@ func (cs *ControllerServer) prepareVolumeParams(ctx context.Context, req *csi.Cr
 	// Retrieve the capacity range from the request to determine the size limits for the volume.
 	capRange := req.GetCapacityRange()
 	// Get the requested size in bytes, handling any potential errors.
-	size, err := getRequestCapacitySize(capRange)
+	size, err := getRequestCapacitySize(ctx, cs.driver, capRange)
 	if err != nil {
 		return nil, err
 	}
 
 	// Get the region from req.AccessibilityRequirements if it exists. Fall back to the controller's metadata region if not specified.
-	accessibilityRequirements := req.GetAccessibilityRequirements()
-	region := cs.metadata.Region
-	if accessibilityRequirements != nil {
-		if topologyRegion := getRegionFromTopology(accessibilityRequirements); topologyRegion != "" {
-			log.V(4).Info("Using region from topology", "region", topologyRegion)
-			region = topologyRegion
-		}
+	region, err := cs.volumeRegion(ctx, req.GetAccessibilityRequirements())
+	if err != nil {
+		return nil, err
+	}
+	if allowed := cs.driver.opts.AllowedRegions; len(allowed) > 0 && !slices.Contains(allowed, region) {
+		return nil, errRegionNotAllowed(region, allowed)
 	}
 
-	preKey := linodevolumes.CreateLinodeVolumeKey(0, req.GetName())
-	volumeName := preKey.GetNormalizedLabelWithPrefix(cs.driver.volumeLabelPrefix)
+	volumeName, legacyVolumeName := cs.driver.volumeLabels(req.GetName(), req.GetParameters()[PVCNamespaceParameter], req.GetParameters()[PVCNameParameter])
+	previousPrefixVolumeNames := cs.driver.previousPrefixLabels(req.GetName(), req.GetParameters()[PVCNamespaceParameter], req.GetParameters()[PVCNameParameter])
 	targetSizeGB := bytesToGB(size)
 
 	// Check if encryption should be enabled
@@ -505,24 +780,28 @@ func (cs *ControllerServer) prepareVolumeParams(ctx context.Context, req *csi.Cr
 			return nil, err
 		}
 		if !supported {
-			return nil, errInternal("Volume encryption is not supported in the %s region", region)
+			return nil, errEncryptionNotSupported(region, cs.driver.regionsSupporting(linodego.CapabilityBlockStorageEncryption, region, maxSuggestedRegions))
 		}
 		encryptionStatus = "enabled"
 	}
 
 	log.V(4).Info("Volume parameters prepared", "parameters", &VolumeParams{
-		VolumeName:       volumeName,
-		TargetSizeGB:     targetSizeGB,
-		Size:             size,
-		EncryptionStatus: encryptionStatus,
-		Region:           region,
+		VolumeName:                volumeName,
+		LegacyVolumeName:          legacyVolumeName,
+		PreviousPrefixVolumeNames: previousPrefixVolumeNames,
+		TargetSizeGB:              targetSizeGB,
+		Size:                      size,
+		EncryptionStatus:          encryptionStatus,
+		Region:                    region,
 	})
 	return &VolumeParams{
-		VolumeName:       volumeName,
-		TargetSizeGB:     targetSizeGB,
-		Size:             size,
-		EncryptionStatus: encryptionStatus,
-		Region:           region,
+		VolumeName:                volumeName,
+		LegacyVolumeName:          legacyVolumeName,
+		PreviousPrefixVolumeNames: previousPrefixVolumeNames,
+		TargetSizeGB:              targetSizeGB,
+		Size:                      size,
+		EncryptionStatus:          encryptionStatus,
+		Region:                    region,
 	}, nil
 }
 
@@ -544,9 +823,73 @@ func (cs *ControllerServer) createVolumeContext(ctx context.Context, req *csi.Cr
 		volumeContext[PublishInfoVolumeName] = req.GetName()
 		volumeContext[LuksCipherAttribute] = req.GetParameters()[LuksCipherAttribute]
 		volumeContext[LuksKeySizeAttribute] = req.GetParameters()[LuksKeySizeAttribute]
+
+		// Let the node plugin replace the key of the source of clones, and
+		// record their lineage on their PersistentVolume.
+		if source := req.GetVolumeContentSource().GetVolume(); source != nil {
+			if key, err := linodevolumes.ParseLinodeVolumeKey(source.GetVolumeId()); err == nil {
+				volumeContext[LuksClonedFromAttribute] = strconv.Itoa(key.VolumeID)
+			}
+			if pvName := req.GetParameters()[PVNameParameter]; pvName != "" {
+				volumeContext[PVNameParameter] = pvName
+			}
+		}
+	}
+
+	if fsType := req.GetParameters()[FilesystemTypeAttribute]; fsType != "" {
+		volumeContext[FilesystemTypeAttribute] = fsType
+	}
+
+	// Cap the usage of the volume to the requested capacity, which is below
+	// the size of the volume when it is less than the minimum size.
+	if req.GetParameters()[ProjectQuotaAttribute] == True {
+		volumeContext[ProjectQuotaAttribute] = True
+		volumeContext[ProjectQuotaLimitAttribute] = projectQuotaContext(req.GetCapacityRange())
+	}
+
+	// Stage the volume on a partition of its device, if requested.
+	if partition := req.GetParameters()[PartitionAttribute]; partition != "" {
+		volumeContext[PartitionAttribute] = partition
+	}
+
+	// Tune the device of the volume when it is staged.
+	for _, key := range []string{ReadAheadKBAttribute, IOSchedulerAttribute} {
+		if value := req.GetParameters()[key]; value != "" {
+			volumeContext[key] = value
+		}
+	}
+
+	// Throttle the pods the volume is published for.
+	for _, k := range ioThrottleKeys {
+		if value := req.GetParameters()[k.attribute]; value != "" {
+			volumeContext[k.attribute] = value
+		}
+	}
+
+	// Pass the claim the volume was provisioned for to the node plugin, so
+	// it can report the volume's usage.
+	if pvcName, pvcNamespace := req.GetParameters()[PVCNameParameter], req.GetParameters()[PVCNamespaceParameter]; pvcName != "" && pvcNamespace != "" {
+		volumeContext[PVCNameParameter] = pvcName
+		volumeContext[PVCNamespaceParameter] = pvcNamespace
+	}
+
+	// Ask the node plugin to check the contents of verified clones.
+	if req.GetVolumeContentSource() != nil && req.GetParameters()[VerifyCloneAttribute] == True {
+		volumeContext[VerifyCloneAttribute] = True
+		if pvName := req.GetParameters()[PVNameParameter]; pvName != "" {
+			volumeContext[PVNameParameter] = pvName
+		}
+	}
+
+	// Record the requested capacity of volumes larger than requested.
+	if required := req.GetCapacityRange().GetRequiredBytes(); required > 0 && gbToBytes(vol.Size) > required {
+		volumeContext[RequestedBytesAttribute] = strconv.FormatInt(required, 10)
 	}
 
 	volumeContext[VolumeTopologyRegion] = vol.Region
+	if vol.Created != nil {
+		volumeContext[VolumeCreatedAtAttribute] = formatTimestamp(*vol.Created)
+	}
 
 	log.V(4).Info("Volume context created", "volumeContext", volumeContext)
 	return volumeContext
@@ -563,27 +906,32 @@ func (cs *ControllerServer) createAndWaitForVolume(ctx context.Context, name str
 		defer span.End()
 	}
 
-	vol, err := cs.attemptCreateLinodeVolume(ctx, name, parameters[VolumeTags], encryptionStatus, sizeGB, sourceInfo, region)
+	vol, err := cs.attemptCreateLinodeVolume(ctx, name, parameters, encryptionStatus, sizeGB, sourceInfo, region)
 	if err != nil {
 		return nil, err
 	}
 
-	// Check if the created volume's size matches the requested size.
-	// if not, and sourceInfo is nil, it indicates that the volume was not created from a source.
-	if vol.Size != sizeGB && sourceInfo == nil {
-		return nil, errAlreadyExists("volume %d already exists with size %d", vol.ID, vol.Size)
+	// Clones may take longer than the request, and are retried until they
+	// are active. Their size is the size of their source volume.
+	if sourceID, ok := cloneSource(vol); ok {
+		return cs.waitForClone(ctx, name, vol, sourceID)
+	} else if sourceInfo != nil {
+		return cs.waitForClone(ctx, name, vol, sourceInfo.VolumeID)
 	}
 
-	// Set the timeout for polling the volume status based on whether it's a clone or not.
-	statusPollTimeout := waitTimeout()
-	if sourceInfo != nil {
-		statusPollTimeout = cloneTimeout()
+	// Check if the created volume's size matches the requested size.
+	// if not, it indicates that the volume already existed with another size.
+	// Volumes created by an earlier request are only checked once active.
+	if vol.Size != sizeGB && !isProvisioning(vol) {
+		return nil, errAlreadyExists("volume %d already exists with size %d", vol.ID, vol.Size)
 	}
 
-	log.V(4).Info("Waiting for volume to be active", "volumeID", vol.ID)
-	vol, err = cs.client.WaitForVolumeStatus(ctx, vol.ID, linodego.VolumeActive, statusPollTimeout)
+	vol, err = cs.waitForNewVolume(ctx, vol)
 	if err != nil {
-		return nil, errInternal("Timed out waiting for volume %d to be active: %v", vol.ID, err)
+		return nil, err
+	}
+	if vol.Size != sizeGB {
+		return nil, errAlreadyExists("volume %d already exists with size %d", vol.ID, vol.Size)
 	}
 
 	log.V(4).Info("Volume is active", "volumeID", vol.ID)
@@ -592,7 +940,8 @@ func (cs *ControllerServer) createAndWaitForVolume(ctx context.Context, name str
 
 // prepareCreateVolumeResponse constructs a CreateVolumeResponse from the created volume details.
 // It includes the volume ID, capacity, accessible topology, and any relevant context or content source.
-func (cs *ControllerServer) prepareCreateVolumeResponse(ctx context.Context, vol *linodego.Volume, size int64, volContext map[string]string, sourceInfo *linodevolumes.LinodeVolumeKey, contentSource *csi.VolumeContentSource) *csi.CreateVolumeResponse {
+// The capacity is the size of the volume, which is that of the source volume for clones.
+func (cs *ControllerServer) prepareCreateVolumeResponse(ctx context.Context, vol *linodego.Volume, volContext map[string]string, sourceInfo *linodevolumes.LinodeVolumeKey, contentSource *csi.VolumeContentSource) *csi.CreateVolumeResponse {
 	log := logger.GetLogger(ctx)
 	log.V(4).Info("Entering prepareCreateVolumeResponse()", "vol", vol)
 	defer log.V(4).Info("Exiting prepareCreateVolumeResponse()")
@@ -605,7 +954,7 @@ func (cs *ControllerServer) prepareCreateVolumeResponse(ctx context.Context, vol
 	resp := &csi.CreateVolumeResponse{
 		Volume: &csi.Volume{
 			VolumeId:      key.GetVolumeKey(),
-			CapacityBytes: size,
+			CapacityBytes: gbToBytes(vol.Size),
 			AccessibleTopology: []*csi.Topology{
 				{
 					Segments: map[string]string{
@@ -650,7 +999,7 @@ func (cs *ControllerServer) validateControllerPublishVolumeRequest(ctx context.C
 	}
 
 	// extract the volume ID from the request
-	volumeID, err = linodevolumes.VolumeIdAsInt("ControllerPublishVolume", req)
+	volumeID, err = cs.volumeIDFromRequest(ctx, "ControllerPublishVolume", req)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -690,7 +1039,7 @@ func (cs *ControllerServer) getAndValidateVolume(ctx context.Context, volumeID i
 		defer span.End()
 	}
 
-	volume, err := cs.client.GetVolume(ctx, volumeID)
+	volume, err := cs.linodeClient(ctx).GetVolume(ctx, volumeID)
 	if linodego.IsNotFound(err) {
 		return "", errVolumeNotFound(volumeID)
 	} else if err != nil {
@@ -702,6 +1051,13 @@ func (cs *ControllerServer) getAndValidateVolume(ctx context.Context, volumeID i
 			log.V(4).Info("Volume already attached to instance", "volume_id", volume.ID, "node_id", *volume.LinodeID, "device_path", volume.FilesystemPath)
 			return volume.FilesystemPath, nil
 		}
+		// Volumes detached asynchronously stay attached until the detach
+		// completes, including the ones the controller no longer tracks
+		if cs.driver != nil && cs.driver.opts.AsyncControllerUnpublish {
+			if err := cs.untrackedDetachError(ctx, volumeID, *volume.LinodeID); err != nil {
+				return "", err
+			}
+		}
 		return "", errVolumeAttached(volumeID, instance.ID)
 	}
 
@@ -722,7 +1078,7 @@ func (cs *ControllerServer) getInstance(ctx context.Context, linodeID int) (*lin
 		defer span.End()
 	}
 
-	instance, err := cs.client.GetInstance(ctx, linodeID)
+	instance, err := cs.linodeClient(ctx).GetInstance(ctx, linodeID)
 	if linodego.IsNotFound(err) {
 		return nil, errInstanceNotFound(linodeID)
 	} else if err != nil {
@@ -737,8 +1093,8 @@ func (cs *ControllerServer) getInstance(ctx context.Context, linodeID int) (*lin
 // checkAttachmentCapacity checks if the specified instance can accommodate
 // additional volume attachments. It retrieves the maximum number of allowed
 // attachments and compares it with the currently attached volumes. If the
-// limit is exceeded, it returns an error indicating the maximum volume
-// attachments allowed.
+// limit is reached, it returns a ResourceExhausted error with the limit, the
+// number of attached volumes and the type of the instance.
 func (cs *ControllerServer) checkAttachmentCapacity(ctx context.Context, instance *linodego.Instance) error {
 	log := logger.GetLogger(ctx)
 	log.V(4).Info("Entering checkAttachmentCapacity()", "linodeID", instance.ID)
@@ -748,30 +1104,249 @@ func (cs *ControllerServer) checkAttachmentCapacity(ctx context.Context, instanc
 		defer span.End()
 	}
 
-	canAttach, err := cs.canAttach(ctx, instance)
-	if err != nil {
+	limit, attached, err := cs.attachmentCapacity(ctx, instance)
+	if errors.Is(err, errNilInstance) {
+		return errInternal("cannot calculate max volume attachments for a nil instance")
+	} else if err != nil {
 		return err
 	}
-	if !canAttach {
-		// If the instance cannot accommodate more attachments, retrieve the maximum allowed attachments.
-		limit, err := cs.maxAllowedVolumeAttachments(ctx, instance)
-		if errors.Is(err, errNilInstance) {
-			return errInternal("cannot calculate max volume attachments for a nil instance")
-		} else if err != nil {
-			return errMaxAttachments // Return an error indicating the maximum attachments limit has been reached.
-		}
-		return errMaxVolumeAttachments(limit) // Return an error indicating the maximum volume attachments allowed.
+	if attached >= limit {
+		log.V(2).Info("Instance cannot accommodate more volumes", "linodeID", instance.ID, "type", instance.Type, "limit", limit, "attached", attached)
+		return errMaxVolumeAttachments(instance, limit, attached)
 	}
 	return nil // Return nil if the instance can accommodate more attachments.
 }
 
+// volumeConditionActions are the actions of the Linode events reported in
+// the condition of volumes by [ControllerServer.ControllerGetVolume].
+var volumeConditionActions = []linodego.EventAction{
+	linodego.ActionVolumeAttach,
+	linodego.ActionVolumeDetach,
+	linodego.ActionVolumeResize,
+	linodego.ActionVolumeClone,
+	linodego.ActionVolumeCreate,
+}
+
+// volumeCondition returns the condition of vol, described by its latest
+// attach, detach, resize, clone or create event. Failing to list events is
+// not an error; the condition then only reports the status of the volume.
+func (cs *ControllerServer) volumeCondition(ctx context.Context, vol *linodego.Volume) *csi.VolumeCondition {
+	log := logger.GetLogger(ctx)
+	log.V(4).Info("Entering volumeCondition()", "volume_id", vol.ID)
+	defer log.V(4).Info("Exiting volumeCondition()")
+
+	event, err := cs.latestVolumeEvent(ctx, vol.ID)
+	if err != nil {
+		log.Error(err, "Failed to get the latest volume event", "volume_id", vol.ID)
+	}
+	return volumeEventCondition(vol, event)
+}
+
+// volumeEventCondition returns the condition of vol, described by event if it
+// is not nil. The volume is abnormal if it is not active, or if event failed.
+func volumeEventCondition(vol *linodego.Volume, event *linodego.Event) *csi.VolumeCondition {
+	condition := &csi.VolumeCondition{
+		Abnormal: vol.Status != linodego.VolumeActive,
+		Message:  fmt.Sprintf("volume is %s", vol.Status),
+	}
+	if event == nil {
+		return condition
+	}
+
+	condition.Message += fmt.Sprintf("; last event: %s %s", event.Action, event.Status)
+	if event.Created != nil {
+		condition.Message += " at " + event.Created.UTC().Format(time.RFC3339)
+	}
+	if event.SecondaryEntity != nil && event.SecondaryEntity.Label != "" {
+		condition.Message += fmt.Sprintf(" (%s %s)", event.SecondaryEntity.Type, event.SecondaryEntity.Label)
+	}
+	if event.Message != "" {
+		condition.Message += ": " + event.Message
+	}
+	if event.Status == linodego.EventFailed {
+		condition.Abnormal = true
+	}
+	return condition
+}
+
+// latestVolumeEvent returns the most recent event of the volume with
+// volumeID whose action is one of [volumeConditionActions], or nil if there
+// is none. The events are only listed if none was fed by the account events.
+func (cs *ControllerServer) latestVolumeEvent(ctx context.Context, volumeID int) (*linodego.Event, error) {
+	if cs.events != nil {
+		if event := cs.events.latestEvent(volumeID); event != nil {
+			return event, nil
+		}
+	}
+	events, err := cs.listVolumeEvents(ctx, map[string]any{"entity.id": volumeID}, minListPageSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, nil
+	}
+	return &events[0], nil
+}
+
+// latestVolumeEvents returns the most recent event of each volume whose
+// action is one of [volumeConditionActions], by volume ID. To bound the cost
+// of ListVolumes, only the last maxListPageSize such events of the account
+// are listed, in a single request: volumes without a recent event are
+// missing.
+func (cs *ControllerServer) latestVolumeEvents(ctx context.Context) (map[int]*linodego.Event, error) {
+	events, err := cs.listVolumeEvents(ctx, nil, maxListPageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	latest := make(map[int]*linodego.Event)
+	for i := range events {
+		volumeID, ok := eventVolumeID(&events[i])
+		if !ok {
+			continue
+		}
+		if _, ok := latest[volumeID]; !ok {
+			latest[volumeID] = &events[i]
+		}
+	}
+	return latest, nil
+}
+
+// eventVolumeID returns the ID of the entity of event, a volume event.
+func eventVolumeID(event *linodego.Event) (int, bool) {
+	if event.Entity == nil {
+		return 0, false
+	}
+	// The ID of the entity is decoded from JSON as a number.
+	switch id := event.Entity.ID.(type) {
+	case float64:
+		return int(id), true
+	case int:
+		return id, true
+	default:
+		return 0, false
+	}
+}
+
+// listVolumeEvents returns the first page of pageSize volume events whose
+// action is one of [volumeConditionActions] and matching the conditions in
+// filter, most recent first.
+func (cs *ControllerServer) listVolumeEvents(ctx context.Context, filter map[string]any, pageSize int) ([]linodego.Event, error) {
+	anyAction := make([]map[string]any, 0, len(volumeConditionActions))
+	for _, action := range volumeConditionActions {
+		anyAction = append(anyAction, map[string]any{"action": action})
+	}
+	conditions := map[string]any{
+		"entity.type": linodego.EntityVolume,
+		"+or":         anyAction,
+		"+order_by":   "created",
+		"+order":      "desc",
+	}
+	for key, value := range filter {
+		conditions[key] = value
+	}
+	jsonFilter, err := json.Marshal(conditions)
+	if err != nil {
+		return nil, fmt.Errorf("marshal json filter: %w", err)
+	}
+
+	events, err := cs.linodeClient(ctx).ListEvents(ctx, &linodego.ListOptions{
+		PageOptions: &linodego.PageOptions{Page: 1},
+		PageSize:    pageSize,
+		Filter:      string(jsonFilter),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list events: %w", err)
+	}
+	return events, nil
+}
+
+// volumeIDFromRequest returns the Linode volume ID for the volume ID of a CSI
+// request, like [linodevolumes.VolumeIdAsInt]. Volume IDs that are not
+// volume keys are hashed into a Linode volume ID, which is logged and
+// counted, or refused when [Options.RejectLegacyVolumeIDs] is enabled.
+func (cs *ControllerServer) volumeIDFromRequest(ctx context.Context, caller string, req interface{ GetVolumeId() string }) (int, error) {
+	volumeID, err := linodevolumes.VolumeIdAsInt(caller, req)
+	if err != nil {
+		return 0, err
+	}
+
+	handle := req.GetVolumeId()
+	if _, err := linodevolumes.ParseLinodeVolumeKey(handle); err == nil {
+		return volumeID, nil
+	}
+
+	observability.LegacyVolumeIDTotal.WithLabelValues(caller).Inc()
+	if cs.driver != nil && cs.driver.opts.RejectLegacyVolumeIDs {
+		if err := cs.driver.enforce(ctx, validationLegacyVolumeID, errLegacyVolumeID(handle), "method", caller); err != nil {
+			return 0, err
+		}
+	}
+	logger.GetLogger(ctx).V(0).Info("Volume ID is not a volume key, using its hash as the Linode volume ID",
+		"volume_id", handle,
+		"hashed_volume_id", volumeID,
+	)
+	return volumeID, nil
+}
+
+// attachConfigID returns the ID of the configuration profile of instance that
+// volumes are attached to, read from the [AttachConfigAnnotation] of the
+// Kubernetes node of the instance, found with [Options.NodeLookup] if set, or
+// else by the name of the instance. It returns 0, to attach volumes
+// to the instance's current configuration profile, if
+// [Options.AttachConfigFromNodeAnnotation] is disabled, or if the node or
+// its annotation do not exist.
+func (cs *ControllerServer) attachConfigID(ctx context.Context, instance *linodego.Instance) (int, error) {
+	log := logger.GetLogger(ctx)
+	log.V(4).Info("Entering attachConfigID()", "node_id", instance.ID)
+	defer log.V(4).Info("Exiting attachConfigID()")
+
+	if cs.driver == nil || !cs.driver.opts.AttachConfigFromNodeAnnotation {
+		return 0, nil
+	}
+
+	var annotations map[string]string
+	var err error
+	switch {
+	case cs.driver.opts.NodeLookup != nil:
+		var node kubeclient.Node
+		node, err = cs.driver.opts.NodeLookup.LookupNode(ctx, instance.ID, instance.Label)
+		annotations = node.Annotations
+		if errors.Is(err, kubeclient.ErrNotSynced) && cs.driver.opts.KubeClient != nil {
+			// The nodes could not be listed yet, get the node by name
+			log.V(4).Info("Nodes not listed yet, getting the node by name", "node_id", instance.ID, "node", instance.Label)
+			annotations, err = cs.driver.opts.KubeClient.GetNodeAnnotations(ctx, instance.Label)
+		}
+	case cs.driver.opts.KubeClient != nil:
+		annotations, err = cs.driver.opts.KubeClient.GetNodeAnnotations(ctx, instance.Label)
+	default:
+		return 0, nil
+	}
+	if errors.Is(err, kubeclient.ErrNotFound) {
+		log.V(4).Info("No Kubernetes node found for instance", "node_id", instance.ID, "node", instance.Label)
+		return 0, nil
+	} else if err != nil {
+		return 0, errInternal("get node %s: %v", instance.Label, err)
+	}
+
+	value, ok := annotations[AttachConfigAnnotation]
+	if !ok {
+		return 0, nil
+	}
+	configID, err := strconv.Atoi(value)
+	if err != nil || configID <= 0 {
+		return 0, errInvalidAttachConfig(instance.Label, value)
+	}
+	return configID, nil
+}
+
 // attachVolume attaches the specified volume to the given Linode instance.
 // It logs the action and handles any errors that may occur during the
 // attachment process. If the volume is already attached, it allows for a
 // retry by returning an Unavailable error.
-func (cs *ControllerServer) attachVolume(ctx context.Context, volumeID, linodeID int) error {
+func (cs *ControllerServer) attachVolume(ctx context.Context, volumeID, linodeID, configID int) error {
 	log := logger.GetLogger(ctx)
-	log.V(4).Info("Entering attachVolume()", "volume_id", volumeID, "node_id", linodeID)
+	log.V(4).Info("Entering attachVolume()", "volume_id", volumeID, "node_id", linodeID, "config_id", configID)
 	defer log.V(4).Info("Exiting attachVolume()")
 	if !observability.SkipObservability {
 		_, span := observability.StartFunctionSpan(ctx)
@@ -779,8 +1354,9 @@ func (cs *ControllerServer) attachVolume(ctx context.Context, volumeID, linodeID
 	}
 
 	persist := false
-	_, err := cs.client.AttachVolume(ctx, volumeID, &linodego.VolumeAttachOptions{
+	_, err := cs.linodeClient(ctx).AttachVolume(ctx, volumeID, &linodego.VolumeAttachOptions{
 		LinodeID:           linodeID,
+		ConfigID:           configID,
 		PersistAcrossBoots: &persist,
 	})
 	if err != nil {
@@ -794,3 +1370,97 @@ func (cs *ControllerServer) attachVolume(ctx context.Context, volumeID, linodeID
 	}
 	return nil // Return nil if the volume is successfully attached.
 }
+
+const (
+	// minListPageSize and maxListPageSize are the smallest and largest page
+	// sizes accepted by the Linode API.
+	minListPageSize = 25
+	maxListPageSize = 500
+)
+
+// maxListVolumesResponseBytes bounds the size of the ListVolumes responses,
+// below the 4 MiB messages accepted by default by gRPC clients. Listings
+// larger than it, e.g. of all the volumes of large accounts, are continued
+// with the next token of the response.
+const maxListVolumesResponseBytes = 3 << 20
+
+// listVolumesFilter returns the Linode API filter restricting ListVolumes to
+// the regions and tag set with [Options.ListVolumesRegions] and
+// [Options.ListVolumesTag]. It returns an empty filter if neither is set.
+func (cs *ControllerServer) listVolumesFilter() (string, error) {
+	if cs.driver == nil {
+		return "", nil
+	}
+
+	var conditions []map[string]any
+	switch regions := cs.driver.opts.ListVolumesRegions; len(regions) {
+	case 0:
+	case 1:
+		conditions = append(conditions, map[string]any{"region": regions[0]})
+	default:
+		anyRegion := make([]map[string]any, 0, len(regions))
+		for _, region := range regions {
+			anyRegion = append(anyRegion, map[string]any{"region": region})
+		}
+		conditions = append(conditions, map[string]any{"+or": anyRegion})
+	}
+	if tag := cs.driver.opts.ListVolumesTag; tag != "" {
+		conditions = append(conditions, map[string]any{"tags": tag})
+	}
+
+	var filter map[string]any
+	switch len(conditions) {
+	case 0:
+		return "", nil
+	case 1:
+		filter = conditions[0]
+	default:
+		filter = map[string]any{"+and": conditions}
+	}
+
+	jsonFilter, err := json.Marshal(filter)
+	if err != nil {
+		return "", fmt.Errorf("marshal json filter: %w", err)
+	}
+	return string(jsonFilter), nil
+}
+
+// listVolumes lists the volumes matching filter page by page, skipping the
+// first offset ones, and passes them to add until it returns false. At most
+// limit volumes are added, so that only one page of volumes is held at a
+// time. more reports whether there are volumes past the added ones.
+func (cs *ControllerServer) listVolumes(ctx context.Context, filter string, offset, limit int, add func(*linodego.Volume) bool) (added int, more bool, err error) {
+	log := logger.GetLogger(ctx)
+	log.V(4).Info("Entering listVolumes()", "filter", filter, "offset", offset, "limit", limit)
+	defer log.V(4).Info("Exiting listVolumes()")
+
+	// The Linode API only returns whole pages, of at least minListPageSize
+	// volumes: fetch the pages holding the requested volumes and trim them.
+	pageSize := min(max(limit, minListPageSize), maxListPageSize)
+	page := offset/pageSize + 1
+	skip := offset % pageSize
+	for {
+		listOpts := linodego.NewListOptions(page, filter)
+		listOpts.PageSize = pageSize
+
+		log.V(4).Info("Listing volumes", "list_opts", listOpts)
+		pageVolumes, err := cs.linodeClient(ctx).ListVolumes(ctx, listOpts)
+		if err != nil {
+			return added, false, err
+		}
+		for i := skip; i < len(pageVolumes); i++ {
+			if added == limit || !add(&pageVolumes[i]) {
+				return added, true, nil
+			}
+			added++
+		}
+		skip = 0
+
+		// The client sets the number of pages after listing one. If it
+		// does not, keep listing until a page is not full.
+		if len(pageVolumes) < pageSize || (listOpts.Pages > 0 && page >= listOpts.Pages) {
+			return added, false, nil
+		}
+		page++
+	}
+}
@@ -4,25 +4,31 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/linode/linodego"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"go.uber.org/mock/gomock"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
 	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+	kubeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/kube-client"
+	linodeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-client"
 	linodevolumes "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-volumes"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
 )
 
 func TestPrepareCreateVolumeResponse(t *testing.T) {
 	testCases := []struct {
 		name          string
 		vol           *linodego.Volume
-		size          int64
 		context       map[string]string
 		sourceInfo    *linodevolumes.LinodeVolumeKey
 		contentSource *csi.VolumeContentSource
@@ -33,9 +39,9 @@ func TestPrepareCreateVolumeResponse(t *testing.T) {
 			vol: &linodego.Volume{
 				ID:     123,
 				Label:  "testvolume",
+				Size:   10,
 				Region: "us-east",
 			},
-			size:    10 << 30, // 10 GiB
 			context: map[string]string{"key": "value"},
 			expected: &csi.CreateVolumeResponse{
 				Volume: &csi.Volume{
@@ -57,9 +63,9 @@ func TestPrepareCreateVolumeResponse(t *testing.T) {
 			vol: &linodego.Volume{
 				ID:     456,
 				Label:  "clonedvolume",
+				Size:   20,
 				Region: "us-west",
 			},
-			size:    20 << 30, // 20 GiB
 			context: map[string]string{"cloned": "true"},
 			sourceInfo: &linodevolumes.LinodeVolumeKey{
 				VolumeID: 789,
@@ -99,9 +105,9 @@ func TestPrepareCreateVolumeResponse(t *testing.T) {
 			vol: &linodego.Volume{
 				ID:     789,
 				Label:  "emptycontextvolume",
+				Size:   5,
 				Region: "eu-west",
 			},
-			size:    5 << 30, // 5 GiB
 			context: map[string]string{},
 			expected: &csi.CreateVolumeResponse{
 				Volume: &csi.Volume{
@@ -125,7 +131,7 @@ func TestPrepareCreateVolumeResponse(t *testing.T) {
 			cs := &ControllerServer{}
 			ctx := context.Background()
 
-			result := cs.prepareCreateVolumeResponse(ctx, tc.vol, tc.size, tc.context, tc.sourceInfo, tc.contentSource)
+			result := cs.prepareCreateVolumeResponse(ctx, tc.vol, tc.context, tc.sourceInfo, tc.contentSource)
 
 			if !reflect.DeepEqual(result, tc.expected) {
 				t.Errorf("Expected %+v, but got %+v", tc.expected, result)
@@ -136,6 +142,7 @@ func TestPrepareCreateVolumeResponse(t *testing.T) {
 
 func TestCreateVolumeContext(t *testing.T) {
 	vol := &linodego.Volume{
+		Size:   10,
 		Region: "us-east",
 	}
 	tests := []struct {
@@ -171,6 +178,30 @@ func TestCreateVolumeContext(t *testing.T) {
 				VolumeTopologyRegion:   "us-east",
 			},
 		},
+		{
+			name: "Encrypted clone",
+			req: &csi.CreateVolumeRequest{
+				Name: "encrypted-clone",
+				Parameters: map[string]string{
+					LuksEncryptedAttribute: "true",
+					LuksCipherAttribute:    "aes-xts-plain64",
+					LuksKeySizeAttribute:   "512",
+					PVNameParameter:        "pv-clone",
+				},
+				VolumeContentSource: &csi.VolumeContentSource{
+					Type: &csi.VolumeContentSource_Volume{Volume: &csi.VolumeContentSource_VolumeSource{VolumeId: "1001-source"}},
+				},
+			},
+			expectedResult: map[string]string{
+				LuksEncryptedAttribute:  "true",
+				PublishInfoVolumeName:   "encrypted-clone",
+				LuksCipherAttribute:     "aes-xts-plain64",
+				LuksKeySizeAttribute:    "512",
+				LuksClonedFromAttribute: "1001",
+				PVNameParameter:         "pv-clone",
+				VolumeTopologyRegion:    "us-east",
+			},
+		},
 		// IMPORTANT:Now sure if we want this behavior, but it's what the code currently does.
 		{
 			name: "Encrypted volume with missing cipher and key size",
@@ -188,6 +219,46 @@ func TestCreateVolumeContext(t *testing.T) {
 				VolumeTopologyRegion:   "us-east",
 			},
 		},
+		{
+			name: "Volume with a project quota",
+			req: &csi.CreateVolumeRequest{
+				Name:          "quota-volume",
+				CapacityRange: &csi.CapacityRange{RequiredBytes: 1 << 30},
+				Parameters: map[string]string{
+					ProjectQuotaAttribute: True,
+				},
+			},
+			expectedResult: map[string]string{
+				ProjectQuotaAttribute:      True,
+				ProjectQuotaLimitAttribute: "1073741824",
+				RequestedBytesAttribute:    "1073741824",
+				VolumeTopologyRegion:       "us-east",
+			},
+		},
+		{
+			name: "Volume partitioned automatically",
+			req: &csi.CreateVolumeRequest{
+				Name: "partitioned-volume",
+				Parameters: map[string]string{
+					PartitionAttribute: PartitionAuto,
+				},
+			},
+			expectedResult: map[string]string{
+				PartitionAttribute:   PartitionAuto,
+				VolumeTopologyRegion: "us-east",
+			},
+		},
+		{
+			name: "Volume of the requested size",
+			req: &csi.CreateVolumeRequest{
+				Name:          "exact-volume",
+				CapacityRange: &csi.CapacityRange{RequiredBytes: 10 << 30},
+				Parameters:    map[string]string{},
+			},
+			expectedResult: map[string]string{
+				VolumeTopologyRegion: "us-east",
+			},
+		},
 		{
 			name: "Non-encrypted volume with cipher and key size (should be ignored)",
 			req: &csi.CreateVolumeRequest{
@@ -264,6 +335,56 @@ func TestCreateVolumeContext_Encryption(t *testing.T) {
 	}
 }
 
+// BenchmarkCreateVolumeContext builds the volume context of an encrypted
+// volume with project quotas and tuning, for its claim.
+func BenchmarkCreateVolumeContext(b *testing.B) {
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	vol := &linodego.Volume{ID: 1001, Region: "us-east", Created: &created}
+	req := &csi.CreateVolumeRequest{
+		Name:          "pvc-0b5a1b8e-6c1d-4b58-9a7d-1f2e3d4c5b6a",
+		CapacityRange: &csi.CapacityRange{RequiredBytes: 5 << 30},
+		Parameters: map[string]string{
+			LuksEncryptedAttribute:  True,
+			LuksCipherAttribute:     "aes-xts-plain64",
+			LuksKeySizeAttribute:    "512",
+			FilesystemTypeAttribute: "ext4",
+			ProjectQuotaAttribute:   True,
+			ReadAheadKBAttribute:    "16",
+			PVCNameParameter:        "data",
+			PVCNamespaceParameter:   "default",
+		},
+	}
+	cs := &ControllerServer{}
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	for range b.N {
+		cs.createVolumeContext(ctx, req, vol)
+	}
+}
+
+// BenchmarkValidVolumeCapabilities validates the capabilities of a file
+// system and a block volume.
+func BenchmarkValidVolumeCapabilities(b *testing.B) {
+	caps := []*csi.VolumeCapability{
+		{
+			AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{FsType: "ext4"}},
+			AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+		},
+		{
+			AccessType: &csi.VolumeCapability_Block{Block: &csi.VolumeCapability_BlockVolume{}},
+			AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+		},
+	}
+
+	b.ReportAllocs()
+	for range b.N {
+		if !validVolumeCapabilities(caps) {
+			b.Fatal("validVolumeCapabilities() = false")
+		}
+	}
+}
+
 func TestCreateAndWaitForVolume(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -340,12 +461,28 @@ func TestCreateAndWaitForVolume(t *testing.T) {
 			sourceInfo: &linodevolumes.LinodeVolumeKey{VolumeID: 789},
 			setupMocks: func() {
 				mockClient.EXPECT().ListVolumes(gomock.Any(), gomock.Any()).Return(nil, nil)
-				mockClient.EXPECT().CloneVolume(gomock.Any(), gomock.Any(), gomock.Any()).Return(&linodego.Volume{ID: 789, Size: 40, Status: linodego.VolumeActive}, nil)
-				mockClient.EXPECT().WaitForVolumeStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(&linodego.Volume{ID: 789, Size: 40, Status: linodego.VolumeActive}, nil)
+				mockClient.EXPECT().CloneVolume(gomock.Any(), gomock.Any(), gomock.Any()).Return(&linodego.Volume{ID: 789, Size: 40, Status: linodego.VolumeCreating, Tags: []string{"tag1"}}, nil)
+				mockClient.EXPECT().UpdateVolume(gomock.Any(), 789, linodego.VolumeUpdateOptions{Tags: &[]string{"tag1", "csi-clone-of:789"}}).Return(&linodego.Volume{}, nil)
+				mockClient.EXPECT().WaitForVolumeStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(&linodego.Volume{ID: 789, Size: 40, Status: linodego.VolumeActive, Tags: []string{"tag1", "csi-clone-of:789"}}, nil)
+				mockClient.EXPECT().UpdateVolume(gomock.Any(), 789, linodego.VolumeUpdateOptions{Tags: &[]string{"tag1"}}).Return(&linodego.Volume{}, nil)
 			},
-			expectedVolume: &linodego.Volume{ID: 789, Size: 40, Status: linodego.VolumeActive},
+			expectedVolume: &linodego.Volume{ID: 789, Size: 40, Status: linodego.VolumeActive, Tags: []string{"tag1"}},
 			expectedError:  nil,
 		},
+		{
+			name:       "Reserved volume tag",
+			volumeName: "reserved-volume",
+			sizeGB:     20,
+			parameters: map[string]string{
+				VolumeTags: "tag1,csi-clone-of:1",
+			},
+			sourceInfo: nil,
+			setupMocks: func() {
+				mockClient.EXPECT().ListVolumes(gomock.Any(), gomock.Any()).Return(nil, nil)
+			},
+			expectedVolume: nil,
+			expectedError:  errReservedVolumeTag("csi-clone-of:1"),
+		},
 		{
 			name:       "Volume creation timeout",
 			volumeName: "timeout-volume",
@@ -454,7 +591,7 @@ func TestPrepareVolumeParams(t *testing.T) {
 			expectedName:   "",
 			expectedSizeGB: 0,
 			expectedSize:   0,
-			expectedError:  errors.New("RequiredBytes and LimitBytes must not be negative"),
+			expectedError:  errInvalidCapacityRange("RequiredBytes and LimitBytes must not be negative"),
 		},
 	}
 
@@ -497,6 +634,133 @@ func TestPrepareVolumeParams(t *testing.T) {
 	}
 }
 
+func TestPrepareVolumeParams_AllowedRegions(t *testing.T) {
+	cs := &ControllerServer{
+		driver: &LinodeDriver{
+			volumeLabelPrefix: "csi-linode-pv-",
+			opts:              Options{AllowedRegions: []string{"us-east", "us-ord"}},
+		},
+		metadata: Metadata{Region: "us-east"},
+	}
+	topology := func(region string) *csi.TopologyRequirement {
+		return &csi.TopologyRequirement{Preferred: []*csi.Topology{{Segments: map[string]string{VolumeTopologyRegion: region}}}}
+	}
+
+	tests := []struct {
+		name         string
+		requirements *csi.TopologyRequirement
+		wantRegion   string
+		wantCode     codes.Code
+	}{
+		{name: "Region of the controller", wantRegion: "us-east"},
+		{name: "Allowed topology region", requirements: topology("us-ord"), wantRegion: "us-ord"},
+		{name: "Topology region not allowed", requirements: topology("eu-west"), wantCode: codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := cs.prepareVolumeParams(context.Background(), &csi.CreateVolumeRequest{
+				Name:                      "pvc",
+				AccessibilityRequirements: tt.requirements,
+			})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("prepareVolumeParams() error = %v, want code %v", err, tt.wantCode)
+			}
+			if err == nil && params.Region != tt.wantRegion {
+				t.Errorf("prepareVolumeParams() region = %q, want %q", params.Region, tt.wantRegion)
+			}
+		})
+	}
+
+	cs.metadata.Region = "eu-west"
+	if _, err := cs.prepareVolumeParams(context.Background(), &csi.CreateVolumeRequest{Name: "pvc"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("prepareVolumeParams() in a controller region not allowed error = %v, want code %v", err, codes.InvalidArgument)
+	}
+}
+
+func TestGetRequestCapacitySize(t *testing.T) {
+	const gib = 1 << 30
+	tests := []struct {
+		name     string
+		capRange *csi.CapacityRange
+		mode     EnforcementMode
+		want     int64
+		wantCode codes.Code
+	}{
+		{name: "No capacity range", want: MinVolumeSizeBytes},
+		{name: "Required below the minimum", capRange: &csi.CapacityRange{RequiredBytes: 5 * gib}, want: MinVolumeSizeBytes},
+		{name: "Required rounded up", capRange: &csi.CapacityRange{RequiredBytes: 20*gib + 1}, want: 21 * gib},
+		{name: "Limit", capRange: &csi.CapacityRange{LimitBytes: 20 * gib}, want: 20 * gib},
+		{name: "Limit rounded down", capRange: &csi.CapacityRange{RequiredBytes: 10 * gib, LimitBytes: 21*gib - 1}, want: 20 * gib},
+		{name: "Required equal to the limit", capRange: &csi.CapacityRange{RequiredBytes: 20 * gib, LimitBytes: 20 * gib}, want: 20 * gib},
+		{name: "Required equal to an unaligned limit", capRange: &csi.CapacityRange{RequiredBytes: 20*gib + 1, LimitBytes: 20*gib + 1}, wantCode: codes.OutOfRange},
+		{name: "Required above the limit", capRange: &csi.CapacityRange{RequiredBytes: 30 * gib, LimitBytes: 20 * gib}, wantCode: codes.OutOfRange},
+		{name: "Required above the limit in warn mode", capRange: &csi.CapacityRange{RequiredBytes: 30 * gib, LimitBytes: 20 * gib}, mode: EnforcementWarn, want: 30 * gib},
+		{name: "Limit below the minimum", capRange: &csi.CapacityRange{LimitBytes: 5 * gib}, wantCode: codes.OutOfRange},
+		{name: "Neither size", capRange: &csi.CapacityRange{}, wantCode: codes.InvalidArgument},
+		{name: "Negative limit", capRange: &csi.CapacityRange{LimitBytes: -1}, wantCode: codes.InvalidArgument},
+		{name: "Required too large to round up", capRange: &csi.CapacityRange{RequiredBytes: math.MaxInt64}, wantCode: codes.OutOfRange},
+		{name: "Largest limit", capRange: &csi.CapacityRange{LimitBytes: math.MaxInt64}, want: math.MaxInt64 &^ (gib - 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			linodeDriver := &LinodeDriver{opts: Options{EnforcementMode: tt.mode}}
+			size, err := getRequestCapacitySize(context.Background(), linodeDriver, tt.capRange)
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("getRequestCapacitySize() error = %v, want code %v", err, tt.wantCode)
+			}
+			if size != tt.want {
+				t.Errorf("getRequestCapacitySize() = %d, want %d", size, tt.want)
+			}
+		})
+	}
+}
+
+func TestSizeRoundUp(t *testing.T) {
+	tests := []struct {
+		name           string
+		requiredBytes  int64
+		allocatedBytes int64
+		want           string
+	}{
+		{name: "No required size", allocatedBytes: 10 << 30, want: ""},
+		{name: "Requested size", requiredBytes: 20 << 30, allocatedBytes: 20 << 30, want: ""},
+		{name: "Rounded up to the minimum size", requiredBytes: 1 << 30, allocatedBytes: 10 << 30, want: sizeRoundUpMinimum},
+		{name: "Rounded up to a whole GiB", requiredBytes: 20<<30 + 1, allocatedBytes: 21 << 30, want: sizeRoundUpGiB},
+		{name: "Cloned from a larger volume", requiredBytes: 20 << 30, allocatedBytes: 40 << 30, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sizeRoundUp(tt.requiredBytes, tt.allocatedBytes); got != tt.want {
+				t.Errorf("sizeRoundUp(%d, %d) = %q, want %q", tt.requiredBytes, tt.allocatedBytes, got, tt.want)
+			}
+		})
+	}
+}
+
+func FuzzGetRequestCapacitySize(f *testing.F) {
+	f.Add(int64(0), int64(0))
+	f.Add(int64(5<<30), int64(0))
+	f.Add(int64(10<<30), int64(10<<30))
+	f.Add(int64(20<<30+1), int64(21<<30))
+	f.Add(int64(math.MaxInt64), int64(math.MaxInt64))
+	f.Add(int64(math.MinInt64), int64(-1))
+
+	f.Fuzz(func(t *testing.T, requiredBytes, limitBytes int64) {
+		size, err := getRequestCapacitySize(context.Background(), nil, &csi.CapacityRange{RequiredBytes: requiredBytes, LimitBytes: limitBytes})
+		if err != nil {
+			if code := status.Code(err); code != codes.InvalidArgument && code != codes.OutOfRange {
+				t.Fatalf("getRequestCapacitySize(%d, %d) error = %v, want code %v or %v", requiredBytes, limitBytes, err, codes.InvalidArgument, codes.OutOfRange)
+			}
+			return
+		}
+		if size < MinVolumeSizeBytes || size%(1<<30) != 0 || size < requiredBytes || (limitBytes > 0 && size > limitBytes) {
+			t.Fatalf("getRequestCapacitySize(%d, %d) = %d, not a whole number of GiB within the range", requiredBytes, limitBytes, size)
+		}
+	})
+}
+
 func TestPrepareVolumeParams_Encryption(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -600,6 +864,73 @@ func TestPrepareVolumeParams_Encryption(t *testing.T) {
 	}
 }
 
+func TestIsEncryptionSupported_Capabilities(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mocks.NewMockLinodeClient(ctrl)
+	cs := &ControllerServer{
+		client: mockClient,
+		driver: &LinodeDriver{
+			capabilities: &linodeclient.Capabilities{Regions: map[string][]string{
+				"us-east": {linodego.CapabilityBlockStorageEncryption},
+				"us-west": {linodego.CapabilityBlockStorage},
+			}},
+		},
+	}
+	ctx := context.Background()
+
+	// Only the region added since the capabilities were discovered is
+	// requested
+	mockClient.EXPECT().GetRegion(gomock.Any(), "eu-west").Return(&linodego.Region{
+		Capabilities: []string{linodego.CapabilityBlockStorageEncryption},
+	}, nil)
+
+	for region, want := range map[string]bool{"us-east": true, "us-west": false, "eu-west": true} {
+		supported, err := cs.isEncryptionSupported(ctx, region)
+		if err != nil || supported != want {
+			t.Errorf("isEncryptionSupported(%s) = %v, %v, want %v, nil", region, supported, err, want)
+		}
+	}
+}
+
+func TestPrepareVolumeParams_EncryptionSuggestedRegions(t *testing.T) {
+	cs := &ControllerServer{
+		driver: &LinodeDriver{
+			capabilities: &linodeclient.Capabilities{
+				Regions: map[string][]string{
+					"eu-central": {linodego.CapabilityBlockStorageEncryption},
+					"us-east":    {linodego.CapabilityBlockStorage},
+					"us-ord":     {linodego.CapabilityBlockStorageEncryption},
+				},
+				Countries: map[string]string{"eu-central": "de", "us-east": "us", "us-ord": "us"},
+			},
+		},
+		metadata: Metadata{Region: "us-east"},
+	}
+	req := &csi.CreateVolumeRequest{
+		Name:       "encrypted-volume",
+		Parameters: map[string]string{VolumeEncryption: True},
+	}
+
+	_, err := cs.prepareVolumeParams(context.Background(), req)
+	st := status.Convert(err)
+	if want := "Volume encryption is not supported in the us-east region, it is supported in: us-ord, eu-central"; st.Message() != want {
+		t.Errorf("prepareVolumeParams() error = %q, want %q", st.Message(), want)
+	}
+	details := st.Details()
+	if len(details) != 1 {
+		t.Fatalf("prepareVolumeParams() details = %v, want ErrorInfo", details)
+	}
+	info, ok := details[0].(*errdetails.ErrorInfo)
+	if !ok {
+		t.Fatalf("prepareVolumeParams() details = %v, want ErrorInfo", details)
+	}
+	if want := map[string]string{"region": "us-east", "supportedRegions": "us-ord,eu-central"}; !reflect.DeepEqual(info.GetMetadata(), want) {
+		t.Errorf("ErrorInfo metadata = %v, want %v", info.GetMetadata(), want)
+	}
+}
+
 func TestValidateCreateVolumeRequest(t *testing.T) {
 	cs := &ControllerServer{}
 	ctx := context.Background()
@@ -691,6 +1022,92 @@ func TestValidateCreateVolumeRequest(t *testing.T) {
 				},
 			}),
 		},
+		{
+			name: "Unsupported file system type",
+			req: &csi.CreateVolumeRequest{
+				Name: "test-volume",
+				VolumeCapabilities: []*csi.VolumeCapability{
+					{
+						AccessMode: &csi.VolumeCapability_AccessMode{
+							Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+						},
+					},
+				},
+				Parameters: map[string]string{
+					FilesystemTypeAttribute: "ntfs",
+				},
+			},
+			wantErr: errUnsupportedFSType("ntfs"),
+		},
+		{
+			name: "Project quota on unsupported file system type",
+			req: &csi.CreateVolumeRequest{
+				Name: "test-volume",
+				VolumeCapabilities: []*csi.VolumeCapability{
+					{
+						AccessMode: &csi.VolumeCapability_AccessMode{
+							Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+						},
+					},
+				},
+				Parameters: map[string]string{
+					FilesystemTypeAttribute: "xfs",
+					ProjectQuotaAttribute:   True,
+				},
+			},
+			wantErr: errUnsupportedProjectQuota("xfs"),
+		},
+		{
+			name: "Invalid read-ahead",
+			req: &csi.CreateVolumeRequest{
+				Name: "test-volume",
+				VolumeCapabilities: []*csi.VolumeCapability{
+					{
+						AccessMode: &csi.VolumeCapability_AccessMode{
+							Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+						},
+					},
+				},
+				Parameters: map[string]string{
+					ReadAheadKBAttribute: "auto",
+				},
+			},
+			wantErr: errInvalidReadAhead("auto"),
+		},
+		{
+			name: "Invalid partition",
+			req: &csi.CreateVolumeRequest{
+				Name: "test-volume",
+				VolumeCapabilities: []*csi.VolumeCapability{
+					{
+						AccessMode: &csi.VolumeCapability_AccessMode{
+							Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+						},
+					},
+				},
+				Parameters: map[string]string{
+					PartitionAttribute: "0",
+				},
+			},
+			wantErr: errInvalidPartition("0"),
+		},
+		{
+			name: "invalid I/O throttle",
+			req: &csi.CreateVolumeRequest{
+				Name: "test-volume",
+				VolumeCapabilities: []*csi.VolumeCapability{
+					{
+						AccessMode: &csi.VolumeCapability_AccessMode{
+							Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+						},
+					},
+				},
+				Parameters: map[string]string{
+					WriteIOPSAttribute: "0",
+				},
+			},
+			wantErr: errInvalidIOThrottle(WriteIOPSAttribute, "0"),
+		},
 	}
 
 	for _, tc := range testCases {
@@ -933,6 +1350,64 @@ func TestGetAndValidateVolume(t *testing.T) {
 	}
 }
 
+func TestGetAndValidateVolumeAsyncDetach(t *testing.T) {
+	tests := []struct {
+		name      string
+		event     *linodego.Event
+		wantError error
+	}{
+		{
+			name:      "Detach in progress",
+			event:     &linodego.Event{Action: linodego.ActionVolumeDetach, Status: linodego.EventStarted},
+			wantError: errDetachInProgress(123),
+		},
+		{
+			name:      "Detach failed",
+			event:     &linodego.Event{Action: linodego.ActionVolumeDetach, Status: linodego.EventFailed, Message: "linode is busy"},
+			wantError: errDetachFailed(123, 789, errors.New("linode is busy")),
+		},
+		{
+			name:      "Detach finished",
+			event:     &linodego.Event{Action: linodego.ActionVolumeDetach, Status: linodego.EventFinished},
+			wantError: errVolumeAttached(123, 456),
+		},
+		{
+			name:      "Attach",
+			event:     &linodego.Event{Action: linodego.ActionVolumeAttach, Status: linodego.EventFinished},
+			wantError: errVolumeAttached(123, 456),
+		},
+		{
+			name:      "No event",
+			wantError: errVolumeAttached(123, 456),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockClient := mocks.NewMockLinodeClient(ctrl)
+			mockClient.EXPECT().GetVolume(gomock.Any(), 123).Return(&linodego.Volume{ID: 123, LinodeID: &[]int{789}[0]}, nil)
+			var events []linodego.Event
+			if tt.event != nil {
+				events = append(events, *tt.event)
+			}
+			mockClient.EXPECT().ListEvents(gomock.Any(), gomock.Any()).Return(events, nil)
+
+			// The controller restarted, so it does not track the detach.
+			cs := &ControllerServer{
+				client: mockClient,
+				driver: &LinodeDriver{opts: Options{AsyncControllerUnpublish: true}},
+			}
+			_, err := cs.getAndValidateVolume(context.Background(), 123, &linodego.Instance{ID: 456})
+			if !reflect.DeepEqual(err, tt.wantError) {
+				t.Errorf("getAndValidateVolume() error = %v, want %v", err, tt.wantError)
+			}
+		})
+	}
+}
+
 func TestCheckAttachmentCapacity(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -974,7 +1449,7 @@ func TestCheckAttachmentCapacity(t *testing.T) {
 				mockClient.EXPECT().ListInstanceDisks(gomock.Any(), 456, gomock.Any()).Return([]linodego.InstanceDisk{{ID: 1}, {ID: 2}}, nil).AnyTimes()
 				mockClient.EXPECT().ListInstanceVolumes(gomock.Any(), 456, gomock.Any()).Return([]linodego.Volume{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}, {ID: 5}, {ID: 6}}, nil)
 			},
-			expectedError: errMaxVolumeAttachments(6),
+			expectedError: errMaxVolumeAttachments(&linodego.Instance{ID: 456, Specs: &linodego.InstanceSpec{Memory: 1024}}, 6, 6),
 		},
 	}
 
@@ -984,19 +1459,49 @@ func TestCheckAttachmentCapacity(t *testing.T) {
 
 			err := cs.checkAttachmentCapacity(context.Background(), tc.instance)
 
-			if err != nil && !reflect.DeepEqual(tc.expectedError, err) {
+			// The details of the errors are serialized, so only the codes
+			// and messages are compared
+			if status.Code(err) != status.Code(tc.expectedError) || status.Convert(err).Message() != status.Convert(tc.expectedError).Message() {
 				t.Errorf("expected error %v, got %v", tc.expectedError, err)
 			}
 		})
 	}
 }
 
+func TestCheckAttachmentCapacity_details(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mocks.NewMockLinodeClient(ctrl)
+	mockClient.EXPECT().ListInstanceDisks(gomock.Any(), 456, gomock.Any()).Return([]linodego.InstanceDisk{{ID: 1}}, nil)
+	mockClient.EXPECT().ListInstanceVolumes(gomock.Any(), 456, gomock.Any()).Return(make([]linodego.Volume, 7), nil)
+	cs := &ControllerServer{client: mockClient}
+
+	err := cs.checkAttachmentCapacity(context.Background(), &linodego.Instance{ID: 456, Type: "g6-nanode-1", Specs: &linodego.InstanceSpec{Memory: 1024}})
+	st := status.Convert(err)
+	if st.Code() != codes.ResourceExhausted {
+		t.Errorf("checkAttachmentCapacity() code = %v, want %v", st.Code(), codes.ResourceExhausted)
+	}
+	details := st.Details()
+	if len(details) != 1 {
+		t.Fatalf("checkAttachmentCapacity() details = %v, want ErrorInfo", details)
+	}
+	info, ok := details[0].(*errdetails.ErrorInfo)
+	if !ok {
+		t.Fatalf("checkAttachmentCapacity() details = %v, want ErrorInfo", details)
+	}
+	if want := map[string]string{"linodeID": "456", "instanceType": "g6-nanode-1", "limit": "7", "attached": "7"}; !reflect.DeepEqual(info.GetMetadata(), want) {
+		t.Errorf("ErrorInfo metadata = %v, want %v", info.GetMetadata(), want)
+	}
+}
+
 func TestGetContentSourceVolume(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockClient := mocks.NewMockLinodeClient(ctrl)
 	cs := &ControllerServer{
+		driver: &LinodeDriver{},
 		client: mockClient,
 		metadata: Metadata{
 			Region: "us-east",
@@ -1150,6 +1655,7 @@ func TestAttachVolume(t *testing.T) {
 		name          string
 		volumeID      int
 		linodeID      int
+		configID      int
 		setupMocks    func()
 		expectedError error
 	}{
@@ -1162,6 +1668,21 @@ func TestAttachVolume(t *testing.T) {
 			},
 			expectedError: nil,
 		},
+		{
+			name:     "Successful attachment to a configuration profile",
+			volumeID: 124,
+			linodeID: 456,
+			configID: 789,
+			setupMocks: func() {
+				persist := false
+				mockClient.EXPECT().AttachVolume(gomock.Any(), 124, &linodego.VolumeAttachOptions{
+					LinodeID:           456,
+					ConfigID:           789,
+					PersistAcrossBoots: &persist,
+				}).Return(&linodego.Volume{}, nil)
+			},
+			expectedError: nil,
+		},
 		{
 			name:     "Volume already attached",
 			volumeID: 789,
@@ -1186,7 +1707,7 @@ func TestAttachVolume(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			tc.setupMocks()
 
-			err := cs.attachVolume(context.Background(), tc.volumeID, tc.linodeID)
+			err := cs.attachVolume(context.Background(), tc.volumeID, tc.linodeID, tc.configID)
 
 			switch {
 			case tc.expectedError == nil && err != nil:
@@ -1202,6 +1723,269 @@ func TestAttachVolume(t *testing.T) {
 	}
 }
 
+func TestVolumeIDFromRequest(t *testing.T) {
+	testCases := []struct {
+		name          string
+		volumeID      string
+		reject        bool
+		mode          EnforcementMode
+		expectedID    int
+		expectedError error
+		expectedCount float64
+	}{
+		{
+			name:       "Volume key",
+			volumeID:   "1001-volume",
+			expectedID: 1001,
+		},
+		{
+			name:          "Legacy volume ID",
+			volumeID:      "1001",
+			expectedID:    597150807,
+			expectedCount: 1,
+		},
+		{
+			name:          "Legacy volume ID rejected",
+			volumeID:      "1001",
+			reject:        true,
+			expectedError: errLegacyVolumeID("1001"),
+			expectedCount: 1,
+		},
+		{
+			name:          "Legacy volume ID rejected in warn mode",
+			volumeID:      "1001",
+			reject:        true,
+			mode:          EnforcementWarn,
+			expectedID:    597150807,
+			expectedCount: 1,
+		},
+		{
+			name:          "Volume key with reject enabled",
+			volumeID:      "1001-volume",
+			reject:        true,
+			expectedID:    1001,
+			expectedCount: 0,
+		},
+		{
+			name:          "No volume ID",
+			expectedError: status.Error(codes.InvalidArgument, "DeleteVolume Volume ID must be provided"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cs := &ControllerServer{
+				driver: &LinodeDriver{opts: Options{RejectLegacyVolumeIDs: tc.reject, EnforcementMode: tc.mode}},
+			}
+
+			before := testutil.ToFloat64(observability.LegacyVolumeIDTotal.WithLabelValues("DeleteVolume"))
+			volumeID, err := cs.volumeIDFromRequest(context.Background(), "DeleteVolume", &csi.DeleteVolumeRequest{VolumeId: tc.volumeID})
+			if !reflect.DeepEqual(err, tc.expectedError) {
+				t.Errorf("expected error %v, got %v", tc.expectedError, err)
+			}
+			if volumeID != tc.expectedID {
+				t.Errorf("expected volume ID %d, got %d", tc.expectedID, volumeID)
+			}
+			after := testutil.ToFloat64(observability.LegacyVolumeIDTotal.WithLabelValues("DeleteVolume"))
+			if after-before != tc.expectedCount {
+				t.Errorf("expected %v legacy volume IDs to be counted, got %v", tc.expectedCount, after-before)
+			}
+		})
+	}
+}
+
+func TestCheckAccountVolumeLimit(t *testing.T) {
+	testCases := []struct {
+		name          string
+		limit         int
+		volumes       int
+		listErr       error
+		parameters    map[string]string
+		eventErr      error
+		expectedError error
+		expectedCount float64
+		expectedEvent bool
+	}{
+		{
+			name: "Limit not set",
+		},
+		{
+			name:    "Below the limit",
+			limit:   100,
+			volumes: 99,
+		},
+		{
+			name:          "Limit reached",
+			limit:         100,
+			volumes:       100,
+			expectedError: errAccountVolumeLimit(100, 100),
+			expectedCount: 1,
+		},
+		{
+			name:          "Limit reached with claim",
+			limit:         100,
+			volumes:       101,
+			parameters:    map[string]string{PVCNamespaceParameter: "default", PVCNameParameter: "data"},
+			expectedError: errAccountVolumeLimit(101, 100),
+			expectedCount: 1,
+			expectedEvent: true,
+		},
+		{
+			name:          "Event error",
+			limit:         100,
+			volumes:       100,
+			parameters:    map[string]string{PVCNamespaceParameter: "default", PVCNameParameter: "data"},
+			eventErr:      errors.New("forbidden"),
+			expectedError: errAccountVolumeLimit(100, 100),
+			expectedCount: 1,
+			expectedEvent: true,
+		},
+		{
+			name:          "List volumes error",
+			limit:         100,
+			listErr:       errors.New("API error"),
+			expectedError: errInternal("list volumes: API error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockClient := mocks.NewMockLinodeClient(ctrl)
+			if tc.limit > 0 {
+				mockClient.EXPECT().ListVolumes(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, opts *linodego.ListOptions) ([]linodego.Volume, error) {
+					opts.Results = tc.volumes
+					return nil, tc.listErr
+				})
+			}
+			mockKubeClient := mocks.NewMockKubeClient(ctrl)
+			if tc.expectedEvent {
+				mockKubeClient.EXPECT().CreatePersistentVolumeClaimEvent(gomock.Any(), "default", "data", "Warning", "AccountVolumeLimitExceeded", status.Convert(tc.expectedError).Message()).Return(tc.eventErr)
+			}
+			cs := &ControllerServer{
+				client: mockClient,
+				driver: &LinodeDriver{opts: Options{AccountVolumeLimit: tc.limit, KubeClient: mockKubeClient}},
+			}
+
+			before := testutil.ToFloat64(observability.AccountVolumeLimitExceededTotal)
+			err := cs.checkAccountVolumeLimit(context.Background(), tc.parameters)
+			if !reflect.DeepEqual(err, tc.expectedError) {
+				t.Errorf("expected error %v, got %v", tc.expectedError, err)
+			}
+			after := testutil.ToFloat64(observability.AccountVolumeLimitExceededTotal)
+			if after-before != tc.expectedCount {
+				t.Errorf("expected %v refused volumes to be counted, got %v", tc.expectedCount, after-before)
+			}
+		})
+	}
+}
+
+func TestAttachConfigID(t *testing.T) {
+	instance := &linodego.Instance{ID: 456, Label: "node-1"}
+
+	testCases := []struct {
+		name          string
+		disabled      bool
+		setupMocks    func(*mocks.MockKubeClient)
+		setupLookup   func(*mocks.MockNodeLookup)
+		expectedID    int
+		expectedError error
+	}{
+		{
+			name:     "Disabled",
+			disabled: true,
+		},
+		{
+			name: "Annotation set on cached node",
+			setupLookup: func(nl *mocks.MockNodeLookup) {
+				nl.EXPECT().LookupNode(gomock.Any(), 456, "node-1").Return(kubeclient.Node{Name: "node-a", LinodeID: 456, Annotations: map[string]string{AttachConfigAnnotation: "789"}}, nil)
+			},
+			expectedID: 789,
+		},
+		{
+			name: "Cached node not found",
+			setupLookup: func(nl *mocks.MockNodeLookup) {
+				nl.EXPECT().LookupNode(gomock.Any(), 456, "node-1").Return(kubeclient.Node{}, fmt.Errorf("node for linode 456 (node-1): %w", kubeclient.ErrNotFound))
+			},
+		},
+		{
+			name: "Node cache not synced",
+			setupLookup: func(nl *mocks.MockNodeLookup) {
+				nl.EXPECT().LookupNode(gomock.Any(), 456, "node-1").Return(kubeclient.Node{}, fmt.Errorf("node for linode 456 (node-1): %w", kubeclient.ErrNotSynced))
+			},
+			setupMocks: func(kc *mocks.MockKubeClient) {
+				kc.EXPECT().GetNodeAnnotations(gomock.Any(), "node-1").Return(map[string]string{AttachConfigAnnotation: "789"}, nil)
+			},
+			expectedID: 789,
+		},
+		{
+			name: "Annotation set",
+			setupMocks: func(kc *mocks.MockKubeClient) {
+				kc.EXPECT().GetNodeAnnotations(gomock.Any(), "node-1").Return(map[string]string{AttachConfigAnnotation: "789"}, nil)
+			},
+			expectedID: 789,
+		},
+		{
+			name: "Annotation not set",
+			setupMocks: func(kc *mocks.MockKubeClient) {
+				kc.EXPECT().GetNodeAnnotations(gomock.Any(), "node-1").Return(map[string]string{"other": "value"}, nil)
+			},
+		},
+		{
+			name: "Node not found",
+			setupMocks: func(kc *mocks.MockKubeClient) {
+				kc.EXPECT().GetNodeAnnotations(gomock.Any(), "node-1").Return(nil, fmt.Errorf("get node node-1: %w", kubeclient.ErrNotFound))
+			},
+		},
+		{
+			name: "Invalid annotation",
+			setupMocks: func(kc *mocks.MockKubeClient) {
+				kc.EXPECT().GetNodeAnnotations(gomock.Any(), "node-1").Return(map[string]string{AttachConfigAnnotation: "main"}, nil)
+			},
+			expectedError: errInvalidAttachConfig("node-1", "main"),
+		},
+		{
+			name: "Kubernetes API error",
+			setupMocks: func(kc *mocks.MockKubeClient) {
+				kc.EXPECT().GetNodeAnnotations(gomock.Any(), "node-1").Return(nil, errors.New("unexpected status 403 Forbidden"))
+			},
+			expectedError: errInternal("get node node-1: unexpected status 403 Forbidden"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			kubeClient := mocks.NewMockKubeClient(ctrl)
+			if tc.setupMocks != nil {
+				tc.setupMocks(kubeClient)
+			}
+			opts := Options{
+				AttachConfigFromNodeAnnotation: !tc.disabled,
+				KubeClient:                     kubeClient,
+			}
+			if tc.setupLookup != nil {
+				nodeLookup := mocks.NewMockNodeLookup(ctrl)
+				tc.setupLookup(nodeLookup)
+				opts.NodeLookup = nodeLookup
+			}
+			cs := &ControllerServer{driver: &LinodeDriver{opts: opts}}
+
+			configID, err := cs.attachConfigID(context.Background(), instance)
+			if !reflect.DeepEqual(err, tc.expectedError) {
+				t.Errorf("expected error %v, got %v", tc.expectedError, err)
+			}
+			if configID != tc.expectedID {
+				t.Errorf("expected config ID %d, got %d", tc.expectedID, configID)
+			}
+		})
+	}
+}
+
 func TestGetInstance(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -1276,10 +2060,21 @@ func TestGetInstance(t *testing.T) {
 }
 
 func Test_getRegionFromTopology(t *testing.T) {
+	topologies := func(regions ...string) []*csi.Topology {
+		var topologies []*csi.Topology
+		for _, region := range regions {
+			topologies = append(topologies, &csi.Topology{Segments: map[string]string{VolumeTopologyRegion: region}})
+		}
+		return topologies
+	}
+	// us-west does not support block storage
+	supported := func(region string) bool { return region != "us-west" }
+
 	tests := []struct {
 		name         string
 		requirements *csi.TopologyRequirement
 		want         string
+		wantCode     codes.Code
 	}{
 		{
 			name:         "Nil requirements",
@@ -1287,78 +2082,144 @@ func Test_getRegionFromTopology(t *testing.T) {
 			want:         "",
 		},
 		{
-			name: "Empty preferred",
+			name:         "Empty preferred",
+			requirements: &csi.TopologyRequirement{Preferred: []*csi.Topology{}},
+			want:         "",
+		},
+		{
+			name: "Preferred topology without region",
 			requirements: &csi.TopologyRequirement{
-				Preferred: []*csi.Topology{},
+				Preferred: []*csi.Topology{{Segments: map[string]string{"some-key": "some-value"}}},
 			},
 			want: "",
 		},
 		{
-			name: "Single preferred topology with region",
+			name:         "Single preferred topology",
+			requirements: &csi.TopologyRequirement{Preferred: topologies("us-east")},
+			want:         "us-east",
+		},
+		{
+			name:         "Preferred topologies in order",
+			requirements: &csi.TopologyRequirement{Preferred: topologies("us-east", "eu-west")},
+			want:         "us-east",
+		},
+		{
+			name:         "First preferred topology not supported",
+			requirements: &csi.TopologyRequirement{Preferred: topologies("us-west", "eu-west")},
+			want:         "eu-west",
+		},
+		{
+			name:         "Requisite only",
+			requirements: &csi.TopologyRequirement{Requisite: topologies("eu-west", "us-east")},
+			want:         "eu-west",
+		},
+		{
+			name: "Preferred topology also in requisite",
 			requirements: &csi.TopologyRequirement{
-				Preferred: []*csi.Topology{
-					{
-						Segments: map[string]string{
-							VolumeTopologyRegion: "us-east",
-						},
-					},
-				},
+				Requisite: topologies("eu-west", "us-east"),
+				Preferred: topologies("us-east", "eu-west"),
 			},
 			want: "us-east",
 		},
 		{
-			name: "Multiple preferred topologies",
+			name: "Preferred topology not in requisite",
 			requirements: &csi.TopologyRequirement{
-				Preferred: []*csi.Topology{
-					{
-						Segments: map[string]string{
-							VolumeTopologyRegion: "us-east",
-						},
-					},
-					{
-						Segments: map[string]string{
-							VolumeTopologyRegion: "us-west",
-						},
-					},
-				},
+				Requisite: topologies("eu-west"),
+				Preferred: topologies("us-east"),
 			},
-			want: "us-east",
+			want: "eu-west",
 		},
 		{
-			name: "Preferred topology without region",
+			name: "Preferred topology not supported, first requisite",
 			requirements: &csi.TopologyRequirement{
-				Preferred: []*csi.Topology{
-					{
-						Segments: map[string]string{
-							"some-key": "some-value",
-						},
-					},
-				},
+				Requisite: topologies("us-west", "eu-west"),
+				Preferred: topologies("us-west"),
 			},
-			want: "",
+			want: "eu-west",
 		},
 		{
-			name: "Empty preferred, non-empty requisite",
+			name: "No supported region",
 			requirements: &csi.TopologyRequirement{
-				Preferred: []*csi.Topology{},
-				Requisite: []*csi.Topology{
-					{
-						Segments: map[string]string{
-							VolumeTopologyRegion: "eu-west",
-						},
-					},
-				},
+				Requisite: topologies("us-west"),
+				Preferred: topologies("us-west"),
 			},
-			want: "eu-west",
+			wantCode: codes.InvalidArgument,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := getRegionFromTopology(tt.requirements)
+			got, err := getRegionFromTopology(tt.requirements, supported)
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("getRegionFromTopology() error = %v, want code %v", err, tt.wantCode)
+			}
 			if got != tt.want {
 				t.Errorf("getRegionFromTopology() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestSupportsBlockStorage(t *testing.T) {
+	cs := &ControllerServer{
+		driver: &LinodeDriver{
+			capabilities: &linodeclient.Capabilities{Regions: map[string][]string{
+				"us-east": {linodego.CapabilityBlockStorage},
+				"us-west": {linodego.CapabilityLinodes},
+			}},
+		},
+	}
+	// Regions added since the capabilities were discovered are assumed to
+	// support block storage
+	for region, want := range map[string]bool{"us-east": true, "us-west": false, "eu-west": true} {
+		if got := cs.supportsBlockStorage(region); got != want {
+			t.Errorf("supportsBlockStorage(%s) = %v, want %v", region, got, want)
+		}
+	}
+}
+
+func TestListVolumesFilter(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{
+			name: "No filter",
+			want: "",
+		},
+		{
+			name: "Single region",
+			opts: Options{ListVolumesRegions: []string{"us-east"}},
+			want: `{"region":"us-east"}`,
+		},
+		{
+			name: "Multiple regions",
+			opts: Options{ListVolumesRegions: []string{"us-east", "us-west"}},
+			want: `{"+or":[{"region":"us-east"},{"region":"us-west"}]}`,
+		},
+		{
+			name: "Tag",
+			opts: Options{ListVolumesTag: "cluster-a"},
+			want: `{"tags":"cluster-a"}`,
+		},
+		{
+			name: "Multiple regions and tag",
+			opts: Options{ListVolumesRegions: []string{"us-east", "us-west"}, ListVolumesTag: "cluster-a"},
+			want: `{"+and":[{"+or":[{"region":"us-east"},{"region":"us-west"}]},{"tags":"cluster-a"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := &ControllerServer{driver: &LinodeDriver{opts: tt.opts}}
+			got, err := cs.listVolumesFilter()
+			if err != nil {
+				t.Fatalf("listVolumesFilter() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("listVolumesFilter() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
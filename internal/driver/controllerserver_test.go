@@ -4,12 +4,20 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"reflect"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/linode/linodego"
 	"go.uber.org/mock/gomock"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/linode/linode-blockstorage-csi-driver/mocks"
 	linodeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-client"
@@ -182,6 +190,108 @@ func TestDeleteVolume(t *testing.T) {
 	}
 }
 
+func TestDeleteVolumeAttached(t *testing.T) {
+	attached := func() *linodego.Volume {
+		return &linodego.Volume{ID: 1001, LinodeID: createLinodeID(1003), LinodeLabel: "node-1", Status: linodego.VolumeActive}
+	}
+	detachStarted := []linodego.Event{{Action: linodego.ActionVolumeDetach, Status: linodego.EventStarted}}
+
+	tests := []struct {
+		name                    string
+		wait                    time.Duration
+		trackDetach             bool
+		expectLinodeClientCalls func(m *mocks.MockLinodeClient)
+		wantCode                codes.Code
+		wantMessage             string
+		wantDetaching           string
+	}{
+		{
+			name: "Attached",
+			wait: 30 * time.Second,
+			expectLinodeClientCalls: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(attached(), nil)
+				m.EXPECT().ListEvents(gomock.Any(), gomock.Any()).Return([]linodego.Event{{Action: linodego.ActionVolumeAttach, Status: linodego.EventFinished}}, nil)
+			},
+			wantCode:      codes.FailedPrecondition,
+			wantMessage:   "volume 1001 is in use: attached to linode 1003 (node-1)",
+			wantDetaching: "false",
+		},
+		{
+			name: "Detach in progress without wait",
+			expectLinodeClientCalls: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(attached(), nil)
+				m.EXPECT().ListEvents(gomock.Any(), gomock.Any()).Return(detachStarted, nil)
+			},
+			wantCode:      codes.FailedPrecondition,
+			wantMessage:   "volume 1001 is in use: attached to linode 1003 (node-1), detach in progress",
+			wantDetaching: "true",
+		},
+		{
+			name:        "Tracked detach finished in time",
+			wait:        30 * time.Second,
+			trackDetach: true,
+			expectLinodeClientCalls: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(attached(), nil)
+				m.EXPECT().WaitForVolumeLinodeID(gomock.Any(), 1001, nil, 30).Return(&linodego.Volume{ID: 1001, Status: linodego.VolumeActive}, nil)
+				m.EXPECT().DeleteVolume(gomock.Any(), 1001).Return(nil)
+			},
+			wantCode: codes.OK,
+		},
+		{
+			name: "Detach not finished in time",
+			wait: 10 * time.Second,
+			expectLinodeClientCalls: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(attached(), nil)
+				m.EXPECT().ListEvents(gomock.Any(), gomock.Any()).Return(detachStarted, nil)
+				m.EXPECT().WaitForVolumeLinodeID(gomock.Any(), 1001, nil, 10).Return(nil, context.DeadlineExceeded)
+			},
+			wantCode:      codes.FailedPrecondition,
+			wantMessage:   "volume 1001 is in use: attached to linode 1003 (node-1), detach in progress",
+			wantDetaching: "true",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockClient := mocks.NewMockLinodeClient(ctrl)
+			tt.expectLinodeClientCalls(mockClient)
+
+			s := &ControllerServer{
+				client: mockClient,
+				driver: &LinodeDriver{opts: Options{DeleteVolumeDetachWait: tt.wait}},
+			}
+			if tt.trackDetach {
+				s.detaches.start(1001, 1003)
+			}
+			_, err := s.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: "1001-vol"})
+			st := status.Convert(err)
+			if st.Code() != tt.wantCode {
+				t.Fatalf("DeleteVolume() error = %v, want code %v", err, tt.wantCode)
+			}
+			if tt.wantCode == codes.OK {
+				return
+			}
+			if st.Message() != tt.wantMessage {
+				t.Errorf("DeleteVolume() message = %q, want %q", st.Message(), tt.wantMessage)
+			}
+			var info *errdetails.ErrorInfo
+			for _, detail := range st.Details() {
+				if i, ok := detail.(*errdetails.ErrorInfo); ok {
+					info = i
+				}
+			}
+			if info == nil {
+				t.Fatal("DeleteVolume() error has no ErrorInfo")
+			}
+			want := map[string]string{"linodeID": "1003", "linodeLabel": "node-1", "detaching": tt.wantDetaching}
+			if !reflect.DeepEqual(info.GetMetadata(), want) {
+				t.Errorf("ErrorInfo metadata = %v, want %v", info.GetMetadata(), want)
+			}
+		})
+	}
+}
+
 func TestControllerPublishVolume(t *testing.T) {
 	tests := []struct {
 		name                    string
@@ -217,9 +327,36 @@ func TestControllerPublishVolume(t *testing.T) {
 				m.EXPECT().AttachVolume(gomock.Any(), 630706045, gomock.Any()).Return(&linodego.Volume{ID: 1001, LinodeID: createLinodeID(1003), Size: 10, Status: linodego.VolumeActive}, nil)
 				m.EXPECT().ListInstanceVolumes(gomock.Any(), 1003, gomock.Any()).Return([]linodego.Volume{{ID: 1001, LinodeID: createLinodeID(1003), Size: 10, Status: linodego.VolumeActive}}, nil)
 				m.EXPECT().ListInstanceDisks(gomock.Any(), 1003, gomock.Any()).Return([]linodego.InstanceDisk{}, nil)
+				m.EXPECT().ListEvents(gomock.Any(), gomock.Any()).Return(nil, nil)
 			},
 			expectedError: nil,
 		},
+		{
+			name: "publishtimeout",
+			req: &csi.ControllerPublishVolumeRequest{
+				VolumeId: "1003",
+				NodeId:   "1003",
+				VolumeCapability: &csi.VolumeCapability{
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+				},
+				VolumeContext: map[string]string{
+					VolumeTopologyRegion: "us-east",
+				},
+			},
+			expectLinodeClientCalls: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().GetInstance(gomock.Any(), gomock.Any()).Return(&linodego.Instance{ID: 1003, Specs: &linodego.InstanceSpec{Memory: 16 << 10}}, nil).Times(2)
+				m.EXPECT().GetVolume(gomock.Any(), gomock.Any()).Return(&linodego.Volume{ID: 1001, Size: 10, Status: linodego.VolumeActive}, nil).AnyTimes()
+				m.EXPECT().AttachVolume(gomock.Any(), 630706045, gomock.Any()).Return(&linodego.Volume{ID: 1001, Size: 10, Status: linodego.VolumeActive}, nil)
+				m.EXPECT().WaitForVolumeLinodeID(gomock.Any(), 630706045, gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("wait: %w", context.DeadlineExceeded))
+				m.EXPECT().ListInstanceVolumes(gomock.Any(), 1003, gomock.Any()).Return(nil, nil)
+				m.EXPECT().ListInstanceDisks(gomock.Any(), 1003, gomock.Any()).Return([]linodego.InstanceDisk{}, nil)
+				// Migrations of the instance, then diagnostics of the timeout
+				m.EXPECT().ListEvents(gomock.Any(), gomock.Any()).Return(nil, nil).Times(3)
+			},
+			expectedError: fmt.Errorf("wait: %w", context.DeadlineExceeded),
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -267,6 +404,31 @@ func TestControllerUnPublishVolume(t *testing.T) {
 			},
 			expectedError: nil,
 		},
+		{
+			name: "already detached",
+			req: &csi.ControllerUnpublishVolumeRequest{
+				VolumeId: "1003",
+				NodeId:   "1003",
+			},
+			resp: &csi.ControllerUnpublishVolumeResponse{},
+			expectLinodeClientCalls: func(m *mocks.MockLinodeClient) {
+				// Neither detached nor waited for
+				m.EXPECT().GetVolume(gomock.Any(), gomock.Any()).Return(&linodego.Volume{ID: 1001, Size: 10, Status: linodego.VolumeActive}, nil)
+			},
+			expectedError: nil,
+		},
+		{
+			name: "attached elsewhere",
+			req: &csi.ControllerUnpublishVolumeRequest{
+				VolumeId: "1003",
+				NodeId:   "1003",
+			},
+			resp: &csi.ControllerUnpublishVolumeResponse{},
+			expectLinodeClientCalls: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), gomock.Any()).Return(&linodego.Volume{ID: 1001, LinodeID: createLinodeID(1004), Size: 10, Status: linodego.VolumeActive}, nil)
+			},
+			expectedError: nil,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -292,7 +454,119 @@ func TestControllerUnPublishVolume(t *testing.T) {
 	}
 }
 
+func TestControllerUnpublishVolumeAsync(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockClient := mocks.NewMockLinodeClient(ctrl)
+
+	detached := make(chan struct{})
+	mockClient.EXPECT().GetVolume(gomock.Any(), 1003).Return(&linodego.Volume{ID: 1003, LinodeID: createLinodeID(1003), Size: 10, Status: linodego.VolumeActive}, nil)
+	mockClient.EXPECT().DetachVolume(gomock.Any(), 1003).Return(nil)
+	mockClient.EXPECT().WaitForVolumeLinodeID(gomock.Any(), 1003, nil, gomock.Any()).DoAndReturn(func(_ context.Context, _ int, _ *int, _ int) (*linodego.Volume, error) {
+		<-detached
+		return &linodego.Volume{ID: 1003, Size: 10, Status: linodego.VolumeActive}, nil
+	})
+
+	s := &ControllerServer{
+		client: mockClient,
+		driver: &LinodeDriver{opts: Options{AsyncControllerUnpublish: true}},
+	}
+
+	unpublishReq := &csi.ControllerUnpublishVolumeRequest{VolumeId: "1003-vol", NodeId: "1003"}
+	if _, err := s.ControllerUnpublishVolume(context.Background(), unpublishReq); err != nil {
+		t.Fatalf("ControllerUnpublishVolume error: %v", err)
+	}
+
+	// A retried unpublish must not detach the volume a second time.
+	if _, err := s.ControllerUnpublishVolume(context.Background(), unpublishReq); err != nil {
+		t.Fatalf("ControllerUnpublishVolume error: %v", err)
+	}
+
+	publishReq := &csi.ControllerPublishVolumeRequest{
+		VolumeId: "1003-vol",
+		NodeId:   "1004",
+		VolumeCapability: &csi.VolumeCapability{
+			AccessMode: &csi.VolumeCapability_AccessMode{
+				Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+			},
+		},
+	}
+	_, err := s.ControllerPublishVolume(context.Background(), publishReq)
+	if want := errDetachInProgress(1003); !reflect.DeepEqual(err, want) {
+		t.Errorf("ControllerPublishVolume error: %v, want %v", err, want)
+	}
+
+	close(detached)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := s.detaches.inProgress(1003); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("detach was not reconciled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestControllerUnpublishVolumeAsyncFailure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockClient := mocks.NewMockLinodeClient(ctrl)
+
+	detachErr := errors.New("timed out")
+	mockClient.EXPECT().GetVolume(gomock.Any(), 1003).Return(&linodego.Volume{ID: 1003, LinodeID: createLinodeID(1003), Size: 10, Status: linodego.VolumeActive}, nil).Times(2)
+	mockClient.EXPECT().DetachVolume(gomock.Any(), 1003).Return(nil).Times(2)
+	gomock.InOrder(
+		mockClient.EXPECT().WaitForVolumeLinodeID(gomock.Any(), 1003, nil, gomock.Any()).Return(nil, detachErr),
+		mockClient.EXPECT().WaitForVolumeLinodeID(gomock.Any(), 1003, nil, gomock.Any()).Return(&linodego.Volume{ID: 1003, Size: 10, Status: linodego.VolumeActive}, nil),
+	)
+
+	s := &ControllerServer{
+		client: mockClient,
+		driver: &LinodeDriver{opts: Options{AsyncControllerUnpublish: true}},
+	}
+
+	req := &csi.ControllerUnpublishVolumeRequest{VolumeId: "1003-vol", NodeId: "1003"}
+	if _, err := s.ControllerUnpublishVolume(context.Background(), req); err != nil {
+		t.Fatalf("ControllerUnpublishVolume error: %v", err)
+	}
+
+	waitForDetach := func() {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			if _, ok := s.detaches.inProgress(1003); !ok {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("detach was not reconciled")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitForDetach()
+
+	// The failure is reported to the next unpublish, and only once: the
+	// retry detaches the volume again.
+	_, err := s.ControllerUnpublishVolume(context.Background(), req)
+	if want := errDetachFailed(1003, 1003, detachErr); !reflect.DeepEqual(err, want) {
+		t.Errorf("ControllerUnpublishVolume error: %v, want %v", err, want)
+	}
+	if _, err := s.ControllerUnpublishVolume(context.Background(), req); err != nil {
+		t.Errorf("ControllerUnpublishVolume error: %v", err)
+	}
+	waitForDetach()
+	if err := s.detaches.takeFailure(1003); err != nil {
+		t.Errorf("takeFailure() = %v, want nil", err)
+	}
+}
+
 func TestValidateVolumeCapabilities(t *testing.T) {
+	writer := &csi.VolumeCapability{
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{FsType: "ext4"}},
+	}
 	tests := []struct {
 		name                    string
 		req                     *csi.ValidateVolumeCapabilitiesRequest
@@ -303,30 +577,68 @@ func TestValidateVolumeCapabilities(t *testing.T) {
 		{
 			name: "validatecapabilities",
 			req: &csi.ValidateVolumeCapabilitiesRequest{
-				VolumeId: "1003",
-				VolumeCapabilities: []*csi.VolumeCapability{
-					{
-						AccessMode: &csi.VolumeCapability_AccessMode{
-							Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
-						},
-					},
-				},
+				VolumeId:           "1003-pvc1",
+				VolumeContext:      map[string]string{PartitionAttribute: PartitionAuto},
+				VolumeCapabilities: []*csi.VolumeCapability{writer},
+				Parameters:         map[string]string{FilesystemTypeAttribute: "xfs"},
+				MutableParameters:  map[string]string{VolumeTags: "team-a"},
 			},
 			resp: &csi.ValidateVolumeCapabilitiesResponse{
 				Confirmed: &csi.ValidateVolumeCapabilitiesResponse_Confirmed{
-					VolumeCapabilities: []*csi.VolumeCapability{
-						{
-							AccessMode: &csi.VolumeCapability_AccessMode{
-								Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
-							},
-						},
-					},
+					VolumeContext:      map[string]string{PartitionAttribute: PartitionAuto},
+					VolumeCapabilities: []*csi.VolumeCapability{writer},
+					Parameters:         map[string]string{FilesystemTypeAttribute: "xfs"},
+					MutableParameters:  map[string]string{VolumeTags: "team-a"},
 				},
 			},
+		},
+		{
+			name: "Unsupported access mode",
+			req: &csi.ValidateVolumeCapabilitiesRequest{
+				VolumeId: "1003-pvc1",
+				VolumeCapabilities: []*csi.VolumeCapability{writer, {
+					AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY},
+				}},
+			},
+			resp: &csi.ValidateVolumeCapabilitiesResponse{
+				Message: "access mode MULTI_NODE_READER_ONLY of volume capability 1 is not supported, only SINGLE_NODE_WRITER is",
+			},
+		},
+		{
+			name: "Missing access mode and unsupported file system",
+			req: &csi.ValidateVolumeCapabilitiesRequest{
+				VolumeId: "1003-pvc1",
+				VolumeCapabilities: []*csi.VolumeCapability{{}, {
+					AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+					AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{FsType: "btrfs"}},
+				}},
+			},
+			resp: &csi.ValidateVolumeCapabilitiesResponse{
+				Message: `volume capability 0 has no access mode; volume capability 1: unsupported file system type "btrfs", must be one of [ext3 ext4 xfs]`,
+			},
+		},
+		{
+			name: "Invalid parameters",
+			req: &csi.ValidateVolumeCapabilitiesRequest{
+				VolumeId:           "1003-pvc1",
+				VolumeCapabilities: []*csi.VolumeCapability{writer},
+				Parameters:         map[string]string{PartitionAttribute: "0"},
+				MutableParameters:  map[string]string{"size": "20"},
+			},
+			resp: &csi.ValidateVolumeCapabilitiesResponse{
+				Message: status.Convert(errInvalidPartition("0")).Message() + "; " + status.Convert(errUnsupportedMutableParameter("size")).Message(),
+			},
+		},
+		{
+			name: "Volume not found",
+			req: &csi.ValidateVolumeCapabilitiesRequest{
+				VolumeId:           "1003-pvc1",
+				VolumeCapabilities: []*csi.VolumeCapability{writer},
+			},
 			expectLinodeClientCalls: func(m *mocks.MockLinodeClient) {
-				m.EXPECT().GetVolume(gomock.Any(), gomock.Any()).Return(&linodego.Volume{ID: 1001, LinodeID: createLinodeID(1003), Size: 10, Status: linodego.VolumeActive}, nil)
+				m.EXPECT().GetVolume(gomock.Any(), 1003).Return(nil, &linodego.Error{Code: http.StatusNotFound})
 			},
-			expectedError: nil,
+			expectedError: errVolumeNotFound(1003),
 		},
 	}
 	for _, tt := range tests {
@@ -336,6 +648,8 @@ func TestValidateVolumeCapabilities(t *testing.T) {
 			mockClient := mocks.NewMockLinodeClient(ctrl)
 			if tt.expectLinodeClientCalls != nil {
 				tt.expectLinodeClientCalls(mockClient)
+			} else {
+				mockClient.EXPECT().GetVolume(gomock.Any(), 1003).Return(&linodego.Volume{ID: 1003, Size: 10, Status: linodego.VolumeActive}, nil)
 			}
 
 			ns := &NodeServer{
@@ -345,9 +659,12 @@ func TestValidateVolumeCapabilities(t *testing.T) {
 				client: mockClient,
 				driver: ns.driver,
 			}
-			_, err := s.ValidateVolumeCapabilities(context.Background(), tt.req)
-			if err != nil && !reflect.DeepEqual(tt.expectedError, err) {
-				t.Errorf("ValidateVolumeCapabilities error %+v, wantErr %+v", err, tt.expectedError)
+			resp, err := s.ValidateVolumeCapabilities(context.Background(), tt.req)
+			if !reflect.DeepEqual(tt.expectedError, err) {
+				t.Fatalf("ValidateVolumeCapabilities error %+v, wantErr %+v", err, tt.expectedError)
+			}
+			if tt.resp != nil && !proto.Equal(resp, tt.resp) {
+				t.Errorf("ValidateVolumeCapabilities() = %v, want %v", resp, tt.resp)
 			}
 		})
 	}
@@ -429,6 +746,61 @@ func TestControllerExpandVolume(t *testing.T) {
 			},
 			expectedError: nil,
 		},
+		{
+			name: "Volume not found",
+			req: &csi.ControllerExpandVolumeRequest{
+				VolumeId:      "1003-pvc3",
+				CapacityRange: &csi.CapacityRange{LimitBytes: 20 << 30},
+			},
+			expectLinodeClientCalls: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 1003).Return(nil, &linodego.Error{Code: http.StatusNotFound, Message: "Not found"})
+			},
+			expectedError: errVolumeNotFound(1003),
+		},
+		{
+			name: "Rate limited",
+			req: &csi.ControllerExpandVolumeRequest{
+				VolumeId:      "1003-pvc3",
+				CapacityRange: &csi.CapacityRange{LimitBytes: 20 << 30},
+			},
+			expectLinodeClientCalls: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 1003).Return(nil, &linodego.Error{Code: http.StatusTooManyRequests, Message: "Too many requests"})
+			},
+			expectedError: status.Error(codes.Unavailable, "get volume 1003: [429] Too many requests"),
+		},
+		{
+			name: "Server error",
+			req: &csi.ControllerExpandVolumeRequest{
+				VolumeId:      "1003-pvc3",
+				CapacityRange: &csi.CapacityRange{LimitBytes: 20 << 30},
+			},
+			expectLinodeClientCalls: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 1003).Return(nil, &linodego.Error{Code: http.StatusBadGateway, Message: "Bad Gateway"})
+			},
+			expectedError: status.Error(codes.Unavailable, "get volume 1003: [502] Bad Gateway"),
+		},
+		{
+			name: "Connection failed",
+			req: &csi.ControllerExpandVolumeRequest{
+				VolumeId:      "1003-pvc3",
+				CapacityRange: &csi.CapacityRange{LimitBytes: 20 << 30},
+			},
+			expectLinodeClientCalls: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 1003).Return(nil, linodego.NewError(errors.New("connection refused")))
+			},
+			expectedError: status.Error(codes.Unavailable, "get volume 1003: [002] connection refused"),
+		},
+		{
+			name: "Unauthorized",
+			req: &csi.ControllerExpandVolumeRequest{
+				VolumeId:      "1003-pvc3",
+				CapacityRange: &csi.CapacityRange{LimitBytes: 20 << 30},
+			},
+			expectLinodeClientCalls: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 1003).Return(nil, &linodego.Error{Code: http.StatusUnauthorized, Message: "Invalid Token"})
+			},
+			expectedError: status.Error(codes.Internal, "get volume 1003: [401] Invalid Token"),
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -447,13 +819,109 @@ func TestControllerExpandVolume(t *testing.T) {
 				driver: ns.driver,
 			}
 			_, err := s.ControllerExpandVolume(context.Background(), tt.req)
-			if err != nil && !reflect.DeepEqual(tt.expectedError, err) {
+			if fmt.Sprint(err) != fmt.Sprint(tt.expectedError) {
 				t.Errorf("ControllerExpandVolume error: %+v, wantErr %+v", err, tt.expectedError)
 			}
 		})
 	}
 }
 
+func TestControllerGetVolume(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name                    string
+		req                     *csi.ControllerGetVolumeRequest
+		expectLinodeClientCalls func(m *mocks.MockLinodeClient)
+		wantCode                codes.Code
+		wantAbnormal            bool
+		wantMessage             string
+		wantPublishedNodeIDs    []string
+	}{
+		{
+			name: "Failed attach",
+			req:  &csi.ControllerGetVolumeRequest{VolumeId: "1001-vol"},
+			expectLinodeClientCalls: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(&linodego.Volume{ID: 1001, Label: "vol", Size: 10, Region: "us-east", Status: linodego.VolumeActive}, nil)
+				m.EXPECT().ListEvents(gomock.Any(), gomock.Any()).Return([]linodego.Event{{
+					Action:          linodego.ActionVolumeAttach,
+					Status:          linodego.EventFailed,
+					Created:         &created,
+					Message:         "attachment limit reached",
+					SecondaryEntity: &linodego.EventEntity{Type: linodego.EntityLinode, Label: "node-1"},
+				}}, nil)
+			},
+			wantCode:     codes.OK,
+			wantAbnormal: true,
+			wantMessage:  "volume is active; last event: volume_attach failed at 2024-05-01T12:00:00Z (linode node-1): attachment limit reached",
+		},
+		{
+			name: "Attached volume",
+			req:  &csi.ControllerGetVolumeRequest{VolumeId: "1001-vol"},
+			expectLinodeClientCalls: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(&linodego.Volume{ID: 1001, Label: "vol", LinodeID: createLinodeID(1003), Size: 10, Region: "us-east", Status: linodego.VolumeActive}, nil)
+				m.EXPECT().ListEvents(gomock.Any(), gomock.Any()).Return([]linodego.Event{{
+					Action:  linodego.ActionVolumeAttach,
+					Status:  linodego.EventFinished,
+					Created: &created,
+				}}, nil)
+			},
+			wantCode:             codes.OK,
+			wantMessage:          "volume is active; last event: volume_attach finished at 2024-05-01T12:00:00Z",
+			wantPublishedNodeIDs: []string{"1003"},
+		},
+		{
+			name: "Events unavailable",
+			req:  &csi.ControllerGetVolumeRequest{VolumeId: "1001-vol"},
+			expectLinodeClientCalls: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(&linodego.Volume{ID: 1001, Label: "vol", Size: 10, Region: "us-east", Status: linodego.VolumeResizing}, nil)
+				m.EXPECT().ListEvents(gomock.Any(), gomock.Any()).Return(nil, errors.New("API error"))
+			},
+			wantCode:     codes.OK,
+			wantAbnormal: true,
+			wantMessage:  "volume is resizing",
+		},
+		{
+			name: "Volume not found",
+			req:  &csi.ControllerGetVolumeRequest{VolumeId: "1001-vol"},
+			expectLinodeClientCalls: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(nil, &linodego.Error{Code: 404})
+			},
+			wantCode: codes.NotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockClient := mocks.NewMockLinodeClient(ctrl)
+			tt.expectLinodeClientCalls(mockClient)
+			s := &ControllerServer{client: mockClient}
+
+			resp, err := s.ControllerGetVolume(context.Background(), tt.req)
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("ControllerGetVolume() error = %v, want code %v", err, tt.wantCode)
+			}
+			if err != nil {
+				return
+			}
+
+			condition := resp.GetStatus().GetVolumeCondition()
+			if condition.GetAbnormal() != tt.wantAbnormal {
+				t.Errorf("ControllerGetVolume() abnormal = %v, want %v", condition.GetAbnormal(), tt.wantAbnormal)
+			}
+			if condition.GetMessage() != tt.wantMessage {
+				t.Errorf("ControllerGetVolume() message = %q, want %q", condition.GetMessage(), tt.wantMessage)
+			}
+			if got := resp.GetStatus().GetPublishedNodeIds(); !reflect.DeepEqual(got, tt.wantPublishedNodeIDs) {
+				t.Errorf("ControllerGetVolume() published node IDs = %v, want %v", got, tt.wantPublishedNodeIDs)
+			}
+		})
+	}
+}
+
 //nolint:gocognit // As simple as possible.
 func TestListVolumes(t *testing.T) {
 	cases := map[string]struct {
@@ -467,6 +935,7 @@ func TestListVolumes(t *testing.T) {
 					Label:    "foo",
 					Region:   "danmaaag",
 					Size:     30,
+					Status:   linodego.VolumeActive,
 					LinodeID: createLinodeID(10),
 				},
 			},
@@ -474,9 +943,10 @@ func TestListVolumes(t *testing.T) {
 		"volume not attached": {
 			volumes: []linodego.Volume{
 				{
-					ID:    1,
-					Label: "bar",
-					Size:  30,
+					ID:     1,
+					Label:  "bar",
+					Size:   30,
+					Status: linodego.VolumeActive,
 				},
 			},
 		},
@@ -486,12 +956,14 @@ func TestListVolumes(t *testing.T) {
 					ID:       1,
 					Label:    "foo",
 					Size:     30,
+					Status:   linodego.VolumeActive,
 					LinodeID: createLinodeID(5),
 				},
 				{
 					ID:       2,
 					Label:    "foo",
 					Size:     60,
+					Status:   linodego.VolumeActive,
 					LinodeID: createLinodeID(10),
 				},
 			},
@@ -502,12 +974,14 @@ func TestListVolumes(t *testing.T) {
 					ID:       1,
 					Label:    "foo",
 					Size:     30,
+					Status:   linodego.VolumeActive,
 					LinodeID: createLinodeID(5),
 				},
 				{
-					ID:    2,
-					Label: "foo",
-					Size:  30,
+					ID:     2,
+					Label:  "foo",
+					Size:   30,
+					Status: linodego.VolumeActive,
 				},
 			},
 		},
@@ -525,7 +999,7 @@ func TestListVolumes(t *testing.T) {
 				},
 			}
 
-			resp, err := cs.ListVolumes(context.Background(), &csi.ListVolumesRequest{StartingToken: "10"})
+			resp, err := cs.ListVolumes(context.Background(), &csi.ListVolumesRequest{})
 			switch {
 			case (err != nil && !tt.throwErr):
 				t.Fatal("failed to list volumes:", err)
@@ -597,6 +1071,365 @@ func TestListVolumes(t *testing.T) {
 	}
 }
 
+func TestListedVolumeContext(t *testing.T) {
+	created := time.Date(2021, 3, 1, 8, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		vol  *linodego.Volume
+		want map[string]string
+	}{
+		{
+			name: "Legacy volume",
+			vol:  &linodego.Volume{ID: 1001, Label: "pvc1", Region: "us-east", Created: &created},
+			want: map[string]string{
+				VolumeTopologyRegion:     "us-east",
+				VolumeCreatedAtAttribute: "2021-03-01T08:30:00Z",
+				VolumeEncryption:         "false",
+			},
+		},
+		{
+			name: "Tagged encrypted volume",
+			vol: &linodego.Volume{
+				ID:         1002,
+				Label:      "pvc2",
+				Region:     "us-ord",
+				Created:    &created,
+				Encryption: "enabled",
+				Tags:       []string{"team-a", "csi-cluster:abcd1234"},
+			},
+			want: map[string]string{
+				VolumeTags:               "team-a",
+				VolumeTopologyRegion:     "us-ord",
+				VolumeCreatedAtAttribute: "2021-03-01T08:30:00Z",
+				VolumeEncryption:         True,
+			},
+		},
+		{
+			name: "Volume without creation time",
+			vol:  &linodego.Volume{ID: 1003, Label: "pvc3", Region: "us-east", Encryption: "disabled"},
+			want: map[string]string{
+				VolumeTopologyRegion: "us-east",
+				VolumeEncryption:     "false",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := listedVolumeContext(tt.vol); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("listedVolumeContext() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListVolumesPagination(t *testing.T) {
+	// makeVolumes returns volumes with IDs in [from, to).
+	makeVolumes := func(from, to int) []linodego.Volume {
+		volumes := make([]linodego.Volume, 0, to-from)
+		for id := from; id < to; id++ {
+			volumes = append(volumes, linodego.Volume{ID: id, Label: fmt.Sprintf("vol-%d", id), Size: 10})
+		}
+		return volumes
+	}
+	// page returns the volumes of a 60 volume account on the given page.
+	page := func(page, pageSize int) []linodego.Volume {
+		return makeVolumes(min((page-1)*pageSize, 60), min(page*pageSize, 60))
+	}
+
+	tests := []struct {
+		name            string
+		req             *csi.ListVolumesRequest
+		opts            Options
+		expectListCalls func(m *mocks.MockLinodeClient)
+		wantIDs         []int
+		wantNextToken   string
+		wantCode        codes.Code
+	}{
+		{
+			name: "All volumes",
+			req:  &csi.ListVolumesRequest{},
+			expectListCalls: func(m *mocks.MockLinodeClient) {
+				listOpts := linodego.NewListOptions(1, "")
+				listOpts.PageSize = maxListPageSize
+				m.EXPECT().ListVolumes(gomock.Any(), listOpts).Return(makeVolumes(0, 3), nil)
+			},
+			wantIDs: []int{0, 1, 2},
+		},
+		{
+			name: "Fewer entries than a page",
+			req:  &csi.ListVolumesRequest{MaxEntries: 10},
+			expectListCalls: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().ListVolumes(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, opts *linodego.ListOptions) ([]linodego.Volume, error) {
+					if opts.Page != 1 || opts.PageSize != minListPageSize {
+						t.Errorf("ListVolumes() page = %d, page size = %d, want 1 and %d", opts.Page, opts.PageSize, minListPageSize)
+					}
+					opts.Pages = 3
+					return page(opts.Page, opts.PageSize), nil
+				})
+			},
+			wantIDs:       []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+			wantNextToken: "10",
+		},
+		{
+			name: "Entries across pages",
+			req:  &csi.ListVolumesRequest{MaxEntries: 10, StartingToken: "20"},
+			expectListCalls: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().ListVolumes(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, opts *linodego.ListOptions) ([]linodego.Volume, error) {
+					opts.Pages = 3
+					return page(opts.Page, opts.PageSize), nil
+				}).Times(2)
+			},
+			wantIDs:       []int{20, 21, 22, 23, 24, 25, 26, 27, 28, 29},
+			wantNextToken: "30",
+		},
+		{
+			name: "Last entries",
+			req:  &csi.ListVolumesRequest{MaxEntries: 30, StartingToken: "50"},
+			expectListCalls: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().ListVolumes(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, opts *linodego.ListOptions) ([]linodego.Volume, error) {
+					opts.Pages = 2
+					return page(opts.Page, opts.PageSize), nil
+				}).Times(1)
+			},
+			wantIDs: []int{50, 51, 52, 53, 54, 55, 56, 57, 58, 59},
+		},
+		{
+			name: "Filtered pages",
+			req:  &csi.ListVolumesRequest{MaxEntries: 25, StartingToken: "25"},
+			opts: Options{ListVolumesRegions: []string{"us-east", "us-west"}},
+			expectListCalls: func(m *mocks.MockLinodeClient) {
+				filter := `{"+or":[{"region":"us-east"},{"region":"us-west"}]}`
+				m.EXPECT().ListVolumes(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, opts *linodego.ListOptions) ([]linodego.Volume, error) {
+					if opts.Filter != filter {
+						t.Errorf("ListVolumes() filter = %s, want %s", opts.Filter, filter)
+					}
+					// The last page is full: it must not be listed past.
+					opts.Pages, opts.Results = 2, 50
+					return page(opts.Page, opts.PageSize), nil
+				})
+			},
+			wantIDs: []int{25, 26, 27, 28, 29, 30, 31, 32, 33, 34, 35, 36, 37, 38, 39, 40, 41, 42, 43, 44, 45, 46, 47, 48, 49},
+		},
+		{
+			name: "Unknown page count",
+			req:  &csi.ListVolumesRequest{MaxEntries: 25},
+			expectListCalls: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().ListVolumes(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, opts *linodego.ListOptions) ([]linodego.Volume, error) {
+					return page(opts.Page, opts.PageSize), nil
+				}).Times(2)
+			},
+			wantIDs:       []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24},
+			wantNextToken: "25",
+		},
+		{
+			name: "Filter by region and tag",
+			req:  &csi.ListVolumesRequest{},
+			opts: Options{ListVolumesRegions: []string{"us-east"}, ListVolumesTag: "cluster-a"},
+			expectListCalls: func(m *mocks.MockLinodeClient) {
+				filter := `{"+and":[{"region":"us-east"},{"tags":"cluster-a"}]}`
+				listOpts := linodego.NewListOptions(1, filter)
+				listOpts.PageSize = maxListPageSize
+				m.EXPECT().ListVolumes(gomock.Any(), listOpts).Return(makeVolumes(0, 1), nil)
+			},
+			wantIDs: []int{0},
+		},
+		{
+			name: "All volumes across pages",
+			req:  &csi.ListVolumesRequest{StartingToken: "450"},
+			expectListCalls: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().ListVolumes(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, opts *linodego.ListOptions) ([]linodego.Volume, error) {
+					if opts.PageSize != maxListPageSize {
+						t.Errorf("ListVolumes() page size = %d, want %d", opts.PageSize, maxListPageSize)
+					}
+					return makeVolumes(min((opts.Page-1)*opts.PageSize, 520), min(opts.Page*opts.PageSize, 520)), nil
+				}).Times(2)
+			},
+			wantIDs: func() []int {
+				var ids []int
+				for id := 450; id < 520; id++ {
+					ids = append(ids, id)
+				}
+				return ids
+			}(),
+		},
+		{
+			name: "Response size bound",
+			req:  &csi.ListVolumesRequest{},
+			expectListCalls: func(m *mocks.MockLinodeClient) {
+				// Each entry takes a bit more than 100 KiB, with the region
+				// in both its topology and context
+				volumes := makeVolumes(0, 60)
+				for i := range volumes {
+					volumes[i].Region = strings.Repeat("r", 50<<10)
+				}
+				m.EXPECT().ListVolumes(gomock.Any(), gomock.Any()).Return(volumes, nil)
+			},
+			wantIDs: []int{
+				0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19,
+				20, 21, 22, 23, 24, 25, 26, 27, 28, 29,
+			},
+			wantNextToken: "30",
+		},
+		{
+			name:     "Invalid starting token",
+			req:      &csi.ListVolumesRequest{StartingToken: "-1"},
+			wantCode: codes.Aborted,
+		},
+		{
+			name:     "Invalid max entries",
+			req:      &csi.ListVolumesRequest{MaxEntries: -1},
+			wantCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockClient := mocks.NewMockLinodeClient(ctrl)
+			mockClient.EXPECT().ListEvents(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+			if tt.expectListCalls != nil {
+				tt.expectListCalls(mockClient)
+			}
+			cs := &ControllerServer{
+				client: mockClient,
+				driver: &LinodeDriver{opts: tt.opts},
+			}
+
+			resp, err := cs.ListVolumes(context.Background(), tt.req)
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("ListVolumes() error = %v, want code %v", err, tt.wantCode)
+			}
+			if err != nil {
+				return
+			}
+
+			var gotIDs []int
+			for _, entry := range resp.GetEntries() {
+				id, err := linodevolumes.ParseLinodeVolumeKey(entry.GetVolume().GetVolumeId())
+				if err != nil {
+					t.Fatalf("invalid volume ID: %v", err)
+				}
+				gotIDs = append(gotIDs, id.VolumeID)
+			}
+			if !reflect.DeepEqual(gotIDs, tt.wantIDs) {
+				t.Errorf("ListVolumes() volume IDs = %v, want %v", gotIDs, tt.wantIDs)
+			}
+			if resp.GetNextToken() != tt.wantNextToken {
+				t.Errorf("ListVolumes() next token = %q, want %q", resp.GetNextToken(), tt.wantNextToken)
+			}
+		})
+	}
+}
+
+// BenchmarkListVolumes lists all the volumes of an account with 10k volumes,
+// served in pages by the Linode API.
+func BenchmarkListVolumes(b *testing.B) {
+	const count = 10000
+	volumes := make([]linodego.Volume, 0, count)
+	for id := range count {
+		volumes = append(volumes, linodego.Volume{ID: id, Label: fmt.Sprintf("pvc-%032d", id), Region: "us-east", Size: 10, Status: linodego.VolumeActive, LinodeID: createLinodeID(id % 100)})
+	}
+
+	ctrl := gomock.NewController(b)
+	mockClient := mocks.NewMockLinodeClient(ctrl)
+	mockClient.EXPECT().ListEvents(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+	mockClient.EXPECT().ListVolumes(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, opts *linodego.ListOptions) ([]linodego.Volume, error) {
+		opts.Pages = (count + opts.PageSize - 1) / opts.PageSize
+		// A copy, as the client decodes a new page for each request
+		return slices.Clone(volumes[min((opts.Page-1)*opts.PageSize, count):min(opts.Page*opts.PageSize, count)]), nil
+	}).AnyTimes()
+	cs := &ControllerServer{client: mockClient}
+
+	b.ReportAllocs()
+	for range b.N {
+		entries := 0
+		req := &csi.ListVolumesRequest{}
+		for {
+			resp, err := cs.ListVolumes(context.Background(), req)
+			if err != nil {
+				b.Fatalf("ListVolumes() error = %v", err)
+			}
+			entries += len(resp.GetEntries())
+			if resp.GetNextToken() == "" {
+				break
+			}
+			req.StartingToken = resp.GetNextToken()
+		}
+		if entries != count {
+			b.Fatalf("ListVolumes() listed %d volumes, want %d", entries, count)
+		}
+	}
+}
+
+// BenchmarkListVolumesEntry builds the ListVolumes entry of an attached
+// volume described by its latest event.
+func BenchmarkListVolumesEntry(b *testing.B) {
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	vol := &linodego.Volume{ID: 1001, Label: "pvc0b5a1b8e6c1d4b589a7d1f", Region: "us-east", Size: 10, Status: linodego.VolumeActive, LinodeID: createLinodeID(1003)}
+	event := &linodego.Event{
+		Action:          linodego.ActionVolumeAttach,
+		Status:          linodego.EventFinished,
+		Created:         &created,
+		SecondaryEntity: &linodego.EventEntity{Type: linodego.EntityLinode, Label: "node-1"},
+	}
+
+	b.ReportAllocs()
+	for range b.N {
+		listVolumesEntry(vol, event)
+	}
+}
+
+func TestListVolumesCondition(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mocks.NewMockLinodeClient(ctrl)
+	mockClient.EXPECT().ListVolumes(gomock.Any(), gomock.Any()).Return([]linodego.Volume{
+		{ID: 1001, Label: "failed", Size: 10, Status: linodego.VolumeActive},
+		{ID: 1002, Label: "attached", Size: 10, Status: linodego.VolumeActive, LinodeID: createLinodeID(1003)},
+		{ID: 1003, Label: "resizing", Size: 10, Status: linodego.VolumeResizing},
+	}, nil)
+	// A single request returns the latest events of every volume, most
+	// recent first.
+	mockClient.EXPECT().ListEvents(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, opts *linodego.ListOptions) ([]linodego.Event, error) {
+		if opts.PageSize != maxListPageSize {
+			t.Errorf("ListEvents() page size = %d, want %d", opts.PageSize, maxListPageSize)
+		}
+		return []linodego.Event{
+			{Action: linodego.ActionVolumeAttach, Status: linodego.EventFailed, Created: &created, Message: "attachment limit reached", Entity: &linodego.EventEntity{ID: float64(1001)}},
+			{Action: linodego.ActionVolumeAttach, Status: linodego.EventFinished, Created: &created, Entity: &linodego.EventEntity{ID: float64(1002)}},
+			{Action: linodego.ActionVolumeAttach, Status: linodego.EventFinished, Created: &created, Entity: &linodego.EventEntity{ID: float64(1001)}},
+		}, nil
+	})
+
+	cs := &ControllerServer{client: mockClient}
+	resp, err := cs.ListVolumes(context.Background(), &csi.ListVolumesRequest{})
+	if err != nil {
+		t.Fatalf("ListVolumes() error = %v", err)
+	}
+
+	want := []*csi.VolumeCondition{
+		{Abnormal: true, Message: "volume is active; last event: volume_attach failed at 2024-05-01T12:00:00Z: attachment limit reached"},
+		{Abnormal: false, Message: "volume is active; last event: volume_attach finished at 2024-05-01T12:00:00Z"},
+		{Abnormal: true, Message: "volume is resizing"},
+	}
+	if len(resp.GetEntries()) != len(want) {
+		t.Fatalf("ListVolumes() returned %d entries, want %d", len(resp.GetEntries()), len(want))
+	}
+	for i, entry := range resp.GetEntries() {
+		got := entry.GetStatus().GetVolumeCondition()
+		if got.GetAbnormal() != want[i].GetAbnormal() || got.GetMessage() != want[i].GetMessage() {
+			t.Errorf("ListVolumes() entry %d condition = %v, want %v", i, got, want[i])
+		}
+	}
+}
+
 var _ linodeclient.LinodeClient = &fakeLinodeClient{}
 
 type fakeLinodeClient struct {
@@ -624,6 +1457,27 @@ func (c *fakeLinodeClient) ListInstanceDisks(_ context.Context, _ int, _ *linode
 	return c.disks, nil
 }
 
+func (c *fakeLinodeClient) ListInstanceConfigs(_ context.Context, _ int, _ *linodego.ListOptions) ([]linodego.InstanceConfig, error) {
+	return nil, nil
+}
+
+func (flc *fakeLinodeClient) ListRegions(context.Context, *linodego.ListOptions) ([]linodego.Region, error) {
+	return nil, nil
+}
+
+//nolint:nilnil // TODO: re-work tests
+func (flc *fakeLinodeClient) GetAccount(context.Context) (*linodego.Account, error) {
+	return &linodego.Account{}, nil
+}
+
+func (flc *fakeLinodeClient) GetProfile(context.Context) (*linodego.Profile, error) {
+	return &linodego.Profile{}, nil
+}
+
+func (flc *fakeLinodeClient) ListTokens(context.Context, *linodego.ListOptions) ([]linodego.Token, error) {
+	return nil, nil
+}
+
 //nolint:nilnil // TODO: re-work tests
 func (flc *fakeLinodeClient) GetRegion(context.Context, string) (*linodego.Region, error) {
 	return nil, nil
@@ -649,6 +1503,16 @@ func (flc *fakeLinodeClient) CloneVolume(context.Context, int, string) (*linodeg
 	return nil, nil
 }
 
+//nolint:nilnil // TODO: re-work tests
+func (flc *fakeLinodeClient) UpdateVolume(context.Context, int, linodego.VolumeUpdateOptions) (*linodego.Volume, error) {
+	return nil, nil
+}
+
+//nolint:nilnil // TODO: re-work tests
+func (flc *fakeLinodeClient) UpdateInstanceConfig(context.Context, int, int, linodego.InstanceConfigUpdateOptions) (*linodego.InstanceConfig, error) {
+	return nil, nil
+}
+
 //nolint:nilnil // TODO: re-work tests
 func (flc *fakeLinodeClient) AttachVolume(context.Context, int, *linodego.VolumeAttachOptions) (*linodego.Volume, error) {
 	return nil, nil
@@ -675,6 +1539,10 @@ func (flc *fakeLinodeClient) NewEventPoller(context.Context, any, linodego.Entit
 	return nil, nil
 }
 
+func (flc *fakeLinodeClient) ListEvents(context.Context, *linodego.ListOptions) ([]linodego.Event, error) {
+	return nil, nil
+}
+
 func createLinodeID(i int) *int {
 	return &i
 }
@@ -741,14 +1609,14 @@ func TestControllerCanAttach(t *testing.T) {
 				},
 			}
 
-			got, err := srv.canAttach(ctx, instance)
+			limit, attached, err := srv.attachmentCapacity(ctx, instance)
 			if err != nil && !tt.fail {
 				t.Fatal(err)
 			} else if err == nil && tt.fail {
 				t.Fatal("should have failed")
 			}
 
-			if got != tt.want {
+			if got := attached < limit; got != tt.want {
 				t.Errorf("got=%t want=%t", got, tt.want)
 			}
 		})
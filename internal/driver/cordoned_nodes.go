@@ -0,0 +1,272 @@
+package driver
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/linode/linodego"
+
+	kubeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/kube-client"
+	linodeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-client"
+	linodevolumes "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-volumes"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+// Results of detaching the read-only volumes of cordoned nodes, used as the
+// "result" label of the csi_cordoned_node_detaches_total metric.
+const (
+	cordonedNodeDetached = "detached"
+	cordonedNodeFailed   = "failed"
+)
+
+// cordonedNodeCheckInterval is how often the read-only volumes of a cordoned
+// node are checked again while pods of the node still use some of them.
+var cordonedNodeCheckInterval = 10 * time.Second
+
+// cordonedNodeDetacher detaches the read-only volumes of this driver from
+// cordoned Kubernetes nodes once no pod of the node uses them, a delay after
+// the nodes were cordoned.
+//
+// A Linode volume is attached to a single instance, so a volume published
+// read-only to several nodes cannot be attached to the node its pods move to
+// until the attach/detach controller detached it from the drained node,
+// which waits for the kubelet of that node to unmount it. No data is written
+// to read-only volumes, so they can be detached ahead of it. Only volumes
+// whose only access mode is ReadOnlyMany are detached: the node plugin stages
+// them read-only, while a volume that is only read-only in its CSI source or
+// in the pods using it may be staged read-write.
+//
+// The staging mount of a detached volume, and its LUKS mapping if it is
+// encrypted, are left on the node, on a device that no longer exists. The
+// kubelet removes them with NodeUnstageVolume, which only unmounts the
+// read-only file system and closes the mapping, when the volume is
+// unpublished from the node; if the node restarts first, they are the
+// orphans removed by the node plugin with ORPHAN_CLEANUP set to "fix".
+//
+// The VolumeAttachments of the detached volumes are left alone. ListVolumes
+// and ControllerGetVolume keep reporting the volumes published to the node
+// they were detached from until ControllerUnpublishVolume is called for it,
+// or the node is uncordoned, so that the external attacher does not attach
+// them again meanwhile.
+type cordonedNodeDetacher struct {
+	kube   kubeclient.KubeClient
+	client linodeclient.LinodeClient
+	nodes  kubeclient.NodeLookup
+	driver *LinodeDriver
+	delay  time.Duration
+
+	mu sync.Mutex // protects the fields below
+	// detached are the nodes the volumes were detached from, by volume ID.
+	detached map[int]cordonedNodeDetach
+	// checking are the names of the nodes whose volumes are being checked.
+	checking map[string]bool
+}
+
+// cordonedNodeDetach is a volume detached from a cordoned node.
+type cordonedNodeDetach struct {
+	node kubeclient.Node
+	// linodeID is the ID of the Linode instance the volume was attached to.
+	linodeID int
+}
+
+func newCordonedNodeDetacher(kube kubeclient.KubeClient, client linodeclient.LinodeClient, nodes kubeclient.NodeLookup, driver *LinodeDriver, delay time.Duration) *cordonedNodeDetacher {
+	return &cordonedNodeDetacher{
+		kube:     kube,
+		client:   client,
+		nodes:    nodes,
+		driver:   driver,
+		delay:    delay,
+		detached: make(map[int]cordonedNodeDetach),
+		checking: make(map[string]bool),
+	}
+}
+
+// start detaches the read-only volumes of the nodes notified by cordons,
+// after the delay, until ctx is canceled.
+func (d *cordonedNodeDetacher) start(ctx context.Context, cordons kubeclient.NodeCordons) {
+	cordons.OnNodeCordoned(func(node kubeclient.Node) {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if d.checking[node.Name] {
+			return
+		}
+		d.checking[node.Name] = true
+
+		logger.GetLogger(ctx).V(2).Info("Node cordoned, detaching its unused read-only volumes after the delay", "node", node.Name, "linodeID", node.LinodeID, "delay", d.delay)
+		time.AfterFunc(d.delay, func() {
+			d.watchNode(ctx, node)
+		})
+	})
+}
+
+// watchNode detaches the read-only volumes of node as pods stop using them,
+// until none is used or the node is uncordoned.
+func (d *cordonedNodeDetacher) watchNode(ctx context.Context, node kubeclient.Node) {
+	defer func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		delete(d.checking, node.Name)
+	}()
+
+	for ctx.Err() == nil && d.cordoned(ctx, node) {
+		if !d.detachNode(ctx, node) {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(cordonedNodeCheckInterval):
+		}
+	}
+}
+
+// cordoned reports whether node still exists and is cordoned.
+func (d *cordonedNodeDetacher) cordoned(ctx context.Context, node kubeclient.Node) bool {
+	current, err := d.nodes.LookupNode(ctx, node.LinodeID, node.Name)
+	return err == nil && current.Name == node.Name && current.Unschedulable
+}
+
+// detachNode detaches the read-only volumes the VolumeAttachments of this
+// driver attach to node that no pod of the node uses. It returns whether
+// some of them must be checked again, because pods still use them, they
+// could not be listed or they failed to detach.
+func (d *cordonedNodeDetacher) detachNode(ctx context.Context, node kubeclient.Node) bool {
+	log := logger.GetLogger(ctx).Klogr.WithValues("node", node.Name, "linodeID", node.LinodeID)
+
+	attachments, err := d.kube.ListVolumeAttachments(ctx, d.driver.name)
+	if err != nil {
+		log.Error(err, "Failed to list the volume attachments of the cordoned node")
+		return true
+	}
+	attached := make(map[string]bool)
+	for _, attachment := range attachments {
+		if attachment.NodeName == node.Name && attachment.Attached && attachment.PersistentVolumeName != "" {
+			attached[attachment.PersistentVolumeName] = true
+		}
+	}
+	if len(attached) == 0 {
+		return false
+	}
+
+	pvs, err := d.kube.ListPersistentVolumes(ctx, d.driver.name)
+	if err != nil {
+		log.Error(err, "Failed to list the persistent volumes of the cordoned node")
+		return true
+	}
+	var readOnly []kubeclient.PersistentVolume
+	for _, pv := range pvs {
+		if attached[pv.Name] && pv.ReadOnly {
+			readOnly = append(readOnly, pv)
+		}
+	}
+	if len(readOnly) == 0 {
+		return false
+	}
+
+	pods, err := d.kube.ListNodePods(ctx, node.Name)
+	if err != nil {
+		log.Error(err, "Failed to list the pods of the cordoned node")
+		return true
+	}
+	used := make(map[string]bool)
+	for _, pod := range pods {
+		for _, claim := range pod.ClaimNames {
+			used[pod.Namespace+"/"+claim] = true
+		}
+	}
+
+	retry := false
+	for _, pv := range readOnly {
+		if used[pv.ClaimNamespace+"/"+pv.ClaimName] {
+			log.V(4).Info("Read-only volume of the cordoned node still used by a pod", "pv", pv.Name)
+			retry = true
+			continue
+		}
+		if err := d.detachVolume(ctx, node, pv); err != nil {
+			log.Error(err, "Failed to detach the read-only volume of the cordoned node", "pv", pv.Name, "volumeHandle", pv.VolumeHandle)
+			observability.CordonedNodeDetachesTotal.WithLabelValues(cordonedNodeFailed).Inc()
+			retry = true
+		}
+	}
+	return retry
+}
+
+// detachVolume detaches the volume of pv if it is still attached to node.
+func (d *cordonedNodeDetacher) detachVolume(ctx context.Context, node kubeclient.Node, pv kubeclient.PersistentVolume) error {
+	log := logger.GetLogger(ctx)
+
+	key, err := linodevolumes.ParseLinodeVolumeKey(pv.VolumeHandle)
+	if err != nil {
+		return err
+	}
+	volume, err := d.client.GetVolume(ctx, key.VolumeID)
+	if linodego.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !attachedToNode(volume, node) {
+		return nil
+	}
+
+	// Record the detach first, so that the volume is never reported
+	// unpublished from the node while the VolumeAttachment exists
+	d.mu.Lock()
+	d.detached[volume.ID] = cordonedNodeDetach{node: node, linodeID: *volume.LinodeID}
+	d.mu.Unlock()
+	if err := d.client.DetachVolume(ctx, volume.ID); err != nil && !linodego.IsNotFound(err) {
+		d.forget(volume.ID, *volume.LinodeID)
+		return err
+	}
+	log.V(2).Info("Read-only volume of the cordoned node detached", "volume_id", volume.ID, "pv", pv.Name, "node", node.Name)
+	observability.CordonedNodeDetachesTotal.WithLabelValues(cordonedNodeDetached).Inc()
+	return nil
+}
+
+// publishedNodeID returns the ID of the node volumeID was detached from, if
+// it is still cordoned, so that the volume is reported published to it.
+func (d *cordonedNodeDetacher) publishedNodeID(ctx context.Context, volumeID int) (string, bool) {
+	d.mu.Lock()
+	detach, ok := d.detached[volumeID]
+	d.mu.Unlock()
+	if !ok {
+		return "", false
+	}
+	if !d.cordoned(ctx, detach.node) {
+		// The external attacher attaches the volume again if the node
+		// still needs it
+		d.forget(volumeID, detach.linodeID)
+		return "", false
+	}
+	return strconv.Itoa(detach.linodeID), true
+}
+
+// forget stops reporting volumeID published to the Linode instance
+// linodeID, once ControllerPublishVolume or ControllerUnpublishVolume is
+// called for them.
+func (d *cordonedNodeDetacher) forget(volumeID, linodeID int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if detach, ok := d.detached[volumeID]; ok && detach.linodeID == linodeID {
+		delete(d.detached, volumeID)
+	}
+}
+
+// withCordonedNode returns publishedNodeIDs, the IDs of the nodes the volume
+// volumeID is attached to, with the cordoned node it was detached from as a
+// read-only volume, if any.
+func (cs *ControllerServer) withCordonedNode(ctx context.Context, volumeID int, publishedNodeIDs []string) []string {
+	if cs.cordonedNodes == nil {
+		return publishedNodeIDs
+	}
+	nodeID, ok := cs.cordonedNodes.publishedNodeID(ctx, volumeID)
+	if !ok || slices.Contains(publishedNodeIDs, nodeID) {
+		return publishedNodeIDs
+	}
+	return append(publishedNodeIDs, nodeID)
+}
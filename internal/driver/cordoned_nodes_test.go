@@ -0,0 +1,199 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/linode/linodego"
+	"go.uber.org/mock/gomock"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+	kubeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/kube-client"
+)
+
+func TestCordonedNodeDetacher(t *testing.T) {
+	node := kubeclient.Node{Name: "node-1", LinodeID: 1003, Unschedulable: true}
+	attachments := []kubeclient.VolumeAttachment{
+		{Name: "csi-1", NodeName: "node-1", PersistentVolumeName: "pv-1", Attached: true},
+		{Name: "csi-2", NodeName: "node-1", PersistentVolumeName: "pv-2", Attached: true},
+		{Name: "csi-3", NodeName: "node-2", PersistentVolumeName: "pv-3", Attached: true},
+	}
+	pvs := []kubeclient.PersistentVolume{
+		{Name: "pv-1", VolumeHandle: "1001-pv1", ClaimNamespace: "default", ClaimName: "cache", ReadOnly: true},
+		{Name: "pv-2", VolumeHandle: "1002-pv2", ClaimNamespace: "default", ClaimName: "data"},
+		{Name: "pv-3", VolumeHandle: "1003-pv3", ClaimNamespace: "default", ClaimName: "other", ReadOnly: true},
+	}
+	linodeID := func(id int) *int { return &id }
+
+	tests := []struct {
+		name         string
+		setupMocks   func(*mocks.MockLinodeClient, *mocks.MockKubeClient)
+		wantRetry    bool
+		wantDetached bool
+	}{
+		{
+			name: "Detached",
+			setupMocks: func(m *mocks.MockLinodeClient, k *mocks.MockKubeClient) {
+				k.EXPECT().ListVolumeAttachments(gomock.Any(), Name).Return(attachments, nil)
+				k.EXPECT().ListPersistentVolumes(gomock.Any(), Name).Return(pvs, nil)
+				// Pods using read-write volumes do not hold back the others
+				k.EXPECT().ListNodePods(gomock.Any(), "node-1").Return([]kubeclient.Pod{{Namespace: "default", Name: "db", ClaimNames: []string{"data"}}}, nil)
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(&linodego.Volume{ID: 1001, LinodeID: linodeID(1003)}, nil)
+				m.EXPECT().DetachVolume(gomock.Any(), 1001).Return(nil)
+			},
+			wantDetached: true,
+		},
+		{
+			name: "Still used",
+			setupMocks: func(m *mocks.MockLinodeClient, k *mocks.MockKubeClient) {
+				k.EXPECT().ListVolumeAttachments(gomock.Any(), Name).Return(attachments, nil)
+				k.EXPECT().ListPersistentVolumes(gomock.Any(), Name).Return(pvs, nil)
+				k.EXPECT().ListNodePods(gomock.Any(), "node-1").Return([]kubeclient.Pod{{Namespace: "default", Name: "web", ClaimNames: []string{"cache"}}}, nil)
+			},
+			wantRetry: true,
+		},
+		{
+			name: "Used by a pod of another namespace",
+			setupMocks: func(m *mocks.MockLinodeClient, k *mocks.MockKubeClient) {
+				k.EXPECT().ListVolumeAttachments(gomock.Any(), Name).Return(attachments, nil)
+				k.EXPECT().ListPersistentVolumes(gomock.Any(), Name).Return(pvs, nil)
+				k.EXPECT().ListNodePods(gomock.Any(), "node-1").Return([]kubeclient.Pod{{Namespace: "other", Name: "web", ClaimNames: []string{"cache"}}}, nil)
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(&linodego.Volume{ID: 1001, LinodeID: linodeID(1003)}, nil)
+				m.EXPECT().DetachVolume(gomock.Any(), 1001).Return(nil)
+			},
+			wantDetached: true,
+		},
+		{
+			name: "No read-only volume",
+			setupMocks: func(m *mocks.MockLinodeClient, k *mocks.MockKubeClient) {
+				k.EXPECT().ListVolumeAttachments(gomock.Any(), Name).Return(attachments, nil)
+				k.EXPECT().ListPersistentVolumes(gomock.Any(), Name).Return(pvs[1:], nil)
+			},
+		},
+		{
+			name: "Attached to another linode",
+			setupMocks: func(m *mocks.MockLinodeClient, k *mocks.MockKubeClient) {
+				k.EXPECT().ListVolumeAttachments(gomock.Any(), Name).Return(attachments, nil)
+				k.EXPECT().ListPersistentVolumes(gomock.Any(), Name).Return(pvs, nil)
+				k.EXPECT().ListNodePods(gomock.Any(), "node-1").Return(nil, nil)
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(&linodego.Volume{ID: 1001, LinodeID: linodeID(1004)}, nil)
+			},
+		},
+		{
+			name: "Detach failed",
+			setupMocks: func(m *mocks.MockLinodeClient, k *mocks.MockKubeClient) {
+				k.EXPECT().ListVolumeAttachments(gomock.Any(), Name).Return(attachments, nil)
+				k.EXPECT().ListPersistentVolumes(gomock.Any(), Name).Return(pvs, nil)
+				k.EXPECT().ListNodePods(gomock.Any(), "node-1").Return(nil, nil)
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(&linodego.Volume{ID: 1001, LinodeID: linodeID(1003)}, nil)
+				m.EXPECT().DetachVolume(gomock.Any(), 1001).Return(errors.New("api error"))
+			},
+			wantRetry: true,
+		},
+		{
+			name: "Pods not listed",
+			setupMocks: func(m *mocks.MockLinodeClient, k *mocks.MockKubeClient) {
+				k.EXPECT().ListVolumeAttachments(gomock.Any(), Name).Return(attachments, nil)
+				k.EXPECT().ListPersistentVolumes(gomock.Any(), Name).Return(pvs, nil)
+				k.EXPECT().ListNodePods(gomock.Any(), "node-1").Return(nil, errors.New("forbidden"))
+			},
+			wantRetry: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockClient := mocks.NewMockLinodeClient(ctrl)
+			mockKube := mocks.NewMockKubeClient(ctrl)
+			tt.setupMocks(mockClient, mockKube)
+			mockNodes := mocks.NewMockNodeLookup(ctrl)
+			mockNodes.EXPECT().LookupNode(gomock.Any(), 1003, "node-1").Return(node, nil).AnyTimes()
+
+			d := newCordonedNodeDetacher(mockKube, mockClient, mockNodes, &LinodeDriver{name: Name}, time.Minute)
+			if retry := d.detachNode(context.Background(), node); retry != tt.wantRetry {
+				t.Errorf("detachNode() = %v, want %v", retry, tt.wantRetry)
+			}
+			nodeID, detached := d.publishedNodeID(context.Background(), 1001)
+			if detached != tt.wantDetached || (detached && nodeID != "1003") {
+				t.Errorf("publishedNodeID() = %q, %v, want the node of the detach %v", nodeID, detached, tt.wantDetached)
+			}
+		})
+	}
+}
+
+func TestCordonedNodeDetacherPublishedNodes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	node := kubeclient.Node{Name: "node-1", LinodeID: 1003, Unschedulable: true}
+	mockNodes := mocks.NewMockNodeLookup(ctrl)
+	d := newCordonedNodeDetacher(nil, nil, mockNodes, &LinodeDriver{name: Name}, time.Minute)
+	cs := &ControllerServer{cordonedNodes: d}
+	ctx := context.Background()
+	detach := func(volumeID int) {
+		d.detached[volumeID] = cordonedNodeDetach{node: node, linodeID: 1003}
+	}
+
+	// Detached volumes are reported published while the node is cordoned
+	detach(1001)
+	mockNodes.EXPECT().LookupNode(gomock.Any(), 1003, "node-1").Return(node, nil).Times(2)
+	if got := cs.withCordonedNode(ctx, 1001, nil); fmt.Sprint(got) != "[1003]" {
+		t.Errorf("withCordonedNode() = %v, want [1003]", got)
+	}
+	if got := cs.withCordonedNode(ctx, 1001, []string{"1004"}); fmt.Sprint(got) != "[1004 1003]" {
+		t.Errorf("withCordonedNode() = %v, want [1004 1003] once attached to another node", got)
+	}
+
+	// Until unpublished from the node, but not from another node
+	d.forget(1001, 1004)
+	d.forget(1001, 1003)
+	if got := cs.withCordonedNode(ctx, 1001, nil); got != nil {
+		t.Errorf("withCordonedNode() = %v, want none once unpublished", got)
+	}
+
+	// Or until the node is uncordoned
+	detach(1002)
+	mockNodes.EXPECT().LookupNode(gomock.Any(), 1003, "node-1").Return(kubeclient.Node{Name: "node-1", LinodeID: 1003}, nil)
+	if got := cs.withCordonedNode(ctx, 1002, nil); got != nil {
+		t.Errorf("withCordonedNode() = %v, want none once uncordoned", got)
+	}
+	if _, ok := d.detached[1002]; ok {
+		t.Error("detach of volume 1002 still recorded once the node was uncordoned")
+	}
+}
+
+func TestCordonedNodeDetacherDelay(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	node := kubeclient.Node{Name: "node-1", LinodeID: 1003, Unschedulable: true}
+	checked := make(chan struct{})
+	mockKube := mocks.NewMockKubeClient(ctrl)
+	mockKube.EXPECT().ListVolumeAttachments(gomock.Any(), Name).DoAndReturn(func(context.Context, string) ([]kubeclient.VolumeAttachment, error) {
+		close(checked)
+		return nil, nil
+	})
+	mockNodes := mocks.NewMockNodeLookup(ctrl)
+	mockNodes.EXPECT().LookupNode(gomock.Any(), 1003, "node-1").Return(node, nil)
+	mockCordons := mocks.NewMockNodeCordons(ctrl)
+	var handler func(kubeclient.Node)
+	mockCordons.EXPECT().OnNodeCordoned(gomock.Any()).Do(func(h func(kubeclient.Node)) { handler = h })
+
+	d := newCordonedNodeDetacher(mockKube, mocks.NewMockLinodeClient(ctrl), mockNodes, &LinodeDriver{name: Name}, 10*time.Millisecond)
+	d.start(context.Background(), mockCordons)
+	// Cordons notified while the node is checked are ignored
+	handler(node)
+	handler(node)
+
+	select {
+	case <-checked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("cordoned node was not checked after the delay")
+	}
+}
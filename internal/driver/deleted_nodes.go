@@ -0,0 +1,143 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/linode/linodego"
+
+	kubeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/kube-client"
+	linodeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-client"
+	linodevolumes "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-volumes"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+// Results of detaching the volumes of deleted nodes, used as the "result"
+// label of the csi_deleted_node_detaches_total metric.
+const (
+	deletedNodeDetached = "detached"
+	deletedNodeFailed   = "failed"
+)
+
+// deletedNodeDetacher detaches the volumes of this driver still attached to
+// Kubernetes nodes a grace period after they were deleted.
+//
+// The attach/detach controller only detaches the volumes of a deleted node
+// once the VolumeAttachments pointing at it time out, which leaves the
+// volumes attached to a Linode instance that may be gone or about to be
+// reused, and their pods unable to start on other nodes meanwhile.
+type deletedNodeDetacher struct {
+	kube   kubeclient.KubeClient
+	client linodeclient.LinodeClient
+	driver *LinodeDriver
+	grace  time.Duration
+}
+
+func newDeletedNodeDetacher(kube kubeclient.KubeClient, client linodeclient.LinodeClient, driver *LinodeDriver, grace time.Duration) *deletedNodeDetacher {
+	return &deletedNodeDetacher{
+		kube:   kube,
+		client: client,
+		driver: driver,
+		grace:  grace,
+	}
+}
+
+// start detaches the volumes of the nodes notified by deletions, after the
+// grace period, until ctx is canceled.
+func (d *deletedNodeDetacher) start(ctx context.Context, deletions kubeclient.NodeDeletions) {
+	deletions.OnNodeDeleted(func(node kubeclient.Node) {
+		logger.GetLogger(ctx).V(2).Info("Node deleted, detaching its volumes after the grace period", "node", node.Name, "linodeID", node.LinodeID, "gracePeriod", d.grace)
+		time.AfterFunc(d.grace, func() {
+			if ctx.Err() == nil {
+				d.detachNode(ctx, node)
+			}
+		})
+	})
+}
+
+// detachNode detaches the volumes the VolumeAttachments of this driver
+// attach to node, unless the node was created again. Volumes attached to
+// another Linode instance since are left alone.
+func (d *deletedNodeDetacher) detachNode(ctx context.Context, node kubeclient.Node) {
+	log := logger.GetLogger(ctx).Klogr.WithValues("node", node.Name, "linodeID", node.LinodeID)
+
+	if _, err := d.kube.GetNodeAnnotations(ctx, node.Name); err == nil {
+		log.V(2).Info("Node created again, not detaching its volumes")
+		return
+	} else if !errors.Is(err, kubeclient.ErrNotFound) {
+		log.Error(err, "Failed to check whether the deleted node was created again")
+		return
+	}
+
+	attachments, err := d.kube.ListVolumeAttachments(ctx, d.driver.name)
+	if err != nil {
+		log.Error(err, "Failed to list the volume attachments of the deleted node")
+		return
+	}
+	attached := make(map[string]bool)
+	for _, attachment := range attachments {
+		if attachment.NodeName == node.Name && attachment.PersistentVolumeName != "" {
+			attached[attachment.PersistentVolumeName] = true
+		}
+	}
+	if len(attached) == 0 {
+		return
+	}
+
+	pvs, err := d.kube.ListPersistentVolumes(ctx, d.driver.name)
+	if err != nil {
+		log.Error(err, "Failed to list the persistent volumes of the deleted node")
+		return
+	}
+	for _, pv := range pvs {
+		if !attached[pv.Name] {
+			continue
+		}
+		if err := d.detachVolume(ctx, node, pv); err != nil {
+			log.Error(err, "Failed to detach the volume of the deleted node", "pv", pv.Name, "volumeHandle", pv.VolumeHandle)
+			observability.DeletedNodeDetachesTotal.WithLabelValues(deletedNodeFailed).Inc()
+		}
+	}
+}
+
+// detachVolume detaches the volume of pv if it is still attached to node.
+func (d *deletedNodeDetacher) detachVolume(ctx context.Context, node kubeclient.Node, pv kubeclient.PersistentVolume) error {
+	log := logger.GetLogger(ctx)
+
+	key, err := linodevolumes.ParseLinodeVolumeKey(pv.VolumeHandle)
+	if err != nil {
+		return err
+	}
+	volume, err := d.client.GetVolume(ctx, key.VolumeID)
+	if linodego.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !attachedToNode(volume, node) {
+		log.V(4).Info("Volume of the deleted node not attached to it, not detaching it", "volume_id", volume.ID, "pv", pv.Name)
+		return nil
+	}
+
+	if err := d.client.DetachVolume(ctx, volume.ID); err != nil && !linodego.IsNotFound(err) {
+		return err
+	}
+	log.V(2).Info("Volume of the deleted node detached", "volume_id", volume.ID, "pv", pv.Name, "node", node.Name)
+	observability.DeletedNodeDetachesTotal.WithLabelValues(deletedNodeDetached).Inc()
+	return nil
+}
+
+// attachedToNode reports whether volume is attached to the Linode instance
+// of node, matched by its name if the node has no provider ID.
+func attachedToNode(volume *linodego.Volume, node kubeclient.Node) bool {
+	if volume.LinodeID == nil {
+		return false
+	}
+	if node.LinodeID != 0 {
+		return *volume.LinodeID == node.LinodeID
+	}
+	return volume.LinodeLabel == node.Name
+}
@@ -0,0 +1,159 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/linode/linodego"
+	"go.uber.org/mock/gomock"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+	kubeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/kube-client"
+)
+
+func TestDeletedNodeDetacher(t *testing.T) {
+	node := kubeclient.Node{Name: "node-1", LinodeID: 1003}
+	attachments := []kubeclient.VolumeAttachment{
+		{Name: "csi-1", NodeName: "node-1", PersistentVolumeName: "pv-1", Attached: true},
+		{Name: "csi-2", NodeName: "node-2", PersistentVolumeName: "pv-2", Attached: true},
+		{Name: "csi-3", NodeName: "node-1"},
+	}
+	pvs := []kubeclient.PersistentVolume{
+		{Name: "pv-1", VolumeHandle: "1001-pv1"},
+		{Name: "pv-2", VolumeHandle: "1002-pv2"},
+	}
+	linodeID := func(id int) *int { return &id }
+
+	tests := []struct {
+		name       string
+		node       kubeclient.Node
+		setupMocks func(*mocks.MockLinodeClient, *mocks.MockKubeClient)
+	}{
+		{
+			name: "Node created again",
+			node: node,
+			setupMocks: func(m *mocks.MockLinodeClient, k *mocks.MockKubeClient) {
+				k.EXPECT().GetNodeAnnotations(gomock.Any(), "node-1").Return(map[string]string{}, nil)
+			},
+		},
+		{
+			name: "Node lookup failed",
+			node: node,
+			setupMocks: func(m *mocks.MockLinodeClient, k *mocks.MockKubeClient) {
+				k.EXPECT().GetNodeAnnotations(gomock.Any(), "node-1").Return(nil, errors.New("api error"))
+			},
+		},
+		{
+			name: "No volume attachments",
+			node: kubeclient.Node{Name: "node-3", LinodeID: 1005},
+			setupMocks: func(m *mocks.MockLinodeClient, k *mocks.MockKubeClient) {
+				k.EXPECT().GetNodeAnnotations(gomock.Any(), "node-3").Return(nil, kubeclient.ErrNotFound)
+				k.EXPECT().ListVolumeAttachments(gomock.Any(), Name).Return(attachments, nil)
+			},
+		},
+		{
+			name: "Detached",
+			node: node,
+			setupMocks: func(m *mocks.MockLinodeClient, k *mocks.MockKubeClient) {
+				k.EXPECT().GetNodeAnnotations(gomock.Any(), "node-1").Return(nil, kubeclient.ErrNotFound)
+				k.EXPECT().ListVolumeAttachments(gomock.Any(), Name).Return(attachments, nil)
+				k.EXPECT().ListPersistentVolumes(gomock.Any(), Name).Return(pvs, nil)
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(&linodego.Volume{ID: 1001, LinodeID: linodeID(1003)}, nil)
+				m.EXPECT().DetachVolume(gomock.Any(), 1001).Return(nil)
+			},
+		},
+		{
+			name: "Detached by name without provider ID",
+			node: kubeclient.Node{Name: "node-1"},
+			setupMocks: func(m *mocks.MockLinodeClient, k *mocks.MockKubeClient) {
+				k.EXPECT().GetNodeAnnotations(gomock.Any(), "node-1").Return(nil, kubeclient.ErrNotFound)
+				k.EXPECT().ListVolumeAttachments(gomock.Any(), Name).Return(attachments, nil)
+				k.EXPECT().ListPersistentVolumes(gomock.Any(), Name).Return(pvs, nil)
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(&linodego.Volume{ID: 1001, LinodeID: linodeID(1003), LinodeLabel: "node-1"}, nil)
+				m.EXPECT().DetachVolume(gomock.Any(), 1001).Return(nil)
+			},
+		},
+		{
+			name: "Attached to another linode",
+			node: node,
+			setupMocks: func(m *mocks.MockLinodeClient, k *mocks.MockKubeClient) {
+				k.EXPECT().GetNodeAnnotations(gomock.Any(), "node-1").Return(nil, kubeclient.ErrNotFound)
+				k.EXPECT().ListVolumeAttachments(gomock.Any(), Name).Return(attachments, nil)
+				k.EXPECT().ListPersistentVolumes(gomock.Any(), Name).Return(pvs, nil)
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(&linodego.Volume{ID: 1001, LinodeID: linodeID(1004)}, nil)
+			},
+		},
+		{
+			name: "Already detached",
+			node: node,
+			setupMocks: func(m *mocks.MockLinodeClient, k *mocks.MockKubeClient) {
+				k.EXPECT().GetNodeAnnotations(gomock.Any(), "node-1").Return(nil, kubeclient.ErrNotFound)
+				k.EXPECT().ListVolumeAttachments(gomock.Any(), Name).Return(attachments, nil)
+				k.EXPECT().ListPersistentVolumes(gomock.Any(), Name).Return(pvs, nil)
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(&linodego.Volume{ID: 1001}, nil)
+			},
+		},
+		{
+			name: "Volume not found",
+			node: node,
+			setupMocks: func(m *mocks.MockLinodeClient, k *mocks.MockKubeClient) {
+				k.EXPECT().GetNodeAnnotations(gomock.Any(), "node-1").Return(nil, kubeclient.ErrNotFound)
+				k.EXPECT().ListVolumeAttachments(gomock.Any(), Name).Return(attachments, nil)
+				k.EXPECT().ListPersistentVolumes(gomock.Any(), Name).Return(pvs, nil)
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(nil, &linodego.Error{Code: 404})
+			},
+		},
+		{
+			name: "Detach failed",
+			node: node,
+			setupMocks: func(m *mocks.MockLinodeClient, k *mocks.MockKubeClient) {
+				k.EXPECT().GetNodeAnnotations(gomock.Any(), "node-1").Return(nil, kubeclient.ErrNotFound)
+				k.EXPECT().ListVolumeAttachments(gomock.Any(), Name).Return(attachments, nil)
+				k.EXPECT().ListPersistentVolumes(gomock.Any(), Name).Return(pvs, nil)
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(&linodego.Volume{ID: 1001, LinodeID: linodeID(1003)}, nil)
+				m.EXPECT().DetachVolume(gomock.Any(), 1001).Return(errors.New("api error"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockClient := mocks.NewMockLinodeClient(ctrl)
+			mockKube := mocks.NewMockKubeClient(ctrl)
+			tt.setupMocks(mockClient, mockKube)
+
+			d := newDeletedNodeDetacher(mockKube, mockClient, &LinodeDriver{name: Name}, time.Minute)
+			d.detachNode(context.Background(), tt.node)
+		})
+	}
+}
+
+func TestDeletedNodeDetacherGracePeriod(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	checked := make(chan struct{})
+	mockKube := mocks.NewMockKubeClient(ctrl)
+	mockKube.EXPECT().GetNodeAnnotations(gomock.Any(), "node-1").DoAndReturn(func(context.Context, string) (map[string]string, error) {
+		close(checked)
+		return map[string]string{}, nil
+	})
+	mockDeletions := mocks.NewMockNodeDeletions(ctrl)
+	var handler func(kubeclient.Node)
+	mockDeletions.EXPECT().OnNodeDeleted(gomock.Any()).Do(func(h func(kubeclient.Node)) { handler = h })
+
+	d := newDeletedNodeDetacher(mockKube, mocks.NewMockLinodeClient(ctrl), &LinodeDriver{name: Name}, 10*time.Millisecond)
+	d.start(context.Background(), mockDeletions)
+	handler(kubeclient.Node{Name: "node-1", LinodeID: 1003})
+
+	select {
+	case <-checked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("deleted node was not checked after the grace period")
+	}
+}
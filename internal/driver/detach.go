@@ -0,0 +1,175 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/linode/linodego"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+// detach is a detach of a volume confirmed in the background.
+type detach struct {
+	linodeID int
+
+	// err is set if the volume did not detach.
+	err error
+}
+
+// detachTracker keeps track of volumes that are being detached in the
+// background, when [Options.AsyncControllerUnpublish] is enabled, and of the
+// detaches that failed until they are reported to the CO.
+//
+// The zero value is ready to use.
+type detachTracker struct {
+	mu       sync.Mutex // protects detaches
+	detaches map[int]*detach
+}
+
+// start records that volumeID is being detached from linodeID. It returns
+// false if a detach is already in progress for the volume.
+func (d *detachTracker) start(volumeID, linodeID int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if existing, ok := d.detaches[volumeID]; ok && existing.err == nil {
+		return false
+	}
+	if d.detaches == nil {
+		d.detaches = make(map[int]*detach)
+	}
+	d.detaches[volumeID] = &detach{linodeID: linodeID}
+	return true
+}
+
+// finish forgets about the detach of volumeID.
+func (d *detachTracker) finish(volumeID int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.detaches, volumeID)
+}
+
+// fail records that volumeID did not detach because of err.
+func (d *detachTracker) fail(volumeID int, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if existing, ok := d.detaches[volumeID]; ok {
+		existing.err = err
+	}
+}
+
+// inProgress reports whether volumeID is being detached, and from which
+// Linode.
+func (d *detachTracker) inProgress(volumeID int) (linodeID int, ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	existing, ok := d.detaches[volumeID]
+	if !ok || existing.err != nil {
+		return 0, false
+	}
+	return existing.linodeID, true
+}
+
+// takeFailure returns [errDetachFailed] if the last detach of volumeID
+// failed, and forgets about it so it is only reported once. It returns nil
+// otherwise.
+func (d *detachTracker) takeFailure(volumeID int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	existing, ok := d.detaches[volumeID]
+	if !ok || existing.err == nil {
+		return nil
+	}
+	delete(d.detaches, volumeID)
+	return errDetachFailed(volumeID, existing.linodeID, existing.err)
+}
+
+// reconcileDetach waits for volumeID to be detached, and then removes it from
+// the set of pending detaches. If it does not detach, the failure is kept
+// and returned by the next ControllerPublishVolume or
+// ControllerUnpublishVolume of the volume. It is meant to be run in its own
+// goroutine, after [ControllerServer.ControllerUnpublishVolume] returned.
+func (cs *ControllerServer) reconcileDetach(ctx context.Context, volumeID, linodeID int) {
+	log := logger.GetLogger(ctx)
+	log.V(4).Info("Entering reconcileDetach()", "volume_id", volumeID, "node_id", linodeID)
+	defer log.V(4).Info("Exiting reconcileDetach()")
+
+	if _, err := cs.waitForVolumeLinodeID(ctx, volumeID, nil, waitTimeout()); err != nil {
+		log.Error(err, "Volume did not detach", "volume_id", volumeID, "node_id", linodeID)
+		cs.detaches.fail(volumeID, err)
+		return
+	}
+	cs.detaches.finish(volumeID)
+	log.V(2).Info("Volume detached successfully", "volume_id", volumeID)
+}
+
+// untrackedDetachError returns the error of a detach of volumeID, still
+// attached to linodeID, that is not tracked, e.g. because it was started
+// before the controller restarted. It is found from the latest event of the
+// volume: [errDetachInProgress] if the detach is still in progress, or
+// [errDetachFailed] if it failed. It returns nil otherwise.
+func (cs *ControllerServer) untrackedDetachError(ctx context.Context, volumeID, linodeID int) error {
+	log := logger.GetLogger(ctx)
+
+	event, err := cs.latestVolumeEvent(ctx, volumeID)
+	if err != nil {
+		log.Error(err, "Failed to get the latest event of the volume", "volume_id", volumeID)
+		return nil
+	}
+	if event == nil || event.Action != linodego.ActionVolumeDetach {
+		return nil
+	}
+
+	switch event.Status {
+	case linodego.EventFinished:
+		return nil
+	case linodego.EventFailed:
+		return errDetachFailed(volumeID, linodeID, errors.New(event.Message))
+	default:
+		return errDetachInProgress(volumeID)
+	}
+}
+
+// detachInProgress reports whether volumeID, attached to linodeID, is being
+// detached, as tracked by the asynchronous ControllerUnpublishVolume or found
+// from the latest event of the volume.
+func (cs *ControllerServer) detachInProgress(ctx context.Context, volumeID, linodeID int) bool {
+	if _, ok := cs.detaches.inProgress(volumeID); ok {
+		return true
+	}
+	return status.Code(cs.untrackedDetachError(ctx, volumeID, linodeID)) == codes.Aborted
+}
+
+// waitForDeleteDetach waits for vol, attached to a Linode, to be detached so
+// that DeleteVolume can delete it, when a detach is in progress and
+// [Options.DeleteVolumeDetachWait] is set, for at most that long. It
+// returns [errVolumeInUse] if the volume is still attached.
+func (cs *ControllerServer) waitForDeleteDetach(ctx context.Context, vol *linodego.Volume) error {
+	log := logger.GetLogger(ctx)
+
+	detaching := cs.detachInProgress(ctx, vol.ID, *vol.LinodeID)
+	var wait time.Duration
+	if cs.driver != nil {
+		wait = cs.driver.opts.DeleteVolumeDetachWait
+	}
+	if !detaching || wait <= 0 {
+		return errVolumeInUse(vol, detaching)
+	}
+
+	log.V(2).Info("Waiting for the volume to detach before deleting it", "volume_id", vol.ID, "node_id", *vol.LinodeID, "wait", wait)
+	timeoutSeconds := max(int(wait.Round(time.Second).Seconds()), 1)
+	if _, err := cs.waitForVolumeLinodeID(ctx, vol.ID, nil, timeoutSeconds); err != nil {
+		log.V(2).Info("Volume did not detach in time", "volume_id", vol.ID, "node_id", *vol.LinodeID, "error", err.Error())
+		return errVolumeInUse(vol, true)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+// Kinds of flaps of the devices of staged volumes, used as the "kind" label
+// of the csi_node_device_flaps_total metric.
+const (
+	deviceFlapChanged = "changed"
+	deviceFlapMissing = "missing"
+)
+
+// flapWatchedDevice is the device a staged volume was found at.
+type flapWatchedDevice struct {
+	// link is the /dev/disk/by-id symlink of the volume, and device the
+	// kernel device it resolved to when it was last checked.
+	link   string
+	device string
+
+	// missing is true while link does not resolve.
+	missing bool
+}
+
+// deviceFlapDetector is a soak-test mode of the node plugin that
+// periodically checks that the /dev/disk/by-id symlink of each volume it
+// staged still resolves to the kernel device it resolved to when the volume
+// was staged, and logs and counts the flaps. It helps debug the host-side
+// renumbering of SCSI devices, which otherwise only shows as I/O errors in
+// the workloads.
+//
+// Only the volumes staged since the node plugin started are checked.
+type deviceFlapDetector struct {
+	interval time.Duration
+
+	mu      sync.Mutex // protects devices
+	devices map[string]*flapWatchedDevice
+}
+
+func newDeviceFlapDetector(interval time.Duration) *deviceFlapDetector {
+	return &deviceFlapDetector{
+		interval: interval,
+		devices:  make(map[string]*flapWatchedDevice),
+	}
+}
+
+// track starts checking the device of volumeID, found at link.
+func (d *deviceFlapDetector) track(ctx context.Context, volumeID, link string) {
+	device, err := filepath.EvalSymlinks(link)
+	if err != nil {
+		logger.GetLogger(ctx).Error(err, "Failed to resolve the device of the staged volume, not checking it for flaps", "volumeID", volumeID, "link", link)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.devices[volumeID] = &flapWatchedDevice{link: link, device: device}
+}
+
+// untrack stops checking the device of volumeID.
+func (d *deviceFlapDetector) untrack(volumeID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.devices, volumeID)
+}
+
+// run checks the devices of the staged volumes every interval until ctx is
+// canceled.
+func (d *deviceFlapDetector) run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.check(ctx)
+		}
+	}
+}
+
+// check logs and counts the symlinks of the staged volumes that no longer
+// resolve, or resolve to another device than when they were last checked.
+func (d *deviceFlapDetector) check(ctx context.Context) {
+	log := logger.GetLogger(ctx)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, volumeID := range slices.Sorted(maps.Keys(d.devices)) {
+		dev := d.devices[volumeID]
+		device, err := filepath.EvalSymlinks(dev.link)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			if !dev.missing {
+				log.Error(fmt.Errorf("%s no longer resolves, it resolved to %s", dev.link, dev.device), "Device of the staged volume flapped", "volumeID", volumeID)
+				observability.NodeDeviceFlapsTotal.WithLabelValues(deviceFlapMissing).Inc()
+			}
+			dev.missing = true
+		case err != nil:
+			log.Error(err, "Failed to resolve the device of the staged volume", "volumeID", volumeID, "link", dev.link)
+		case device != dev.device:
+			log.Error(fmt.Errorf("%s resolves to %s, it resolved to %s", dev.link, device, dev.device), "Device of the staged volume flapped", "volumeID", volumeID)
+			observability.NodeDeviceFlapsTotal.WithLabelValues(deviceFlapChanged).Inc()
+			dev.device, dev.missing = device, false
+		case dev.missing:
+			log.V(2).Info("Device of the staged volume is back", "volumeID", volumeID, "link", dev.link, "device", device)
+			dev.missing = false
+		}
+	}
+}
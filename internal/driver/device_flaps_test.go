@@ -0,0 +1,73 @@
+package driver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+func TestDeviceFlapDetector(t *testing.T) {
+	dir := t.TempDir()
+	for _, device := range []string{"sdb", "sdc"} {
+		if err := os.WriteFile(filepath.Join(dir, device), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	link := filepath.Join(dir, "scsi-0Linode_Volume_pvc1")
+	relink := func(device string) {
+		t.Helper()
+		if err := os.Remove(link); err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		if device != "" {
+			if err := os.Symlink(filepath.Join(dir, device), link); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	ctx := context.Background()
+	d := newDeviceFlapDetector(0)
+	relink("sdb")
+	d.track(ctx, "1001-pvc1", link)
+
+	steps := []struct {
+		name        string
+		device      string
+		wantChanged float64
+		wantMissing float64
+	}{
+		{name: "Unchanged", device: "sdb"},
+		{name: "Missing", wantMissing: 1},
+		{name: "Still missing", wantMissing: 1},
+		{name: "Back", device: "sdb", wantMissing: 1},
+		{name: "Changed", device: "sdc", wantChanged: 1, wantMissing: 1},
+		{name: "Unchanged since", device: "sdc", wantChanged: 1, wantMissing: 1},
+	}
+
+	changed := testutil.ToFloat64(observability.NodeDeviceFlapsTotal.WithLabelValues(deviceFlapChanged))
+	missing := testutil.ToFloat64(observability.NodeDeviceFlapsTotal.WithLabelValues(deviceFlapMissing))
+	for _, step := range steps {
+		relink(step.device)
+		d.check(ctx)
+		if got := testutil.ToFloat64(observability.NodeDeviceFlapsTotal.WithLabelValues(deviceFlapChanged)) - changed; got != step.wantChanged {
+			t.Errorf("%s: changed flaps = %v, want %v", step.name, got, step.wantChanged)
+		}
+		if got := testutil.ToFloat64(observability.NodeDeviceFlapsTotal.WithLabelValues(deviceFlapMissing)) - missing; got != step.wantMissing {
+			t.Errorf("%s: missing flaps = %v, want %v", step.name, got, step.wantMissing)
+		}
+	}
+
+	// Unstaged volumes are no longer checked
+	d.untrack("1001-pvc1")
+	relink("")
+	d.check(ctx)
+	if got := testutil.ToFloat64(observability.NodeDeviceFlapsTotal.WithLabelValues(deviceFlapMissing)) - missing; got != 1 {
+		t.Errorf("missing flaps after untrack = %v, want 1", got)
+	}
+}
@@ -0,0 +1,123 @@
+package driver
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+// Operations holding a device, reported to the requests waiting for it.
+const (
+	deviceOpStage   = "stage"
+	deviceOpUnstage = "unstage"
+	deviceOpExpand  = "expand"
+)
+
+// deviceLockTimeout is how long an operation waits for the operations of
+// other requests on the same device before failing with Aborted.
+var deviceLockTimeout = 30 * time.Second
+
+// deviceLocks serializes the operations of the node on the same device
+// across requests: staging a volume, from finding its device to resizing its
+// file system, unstaging it, and expanding it. [volumeLocks] only
+// serializes the requests for the same volume ID, while a device such as
+// /dev/sdb is reused by another volume once detached, and NodeExpandVolume
+// must not return while the file system is being resized.
+//
+// Devices are identified by the path their symlinks resolve to, and the
+// device last held for each volume is remembered until the volume is
+// unstaged.
+//
+// The zero value is ready to use.
+type deviceLocks struct {
+	mu sync.Mutex // protects the fields below
+	// held are the operations holding the devices, by device.
+	held map[string]*deviceHold
+	// devices are the devices last held for the volumes, by volume ID.
+	devices map[string]string
+}
+
+// deviceHold is an operation holding a device.
+type deviceHold struct {
+	op       string
+	volumeID string
+	// released is closed once the operation released the device.
+	released chan struct{}
+}
+
+// acquire holds device for the operation op of volumeID, once the other
+// operations holding it released it, and returns the function releasing it.
+// It fails with Aborted if the device is not released within
+// [deviceLockTimeout], or with the error of ctx if ctx is done first.
+func (d *deviceLocks) acquire(ctx context.Context, device, volumeID, op string) (func(), error) {
+	log := logger.GetLogger(ctx)
+	device = canonicalDevice(device)
+	timeout := time.NewTimer(deviceLockTimeout)
+	defer timeout.Stop()
+
+	for {
+		d.mu.Lock()
+		hold, busy := d.held[device]
+		if !busy {
+			hold = &deviceHold{op: op, volumeID: volumeID, released: make(chan struct{})}
+			if d.held == nil {
+				d.held = make(map[string]*deviceHold)
+				d.devices = make(map[string]string)
+			}
+			d.held[device] = hold
+			d.devices[volumeID] = device
+			d.mu.Unlock()
+			return func() { d.release(device, hold) }, nil
+		}
+		d.mu.Unlock()
+
+		log.V(4).Info("Waiting for the device", "device", device, "volumeID", volumeID, "op", op, "heldBy", hold.op, "heldFor", hold.volumeID)
+		select {
+		case <-hold.released:
+		case <-timeout.C:
+			return nil, errDeviceBusy(device, hold.op, hold.volumeID)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (d *deviceLocks) release(device string, hold *deviceHold) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.held[device] == hold {
+		delete(d.held, device)
+	}
+	close(hold.released)
+}
+
+// acquireVolume holds the device last held for volumeID, if any, for the
+// operation op, as acquire does.
+func (d *deviceLocks) acquireVolume(ctx context.Context, volumeID, op string) (func(), error) {
+	d.mu.Lock()
+	device, ok := d.devices[volumeID]
+	d.mu.Unlock()
+	if !ok {
+		return func() {}, nil
+	}
+	return d.acquire(ctx, device, volumeID, op)
+}
+
+// forget forgets the device last held for volumeID, once it is unstaged.
+func (d *deviceLocks) forget(volumeID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.devices, volumeID)
+}
+
+// canonicalDevice returns the path the symlinks of device resolve to, or
+// device if it cannot be resolved.
+func canonicalDevice(device string) string {
+	if resolved, err := filepath.EvalSymlinks(device); err == nil {
+		return resolved
+	}
+	return device
+}
@@ -0,0 +1,139 @@
+package driver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestDeviceLocksInterleaved(t *testing.T) {
+	const volumeID = "1001-pvc1"
+	var d deviceLocks
+	ctx := context.Background()
+
+	// Operations of the requests for the volume, and of another volume
+	// reusing its device, each checking no other one holds the device
+	var holders, overlaps atomic.Int32
+	run := func(acquire func() (func(), error)) {
+		release, err := acquire()
+		if err != nil {
+			t.Errorf("acquire() error = %v", err)
+			return
+		}
+		if holders.Add(1) > 1 {
+			overlaps.Add(1)
+		}
+		time.Sleep(time.Millisecond)
+		holders.Add(-1)
+		release()
+	}
+	stage := func(volumeID string) {
+		run(func() (func(), error) { return d.acquire(ctx, "/dev/sdb", volumeID, deviceOpStage) })
+	}
+	unstage := func(volumeID string) {
+		run(func() (func(), error) { return d.acquireVolume(ctx, volumeID, deviceOpUnstage) })
+	}
+	expand := func(volumeID string) {
+		run(func() (func(), error) { return d.acquireVolume(ctx, volumeID, deviceOpExpand) })
+	}
+
+	stage(volumeID)
+	stage("1002-pvc2")
+	var wg sync.WaitGroup
+	for range 10 {
+		for _, op := range []func(string){stage, unstage, expand} {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				op(volumeID)
+			}()
+			go func() {
+				defer wg.Done()
+				op("1002-pvc2")
+			}()
+		}
+	}
+	wg.Wait()
+
+	if overlaps.Load() != 0 {
+		t.Errorf("%d operations held the device at the same time as another", overlaps.Load())
+	}
+	if len(d.held) != 0 {
+		t.Errorf("devices still held: %v", d.held)
+	}
+}
+
+func TestDeviceLocksTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { deviceLockTimeout = timeout }(deviceLockTimeout)
+	deviceLockTimeout = 10 * time.Millisecond
+
+	var d deviceLocks
+	ctx := context.Background()
+	release, err := d.acquire(ctx, "/dev/sdb", "1001-pvc1", deviceOpStage)
+	if err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+
+	// Waiters give up after the timeout, or once their request is done
+	if _, err := d.acquireVolume(ctx, "1001-pvc1", deviceOpExpand); status.Code(err) != codes.Aborted {
+		t.Errorf("acquireVolume() error = %v, want code %v", err, codes.Aborted)
+	}
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := d.acquire(canceled, "/dev/sdb", "1002-pvc2", deviceOpStage); err != context.Canceled {
+		t.Errorf("acquire() error = %v, want %v", err, context.Canceled)
+	}
+
+	// The device is held again once released
+	release()
+	release, err = d.acquire(ctx, "/dev/sdb", "1002-pvc2", deviceOpStage)
+	if err != nil {
+		t.Fatalf("acquire() error = %v once released", err)
+	}
+	release()
+
+	// Volumes are not waited for once unstaged
+	d.forget("1002-pvc2")
+	release, err = d.acquire(ctx, "/dev/sdb", "1001-pvc1", deviceOpStage)
+	if err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+	defer release()
+	if release, err := d.acquireVolume(ctx, "1002-pvc2", deviceOpUnstage); err != nil {
+		t.Errorf("acquireVolume() of a forgotten volume error = %v", err)
+	} else {
+		release()
+	}
+}
+
+func TestDeviceLocksSymlinks(t *testing.T) {
+	defer func(timeout time.Duration) { deviceLockTimeout = timeout }(deviceLockTimeout)
+	deviceLockTimeout = 10 * time.Millisecond
+
+	dir := t.TempDir()
+	device := filepath.Join(dir, "sdb")
+	if err := os.WriteFile(device, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "linode-pvc1")
+	if err := os.Symlink(device, link); err != nil {
+		t.Fatal(err)
+	}
+
+	var d deviceLocks
+	release, err := d.acquire(context.Background(), link, "1001-pvc1", deviceOpStage)
+	if err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+	defer release()
+	if _, err := d.acquire(context.Background(), device, "1002-pvc2", deviceOpStage); status.Code(err) != codes.Aborted {
+		t.Errorf("acquire() of the device a held symlink resolves to error = %v, want code %v", err, codes.Aborted)
+	}
+}
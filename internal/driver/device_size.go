@@ -0,0 +1,52 @@
+package driver
+
+import (
+	"context"
+
+	"github.com/linode/linodego"
+
+	linodevolumes "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-volumes"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+// deviceSizeTolerance is how much smaller than their volume devices may be
+// with [Options.StrictDeviceSize], e.g. for the partition table of
+// [PartitionAuto] volumes.
+const deviceSizeTolerance = 16 << 20 // 16MiB
+
+// checkDeviceSize fails if devicePath, the device of the volume of key or of
+// its [PartitionAuto] partition, is smaller than the volume in the Linode
+// API, when [Options.StrictDeviceSize] is enabled. The devices of numbered
+// partitions, which may be much smaller than their volume, and the volumes
+// being resized are not checked, nor the devices of volumes whose size could
+// not be found, so that staging does not depend on the Linode API.
+func (ns *NodeServer) checkDeviceSize(ctx context.Context, key linodevolumes.LinodeVolumeKey, devicePath, partition string) error {
+	if ns.driver == nil || !ns.driver.opts.StrictDeviceSize || ns.client == nil {
+		return nil
+	}
+	if partition != "" && partition != PartitionAuto {
+		return nil
+	}
+	log := logger.GetLogger(ctx)
+
+	volume, err := ns.client.GetVolume(ctx, key.VolumeID)
+	if err != nil {
+		log.Error(err, "Failed to get the volume, not checking the size of its device", "volume_id", key.VolumeID)
+		return nil
+	}
+	if volume.Status != linodego.VolumeActive {
+		log.V(4).Info("Volume not active, not checking the size of its device", "volume_id", key.VolumeID, "status", volume.Status)
+		return nil
+	}
+
+	deviceBytes, err := blockDeviceSize(devicePath)
+	if err != nil {
+		return errInternal("get size of device %s: %v", devicePath, err)
+	}
+	volumeBytes := gbToBytes(volume.Size)
+	if deviceBytes >= volumeBytes-deviceSizeTolerance {
+		return nil
+	}
+	return ns.driver.enforce(ctx, validationDeviceSize, errDeviceTooSmall(devicePath, deviceBytes, volumeBytes),
+		"volume_id", key.VolumeID, "devicePath", devicePath, "deviceBytes", deviceBytes, "volumeBytes", volumeBytes)
+}
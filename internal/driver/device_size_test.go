@@ -0,0 +1,102 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/linode/linodego"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+	linodevolumes "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-volumes"
+)
+
+func TestCheckDeviceSize(t *testing.T) {
+	const devicePath = "/dev/disk/by-id/linode-pvc1"
+
+	tests := []struct {
+		name        string
+		opts        Options
+		partition   string
+		volume      *linodego.Volume
+		volumeErr   error
+		deviceBytes int64
+		wantCode    codes.Code
+	}{
+		{
+			name:        "Same size",
+			opts:        Options{StrictDeviceSize: true},
+			volume:      &linodego.Volume{ID: 1001, Size: 20, Status: linodego.VolumeActive},
+			deviceBytes: 20 << 30,
+		},
+		{
+			name:        "Smaller device",
+			opts:        Options{StrictDeviceSize: true},
+			volume:      &linodego.Volume{ID: 1001, Size: 20, Status: linodego.VolumeActive},
+			deviceBytes: 10 << 30,
+			wantCode:    codes.FailedPrecondition,
+		},
+		{
+			name:        "Smaller device in warn mode",
+			opts:        Options{StrictDeviceSize: true, EnforcementMode: EnforcementWarn},
+			volume:      &linodego.Volume{ID: 1001, Size: 20, Status: linodego.VolumeActive},
+			deviceBytes: 10 << 30,
+		},
+		{
+			name:        "Automatic partition within the tolerance",
+			opts:        Options{StrictDeviceSize: true},
+			partition:   PartitionAuto,
+			volume:      &linodego.Volume{ID: 1001, Size: 20, Status: linodego.VolumeActive},
+			deviceBytes: 20<<30 - 2<<20,
+		},
+		{
+			name:      "Numbered partition",
+			opts:      Options{StrictDeviceSize: true},
+			partition: "2",
+		},
+		{
+			name:        "Volume being resized",
+			opts:        Options{StrictDeviceSize: true},
+			volume:      &linodego.Volume{ID: 1001, Size: 40, Status: linodego.VolumeResizing},
+			deviceBytes: 20 << 30,
+		},
+		{
+			name:      "Volume not found",
+			opts:      Options{StrictDeviceSize: true},
+			volumeErr: errors.New("api error"),
+		},
+		{
+			name: "Disabled",
+		},
+	}
+
+	defer func(size func(string) (int64, error)) { blockDeviceSize = size }(blockDeviceSize)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockClient := mocks.NewMockLinodeClient(ctrl)
+			if tt.volume != nil || tt.volumeErr != nil {
+				mockClient.EXPECT().GetVolume(gomock.Any(), 1001).Return(tt.volume, tt.volumeErr)
+			}
+			blockDeviceSize = func(path string) (int64, error) {
+				if path != devicePath {
+					t.Errorf("blockDeviceSize(%q), want %q", path, devicePath)
+				}
+				return tt.deviceBytes, nil
+			}
+
+			ns := &NodeServer{driver: &LinodeDriver{opts: tt.opts}, client: mockClient}
+			key := linodevolumes.CreateLinodeVolumeKey(1001, "pvc1")
+			err := ns.checkDeviceSize(context.Background(), key, devicePath, tt.partition)
+			if status.Code(err) != tt.wantCode {
+				t.Errorf("checkDeviceSize() error = %v, want code %v", err, tt.wantCode)
+			}
+		})
+	}
+}
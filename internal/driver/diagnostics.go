@@ -0,0 +1,118 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/linode/linodego"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+const (
+	// diagnosticsTimeout bounds the requests gathering diagnostics, which
+	// are sent after the request they diagnose timed out.
+	diagnosticsTimeout = 30 * time.Second
+
+	// diagnosticEvents is the number of recent events of each entity
+	// included in diagnostics.
+	diagnosticEvents = 10
+)
+
+// attachDiagnostics is the state of a volume stuck attaching to an instance,
+// logged in a single record to help understand why.
+type attachDiagnostics struct {
+	Volume         *volumeDiagnostics   `json:"volume,omitempty"`
+	Instance       *instanceDiagnostics `json:"instance,omitempty"`
+	VolumeEvents   []diagnosticEvent    `json:"volumeEvents,omitempty"`
+	InstanceEvents []diagnosticEvent    `json:"instanceEvents,omitempty"`
+
+	// Errors lists the diagnostics that could not be gathered.
+	Errors []string `json:"errors,omitempty"`
+}
+
+type volumeDiagnostics struct {
+	Status   linodego.VolumeStatus `json:"status"`
+	LinodeID *int                  `json:"linodeID"`
+	Region   string                `json:"region"`
+	Updated  *time.Time            `json:"updated,omitempty"`
+}
+
+type instanceDiagnostics struct {
+	Status linodego.InstanceStatus `json:"status"`
+	Region string                  `json:"region"`
+}
+
+type diagnosticEvent struct {
+	Action  linodego.EventAction `json:"action"`
+	Status  linodego.EventStatus `json:"status"`
+	Created *time.Time           `json:"created,omitempty"`
+	Message string               `json:"message,omitempty"`
+}
+
+// logAttachTimeout logs why waiting for the volume with volumeID to be
+// attached to the instance with linodeID failed with err, along with the
+// state of the volume and instance and their recent events, and counts the
+// timeout. The diagnostics are gathered on a best-effort basis.
+func (cs *ControllerServer) logAttachTimeout(ctx context.Context, err error, volumeID, linodeID int) {
+	observability.AttachTimeoutTotal.Inc()
+
+	// The request may be out of time, but the diagnostics are still useful
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), diagnosticsTimeout)
+	defer cancel()
+
+	var diag attachDiagnostics
+	if vol, err := cs.linodeClient(ctx).GetVolume(ctx, volumeID); err != nil {
+		diag.Errors = append(diag.Errors, fmt.Sprintf("get volume: %v", err))
+	} else {
+		diag.Volume = &volumeDiagnostics{Status: vol.Status, LinodeID: vol.LinodeID, Region: vol.Region, Updated: vol.Updated}
+	}
+	if instance, err := cs.linodeClient(ctx).GetInstance(ctx, linodeID); err != nil {
+		diag.Errors = append(diag.Errors, fmt.Sprintf("get instance: %v", err))
+	} else {
+		diag.Instance = &instanceDiagnostics{Status: instance.Status, Region: instance.Region}
+	}
+
+	var eventsErr error
+	diag.VolumeEvents, eventsErr = cs.recentEvents(ctx, linodego.EntityVolume, volumeID)
+	if eventsErr != nil {
+		diag.Errors = append(diag.Errors, fmt.Sprintf("list volume events: %v", eventsErr))
+	}
+	diag.InstanceEvents, eventsErr = cs.recentEvents(ctx, linodego.EntityLinode, linodeID)
+	if eventsErr != nil {
+		diag.Errors = append(diag.Errors, fmt.Sprintf("list instance events: %v", eventsErr))
+	}
+
+	logger.GetLogger(ctx).Error(err, "Timed out waiting for volume to attach", "volume_id", volumeID, "node_id", linodeID, "diagnostics", diag)
+}
+
+// recentEvents returns the last [diagnosticEvents] events of the entity of
+// entityType with id, most recent first.
+func (cs *ControllerServer) recentEvents(ctx context.Context, entityType linodego.EntityType, id int) ([]diagnosticEvent, error) {
+	jsonFilter, err := json.Marshal(map[string]any{
+		"entity.type": entityType,
+		"entity.id":   id,
+		"+order_by":   "created",
+		"+order":      "desc",
+	})
+	if err != nil {
+		return nil, err
+	}
+	events, err := cs.linodeClient(ctx).ListEvents(ctx, &linodego.ListOptions{
+		PageOptions: &linodego.PageOptions{Page: 1},
+		PageSize:    minListPageSize,
+		Filter:      string(jsonFilter),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	recent := make([]diagnosticEvent, 0, min(len(events), diagnosticEvents))
+	for _, event := range events[:min(len(events), diagnosticEvents)] {
+		recent = append(recent, diagnosticEvent{Action: event.Action, Status: event.Status, Created: event.Created, Message: event.Message})
+	}
+	return recent, nil
+}
@@ -0,0 +1,70 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/linode/linodego"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/mock/gomock"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+func TestLogAttachTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mocks.NewMockLinodeClient(ctrl)
+	mockClient.EXPECT().GetVolume(gomock.Any(), 1001).Return(&linodego.Volume{ID: 1001, Status: linodego.VolumeActive}, nil)
+	mockClient.EXPECT().GetInstance(gomock.Any(), 1003).Return(nil, errors.New("API error"))
+	mockClient.EXPECT().ListEvents(gomock.Any(), &linodego.ListOptions{
+		PageOptions: &linodego.PageOptions{Page: 1},
+		PageSize:    minListPageSize,
+		Filter:      `{"+order":"desc","+order_by":"created","entity.id":1001,"entity.type":"volume"}`,
+	}).Return([]linodego.Event{{Action: linodego.ActionVolumeAttach, Status: linodego.EventFailed}}, nil)
+	mockClient.EXPECT().ListEvents(gomock.Any(), &linodego.ListOptions{
+		PageOptions: &linodego.PageOptions{Page: 1},
+		PageSize:    minListPageSize,
+		Filter:      `{"+order":"desc","+order_by":"created","entity.id":1003,"entity.type":"linode"}`,
+	}).Return(nil, nil)
+
+	// The diagnostics are gathered even though the request timed out
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cs := &ControllerServer{client: mockClient}
+	before := testutil.ToFloat64(observability.AttachTimeoutTotal)
+	cs.logAttachTimeout(ctx, context.DeadlineExceeded, 1001, 1003)
+	if got := testutil.ToFloat64(observability.AttachTimeoutTotal) - before; got != 1 {
+		t.Errorf("expected 1 attach timeout to be counted, got %v", got)
+	}
+}
+
+func TestRecentEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	events := make([]linodego.Event, minListPageSize)
+	for i := range events {
+		events[i] = linodego.Event{ID: i, Action: linodego.ActionVolumeAttach, Status: linodego.EventFinished, Message: "attached"}
+	}
+	mockClient := mocks.NewMockLinodeClient(ctrl)
+	mockClient.EXPECT().ListEvents(gomock.Any(), gomock.Any()).Return(events, nil)
+
+	cs := &ControllerServer{client: mockClient}
+	got, err := cs.recentEvents(context.Background(), linodego.EntityVolume, 1001)
+	if err != nil {
+		t.Fatalf("recentEvents() error = %v", err)
+	}
+	if len(got) != diagnosticEvents {
+		t.Errorf("recentEvents() returned %d events, want %d", len(got), diagnosticEvents)
+	}
+	want := diagnosticEvent{Action: linodego.ActionVolumeAttach, Status: linodego.EventFinished, Message: "attached"}
+	if !reflect.DeepEqual(got[0], want) {
+		t.Errorf("recentEvents()[0] = %+v, want %+v", got[0], want)
+	}
+}
@@ -16,11 +16,15 @@ limitations under the License.
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"maps"
 	"regexp"
 	"strconv"
 	"sync"
+	"text/template"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"google.golang.org/grpc/codes"
@@ -28,6 +32,7 @@ import (
 	"k8s.io/mount-utils"
 
 	devicemanager "github.com/linode/linode-blockstorage-csi-driver/pkg/device-manager"
+	kubeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/kube-client"
 	linodeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-client"
 	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
 	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
@@ -51,18 +56,355 @@ type LinodeDriver struct {
 	cscap []*csi.ControllerServiceCapability
 	nscap []*csi.NodeServiceCapability
 
-	readyMu       sync.Mutex // protects ready
-	ready         bool
+	// capabilities are the capabilities of the regions and account,
+	// discovered at startup. It is nil if they could not be discovered.
+	capabilities *linodeclient.Capabilities
+
+	readyMu sync.Mutex // protects ready
+	ready   bool
+
+	// conditions are the readiness conditions of the plugin, reported by
+	// the identity service.
+	conditions readinessConditions
+
 	enableMetrics string
 	metricsPort   string
 	enableTracing string
 	tracingPort   string
+
+	opts Options
+}
+
+// Options configures optional driver behavior. The zero value keeps the
+// default behavior.
+type Options struct {
+	// AsyncControllerUnpublish makes ControllerUnpublishVolume return as soon
+	// as the Linode API accepted the detach request, instead of waiting for
+	// the volume to be detached. The detach is confirmed in the background,
+	// and attaching the volume fails until it completes. A detach that fails
+	// is reported by the next ControllerPublishVolume or
+	// ControllerUnpublishVolume of the volume, so the CO retries it.
+	AsyncControllerUnpublish bool
+
+	// DeleteVolumeDetachWait is how long DeleteVolume waits for a volume
+	// still attached to be detached, when a detach of it is in progress, e.g.
+	// since the PersistentVolume was deleted while its ControllerUnpublishVolume
+	// was running. It fails right away if it is zero, and the CO retries it.
+	DeleteVolumeDetachWait time.Duration
+
+	// DefaultFSType is the file system volumes are formatted with when
+	// neither the volume capability nor the StorageClass specify one. If
+	// empty, ext4 is used.
+	DefaultFSType string
+
+	// DefaultMountOptions are added to the mount options of every volume
+	// staged on the node. Mount flags set in the StorageClass or
+	// PersistentVolume come after them, so they take precedence.
+	DefaultMountOptions []string
+
+	// ReadOnlyNoRecovery makes the node plugin mount the volumes staged
+	// read-only with norecovery, so that the file systems of snapshots and
+	// clones that were not cleanly unmounted can be mounted without
+	// replaying their journal. The changes left in the journal are then
+	// missing from the mounted file system.
+	ReadOnlyNoRecovery bool
+
+	// AllowFSMismatchMount makes the node plugin mount the volumes holding
+	// another supported file system than the one they are staged with, e.g.
+	// an xfs volume of a StorageClass changed to ext4, with the file system
+	// they hold. They fail to stage otherwise.
+	AllowFSMismatchMount bool
+
+	// StrictDeviceSize makes the node plugin refuse to stage the volumes
+	// whose device is smaller than their size in the Linode API, e.g. a
+	// stale attachment or the device of another volume, before a file
+	// system is created, checked or grown on it. The refusal is subject to
+	// the EnforcementMode.
+	StrictDeviceSize bool
+
+	// RegistrationSocket is the path of the socket node-driver-registrar
+	// registers the node plugin with the kubelet through, as mounted in the
+	// node plugin. The node plugin removes it when it is terminated, before
+	// it stops serving, so that the kubelet deregisters the driver. It is
+	// left alone if empty.
+	RegistrationSocket string
+
+	// KubeClient is used to write the usage of volumes staged on the node
+	// to their PersistentVolumeClaims every VolumeUsageReportInterval.
+	// Usage is not reported if either is unset.
+	KubeClient                kubeclient.KubeClient
+	VolumeUsageReportInterval time.Duration
+
+	// AnnotateCloneVerification makes the node plugin write the result of
+	// the verification of cloned volumes to the
+	// [CloneVerificationAnnotation] of their PersistentVolume, with
+	// KubeClient. Verified clones are then not checked again.
+	AnnotateCloneVerification bool
+
+	// AttachConfigFromNodeAnnotation makes ControllerPublishVolume attach
+	// volumes to the configuration profile whose ID is set in the
+	// [AttachConfigAnnotation] of the Kubernetes node named after the
+	// Linode instance, read with KubeClient. Volumes are attached to the
+	// instance's current configuration profile when the annotation is not
+	// set.
+	AttachConfigFromNodeAnnotation bool
+
+	// NodeLookup, if set, is used instead of KubeClient to find the
+	// Kubernetes node of a Linode instance for
+	// AttachConfigFromNodeAnnotation. Nodes are then matched by their
+	// provider ID before their name, from a cache of the nodes rather than
+	// with a request to the API server on each ControllerPublishVolume.
+	// KubeClient is still used until the nodes were listed.
+	NodeLookup kubeclient.NodeLookup
+
+	// DeletedNodeDetachGracePeriod is how long after a Kubernetes node
+	// notified by NodeDeletions was deleted the controller detaches the
+	// volumes that the VolumeAttachments of the driver still attach to it,
+	// unless the node was created again, instead of waiting for the
+	// attach/detach controller to time out. Volumes of deleted nodes are
+	// not detached if it is zero, or if KubeClient or NodeDeletions is not
+	// set.
+	DeletedNodeDetachGracePeriod time.Duration
+	NodeDeletions                kubeclient.NodeDeletions
+
+	// CordonedNodeReadOnlyDetachDelay is how long after a Kubernetes node
+	// notified by NodeCordons was cordoned the controller starts detaching
+	// the read-only volumes that the VolumeAttachments of the driver attach
+	// to it, as soon as no pod of the node uses them, instead of waiting for
+	// the attach/detach controller. Read-only volumes of cordoned nodes are
+	// not detached if it is zero, or if KubeClient, NodeLookup or
+	// NodeCordons is not set.
+	CordonedNodeReadOnlyDetachDelay time.Duration
+	NodeCordons                     kubeclient.NodeCordons
+
+	// ListVolumesRegions and ListVolumesTag restrict the volumes returned
+	// by ListVolumes to those in one of the given regions, and with the
+	// given tag. The filtering is done by the Linode API. All the volumes
+	// of the account are listed when they are unset.
+	ListVolumesRegions []string
+	ListVolumesTag     string
+
+	// VolumeFailureBackoff makes CreateVolume and ControllerPublishVolume
+	// fail with Unavailable, without trying, for the volumes whose previous
+	// requests failed, for a delay starting at VolumeFailureBackoff and
+	// doubling with each consecutive failure, up to 10 minutes. Requests
+	// are not refused when it is zero.
+	VolumeFailureBackoff time.Duration
+
+	// Topology is whether the controller advertises the
+	// VOLUME_ACCESSIBILITY_CONSTRAINTS capability and creates volumes in the
+	// region of the topology requirements of their request, and what it
+	// does with the requests without one. The zero value is [TopologyOn].
+	Topology TopologyMode
+
+	// AllowedRegions makes CreateVolume fail with InvalidArgument when the
+	// region of the volume, from the topology requirements of the request
+	// or the region of the controller, is not one of them. Volumes can be
+	// created in any region when it is unset.
+	AllowedRegions []string
+
+	// ExcludedDiskLabels and ExcludedDiskFilesystems are the labels and file
+	// systems (e.g. "swap") of the instance disks that are not subtracted
+	// from the number of volumes that can be attached to an instance, for
+	// deployments whose instances do not use some of their disks. All disks
+	// are subtracted when they are unset.
+	ExcludedDiskLabels      []string
+	ExcludedDiskFilesystems []string
+
+	// PersistedAttachments is what ControllerPublishVolume does with the
+	// volumes already attached to the instance that persist across its
+	// boots: nothing, report them, or remove them from its configuration
+	// profiles. The zero value does nothing.
+	PersistedAttachments PersistedAttachmentMode
+
+	// FeatureTelemetry makes CreateVolume count the features used by the
+	// volumes it provisions in the csi_feature_usage_total metric. The
+	// counts are only exported through the metrics endpoint.
+	FeatureTelemetry bool
+
+	// RejectLegacyVolumeIDs makes controller requests fail when their volume
+	// ID is not a volume key, instead of hashing it into a Linode volume ID
+	// that could belong to an unrelated volume.
+	RejectLegacyVolumeIDs bool
+
+	// AccountVolumeLimit is the number of volumes the Linode account may
+	// have. When it is set, CreateVolume counts the volumes of the account
+	// and fails with ResourceExhausted instead of creating a volume over
+	// the limit, recording a warning event on the claim with KubeClient.
+	AccountVolumeLimit int
+
+	// Tracing configures the export of the traces when tracing is enabled.
+	// The endpoint defaults to the otel-collector service on the tracing
+	// port, and the spans are tagged with the ClusterName if it is set.
+	Tracing observability.TracingConfig
+
+	// ClusterName identifies the cluster among the clusters sharing the
+	// Linode account. When it is set, the labels of the volumes created by
+	// the driver end with a short hash of it, so that clusters with the
+	// same volume label prefix do not create volumes with the same label,
+	// and the volumes are tagged with [ClusterTagPrefix] and the hash.
+	ClusterName string
+
+	// PreviousVolumeLabelPrefix is the volume label prefix the driver had
+	// before it was changed. CreateVolume returns the volume created for
+	// the request with a label made with it, if any, instead of creating
+	// another one with the label made with the current prefix, e.g. for a
+	// request retried across the change. Volumes are only looked up by
+	// their label made with the current prefix if it is empty.
+	PreviousVolumeLabelPrefix string
+
+	// VolumeNameTemplate makes the labels of the volumes the controller
+	// creates, from a [VolumeNameTemplateData], instead of the volume label
+	// prefix followed by the name of the PersistentVolume. The labels end
+	// with a short hash of the name of the PersistentVolume, so that they
+	// are unique. Labels are not made from a template if it is nil.
+	VolumeNameTemplate *template.Template
+
+	// EnforcementMode is what the driver does with the requests failing the
+	// validations introduced by recent releases: refusing legacy volume IDs
+	// with RejectLegacyVolumeIDs, refusing to stage devices holding
+	// unexpected data, smaller than their volume with StrictDeviceSize, or
+	// needing missing node dependencies, and refusing to
+	// publish volumes under mounts not shared with the pods. In
+	// [EnforcementWarn] mode they are only logged and counted in the
+	// csi_validation_failures_total metric, so that the validations can be
+	// observed before they are enforced. The zero value enforces them.
+	EnforcementMode EnforcementMode
+
+	// VolumeLabelSyncInterval is how often the controller renames the
+	// Linode volumes of the PersistentVolumes with the
+	// [SyncVolumeLabelAnnotation] after them, listed with KubeClient.
+	// Volumes are not renamed if either is unset.
+	VolumeLabelSyncInterval time.Duration
+
+	// CrossNamespaceClones is whether CreateVolume clones volumes whose
+	// claim is in another namespace than the claim of the new volume.
+	// Source claims are found with KubeClient, and cross-namespace clones
+	// allowed by ReferenceGrants under
+	// [CrossNamespaceClonesReferenceGrant]. The zero value allows them.
+	CrossNamespaceClones CrossNamespaceClonePolicy
+
+	// MountWatchdogInterval is how often the node plugin checks that the
+	// file system volumes it staged and published are still mounted, and
+	// mounts again those whose mounts vanished, recording events on their
+	// PersistentVolumeClaims with KubeClient if it is set. The mounts are
+	// not checked if it is zero.
+	MountWatchdogInterval time.Duration
+
+	// DeviceFlapCheckInterval is how often the node plugin checks, as a
+	// soak test, that the /dev/disk/by-id symlinks of the volumes it staged
+	// still resolve to the devices they resolved to when the volumes were
+	// staged, logging and counting the flaps. The devices are not checked
+	// if it is zero.
+	DeviceFlapCheckInterval time.Duration
+
+	// HalfCreatedVolumes is what the controller does at startup with the
+	// volumes that CreateVolume created but never saw active, tagged with
+	// the [ProvisioningTag] more than HalfCreatedVolumeAge ago (an hour if
+	// it is zero): nothing, report them, finish them, or delete those no
+	// PersistentVolume refers to. The zero value does nothing.
+	HalfCreatedVolumes   HalfCreatedVolumeMode
+	HalfCreatedVolumeAge time.Duration
+
+	// Sharding shares the volumes between the replicas of the controller,
+	// which hold Leases in ShardNamespace with KubeClient, renewed every
+	// third of ShardLeaseDuration (15 seconds if it is zero). The replica is
+	// named ShardIdentity, e.g. after its pod, and serves the RPCs forwarded
+	// by the other replicas at ShardAddress in [ShardingProxy] mode, over
+	// mutual TLS with ShardTLS, as returned by [LoadShardTLS], and
+	// authenticated with ShardToken. RPCs are not forwarded without
+	// ShardTLS. The zero value does not share them.
+	Sharding           ShardingMode
+	ShardNamespace     string
+	ShardIdentity      string
+	ShardAddress       string
+	ShardLeaseDuration time.Duration
+	ShardToken         string
+	ShardTLS           *tls.Config
+
+	// OrphanCleanup is what the node plugin does at startup with the
+	// staging mounts and LUKS mappings of the driver whose volumes are no
+	// longer attached to the node: nothing, report them, or clean them up.
+	// The zero value does nothing.
+	OrphanCleanup OrphanCleanupMode
+
+	// RPCTimeouts overrides, by CSI method name, the maximum durations of
+	// the requests, e.g. 10 minutes for CreateVolume and 2 minutes for
+	// NodeStageVolume, which apply whatever the deadline set by the caller.
+	// Requests exceeding them fail with DeadlineExceeded. A maximum of 0
+	// lets the requests of the method run until the deadline of the caller.
+	RPCTimeouts map[string]time.Duration
+
+	// LUKSHeaderBackup stores the LUKS header of the volumes the node plugin
+	// formats with LUKS, right after they are formatted, so that a corrupted
+	// header can be restored with the linode-csi luks restore-header
+	// command. Headers are not backed up when it is nil.
+	LUKSHeaderBackup LUKSHeaderSink
+
+	// CgroupRoot is the mount point of the cgroup v2 hierarchy of the node
+	// in the node plugin, where it finds the cgroups of the pods whose
+	// volumes have throttling parameters to limit their I/O. It is
+	// /sys/fs/cgroup when empty.
+	CgroupRoot string
+
+	// AccountEventsInterval is how often the controller polls the volume
+	// events of the Linode account, from the latest one it polled. The
+	// waits of the controller for volumes to be active, attached or
+	// detached then get the volume when one of its events happens rather
+	// than polling it, and the volume conditions are described by the
+	// events polled. Events are not polled if it is zero.
+	AccountEventsInterval time.Duration
+
+	// AccountEventsAddress is the address the controller accepts the events
+	// of the Linode account pushed to it on, at [AccountEventsPath], e.g.
+	// by a relay of the events of the account, from clients sending
+	// AccountEventsToken as a bearer token. They are used like the polled
+	// events. Events are not accepted if it is empty.
+	AccountEventsAddress string
+	AccountEventsToken   string
+
+	// TokenCheckInterval is how often the controller checks that the Linode
+	// API accepts its token, LinodeToken, and when the token expires, which
+	// it finds among the tokens of the profile of the token when the token
+	// may list them. The results are exported as metrics, and a warning is
+	// logged once the token expires within [tokenExpiryWarning]. The token
+	// is not checked if it is zero.
+	TokenCheckInterval time.Duration
+	LinodeToken        string
+
+	// NewLinodeClient creates a Linode client using a token. It is used for
+	// the CreateVolume, DeleteVolume and ControllerExpandVolume requests
+	// whose secrets have a [LinodeTokenSecretKey], which fail if it is not
+	// set.
+	NewLinodeClient func(token string) (linodeclient.LinodeClient, error)
+
+	// LogVerbosityAddress is the loopback address the verbosity of the logs
+	// is served on and changed at, at [LogVerbosityPath], e.g.
+	// 127.0.0.1:9810, so that a component can be debugged without
+	// restarting the driver. It is not served if it is empty.
+	LogVerbosityAddress string
 }
 
 // MaxVolumeLabelPrefixLength is the maximum allowed length of a volume label
 // prefix.
 const MaxVolumeLabelPrefixLength = 12
 
+// ValidateVolumeLabelPrefix fails if prefix is not a valid volume label
+// prefix.
+func ValidateVolumeLabelPrefix(prefix string) error {
+	if r := []rune(prefix); len(r) > MaxVolumeLabelPrefixLength {
+		return fmt.Errorf("volume label prefix is too long: length=%d max=%d", len(r), MaxVolumeLabelPrefixLength)
+	}
+	matched, err := regexp.MatchString(`^[0-9A-Za-z_-]{0,`+strconv.Itoa(MaxVolumeLabelPrefixLength)+`}$`, prefix)
+	if err != nil {
+		return fmt.Errorf("invalid regexp pattern: %w", err)
+	}
+	if !matched {
+		return errors.New("volume label prefix may only contain: [A-Za-z0-9_-]")
+	}
+	return nil
+}
+
 func GetLinodeDriver(ctx context.Context) *LinodeDriver {
 	log, _, done := logger.GetLogger(ctx).WithMethod("GetLinodeDriver")
 	defer done()
@@ -91,6 +433,7 @@ func (linodeDriver *LinodeDriver) SetupLinodeDriver(
 	metricsPort string,
 	enableTracing string,
 	tracingPort string,
+	opts Options,
 ) error {
 	log, _, done := logger.GetLogger(ctx).WithMethod("SetupLinodeDriver")
 	defer done()
@@ -105,24 +448,62 @@ func (linodeDriver *LinodeDriver) SetupLinodeDriver(
 	linodeDriver.vendorVersion = vendorVersion
 
 	log.V(3).Info("Validating volume label prefix", "prefix", volumeLabelPrefix)
-	if r := []rune(volumeLabelPrefix); len(r) > MaxVolumeLabelPrefixLength {
-		return fmt.Errorf("volume label prefix is too long: length=%d max=%d", len(r), MaxVolumeLabelPrefixLength)
-	}
-	matched, err := regexp.MatchString(`^[0-9A-Za-z_-]{0,`+strconv.Itoa(MaxVolumeLabelPrefixLength)+`}$`, volumeLabelPrefix)
+	err := ValidateVolumeLabelPrefix(volumeLabelPrefix)
 	if err != nil {
-		return fmt.Errorf("invalid regexp pattern: %w", err)
+		return err
 	}
-	if !matched {
-		return errors.New("volume label prefix may only contain: [A-Za-z0-9_-]")
+	if err = ValidateVolumeLabelPrefix(opts.PreviousVolumeLabelPrefix); err != nil {
+		return fmt.Errorf("previous %w", err)
 	}
 	linodeDriver.volumeLabelPrefix = volumeLabelPrefix
 
+	if err = validateLogVerbosityAddress(opts.LogVerbosityAddress); err != nil {
+		return err
+	}
+	if opts.DefaultFSType != "" && !supportedFSType(opts.DefaultFSType) {
+		return fmt.Errorf("unsupported default file system type %q, must be one of %v", opts.DefaultFSType, supportedFSTypes)
+	}
+	linodeDriver.opts = opts
+	log.V(2).Info("Enabled features", "version", vendorVersion, "features", linodeDriver.enabledFeatures(), "featuresHash", linodeDriver.featuresHash())
+
+	log.V(2).Info("Discovering the capabilities of the regions and account")
+	if linodeDriver.capabilities, err = linodeclient.DiscoverCapabilities(ctx, linodeClient); err != nil {
+		// The regions are then requested whenever their capabilities are
+		// needed
+		log.Error(err, "Failed to discover capabilities")
+	}
+
+	linodeDriver.checkMetadata(ctx, metadata)
+
 	log.V(2).Info("Setting up RPC Servers")
 	linodeDriver.ns, err = NewNodeServer(ctx, linodeDriver, mounter, deviceUtils, linodeClient, metadata, encrypt)
 	if err != nil {
 		return fmt.Errorf("new node server: %w", err)
 	}
 
+	if opts.KubeClient != nil && opts.VolumeUsageReportInterval > 0 {
+		log.V(2).Info("Enabling volume usage reporting", "interval", opts.VolumeUsageReportInterval)
+		linodeDriver.ns.usage = newUsageReporter(opts.KubeClient, opts.VolumeUsageReportInterval)
+	}
+	if opts.MountWatchdogInterval > 0 {
+		log.V(2).Info("Enabling the mount watchdog", "interval", opts.MountWatchdogInterval)
+		linodeDriver.ns.watchdog = newMountWatchdog(opts.KubeClient, opts.MountWatchdogInterval)
+	}
+	if opts.DeviceFlapCheckInterval > 0 {
+		log.V(2).Info("Enabling the device flap soak test", "interval", opts.DeviceFlapCheckInterval)
+		linodeDriver.ns.flaps = newDeviceFlapDetector(opts.DeviceFlapCheckInterval)
+	}
+
+	linodeDriver.ns.selfTest = runNodeSelfTest(ctx, mounter.Exec, encrypt.FileSystem)
+	if missing := linodeDriver.ns.selfTest.missing(); len(missing) > 0 {
+		log.V(2).Info("Some node dependencies are missing", "missing", missing)
+	}
+	linodeDriver.applyNodeSelfTest(ctx)
+
+	// Before any volume is staged, so that the mounts and mappings of the
+	// volumes being staged are not mistaken for orphans
+	linodeDriver.ns.cleanupOrphans(ctx, opts.OrphanCleanup)
+
 	linodeDriver.ids, err = NewIdentityServer(ctx, linodeDriver)
 	if err != nil {
 		return fmt.Errorf("new identity server: %w", err)
@@ -134,6 +515,36 @@ func (linodeDriver *LinodeDriver) SetupLinodeDriver(
 	}
 	linodeDriver.cs = cs
 
+	if opts.AccountEventsInterval > 0 || opts.AccountEventsAddress != "" {
+		log.V(2).Info("Enabling account events", "interval", opts.AccountEventsInterval, "address", opts.AccountEventsAddress)
+		cs.events = newAccountEvents(cs.client)
+	}
+
+	if opts.TokenCheckInterval > 0 && cs.client != nil {
+		log.V(2).Info("Enabling token check", "interval", opts.TokenCheckInterval)
+		cs.tokenCheck = newTokenCheck(cs.client, opts.LinodeToken, opts.TokenCheckInterval)
+	}
+
+	if opts.KubeClient != nil && opts.VolumeLabelSyncInterval > 0 {
+		log.V(2).Info("Enabling volume label sync", "interval", opts.VolumeLabelSyncInterval)
+		cs.labelSync = newVolumeLabelSyncer(opts.KubeClient, linodeClient, linodeDriver, opts.VolumeLabelSyncInterval)
+	}
+
+	if opts.KubeClient != nil && opts.NodeDeletions != nil && opts.DeletedNodeDetachGracePeriod > 0 {
+		log.V(2).Info("Enabling the detach of the volumes of deleted nodes", "gracePeriod", opts.DeletedNodeDetachGracePeriod)
+		cs.deletedNodes = newDeletedNodeDetacher(opts.KubeClient, cs.client, linodeDriver, opts.DeletedNodeDetachGracePeriod)
+	}
+
+	if opts.KubeClient != nil && opts.NodeLookup != nil && opts.NodeCordons != nil && opts.CordonedNodeReadOnlyDetachDelay > 0 {
+		log.V(2).Info("Enabling the detach of the read-only volumes of cordoned nodes", "delay", opts.CordonedNodeReadOnlyDetachDelay)
+		cs.cordonedNodes = newCordonedNodeDetacher(opts.KubeClient, cs.client, opts.NodeLookup, linodeDriver, opts.CordonedNodeReadOnlyDetachDelay)
+	}
+
+	if opts.KubeClient != nil && opts.Sharding != "" && opts.Sharding != ShardingOff {
+		log.V(2).Info("Enabling controller sharding", "mode", opts.Sharding, "identity", opts.ShardIdentity, "address", opts.ShardAddress)
+		cs.shards = newVolumeShards(opts.KubeClient, linodeDriver.name, opts)
+	}
+
 	// Set observability config
 	linodeDriver.enableMetrics = enableMetrics
 	linodeDriver.metricsPort = metricsPort
@@ -143,14 +554,46 @@ func (linodeDriver *LinodeDriver) SetupLinodeDriver(
 	linodeDriver.tracingPort = tracingPort
 
 	if linodeDriver.enableTracing == True {
-		observability.InitTracer(ctx, "linode-csi-driver", linodeDriver.vendorVersion, linodeDriver.tracingPort)
-		observability.SkipObservability = false
+		tracing := opts.Tracing
+		if tracing.Endpoint == "" {
+			tracing.Endpoint = "otel-collector:" + linodeDriver.tracingPort
+		}
+		if opts.ClusterName != "" {
+			tracing.Attributes = maps.Clone(tracing.Attributes)
+			if tracing.Attributes == nil {
+				tracing.Attributes = make(map[string]string)
+			}
+			tracing.Attributes["k8s.cluster.name"] = opts.ClusterName
+		}
+		if err := observability.InitTracer(ctx, "linode-csi-driver", linodeDriver.vendorVersion, tracing); err != nil {
+			return fmt.Errorf("init tracing: %w", err)
+		}
 	}
 
 	log.V(2).Info("LinodeDriver setup completed successfully")
 	return nil
 }
 
+// regionSupports reports whether region has capability, from the
+// capabilities discovered at startup. known is false if they were not
+// discovered, or do not include the region.
+func (linodeDriver *LinodeDriver) regionSupports(region, capability string) (supported, known bool) {
+	if linodeDriver == nil {
+		return false, false
+	}
+	return linodeDriver.capabilities.RegionSupports(region, capability)
+}
+
+// regionsSupporting returns up to limit regions with capability, those in
+// the same country as near first, from the capabilities discovered at
+// startup.
+func (linodeDriver *LinodeDriver) regionsSupporting(capability, near string, limit int) []string {
+	if linodeDriver == nil {
+		return nil
+	}
+	return linodeDriver.capabilities.RegionsSupporting(capability, near, limit)
+}
+
 func (linodeDriver *LinodeDriver) ValidateControllerServiceRequest(ctx context.Context, rpcType csi.ControllerServiceCapability_RPC_Type) error {
 	log, _, done := logger.GetLogger(ctx).WithMethod("ValidateControllerServiceRequest")
 	defer done()
@@ -172,6 +615,8 @@ func (linodeDriver *LinodeDriver) ValidateControllerServiceRequest(ctx context.C
 	return status.Error(codes.InvalidArgument, "Invalid controller service request")
 }
 
+// Run serves the CSI services on endpoint until ctx is canceled, then
+// shuts the driver down.
 func (linodeDriver *LinodeDriver) Run(ctx context.Context, endpoint string) {
 	log, _, done := logger.GetLogger(ctx).WithMethod("Run")
 	defer done()
@@ -181,15 +626,67 @@ func (linodeDriver *LinodeDriver) Run(ctx context.Context, endpoint string) {
 		log.V(4).Info("BS Volume Prefix", "prefix", linodeDriver.volumeLabelPrefix)
 	}
 
+	// The driver is not ready if the node is missing the tools needed to
+	// stage volumes.
 	linodeDriver.readyMu.Lock()
-	linodeDriver.ready = true
+	linodeDriver.ready = linodeDriver.ns.canStage()
 	linodeDriver.readyMu.Unlock()
 
+	if linodeDriver.ns.usage != nil {
+		go linodeDriver.ns.usage.run(ctx)
+	}
+	if linodeDriver.ns.watchdog != nil {
+		go linodeDriver.ns.watchMounts(ctx)
+	}
+	if linodeDriver.ns.flaps != nil {
+		go linodeDriver.ns.flaps.run(ctx)
+	}
+	if linodeDriver.cs.labelSync != nil {
+		go linodeDriver.cs.labelSync.run(ctx)
+	}
+	if linodeDriver.cs.tokenCheck != nil {
+		go linodeDriver.cs.tokenCheck.run(ctx)
+	}
+	if linodeDriver.cs.deletedNodes != nil {
+		linodeDriver.cs.deletedNodes.start(ctx, linodeDriver.opts.NodeDeletions)
+	}
+	if linodeDriver.cs.cordonedNodes != nil {
+		linodeDriver.cs.cordonedNodes.start(ctx, linodeDriver.opts.NodeCordons)
+	}
+	if linodeDriver.cs.events != nil {
+		if linodeDriver.opts.AccountEventsInterval > 0 {
+			go linodeDriver.cs.events.run(ctx, linodeDriver.opts.AccountEventsInterval)
+		}
+		if linodeDriver.opts.AccountEventsAddress != "" {
+			go linodeDriver.cs.events.serve(ctx, linodeDriver.opts.AccountEventsAddress, linodeDriver.opts.AccountEventsToken)
+		}
+	}
+	if linodeDriver.cs.client != nil && linodeDriver.opts.HalfCreatedVolumes != "" && linodeDriver.opts.HalfCreatedVolumes != HalfCreatedVolumesOff {
+		go linodeDriver.cs.reconcileHalfCreatedVolumes(ctx, linodeDriver.opts.HalfCreatedVolumes, linodeDriver.opts.HalfCreatedVolumeAge)
+	}
+	if linodeDriver.cs.shards != nil {
+		go linodeDriver.cs.shards.run(ctx, linodeDriver.cs)
+	}
+	if linodeDriver.opts.LogVerbosityAddress != "" {
+		go serveLogVerbosity(ctx, linodeDriver.opts.LogVerbosityAddress)
+	}
+	if linodeDriver.cs.client != nil {
+		go linodeDriver.watchAPI(ctx, linodeDriver.cs.client, linodeDriver.cs.metadata.Region, apiCheckInterval)
+	}
+
 	log.V(2).Info("Starting non-blocking GRPC server")
 	s := NewNonBlockingGRPCServer()
 	s.SetMetricsConfig(linodeDriver.enableMetrics, linodeDriver.metricsPort)
+	s.SetRPCTimeouts(linodeDriver.opts.RPCTimeouts)
+	if linodeDriver.cs.shards != nil {
+		s.SetControllerInterceptor(linodeDriver.cs.shards.interceptor)
+	}
 	s.Start(endpoint, linodeDriver.ids, linodeDriver.cs, linodeDriver.ns)
 	log.V(2).Info("GRPC server started successfully")
+	go func() {
+		<-ctx.Done()
+		linodeDriver.shutdown(ctx, s)
+	}()
 	s.Wait()
 	log.V(2).Info("LinodeDriver run completed")
 }
@@ -11,6 +11,7 @@ import (
 
 	"github.com/linode/linode-blockstorage-csi-driver/mocks"
 	linodeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-client"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
 )
 
 var (
@@ -30,16 +31,21 @@ func TestDriverSuite(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
+	mockExec := mocks.NewMockExecutor(mockCtrl)
 	mounter := &mount.SafeFormatAndMount{
 		Interface: mocks.NewMockMounter(mockCtrl),
-		Exec:      mocks.NewMockExecutor(mockCtrl),
+		Exec:      mockExec,
 	}
 	deviceUtils := mocks.NewMockDeviceUtils(mockCtrl)
 	fileSystem := mocks.NewMockFileSystem(mockCtrl)
+
+	// Node self-test
+	mockExec.EXPECT().LookPath(gomock.Any()).Return("", nil).AnyTimes()
+	fileSystem.EXPECT().Stat(gomock.Any()).Return(nil, nil).AnyTimes()
 	cryptSetup := mocks.NewMockCryptSetupClient(mockCtrl)
 	encrypt := NewLuksEncryption(mounter.Exec, fileSystem, cryptSetup)
 
-	fakeCloudProvider, err := linodeclient.NewLinodeClient("dummy", fmt.Sprintf("LinodeCSI/%s", vendorVersion), "")
+	fakeCloudProvider, err := linodeclient.NewLinodeClient("dummy", fmt.Sprintf("LinodeCSI/%s", vendorVersion), "", linodeclient.Transport{}, linodeclient.DebugDump{})
 	if err != nil {
 		t.Fatalf("Failed to setup Linode client: %s", err)
 	}
@@ -57,7 +63,10 @@ func TestDriverSuite(t *testing.T) {
 	metricsPort := "10251"
 	enableTracing := "true"
 	tracingPort := "4318"
-	if err := linodeDriver.SetupLinodeDriver(context.Background(), fakeCloudProvider, mounter, deviceUtils, md, driver, vendorVersion, bsPrefix, encrypt, enableMetrics, metricsPort, enableTracing, tracingPort); err != nil {
+	// Enabling tracing changes how the controller waits for volumes: keep it
+	// disabled for the tests run after this one
+	defer func(skip bool) { observability.SkipObservability = skip }(observability.SkipObservability)
+	if err := linodeDriver.SetupLinodeDriver(context.Background(), fakeCloudProvider, mounter, deviceUtils, md, driver, vendorVersion, bsPrefix, encrypt, enableMetrics, metricsPort, enableTracing, tracingPort, Options{}); err != nil {
 		t.Fatalf("Failed to setup Linode Driver: %v", err)
 	}
 
@@ -0,0 +1,94 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+// EnforcementMode is what the driver does when a request fails one of the
+// validations rolled out with [Options.EnforcementMode].
+type EnforcementMode string
+
+const (
+	// EnforcementEnforce fails the requests that do not pass the
+	// validations. It is the default.
+	EnforcementEnforce EnforcementMode = "enforce"
+
+	// EnforcementWarn logs and counts the requests that would fail the
+	// validations, and handles them as if the validations did not exist.
+	EnforcementWarn EnforcementMode = "warn"
+)
+
+// ParseEnforcementMode parses an enforcement mode. The empty string is
+// [EnforcementEnforce].
+func ParseEnforcementMode(s string) (EnforcementMode, error) {
+	switch mode := EnforcementMode(s); mode {
+	case "":
+		return EnforcementEnforce, nil
+	case EnforcementEnforce, EnforcementWarn:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid enforcement mode %q, must be %q or %q", s, EnforcementEnforce, EnforcementWarn)
+	}
+}
+
+// Validations subject to the enforcement mode, used as the "validation"
+// label of the csi_validation_failures_total metric.
+const (
+	// validationLegacyVolumeID refuses volume IDs that are not volume keys,
+	// when [Options.RejectLegacyVolumeIDs] is enabled.
+	validationLegacyVolumeID = "legacy_volume_id"
+
+	// validationDeviceFormat refuses to stage devices holding data other
+	// than the file system of the volume.
+	validationDeviceFormat = "device_format"
+
+	// validationDeviceSize refuses to stage devices smaller than their
+	// volume, when [Options.StrictDeviceSize] is enabled.
+	validationDeviceSize = "device_size"
+
+	// validationNodeDependencies refuses to stage volumes needing tools or
+	// kernel modules the self-test of the node plugin did not find.
+	validationNodeDependencies = "node_dependencies"
+
+	// validationMountPropagation refuses to publish volumes under mounts
+	// that do not propagate them to the pods.
+	validationMountPropagation = "mount_propagation"
+
+	// validationCapacityRange refuses to create volumes whose capacity
+	// range has a required size above the limit, or allows no size of
+	// volume.
+	validationCapacityRange = "capacity_range"
+
+	// validationStorageClass refuses the StorageClasses whose parameters
+	// would make provisioning fail, in the [StorageClassValidator].
+	validationStorageClass = "storage_class"
+)
+
+// enforce returns err, the failure of validation, unless the driver runs in
+// [EnforcementWarn] mode, in which case the failure is only logged with
+// keysAndValues. Failures are counted in both modes. A nil driver enforces
+// every validation.
+func (linodeDriver *LinodeDriver) enforce(ctx context.Context, validation string, err error, keysAndValues ...any) error {
+	if err == nil {
+		return nil
+	}
+
+	mode := EnforcementEnforce
+	if linodeDriver != nil && linodeDriver.opts.EnforcementMode == EnforcementWarn {
+		mode = EnforcementWarn
+	}
+	observability.ValidationFailuresTotal.WithLabelValues(validation, string(mode)).Inc()
+
+	log := logger.GetLogger(ctx)
+	keysAndValues = append([]any{"validation", validation}, keysAndValues...)
+	if mode == EnforcementWarn {
+		log.V(0).Info("Request would fail validation, not enforced in warn mode", append(keysAndValues, "error", err.Error())...)
+		return nil
+	}
+	log.Error(err, "Request failed validation", keysAndValues...)
+	return err
+}
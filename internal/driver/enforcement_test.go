@@ -0,0 +1,95 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+func TestParseEnforcementMode(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    EnforcementMode
+		wantErr bool
+	}{
+		{value: "", want: EnforcementEnforce},
+		{value: "enforce", want: EnforcementEnforce},
+		{value: "warn", want: EnforcementWarn},
+		{value: "audit", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseEnforcementMode(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseEnforcementMode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseEnforcementMode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnforce(t *testing.T) {
+	failure := errors.New("validation failed")
+
+	tests := []struct {
+		name    string
+		driver  *LinodeDriver
+		err     error
+		wantErr error
+		counted string
+	}{
+		{
+			name:   "Passed",
+			driver: &LinodeDriver{},
+		},
+		{
+			name:    "Enforced",
+			driver:  &LinodeDriver{},
+			err:     failure,
+			wantErr: failure,
+			counted: string(EnforcementEnforce),
+		},
+		{
+			name:    "Nil driver",
+			err:     failure,
+			wantErr: failure,
+			counted: string(EnforcementEnforce),
+		},
+		{
+			name:    "Warn",
+			driver:  &LinodeDriver{opts: Options{EnforcementMode: EnforcementWarn}},
+			err:     failure,
+			counted: string(EnforcementWarn),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := map[string]float64{}
+			for _, mode := range []EnforcementMode{EnforcementEnforce, EnforcementWarn} {
+				before[string(mode)] = testutil.ToFloat64(observability.ValidationFailuresTotal.WithLabelValues("test", string(mode)))
+			}
+
+			if err := tt.driver.enforce(context.Background(), "test", tt.err); !errors.Is(err, tt.wantErr) {
+				t.Errorf("enforce() error = %v, want %v", err, tt.wantErr)
+			}
+
+			for mode, count := range before {
+				want := 0.0
+				if mode == tt.counted {
+					want = 1
+				}
+				if got := testutil.ToFloat64(observability.ValidationFailuresTotal.WithLabelValues("test", mode)) - count; got != want {
+					t.Errorf("%s failures counted = %v, want %v", mode, got, want)
+				}
+			}
+		})
+	}
+}
@@ -1,9 +1,19 @@
 package driver
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/linode/linodego"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
 // Errors that are returned from RPC methods.
@@ -12,7 +22,6 @@ var (
 	errNilDriver            = status.Error(codes.Internal, "nil driver")
 	errNoVolumeName         = status.Error(codes.InvalidArgument, "volume name is required")
 	errNoVolumeCapabilities = status.Error(codes.InvalidArgument, "volume capabilities are required")
-	errVolumeInUse          = status.Error(codes.FailedPrecondition, "volume is in use")
 	errNoVolumeCapability   = status.Error(codes.InvalidArgument, "no volume capability set")
 	errNoVolumeID           = status.Error(codes.InvalidArgument, "volume id is not set")
 	errNoVolumePath         = status.Error(codes.InvalidArgument, "volume path is not set")
@@ -27,13 +36,6 @@ var (
 	// function.
 	errNilInstance = errInternal("nil instance")
 
-	// errMaxAttachments is used to indicate that the maximum number of attachments
-	// for a Linode instance has already been reached.
-	//
-	// If you want to return an error that includes the maximum number of
-	// attachments allowed for the instance, call errMaxVolumeAttachments.
-	errMaxAttachments = status.Error(codes.ResourceExhausted, "max number of volumes already attached to instance")
-
 	// errResizeDown indicates a request would result in a volume being resized
 	// to be smaller than it currently is.
 	//
@@ -61,8 +63,97 @@ func errRegionMismatch(gotRegion, wantRegion string) error {
 	return status.Errorf(codes.InvalidArgument, "source volume is in region %q, needs to be in region %q", gotRegion, wantRegion)
 }
 
-func errMaxVolumeAttachments(numAttachments int) error {
-	return status.Errorf(codes.ResourceExhausted, "max number of volumes (%d) already attached to instance", numAttachments)
+// errRegionNotAllowed returns an error indicating volumes cannot be created
+// in region, which is not one of the allowed regions.
+func errRegionNotAllowed(region string, allowedRegions []string) error {
+	return status.Errorf(codes.InvalidArgument, "volumes cannot be created in region %q, allowed regions: %s", region, strings.Join(allowedRegions, ", "))
+}
+
+// errNoSupportedTopologyRegion indicates none of regions, from the topology
+// requirement of a CreateVolume request, supports block storage.
+func errNoSupportedTopologyRegion(regions []string) error {
+	return status.Errorf(codes.InvalidArgument, "none of the regions of the topology requirement supports block storage: %s", strings.Join(regions, ", "))
+}
+
+// errNoTopologyRegion indicates a CreateVolume request has no region in its
+// topology requirement, with [TopologyRequired], which refuses to create its
+// volume in controllerRegion.
+func errNoTopologyRegion(controllerRegion string) error {
+	return status.Errorf(codes.InvalidArgument, "the topology requirement has no %s segment, and volumes are not created in the region of the controller (%s) without one: enable the Topology feature gate of the external provisioner, or set allowedTopologies on the StorageClass", VolumeTopologyRegion, controllerRegion)
+}
+
+// errInvalidIOThrottle indicates value, of the throttling parameter key, is
+// not a positive integer.
+func errInvalidIOThrottle(key, value string) error {
+	return status.Errorf(codes.InvalidArgument, "invalid %s %q: must be a positive integer", key, value)
+}
+
+// errReservedVolumeTag indicates tag, set with the [VolumeTags] parameter, is
+// the tag of a volume attribute managed by the driver.
+func errReservedVolumeTag(tag string) error {
+	return status.Errorf(codes.InvalidArgument, "volume tag %q is reserved for the attributes the driver stores on volumes", tag)
+}
+
+// errUnsupportedMutableParameter indicates key, a parameter of a
+// VolumeAttributesClass, is not one of the [mutableParameters].
+func errUnsupportedMutableParameter(key string) error {
+	return status.Errorf(codes.InvalidArgument, "parameter %q cannot be set by a VolumeAttributesClass, mutable parameters: %s", key, strings.Join(mutableParameters, ", "))
+}
+
+// errMaxVolumeAttachments indicates no more volumes can be attached to
+// instance, which has attached volumes and can have at most limit. They are
+// also given, with the type of the instance, as the ErrorInfo details of the
+// status.
+func errMaxVolumeAttachments(instance *linodego.Instance, limit, attached int) error {
+	st := status.Newf(codes.ResourceExhausted, "max number of volumes (%d) already attached to linode %d of type %s: %d volumes attached", limit, instance.ID, instance.Type, attached)
+	info := &errdetails.ErrorInfo{
+		Reason: "MAX_VOLUME_ATTACHMENTS",
+		Domain: Name,
+		Metadata: map[string]string{
+			"linodeID":     strconv.Itoa(instance.ID),
+			"instanceType": instance.Type,
+			"limit":        strconv.Itoa(limit),
+			"attached":     strconv.Itoa(attached),
+		},
+	}
+	if withInfo, err := st.WithDetails(info); err == nil {
+		st = withInfo
+	}
+	return st.Err()
+}
+
+// errVolumeInUse indicates vol cannot be deleted since it is attached to a
+// Linode, which is detaching it if detaching is true. The Linode is also
+// given as the ErrorInfo details of the status.
+func errVolumeInUse(vol *linodego.Volume, detaching bool) error {
+	linode := strconv.Itoa(*vol.LinodeID)
+	if vol.LinodeLabel != "" {
+		linode += " (" + vol.LinodeLabel + ")"
+	}
+	msg := fmt.Sprintf("volume %d is in use: attached to linode %s", vol.ID, linode)
+	if detaching {
+		msg += ", detach in progress"
+	}
+	st := status.New(codes.FailedPrecondition, msg)
+	info := &errdetails.ErrorInfo{
+		Reason: "VOLUME_IN_USE",
+		Domain: Name,
+		Metadata: map[string]string{
+			"linodeID":    strconv.Itoa(*vol.LinodeID),
+			"linodeLabel": vol.LinodeLabel,
+			"detaching":   strconv.FormatBool(detaching),
+		},
+	}
+	if withInfo, err := st.WithDetails(info); err == nil {
+		st = withInfo
+	}
+	return st.Err()
+}
+
+// errAccountVolumeLimit indicates creating a volume would exceed the
+// [Options.AccountVolumeLimit] of the account, which has count volumes.
+func errAccountVolumeLimit(count, limit int) error {
+	return status.Errorf(codes.ResourceExhausted, "account already has %d volumes, the limit is %d", count, limit)
 }
 
 func errInstanceNotFound(linodeID int) error {
@@ -73,14 +164,237 @@ func errVolumeAttached(volumeID, linodeID int) error {
 	return status.Errorf(codes.AlreadyExists, "volume %d is already attached to linode %d", volumeID, linodeID)
 }
 
+// errLegacyVolumeID indicates volumeID is not a volume key, and
+// [Options.RejectLegacyVolumeIDs] is enabled.
+func errLegacyVolumeID(volumeID string) error {
+	return status.Errorf(codes.InvalidArgument, "volume ID %q is not a volume key, and legacy volume IDs are rejected", volumeID)
+}
+
+// errInvalidAttachConfig indicates the [AttachConfigAnnotation] of node is
+// not a valid configuration profile ID.
+func errInvalidAttachConfig(node, value string) error {
+	return status.Errorf(codes.FailedPrecondition, "invalid %s annotation %q on node %s", AttachConfigAnnotation, value, node)
+}
+
+// errDetachInProgress indicates volumeID is still being detached in the
+// background, and cannot be attached yet.
+func errDetachInProgress(volumeID int) error {
+	return status.Errorf(codes.Aborted, "detach in progress for volume %d", volumeID)
+}
+
+// errDetachFailed indicates volumeID did not detach from linodeID after
+// ControllerUnpublishVolume accepted the detach, because of err.
+func errDetachFailed(volumeID, linodeID int, err error) error {
+	return status.Errorf(codes.FailedPrecondition, "detach of volume %d from linode %d failed: %v", volumeID, linodeID, err)
+}
+
+// errCloneInProgress indicates volumeID is still being cloned from sourceID,
+// so CreateVolume is retried until the clone is active.
+func errCloneInProgress(volumeID, sourceID int) error {
+	return status.Errorf(codes.Aborted, "volume %d is still being cloned from volume %d", volumeID, sourceID)
+}
+
+// errVolumeProvisioning indicates volumeID, created by CreateVolume, is not
+// active yet, so CreateVolume is retried until it is.
+func errVolumeProvisioning(volumeID int) error {
+	return status.Errorf(codes.Aborted, "volume %d is still being created", volumeID)
+}
+
+// errOperationInProgress indicates another operation is already being
+// performed on volumeID.
+func errOperationInProgress(volumeID string) error {
+	return status.Errorf(codes.Aborted, "an operation is already in progress for volume %q", volumeID)
+}
+
+// errDeviceBusy indicates device is still held by the operation op of
+// volumeID, which another operation waited too long for.
+func errDeviceBusy(device, op, volumeID string) error {
+	return status.Errorf(codes.Aborted, "device %s is busy with the %s of volume %q", device, op, volumeID)
+}
+
+// errMissingNodeDependencies indicates feature cannot be used on the node,
+// because the given tools or kernel modules are missing.
+func errMissingNodeDependencies(feature string, dependencies ...string) error {
+	return status.Errorf(codes.FailedPrecondition, "%s is not supported on this node, missing: %v", feature, dependencies)
+}
+
+// errUnexpectedDeviceFormat indicates the device at source already holds
+// data other than a fsType file system, such as another file system, a LUKS
+// header or a partition table, so it is neither formatted nor mounted. The
+// error tells how to mount volumes holding another supported file system.
+func errUnexpectedDeviceFormat(source, format, fsType string) error {
+	if supportedFSType(format) {
+		return status.Errorf(codes.FailedPrecondition, "device %s holds a %s file system instead of %s, e.g. since the fsType of its StorageClass changed, refusing to format or mount it: set the fsType back to %s, or set ALLOW_FS_MISMATCH_MOUNT on the node plugin to mount it as %s", source, format, fsType, format, format)
+	}
+	return status.Errorf(codes.FailedPrecondition, "device %s already holds %q instead of a %s file system, refusing to format or mount it", source, format, fsType)
+}
+
+// errMaintenance indicates the Linode API is in read-only maintenance mode,
+// and the request should be retried after retryAfter, also given as the
+// RetryInfo details of the status.
+func errMaintenance(retryAfter time.Duration) error {
+	retryAfter = retryAfter.Round(time.Second)
+	st := status.Newf(codes.Unavailable, "linode API is in read-only maintenance mode, retry after %s", retryAfter)
+	if withInfo, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)}); err == nil {
+		st = withInfo
+	}
+	return st.Err()
+}
+
+// errVolumeBackoff indicates the requests for the volume of key are refused
+// for retryAfter, also given as the RetryInfo details of the status, after
+// failures consecutive failures.
+func errVolumeBackoff(key backoffKey, failures int, retryAfter time.Duration) error {
+	retryAfter = retryAfter.Round(time.Second)
+	st := status.Newf(codes.Unavailable, "%s of volume %s failed %d times in a row, retry after %s", key.operation, key.volume, failures, retryAfter)
+	if withInfo, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)}); err == nil {
+		st = withInfo
+	}
+	return st.Err()
+}
+
+// errInstanceMigrating indicates volumes cannot be attached to the instance
+// with linodeID, which is migrating as shown by reason, until retryAfter,
+// also given as the RetryInfo details of the status.
+func errInstanceMigrating(linodeID int, reason string, retryAfter time.Duration) error {
+	st := status.Newf(codes.Unavailable, "linode instance %d is migrating (%s), retry after %s", linodeID, reason, retryAfter)
+	if withInfo, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)}); err == nil {
+		st = withInfo
+	}
+	return st.Err()
+}
+
+// errTooManyVolumePolls indicates the controller is already polling the
+// Linode API for [maxVolumePolls] volumes, so volumeID is not waited for
+// until one of them completes.
+func errTooManyVolumePolls(volumeID int) error {
+	return status.Errorf(codes.Unavailable, "too many volumes are being waited for, retry waiting for volume %d later", volumeID)
+}
+
+// errEncryptionNotSupported indicates volumes cannot be encrypted in region.
+// The regions that support encryption, if any, are suggested in the message
+// and in the ErrorInfo details of the status, so that the topology of the
+// StorageClass can be fixed.
+func errEncryptionNotSupported(region string, supportedRegions []string) error {
+	msg := fmt.Sprintf("Volume encryption is not supported in the %s region", region)
+	if len(supportedRegions) > 0 {
+		msg += fmt.Sprintf(", it is supported in: %s", strings.Join(supportedRegions, ", "))
+	}
+	st := status.New(codes.Internal, msg)
+	info := &errdetails.ErrorInfo{
+		Reason: "ENCRYPTION_NOT_SUPPORTED_IN_REGION",
+		Domain: Name,
+		Metadata: map[string]string{
+			"region":           region,
+			"supportedRegions": strings.Join(supportedRegions, ","),
+		},
+	}
+	if withInfo, err := st.WithDetails(info); err == nil {
+		st = withInfo
+	}
+	return st.Err()
+}
+
 func errVolumeNotFound(volumeID int) error {
 	return status.Errorf(codes.NotFound, "volume not found: %d", volumeID)
 }
 
+// errGetVolume is the error of a request for the volume with volumeID, for
+// which the Linode API failed with err: NOT_FOUND if the volume does not
+// exist, UNAVAILABLE if the failure is transient and the request should be
+// retried, or INTERNAL otherwise.
+func errGetVolume(volumeID int, err error) error {
+	switch {
+	case linodego.IsNotFound(err):
+		return errVolumeNotFound(volumeID)
+	case transientAPIError(err):
+		return status.Errorf(codes.Unavailable, "get volume %d: %v", volumeID, err)
+	default:
+		return errInternal("get volume %d: %v", volumeID, err)
+	}
+}
+
+// transientAPIError reports whether err is a failure of the Linode API that
+// may not happen again: a request that could not be sent or timed out, a
+// rate limit, or a server error.
+func transientAPIError(err error) bool {
+	var apiErr *linodego.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch code := apiErr.StatusCode(); {
+	case code == linodego.ErrorFromError, code == http.StatusRequestTimeout, code == http.StatusTooManyRequests:
+		return true
+	default:
+		return code >= http.StatusInternalServerError
+	}
+}
+
+func errUnsupportedFSType(fsType string) error {
+	return status.Errorf(codes.InvalidArgument, "unsupported file system type %q, must be one of %v", fsType, supportedFSTypes)
+}
+
+// errUnsupportedProjectQuota indicates the usage of volumes formatted with
+// fsType cannot be capped with a project quota.
+func errUnsupportedProjectQuota(fsType string) error {
+	return status.Errorf(codes.InvalidArgument, "project quotas are not supported on file system type %q", fsType)
+}
+
+// errInvalidProjectQuotaLimit indicates the project quota limit passed in
+// the volume context is not a positive number of bytes.
+func errInvalidProjectQuotaLimit(limit string) error {
+	return status.Errorf(codes.InvalidArgument, "invalid project quota limit %q", limit)
+}
+
+// errInvalidReadAhead indicates the read-ahead set with the
+// [ReadAheadKBAttribute] parameter is not a number of kilobytes.
+func errInvalidReadAhead(readAheadKB string) error {
+	return status.Errorf(codes.InvalidArgument, "invalid read-ahead %q, must be a number of kilobytes", readAheadKB)
+}
+
+// errInvalidPartition indicates the partition set with the
+// [PartitionAttribute] parameter is neither [PartitionAuto] nor the number
+// of a partition.
+func errInvalidPartition(partition string) error {
+	return status.Errorf(codes.InvalidArgument, "invalid partition %q, must be %q or the number of a partition", partition, PartitionAuto)
+}
+
+// errUnpartitionedDevice indicates the disk of a [PartitionAuto] volume
+// holds format instead of a partition table, so it is not partitioned.
+func errUnpartitionedDevice(disk, format string) error {
+	return status.Errorf(codes.FailedPrecondition, "device %s holds %q instead of a partition table, refusing to partition it", disk, format)
+}
+
+// errDeviceTooSmall indicates the device of a volume is smaller than the
+// volume in the Linode API, so it may be a stale attachment or the device of
+// another volume.
+func errDeviceTooSmall(devicePath string, deviceBytes, volumeBytes int64) error {
+	return status.Errorf(codes.FailedPrecondition, "device %s has %d bytes, less than the %d bytes of its volume, refusing to stage it", devicePath, deviceBytes, volumeBytes)
+}
+
+// errUnavailableIOScheduler indicates the I/O scheduler set with the
+// [IOSchedulerAttribute] parameter is not one of the schedulers available
+// for device on the node.
+func errUnavailableIOScheduler(scheduler, device string, available []string) error {
+	return status.Errorf(codes.InvalidArgument, "I/O scheduler %q is not available for device %s, available schedulers: %s", scheduler, device, strings.Join(available, ", "))
+}
+
 func errInvalidVolumeCapability(capability []*csi.VolumeCapability) error {
 	return status.Errorf(codes.InvalidArgument, "invalid volume capability: %v", capability)
 }
 
+// errInvalidCapacityRange indicates the capacity range of a request is
+// invalid.
+func errInvalidCapacityRange(message string) error {
+	return status.Error(codes.InvalidArgument, message)
+}
+
+// errCapacityOutOfRange indicates no volume size, a whole number of GiB of at
+// least [MinVolumeSizeBytes], is within the capacity range of a request.
+func errCapacityOutOfRange(requiredBytes, limitBytes int64) error {
+	return status.Errorf(codes.OutOfRange, "no volume size is within the capacity range: required %d bytes, limit %d bytes, volumes are a whole number of GiB of at least %d bytes", requiredBytes, limitBytes, MinVolumeSizeBytes)
+}
+
 // errInternal is a convenience function to return a gRPC error with an
 // INTERNAL status code.
 func errInternal(format string, args ...any) error {
@@ -100,3 +414,18 @@ func errNotFound(format string, args ...any) error {
 func errAlreadyExists(format string, args ...any) error {
 	return status.Errorf(codes.AlreadyExists, format, args...)
 }
+
+// errMountPropagation indicates the mount at mountPoint holding path, with
+// the propagation fields of mountinfo(5), is not shared, so the volumes
+// mounted under path would not be visible to the pods.
+func errMountPropagation(path, mountPoint string, fields []string) error {
+	propagation := "private"
+	if len(fields) > 0 {
+		propagation = strings.Join(fields, " ")
+	}
+	return status.Errorf(codes.FailedPrecondition,
+		"mount %s holding %s is not shared (%s), so volumes published there are not visible to pods: "+
+			"the kubelet directory must be mounted in the node plugin container with mountPropagation: Bidirectional, "+
+			"and the kubelet must not run in its own mount namespace (e.g. with MountFlags=slave or PrivateMounts=yes in its systemd unit)",
+		mountPoint, path, propagation)
+}
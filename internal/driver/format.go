@@ -0,0 +1,134 @@
+package driver
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"k8s.io/mount-utils"
+	utilexec "k8s.io/utils/exec"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+// Results of the probe of a device before it is formatted and mounted,
+// counted by the csi_node_format_total metric.
+const (
+	formatResultFormatted = "formatted"
+	formatResultMounted   = "mounted"
+	formatResultRefused   = "refused"
+	formatResultMismatch  = "mismatch_mounted"
+)
+
+// checkDeviceFormat probes the device at source before it is formatted and
+// mounted with fsType by NodeStageVolume, and returns its signature, empty
+// if it is blank. It fails if the device holds anything but a fsType file
+// system, such as another file system, a LUKS header or a partition table,
+// as it is then likely not the device of the volume. With
+// [Options.AllowFSMismatchMount], devices holding another supported file
+// system are mounted with it instead.
+func (ns *NodeServer) checkDeviceFormat(ctx context.Context, source, fsType, volumeID string) (format string, err error) {
+	log := logger.GetLogger(ctx)
+	log.V(4).Info("Entering checkDeviceFormat()", "source", source, "fsType", fsType)
+	defer log.V(4).Info("Exiting checkDeviceFormat()")
+
+	format, err = ns.diskFormat(ctx, source)
+	if err != nil {
+		return "", errInternal("get disk format of %s: %v", source, err)
+	}
+
+	switch format {
+	case "":
+		log.V(2).Info("Formatting blank device", "volume_id", volumeID, "source", source, "fsType", fsType)
+		observability.NodeFormatTotal.WithLabelValues(formatResultFormatted).Inc()
+	case fsType:
+		log.V(4).Info("Device already formatted", "source", source, "fsType", fsType)
+		observability.NodeFormatTotal.WithLabelValues(formatResultMounted).Inc()
+	default:
+		if ns.allowsFSMismatch(format) {
+			log.V(2).Info("Mounting device with the file system it holds instead of the requested one", "volume_id", volumeID, "source", source, "fsType", fsType, "format", format)
+			observability.NodeFormatTotal.WithLabelValues(formatResultMismatch).Inc()
+			return format, nil
+		}
+		observability.NodeFormatTotal.WithLabelValues(formatResultRefused).Inc()
+		// When the check is not enforced, the device is mounted as it is
+		err := errUnexpectedDeviceFormat(source, format, fsType)
+		return format, ns.driver.enforce(ctx, validationDeviceFormat, err, "volume_id", volumeID, "source", source, "fsType", fsType, "signature", format)
+	}
+	return format, nil
+}
+
+// allowsFSMismatch reports whether a device holding a format file system is
+// mounted with it when another file system is requested.
+func (ns *NodeServer) allowsFSMismatch(format string) bool {
+	return ns.driver != nil && ns.driver.opts.AllowFSMismatchMount && supportedFSType(format)
+}
+
+// formatDevice formats the blank device at source with fsType and the given
+// options of mkfs, with the same defaults as FormatAndMount. Devices staged
+// read-only are not formatted.
+func (ns *NodeServer) formatDevice(ctx context.Context, source, fsType, volumeID string, mountOptions, formatOptions []string) error {
+	if slices.Contains(mountOptions, "ro") {
+		return errInternal("cannot format blank device %s of a volume staged read-only", source)
+	}
+
+	args := slices.Clone(formatOptions)
+	switch fsType {
+	case "ext3", "ext4":
+		// Force the format and reserve no blocks for the super-user
+		args = append(args, "-F", "-m0")
+	case "xfs":
+		args = append(args, "-f")
+	}
+	args = append(args, source)
+
+	if _, err := ns.auditedMounter(ctx, volumeID).Exec.Command(mkfsDependency(fsType), args...).CombinedOutput(); err != nil {
+		return errInternal("format %s with %s: %v", source, fsType, err)
+	}
+	return nil
+}
+
+// auditedMounter returns the mounter of the node server, logging the mkfs
+// commands it runs for volumeID along with their output.
+func (ns *NodeServer) auditedMounter(ctx context.Context, volumeID string) *mount.SafeFormatAndMount {
+	return &mount.SafeFormatAndMount{
+		Interface: ns.mounter.Interface,
+		Exec:      &mkfsAuditExec{Interface: ns.mounter.Exec, log: logger.GetLogger(ctx), volumeID: volumeID},
+	}
+}
+
+// mkfsAuditExec logs the mkfs commands run with the executor it wraps.
+type mkfsAuditExec struct {
+	utilexec.Interface
+	log      *logger.Logger
+	volumeID string
+}
+
+func (e *mkfsAuditExec) Command(cmd string, args ...string) utilexec.Cmd {
+	command := e.Interface.Command(cmd, args...)
+	if !strings.HasPrefix(cmd, "mkfs") {
+		return command
+	}
+	return &mkfsAuditCmd{Cmd: command, exec: e, args: append([]string{cmd}, args...)}
+}
+
+// mkfsAuditCmd logs the command line and output of a mkfs command once it
+// has run.
+type mkfsAuditCmd struct {
+	utilexec.Cmd
+	exec *mkfsAuditExec
+	args []string
+}
+
+func (c *mkfsAuditCmd) CombinedOutput() ([]byte, error) {
+	out, err := c.Cmd.CombinedOutput()
+	command := strings.Join(c.args, " ")
+	output := strings.TrimSpace(string(out))
+	if err != nil {
+		c.exec.log.Error(err, "mkfs failed", "volume_id", c.exec.volumeID, "command", command, "output", output)
+	} else {
+		c.exec.log.V(2).Info("mkfs completed", "volume_id", c.exec.volumeID, "command", command, "output", output)
+	}
+	return out, err
+}
@@ -0,0 +1,131 @@
+//go:build linux
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/mount-utils"
+	"k8s.io/utils/exec"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+func TestCheckDeviceFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		blkidOutput string
+		blkidErr    error
+		mode        EnforcementMode
+		allowFS     bool
+		wantCode    codes.Code
+	}{
+		{
+			name:     "Blank device",
+			blkidErr: exec.CodeExitError{Code: 2, Err: fmt.Errorf("not formatted")},
+			wantCode: codes.OK,
+		},
+		{
+			name:        "Expected file system",
+			blkidOutput: "DEVNAME=/dev/sdb\nTYPE=ext4\n",
+			wantCode:    codes.OK,
+		},
+		{
+			name:        "Other file system",
+			blkidOutput: "DEVNAME=/dev/sdb\nTYPE=xfs\n",
+			wantCode:    codes.FailedPrecondition,
+		},
+		{
+			name:        "Other file system in warn mode",
+			blkidOutput: "DEVNAME=/dev/sdb\nTYPE=xfs\n",
+			mode:        EnforcementWarn,
+			wantCode:    codes.OK,
+		},
+		{
+			name:        "Other file system allowed",
+			blkidOutput: "DEVNAME=/dev/sdb\nTYPE=xfs\n",
+			allowFS:     true,
+			wantCode:    codes.OK,
+		},
+		{
+			name:        "LUKS header",
+			blkidOutput: "DEVNAME=/dev/sdb\nTYPE=crypto_LUKS\n",
+			wantCode:    codes.FailedPrecondition,
+		},
+		{
+			name:        "LUKS header with other file systems allowed",
+			blkidOutput: "DEVNAME=/dev/sdb\nTYPE=crypto_LUKS\n",
+			allowFS:     true,
+			wantCode:    codes.FailedPrecondition,
+		},
+		{
+			name:        "Partition table",
+			blkidOutput: "DEVNAME=/dev/sdb\nPTTYPE=gpt\n",
+			wantCode:    codes.FailedPrecondition,
+		},
+		{
+			name:     "Probe error",
+			blkidErr: errors.New("permission denied"),
+			wantCode: codes.Internal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockExec := mocks.NewMockExecutor(ctrl)
+			mockCommand := mocks.NewMockCommand(ctrl)
+			mockExec.EXPECT().Command("blkid", "-p", "-s", "TYPE", "-s", "PTTYPE", "-o", "export", "/dev/sdb").Return(mockCommand)
+			mockCommand.EXPECT().CombinedOutput().Return([]byte(tt.blkidOutput), tt.blkidErr)
+
+			ns := &NodeServer{
+				driver: &LinodeDriver{opts: Options{EnforcementMode: tt.mode, AllowFSMismatchMount: tt.allowFS}},
+				mounter: &mount.SafeFormatAndMount{
+					Interface: mocks.NewMockMounter(ctrl),
+					Exec:      mockExec,
+				},
+			}
+			_, err := ns.checkDeviceFormat(context.Background(), "/dev/sdb", "ext4", "1001-test")
+			if status.Code(err) != tt.wantCode {
+				t.Errorf("checkDeviceFormat() error = %v, want code %v", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestMkfsAuditExec(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockExec := mocks.NewMockExecutor(ctrl)
+	mkfs := mocks.NewMockCommand(ctrl)
+	mockExec.EXPECT().Command("mkfs.ext4", "-F", "-m0", "/dev/sdb").Return(mkfs)
+	mkfs.EXPECT().CombinedOutput().Return([]byte("Writing superblocks\n"), nil)
+	blkid := mocks.NewMockCommand(ctrl)
+	mockExec.EXPECT().Command("blkid", "/dev/sdb").Return(blkid)
+
+	audit := &mkfsAuditExec{Interface: mockExec, log: logger.GetLogger(context.Background()), volumeID: "1001-test"}
+
+	cmd := audit.Command("mkfs.ext4", "-F", "-m0", "/dev/sdb")
+	if _, ok := cmd.(*mkfsAuditCmd); !ok {
+		t.Fatalf("Command(mkfs.ext4) = %T, want *mkfsAuditCmd", cmd)
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil || string(out) != "Writing superblocks\n" {
+		t.Errorf("CombinedOutput() = %q, %v, want the output of mkfs", out, err)
+	}
+
+	// Other commands are not wrapped
+	if cmd := audit.Command("blkid", "/dev/sdb"); cmd != blkid {
+		t.Errorf("Command(blkid) = %v, want the command of the executor", cmd)
+	}
+}
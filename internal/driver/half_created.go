@@ -0,0 +1,184 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/linode/linodego"
+
+	linodevolumes "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-volumes"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+// HalfCreatedVolumeMode is what the controller does at startup with the
+// volumes still tagged with the [ProvisioningTag] long after they were
+// created, as left behind when the controller crashed between creating a
+// volume and returning it to the CO. Unless CreateVolume is retried for them,
+// no PersistentVolume refers to them, and they are billed until deleted.
+type HalfCreatedVolumeMode string
+
+const (
+	// HalfCreatedVolumesOff does not look for half-created volumes. It is
+	// the default.
+	HalfCreatedVolumesOff HalfCreatedVolumeMode = "off"
+
+	// HalfCreatedVolumesReport logs the half-created volumes and counts them
+	// in the csi_half_created_volumes_total metric, without changing them.
+	HalfCreatedVolumesReport HalfCreatedVolumeMode = "report"
+
+	// HalfCreatedVolumesFinish waits for the half-created volumes to be
+	// active and removes their [ProvisioningTag], as CreateVolume would
+	// have.
+	HalfCreatedVolumesFinish HalfCreatedVolumeMode = "finish"
+
+	// HalfCreatedVolumesDelete deletes the half-created volumes that are not
+	// attached and that no PersistentVolume refers to, found with
+	// [Options.KubeClient], and finishes the others. Volumes are only
+	// reported without a KubeClient.
+	HalfCreatedVolumesDelete HalfCreatedVolumeMode = "delete"
+)
+
+// ParseHalfCreatedVolumeMode parses a half-created volume mode. The empty
+// string is [HalfCreatedVolumesOff].
+func ParseHalfCreatedVolumeMode(s string) (HalfCreatedVolumeMode, error) {
+	switch mode := HalfCreatedVolumeMode(s); mode {
+	case "":
+		return HalfCreatedVolumesOff, nil
+	case HalfCreatedVolumesOff, HalfCreatedVolumesReport, HalfCreatedVolumesFinish, HalfCreatedVolumesDelete:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid half-created volume mode %q, must be %q, %q, %q or %q", s, HalfCreatedVolumesOff, HalfCreatedVolumesReport, HalfCreatedVolumesFinish, HalfCreatedVolumesDelete)
+	}
+}
+
+// defaultHalfCreatedVolumeAge is how long ago the volumes still tagged with
+// the [ProvisioningTag] must have been created to be half-created, when
+// [Options.HalfCreatedVolumeAge] is not set. CreateVolume requests retried
+// by the CO resume waiting for their volume well before.
+const defaultHalfCreatedVolumeAge = time.Hour
+
+// What the controller did with the half-created volumes, used as the
+// "action" label of the csi_half_created_volumes_total metric.
+const (
+	halfCreatedReported = "reported"
+	halfCreatedFinished = "finished"
+	halfCreatedDeleted  = "deleted"
+	halfCreatedFailed   = "failed"
+)
+
+// reconcileHalfCreatedVolumes handles the half-created volumes of the
+// cluster as mode requests: those tagged with the [ProvisioningTag], and
+// with the [attributeCluster] of the controller if it has one, created more
+// than age ago.
+func (cs *ControllerServer) reconcileHalfCreatedVolumes(ctx context.Context, mode HalfCreatedVolumeMode, age time.Duration) {
+	log := logger.GetLogger(ctx)
+	if age <= 0 {
+		age = defaultHalfCreatedVolumeAge
+	}
+
+	volumes, err := cs.listHalfCreatedVolumes(ctx, age)
+	if err != nil {
+		log.Error(err, "Failed to list the half-created volumes")
+		return
+	}
+	if len(volumes) == 0 {
+		return
+	}
+
+	var referenced map[int]bool
+	if mode == HalfCreatedVolumesDelete {
+		if referenced, err = cs.referencedVolumes(ctx); err != nil {
+			log.Error(err, "Failed to list the persistent volumes, only reporting the half-created volumes")
+			mode = HalfCreatedVolumesReport
+		}
+	}
+
+	for i := range volumes {
+		vol := &volumes[i]
+		action, err := cs.reconcileHalfCreatedVolume(ctx, vol, mode, referenced)
+		if err != nil {
+			log.Error(err, "Failed to reconcile the half-created volume", "volume_id", vol.ID, "label", vol.Label)
+			action = halfCreatedFailed
+		} else {
+			log.V(2).Info("Half-created volume found", "volume_id", vol.ID, "label", vol.Label, "created", vol.Created, "action", action)
+		}
+		observability.HalfCreatedVolumesTotal.WithLabelValues(action).Inc()
+	}
+}
+
+// reconcileHalfCreatedVolume handles vol as mode requests, and returns what
+// it did with it. Volumes whose ID is in referenced are never deleted.
+func (cs *ControllerServer) reconcileHalfCreatedVolume(ctx context.Context, vol *linodego.Volume, mode HalfCreatedVolumeMode, referenced map[int]bool) (string, error) {
+	switch {
+	case mode == HalfCreatedVolumesReport:
+		return halfCreatedReported, nil
+	case mode == HalfCreatedVolumesDelete && !referenced[vol.ID] && vol.LinodeID == nil:
+		if err := cs.linodeClient(ctx).DeleteVolume(ctx, vol.ID); err != nil && !linodego.IsNotFound(err) {
+			return "", fmt.Errorf("delete volume %d: %w", vol.ID, err)
+		}
+		return halfCreatedDeleted, nil
+	}
+
+	active := vol
+	if vol.Status != linodego.VolumeActive {
+		var err error
+		if active, err = cs.waitForVolumeActive(ctx, vol.ID, waitTimeout()); err != nil {
+			return "", fmt.Errorf("wait for volume %d to be active: %w", vol.ID, err)
+		}
+	}
+	if err := cs.updateVolumeAttributes(ctx, active, nil, attributeProvisioning); err != nil {
+		return "", err
+	}
+	return halfCreatedFinished, nil
+}
+
+// listHalfCreatedVolumes returns the volumes tagged with the
+// [ProvisioningTag], and with the [attributeCluster] of the controller if it
+// has one, created more than age ago.
+func (cs *ControllerServer) listHalfCreatedVolumes(ctx context.Context, age time.Duration) ([]linodego.Volume, error) {
+	jsonFilter, err := json.Marshal(map[string]string{"tags": ProvisioningTag})
+	if err != nil {
+		return nil, fmt.Errorf("marshal json filter: %w", err)
+	}
+	volumes, err := cs.linodeClient(ctx).ListVolumes(ctx, linodego.NewListOptions(0, string(jsonFilter)))
+	if err != nil {
+		return nil, fmt.Errorf("list volumes: %w", err)
+	}
+
+	cluster, hasCluster := cs.driver.clusterAttribute()
+	cutoff := time.Now().Add(-age)
+	var halfCreated []linodego.Volume
+	for _, vol := range volumes {
+		if !isProvisioning(&vol) || vol.Created == nil || vol.Created.After(cutoff) {
+			continue
+		}
+		if hash, _ := getAttribute(vol.Tags, attributeCluster); hasCluster && hash != cluster.value {
+			continue
+		}
+		halfCreated = append(halfCreated, vol)
+	}
+	return halfCreated, nil
+}
+
+// referencedVolumes returns the IDs of the volumes the PersistentVolumes of
+// the driver refer to.
+func (cs *ControllerServer) referencedVolumes(ctx context.Context) (map[int]bool, error) {
+	kube := cs.driver.opts.KubeClient
+	if kube == nil {
+		return nil, fmt.Errorf("no kubernetes client")
+	}
+	pvs, err := kube.ListPersistentVolumes(ctx, cs.driver.name)
+	if err != nil {
+		return nil, err
+	}
+	referenced := make(map[int]bool, len(pvs))
+	for _, pv := range pvs {
+		if key, err := linodevolumes.ParseLinodeVolumeKey(pv.VolumeHandle); err == nil {
+			referenced[key.VolumeID] = true
+		}
+	}
+	return referenced, nil
+}
@@ -0,0 +1,121 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/linode/linodego"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/mock/gomock"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+	kubeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/kube-client"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+func TestParseHalfCreatedVolumeMode(t *testing.T) {
+	for s, want := range map[string]HalfCreatedVolumeMode{
+		"":       HalfCreatedVolumesOff,
+		"off":    HalfCreatedVolumesOff,
+		"report": HalfCreatedVolumesReport,
+		"finish": HalfCreatedVolumesFinish,
+		"delete": HalfCreatedVolumesDelete,
+	} {
+		if got, err := ParseHalfCreatedVolumeMode(s); err != nil || got != want {
+			t.Errorf("ParseHalfCreatedVolumeMode(%q) = %q, %v, want %q", s, got, err, want)
+		}
+	}
+	if _, err := ParseHalfCreatedVolumeMode("clean"); err == nil {
+		t.Error("ParseHalfCreatedVolumeMode(\"clean\") succeeded, want error")
+	}
+}
+
+func TestReconcileHalfCreatedVolumes(t *testing.T) {
+	old := time.Now().Add(-2 * time.Hour)
+	recent := time.Now().Add(-time.Minute)
+	linodeID := 1003
+	cluster := "csi-cluster:" + clusterHash("prod")
+
+	// The volumes listed with the provisioning tag
+	volumes := []linodego.Volume{
+		{ID: 1001, Status: linodego.VolumeActive, Created: &old, Tags: []string{"team", ProvisioningTag, cluster}},
+		{ID: 1002, Status: linodego.VolumeActive, Created: &recent, Tags: []string{ProvisioningTag, cluster}},
+		{ID: 1003, Status: linodego.VolumeActive, Created: &old, Tags: []string{ProvisioningTag, "csi-cluster:c0ffee"}},
+		{ID: 1004, Status: linodego.VolumeActive, Created: &old, Tags: []string{ProvisioningTag, cluster}, LinodeID: &linodeID},
+		{ID: 1005, Status: linodego.VolumeActive, Created: &old, Tags: []string{ProvisioningTag, cluster}},
+	}
+
+	tests := []struct {
+		name       string
+		mode       HalfCreatedVolumeMode
+		setupMocks func(*mocks.MockLinodeClient, *mocks.MockKubeClient)
+		noKube     bool
+		want       map[string]float64
+	}{
+		{
+			name: "Report",
+			mode: HalfCreatedVolumesReport,
+			want: map[string]float64{halfCreatedReported: 3},
+		},
+		{
+			name: "Finish",
+			mode: HalfCreatedVolumesFinish,
+			setupMocks: func(m *mocks.MockLinodeClient, k *mocks.MockKubeClient) {
+				m.EXPECT().UpdateVolume(gomock.Any(), 1001, linodego.VolumeUpdateOptions{Tags: &[]string{"team", cluster}}).Return(&linodego.Volume{}, nil)
+				m.EXPECT().UpdateVolume(gomock.Any(), 1004, linodego.VolumeUpdateOptions{Tags: &[]string{cluster}}).Return(&linodego.Volume{}, nil)
+				m.EXPECT().UpdateVolume(gomock.Any(), 1005, gomock.Any()).Return(nil, errors.New("api error"))
+			},
+			want: map[string]float64{halfCreatedFinished: 2, halfCreatedFailed: 1},
+		},
+		{
+			name: "Delete",
+			mode: HalfCreatedVolumesDelete,
+			setupMocks: func(m *mocks.MockLinodeClient, k *mocks.MockKubeClient) {
+				k.EXPECT().ListPersistentVolumes(gomock.Any(), Name).Return([]kubeclient.PersistentVolume{{Name: "pvc-5", VolumeHandle: "1005-pvc5"}}, nil)
+				m.EXPECT().DeleteVolume(gomock.Any(), 1001).Return(nil)
+				// Attached or referenced volumes are finished instead
+				m.EXPECT().UpdateVolume(gomock.Any(), 1004, linodego.VolumeUpdateOptions{Tags: &[]string{cluster}}).Return(&linodego.Volume{}, nil)
+				m.EXPECT().UpdateVolume(gomock.Any(), 1005, linodego.VolumeUpdateOptions{Tags: &[]string{cluster}}).Return(&linodego.Volume{}, nil)
+			},
+			want: map[string]float64{halfCreatedDeleted: 1, halfCreatedFinished: 2},
+		},
+		{
+			name:   "Delete without a kubernetes client",
+			mode:   HalfCreatedVolumesDelete,
+			noKube: true,
+			want:   map[string]float64{halfCreatedReported: 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockClient := mocks.NewMockLinodeClient(ctrl)
+			mockKube := mocks.NewMockKubeClient(ctrl)
+			mockClient.EXPECT().ListVolumes(gomock.Any(), gomock.Any()).Return(volumes, nil)
+			if tt.setupMocks != nil {
+				tt.setupMocks(mockClient, mockKube)
+			}
+			driver := &LinodeDriver{name: Name, opts: Options{ClusterName: "prod", KubeClient: mockKube}}
+			if tt.noKube {
+				driver.opts.KubeClient = nil
+			}
+			cs := &ControllerServer{client: mockClient, driver: driver}
+
+			before := make(map[string]float64)
+			for action := range tt.want {
+				before[action] = testutil.ToFloat64(observability.HalfCreatedVolumesTotal.WithLabelValues(action))
+			}
+			cs.reconcileHalfCreatedVolumes(context.Background(), tt.mode, 0)
+			for action, want := range tt.want {
+				if got := testutil.ToFloat64(observability.HalfCreatedVolumesTotal.WithLabelValues(action)) - before[action]; got != want {
+					t.Errorf("%s volumes = %v, want %v", action, got, want)
+				}
+			}
+		})
+	}
+}
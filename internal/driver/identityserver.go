@@ -55,7 +55,8 @@ func NewIdentityServer(ctx context.Context, linodeDriver *LinodeDriver) (*Identi
 
 // GetPluginInfo returns information about the CSI plugin.
 // This method is REQUIRED for the Identity service as per the CSI spec.
-// It returns the name and version of the CSI plugin.
+// It returns the name and version of the CSI plugin, and its readiness
+// conditions in the manifest.
 func (linodeIdentity *IdentityServer) GetPluginInfo(ctx context.Context, req *csi.GetPluginInfoRequest) (*csi.GetPluginInfoResponse, error) {
 	log, _, done := logger.GetLogger(ctx).WithMethod("GetPluginInfo")
 	defer done()
@@ -69,6 +70,7 @@ func (linodeIdentity *IdentityServer) GetPluginInfo(ctx context.Context, req *cs
 	return &csi.GetPluginInfoResponse{
 		Name:          linodeIdentity.driver.name,
 		VendorVersion: linodeIdentity.driver.vendorVersion,
+		Manifest:      linodeIdentity.driver.conditions.manifest(),
 	}, nil
 }
 
@@ -81,39 +83,45 @@ func (linodeIdentity *IdentityServer) GetPluginCapabilities(ctx context.Context,
 
 	log.V(2).Info("Processing request")
 
-	return &csi.GetPluginCapabilitiesResponse{
-		Capabilities: []*csi.PluginCapability{
-			{
-				Type: &csi.PluginCapability_Service_{
-					Service: &csi.PluginCapability_Service{
-						Type: csi.PluginCapability_Service_CONTROLLER_SERVICE,
-					},
+	capabilities := []*csi.PluginCapability{
+		{
+			Type: &csi.PluginCapability_Service_{
+				Service: &csi.PluginCapability_Service{
+					Type: csi.PluginCapability_Service_CONTROLLER_SERVICE,
 				},
 			},
-			{
-				Type: &csi.PluginCapability_Service_{
-					Service: &csi.PluginCapability_Service{
-						Type: csi.PluginCapability_Service_VOLUME_ACCESSIBILITY_CONSTRAINTS,
-					},
+		},
+	}
+	// The provisioner sends no topology requirements without the capability
+	if linodeIdentity.driver.opts.Topology != TopologyOff {
+		capabilities = append(capabilities, &csi.PluginCapability{
+			Type: &csi.PluginCapability_Service_{
+				Service: &csi.PluginCapability_Service{
+					Type: csi.PluginCapability_Service_VOLUME_ACCESSIBILITY_CONSTRAINTS,
 				},
 			},
-			{
-				Type: &csi.PluginCapability_VolumeExpansion_{
-					// We currently only support offline volume expansion
-					// In order to use the feature:
-					// 	1. Update your PersistentVolumeClaim k8s object to desired size(note that the size needs to be more than what it currently is)
-					// 	2. Delete and recreate the pod that is using the PVC(or scale replicas accordingly)
-					// 	3. This operation should detach and re-attach the volume to the newly created pod allowing you to use the updated size
-					VolumeExpansion: &csi.PluginCapability_VolumeExpansion{
-						Type: csi.PluginCapability_VolumeExpansion_ONLINE,
-					},
+		})
+	}
+	capabilities = append(capabilities,
+		&csi.PluginCapability{
+			Type: &csi.PluginCapability_VolumeExpansion_{
+				// We currently only support offline volume expansion
+				// In order to use the feature:
+				// 	1. Update your PersistentVolumeClaim k8s object to desired size(note that the size needs to be more than what it currently is)
+				// 	2. Delete and recreate the pod that is using the PVC(or scale replicas accordingly)
+				// 	3. This operation should detach and re-attach the volume to the newly created pod allowing you to use the updated size
+				VolumeExpansion: &csi.PluginCapability_VolumeExpansion{
+					Type: csi.PluginCapability_VolumeExpansion_ONLINE,
 				},
 			},
 		},
-	}, nil
+	)
+
+	return &csi.GetPluginCapabilitiesResponse{Capabilities: capabilities}, nil
 }
 
-// Probe checks if the plugin is ready to serve requests.
+// Probe checks if the plugin is ready to serve requests, and its fatal
+// readiness conditions are met.
 // This method is REQUIRED for the Identity service as per the CSI spec.
 // It allows the CO to check the readiness of the plugin.
 func (linodeIdentity *IdentityServer) Probe(ctx context.Context, req *csi.ProbeRequest) (*csi.ProbeResponse, error) {
@@ -125,9 +133,18 @@ func (linodeIdentity *IdentityServer) Probe(ctx context.Context, req *csi.ProbeR
 	linodeIdentity.driver.readyMu.Lock()
 	defer linodeIdentity.driver.readyMu.Unlock()
 
+	// Only the fatal conditions make the plugin not ready, so that it is not
+	// restarted by the liveness probe when the Linode API cannot be reached
+	// for a while
+	ready := linodeIdentity.driver.ready
+	if unmet := linodeIdentity.driver.conditions.unmetFatal(); len(unmet) > 0 {
+		log.V(2).Info("Fatal readiness conditions are not met", "conditions", unmet)
+		ready = false
+	}
+
 	return &csi.ProbeResponse{
 		Ready: &wrapperspb.BoolValue{
-			Value: linodeIdentity.driver.ready,
+			Value: ready,
 		},
 	}, nil
 }
@@ -16,6 +16,7 @@ package driver
 
 import (
 	"context"
+	"errors"
 	"reflect"
 	"testing"
 
@@ -75,6 +76,11 @@ func TestIdentityServer_GetPluginInfo(t *testing.T) {
 			wantResponse: &csi.GetPluginInfoResponse{
 				Name:          "test-driver",
 				VendorVersion: "v1.0.0",
+				Manifest: map[string]string{
+					"condition.api-reachable":  "true",
+					"condition.metadata":       "true",
+					"condition.mounts-healthy": "true",
+				},
 			},
 			wantErr: false,
 		},
@@ -109,39 +115,51 @@ func TestIdentityServer_GetPluginInfo(t *testing.T) {
 }
 
 func TestIdentityServer_GetPluginCapabilities(t *testing.T) {
-	wantCapabilities := []*csi.PluginCapability{
-		{
-			Type: &csi.PluginCapability_Service_{
-				Service: &csi.PluginCapability_Service{
-					Type: csi.PluginCapability_Service_CONTROLLER_SERVICE,
-				},
+	controllerService := &csi.PluginCapability{
+		Type: &csi.PluginCapability_Service_{
+			Service: &csi.PluginCapability_Service{
+				Type: csi.PluginCapability_Service_CONTROLLER_SERVICE,
 			},
 		},
-		{
-			Type: &csi.PluginCapability_Service_{
-				Service: &csi.PluginCapability_Service{
-					Type: csi.PluginCapability_Service_VOLUME_ACCESSIBILITY_CONSTRAINTS,
-				},
+	}
+	accessibilityConstraints := &csi.PluginCapability{
+		Type: &csi.PluginCapability_Service_{
+			Service: &csi.PluginCapability_Service{
+				Type: csi.PluginCapability_Service_VOLUME_ACCESSIBILITY_CONSTRAINTS,
 			},
 		},
-		{
-			Type: &csi.PluginCapability_VolumeExpansion_{
-				VolumeExpansion: &csi.PluginCapability_VolumeExpansion{
-					Type: csi.PluginCapability_VolumeExpansion_ONLINE,
-				},
+	}
+	volumeExpansion := &csi.PluginCapability{
+		Type: &csi.PluginCapability_VolumeExpansion_{
+			VolumeExpansion: &csi.PluginCapability_VolumeExpansion{
+				Type: csi.PluginCapability_VolumeExpansion_ONLINE,
 			},
 		},
 	}
 
-	linodeIdentity := &IdentityServer{driver: &LinodeDriver{}}
-	gotResponse, err := linodeIdentity.GetPluginCapabilities(context.Background(), &csi.GetPluginCapabilitiesRequest{})
-
-	if err != nil {
-		t.Errorf("IdentityServer.GetPluginCapabilities() unexpected error: %v", err)
+	tests := []struct {
+		name             string
+		topology         TopologyMode
+		wantCapabilities []*csi.PluginCapability
+	}{
+		{name: "Default", wantCapabilities: []*csi.PluginCapability{controllerService, accessibilityConstraints, volumeExpansion}},
+		{name: "Topology required", topology: TopologyRequired, wantCapabilities: []*csi.PluginCapability{controllerService, accessibilityConstraints, volumeExpansion}},
+		{name: "Topology off", topology: TopologyOff, wantCapabilities: []*csi.PluginCapability{controllerService, volumeExpansion}},
 	}
 
-	if !reflect.DeepEqual(gotResponse.GetCapabilities(), wantCapabilities) {
-		t.Errorf("IdentityServer.GetPluginCapabilities() = %v, want %v", gotResponse.GetCapabilities(), wantCapabilities)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			linodeIdentity := &IdentityServer{driver: &LinodeDriver{opts: Options{Topology: tt.topology}}}
+			gotResponse, err := linodeIdentity.GetPluginCapabilities(context.Background(), &csi.GetPluginCapabilitiesRequest{})
+
+			if err != nil {
+				t.Errorf("IdentityServer.GetPluginCapabilities() unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(gotResponse.GetCapabilities(), tt.wantCapabilities) {
+				t.Errorf("IdentityServer.GetPluginCapabilities() = %v, want %v", gotResponse.GetCapabilities(), tt.wantCapabilities)
+			}
+		})
 	}
 }
 
@@ -149,6 +167,7 @@ func TestIdentityServer_Probe(t *testing.T) {
 	tests := []struct {
 		name        string
 		driverReady bool
+		unmet       []string
 		wantReady   bool
 	}{
 		{
@@ -161,6 +180,18 @@ func TestIdentityServer_Probe(t *testing.T) {
 			driverReady: false,
 			wantReady:   false,
 		},
+		{
+			name:        "Transient conditions are not met",
+			driverReady: true,
+			unmet:       []string{ConditionAPIReachable, ConditionMountsHealthy},
+			wantReady:   true,
+		},
+		{
+			name:        "Fatal condition is not met",
+			driverReady: true,
+			unmet:       []string{ConditionMetadata},
+			wantReady:   false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -170,6 +201,9 @@ func TestIdentityServer_Probe(t *testing.T) {
 					ready: tt.driverReady,
 				},
 			}
+			for _, name := range tt.unmet {
+				linodeIdentity.driver.conditions.set(name, errors.New("unmet"))
+			}
 			gotResponse, err := linodeIdentity.Probe(context.Background(), &csi.ProbeRequest{})
 
 			if err != nil {
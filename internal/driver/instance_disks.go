@@ -0,0 +1,87 @@
+package driver
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/linode/linodego"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+// instanceDiskCacheTTL is how long the disks of the instance of the node are
+// used without listing them again.
+const instanceDiskCacheTTL = time.Minute
+
+// instanceDiskCache holds the disks of the instance of the node, listed to
+// compute the number of volumes that can be attached to it, so that the
+// NodeGetInfo calls of the kubelet, on each registration, and of the sidecars
+// calling it periodically do not all list them. The disks of an instance
+// rarely change: a watcher of the disks calls invalidate when they do, so
+// that they are listed again right away.
+//
+// The zero value is ready to use.
+type instanceDiskCache struct {
+	mu     sync.Mutex
+	disks  []linodego.InstanceDisk
+	listed time.Time
+	// generation is incremented by invalidate, so that the disks listed
+	// before are not recorded.
+	generation int
+}
+
+// get returns the disks of the instance, if they were listed less than
+// [instanceDiskCacheTTL] ago, and the generation to record them again with.
+func (c *instanceDiskCache) get() ([]linodego.InstanceDisk, int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.listed.IsZero() || time.Since(c.listed) > instanceDiskCacheTTL {
+		return nil, c.generation, false
+	}
+	return slices.Clone(c.disks), c.generation, true
+}
+
+// set records the disks of the instance, listed since get returned
+// generation.
+func (c *instanceDiskCache) set(disks []linodego.InstanceDisk, generation int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	c.disks, c.listed = slices.Clone(disks), time.Now()
+}
+
+// invalidate drops the disks of the instance, which are listed again by the
+// next NodeGetInfo call. It is the hook for the watchers of the disks of the
+// instance.
+func (c *instanceDiskCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.disks, c.listed = nil, time.Time{}
+	c.generation++
+}
+
+// instanceDisks returns the disks of the instance of the node, from the
+// cache if they were listed recently.
+func (ns *NodeServer) instanceDisks(ctx context.Context) ([]linodego.InstanceDisk, error) {
+	log := logger.GetLogger(ctx)
+	disks, generation, ok := ns.disks.get()
+	if ok {
+		log.V(4).Info("Using cached instance disks", "nodeID", ns.metadata.ID, "disks", len(disks))
+		return disks, nil
+	}
+
+	log.V(4).Info("Listing instance disks", "nodeID", ns.metadata.ID)
+	disks, err := ns.client.ListInstanceDisks(ctx, ns.metadata.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+	ns.disks.set(disks, generation)
+	return disks, nil
+}
@@ -0,0 +1,49 @@
+package driver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/linode/linodego"
+	"go.uber.org/mock/gomock"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+)
+
+func TestNodeServer_instanceDisks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockClient := mocks.NewMockLinodeClient(ctrl)
+	ns := &NodeServer{client: mockClient, metadata: Metadata{ID: 10}}
+	check := func(want int) {
+		t.Helper()
+		if disks, err := ns.instanceDisks(ctx); err != nil || len(disks) != want {
+			t.Errorf("instanceDisks() = %v, %v, want %d disks", disks, err, want)
+		}
+	}
+
+	// Listed once while cached
+	mockClient.EXPECT().ListInstanceDisks(gomock.Any(), 10, nil).Return([]linodego.InstanceDisk{{ID: 1}}, nil)
+	check(1)
+	check(1)
+
+	// Listed again once invalidated, or expired
+	mockClient.EXPECT().ListInstanceDisks(gomock.Any(), 10, nil).Return([]linodego.InstanceDisk{{ID: 1}, {ID: 2}}, nil)
+	ns.disks.invalidate()
+	check(2)
+	check(2)
+	mockClient.EXPECT().ListInstanceDisks(gomock.Any(), 10, nil).Return([]linodego.InstanceDisk{{ID: 1}}, nil)
+	ns.disks.listed = time.Now().Add(-2 * instanceDiskCacheTTL)
+	check(1)
+
+	// Disks listed before an invalidation are not recorded
+	_, generation, _ := ns.disks.get()
+	ns.disks.invalidate()
+	ns.disks.set([]linodego.InstanceDisk{{ID: 3}}, generation)
+	if _, _, ok := ns.disks.get(); ok {
+		t.Error("disks listed before the invalidation were recorded")
+	}
+}
@@ -0,0 +1,169 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+const (
+	// ReadIOPSAttribute, WriteIOPSAttribute, ReadBPSAttribute and
+	// WriteBPSAttribute are the StorageClass parameter keys limiting the
+	// read and write operations and bytes per second that the pods using
+	// volumes can issue to their device, so that a noisy pod does not
+	// starve the other volumes of its node. They are passed to the node
+	// plugin through the volume context, which sets them in the io.max file
+	// of the cgroup v2 of the pod when the volume is published.
+	ReadIOPSAttribute  = Name + "/riops"
+	WriteIOPSAttribute = Name + "/wiops"
+	ReadBPSAttribute   = Name + "/rbps"
+	WriteBPSAttribute  = Name + "/wbps"
+
+	// podUIDContextKey is the key of the UID of the pod a volume is
+	// published for in the volume context, set by the kubelet since the
+	// CSIDriver of the driver has podInfoOnMount.
+	podUIDContextKey = "csi.storage.k8s.io/pod.uid"
+)
+
+// ioThrottleKeys maps the throttling parameters to their keys in io.max.
+var ioThrottleKeys = []struct {
+	attribute string
+	key       string
+}{
+	{ReadIOPSAttribute, "riops"},
+	{WriteIOPSAttribute, "wiops"},
+	{ReadBPSAttribute, "rbps"},
+	{WriteBPSAttribute, "wbps"},
+}
+
+// defaultCgroupRoot is the mount point of the cgroup v2 hierarchy used when
+// [Options.CgroupRoot] is not set.
+const defaultCgroupRoot = "/sys/fs/cgroup"
+
+// Results of throttling the device of a published volume, used as the
+// "result" label of the csi_io_throttles_total metric.
+const (
+	ioThrottleApplied     = "applied"
+	ioThrottleUnsupported = "unsupported"
+	ioThrottleFailed      = "failed"
+)
+
+// validateIOThrottle checks the throttling parameters of a volume.
+func validateIOThrottle(parameters map[string]string) error {
+	for _, k := range ioThrottleKeys {
+		if value, ok := parameters[k.attribute]; ok {
+			if limit, err := strconv.ParseUint(value, 10, 64); err != nil || limit == 0 {
+				return errInvalidIOThrottle(k.attribute, value)
+			}
+		}
+	}
+	return nil
+}
+
+// ioMaxLimits returns the limits of the io.max line of the volume with
+// volumeContext, e.g. "riops=1000 wbps=52428800", or "" if it is not
+// throttled.
+func ioMaxLimits(volumeContext map[string]string) string {
+	var limits []string
+	for _, k := range ioThrottleKeys {
+		if value := volumeContext[k.attribute]; value != "" {
+			limits = append(limits, k.key+"="+value)
+		}
+	}
+	return strings.Join(limits, " ")
+}
+
+// throttlePublishedVolume limits the I/O of the pod a volume was published
+// for on the device of the volume, published at its target path, as the
+// throttling parameters of its volume context request. It does nothing when
+// the node does not use cgroup v2, the pod or its cgroup cannot be found, or
+// the io controller is not enabled for it. Failures are logged and counted
+// rather than failing NodePublishVolume, since the volume is usable anyway.
+func (ns *NodeServer) throttlePublishedVolume(ctx context.Context, req *csi.NodePublishVolumeRequest) {
+	limits := ioMaxLimits(req.GetVolumeContext())
+	if limits == "" {
+		return
+	}
+	log := logger.GetLogger(ctx).Klogr.WithValues("volumeID", req.GetVolumeId(), "limits", limits)
+
+	if err := validateIOThrottle(req.GetVolumeContext()); err != nil {
+		log.Error(err, "Not throttling the volume")
+		observability.IOThrottlesTotal.WithLabelValues(ioThrottleFailed).Inc()
+		return
+	}
+
+	cgroup, reason := ns.podCgroup(req.GetVolumeContext()[podUIDContextKey])
+	if reason != "" {
+		log.V(2).Info("Not throttling the volume", "reason", reason)
+		observability.IOThrottlesTotal.WithLabelValues(ioThrottleUnsupported).Inc()
+		return
+	}
+
+	device, err := deviceNumber(req.GetTargetPath())
+	if err != nil {
+		log.Error(err, "Failed to find the device of the volume to throttle it", "targetPath", req.GetTargetPath())
+		observability.IOThrottlesTotal.WithLabelValues(ioThrottleFailed).Inc()
+		return
+	}
+	if err := os.WriteFile(filepath.Join(cgroup, "io.max"), []byte(device+" "+limits), 0); err != nil {
+		log.Error(err, "Failed to throttle the volume", "cgroup", cgroup, "device", device)
+		observability.IOThrottlesTotal.WithLabelValues(ioThrottleFailed).Inc()
+		return
+	}
+	log.V(2).Info("Throttled the volume", "cgroup", cgroup, "device", device)
+	observability.IOThrottlesTotal.WithLabelValues(ioThrottleApplied).Inc()
+}
+
+// podCgroup returns the cgroup v2 directory of the pod with podUID, whose
+// io.max file throttles it. If it cannot be throttled, it returns why.
+func (ns *NodeServer) podCgroup(podUID string) (cgroup, reason string) {
+	if podUID == "" {
+		return "", "the UID of the pod is not in the volume context"
+	}
+	root := defaultCgroupRoot
+	if ns.driver != nil && ns.driver.opts.CgroupRoot != "" {
+		root = ns.driver.opts.CgroupRoot
+	}
+	// Only the root of cgroup v2 hierarchies has cgroup.controllers
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err != nil {
+		return "", "the node does not use cgroup v2 at " + root
+	}
+
+	for _, dir := range podCgroupCandidates(root, podUID) {
+		controllers, err := os.ReadFile(filepath.Join(dir, "cgroup.controllers"))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil || !slices.Contains(strings.Fields(string(controllers)), "io") {
+			return "", "the io controller is not enabled for the cgroup of the pod " + dir
+		}
+		return dir, ""
+	}
+	return "", "the cgroup of the pod is not under " + root
+}
+
+// podCgroupCandidates returns the directories the kubelet may have created
+// the cgroup of the pod with podUID in, under root, for each QoS class of
+// pods, with the cgroupfs and systemd cgroup drivers.
+func podCgroupCandidates(root, podUID string) []string {
+	systemdUID := strings.ReplaceAll(podUID, "-", "_")
+	var candidates []string
+	for _, qos := range []string{"", "burstable", "besteffort"} {
+		cgroupfs := filepath.Join(root, "kubepods", qos, "pod"+podUID)
+		systemd := filepath.Join(root, "kubepods.slice", "kubepods-pod"+systemdUID+".slice")
+		if qos != "" {
+			systemd = filepath.Join(root, "kubepods.slice", "kubepods-"+qos+".slice", "kubepods-"+qos+"-pod"+systemdUID+".slice")
+		}
+		candidates = append(candidates, cgroupfs, systemd)
+	}
+	return candidates
+}
@@ -0,0 +1,165 @@
+package driver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+func TestValidateIOThrottle(t *testing.T) {
+	tests := []struct {
+		name       string
+		parameters map[string]string
+		wantErr    bool
+	}{
+		{name: "No limits"},
+		{name: "Limits", parameters: map[string]string{ReadIOPSAttribute: "1000", WriteBPSAttribute: "52428800"}},
+		{name: "Zero", parameters: map[string]string{WriteIOPSAttribute: "0"}, wantErr: true},
+		{name: "Negative", parameters: map[string]string{ReadBPSAttribute: "-1"}, wantErr: true},
+		{name: "Not a number", parameters: map[string]string{ReadIOPSAttribute: "max"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateIOThrottle(tt.parameters); (err != nil) != tt.wantErr {
+				t.Errorf("validateIOThrottle() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestThrottlePublishedVolume(t *testing.T) {
+	const podUID = "0a1b2c3d-4e5f-6789-abcd-ef0123456789"
+
+	defaultDeviceNumber := deviceNumber
+	t.Cleanup(func() { deviceNumber = defaultDeviceNumber })
+	deviceNumber = func(string) (string, error) { return "8:16", nil }
+
+	tests := []struct {
+		name          string
+		cgroupV2      bool
+		podCgroup     string
+		controllers   string
+		volumeContext map[string]string
+		wantIOMax     string
+		wantResult    string
+	}{
+		{
+			name:        "Systemd driver",
+			cgroupV2:    true,
+			podCgroup:   "kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod0a1b2c3d_4e5f_6789_abcd_ef0123456789.slice",
+			controllers: "cpu io memory pids",
+			volumeContext: map[string]string{
+				podUIDContextKey:   podUID,
+				ReadIOPSAttribute:  "1000",
+				WriteBPSAttribute:  "52428800",
+				WriteIOPSAttribute: "500",
+			},
+			wantIOMax:  "8:16 riops=1000 wiops=500 wbps=52428800",
+			wantResult: ioThrottleApplied,
+		},
+		{
+			name:          "Cgroupfs driver",
+			cgroupV2:      true,
+			podCgroup:     "kubepods/pod" + podUID,
+			controllers:   "io memory",
+			volumeContext: map[string]string{podUIDContextKey: podUID, ReadBPSAttribute: "1048576"},
+			wantIOMax:     "8:16 rbps=1048576",
+			wantResult:    ioThrottleApplied,
+		},
+		{
+			name:          "No limits",
+			cgroupV2:      true,
+			podCgroup:     "kubepods/pod" + podUID,
+			controllers:   "io memory",
+			volumeContext: map[string]string{podUIDContextKey: podUID},
+		},
+		{
+			name:          "Cgroup v1",
+			podCgroup:     "kubepods/pod" + podUID,
+			controllers:   "io memory",
+			volumeContext: map[string]string{podUIDContextKey: podUID, ReadIOPSAttribute: "1000"},
+			wantResult:    ioThrottleUnsupported,
+		},
+		{
+			name:          "No io controller",
+			cgroupV2:      true,
+			podCgroup:     "kubepods/besteffort/pod" + podUID,
+			controllers:   "cpu memory",
+			volumeContext: map[string]string{podUIDContextKey: podUID, ReadIOPSAttribute: "1000"},
+			wantResult:    ioThrottleUnsupported,
+		},
+		{
+			name:          "Pod cgroup not found",
+			cgroupV2:      true,
+			podCgroup:     "kubepods/podother",
+			controllers:   "io memory",
+			volumeContext: map[string]string{podUIDContextKey: podUID, ReadIOPSAttribute: "1000"},
+			wantResult:    ioThrottleUnsupported,
+		},
+		{
+			name:          "No pod UID",
+			cgroupV2:      true,
+			podCgroup:     "kubepods/pod" + podUID,
+			controllers:   "io memory",
+			volumeContext: map[string]string{ReadIOPSAttribute: "1000"},
+			wantResult:    ioThrottleUnsupported,
+		},
+		{
+			name:          "Invalid limit",
+			cgroupV2:      true,
+			podCgroup:     "kubepods/pod" + podUID,
+			controllers:   "io memory",
+			volumeContext: map[string]string{podUIDContextKey: podUID, ReadIOPSAttribute: "0"},
+			wantResult:    ioThrottleFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			if tt.cgroupV2 {
+				if err := os.WriteFile(filepath.Join(root, "cgroup.controllers"), []byte("cpu io memory pids"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cgroup := filepath.Join(root, tt.podCgroup)
+			if err := os.MkdirAll(cgroup, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(cgroup, "cgroup.controllers"), []byte(tt.controllers), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			var before float64
+			if tt.wantResult != "" {
+				before = testutil.ToFloat64(observability.IOThrottlesTotal.WithLabelValues(tt.wantResult))
+			}
+			ns := &NodeServer{driver: &LinodeDriver{opts: Options{CgroupRoot: root}}}
+			ns.throttlePublishedVolume(context.Background(), &csi.NodePublishVolumeRequest{
+				VolumeId:      "1001-vol",
+				TargetPath:    "/var/lib/kubelet/pods/" + podUID + "/volumes/kubernetes.io~csi/pvc/mount",
+				VolumeContext: tt.volumeContext,
+			})
+
+			ioMax, err := os.ReadFile(filepath.Join(cgroup, "io.max"))
+			if err != nil && !os.IsNotExist(err) {
+				t.Fatal(err)
+			}
+			if string(ioMax) != tt.wantIOMax {
+				t.Errorf("io.max = %q, want %q", ioMax, tt.wantIOMax)
+			}
+			if tt.wantResult != "" {
+				if got := testutil.ToFloat64(observability.IOThrottlesTotal.WithLabelValues(tt.wantResult)); got != before+1 {
+					t.Errorf("csi_io_throttles_total{result=%q} = %v, want %v", tt.wantResult, got, before+1)
+				}
+			}
+		})
+	}
+}
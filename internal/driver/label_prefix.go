@@ -0,0 +1,154 @@
+package driver
+
+import (
+	"context"
+	"slices"
+
+	"github.com/linode/linodego"
+
+	kubeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/kube-client"
+	linodeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-client"
+	linodevolumes "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-volumes"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+// previousPrefixLabels returns the labels of volumeLabels made with the
+// [Options.PreviousVolumeLabelPrefix], or nil if it is not set.
+func (d *LinodeDriver) previousPrefixLabels(name, pvcNamespace, pvcName string) []string {
+	if d.opts.PreviousVolumeLabelPrefix == "" || d.opts.PreviousVolumeLabelPrefix == d.volumeLabelPrefix {
+		return nil
+	}
+	label, legacyLabel := d.volumeLabelsWithPrefix(d.opts.PreviousVolumeLabelPrefix, name, pvcNamespace, pvcName)
+	if legacyLabel == "" {
+		return []string{label}
+	}
+	return []string{label, legacyLabel}
+}
+
+// previousPrefixVolume returns the first of previousLabels a volume has, if
+// no volume has label, so that the volume created for the request before the
+// volume label prefix changed is returned instead of creating another one.
+// It returns label otherwise, including when the volume belongs to another
+// cluster.
+func (cs *ControllerServer) previousPrefixVolume(ctx context.Context, label string, previousLabels []string) (string, error) {
+	if len(previousLabels) == 0 {
+		return label, nil
+	}
+	log := logger.GetLogger(ctx)
+	log.V(4).Info("Entering previousPrefixVolume()", "label", label, "previousLabels", previousLabels)
+	defer log.V(4).Info("Exiting previousPrefixVolume()")
+
+	volumes, err := cs.listVolumesByLabel(ctx, label)
+	if err != nil || len(volumes) > 0 {
+		return label, err
+	}
+	cluster, _ := cs.driver.clusterAttribute()
+	for _, previousLabel := range previousLabels {
+		volumes, err := cs.listVolumesByLabel(ctx, previousLabel)
+		if err != nil {
+			return label, err
+		}
+		if len(volumes) != 1 {
+			continue
+		}
+		if hash, ok := getAttribute(volumes[0].Tags, attributeCluster); ok && hash != cluster.value {
+			log.V(4).Info("Volume with the previous label prefix belongs to another cluster", "volume_id", volumes[0].ID, "tags", volumes[0].Tags)
+			continue
+		}
+		log.V(2).Info("Found volume created with the previous volume label prefix", "volume_id", volumes[0].ID, "label", previousLabel)
+		return previousLabel, nil
+	}
+	return label, nil
+}
+
+// VolumeLabelMigration is the rename of the volume of a PersistentVolume
+// from its label made with the previous volume label prefix to the label
+// made with the current one.
+type VolumeLabelMigration struct {
+	PersistentVolume string
+	VolumeID         int
+	Label            string
+	NewLabel         string
+
+	pv kubeclient.PersistentVolume
+}
+
+// LabelPrefixMigration renames the volumes of the PersistentVolumes of the
+// driver whose labels were made with the [Options.PreviousVolumeLabelPrefix]
+// after the current volume label prefix, as CreateVolume would name them.
+//
+// The volume handles of the PersistentVolumes keep the previous labels:
+// volumes are identified by the IDs of the handles, and the node plugin finds
+// the devices of renamed volumes by their current label, as it does for the
+// volumes renamed by the [SyncVolumeLabelAnnotation].
+type LabelPrefixMigration struct {
+	driver *LinodeDriver
+	client linodeclient.LinodeClient
+	kube   kubeclient.KubeClient
+}
+
+// NewLabelPrefixMigration returns the migration of the labels of the volumes
+// from the [Options.PreviousVolumeLabelPrefix] to prefix. The labels are made
+// with the [Options.ClusterName] and [Options.VolumeNameTemplate] of opts,
+// which must be those of the controller.
+func NewLabelPrefixMigration(client linodeclient.LinodeClient, kube kubeclient.KubeClient, prefix string, opts Options) (*LabelPrefixMigration, error) {
+	if err := ValidateVolumeLabelPrefix(prefix); err != nil {
+		return nil, err
+	}
+	if err := ValidateVolumeLabelPrefix(opts.PreviousVolumeLabelPrefix); err != nil {
+		return nil, err
+	}
+	return &LabelPrefixMigration{
+		driver: &LinodeDriver{name: Name, volumeLabelPrefix: prefix, opts: opts},
+		client: client,
+		kube:   kube,
+	}, nil
+}
+
+// Plan returns the volumes to rename. The volumes already renamed, and those
+// whose label was not made with the previous prefix, e.g. renamed since, are
+// left alone.
+func (m *LabelPrefixMigration) Plan(ctx context.Context) ([]VolumeLabelMigration, error) {
+	pvs, err := m.kube.ListPersistentVolumes(ctx, m.driver.name)
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []VolumeLabelMigration
+	for _, pv := range pvs {
+		previousLabels := m.driver.previousPrefixLabels(pv.Name, pv.ClaimNamespace, pv.ClaimName)
+		if len(previousLabels) == 0 {
+			continue
+		}
+		key, err := linodevolumes.ParseLinodeVolumeKey(pv.VolumeHandle)
+		if err != nil {
+			continue
+		}
+		volume, err := m.client.GetVolume(ctx, key.VolumeID)
+		if linodego.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		label, _ := m.driver.volumeLabels(pv.Name, pv.ClaimNamespace, pv.ClaimName)
+		if volume.Label != label && slices.Contains(previousLabels, volume.Label) {
+			migrations = append(migrations, VolumeLabelMigration{
+				PersistentVolume: pv.Name,
+				VolumeID:         volume.ID,
+				Label:            volume.Label,
+				NewLabel:         label,
+				pv:               pv,
+			})
+		}
+	}
+	return migrations, nil
+}
+
+// Apply renames the volume of migration, and records its label in the
+// [VolumeLabelAnnotation] of its PersistentVolume.
+func (m *LabelPrefixMigration) Apply(ctx context.Context, migration VolumeLabelMigration) error {
+	syncer := newVolumeLabelSyncer(m.kube, m.client, m.driver, 0)
+	return syncer.syncVolumeLabel(ctx, migration.pv, migration.NewLabel)
+}
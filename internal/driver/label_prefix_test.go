@@ -0,0 +1,181 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/linode/linodego"
+	"go.uber.org/mock/gomock"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+	kubeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/kube-client"
+)
+
+func TestPreviousPrefixLabels(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{
+			name: "No previous prefix",
+		},
+		{
+			name: "Same prefix",
+			opts: Options{PreviousVolumeLabelPrefix: "new-"},
+		},
+		{
+			name: "Previous prefix",
+			opts: Options{PreviousVolumeLabelPrefix: "old-"},
+			want: []string{"old-pvc-0a1b2c3d"},
+		},
+		{
+			name: "Previous prefix with a cluster name",
+			opts: Options{PreviousVolumeLabelPrefix: "old-", ClusterName: "cluster-a"},
+			want: []string{"old-pvc-0a1b2c3d-34ab3e", "old-pvc-0a1b2c3d"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &LinodeDriver{volumeLabelPrefix: "new-", opts: tt.opts}
+			if got := d.previousPrefixLabels("pvc-0a1b2c3d", "", ""); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("previousPrefixLabels() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreviousPrefixVolume(t *testing.T) {
+	const (
+		label         = "new-pvc-0a1b2c3d"
+		previousLabel = "old-pvc-0a1b2c3d"
+	)
+	labelFilter := linodego.NewListOptions(0, `{"label":"`+label+`"}`)
+	previousFilter := linodego.NewListOptions(0, `{"label":"`+previousLabel+`"}`)
+
+	tests := []struct {
+		name           string
+		previousLabels []string
+		setupMocks     func(*mocks.MockLinodeClient)
+		expectedLabel  string
+		expectedError  bool
+	}{
+		{
+			name:          "No previous prefix",
+			expectedLabel: label,
+		},
+		{
+			name:           "Volume with the label",
+			previousLabels: []string{previousLabel},
+			setupMocks: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().ListVolumes(gomock.Any(), labelFilter).Return([]linodego.Volume{{ID: 1, Label: label}}, nil)
+			},
+			expectedLabel: label,
+		},
+		{
+			name:           "Volume with the previous label",
+			previousLabels: []string{previousLabel},
+			setupMocks: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().ListVolumes(gomock.Any(), labelFilter).Return(nil, nil)
+				m.EXPECT().ListVolumes(gomock.Any(), previousFilter).Return([]linodego.Volume{{ID: 1, Label: previousLabel}}, nil)
+			},
+			expectedLabel: previousLabel,
+		},
+		{
+			name:           "Volume of another cluster",
+			previousLabels: []string{previousLabel},
+			setupMocks: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().ListVolumes(gomock.Any(), labelFilter).Return(nil, nil)
+				m.EXPECT().ListVolumes(gomock.Any(), previousFilter).Return([]linodego.Volume{{ID: 1, Label: previousLabel, Tags: []string{"csi-cluster:ebe9fb"}}}, nil)
+			},
+			expectedLabel: label,
+		},
+		{
+			name:           "No volume",
+			previousLabels: []string{previousLabel},
+			setupMocks: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().ListVolumes(gomock.Any(), labelFilter).Return(nil, nil)
+				m.EXPECT().ListVolumes(gomock.Any(), previousFilter).Return(nil, nil)
+			},
+			expectedLabel: label,
+		},
+		{
+			name:           "List error",
+			previousLabels: []string{previousLabel},
+			setupMocks: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().ListVolumes(gomock.Any(), labelFilter).Return(nil, nil)
+				m.EXPECT().ListVolumes(gomock.Any(), previousFilter).Return(nil, errors.New("API error"))
+			},
+			expectedLabel: label,
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockClient := mocks.NewMockLinodeClient(ctrl)
+			if tt.setupMocks != nil {
+				tt.setupMocks(mockClient)
+			}
+			cs := &ControllerServer{client: mockClient, driver: &LinodeDriver{}}
+
+			got, err := cs.previousPrefixVolume(context.Background(), label, tt.previousLabels)
+			if (err != nil) != tt.expectedError {
+				t.Errorf("previousPrefixVolume() error = %v, wantErr %v", err, tt.expectedError)
+			}
+			if got != tt.expectedLabel {
+				t.Errorf("previousPrefixVolume() = %q, want %q", got, tt.expectedLabel)
+			}
+		})
+	}
+}
+
+func TestLabelPrefixMigration(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mocks.NewMockLinodeClient(ctrl)
+	mockKube := mocks.NewMockKubeClient(ctrl)
+	mockKube.EXPECT().ListPersistentVolumes(gomock.Any(), Name).Return([]kubeclient.PersistentVolume{
+		{Name: "pvc-1", VolumeHandle: "1001-old-pvc-1"},
+		{Name: "pvc-2", VolumeHandle: "1002-old-pvc-2"},
+		{Name: "pvc-3", VolumeHandle: "1003-custom"},
+		{Name: "pvc-4", VolumeHandle: "1004-old-pvc-4"},
+		{Name: "static", VolumeHandle: "not-a-volume-key"},
+	}, nil)
+	mockClient.EXPECT().GetVolume(gomock.Any(), 1001).Return(&linodego.Volume{ID: 1001, Label: "old-pvc-1"}, nil)
+	// Already renamed
+	mockClient.EXPECT().GetVolume(gomock.Any(), 1002).Return(&linodego.Volume{ID: 1002, Label: "new-pvc-2"}, nil)
+	// Renamed by hand
+	mockClient.EXPECT().GetVolume(gomock.Any(), 1003).Return(&linodego.Volume{ID: 1003, Label: "custom"}, nil)
+	mockClient.EXPECT().GetVolume(gomock.Any(), 1004).Return(nil, &linodego.Error{Code: 404})
+
+	migration, err := NewLabelPrefixMigration(mockClient, mockKube, "new-", Options{PreviousVolumeLabelPrefix: "old-"})
+	if err != nil {
+		t.Fatalf("NewLabelPrefixMigration() error = %v", err)
+	}
+	migrations, err := migration.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if len(migrations) != 1 || migrations[0].VolumeID != 1001 || migrations[0].Label != "old-pvc-1" || migrations[0].NewLabel != "new-pvc-1" {
+		t.Fatalf("Plan() = %+v, want the rename of volume 1001 to new-pvc-1", migrations)
+	}
+
+	mockClient.EXPECT().GetVolume(gomock.Any(), 1001).Return(&linodego.Volume{ID: 1001, Label: "old-pvc-1"}, nil)
+	mockClient.EXPECT().UpdateVolume(gomock.Any(), 1001, linodego.VolumeUpdateOptions{Label: "new-pvc-1"}).Return(&linodego.Volume{}, nil)
+	mockKube.EXPECT().PatchPersistentVolumeAnnotations(gomock.Any(), "pvc-1", map[string]string{VolumeLabelAnnotation: "new-pvc-1"}).Return(nil)
+	if err := migration.Apply(context.Background(), migrations[0]); err != nil {
+		t.Errorf("Apply() error = %v", err)
+	}
+
+	if _, err := NewLabelPrefixMigration(mockClient, mockKube, "new-", Options{PreviousVolumeLabelPrefix: "old prefix"}); err == nil {
+		t.Error("NewLabelPrefixMigration() succeeded with an invalid previous prefix")
+	}
+}
@@ -0,0 +1,119 @@
+package driver
+
+import (
+	"context"
+	"time"
+
+	"github.com/linode/linodego"
+
+	kubeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/kube-client"
+	linodeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-client"
+	linodevolumes "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-volumes"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+const (
+	// SyncVolumeLabelAnnotation is the PersistentVolume annotation that, set
+	// to "true", makes the controller rename the Linode volume of the
+	// PersistentVolume after it, as CreateVolume would have named it. This
+	// keeps the labels shown in Cloud Manager consistent when volumes are
+	// restored or migrated under a new PersistentVolume name.
+	SyncVolumeLabelAnnotation = Name + "/sync-volume-label"
+
+	// VolumeLabelAnnotation is the PersistentVolume annotation the
+	// controller writes the label of the Linode volume to once it was
+	// renamed.
+	VolumeLabelAnnotation = Name + "/volume-label"
+)
+
+// volumeLabelSyncer periodically renames the Linode volumes of the
+// PersistentVolumes with the [SyncVolumeLabelAnnotation].
+//
+// The volume handle of a PersistentVolume cannot change, so it keeps the
+// label the volume was created with. Volumes are only identified by the ID
+// in the handle, and the node plugin finds the devices of renamed volumes by
+// their current label.
+type volumeLabelSyncer struct {
+	kube     kubeclient.KubeClient
+	client   linodeclient.LinodeClient
+	driver   *LinodeDriver
+	interval time.Duration
+}
+
+func newVolumeLabelSyncer(kube kubeclient.KubeClient, client linodeclient.LinodeClient, driver *LinodeDriver, interval time.Duration) *volumeLabelSyncer {
+	return &volumeLabelSyncer{
+		kube:     kube,
+		client:   client,
+		driver:   driver,
+		interval: interval,
+	}
+}
+
+// run syncs the volume labels every interval until ctx is canceled.
+func (s *volumeLabelSyncer) run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.sync(ctx)
+		}
+	}
+}
+
+// sync renames the volumes of the annotated PersistentVolumes whose label
+// does not match their name.
+func (s *volumeLabelSyncer) sync(ctx context.Context) {
+	log := logger.GetLogger(ctx)
+	log.V(4).Info("Entering volumeLabelSyncer.sync()")
+	defer log.V(4).Info("Exiting volumeLabelSyncer.sync()")
+
+	pvs, err := s.kube.ListPersistentVolumes(ctx, s.driver.name)
+	if err != nil {
+		log.Error(err, "Failed to list persistent volumes to sync volume labels")
+		return
+	}
+	for _, pv := range pvs {
+		if pv.Annotations[SyncVolumeLabelAnnotation] != True {
+			continue
+		}
+		label, _ := s.driver.volumeLabels(pv.Name, pv.ClaimNamespace, pv.ClaimName)
+		if pv.Annotations[VolumeLabelAnnotation] == label {
+			continue
+		}
+		if err := s.syncVolumeLabel(ctx, pv, label); err != nil {
+			// Try again on the next sync.
+			log.Error(err, "Failed to sync volume label", "pv", pv.Name, "volumeHandle", pv.VolumeHandle, "label", label)
+		}
+	}
+}
+
+// syncVolumeLabel renames the volume of pv to label, unless it already has
+// it, and records the label in the [VolumeLabelAnnotation] of pv.
+func (s *volumeLabelSyncer) syncVolumeLabel(ctx context.Context, pv kubeclient.PersistentVolume, label string) error {
+	log := logger.GetLogger(ctx)
+
+	key, err := linodevolumes.ParseLinodeVolumeKey(pv.VolumeHandle)
+	if err != nil {
+		return err
+	}
+	volume, err := s.client.GetVolume(ctx, key.VolumeID)
+	if linodego.IsNotFound(err) {
+		log.V(4).Info("Volume of persistent volume not found, not syncing its label", "pv", pv.Name, "volume_id", key.VolumeID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if volume.Label != label {
+		if _, err := s.client.UpdateVolume(ctx, volume.ID, linodego.VolumeUpdateOptions{Label: label}); err != nil {
+			return err
+		}
+		log.V(2).Info("Volume renamed", "volume_id", volume.ID, "pv", pv.Name, "previousLabel", volume.Label, "label", label)
+	}
+	return s.kube.PatchPersistentVolumeAnnotations(ctx, pv.Name, map[string]string{VolumeLabelAnnotation: label})
+}
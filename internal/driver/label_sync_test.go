@@ -0,0 +1,85 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/linode/linodego"
+	"go.uber.org/mock/gomock"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+	kubeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/kube-client"
+)
+
+func TestVolumeLabelSyncer(t *testing.T) {
+	annotated := map[string]string{SyncVolumeLabelAnnotation: True}
+
+	tests := []struct {
+		name       string
+		pv         kubeclient.PersistentVolume
+		setupMocks func(*mocks.MockLinodeClient, *mocks.MockKubeClient)
+	}{
+		{
+			name: "Not annotated",
+			pv:   kubeclient.PersistentVolume{Name: "pvc-restored", VolumeHandle: "1001-pvcoriginal"},
+		},
+		{
+			name: "Already synced",
+			pv: kubeclient.PersistentVolume{
+				Name:         "pvc-restored",
+				Annotations:  map[string]string{SyncVolumeLabelAnnotation: True, VolumeLabelAnnotation: "pvc-restored"},
+				VolumeHandle: "1001-pvcoriginal",
+			},
+		},
+		{
+			name: "Renamed",
+			pv:   kubeclient.PersistentVolume{Name: "pvc-restored", Annotations: annotated, VolumeHandle: "1001-pvcoriginal"},
+			setupMocks: func(m *mocks.MockLinodeClient, k *mocks.MockKubeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(&linodego.Volume{ID: 1001, Label: "pvc-original"}, nil)
+				m.EXPECT().UpdateVolume(gomock.Any(), 1001, linodego.VolumeUpdateOptions{Label: "pvc-restored"}).Return(&linodego.Volume{ID: 1001, Label: "pvc-restored"}, nil)
+				k.EXPECT().PatchPersistentVolumeAnnotations(gomock.Any(), "pvc-restored", map[string]string{VolumeLabelAnnotation: "pvc-restored"}).Return(nil)
+			},
+		},
+		{
+			name: "Label already matches",
+			pv:   kubeclient.PersistentVolume{Name: "pvc-restored", Annotations: annotated, VolumeHandle: "1001-pvcoriginal"},
+			setupMocks: func(m *mocks.MockLinodeClient, k *mocks.MockKubeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(&linodego.Volume{ID: 1001, Label: "pvc-restored"}, nil)
+				k.EXPECT().PatchPersistentVolumeAnnotations(gomock.Any(), "pvc-restored", map[string]string{VolumeLabelAnnotation: "pvc-restored"}).Return(nil)
+			},
+		},
+		{
+			name: "Volume not found",
+			pv:   kubeclient.PersistentVolume{Name: "pvc-restored", Annotations: annotated, VolumeHandle: "1001-pvcoriginal"},
+			setupMocks: func(m *mocks.MockLinodeClient, k *mocks.MockKubeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(nil, &linodego.Error{Code: 404})
+			},
+		},
+		{
+			name: "Rename failed",
+			pv:   kubeclient.PersistentVolume{Name: "pvc-restored", Annotations: annotated, VolumeHandle: "1001-pvcoriginal"},
+			setupMocks: func(m *mocks.MockLinodeClient, k *mocks.MockKubeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(&linodego.Volume{ID: 1001, Label: "pvc-original"}, nil)
+				m.EXPECT().UpdateVolume(gomock.Any(), 1001, gomock.Any()).Return(nil, errors.New("api error"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockClient := mocks.NewMockLinodeClient(ctrl)
+			mockKube := mocks.NewMockKubeClient(ctrl)
+			mockKube.EXPECT().ListPersistentVolumes(gomock.Any(), Name).Return([]kubeclient.PersistentVolume{tt.pv}, nil)
+			if tt.setupMocks != nil {
+				tt.setupMocks(mockClient, mockKube)
+			}
+
+			s := newVolumeLabelSyncer(mockKube, mockClient, &LinodeDriver{name: Name}, 0)
+			s.sync(context.Background())
+		})
+	}
+}
@@ -1,5 +1,18 @@
 package driver
 
+import (
+	"context"
+	"slices"
+	"strconv"
+
+	"github.com/linode/linodego"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+//go:generate go run ../../hack/plan-limits -limits ../../hack/plan-limits/documented_limits.csv -o limits_plans_test.go
+
 // maxVolumeAttachments returns the maximum number of block storage volumes
 // that can be attached to a Linode instance, given the amount of memory the
 // instance has.
@@ -18,6 +31,39 @@ func maxVolumeAttachments(memoryBytes uint) int {
 	return int(attachments)
 }
 
+// volumeAttachmentLimit returns the number of volumes that can be attached to
+// the instance with instanceID, given the amount of memory it has and its
+// disks, each of which takes the place of a volume unless it is excluded by
+// [Options.ExcludedDiskLabels] or [Options.ExcludedDiskFilesystems]. The
+// limit is exported in the csi_volume_attachment_limit metric, so that
+// operators can tell why it differs between nodes.
+func (d *LinodeDriver) volumeAttachmentLimit(ctx context.Context, instanceID int, memoryBytes uint, disks []linodego.InstanceDisk) int {
+	log := logger.GetLogger(ctx)
+
+	counted := 0
+	for _, disk := range disks {
+		if d.excludesDisk(disk) {
+			log.V(4).Info("Not counting instance disk against the volume attachment limit", "instanceID", instanceID, "disk", disk.Label, "filesystem", disk.Filesystem)
+			continue
+		}
+		counted++
+	}
+
+	limit := maxVolumeAttachments(memoryBytes) - counted
+	observability.VolumeAttachmentLimit.WithLabelValues(strconv.Itoa(instanceID)).Set(float64(limit))
+	return limit
+}
+
+// excludesDisk reports whether disk is excluded from the volume attachment
+// limit by its label or file system.
+func (d *LinodeDriver) excludesDisk(disk linodego.InstanceDisk) bool {
+	if d == nil {
+		return false
+	}
+	return slices.Contains(d.opts.ExcludedDiskLabels, disk.Label) ||
+		slices.Contains(d.opts.ExcludedDiskFilesystems, string(disk.Filesystem))
+}
+
 const (
 	// maxPersistentAttachments is the default number of volume attachments
 	// allowed when they are persisted to an instance/boot config. This is
@@ -0,0 +1,68 @@
+// Code generated by hack/plan-limits from hack/plan-limits/documented_limits.csv; DO NOT EDIT.
+
+package driver
+
+import "testing"
+
+func TestMaxVolumeAttachmentsPlans(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		plan     string
+		memoryMB int
+		want     int
+	}{
+		{plan: "g6-nanode-1", memoryMB: 1024, want: 8},
+		{plan: "g6-standard-1", memoryMB: 2048, want: 8},
+		{plan: "g6-dedicated-2", memoryMB: 4096, want: 8},
+		{plan: "g6-standard-2", memoryMB: 4096, want: 8},
+		{plan: "g7-premium-2", memoryMB: 4096, want: 8},
+		{plan: "g6-dedicated-4", memoryMB: 8192, want: 8},
+		{plan: "g6-standard-4", memoryMB: 8192, want: 8},
+		{plan: "g7-premium-4", memoryMB: 8192, want: 8},
+		{plan: "g6-dedicated-8", memoryMB: 16384, want: 16},
+		{plan: "g6-standard-6", memoryMB: 16384, want: 16},
+		{plan: "g7-premium-8", memoryMB: 16384, want: 16},
+		{plan: "g7-highmem-1", memoryMB: 24576, want: 24},
+		{plan: "g1-gpu-rtx6000-1", memoryMB: 32768, want: 32},
+		{plan: "g6-dedicated-16", memoryMB: 32768, want: 32},
+		{plan: "g6-standard-8", memoryMB: 32768, want: 32},
+		{plan: "g7-premium-16", memoryMB: 32768, want: 32},
+		{plan: "g7-highmem-2", memoryMB: 49152, want: 48},
+		{plan: "g1-gpu-rtx6000-2", memoryMB: 65536, want: 64},
+		{plan: "g6-dedicated-32", memoryMB: 65536, want: 64},
+		{plan: "g6-standard-16", memoryMB: 65536, want: 64},
+		{plan: "g7-premium-32", memoryMB: 65536, want: 64},
+		{plan: "g7-highmem-4", memoryMB: 90112, want: 64},
+		{plan: "g1-gpu-rtx6000-3", memoryMB: 98304, want: 64},
+		{plan: "g6-dedicated-48", memoryMB: 98304, want: 64},
+		{plan: "g6-standard-20", memoryMB: 98304, want: 64},
+		{plan: "g7-premium-48", memoryMB: 98304, want: 64},
+		{plan: "g1-gpu-rtx6000-4", memoryMB: 131072, want: 64},
+		{plan: "g6-dedicated-50", memoryMB: 131072, want: 64},
+		{plan: "g6-standard-24", memoryMB: 131072, want: 64},
+		{plan: "g7-premium-50", memoryMB: 131072, want: 64},
+		{plan: "g7-highmem-8", memoryMB: 155648, want: 64},
+		{plan: "g6-standard-32", memoryMB: 196608, want: 64},
+		{plan: "g6-dedicated-56", memoryMB: 262144, want: 64},
+		{plan: "g7-premium-56", memoryMB: 262144, want: 64},
+		{plan: "g7-highmem-16", memoryMB: 307200, want: 64},
+		{plan: "g6-dedicated-64", memoryMB: 524288, want: 64},
+		{plan: "g7-premium-64", memoryMB: 524288, want: 64},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.plan, func(t *testing.T) {
+			t.Parallel()
+
+			// As computed by the controller, from the instance specs
+			if got := maxVolumeAttachments(uint(tt.memoryMB) << 20); got != tt.want {
+				t.Errorf("controller: want=%d got=%d", tt.want, got)
+			}
+			// As computed by the node plugin, from the metadata service
+			if got := maxVolumeAttachments(memoryToBytes(tt.memoryMB)); got != tt.want {
+				t.Errorf("node: want=%d got=%d", tt.want, got)
+			}
+		})
+	}
+}
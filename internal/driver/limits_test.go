@@ -1,8 +1,14 @@
 package driver
 
 import (
+	"context"
 	"fmt"
 	"testing"
+
+	"github.com/linode/linodego"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
 )
 
 func TestMaxVolumeAttachments(t *testing.T) {
@@ -37,3 +43,48 @@ func TestMaxVolumeAttachments(t *testing.T) {
 		})
 	}
 }
+
+func TestVolumeAttachmentLimit(t *testing.T) {
+	disks := []linodego.InstanceDisk{
+		{Label: "boot", Filesystem: linodego.FilesystemExt4},
+		{Label: "swap", Filesystem: linodego.FilesystemSwap},
+		{Label: "scratch", Filesystem: linodego.FilesystemRaw},
+	}
+
+	tests := []struct {
+		name   string
+		driver *LinodeDriver
+		want   int
+	}{
+		{
+			name: "No driver",
+			want: maxPersistentAttachments - 3,
+		},
+		{
+			name:   "No exclusions",
+			driver: &LinodeDriver{},
+			want:   maxPersistentAttachments - 3,
+		},
+		{
+			name:   "Excluded file system",
+			driver: &LinodeDriver{opts: Options{ExcludedDiskFilesystems: []string{"swap"}}},
+			want:   maxPersistentAttachments - 2,
+		},
+		{
+			name:   "Excluded label and file system",
+			driver: &LinodeDriver{opts: Options{ExcludedDiskLabels: []string{"scratch"}, ExcludedDiskFilesystems: []string{"swap"}}},
+			want:   maxPersistentAttachments - 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.driver.volumeAttachmentLimit(context.Background(), 1001, 4<<30, disks); got != tt.want {
+				t.Errorf("volumeAttachmentLimit() = %d, want %d", got, tt.want)
+			}
+			if got := testutil.ToFloat64(observability.VolumeAttachmentLimit.WithLabelValues("1001")); got != float64(tt.want) {
+				t.Errorf("volume attachment limit metric = %v, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+// LogVerbosityPath is the path the verbosity of the logs is served on and
+// changed at, see [logger.VerbosityHandler].
+const LogVerbosityPath = "/verbosity"
+
+// validateLogVerbosityAddress checks that the verbosity of the logs is only
+// served on a loopback address, since its callers are not authenticated.
+func validateLogVerbosityAddress(address string) error {
+	if address == "" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid log verbosity address %q: %w", address, err)
+	}
+	if !isLoopback(host) {
+		return fmt.Errorf("log verbosity address %s must be a loopback address, such as 127.0.0.1", address)
+	}
+	return nil
+}
+
+// serveLogVerbosity serves the verbosity of the logs on address until ctx is
+// canceled.
+func serveLogVerbosity(ctx context.Context, address string) {
+	log := logger.GetLogger(ctx)
+
+	mux := http.NewServeMux()
+	mux.Handle(LogVerbosityPath, logger.VerbosityHandler())
+	server := &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadTimeout:       10 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		IdleTimeout:       60 * time.Second,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		if err := server.Shutdown(context.Background()); err != nil {
+			log.Error(err, "Failed to stop the log verbosity server")
+		}
+	}()
+
+	log.V(2).Info("Serving the log verbosity", "address", address, "path", LogVerbosityPath)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Error(err, "Failed to serve the log verbosity")
+	}
+}
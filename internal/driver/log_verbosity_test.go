@@ -0,0 +1,20 @@
+package driver
+
+import "testing"
+
+func TestValidateLogVerbosityAddress(t *testing.T) {
+	for address, wantErr := range map[string]bool{
+		"":               false,
+		"127.0.0.1:9810": false,
+		"[::1]:9810":     false,
+		"localhost:9810": false,
+		":9810":          true,
+		"0.0.0.0:9810":   true,
+		"10.0.0.1:9810":  true,
+		"127.0.0.1":      true,
+	} {
+		if err := validateLogVerbosityAddress(address); (err != nil) != wantErr {
+			t.Errorf("validateLogVerbosityAddress(%q) error = %v, wantErr %v", address, err, wantErr)
+		}
+	}
+}
@@ -45,6 +45,14 @@ type LuksContext struct {
 	EncryptionKeySize string
 	VolumeName        string
 	VolumeLifecycle   VolumeLifecycle
+	// PreviousEncryptionKey is the key of the volume a clone was cloned
+	// from, replaced with EncryptionKey the first time the clone is staged.
+	PreviousEncryptionKey string
+	// ClonedFrom is the ID of the volume a clone was cloned from.
+	ClonedFrom string
+	// PersistentVolumeName is the name of the PersistentVolume of the
+	// volume, which the lineage of clones is recorded on.
+	PersistentVolumeName string
 }
 
 const (
@@ -62,8 +70,39 @@ const (
 
 	// LuksKeyAttribute is the key of the luks key used in the map of secrets passed from the CO
 	LuksKeyAttribute = "luksKey"
+
+	// LuksPreviousKeyAttribute is the key of the luks key of the volume a clone was
+	// cloned from, in the map of secrets passed from the CO
+	LuksPreviousKeyAttribute = "luksPreviousKey"
+
+	// LuksClonedFromAttribute is used to pass the ID of the volume a luks volume was
+	// cloned from to `NodeStageVolume`
+	LuksClonedFromAttribute = Name + "/luks-cloned-from"
 )
 
+// luksContextLog is a [LuksContext] without its methods, to format it.
+type luksContextLog LuksContext
+
+// redacted replaces the keys of a [LuksContext] in logs.
+const redacted = "[redacted]"
+
+// MarshalLog returns ctx with its keys redacted, so that logging it does not
+// leak them.
+func (ctx LuksContext) MarshalLog() any {
+	if ctx.EncryptionKey != "" {
+		ctx.EncryptionKey = redacted
+	}
+	if ctx.PreviousEncryptionKey != "" {
+		ctx.PreviousEncryptionKey = redacted
+	}
+	return luksContextLog(ctx)
+}
+
+// String formats ctx with its keys redacted.
+func (ctx LuksContext) String() string {
+	return fmt.Sprintf("%+v", ctx.MarshalLog())
+}
+
 func (ctx *LuksContext) validate() error {
 	if !ctx.EncryptionEnabled {
 		return nil
@@ -120,12 +159,22 @@ func getLuksContext(secrets, volContext map[string]string, lifecycle VolumeLifec
 		EncryptionKeySize: luksKeySize,
 		VolumeName:        volumeName,
 		VolumeLifecycle:   lifecycle,
+
+		PreviousEncryptionKey: secrets[LuksPreviousKeyAttribute],
+		ClonedFrom:            volContext[LuksClonedFromAttribute],
+		PersistentVolumeName:  volContext[PVNameParameter],
 	}
 }
 
+// luksDevicePath returns the path of the device mapper device of the open
+// LUKS volume volumeName.
+func luksDevicePath(volumeName string) string {
+	return "/dev/mapper/" + volumeName
+}
+
 func (e *Encryption) luksFormat(ctx context.Context, luksCtx *LuksContext, source string) (devicePath string, err error) {
-	log := logger.GetLogger(ctx)
-	devicePath = "/dev/mapper/" + luksCtx.VolumeName
+	log := logger.GetLogger(ctx).WithComponent(logger.ComponentCryptsetup)
+	devicePath = luksDevicePath(luksCtx.VolumeName)
 
 	// Set params
 	keySize, err := strconv.Atoi(luksCtx.EncryptionKeySize)
@@ -146,6 +195,7 @@ func (e *Encryption) luksFormat(ctx context.Context, luksCtx *LuksContext, sourc
 	if err != nil {
 		return "", fmt.Errorf("initializing luks device to format: %w", err)
 	}
+	defer newLuksDevice.Device.Free()
 
 	// Format the device
 	log.V(4).Info("Formatting luks device", "device path", source)
@@ -159,7 +209,6 @@ func (e *Encryption) luksFormat(ctx context.Context, luksCtx *LuksContext, sourc
 	if err = newLuksDevice.Device.KeyslotAddByVolumeKey(0, "", luksCtx.EncryptionKey); err != nil {
 		return "", fmt.Errorf("adding keysot by volumekey: %w", err)
 	}
-	defer newLuksDevice.Device.Free()
 
 	// Activate the device using the encryption key
 	log.V(4).Info("Activating luks device using volumekey", "device", newLuksDevice.Identifier, "VolumeName", luksCtx.VolumeName)
@@ -174,7 +223,7 @@ func (e *Encryption) luksFormat(ctx context.Context, luksCtx *LuksContext, sourc
 }
 
 func (e *Encryption) luksOpen(ctx context.Context, luksCtx *LuksContext, source string) (string, error) {
-	log := logger.GetLogger(ctx)
+	log := logger.GetLogger(ctx).WithComponent(logger.ComponentCryptsetup)
 
 	// Initialize the device using the path
 	log.V(4).Info("Initializing device to perform luks open", "source", source)
@@ -194,9 +243,11 @@ func (e *Encryption) luksOpen(ctx context.Context, luksCtx *LuksContext, source
 	// Activate the device using the encryption key
 	log.V(4).Info("Activating luks device using volumekey", "device", newLuksDevice.Identifier, "VolumeName", luksCtx.VolumeName)
 	if err := newLuksDevice.Device.ActivateByPassphrase(luksCtx.VolumeName, 0, luksCtx.EncryptionKey, 0); err != nil {
-		var apiErr *cryptsetup.Error
+		// The error is usually a *cryptsetup.Error, but may come from the
+		// host helper, so only rely on it exposing the return code.
+		var apiErr interface{ Code() int }
 		if errors.As(err, &apiErr) && apiErr.Code() == -17 {
-			return "/dev/mapper/" + luksCtx.VolumeName, nil
+			return luksDevicePath(luksCtx.VolumeName), nil
 		}
 		return "", fmt.Errorf("activating %s luks device %s volumekey %s: %w", newLuksDevice.Identifier, luksCtx.VolumeName, luksCtx.EncryptionKey, err)
 	}
@@ -204,34 +255,72 @@ func (e *Encryption) luksOpen(ctx context.Context, luksCtx *LuksContext, source
 	log.V(4).Info("The LUKS volume is now ready ", "volumeName", luksCtx.VolumeName)
 
 	// Return the mapper path
-	return "/dev/mapper/" + luksCtx.VolumeName, nil
+	return luksDevicePath(luksCtx.VolumeName), nil
 }
 
-func (e *Encryption) luksClose(ctx context.Context, volumeName string) error {
-	log := logger.GetLogger(ctx)
+// luksChangeKey replaces the keyslot of the previous key of the LUKS device
+// at source with one of its key, like cryptsetup luksChangeKey, so that the
+// previous key no longer opens it. It returns false if the key already opens
+// the device, e.g. because it was changed by an earlier call.
+func (e *Encryption) luksChangeKey(ctx context.Context, luksCtx *LuksContext, source string) (bool, error) {
+	log := logger.GetLogger(ctx).WithComponent(logger.ComponentCryptsetup)
+
+	// Initialize the device using the path
+	log.V(4).Info("Initializing device to perform luks change key", "source", source)
+	newLuksDevice, err := cryptsetupclient.NewLuksDevice(e.CryptSetup, source)
+	if err != nil {
+		return false, fmt.Errorf("initializing luks device to change its key: %w", err)
+	}
+	defer newLuksDevice.Device.Free()
+
+	// Loading the device
+	log.V(4).Info("Loading luks device", "device", newLuksDevice.Identifier, "VolumeName", luksCtx.VolumeName)
+	if err := newLuksDevice.Device.Load(cryptsetup.LUKS2{SectorSize: 512}); err != nil {
+		return false, fmt.Errorf("loading %s luks device %s: %w", newLuksDevice.Identifier, luksCtx.VolumeName, err)
+	}
+
+	// Activating without a name only checks the passphrase
+	if err := newLuksDevice.Device.ActivateByPassphrase("", cryptsetup.CRYPT_ANY_SLOT, luksCtx.EncryptionKey, 0); err == nil {
+		log.V(4).Info("The key of the luks device is already changed", "device", newLuksDevice.Identifier, "VolumeName", luksCtx.VolumeName)
+		return false, nil
+	}
+
+	log.V(4).Info("Changing the key of the luks device", "device", newLuksDevice.Identifier, "VolumeName", luksCtx.VolumeName)
+	if err := newLuksDevice.Device.KeyslotChangeByPassphrase(cryptsetup.CRYPT_ANY_SLOT, cryptsetup.CRYPT_ANY_SLOT,
+		luksCtx.PreviousEncryptionKey, luksCtx.EncryptionKey); err != nil {
+		return false, fmt.Errorf("changing the previous key of %s luks device %s: %w", newLuksDevice.Identifier, luksCtx.VolumeName, err)
+	}
+	return true, nil
+}
+
+func (e *Encryption) luksClose(ctx context.Context, volumeName string) (err error) {
+	log := logger.GetLogger(ctx).WithComponent(logger.ComponentCryptsetup)
 	// Initialize the device by name
 	log.V(4).Info("Initializing device to perform luks close", "volumeName", volumeName)
-	newLuksDeviceByName, err := cryptsetupclient.NewLuksDeviceByName(e.CryptSetup, volumeName)
-	if err == nil {
-		log.V(4).Info("Initialized device to perform luks close", "volumeName", volumeName)
-
-		// Deactivating the device
-		log.V(4).Info("Deactivating and closing the volume", "volumeName", volumeName)
-		if err := newLuksDeviceByName.Device.Deactivate(volumeName); err != nil {
-			return fmt.Errorf("deactivating %s luks device: %w", volumeName, err)
-		}
+	newLuksDeviceByName, initErr := cryptsetupclient.NewLuksDeviceByName(e.CryptSetup, volumeName)
+	if initErr != nil {
+		log.V(4).Info("device is no longer active", "volumeName", volumeName)
+		return nil
+	}
+	log.V(4).Info("Initialized device to perform luks close", "volumeName", volumeName)
 
-		// Freeing the device
+	// Freeing the device, which also releases its lock, even if it could not
+	// be deactivated
+	defer func() {
 		log.V(4).Info("Releasing/Freeing the device", "volumeName", volumeName)
-		if !newLuksDeviceByName.Device.Free() {
-			return errors.New("could not release/free the luks device")
+		if !newLuksDeviceByName.Device.Free() && err == nil {
+			err = errors.New("could not release/free the luks device")
+			return
 		}
 		log.V(4).Info("Released/Freed the device", "volumeName", volumeName)
+	}()
 
-		log.V(4).Info("Released/Freed and Deactivated/Closed the volume", "volumeName", volumeName)
-		return nil
+	// Deactivating the device
+	log.V(4).Info("Deactivating and closing the volume", "volumeName", volumeName)
+	if err := newLuksDeviceByName.Device.Deactivate(volumeName); err != nil {
+		return fmt.Errorf("deactivating %s luks device: %w", volumeName, err)
 	}
-	log.V(4).Info("device is no longer active", "volumeName", volumeName)
+	log.V(4).Info("Deactivated/Closed the volume", "volumeName", volumeName)
 	return nil
 }
 
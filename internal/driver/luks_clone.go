@@ -0,0 +1,54 @@
+package driver
+
+import (
+	"context"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+// LuksRekeyedFromAnnotation is the PersistentVolume annotation the node
+// plugin writes the ID of the volume a LUKS clone was cloned from to, once
+// the key of the clone replaced the key of its source.
+const LuksRekeyedFromAnnotation = Name + "/luks-rekeyed-from"
+
+// rekeyLUKSClone replaces the key of the volume a LUKS clone at devicePath
+// was cloned from with the key of the clone, the first time the clone is
+// staged with both keys in its secrets, so that the key of the original
+// volume no longer opens the clone. The new header is backed up, and the
+// lineage of the clone recorded on its PersistentVolume.
+//
+// Only the keyslot is replaced, not the volume key the data is encrypted
+// with, which the clone shares with its source.
+func (ns *NodeServer) rekeyLUKSClone(ctx context.Context, devicePath string, luksContext *LuksContext) error {
+	log := logger.GetLogger(ctx)
+	if luksContext.ClonedFrom == "" {
+		log.V(2).Info("Ignoring the previous luks key of a volume that is not a clone", "volumeName", luksContext.VolumeName)
+		return nil
+	}
+
+	changed, err := ns.encrypt.luksChangeKey(ctx, luksContext, devicePath)
+	if err != nil {
+		return errInternal("Failed to change the luks key of the clone (%q): %v", devicePath, err)
+	}
+	if !changed {
+		return nil
+	}
+	log.V(2).Info("Changed the luks key of the clone", "volumeName", luksContext.VolumeName, "clonedFrom", luksContext.ClonedFrom)
+
+	ns.backupLUKSHeader(ctx, luksContext.VolumeName, devicePath)
+	ns.recordLUKSLineage(ctx, luksContext)
+	return nil
+}
+
+// recordLUKSLineage writes the ID of the volume a rekeyed LUKS clone was
+// cloned from to its PersistentVolume, if the node plugin has a Kubernetes
+// client. The clone is usable without it, so failures are only logged.
+func (ns *NodeServer) recordLUKSLineage(ctx context.Context, luksContext *LuksContext) {
+	pvName := luksContext.PersistentVolumeName
+	if ns.driver == nil || ns.driver.opts.KubeClient == nil || pvName == "" {
+		return
+	}
+	if err := ns.driver.opts.KubeClient.PatchPersistentVolumeAnnotations(ctx, pvName, map[string]string{LuksRekeyedFromAnnotation: luksContext.ClonedFrom}); err != nil {
+		logger.GetLogger(ctx).Error(err, "Failed to record the lineage of the luks clone", "pv", pvName)
+	}
+}
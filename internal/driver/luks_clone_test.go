@@ -0,0 +1,126 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	cryptsetup "github.com/martinjungblut/go-cryptsetup"
+	"go.uber.org/mock/gomock"
+	"k8s.io/klog/v2"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+)
+
+func TestRekeyLUKSClone(t *testing.T) {
+	clone := LuksContext{
+		EncryptionEnabled:     true,
+		EncryptionKey:         "new-key",
+		PreviousEncryptionKey: "old-key",
+		VolumeName:            "pvc-clone",
+		ClonedFrom:            "1001",
+		PersistentVolumeName:  "pv-clone",
+	}
+
+	tests := []struct {
+		name        string
+		luksContext LuksContext
+		setup       func(*mocks.MockCryptSetupClient, *mocks.MockDevice, *mocks.MockKubeClient)
+		wantErr     bool
+	}{
+		{
+			name:        "Key of the source replaced",
+			luksContext: clone,
+			setup: func(c *mocks.MockCryptSetupClient, d *mocks.MockDevice, k *mocks.MockKubeClient) {
+				c.EXPECT().Init("/dev/sdb").Return(d, nil)
+				d.EXPECT().Load(gomock.Any()).Return(nil)
+				d.EXPECT().ActivateByPassphrase("", cryptsetup.CRYPT_ANY_SLOT, "new-key", 0).Return(errors.New("no key available with this passphrase"))
+				d.EXPECT().KeyslotChangeByPassphrase(cryptsetup.CRYPT_ANY_SLOT, cryptsetup.CRYPT_ANY_SLOT, "old-key", "new-key").Return(nil)
+				d.EXPECT().Free().Return(true)
+				k.EXPECT().PatchPersistentVolumeAnnotations(gomock.Any(), "pv-clone", map[string]string{LuksRekeyedFromAnnotation: "1001"}).Return(nil)
+			},
+		},
+		{
+			name:        "Key already replaced",
+			luksContext: clone,
+			setup: func(c *mocks.MockCryptSetupClient, d *mocks.MockDevice, k *mocks.MockKubeClient) {
+				c.EXPECT().Init("/dev/sdb").Return(d, nil)
+				d.EXPECT().Load(gomock.Any()).Return(nil)
+				d.EXPECT().ActivateByPassphrase("", cryptsetup.CRYPT_ANY_SLOT, "new-key", 0).Return(nil)
+				d.EXPECT().Free().Return(true)
+			},
+		},
+		{
+			name:        "Neither key opens the clone",
+			luksContext: clone,
+			setup: func(c *mocks.MockCryptSetupClient, d *mocks.MockDevice, k *mocks.MockKubeClient) {
+				c.EXPECT().Init("/dev/sdb").Return(d, nil)
+				d.EXPECT().Load(gomock.Any()).Return(nil)
+				d.EXPECT().ActivateByPassphrase("", cryptsetup.CRYPT_ANY_SLOT, "new-key", 0).Return(errors.New("no key available with this passphrase"))
+				d.EXPECT().KeyslotChangeByPassphrase(cryptsetup.CRYPT_ANY_SLOT, cryptsetup.CRYPT_ANY_SLOT, "old-key", "new-key").Return(errors.New("no key available with this passphrase"))
+				d.EXPECT().Free().Return(true)
+			},
+			wantErr: true,
+		},
+		{
+			name: "Not a clone",
+			luksContext: LuksContext{
+				EncryptionEnabled:     true,
+				EncryptionKey:         "new-key",
+				PreviousEncryptionKey: "old-key",
+				VolumeName:            "pvc-volume",
+			},
+			setup: func(c *mocks.MockCryptSetupClient, d *mocks.MockDevice, k *mocks.MockKubeClient) {},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			cryptSetup := mocks.NewMockCryptSetupClient(ctrl)
+			device := mocks.NewMockDevice(ctrl)
+			kubeClient := mocks.NewMockKubeClient(ctrl)
+			tt.setup(cryptSetup, device, kubeClient)
+
+			ns := &NodeServer{
+				driver:  &LinodeDriver{opts: Options{KubeClient: kubeClient}},
+				encrypt: NewLuksEncryption(mocks.NewMockExecutor(ctrl), mocks.NewMockFileSystem(ctrl), cryptSetup),
+			}
+			err := ns.rekeyLUKSClone(context.Background(), "/dev/sdb", &tt.luksContext)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("rekeyLUKSClone() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLuksContextRedactsKeys(t *testing.T) {
+	luksContext := &LuksContext{
+		EncryptionEnabled:     true,
+		EncryptionKey:         "new-key",
+		PreviousEncryptionKey: "old-key",
+		VolumeName:            "pvc-clone",
+	}
+
+	var buf bytes.Buffer
+	klog.SetOutput(&buf)
+	klog.LogToStderr(false)
+	defer func() {
+		klog.SetOutput(os.Stderr)
+		klog.LogToStderr(true)
+	}()
+	klog.NewKlogr().Info("Entering formatLUKSVolume", "luksContext", luksContext)
+	klog.Flush()
+
+	for _, out := range []string{buf.String(), fmt.Sprint(luksContext), fmt.Sprintf("%v", *luksContext)} {
+		if strings.Contains(out, "new-key") || strings.Contains(out, "old-key") || !strings.Contains(out, "pvc-clone") {
+			t.Errorf("LuksContext formatted as %q, want its volume name without its keys", out)
+		}
+	}
+}
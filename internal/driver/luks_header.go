@@ -0,0 +1,148 @@
+package driver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	cryptsetupclient "github.com/linode/linode-blockstorage-csi-driver/pkg/cryptsetup-client"
+	kubeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/kube-client"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+const (
+	// LUKSHeaderSecretPrefix starts the names of the Secrets holding the
+	// LUKS header backups of volumes, followed by the name of their
+	// PersistentVolume.
+	LUKSHeaderSecretPrefix = "luks-header-"
+
+	// LUKSHeaderSecretKey is the key of the gzip-compressed header in the
+	// Secrets holding LUKS header backups.
+	LUKSHeaderSecretKey = "header"
+
+	// LUKSHeaderLabel labels the Secrets holding LUKS header backups.
+	LUKSHeaderLabel = Name + "/luks-header"
+
+	// luksHeaderFileSuffix is appended to the name of the PersistentVolume
+	// of volumes to get the name of their LUKS header backup file.
+	luksHeaderFileSuffix = ".luks-header"
+)
+
+// LUKSHeaderSink stores the backups of the LUKS headers of the volumes
+// formatted by the node plugin, by the name of their PersistentVolume, so
+// that the headers can be restored when they are corrupted.
+type LUKSHeaderSink interface {
+	StoreLUKSHeader(ctx context.Context, volumeName string, header []byte) error
+}
+
+// secretLUKSHeaderSink stores the LUKS header backups in Secrets. LUKS2
+// headers are 16 MiB, mostly zeroes, so they are compressed to fit.
+type secretLUKSHeaderSink struct {
+	client    kubeclient.KubeClient
+	namespace string
+}
+
+// NewSecretLUKSHeaderSink returns a sink storing the LUKS header backups in
+// the Secrets of namespace named by [LUKSHeaderSecretName], with client.
+func NewSecretLUKSHeaderSink(client kubeclient.KubeClient, namespace string) LUKSHeaderSink {
+	return &secretLUKSHeaderSink{client: client, namespace: namespace}
+}
+
+func (s *secretLUKSHeaderSink) StoreLUKSHeader(ctx context.Context, volumeName string, header []byte) error {
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return s.client.ApplySecret(ctx, s.namespace, LUKSHeaderSecretName(volumeName),
+		map[string]string{LUKSHeaderLabel: True},
+		map[string]string{PublishInfoVolumeName: volumeName},
+		map[string][]byte{LUKSHeaderSecretKey: compressed.Bytes()})
+}
+
+// directoryLUKSHeaderSink stores the LUKS header backups in files, e.g. on
+// a volume mounted from a backup server.
+type directoryLUKSHeaderSink struct {
+	dir string
+}
+
+// NewDirectoryLUKSHeaderSink returns a sink storing the LUKS header backups
+// in dir, in files named after the PersistentVolume of the volumes with a
+// ".luks-header" suffix, as made by cryptsetup luksHeaderBackup.
+func NewDirectoryLUKSHeaderSink(dir string) LUKSHeaderSink {
+	return &directoryLUKSHeaderSink{dir: dir}
+}
+
+func (s *directoryLUKSHeaderSink) StoreLUKSHeader(_ context.Context, volumeName string, header []byte) error {
+	path := filepath.Join(s.dir, filepath.Base(volumeName)+luksHeaderFileSuffix)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, header, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// gzipMagic starts gzip-compressed data.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// invalidSecretNameChars are the characters not allowed in the names of
+// Secrets.
+var invalidSecretNameChars = regexp.MustCompile(`[^a-z0-9.-]`)
+
+// LUKSHeaderSecretName returns the name of the Secret holding the LUKS header
+// backup of the volume of the PersistentVolume volumeName.
+func LUKSHeaderSecretName(volumeName string) string {
+	return LUKSHeaderSecretPrefix + invalidSecretNameChars.ReplaceAllString(strings.ToLower(volumeName), "-")
+}
+
+// DecodeLUKSHeader returns the LUKS header backup held by data, the value of
+// the [LUKSHeaderSecretKey] of a Secret or the contents of a backup file.
+func DecodeLUKSHeader(data []byte) ([]byte, error) {
+	// The files of the directory sink are not compressed
+	if !bytes.HasPrefix(data, gzipMagic) {
+		return data, nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decompress LUKS header: %w", err)
+	}
+	defer r.Close()
+	header, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("decompress LUKS header: %w", err)
+	}
+	return header, nil
+}
+
+// backupLUKSHeader stores the LUKS header of the device at devicePath,
+// just formatted or rekeyed for the volume volumeName, in the
+// [Options.LUKSHeaderBackup] sink, if any. The volume is usable without it,
+// so failures are logged and counted rather than failing the request.
+func (ns *NodeServer) backupLUKSHeader(ctx context.Context, volumeName, devicePath string) {
+	if ns.driver == nil || ns.driver.opts.LUKSHeaderBackup == nil {
+		return
+	}
+	log := logger.GetLogger(ctx).WithComponent(logger.ComponentCryptsetup)
+
+	header, err := cryptsetupclient.BackupHeader(ns.encrypt.Exec, devicePath)
+	if err == nil {
+		err = ns.driver.opts.LUKSHeaderBackup.StoreLUKSHeader(ctx, volumeName, header)
+	}
+	if err != nil {
+		observability.LUKSHeaderBackupsTotal.WithLabelValues("failed").Inc()
+		log.Error(err, "Failed to back up the LUKS header of the volume", "volumeName", volumeName, "devicePath", devicePath)
+		return
+	}
+	observability.LUKSHeaderBackupsTotal.WithLabelValues("stored").Inc()
+	log.V(2).Info("Backed up the LUKS header of the volume", "volumeName", volumeName, "size", len(header))
+}
@@ -0,0 +1,129 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/mock/gomock"
+	"k8s.io/utils/exec"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+)
+
+func TestLUKSHeaderSecretName(t *testing.T) {
+	tests := []struct {
+		volumeName string
+		want       string
+	}{
+		{"pvc-0b5a1b8e-6c1d-4b58-9a7d-1f2e3d4c5b6a", "luks-header-pvc-0b5a1b8e-6c1d-4b58-9a7d-1f2e3d4c5b6a"},
+		{"My_Volume", "luks-header-my-volume"},
+	}
+	for _, tt := range tests {
+		if got := LUKSHeaderSecretName(tt.volumeName); got != tt.want {
+			t.Errorf("LUKSHeaderSecretName(%q) = %q, want %q", tt.volumeName, got, tt.want)
+		}
+	}
+}
+
+func TestSecretLUKSHeaderSink(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	header := append([]byte("LUKS\xba\xbe"), make([]byte, 1<<20)...)
+	var stored []byte
+	kube := mocks.NewMockKubeClient(ctrl)
+	kube.EXPECT().ApplySecret(gomock.Any(), "kube-system", "luks-header-pvc-1",
+		map[string]string{LUKSHeaderLabel: True},
+		map[string]string{PublishInfoVolumeName: "pvc-1"},
+		gomock.Any()).
+		DoAndReturn(func(_ context.Context, _, _ string, _, _ map[string]string, data map[string][]byte) error {
+			stored = data[LUKSHeaderSecretKey]
+			return nil
+		})
+
+	sink := NewSecretLUKSHeaderSink(kube, "kube-system")
+	if err := sink.StoreLUKSHeader(context.Background(), "pvc-1", header); err != nil {
+		t.Fatalf("StoreLUKSHeader() error = %v", err)
+	}
+	if len(stored) >= len(header)/100 {
+		t.Errorf("stored %d bytes, want the header compressed", len(stored))
+	}
+	got, err := DecodeLUKSHeader(stored)
+	if err != nil || !bytes.Equal(got, header) {
+		t.Errorf("DecodeLUKSHeader() = %d bytes, %v, want the header", len(got), err)
+	}
+}
+
+func TestDirectoryLUKSHeaderSink(t *testing.T) {
+	dir := t.TempDir()
+	header := []byte("LUKS\xba\xbe header")
+
+	sink := NewDirectoryLUKSHeaderSink(dir)
+	if err := sink.StoreLUKSHeader(context.Background(), "pvc-1", header); err != nil {
+		t.Fatalf("StoreLUKSHeader() error = %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "pvc-1.luks-header"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Backup files are not compressed
+	if got, err := DecodeLUKSHeader(data); err != nil || !bytes.Equal(got, header) {
+		t.Errorf("DecodeLUKSHeader() = %q, %v, want %q", got, err, header)
+	}
+}
+
+type recordingLUKSHeaderSink struct {
+	headers map[string][]byte
+}
+
+func (s *recordingLUKSHeaderSink) StoreLUKSHeader(_ context.Context, volumeName string, header []byte) error {
+	s.headers[volumeName] = header
+	return nil
+}
+
+func TestBackupLUKSHeader(t *testing.T) {
+	header := []byte("LUKS\xba\xbe header")
+	tests := []struct {
+		name    string
+		execErr error
+		want    []byte
+	}{
+		{name: "Stored", want: header},
+		{name: "Failed", execErr: errors.New("exit status 1")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockExec := mocks.NewMockExecutor(ctrl)
+			mockCommand := mocks.NewMockCommand(ctrl)
+			var path string
+			mockExec.EXPECT().Command("cryptsetup", "luksHeaderBackup", "/dev/sdb", "--header-backup-file", gomock.Any()).
+				DoAndReturn(func(_ string, args ...string) exec.Cmd {
+					path = args[len(args)-1]
+					return mockCommand
+				})
+			mockCommand.EXPECT().CombinedOutput().DoAndReturn(func() ([]byte, error) {
+				if tt.execErr != nil {
+					return []byte("Device /dev/sdb is not a valid LUKS device."), tt.execErr
+				}
+				return nil, os.WriteFile(path, header, 0o600)
+			})
+
+			sink := &recordingLUKSHeaderSink{headers: map[string][]byte{}}
+			ns := &NodeServer{
+				driver:  &LinodeDriver{opts: Options{LUKSHeaderBackup: sink}},
+				encrypt: Encryption{Exec: mockExec},
+			}
+			ns.backupLUKSHeader(context.Background(), "pvc-1", "/dev/sdb")
+			if got := sink.headers["pvc-1"]; !bytes.Equal(got, tt.want) {
+				t.Errorf("stored header = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linode/linodego"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	linodeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-client"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+const (
+	// maintenanceModeHeader is set on the responses of the Linode API while it
+	// is in maintenance mode.
+	maintenanceModeHeader = "X-Maintenance-Mode"
+
+	// defaultMaintenanceRetryAfter is how long mutations are paused when the
+	// API is in maintenance mode and does not say when to retry.
+	defaultMaintenanceRetryAfter = time.Minute
+
+	// maxMaintenanceRetryAfter bounds how long mutations are paused, whatever
+	// the API says, so that the driver notices the end of the maintenance.
+	maxMaintenanceRetryAfter = 15 * time.Minute
+)
+
+// errAPIMaintenance is returned instead of sending a request that changes
+// resources while the Linode API is in maintenance mode.
+var errAPIMaintenance = errors.New("linode API is in read-only maintenance mode")
+
+// maintenanceRetryAfter reports whether err is the response of the Linode API
+// refusing a request because it is in read-only maintenance mode, and how
+// long to wait before retrying, from its Retry-After header.
+func maintenanceRetryAfter(err error) (time.Duration, bool) {
+	var apiErr *linodego.Error
+	if !errors.As(err, &apiErr) {
+		var valueErr linodego.Error
+		if !errors.As(err, &valueErr) {
+			return 0, false
+		}
+		apiErr = &valueErr
+	}
+	if apiErr.Code != http.StatusServiceUnavailable {
+		return 0, false
+	}
+
+	var header http.Header
+	if apiErr.Response != nil {
+		header = apiErr.Response.Header
+	}
+	msg := strings.ToLower(apiErr.Message)
+	if header.Get(maintenanceModeHeader) == "" && !strings.Contains(msg, "maintenance") && !strings.Contains(msg, "read-only") {
+		return 0, false
+	}
+	return min(parseRetryAfter(header.Get("Retry-After")), maxMaintenanceRetryAfter), true
+}
+
+// parseRetryAfter returns the delay of a Retry-After header, either a number
+// of seconds or an HTTP date, or [defaultMaintenanceRetryAfter] if it is
+// missing or invalid.
+func parseRetryAfter(value string) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+	return defaultMaintenanceRetryAfter
+}
+
+// maintenanceBreaker pauses the requests changing resources while the Linode
+// API is in read-only maintenance mode, rather than sending requests that
+// are bound to fail. Reads are not affected.
+//
+// The zero value is ready to use.
+type maintenanceBreaker struct {
+	mu    sync.Mutex // protects until
+	until time.Time
+}
+
+// trip pauses mutations for retryAfter, unless they are already paused for
+// longer.
+func (b *maintenanceBreaker) trip(retryAfter time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if until := time.Now().Add(retryAfter); until.After(b.until) {
+		b.until = until
+	}
+}
+
+// retryAfter returns how long mutations are still paused, or false if they
+// are not.
+func (b *maintenanceBreaker) retryAfter() (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delay := time.Until(b.until)
+	return delay, delay > 0
+}
+
+// unavailable turns err, returned by an RPC changing resources, into
+// [errMaintenance] while mutations are paused, so that the CO retries the
+// request once the maintenance is over. Errors that are not internal, e.g.
+// invalid arguments, are returned as is.
+func (b *maintenanceBreaker) unavailable(err error) error {
+	if err == nil {
+		return nil
+	}
+	if code := status.Code(err); code != codes.Internal && code != codes.Unknown {
+		return err
+	}
+	delay, ok := b.retryAfter()
+	if !ok {
+		return err
+	}
+	return errMaintenance(delay)
+}
+
+// maintenanceClient is a [linodeclient.LinodeClient] that trips breaker when
+// the Linode API refuses a request changing resources because it is in
+// maintenance mode, and does not send such requests while breaker is tripped.
+type maintenanceClient struct {
+	linodeclient.LinodeClient
+
+	breaker *maintenanceBreaker
+}
+
+// mutate sends a request changing resources with send, unless the breaker is
+// tripped.
+func (c *maintenanceClient) mutate(ctx context.Context, send func() error) error {
+	if _, ok := c.breaker.retryAfter(); ok {
+		return errAPIMaintenance
+	}
+	err := send()
+	if retryAfter, ok := maintenanceRetryAfter(err); ok {
+		logger.GetLogger(ctx).Error(err, "Linode API is in maintenance mode, pausing mutations", "retry_after", retryAfter)
+		observability.APIMaintenanceTotal.Inc()
+		c.breaker.trip(retryAfter)
+	}
+	return err
+}
+
+func (c *maintenanceClient) CreateVolume(ctx context.Context, opts linodego.VolumeCreateOptions) (vol *linodego.Volume, err error) {
+	err = c.mutate(ctx, func() error {
+		vol, err = c.LinodeClient.CreateVolume(ctx, opts)
+		return err
+	})
+	return vol, err
+}
+
+func (c *maintenanceClient) CloneVolume(ctx context.Context, volumeID int, label string) (vol *linodego.Volume, err error) {
+	err = c.mutate(ctx, func() error {
+		vol, err = c.LinodeClient.CloneVolume(ctx, volumeID, label)
+		return err
+	})
+	return vol, err
+}
+
+func (c *maintenanceClient) UpdateVolume(ctx context.Context, volumeID int, opts linodego.VolumeUpdateOptions) (vol *linodego.Volume, err error) {
+	err = c.mutate(ctx, func() error {
+		vol, err = c.LinodeClient.UpdateVolume(ctx, volumeID, opts)
+		return err
+	})
+	return vol, err
+}
+
+func (c *maintenanceClient) UpdateInstanceConfig(ctx context.Context, instanceID, configID int, opts linodego.InstanceConfigUpdateOptions) (config *linodego.InstanceConfig, err error) {
+	err = c.mutate(ctx, func() error {
+		config, err = c.LinodeClient.UpdateInstanceConfig(ctx, instanceID, configID, opts)
+		return err
+	})
+	return config, err
+}
+
+func (c *maintenanceClient) AttachVolume(ctx context.Context, volumeID int, opts *linodego.VolumeAttachOptions) (vol *linodego.Volume, err error) {
+	err = c.mutate(ctx, func() error {
+		vol, err = c.LinodeClient.AttachVolume(ctx, volumeID, opts)
+		return err
+	})
+	return vol, err
+}
+
+func (c *maintenanceClient) DetachVolume(ctx context.Context, volumeID int) error {
+	return c.mutate(ctx, func() error {
+		return c.LinodeClient.DetachVolume(ctx, volumeID)
+	})
+}
+
+func (c *maintenanceClient) ResizeVolume(ctx context.Context, volumeID, size int) error {
+	return c.mutate(ctx, func() error {
+		return c.LinodeClient.ResizeVolume(ctx, volumeID, size)
+	})
+}
+
+func (c *maintenanceClient) DeleteVolume(ctx context.Context, volumeID int) error {
+	return c.mutate(ctx, func() error {
+		return c.LinodeClient.DeleteVolume(ctx, volumeID)
+	})
+}
@@ -0,0 +1,162 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/linode/linodego"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+)
+
+// maintenanceError returns the error of the Linode API refusing a request in
+// maintenance mode, with the headers of the response.
+func maintenanceError(header http.Header) *linodego.Error {
+	return &linodego.Error{
+		Code:     http.StatusServiceUnavailable,
+		Message:  "Service Unavailable",
+		Response: &http.Response{StatusCode: http.StatusServiceUnavailable, Header: header},
+	}
+}
+
+func TestMaintenanceRetryAfter(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		wantRetryAfter time.Duration
+		wantOK         bool
+	}{
+		{
+			name: "Nil error",
+		},
+		{
+			name: "Other error",
+			err:  errors.New("connection refused"),
+		},
+		{
+			name: "Other status",
+			err:  &linodego.Error{Code: http.StatusInternalServerError, Message: "maintenance"},
+		},
+		{
+			name: "Service unavailable",
+			err:  &linodego.Error{Code: http.StatusServiceUnavailable, Message: "Service Unavailable"},
+		},
+		{
+			name:           "Maintenance header",
+			err:            maintenanceError(http.Header{"X-Maintenance-Mode": {"1"}}),
+			wantRetryAfter: defaultMaintenanceRetryAfter,
+			wantOK:         true,
+		},
+		{
+			name:           "Retry-After seconds",
+			err:            maintenanceError(http.Header{"X-Maintenance-Mode": {"1"}, "Retry-After": {"120"}}),
+			wantRetryAfter: 2 * time.Minute,
+			wantOK:         true,
+		},
+		{
+			name:           "Retry-After too long",
+			err:            maintenanceError(http.Header{"X-Maintenance-Mode": {"1"}, "Retry-After": {"86400"}}),
+			wantRetryAfter: maxMaintenanceRetryAfter,
+			wantOK:         true,
+		},
+		{
+			name:           "Read-only message",
+			err:            linodego.Error{Code: http.StatusServiceUnavailable, Message: "The API is in read-only mode"},
+			wantRetryAfter: defaultMaintenanceRetryAfter,
+			wantOK:         true,
+		},
+		{
+			name:           "Wrapped error",
+			err:            errors.Join(errors.New("create volume"), maintenanceError(http.Header{"X-Maintenance-Mode": {"1"}, "Retry-After": {"30"}})),
+			wantRetryAfter: 30 * time.Second,
+			wantOK:         true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retryAfter, ok := maintenanceRetryAfter(tt.err)
+			if retryAfter != tt.wantRetryAfter || ok != tt.wantOK {
+				t.Errorf("maintenanceRetryAfter() = %v, %v, want %v, %v", retryAfter, ok, tt.wantRetryAfter, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfterDate(t *testing.T) {
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if delay := parseRetryAfter(date); delay < 59*time.Minute || delay > time.Hour {
+		t.Errorf("parseRetryAfter(%q) = %v, want about 1h", date, delay)
+	}
+}
+
+func TestMaintenanceBreakerUnavailable(t *testing.T) {
+	var breaker maintenanceBreaker
+	internalErr := errInternal("create volume: [503] Service Unavailable")
+
+	if err := breaker.unavailable(internalErr); err != internalErr {
+		t.Errorf("unavailable() before trip = %v, want %v", err, internalErr)
+	}
+
+	breaker.trip(2 * time.Minute)
+	breaker.trip(time.Minute)
+
+	if err := breaker.unavailable(errNoVolumeName); err != errNoVolumeName {
+		t.Errorf("unavailable() = %v, want %v", err, errNoVolumeName)
+	}
+	if err := breaker.unavailable(nil); err != nil {
+		t.Errorf("unavailable(nil) = %v, want nil", err)
+	}
+
+	st := status.Convert(breaker.unavailable(internalErr))
+	if st.Code() != codes.Unavailable {
+		t.Fatalf("unavailable() code = %v, want %v", st.Code(), codes.Unavailable)
+	}
+	details := st.Details()
+	if len(details) != 1 {
+		t.Fatalf("unavailable() details = %v, want RetryInfo", details)
+	}
+	info, ok := details[0].(*errdetails.RetryInfo)
+	if !ok {
+		t.Fatalf("unavailable() details = %v, want RetryInfo", details)
+	}
+	if delay := info.GetRetryDelay().AsDuration(); delay <= time.Minute || delay > 2*time.Minute {
+		t.Errorf("RetryInfo delay = %v, want about 2m", delay)
+	}
+}
+
+func TestMaintenanceClient(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mocks.NewMockLinodeClient(ctrl)
+	cs := &ControllerServer{driver: &LinodeDriver{}}
+	cs.client = &maintenanceClient{LinodeClient: mockClient, breaker: &cs.maintenance}
+
+	// The first delete is refused by the API, the second one is not sent,
+	// while the volumes can still be read
+	gomock.InOrder(
+		mockClient.EXPECT().GetVolume(gomock.Any(), 1).Return(&linodego.Volume{ID: 1}, nil),
+		mockClient.EXPECT().DeleteVolume(gomock.Any(), 1).Return(maintenanceError(http.Header{"X-Maintenance-Mode": {"1"}, "Retry-After": {"120"}})),
+		mockClient.EXPECT().GetVolume(gomock.Any(), 2).Return(&linodego.Volume{ID: 2}, nil),
+	)
+
+	for _, volumeID := range []string{"1-vol", "2-vol"} {
+		_, err := cs.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: volumeID})
+		if status.Code(err) != codes.Unavailable {
+			t.Errorf("DeleteVolume(%s) error = %v, want code %v", volumeID, err, codes.Unavailable)
+		}
+	}
+
+	if err := cs.client.DetachVolume(context.Background(), 3); !errors.Is(err, errAPIMaintenance) {
+		t.Errorf("DetachVolume() error = %v, want %v", err, errAPIMaintenance)
+	}
+}
@@ -5,13 +5,22 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"os"
+	"regexp"
 	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	metadata "github.com/linode/go-metadata"
+	"k8s.io/apimachinery/pkg/util/wait"
 
 	"github.com/linode/linode-blockstorage-csi-driver/pkg/filesystem"
 	linodeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-client"
 	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
 )
 
 // Metadata contains metadata about the node/instance the CSI node plugin
@@ -28,13 +37,154 @@ type MetadataClient interface {
 }
 
 var NewMetadataClient = func(ctx context.Context) (MetadataClient, error) {
-	return metadata.NewClient(ctx)
+	client, err := metadata.NewClient(ctx, metadata.ClientWithoutManagedToken())
+	if err != nil {
+		return nil, err
+	}
+	return &tokenCachingMetadataClient{client: client}, nil
+}
+
+const (
+	// metadataTokenExpiry is how long the tokens used to access the Linode
+	// Metadata Service are valid for.
+	metadataTokenExpiry = time.Hour
+
+	// metadataTokenRefreshMargin is how long before it expires a token is
+	// replaced.
+	metadataTokenRefreshMargin = time.Minute
+)
+
+// tokenCachingMetadataClient is a [MetadataClient] that generates a Metadata
+// Service token on first use, and reuses it until shortly before it expires.
+type tokenCachingMetadataClient struct {
+	client *metadata.Client
+
+	mu      sync.Mutex // protects expires
+	expires time.Time
+}
+
+func (c *tokenCachingMetadataClient) GetInstance(ctx context.Context) (*metadata.InstanceData, error) {
+	if err := c.refreshToken(ctx); err != nil {
+		return nil, err
+	}
+
+	data, err := c.client.GetInstance(ctx)
+	if metadataErrorCode(err) == http.StatusUnauthorized {
+		// The token was revoked, or the instance rebooted; generate a new
+		// one on the next call.
+		c.mu.Lock()
+		c.expires = time.Time{}
+		c.mu.Unlock()
+	}
+	return data, err
+}
+
+// refreshToken generates a new token if the cached one is about to expire.
+func (c *tokenCachingMetadataClient) refreshToken(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Now().Before(c.expires) {
+		return nil
+	}
+
+	created := time.Now()
+	token, err := c.client.GenerateToken(ctx, metadata.TokenWithExpiry(int(metadataTokenExpiry.Seconds())))
+	if err != nil {
+		return fmt.Errorf("generate metadata token: %w", err)
+	}
+	c.client.UseToken(token)
+	c.expires = created.Add(metadataTokenExpiry - metadataTokenRefreshMargin)
+	return nil
+}
+
+// metadataBackoff controls how [GetNodeMetadata] retries transient failures
+// of the Linode Metadata Service, before falling back to the Linode API.
+var metadataBackoff = wait.Backoff{
+	Duration: 500 * time.Millisecond,
+	Factor:   2,
+	Jitter:   0.1,
+	Steps:    5,
+	Cap:      5 * time.Second,
+}
+
+// metadataErrorCode returns the HTTP status code of an error returned by the
+// Linode Metadata Service, or 0 if the request did not get a response.
+func metadataErrorCode(err error) int {
+	var apiErr *metadata.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return 0
+}
+
+// unreachableMetadataError reports whether a request to the Linode Metadata
+// Service failed with err because nothing listens at its address, which is
+// the case when the service is not available to the instance.
+func unreachableMetadataError(err error) bool {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	// The metadata client only keeps the message of transport errors.
+	var transportErr metadata.Error
+	return errors.As(err, &transportErr) && transportErr.Code == 0 &&
+		strings.Contains(transportErr.Message, syscall.ECONNREFUSED.Error())
+}
+
+// retryableMetadataError reports whether a request to the Linode Metadata
+// Service that failed with err should be retried.
+func retryableMetadataError(err error) bool {
+	if errors.Is(err, errNilClient) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	// Fall back to the Linode API right away, instead of waiting for a
+	// service that is not there.
+	if unreachableMetadataError(err) {
+		return false
+	}
+
+	code := metadataErrorCode(err)
+	switch {
+	case code == http.StatusUnauthorized, code == http.StatusTooManyRequests:
+		return true
+	case code >= http.StatusBadRequest && code < http.StatusInternalServerError:
+		return false
+	default:
+		return true
+	}
+}
+
+// getMetadataWithRetry calls [GetMetadata], retrying transient failures
+// with exponential backoff.
+func getMetadataWithRetry(ctx context.Context, client MetadataClient) (Metadata, error) {
+	log := logger.GetLogger(ctx)
+
+	var (
+		nodeMetadata Metadata
+		lastErr      error
+	)
+	err := wait.ExponentialBackoffWithContext(ctx, metadataBackoff, func(ctx context.Context) (bool, error) {
+		nodeMetadata, lastErr = GetMetadata(ctx, client)
+		if lastErr == nil {
+			return true, nil
+		}
+		if !retryableMetadataError(lastErr) {
+			return false, lastErr
+		}
+		log.V(2).Info("Retrying metadata service request", "error", lastErr.Error())
+		return false, nil
+	})
+	if wait.Interrupted(err) && lastErr != nil {
+		err = lastErr
+	}
+	return nodeMetadata, err
 }
 
 // GetNodeMetadata retrieves metadata about the current node/instance.
-// It first attempts to use the Linode Metadata Service, and if that fails,
-// it falls back to using the Linode API. This function ensures that valid
-// metadata is obtained before returning.
+// It first attempts to use the Linode Metadata Service, retrying transient
+// failures with exponential backoff, and if that fails, it falls back to
+// using the Linode API. This function ensures that valid metadata is
+// obtained before returning.
 func GetNodeMetadata(ctx context.Context, cloudProvider linodeclient.LinodeClient, fileSystem filesystem.FileSystem) (Metadata, error) {
 	log := logger.GetLogger(ctx)
 
@@ -50,7 +200,7 @@ func GetNodeMetadata(ctx context.Context, cloudProvider linodeclient.LinodeClien
 	var nodeMetadata Metadata
 	if linodeMetadataClient != nil {
 		log.V(4).Info("Attempting to get metadata from metadata service")
-		nodeMetadata, err = GetMetadata(ctx, linodeMetadataClient)
+		nodeMetadata, err = getMetadataWithRetry(ctx, linodeMetadataClient)
 		if err != nil {
 			log.Error(err, "Failed to get metadata from metadata service")
 		}
@@ -151,14 +301,58 @@ func GetMetadataFromAPI(ctx context.Context, client linodeclient.LinodeClient, f
 	}
 
 	log.V(4).Info("Checking LinodeIDPath", "path", LinodeIDPath)
-	if _, err := fs.Stat(LinodeIDPath); err != nil {
+	var linodeID int
+	_, err := fs.Stat(LinodeIDPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		log.V(2).Info("LinodeIDPath not found, reading the instance ID from the DMI serial number", "path", DMIProductSerialPath)
+		if linodeID, err = linodeIDFromDMI(ctx, fs); err != nil {
+			return Metadata{}, err
+		}
+	case err != nil:
 		return Metadata{}, fmt.Errorf("stat %s: %w", LinodeIDPath, err)
+	default:
+		if linodeID, err = readLinodeIDPath(ctx, fs); err != nil {
+			return Metadata{}, err
+		}
+	}
+
+	log.V(4).Info("Retrieving instance data from API", "linodeID", linodeID)
+	instance, err := client.GetInstance(ctx, linodeID)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("get instance: %w", err)
+	}
+
+	memory := minMemory
+	if instance.Specs != nil {
+		memory = memoryToBytes(instance.Specs.Memory)
+	}
+
+	nodeMetadata := Metadata{
+		ID:     linodeID,
+		Label:  instance.Label,
+		Region: instance.Region,
+		Memory: memory,
 	}
 
+	log.V(4).Info("Successfully retrieved metadata",
+		"instanceID", nodeMetadata.ID,
+		"instanceLabel", nodeMetadata.Label,
+		"region", nodeMetadata.Region,
+		"memory", nodeMetadata.Memory)
+
+	log.V(2).Info("Successfully completed")
+	return nodeMetadata, nil
+}
+
+// readLinodeIDPath reads the ID of the instance from [LinodeIDPath].
+func readLinodeIDPath(ctx context.Context, fs filesystem.FileSystem) (int, error) {
+	log := logger.GetLogger(ctx)
+
 	log.V(4).Info("Opening LinodeIDPath", "path", LinodeIDPath)
 	fileObj, err := fs.Open(LinodeIDPath)
 	if err != nil {
-		return Metadata{}, fmt.Errorf("open: %w", err)
+		return 0, fmt.Errorf("open: %w", err)
 	}
 	defer func() {
 		err = fileObj.Close()
@@ -169,39 +363,117 @@ func GetMetadataFromAPI(ctx context.Context, client linodeclient.LinodeClient, f
 	// reading in junk.
 	data, err := io.ReadAll(io.LimitReader(fileObj, 1<<10))
 	if err != nil {
-		return Metadata{}, fmt.Errorf("read all: %w", err)
+		return 0, fmt.Errorf("read all: %w", err)
 	}
 
 	log.V(4).Info("Parsing LinodeID")
 	linodeID, err := strconv.Atoi(string(data))
 	if err != nil {
-		return Metadata{}, fmt.Errorf("atoi: %w", err)
+		return 0, fmt.Errorf("atoi: %w", err)
 	}
+	return linodeID, nil
+}
 
-	log.V(4).Info("Retrieving instance data from API", "linodeID", linodeID)
-	instance, err := client.GetInstance(ctx, linodeID)
-	if err != nil {
-		return Metadata{}, fmt.Errorf("get instance: %w", err)
+// DMIProductSerialPath is the path of the serial number of the instance in
+// its DMI table, which Linode sets to the ID of the instance. It is read when
+// the init container did not write [LinodeIDPath].
+const DMIProductSerialPath = "/sys/class/dmi/id/product_serial"
+
+// dmiSerialFormats match the layouts of the DMI serial numbers of Linode
+// instances, capturing the ID of the instance. Layouts are tried in order,
+// and new ones are added here.
+var dmiSerialFormats = []*regexp.Regexp{
+	regexp.MustCompile(`^([0-9]+)$`),
+	regexp.MustCompile(`^(?i:linode)(?:-|://)([0-9]+)$`),
+}
+
+// Results of reading the ID of the instance from its DMI serial number, used
+// as the "result" label of the csi_node_metadata_dmi_fallback_total metric.
+const (
+	dmiSerialFound            = "found"
+	dmiSerialNotFound         = "not_found"
+	dmiSerialPermissionDenied = "permission_denied"
+	dmiSerialReadError        = "read_error"
+	dmiSerialInvalid          = "invalid"
+)
+
+// dmiSerialError is a failure to read the ID of the instance from its DMI
+// serial number, with the result explaining it.
+type dmiSerialError struct {
+	result string
+	err    error
+}
+
+func (e *dmiSerialError) Error() string {
+	return fmt.Sprintf("read instance ID from %s: %v: %s", DMIProductSerialPath, e.err, e.guidance())
+}
+
+func (e *dmiSerialError) Unwrap() error {
+	return e.err
+}
+
+// guidance explains how to fix the failure.
+func (e *dmiSerialError) guidance() string {
+	switch e.result {
+	case dmiSerialNotFound:
+		return "the host has no DMI serial number, so it is likely not a Linode instance; make sure the init container writes " + LinodeIDPath
+	case dmiSerialPermissionDenied:
+		return "the plugin is not allowed to read it; run the plugin as root, or mount /sys/class/dmi/id from the host"
+	case dmiSerialInvalid:
+		return "the serial number is not in a known layout of Linode instance IDs; make sure the init container writes " + LinodeIDPath
+	default:
+		return "make sure the init container writes " + LinodeIDPath
 	}
+}
 
-	memory := minMemory
-	if instance.Specs != nil {
-		memory = memoryToBytes(instance.Specs.Memory)
+// linodeIDFromDMI reads the ID of the instance from its DMI serial number at
+// [DMIProductSerialPath]. Failures are logged with how to fix them, and
+// counted with the successes by result.
+func linodeIDFromDMI(ctx context.Context, fs filesystem.FileSystem) (int, error) {
+	log := logger.GetLogger(ctx)
+
+	linodeID, err := readDMISerial(fs)
+	result := dmiSerialFound
+	var serialErr *dmiSerialError
+	if errors.As(err, &serialErr) {
+		result = serialErr.result
+		log.Error(serialErr.err, "Failed to read the instance ID from the DMI serial number", "path", DMIProductSerialPath, "result", result, "guidance", serialErr.guidance())
 	}
+	observability.NodeMetadataDMIFallbackTotal.WithLabelValues(result).Inc()
+	return linodeID, err
+}
 
-	nodeMetadata := Metadata{
-		ID:     linodeID,
-		Label:  instance.Label,
-		Region: instance.Region,
-		Memory: memory,
+// readDMISerial reads the ID of the instance from [DMIProductSerialPath],
+// failing with a *dmiSerialError.
+func readDMISerial(fs filesystem.FileSystem) (int, error) {
+	file, err := fs.Open(DMIProductSerialPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return 0, &dmiSerialError{result: dmiSerialNotFound, err: err}
+	case errors.Is(err, os.ErrPermission):
+		return 0, &dmiSerialError{result: dmiSerialPermissionDenied, err: err}
+	case err != nil:
+		return 0, &dmiSerialError{result: dmiSerialReadError, err: err}
 	}
+	defer file.Close()
 
-	log.V(4).Info("Successfully retrieved metadata",
-		"instanceID", nodeMetadata.ID,
-		"instanceLabel", nodeMetadata.Label,
-		"region", nodeMetadata.Region,
-		"memory", nodeMetadata.Memory)
+	data, err := io.ReadAll(io.LimitReader(file, 1<<10))
+	if errors.Is(err, os.ErrPermission) {
+		return 0, &dmiSerialError{result: dmiSerialPermissionDenied, err: err}
+	}
+	if err != nil {
+		return 0, &dmiSerialError{result: dmiSerialReadError, err: err}
+	}
 
-	log.V(2).Info("Successfully completed")
-	return nodeMetadata, nil
+	serial := strings.TrimSpace(string(data))
+	for _, format := range dmiSerialFormats {
+		match := format.FindStringSubmatch(serial)
+		if match == nil {
+			continue
+		}
+		if linodeID, err := strconv.Atoi(match[1]); err == nil && linodeID > 0 {
+			return linodeID, nil
+		}
+	}
+	return 0, &dmiSerialError{result: dmiSerialInvalid, err: fmt.Errorf("unknown serial number layout %q", serial)}
 }
@@ -3,15 +3,21 @@ package driver
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"io/fs"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"reflect"
+	"syscall"
 	"testing"
 
 	metadata "github.com/linode/go-metadata"
 	"github.com/linode/linodego"
 	"go.uber.org/mock/gomock"
+	"k8s.io/apimachinery/pkg/util/wait"
 
 	"github.com/linode/linode-blockstorage-csi-driver/mocks"
 	filesystem "github.com/linode/linode-blockstorage-csi-driver/pkg/filesystem"
@@ -181,6 +187,39 @@ func TestGetMetadataFromAPI(t *testing.T) {
 			want:    Metadata{},
 			wantErr: true,
 		},
+		{
+			name: "Linode ID from DMI serial number",
+			setup: func(client *mocks.MockLinodeClient, fs *mocks.MockFileSystem, file *mocks.MockFileInterface) {
+				fs.EXPECT().Stat(LinodeIDPath).Return(nil, os.ErrNotExist)
+				fs.EXPECT().Open(DMIProductSerialPath).Return(file, nil)
+				file.EXPECT().Read(gomock.Any()).DoAndReturn(func(p []byte) (int, error) {
+					return copy(p, "12345\n"), io.EOF
+				})
+				file.EXPECT().Close().Return(nil)
+				client.EXPECT().GetInstance(gomock.Any(), 12345).Return(&linodego.Instance{
+					ID:     12345,
+					Label:  "test-instance",
+					Region: "us-east",
+					Specs:  &linodego.InstanceSpec{Memory: 2048},
+				}, nil)
+			},
+			want: Metadata{
+				ID:     12345,
+				Label:  "test-instance",
+				Region: "us-east",
+				Memory: 2048 << 20,
+			},
+			wantErr: false,
+		},
+		{
+			name: "No DMI serial number",
+			setup: func(client *mocks.MockLinodeClient, fs *mocks.MockFileSystem, file *mocks.MockFileInterface) {
+				fs.EXPECT().Stat(LinodeIDPath).Return(nil, os.ErrNotExist)
+				fs.EXPECT().Open(DMIProductSerialPath).Return(nil, os.ErrNotExist)
+			},
+			want:    Metadata{},
+			wantErr: true,
+		},
 		{
 			name: "Open file error",
 			setup: func(client *mocks.MockLinodeClient, fs *mocks.MockFileSystem, file *mocks.MockFileInterface) {
@@ -270,6 +309,65 @@ func TestGetMetadataFromAPI(t *testing.T) {
 	}
 }
 
+func TestReadDMISerial(t *testing.T) {
+	tests := []struct {
+		name       string
+		openErr    error
+		serial     string
+		readErr    error
+		wantID     int
+		wantResult string
+	}{
+		{name: "ID", serial: "12345\n", wantID: 12345},
+		{name: "Prefixed ID", serial: "linode-12345", wantID: 12345},
+		{name: "ID URL", serial: "linode://12345", wantID: 12345},
+		{name: "No serial number", openErr: &fs.PathError{Op: "open", Path: DMIProductSerialPath, Err: syscall.ENOENT}, wantResult: dmiSerialNotFound},
+		{name: "Not allowed to open", openErr: &fs.PathError{Op: "open", Path: DMIProductSerialPath, Err: syscall.EACCES}, wantResult: dmiSerialPermissionDenied},
+		{name: "Not allowed to read", readErr: &fs.PathError{Op: "read", Path: DMIProductSerialPath, Err: syscall.EPERM}, wantResult: dmiSerialPermissionDenied},
+		{name: "Open error", openErr: errors.New("too many open files"), wantResult: dmiSerialReadError},
+		{name: "Read error", readErr: syscall.EIO, wantResult: dmiSerialReadError},
+		{name: "Unknown layout", serial: "VMware-56 4d", wantResult: dmiSerialInvalid},
+		{name: "Zero ID", serial: "0", wantResult: dmiSerialInvalid},
+		{name: "Empty", serial: "", wantResult: dmiSerialInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockFS := mocks.NewMockFileSystem(ctrl)
+			mockFile := mocks.NewMockFileInterface(ctrl)
+
+			if tt.openErr != nil {
+				mockFS.EXPECT().Open(DMIProductSerialPath).Return(nil, tt.openErr)
+			} else {
+				mockFS.EXPECT().Open(DMIProductSerialPath).Return(mockFile, nil)
+				mockFile.EXPECT().Read(gomock.Any()).DoAndReturn(func(p []byte) (int, error) {
+					if tt.readErr != nil {
+						return 0, tt.readErr
+					}
+					return copy(p, tt.serial), io.EOF
+				})
+				mockFile.EXPECT().Close().Return(nil)
+			}
+
+			id, err := readDMISerial(mockFS)
+			if tt.wantResult == "" {
+				if err != nil || id != tt.wantID {
+					t.Errorf("readDMISerial() = %d, %v, want %d", id, err, tt.wantID)
+				}
+				return
+			}
+			var serialErr *dmiSerialError
+			if !errors.As(err, &serialErr) {
+				t.Fatalf("readDMISerial() error = %v, want a *dmiSerialError", err)
+			}
+			if serialErr.result != tt.wantResult {
+				t.Errorf("readDMISerial() result = %q, want %q (%v)", serialErr.result, tt.wantResult, err)
+			}
+		})
+	}
+}
+
 func TestGetNodeMetadata(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -337,7 +435,7 @@ func TestGetNodeMetadata(t *testing.T) {
 		{
 			name: "Failure from metadata service, successful retrieval from API",
 			setupMocks: func() {
-				mockMetadataClient.EXPECT().GetInstance(gomock.Any()).Return(nil, errors.New("metadata service error"))
+				mockMetadataClient.EXPECT().GetInstance(gomock.Any()).Return(nil, errors.New("metadata service error")).Times(3)
 
 				mockFile := mocks.NewMockFileInterface(ctrl)
 				mockFileSystem.EXPECT().Stat(LinodeIDPath).Return(nil, nil)
@@ -364,10 +462,66 @@ func TestGetNodeMetadata(t *testing.T) {
 				return mockMetadataClient, nil
 			},
 		},
+		{
+			name: "Transient failure from metadata service, successful retry",
+			setupMocks: func() {
+				gomock.InOrder(
+					mockMetadataClient.EXPECT().GetInstance(gomock.Any()).Return(nil, &metadata.Error{Code: http.StatusServiceUnavailable}),
+					mockMetadataClient.EXPECT().GetInstance(gomock.Any()).Return(&metadata.InstanceData{
+						ID:     321,
+						Label:  "retried-instance",
+						Region: "us-east",
+						Specs:  metadata.InstanceSpecsData{Memory: 2048},
+					}, nil),
+				)
+			},
+			expectedMetadata: Metadata{
+				ID:     321,
+				Label:  "retried-instance",
+				Region: "us-east",
+				Memory: 2 << 30, // 2 GB
+			},
+			metadataClientFunc: func(context.Context) (MetadataClient, error) {
+				return mockMetadataClient, nil
+			},
+		},
+		{
+			name: "Permanent failure from metadata service is not retried",
+			setupMocks: func() {
+				mockMetadataClient.EXPECT().GetInstance(gomock.Any()).Return(nil, &metadata.Error{Code: http.StatusForbidden})
+				mockFileSystem.EXPECT().Stat(LinodeIDPath).Return(nil, errors.New("file not found"))
+			},
+			expectedErr: "failed to get metadata from API: stat /linode-info/linode-id: file not found",
+			metadataClientFunc: func(context.Context) (MetadataClient, error) {
+				return mockMetadataClient, nil
+			},
+		},
+		{
+			name: "Unreachable metadata service is not retried",
+			setupMocks: func() {
+				mockMetadataClient.EXPECT().GetInstance(gomock.Any()).Return(nil, metadata.Error{Message: `Get "http://169.254.169.254/v1/instance": dial tcp 169.254.169.254:80: connect: connection refused`})
+				mockFileSystem.EXPECT().Stat(LinodeIDPath).Return(nil, errors.New("file not found"))
+			},
+			expectedErr: "failed to get metadata from API: stat /linode-info/linode-id: file not found",
+			metadataClientFunc: func(context.Context) (MetadataClient, error) {
+				return mockMetadataClient, nil
+			},
+		},
+		{
+			name: "Refused connection is not retried",
+			setupMocks: func() {
+				mockMetadataClient.EXPECT().GetInstance(gomock.Any()).Return(nil, &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)})
+				mockFileSystem.EXPECT().Stat(LinodeIDPath).Return(nil, errors.New("file not found"))
+			},
+			expectedErr: "failed to get metadata from API: stat /linode-info/linode-id: file not found",
+			metadataClientFunc: func(context.Context) (MetadataClient, error) {
+				return mockMetadataClient, nil
+			},
+		},
 		{
 			name: "Failure from both metadata service and API",
 			setupMocks: func() {
-				mockMetadataClient.EXPECT().GetInstance(gomock.Any()).Return(nil, errors.New("metadata service error"))
+				mockMetadataClient.EXPECT().GetInstance(gomock.Any()).Return(nil, errors.New("metadata service error")).Times(3)
 				mockFileSystem.EXPECT().Stat(LinodeIDPath).Return(nil, errors.New("file not found"))
 			},
 			expectedErr: "failed to get metadata from API: stat /linode-info/linode-id: file not found",
@@ -377,6 +531,10 @@ func TestGetNodeMetadata(t *testing.T) {
 		},
 	}
 
+	oldBackoff := metadataBackoff
+	metadataBackoff = wait.Backoff{Steps: 3}
+	defer func() { metadataBackoff = oldBackoff }()
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Setup mocks
@@ -406,3 +564,52 @@ func TestGetNodeMetadata(t *testing.T) {
 		})
 	}
 }
+
+func TestTokenCachingMetadataClient(t *testing.T) {
+	var tokens, instances int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/token":
+			tokens++
+			fmt.Fprintf(w, `["token-%d"]`, tokens)
+		case "/v1/instance":
+			instances++
+			if r.Header.Get("Metadata-Token") != fmt.Sprintf("token-%d", tokens) {
+				t.Errorf("request made with token %q, want token-%d", r.Header.Get("Metadata-Token"), tokens)
+			}
+			if instances == 2 {
+				w.WriteHeader(http.StatusUnauthorized)
+				fmt.Fprint(w, `{"errors":[{"reason":"Unauthorized"}]}`)
+				return
+			}
+			fmt.Fprint(w, `{"id":123}`)
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client, err := metadata.NewClient(context.Background(), metadata.ClientWithoutManagedToken())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cachingClient := &tokenCachingMetadataClient{client: client.SetBaseURL(server.URL)}
+
+	// The token is generated on first use, and reused until the Metadata
+	// Service rejects it.
+	wantTokens := []int{1, 1, 2}
+	for i, want := range wantTokens {
+		data, err := cachingClient.GetInstance(context.Background())
+		if i == 1 {
+			if metadataErrorCode(err) != http.StatusUnauthorized {
+				t.Errorf("GetInstance() #%d error = %v, want 401", i, err)
+			}
+		} else if err != nil || data.ID != 123 {
+			t.Errorf("GetInstance() #%d = %v, %v", i, data, err)
+		}
+		if tokens != want {
+			t.Errorf("after GetInstance() #%d, %d tokens were generated, want %d", i, tokens, want)
+		}
+	}
+}
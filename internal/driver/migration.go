@@ -0,0 +1,55 @@
+package driver
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/linode/linodego"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+// instanceMigrationRetryAfter is when ControllerPublishVolume is to be
+// retried after it was refused because the instance is migrating.
+const instanceMigrationRetryAfter = 30 * time.Second
+
+// migrationEventActions are the actions of the events of instances being
+// moved to another host, which volumes must not be attached during.
+var migrationEventActions = []linodego.EventAction{
+	linodego.ActionLinodeMigrate,
+	linodego.ActionLinodeMigrateDatacenter,
+	linodego.ActionLinodeMigrateDatacenterCreate,
+	linodego.ActionHostReboot,
+}
+
+// checkInstanceMigration fails with Unavailable if the instance is migrating,
+// or has a migration or host maintenance scheduled or in progress, so that
+// the volume is not attached right before the instance moves and the CO
+// retries the attachment shortly. The events of the instance are checked on
+// a best-effort basis: the volume is attached if they cannot be listed.
+func (cs *ControllerServer) checkInstanceMigration(ctx context.Context, instance *linodego.Instance) error {
+	log := logger.GetLogger(ctx)
+	log.V(4).Info("Entering checkInstanceMigration()", "linodeID", instance.ID)
+	defer log.V(4).Info("Exiting checkInstanceMigration()")
+
+	if instance.Status == linodego.InstanceMigrating {
+		return errInstanceMigrating(instance.ID, string(instance.Status), instanceMigrationRetryAfter)
+	}
+
+	events, err := cs.recentEvents(ctx, linodego.EntityLinode, instance.ID)
+	if err != nil {
+		log.Error(err, "Failed to list the events of the instance, attaching anyway", "linodeID", instance.ID)
+		return nil
+	}
+	for _, event := range events {
+		if !slices.Contains(migrationEventActions, event.Action) {
+			continue
+		}
+		if event.Status == linodego.EventScheduled || event.Status == linodego.EventStarted {
+			log.V(2).Info("Refusing to attach to an instance about to migrate", "linodeID", instance.ID, "action", event.Action, "status", event.Status)
+			return errInstanceMigrating(instance.ID, string(event.Action)+" "+string(event.Status), instanceMigrationRetryAfter)
+		}
+	}
+	return nil
+}
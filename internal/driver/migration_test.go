@@ -0,0 +1,75 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/linode/linodego"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+)
+
+func TestControllerServer_checkInstanceMigration(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    linodego.InstanceStatus
+		events    []linodego.Event
+		eventsErr error
+		wantCode  codes.Code
+	}{
+		{
+			name:   "Running",
+			status: linodego.InstanceRunning,
+			events: []linodego.Event{{Action: linodego.ActionLinodeMigrate, Status: linodego.EventFinished}},
+		},
+		{
+			name:     "Migrating",
+			status:   linodego.InstanceMigrating,
+			wantCode: codes.Unavailable,
+		},
+		{
+			name:     "Migration scheduled",
+			status:   linodego.InstanceRunning,
+			events:   []linodego.Event{{Action: linodego.ActionLinodeMigrateDatacenter, Status: linodego.EventScheduled}},
+			wantCode: codes.Unavailable,
+		},
+		{
+			name:     "Host maintenance started",
+			status:   linodego.InstanceRunning,
+			events:   []linodego.Event{{Action: linodego.ActionHostReboot, Status: linodego.EventStarted}},
+			wantCode: codes.Unavailable,
+		},
+		{
+			name:   "Other event in progress",
+			status: linodego.InstanceRunning,
+			events: []linodego.Event{{Action: linodego.ActionVolumeAttach, Status: linodego.EventStarted}},
+		},
+		{
+			name:      "Events not listed",
+			status:    linodego.InstanceRunning,
+			eventsErr: errors.New("rate limited"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockClient := mocks.NewMockLinodeClient(ctrl)
+			if tt.status != linodego.InstanceMigrating {
+				mockClient.EXPECT().ListEvents(gomock.Any(), gomock.Any()).Return(tt.events, tt.eventsErr)
+			}
+
+			cs := &ControllerServer{client: mockClient}
+			err := cs.checkInstanceMigration(context.Background(), &linodego.Instance{ID: 1003, Status: tt.status})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("checkInstanceMigration() error = %v, want code %v", err, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package driver
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/linode/linodego"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+// mutableParameters are the keys of the parameters of the
+// VolumeAttributesClasses the driver supports, applied by CreateVolume to the
+// volumes it creates and by ControllerModifyVolume to existing volumes.
+// Parameters that Linode volumes cannot change without being recreated, like
+// their encryption, are not mutable.
+var mutableParameters = []string{VolumeTags}
+
+// validateMutableParameters fails if parameters, the mutable parameters of a
+// CreateVolume or ControllerModifyVolume request, have a key that is not one
+// of the [mutableParameters] or set reserved volume tags.
+func validateMutableParameters(parameters map[string]string) error {
+	for _, key := range slices.Sorted(maps.Keys(parameters)) {
+		if !slices.Contains(mutableParameters, key) {
+			return errUnsupportedMutableParameter(key)
+		}
+	}
+	_, err := userVolumeTags(parameters)
+	return err
+}
+
+// userVolumeTags returns the tags set with the [VolumeTags] parameter of
+// parameters, and fails if one is the tag of a volume attribute.
+func userVolumeTags(parameters map[string]string) ([]string, error) {
+	tags := parameters[VolumeTags]
+	if tags == "" {
+		return nil, nil
+	}
+	userTags := strings.Split(tags, ",")
+	if i := slices.IndexFunc(userTags, isAttributeTag); i >= 0 {
+		return nil, errReservedVolumeTag(userTags[i])
+	}
+	return userTags, nil
+}
+
+// createParameters returns the parameters of the volume created for req: its
+// parameters, with those of the VolumeAttributesClass of the claim, given as
+// its mutable parameters, taking precedence.
+func createParameters(req *csi.CreateVolumeRequest) map[string]string {
+	if len(req.GetMutableParameters()) == 0 {
+		return req.GetParameters()
+	}
+	parameters := maps.Clone(req.GetParameters())
+	if parameters == nil {
+		parameters = make(map[string]string, len(req.GetMutableParameters()))
+	}
+	maps.Copy(parameters, req.GetMutableParameters())
+	return parameters
+}
+
+// withUserTags returns a copy of tags, the tags of a volume, with userTags
+// instead of the tags that are not volume attributes.
+func withUserTags(tags, userTags []string) []string {
+	result := slices.Clone(userTags)
+	for _, tag := range tags {
+		if isAttributeTag(tag) {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+// appliedParameters returns the mutable parameters applied to vol, reported
+// by ControllerGetVolume.
+func appliedParameters(vol *linodego.Volume) map[string]string {
+	userTags := slices.DeleteFunc(slices.Clone(vol.Tags), isAttributeTag)
+	if len(userTags) == 0 {
+		return nil
+	}
+	return map[string]string{VolumeTags: strings.Join(userTags, ",")}
+}
+
+// ControllerModifyVolume applies the parameters of the VolumeAttributesClass
+// of a claim, given as the mutable parameters of the request, to its volume.
+// The [VolumeTags] parameter replaces the tags of the volume, except the
+// attributes the driver stores in them.
+// For more details, refer to the CSI Driver Spec documentation.
+func (cs *ControllerServer) ControllerModifyVolume(ctx context.Context, req *csi.ControllerModifyVolumeRequest) (resp *csi.ControllerModifyVolumeResponse, err error) {
+	log, _, done := logger.GetLogger(ctx).WithMethod("ControllerModifyVolume")
+	defer done()
+	defer func() { err = cs.maintenance.unavailable(err) }()
+
+	log.V(2).Info("Processing request", "req", redactSecrets(req))
+
+	volumeID, err := cs.volumeIDFromRequest(ctx, "ControllerModifyVolume", req)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, err = cs.withSecrets(ctx, req.GetSecrets())
+	if err != nil {
+		return nil, err
+	}
+
+	parameters := req.GetMutableParameters()
+	if err := validateMutableParameters(parameters); err != nil {
+		return nil, err
+	}
+
+	vol, err := cs.linodeClient(ctx).GetVolume(ctx, volumeID)
+	if linodego.IsNotFound(err) {
+		return nil, errVolumeNotFound(volumeID)
+	} else if err != nil {
+		return nil, errInternal("get volume %d: %v", volumeID, err)
+	}
+
+	if _, ok := parameters[VolumeTags]; ok {
+		userTags, err := userVolumeTags(parameters)
+		if err != nil {
+			return nil, err
+		}
+		tags := withUserTags(vol.Tags, userTags)
+		if stamp, ok := cs.driver.driverAttribute(); ok {
+			if tags, err = withAttributes(tags, []attribute{stamp}); err != nil {
+				return nil, errInternal("encode attributes of volume %d: %v", volumeID, err)
+			}
+		}
+		if !slices.Equal(tags, vol.Tags) {
+			log.V(4).Info("Calling API to update the tags of the volume", "volume_id", volumeID, "tags", tags)
+			if _, err := cs.linodeClient(ctx).UpdateVolume(ctx, volumeID, linodego.VolumeUpdateOptions{Tags: &tags}); err != nil {
+				return nil, errInternal("update tags of volume %d: %v", volumeID, err)
+			}
+		}
+	}
+
+	log.V(2).Info("Volume modified successfully", "volume_id", volumeID)
+	return &csi.ControllerModifyVolumeResponse{}, nil
+}
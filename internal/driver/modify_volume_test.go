@@ -0,0 +1,176 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/linode/linodego"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+)
+
+func TestControllerModifyVolume(t *testing.T) {
+	tests := []struct {
+		name                    string
+		version                 string
+		parameters              map[string]string
+		expectLinodeClientCalls func(m *mocks.MockLinodeClient)
+		wantCode                codes.Code
+	}{
+		{
+			name:       "Replace tags",
+			parameters: map[string]string{VolumeTags: "team-a,gold"},
+			expectLinodeClientCalls: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(&linodego.Volume{ID: 1001, Tags: []string{"team-b", "csi-cluster:34ab3e"}}, nil)
+				m.EXPECT().UpdateVolume(gomock.Any(), 1001, linodego.VolumeUpdateOptions{Tags: &[]string{"team-a", "gold", "csi-cluster:34ab3e"}}).Return(&linodego.Volume{ID: 1001}, nil)
+			},
+			wantCode: codes.OK,
+		},
+		{
+			name:       "Remove tags",
+			parameters: map[string]string{VolumeTags: ""},
+			expectLinodeClientCalls: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(&linodego.Volume{ID: 1001, Tags: []string{"team-b", "csi-cluster:34ab3e"}}, nil)
+				m.EXPECT().UpdateVolume(gomock.Any(), 1001, linodego.VolumeUpdateOptions{Tags: &[]string{"csi-cluster:34ab3e"}}).Return(&linodego.Volume{ID: 1001}, nil)
+			},
+			wantCode: codes.OK,
+		},
+		{
+			name:       "Tags already applied",
+			parameters: map[string]string{VolumeTags: "team-a"},
+			expectLinodeClientCalls: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(&linodego.Volume{ID: 1001, Tags: []string{"team-a", "csi-cluster:34ab3e"}}, nil)
+			},
+			wantCode: codes.OK,
+		},
+		{
+			name:       "Stamp the driver",
+			version:    "v1.2.3",
+			parameters: map[string]string{VolumeTags: "team-a"},
+			expectLinodeClientCalls: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(&linodego.Volume{ID: 1001, Tags: []string{"team-a", "csi-driver:v1.0.0/c0ffee"}}, nil)
+				m.EXPECT().UpdateVolume(gomock.Any(), 1001, linodego.VolumeUpdateOptions{Tags: &[]string{"team-a", "csi-driver:v1.2.3/" + shortHash("")}}).Return(&linodego.Volume{ID: 1001}, nil)
+			},
+			wantCode: codes.OK,
+		},
+		{
+			name:       "No parameters",
+			parameters: nil,
+			expectLinodeClientCalls: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(&linodego.Volume{ID: 1001, Tags: []string{"team-a"}}, nil)
+			},
+			wantCode: codes.OK,
+		},
+		{
+			name:       "Unsupported parameter",
+			parameters: map[string]string{VolumeEncryption: True},
+			wantCode:   codes.InvalidArgument,
+		},
+		{
+			name:       "Reserved tag",
+			parameters: map[string]string{VolumeTags: "team-a,csi-provisioning"},
+			wantCode:   codes.InvalidArgument,
+		},
+		{
+			name:       "Volume not found",
+			parameters: map[string]string{VolumeTags: "team-a"},
+			expectLinodeClientCalls: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(nil, &linodego.Error{Code: 404})
+			},
+			wantCode: codes.NotFound,
+		},
+		{
+			name:       "Update failed",
+			parameters: map[string]string{VolumeTags: "team-a"},
+			expectLinodeClientCalls: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 1001).Return(&linodego.Volume{ID: 1001}, nil)
+				m.EXPECT().UpdateVolume(gomock.Any(), 1001, gomock.Any()).Return(nil, errors.New("API error"))
+			},
+			wantCode: codes.Internal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockClient := mocks.NewMockLinodeClient(ctrl)
+			if tt.expectLinodeClientCalls != nil {
+				tt.expectLinodeClientCalls(mockClient)
+			}
+
+			cs := &ControllerServer{client: mockClient, driver: &LinodeDriver{vendorVersion: tt.version}}
+			_, err := cs.ControllerModifyVolume(context.Background(), &csi.ControllerModifyVolumeRequest{
+				VolumeId:          "1001-vol",
+				MutableParameters: tt.parameters,
+			})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("ControllerModifyVolume() error = %v, want code %v", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestCreateParameters(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *csi.CreateVolumeRequest
+		want map[string]string
+	}{
+		{
+			name: "No mutable parameters",
+			req:  &csi.CreateVolumeRequest{Parameters: map[string]string{VolumeTags: "team-a", VolumeEncryption: True}},
+			want: map[string]string{VolumeTags: "team-a", VolumeEncryption: True},
+		},
+		{
+			name: "Mutable parameters",
+			req: &csi.CreateVolumeRequest{
+				Parameters:        map[string]string{VolumeTags: "team-a", VolumeEncryption: True},
+				MutableParameters: map[string]string{VolumeTags: "team-b"},
+			},
+			want: map[string]string{VolumeTags: "team-b", VolumeEncryption: True},
+		},
+		{
+			name: "Only mutable parameters",
+			req:  &csi.CreateVolumeRequest{MutableParameters: map[string]string{VolumeTags: "team-b"}},
+			want: map[string]string{VolumeTags: "team-b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := createParameters(tt.req); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("createParameters() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppliedParameters(t *testing.T) {
+	tests := []struct {
+		name string
+		tags []string
+		want map[string]string
+	}{
+		{name: "No tags", want: nil},
+		{name: "Only attributes", tags: []string{"csi-cluster:34ab3e"}, want: nil},
+		{name: "Tags", tags: []string{"team-a", "csi-cluster:34ab3e", "gold"}, want: map[string]string{VolumeTags: "team-a,gold"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vol := &linodego.Volume{Tags: tt.tags}
+			if got := appliedParameters(vol); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("appliedParameters() = %v, want %v", got, tt.want)
+			}
+			if len(vol.Tags) != len(tt.tags) {
+				t.Errorf("appliedParameters() changed the tags of the volume to %v", vol.Tags)
+			}
+		})
+	}
+}
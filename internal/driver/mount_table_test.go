@@ -0,0 +1,436 @@
+//go:build linux
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"go.uber.org/mock/gomock"
+	"k8s.io/mount-utils"
+	utilexec "k8s.io/utils/exec"
+	testingexec "k8s.io/utils/exec/testing"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+	devicemanager "github.com/linode/linode-blockstorage-csi-driver/pkg/device-manager"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/filesystem"
+)
+
+// fakeMountTable is a [mount.Interface] keeping the mounts of a node in
+// memory, so that a sequence of requests sees the mounts of the earlier
+// ones, already-mounted paths included. It outlives the node servers of a
+// test, as the mounts of a node outlive restarts of the kubelet and of the
+// node plugin. Mount points must exist, as they are real directories.
+type fakeMountTable struct {
+	mu     sync.Mutex
+	mounts []mount.MountPoint
+
+	// mountErr, if set, fails the next mount.
+	mountErr error
+
+	// mountCalls and unmountCalls count the mounts and unmounts made.
+	mountCalls   int
+	unmountCalls int
+}
+
+var _ mount.Interface = &fakeMountTable{}
+
+func (f *fakeMountTable) Mount(source, target, fstype string, options []string) error {
+	return f.MountSensitive(source, target, fstype, options, nil)
+}
+
+func (f *fakeMountTable) MountSensitive(source, target, fstype string, options, _ []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.mountErr; err != nil {
+		f.mountErr = nil
+		return err
+	}
+	if _, err := os.Stat(target); err != nil {
+		return fmt.Errorf("mount point %s: %w", target, err)
+	}
+	f.mounts = append(f.mounts, mount.MountPoint{Device: source, Path: target, Type: fstype, Opts: slices.Clone(options)})
+	f.mountCalls++
+	return nil
+}
+
+func (f *fakeMountTable) MountSensitiveWithoutSystemd(source, target, fstype string, options, sensitiveOptions []string) error {
+	return f.MountSensitive(source, target, fstype, options, sensitiveOptions)
+}
+
+func (f *fakeMountTable) MountSensitiveWithoutSystemdWithMountFlags(source, target, fstype string, options, sensitiveOptions, _ []string) error {
+	return f.MountSensitive(source, target, fstype, options, sensitiveOptions)
+}
+
+// Unmount removes the last mount on target, uncovering the ones below it.
+func (f *fakeMountTable) Unmount(target string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := len(f.mounts) - 1; i >= 0; i-- {
+		if f.mounts[i].Path == target {
+			f.mounts = slices.Delete(f.mounts, i, i+1)
+			f.unmountCalls++
+			return nil
+		}
+	}
+	return fmt.Errorf("umount %s: not mounted", target)
+}
+
+func (f *fakeMountTable) List() ([]mount.MountPoint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.mounts), nil
+}
+
+func (f *fakeMountTable) IsLikelyNotMountPoint(file string) (bool, error) {
+	mounted, err := f.IsMountPoint(file)
+	return !mounted, err
+}
+
+func (f *fakeMountTable) CanSafelySkipMountPointCheck() bool {
+	return false
+}
+
+func (f *fakeMountTable) IsMountPoint(file string) (bool, error) {
+	if _, err := os.Stat(file); err != nil {
+		return false, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.ContainsFunc(f.mounts, func(mp mount.MountPoint) bool { return mp.Path == file }), nil
+}
+
+// GetMountRefs returns the other mount points of the device mounted on
+// pathname.
+func (f *fakeMountTable) GetMountRefs(pathname string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var device string
+	for _, mp := range f.mounts {
+		if mp.Path == pathname {
+			device = mp.Device
+		}
+	}
+	var refs []string
+	for _, mp := range f.mounts {
+		if mp.Device == device && mp.Path != pathname {
+			refs = append(refs, mp.Path)
+		}
+	}
+	return refs, nil
+}
+
+// paths returns the mount points, relative to dir.
+func (f *fakeMountTable) paths(dir string) []string {
+	mounts, _ := f.List()
+	var paths []string
+	for _, mp := range mounts {
+		path, _ := filepath.Rel(dir, mp.Path)
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// fakeDisks is an executor running the commands NodeStageVolume runs on the
+// devices of volumes against an in-memory model of their contents, so that
+// the file systems created outlive the node servers of a test.
+type fakeDisks struct {
+	mu sync.Mutex
+
+	// formats are the file systems of the devices, by path.
+	formats map[string]string
+
+	// mkfsCalls counts the devices formatted.
+	mkfsCalls int
+}
+
+// fakeDiskBytes is the size of the devices of [fakeDisks], and of their file
+// systems.
+const fakeDiskBytes = 10 << 30
+
+var _ utilexec.Interface = &fakeDisks{}
+
+func (d *fakeDisks) Command(cmd string, args ...string) utilexec.Cmd {
+	return &testingexec.FakeCmd{
+		CombinedOutputScript: []testingexec.FakeAction{
+			func() ([]byte, []byte, error) {
+				out, err := d.run(cmd, args)
+				return []byte(out), nil, err
+			},
+		},
+	}
+}
+
+func (d *fakeDisks) CommandContext(_ context.Context, cmd string, args ...string) utilexec.Cmd {
+	return d.Command(cmd, args...)
+}
+
+func (d *fakeDisks) LookPath(file string) (string, error) {
+	return "/usr/sbin/" + file, nil
+}
+
+func (d *fakeDisks) run(cmd string, args []string) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	device := args[len(args)-1]
+	switch {
+	case cmd == "blkid":
+		if format := d.formats[device]; format != "" {
+			return fmt.Sprintf("DEVNAME=%s\nTYPE=%s\n", device, format), nil
+		}
+		return "", testingexec.FakeExitError{Status: 2}
+	case strings.HasPrefix(cmd, "mkfs."):
+		d.formats[device] = strings.TrimPrefix(cmd, "mkfs.")
+		d.mkfsCalls++
+		return "", nil
+	case cmd == "fsck":
+		return "", nil
+	case cmd == "blockdev" && args[0] == "--getro":
+		return "0", nil
+	case cmd == "blockdev" && args[0] == "--getsize64":
+		return fmt.Sprint(fakeDiskBytes), nil
+	case cmd == "dumpe2fs":
+		return fmt.Sprintf("Block count: %d\nBlock size: 4096\n", fakeDiskBytes/4096), nil
+	}
+	return "", fmt.Errorf("unexpected command %s %v", cmd, args)
+}
+
+// fakeAttachedDevices finds the devices of the volumes under their
+// /dev/disk/by-id path, as if they were all attached.
+type fakeAttachedDevices struct{}
+
+var _ devicemanager.DeviceUtils = fakeAttachedDevices{}
+
+func (fakeAttachedDevices) GetDiskByIdPaths(deviceName, _ string) []string {
+	return []string{"/dev/disk/by-id/linode-" + deviceName}
+}
+
+func (fakeAttachedDevices) VerifyDevicePath(devicePaths []string) (string, error) {
+	return devicePaths[0], nil
+}
+
+func (fakeAttachedDevices) PartitionDisk(string) error {
+	return errors.New("partitioning is not supported")
+}
+
+// fakeNode is a node with a volume attached, whose node server is replaced
+// when the node plugin restarts, while its mounts and disks persist.
+type fakeNode struct {
+	t     *testing.T
+	ctrl  *gomock.Controller
+	ns    *NodeServer
+	mount *fakeMountTable
+	disks *fakeDisks
+
+	dir, stagingPath, targetPath string
+}
+
+func newFakeNode(t *testing.T) *fakeNode {
+	dir := t.TempDir()
+	n := &fakeNode{
+		t:           t,
+		ctrl:        gomock.NewController(t),
+		mount:       &fakeMountTable{},
+		disks:       &fakeDisks{formats: make(map[string]string)},
+		dir:         dir,
+		stagingPath: filepath.Join(dir, "staging"),
+		targetPath:  filepath.Join(dir, "target"),
+	}
+	n.restart()
+	return n
+}
+
+// restart replaces the node server, losing its state in memory, as when the
+// node plugin or the kubelet restarts and the kubelet sends its requests
+// again.
+func (n *fakeNode) restart() {
+	crypt := mocks.NewMockCryptSetupClient(n.ctrl)
+	crypt.EXPECT().InitByName(gomock.Any()).Return(nil, os.ErrNotExist).AnyTimes()
+	n.ns = &NodeServer{
+		driver:      &LinodeDriver{},
+		mounter:     &mount.SafeFormatAndMount{Interface: n.mount, Exec: n.disks},
+		deviceutils: fakeAttachedDevices{},
+		encrypt:     NewLuksEncryption(n.disks, filesystem.NewFileSystem(), crypt),
+	}
+}
+
+// reboot restarts the node, which loses its mounts but keeps the contents of
+// its disks.
+func (n *fakeNode) reboot() {
+	n.mount.mu.Lock()
+	n.mount.mounts = nil
+	n.mount.mu.Unlock()
+	n.restart()
+}
+
+var fakeNodeCapability = &csi.VolumeCapability{
+	AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{FsType: "ext4"}},
+	AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+}
+
+func (n *fakeNode) stage() error {
+	_, err := n.ns.NodeStageVolume(context.Background(), &csi.NodeStageVolumeRequest{
+		VolumeId:          "1001-pvc1",
+		StagingTargetPath: n.stagingPath,
+		VolumeCapability:  fakeNodeCapability,
+	})
+	return err
+}
+
+func (n *fakeNode) publish() error {
+	_, err := n.ns.NodePublishVolume(context.Background(), &csi.NodePublishVolumeRequest{
+		VolumeId:          "1001-pvc1",
+		StagingTargetPath: n.stagingPath,
+		TargetPath:        n.targetPath,
+		VolumeCapability:  fakeNodeCapability,
+	})
+	return err
+}
+
+func (n *fakeNode) unpublish() error {
+	_, err := n.ns.NodeUnpublishVolume(context.Background(), &csi.NodeUnpublishVolumeRequest{
+		VolumeId:   "1001-pvc1",
+		TargetPath: n.targetPath,
+	})
+	return err
+}
+
+func (n *fakeNode) unstage() error {
+	_, err := n.ns.NodeUnstageVolume(context.Background(), &csi.NodeUnstageVolumeRequest{
+		VolumeId:          "1001-pvc1",
+		StagingTargetPath: n.stagingPath,
+	})
+	return err
+}
+
+// Steps of the sequences of TestNodeRequestSequences.
+const (
+	stepStage     = "stage"
+	stepPublish   = "publish"
+	stepUnpublish = "unpublish"
+	stepUnstage   = "unstage"
+	stepRestart   = "restart"
+	stepReboot    = "reboot"
+
+	// stepFailMount fails the next mount.
+	stepFailMount = "fail-mount"
+)
+
+// TestNodeRequestSequences sends sequences of requests for a volume to node
+// servers sharing a mount table, as the kubelet does when it retries them
+// or restarts, and checks the mounts made.
+func TestNodeRequestSequences(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []string
+		// failing are the indexes of the steps expected to fail.
+		failing []int
+
+		wantMounts       []string
+		wantMountCalls   int
+		wantUnmountCalls int
+		wantMkfsCalls    int
+	}{
+		{
+			name:           "Stage and publish",
+			steps:          []string{stepStage, stepPublish},
+			wantMounts:     []string{"staging", "target"},
+			wantMountCalls: 2,
+			wantMkfsCalls:  1,
+		},
+		{
+			name: "Restart after each request",
+			steps: []string{
+				stepStage, stepRestart, stepStage,
+				stepPublish, stepRestart, stepPublish,
+				stepUnpublish, stepRestart, stepUnpublish,
+				stepUnstage, stepRestart, stepUnstage,
+			},
+			wantMountCalls:   2,
+			wantUnmountCalls: 2,
+			wantMkfsCalls:    1,
+		},
+		{
+			name:           "Requests sent again",
+			steps:          []string{stepStage, stepStage, stepPublish, stepPublish},
+			wantMounts:     []string{"staging", "target"},
+			wantMountCalls: 2,
+			wantMkfsCalls:  1,
+		},
+		{
+			name:           "Restart after the mount failed",
+			steps:          []string{stepFailMount, stepStage, stepRestart, stepStage, stepPublish},
+			failing:        []int{1},
+			wantMounts:     []string{"staging", "target"},
+			wantMountCalls: 2,
+			wantMkfsCalls:  1,
+		},
+		{
+			name:           "Reboot while published",
+			steps:          []string{stepStage, stepPublish, stepReboot, stepStage, stepPublish},
+			wantMounts:     []string{"staging", "target"},
+			wantMountCalls: 4,
+			wantMkfsCalls:  1,
+		},
+		{
+			name:             "Staged again after it was unstaged",
+			steps:            []string{stepStage, stepPublish, stepUnpublish, stepUnstage, stepStage},
+			wantMounts:       []string{"staging"},
+			wantMountCalls:   3,
+			wantUnmountCalls: 2,
+			wantMkfsCalls:    1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := newFakeNode(t)
+			for i, step := range tt.steps {
+				var err error
+				switch step {
+				case stepStage:
+					err = node.stage()
+				case stepPublish:
+					err = node.publish()
+				case stepUnpublish:
+					err = node.unpublish()
+				case stepUnstage:
+					err = node.unstage()
+				case stepRestart:
+					node.restart()
+				case stepReboot:
+					node.reboot()
+				case stepFailMount:
+					node.mount.mountErr = errors.New("mount failed")
+				}
+				if wantErr := slices.Contains(tt.failing, i); (err != nil) != wantErr {
+					t.Fatalf("step %d (%s) error = %v, wantErr %v", i, step, err, wantErr)
+				}
+			}
+
+			if got := node.mount.paths(node.dir); !reflect.DeepEqual(got, tt.wantMounts) {
+				t.Errorf("mounts = %v, want %v", got, tt.wantMounts)
+			}
+			if node.mount.mountCalls != tt.wantMountCalls || node.mount.unmountCalls != tt.wantUnmountCalls {
+				t.Errorf("mounts, unmounts = %d, %d, want %d, %d", node.mount.mountCalls, node.mount.unmountCalls, tt.wantMountCalls, tt.wantUnmountCalls)
+			}
+			if node.disks.mkfsCalls != tt.wantMkfsCalls {
+				t.Errorf("mkfs calls = %d, want %d", node.disks.mkfsCalls, tt.wantMkfsCalls)
+			}
+		})
+	}
+}
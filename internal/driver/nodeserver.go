@@ -18,7 +18,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"strconv"
-	"sync"
 	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
@@ -41,8 +40,31 @@ type NodeServer struct {
 	client      linodeclient.LinodeClient
 	metadata    Metadata
 	encrypt     Encryption
-	// TODO: Only lock mutually exclusive calls and make locking more fine grained
-	mux sync.Mutex
+
+	// volumeLocks prevents concurrent operations on the same volume.
+	volumeLocks volumeLocks
+
+	// usage reports volume usage to PersistentVolumeClaims, if enabled.
+	usage *usageReporter
+
+	// selfTest records the dependencies found on the node at startup.
+	selfTest *nodeSelfTest
+
+	// signatures caches the file system signatures of the devices staged.
+	signatures signatureCache
+
+	// devices serializes the operations on the same device across requests.
+	devices deviceLocks
+
+	// disks caches the disks of the instance of the node.
+	disks instanceDiskCache
+
+	// watchdog mounts again the volumes whose mounts vanished, if enabled.
+	watchdog *mountWatchdog
+
+	// flaps checks that the devices of the staged volumes do not change, if
+	// enabled.
+	flaps *deviceFlapDetector
 
 	csi.UnimplementedNodeServer
 }
@@ -92,8 +114,11 @@ func (ns *NodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublis
 	volumeID := req.GetVolumeId()
 	log.V(2).Info("Processing request", "volumeID", volumeID)
 
-	ns.mux.Lock()
-	defer ns.mux.Unlock()
+	if !ns.volumeLocks.tryAcquire(volumeID) {
+		observability.RecordMetrics(observability.NodePublishTotal, observability.NodePublishDuration, observability.Failed, functionStartTime)
+		return nil, errOperationInProgress(volumeID)
+	}
+	defer ns.volumeLocks.release(volumeID)
 
 	// Validate the request object
 	log.V(4).Info("Validating request", "volumeID", volumeID)
@@ -102,10 +127,15 @@ func (ns *NodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublis
 		return nil, err
 	}
 
+	// Refuse to mount a volume the pod would not see
+	if err := ns.checkPublishPropagation(ctx, volumeID, req.GetTargetPath()); err != nil {
+		observability.RecordMetrics(observability.NodePublishTotal, observability.NodePublishDuration, observability.Failed, functionStartTime)
+		return nil, err
+	}
+
 	// Set mount options
-	options := []string{"bind"}
+	options := publishMountOptions(req)
 	if req.GetReadonly() {
-		options = append(options, "ro")
 		log.V(4).Info("Volume will be mounted as read-only", "volumeID", volumeID)
 	}
 
@@ -116,6 +146,8 @@ func (ns *NodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublis
 		response, err := ns.nodePublishVolumeBlock(ctx, req, options, fs)
 		if err != nil {
 			observability.RecordMetrics(observability.NodePublishTotal, observability.NodePublishDuration, observability.Failed, functionStartTime)
+		} else {
+			ns.throttlePublishedVolume(ctx, req)
 		}
 		observability.RecordMetrics(observability.NodePublishTotal, observability.NodePublishDuration, observability.Completed, functionStartTime)
 		return response, err
@@ -132,6 +164,9 @@ func (ns *NodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublis
 	}
 	if !notMnt {
 		log.V(4).Info("Target path is already a mount point", "volumeID", volumeID, "targetPath", targetPath)
+		if ns.watchdog != nil {
+			ns.watchdog.trackPublish(req)
+		}
 		observability.RecordMetrics(observability.NodePublishTotal, observability.NodePublishDuration, observability.Failed, functionStartTime)
 		return &csi.NodePublishVolumeResponse{}, nil
 	}
@@ -147,6 +182,12 @@ func (ns *NodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublis
 		return nil, errInternal("NodePublishVolume could not mount %s at %s: %v", stagingTargetPath, targetPath, err)
 	}
 
+	ns.throttlePublishedVolume(ctx, req)
+
+	if ns.watchdog != nil {
+		ns.watchdog.trackPublish(req)
+	}
+
 	// Record functionStatus metrics
 	observability.RecordMetrics(observability.NodePublishTotal, observability.NodePublishDuration, observability.Completed, functionStartTime)
 
@@ -163,8 +204,11 @@ func (ns *NodeServer) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpu
 	volumeID := req.GetVolumeId()
 	log.V(2).Info("Processing request", "volumeID", volumeID, "targetPath", targetPath)
 
-	ns.mux.Lock()
-	defer ns.mux.Unlock()
+	if !ns.volumeLocks.tryAcquire(volumeID) {
+		observability.RecordMetrics(observability.NodeUnpublishTotal, observability.NodeUnpublishDuration, observability.Failed, functionStartTime)
+		return nil, errOperationInProgress(volumeID)
+	}
+	defer ns.volumeLocks.release(volumeID)
 
 	// Validate request object
 	log.V(4).Info("Validating request", "volumeID", volumeID, "targetPath", targetPath)
@@ -181,6 +225,10 @@ func (ns *NodeServer) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpu
 		return nil, errInternal("NodeUnpublishVolume could not unmount %s: %v", targetPath, err)
 	}
 
+	if ns.watchdog != nil {
+		ns.watchdog.untrackPublish(volumeID, targetPath)
+	}
+
 	// Record functionStatus metric
 	observability.RecordMetrics(observability.NodeUnpublishTotal, observability.NodeUnpublishDuration, observability.Completed, functionStartTime)
 
@@ -196,8 +244,11 @@ func (ns *NodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeStageVol
 	volumeID := req.GetVolumeId()
 	log.V(2).Info("Processing request", "volumeID", volumeID)
 
-	ns.mux.Lock()
-	defer ns.mux.Unlock()
+	if !ns.volumeLocks.tryAcquire(volumeID) {
+		observability.RecordMetrics(observability.NodeStageVolumeTotal, observability.NodeStageVolumeDuration, observability.Failed, functionStartTime)
+		return nil, errOperationInProgress(volumeID)
+	}
+	defer ns.volumeLocks.release(volumeID)
 
 	// Before to functionStartTime, validate the request object (NodeStageVolumeRequest)
 	log.V(4).Info("Validating request", "volumeID", volumeID)
@@ -206,36 +257,23 @@ func (ns *NodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeStageVol
 		return nil, err
 	}
 
-	// Get the LinodeVolumeKey which we need to find the device path
-	LinodeVolumeKey, err := linodevolumes.ParseLinodeVolumeKey(volumeID)
-	if err != nil {
-		observability.RecordMetrics(observability.NodeStageVolumeTotal, observability.NodeStageVolumeDuration, observability.Failed, functionStartTime)
-		return nil, err
-	}
-
-	// Get device path of attached device
-	partition := ""
-
-	if part, ok := req.GetVolumeContext()["partition"]; ok {
-		partition = part
-	}
-
-	log.V(4).Info("Finding device path", "volumeID", volumeID)
-	devicePath, err := ns.findDevicePath(ctx, *LinodeVolumeKey, partition)
-	if err != nil {
-		observability.RecordMetrics(observability.NodeStageVolumeTotal, observability.NodeStageVolumeDuration, observability.Failed, functionStartTime)
-		return nil, err
+	if ns.usage != nil && req.GetVolumeCapability().GetBlock() == nil {
+		ns.usage.track(volumeID, req.GetVolumeContext())
 	}
 
 	// Check if staging target path is a valid mount point.
+	fs := filesystem.NewFileSystem()
 	log.V(4).Info("Ensuring staging target path is a valid mount point", "volumeID", volumeID, "stagingTargetPath", req.GetStagingTargetPath())
-	notMnt, err := ns.ensureMountPoint(ctx, req.GetStagingTargetPath(), filesystem.NewFileSystem())
+	notMnt, err := ns.ensureMountPoint(ctx, req.GetStagingTargetPath(), fs)
 	if err != nil {
 		observability.RecordMetrics(observability.NodeStageVolumeTotal, observability.NodeStageVolumeDuration, observability.Failed, functionStartTime)
 		return nil, err
 	}
 
-	if !notMnt {
+	// Volumes whose staging was interrupted after they were mounted resume
+	// with the following steps
+	marker := newStageMarker(req.GetStagingTargetPath(), fs)
+	if !notMnt && marker.load(ctx, volumeID) == "" {
 		// TODO(#95): Check who is mounted here. No error if its us
 		/*
 		   1) Target Path MUST be the vol referenced by vol ID
@@ -245,25 +283,35 @@ func (ns *NodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeStageVol
 		*/
 		observability.RecordMetrics(observability.NodeStageVolumeTotal, observability.NodeStageVolumeDuration, observability.Failed, functionStartTime)
 		log.V(4).Info("Staging target path is already a mount point", "volumeID", volumeID, "stagingTargetPath", req.GetStagingTargetPath())
+		if ns.watchdog != nil {
+			ns.watchdog.trackStage(req)
+		}
 		return &csi.NodeStageVolumeResponse{}, nil
 	}
 
-	// Check if the volume mode is set to 'Block'
-	// Do nothing else with the mount point for stage
-	if blk := req.GetVolumeCapability().GetBlock(); blk != nil {
+	st, err := ns.newStageState(ctx, req)
+	if err != nil {
 		observability.RecordMetrics(observability.NodeStageVolumeTotal, observability.NodeStageVolumeDuration, observability.Failed, functionStartTime)
-		log.V(4).Info("Volume is a block volume", "volumeID", volumeID)
-		return &csi.NodeStageVolumeResponse{}, nil
+		return nil, err
 	}
 
-	// Mount device to stagingTargetPath
-	// If LUKS is enabled, format the device accordingly
-	log.V(4).Info("Mounting device", "volumeID", volumeID, "devicePath", devicePath, "stagingTargetPath", req.GetStagingTargetPath())
-	if err := ns.mountVolume(ctx, devicePath, req); err != nil {
+	// Find the device, open it if it is encrypted, then format, mount and
+	// resize its file system, resuming after the steps completed by an
+	// earlier request. Block volumes are only opened if they are encrypted.
+	log.V(4).Info("Staging volume", "volumeID", volumeID, "stagingTargetPath", req.GetStagingTargetPath())
+	if err := ns.runStageSteps(ctx, st, stageSteps(req), marker); err != nil {
 		observability.RecordMetrics(observability.NodeStageVolumeTotal, observability.NodeStageVolumeDuration, observability.Failed, functionStartTime)
 		return nil, err
 	}
 
+	if ns.watchdog != nil {
+		ns.watchdog.trackStage(req)
+	}
+	if ns.flaps != nil {
+		ns.flaps.track(ctx, volumeID, st.devicePath)
+	}
+	observeMountLatency(ctx, st)
+
 	// Record functionStatus metric
 	observability.RecordMetrics(observability.NodeStageVolumeTotal, observability.NodeStageVolumeDuration, observability.Completed, functionStartTime)
 
@@ -280,8 +328,11 @@ func (ns *NodeServer) NodeUnstageVolume(ctx context.Context, req *csi.NodeUnstag
 	volumeID := req.GetVolumeId()
 	log.V(2).Info("Processing request", "volumeID", volumeID, "stagingTargetPath", stagingTargetPath)
 
-	ns.mux.Lock()
-	defer ns.mux.Unlock()
+	if !ns.volumeLocks.tryAcquire(volumeID) {
+		observability.RecordMetrics(observability.NodeUnstageVolumeTotal, observability.NodeUnstageVolumeDuration, observability.Failed, functionStartTime)
+		return nil, errOperationInProgress(volumeID)
+	}
+	defer ns.volumeLocks.release(volumeID)
 
 	// Validate req (NodeUnstageVolumeRequest)
 	log.V(4).Info("Validating request", "volumeID", volumeID, "stagingTargetPath", stagingTargetPath)
@@ -291,6 +342,14 @@ func (ns *NodeServer) NodeUnstageVolume(ctx context.Context, req *csi.NodeUnstag
 		return nil, err
 	}
 
+	// Wait for the operations of other requests on the device of the volume
+	release, err := ns.devices.acquireVolume(ctx, volumeID, deviceOpUnstage)
+	if err != nil {
+		observability.RecordMetrics(observability.NodeUnstageVolumeTotal, observability.NodeUnstageVolumeDuration, observability.Failed, functionStartTime)
+		return nil, err
+	}
+	defer release()
+
 	log.V(4).Info("Unmounting staging target path", "volumeID", volumeID, "stagingTargetPath", stagingTargetPath)
 	err = mount.CleanupMountPoint(stagingTargetPath, ns.mounter.Interface, true /* bind mount */)
 	if err != nil {
@@ -298,6 +357,12 @@ func (ns *NodeServer) NodeUnstageVolume(ctx context.Context, req *csi.NodeUnstag
 		return nil, errInternal("NodeUnstageVolume failed to unmount at path %s: %v", stagingTargetPath, err)
 	}
 
+	// Forget about the steps of an interrupted staging
+	newStageMarker(stagingTargetPath, filesystem.NewFileSystem()).remove(ctx)
+
+	// Restore the queue settings of the device changed when it was staged
+	restoreDeviceTuning(ctx, stagingTargetPath)
+
 	// If LUKS volume is used, close the LUKS device
 	log.V(4).Info("Closing LUKS device", "volumeID", volumeID, "stagingTargetPath", stagingTargetPath)
 	if err := ns.closeLuksMountSource(ctx, volumeID); err != nil {
@@ -305,6 +370,17 @@ func (ns *NodeServer) NodeUnstageVolume(ctx context.Context, req *csi.NodeUnstag
 		return nil, fmt.Errorf("closing luks to unstage volume %s: %w", volumeID, err)
 	}
 
+	if ns.usage != nil {
+		ns.usage.untrack(volumeID)
+	}
+	if ns.watchdog != nil {
+		ns.watchdog.untrack(volumeID)
+	}
+	if ns.flaps != nil {
+		ns.flaps.untrack(volumeID)
+	}
+	ns.devices.forget(volumeID)
+
 	// Record functionStatus metric
 	observability.RecordMetrics(observability.NodeUnstageVolumeTotal, observability.NodeUnstageVolumeDuration, observability.Completed, functionStartTime)
 
@@ -344,6 +420,16 @@ func (ns *NodeServer) NodeExpandVolume(ctx context.Context, req *csi.NodeExpandV
 		return nil, errInternal("marshal json filter: %v", err)
 	}
 
+	// The file system is resized when the volume is staged: hold the device
+	// for the whole expansion, so that it waits for a stage in progress and
+	// the capacity returned is the one of the file system
+	release, err := ns.devices.acquireVolume(ctx, volumeID, deviceOpExpand)
+	if err != nil {
+		observability.RecordMetrics(observability.NodeExpandTotal, observability.NodeExpandDuration, observability.Failed, functionStartTime)
+		return nil, err
+	}
+	defer release()
+
 	log.V(4).Info("Listing volumes", "volumeID", volumeID)
 	if _, err = ns.client.ListVolumes(ctx, linodego.NewListOptions(0, string(jsonFilter))); err != nil {
 		observability.RecordMetrics(observability.NodeExpandTotal, observability.NodeExpandDuration, observability.Failed, functionStartTime)
@@ -383,13 +469,14 @@ func (ns *NodeServer) NodeGetInfo(ctx context.Context, req *csi.NodeGetInfoReque
 	//
 	// This is what the spec wants us to report: the actual number of volumes
 	// that can be attached, and not the theoretical maximum number of
-	// devices that can be attached.
-	log.V(4).Info("Listing instance disks", "nodeID", ns.metadata.ID)
-	disks, err := ns.client.ListInstanceDisks(ctx, ns.metadata.ID, nil)
+	// devices that can be attached. Disks excluded by the options of the
+	// driver are not subtracted. The disks are cached for a short while,
+	// since the kubelet and the sidecars call NodeGetInfo repeatedly.
+	disks, err := ns.instanceDisks(ctx)
 	if err != nil {
 		return &csi.NodeGetInfoResponse{}, errInternal("list instance disks: %v", err)
 	}
-	maxVolumes := maxVolumeAttachments(ns.metadata.Memory) - len(disks)
+	maxVolumes := ns.driver.volumeAttachmentLimit(ctx, ns.metadata.ID, ns.metadata.Memory, disks)
 
 	log.V(2).Info("functionStatusfully completed")
 	return &csi.NodeGetInfoResponse{
@@ -409,5 +496,10 @@ func (ns *NodeServer) NodeGetVolumeStats(ctx context.Context, req *csi.NodeGetVo
 
 	log.V(2).Info("Processing request", "req", req)
 
-	return nodeGetVolumeStats(ctx, req)
+	resp, err := nodeGetVolumeStats(ctx, req)
+	if err == nil && ns.usage != nil {
+		ns.usage.trackPublished(ctx, req.GetVolumeId(), req.GetVolumePath())
+		ns.usage.observe(req.GetVolumeId(), resp)
+	}
+	return resp, err
 }
@@ -6,6 +6,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"golang.org/x/sys/unix"
@@ -15,9 +17,60 @@ import (
 	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
 )
 
+// volumeStatsSupported is true when NodeGetVolumeStats reports the usage
+// and condition of both filesystem and block volumes, so that the node
+// advertises the GET_VOLUME_STATS and VOLUME_CONDITION capabilities.
+const volumeStatsSupported = true
+
 // unixStatfs is used to mock the unix.Statfs function.
 var unixStatfs = unix.Statfs
 
+// unixStat is used to mock the unix.Stat function.
+var unixStat = unix.Stat
+
+// sameBlockDevice reports whether the paths a and b, following symbolic
+// links, are both the same block device. It is a variable so it can be
+// mocked.
+var sameBlockDevice = func(a, b string) (bool, error) {
+	var statA, statB unix.Stat_t
+	if err := unixStat(a, &statA); err != nil {
+		return false, err
+	}
+	if err := unixStat(b, &statB); err != nil {
+		return false, err
+	}
+	isBlock := func(stat *unix.Stat_t) bool { return stat.Mode&unix.S_IFMT == unix.S_IFBLK }
+	return isBlock(&statA) && isBlock(&statB) && statA.Rdev == statB.Rdev, nil
+}
+
+// deviceNumber returns the device number of path as "major:minor": the one
+// of the block device at path, or else of the device of its file system. It
+// is a variable so it can be mocked.
+var deviceNumber = func(path string) (string, error) {
+	var stat unix.Stat_t
+	if err := unixStat(path, &stat); err != nil {
+		return "", err
+	}
+	dev := uint64(stat.Dev)
+	if stat.Mode&unix.S_IFMT == unix.S_IFBLK {
+		dev = uint64(stat.Rdev)
+	}
+	return fmt.Sprintf("%d:%d", unix.Major(dev), unix.Minor(dev)), nil
+}
+
+// blockDeviceSize returns the size in bytes of the block device at path. It
+// is a variable so it can be mocked.
+var blockDeviceSize = func(path string) (size int64, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		err = errors.Join(err, f.Close())
+	}()
+	return f.Seek(0, io.SeekEnd)
+}
+
 func nodeGetVolumeStats(ctx context.Context, req *csi.NodeGetVolumeStatsRequest) (*csi.NodeGetVolumeStatsResponse, error) {
 	log := logger.GetLogger(ctx)
 
@@ -25,8 +78,17 @@ func nodeGetVolumeStats(ctx context.Context, req *csi.NodeGetVolumeStatsRequest)
 		return nil, status.Error(codes.InvalidArgument, "volume ID or path empty")
 	}
 
+	// Block volumes are published as device files, whose file system is
+	// the one of the kubelet directory
+	var stat unix.Stat_t
+	if err := unixStat(req.GetVolumePath(), &stat); err == nil && stat.Mode&unix.S_IFMT == unix.S_IFBLK {
+		return blockVolumeStats(ctx, req)
+	}
+
 	var statfs unix.Statfs_t
 	// See http://man7.org/linux/man-pages/man2/statfs.2.html for details.
+	// For volumes with a project quota, statfs reports the usage and limit
+	// of the project rather than those of the file system.
 	err := unixStatfs(req.GetVolumePath(), &statfs)
 	switch {
 	case errors.Is(err, unix.EIO):
@@ -69,3 +131,43 @@ func nodeGetVolumeStats(ctx context.Context, req *csi.NodeGetVolumeStatsRequest)
 	log.V(2).Info("Successfully retrieved volume stats", "volumeID", req.GetVolumeId(), "volumePath", req.GetVolumePath(), "response", response)
 	return response, nil
 }
+
+// blockVolumeStats returns the size of the block volume published at the
+// volume path of req. Only the total size is known, since the usage of the
+// device is up to the workload, and the kubelet expects no inode usage for
+// block volumes.
+func blockVolumeStats(ctx context.Context, req *csi.NodeGetVolumeStatsRequest) (*csi.NodeGetVolumeStatsResponse, error) {
+	log := logger.GetLogger(ctx)
+
+	size, err := blockDeviceSize(req.GetVolumePath())
+	switch {
+	case errors.Is(err, unix.EIO), errors.Is(err, unix.ENXIO):
+		// The device is gone, e.g. because the volume was detached.
+		return &csi.NodeGetVolumeStatsResponse{
+			VolumeCondition: &csi.VolumeCondition{
+				Abnormal: true,
+				Message:  fmt.Sprintf("failed to get block device size: %v", err.Error()),
+			},
+		}, nil
+	case errors.Is(err, unix.ENOENT):
+		return nil, status.Errorf(codes.NotFound, "volume path not found: %v", err.Error())
+	case err != nil:
+		return nil, status.Errorf(codes.Internal, "failed to get block device size: %v", err.Error())
+	}
+
+	response := &csi.NodeGetVolumeStatsResponse{
+		Usage: []*csi.VolumeUsage{
+			{
+				Total: size,
+				Unit:  csi.VolumeUsage_BYTES,
+			},
+		},
+		VolumeCondition: &csi.VolumeCondition{
+			Abnormal: false,
+			Message:  "healthy",
+		},
+	}
+
+	log.V(2).Info("Successfully retrieved block volume stats", "volumeID", req.GetVolumeId(), "volumePath", req.GetVolumePath(), "response", response)
+	return response, nil
+}
@@ -3,6 +3,8 @@ package driver
 import (
 	"context"
 	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
@@ -127,3 +129,190 @@ func TestNodeGetVolumeStats(t *testing.T) {
 		})
 	}
 }
+
+func TestNodeGetVolumeStatsBlock(t *testing.T) {
+	defaultStat, defaultSize := unixStat, blockDeviceSize
+	t.Cleanup(func() {
+		unixStat, blockDeviceSize = defaultStat, defaultSize
+	})
+	unixStat = func(_ string, stat *unix.Stat_t) error {
+		stat.Mode = unix.S_IFBLK | 0o660
+		return nil
+	}
+	blockDeviceSize = func(path string) (int64, error) {
+		switch path {
+		case "/dev/block":
+			return 10 << 30, nil
+		case "/dev/detached":
+			return 0, unix.ENXIO
+		default:
+			return 0, errors.New("internal error")
+		}
+	}
+
+	testCases := []struct {
+		name        string
+		volumePath  string
+		expectedErr error
+		expectedRes *csi.NodeGetVolumeStatsResponse
+	}{
+		{
+			name:       "Block volume",
+			volumePath: "/dev/block",
+			expectedRes: &csi.NodeGetVolumeStatsResponse{
+				Usage: []*csi.VolumeUsage{
+					{
+						Total: 10 << 30,
+						Unit:  csi.VolumeUsage_BYTES,
+					},
+				},
+				VolumeCondition: &csi.VolumeCondition{
+					Abnormal: false,
+					Message:  "healthy",
+				},
+			},
+		},
+		{
+			name:       "Detached block volume",
+			volumePath: "/dev/detached",
+			expectedRes: &csi.NodeGetVolumeStatsResponse{
+				VolumeCondition: &csi.VolumeCondition{
+					Abnormal: true,
+					Message:  "failed to get block device size: no such device or address",
+				},
+			},
+		},
+		{
+			name:        "Internal error",
+			volumePath:  "/dev/error",
+			expectedErr: status.Errorf(codes.Internal, "failed to get block device size: internal error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := nodeGetVolumeStats(context.Background(), &csi.NodeGetVolumeStatsRequest{
+				VolumeId:   "block-volume",
+				VolumePath: tc.volumePath,
+			})
+
+			if tc.expectedErr != nil {
+				require.EqualError(t, err, tc.expectedErr.Error())
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedRes, resp)
+		})
+	}
+}
+
+// TestNodeGetVolumeStatsKubelet checks the stats of volumes against what the
+// kubelet expects: byte and inode usage for filesystem volumes, and only the
+// size of block volumes.
+func TestNodeGetVolumeStatsKubelet(t *testing.T) {
+	defaultStatfs, defaultStat := unixStatfs, unixStat
+	t.Cleanup(func() {
+		unixStatfs, unixStat = defaultStatfs, defaultStat
+	})
+	unixStatfs, unixStat = unix.Statfs, unix.Stat
+
+	ns := &NodeServer{driver: &LinodeDriver{nscap: NodeServiceCapabilities()}}
+
+	t.Run("Filesystem volume", func(t *testing.T) {
+		resp, err := ns.NodeGetVolumeStats(context.Background(), &csi.NodeGetVolumeStatsRequest{
+			VolumeId:   "fs-volume",
+			VolumePath: t.TempDir(),
+		})
+		require.NoError(t, err)
+		require.Len(t, resp.GetUsage(), 2)
+
+		bytes, inodes := resp.GetUsage()[0], resp.GetUsage()[1]
+		assert.Equal(t, csi.VolumeUsage_BYTES, bytes.GetUnit())
+		assert.Positive(t, bytes.GetTotal())
+		assert.LessOrEqual(t, bytes.GetUsed()+bytes.GetAvailable(), bytes.GetTotal())
+		assert.Equal(t, csi.VolumeUsage_INODES, inodes.GetUnit())
+		assert.False(t, resp.GetVolumeCondition().GetAbnormal())
+	})
+
+	t.Run("Block volume", func(t *testing.T) {
+		// A regular file stands for the device file, which cannot be
+		// created without privileges
+		device := filepath.Join(t.TempDir(), "device")
+		require.NoError(t, os.WriteFile(device, make([]byte, 1<<20), 0o600))
+		unixStat = func(path string, stat *unix.Stat_t) error {
+			err := unix.Stat(path, stat)
+			stat.Mode = stat.Mode&^unix.S_IFMT | unix.S_IFBLK
+			return err
+		}
+
+		resp, err := ns.NodeGetVolumeStats(context.Background(), &csi.NodeGetVolumeStatsRequest{
+			VolumeId:   "block-volume",
+			VolumePath: device,
+		})
+		require.NoError(t, err)
+		require.Len(t, resp.GetUsage(), 1)
+
+		bytes := resp.GetUsage()[0]
+		assert.Equal(t, csi.VolumeUsage_BYTES, bytes.GetUnit())
+		assert.Equal(t, int64(1<<20), bytes.GetTotal())
+		assert.False(t, resp.GetVolumeCondition().GetAbnormal())
+	})
+}
+
+func TestNodeServiceCapabilitiesVolumeStats(t *testing.T) {
+	advertised := map[csi.NodeServiceCapability_RPC_Type]bool{}
+	for _, c := range NodeServiceCapabilities() {
+		advertised[c.GetRpc().GetType()] = true
+	}
+	for _, c := range []csi.NodeServiceCapability_RPC_Type{csi.NodeServiceCapability_RPC_GET_VOLUME_STATS, csi.NodeServiceCapability_RPC_VOLUME_CONDITION} {
+		if advertised[c] != volumeStatsSupported {
+			t.Errorf("capability %v advertised = %v, want %v", c, advertised[c], volumeStatsSupported)
+		}
+	}
+}
+
+func TestSameBlockDevice(t *testing.T) {
+	defaultStat := unixStat
+	t.Cleanup(func() {
+		unixStat = defaultStat
+	})
+	devices := map[string]struct {
+		mode uint32
+		rdev uint64
+	}{
+		"/dev/disk/by-id/scsi-0Linode_Volume_pvc-1": {mode: unix.S_IFBLK, rdev: unix.Mkdev(8, 16)},
+		"/mnt/target-1": {mode: unix.S_IFBLK, rdev: unix.Mkdev(8, 16)},
+		"/mnt/target-2": {mode: unix.S_IFBLK, rdev: unix.Mkdev(8, 32)},
+		"/mnt/file":     {mode: unix.S_IFREG},
+	}
+	unixStat = func(path string, stat *unix.Stat_t) error {
+		device, ok := devices[path]
+		if !ok {
+			return unix.ENOENT
+		}
+		stat.Mode, stat.Rdev = device.mode|0o660, device.rdev
+		return nil
+	}
+
+	testCases := []struct {
+		targetPath string
+		want       bool
+		wantErr    bool
+	}{
+		{targetPath: "/mnt/target-1", want: true},
+		{targetPath: "/mnt/target-2", want: false},
+		{targetPath: "/mnt/file", want: false},
+		{targetPath: "/mnt/missing", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.targetPath, func(t *testing.T) {
+			got, err := sameBlockDevice("/dev/disk/by-id/scsi-0Linode_Volume_pvc-1", tc.targetPath)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
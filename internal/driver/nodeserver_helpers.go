@@ -16,9 +16,11 @@ limitations under the License.
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
@@ -35,6 +37,14 @@ const (
 	ownerGroupReadWritePermissions = os.FileMode(0o660)
 )
 
+// supportedFSTypes are the file systems volumes can be formatted with.
+var supportedFSTypes = []string{"ext3", "ext4", "xfs"}
+
+// supportedFSType reports whether fsType is one of [supportedFSTypes].
+func supportedFSType(fsType string) bool {
+	return slices.Contains(supportedFSTypes, fsType)
+}
+
 // ValidateNodeStageVolumeRequest validates the node stage volume request.
 // It validates the volume ID, staging target path, and volume capability.
 func validateNodeStageVolumeRequest(ctx context.Context, req *csi.NodeStageVolumeRequest) error {
@@ -130,13 +140,27 @@ func validateNodeUnpublishVolumeRequest(ctx context.Context, req *csi.NodeUnpubl
 }
 
 // getFSTypeAndMountOptions retrieves the file system type and mount options from the given volume capability.
-// If the capability is not set, the default file system type and empty mount options will be returned.
-func getFSTypeAndMountOptions(ctx context.Context, volumeCapability *csi.VolumeCapability) (fsType string, mountOptions []string) {
+// The file system type set in the volume capability takes precedence over the one set in the volume context
+// (from the StorageClass), which takes precedence over driverFSType. If none of them are set, [defaultFSType]
+// is returned. The mount options are driverMountOptions followed by the mount flags of the volume capability,
+// without duplicates.
+func getFSTypeAndMountOptions(ctx context.Context, volumeCapability *csi.VolumeCapability, volumeContext map[string]string, driverFSType string, driverMountOptions []string) (fsType string, mountOptions []string) {
 	log := logger.GetLogger(ctx)
 	log.V(4).Info("Entering getFSTypeAndMountOptions", "volumeCapability", volumeCapability)
 
 	// Use default file system type if not specified in the volume capability
-	fsType = defaultFSType
+	switch {
+	case volumeContext[FilesystemTypeAttribute] != "":
+		fsType = volumeContext[FilesystemTypeAttribute]
+	case driverFSType != "":
+		fsType = driverFSType
+	default:
+		fsType = defaultFSType
+	}
+
+	// Mount options set for the driver come first, so that the ones of the
+	// volume capability override them
+	mountOptions = appendMountOptions(mountOptions, driverMountOptions...)
 
 	if mnt := volumeCapability.GetMount(); mnt != nil {
 		// Use file system type from volume capability if specified
@@ -144,14 +168,12 @@ func getFSTypeAndMountOptions(ctx context.Context, volumeCapability *csi.VolumeC
 			fsType = mnt.GetFsType()
 		}
 		// Use mount options from volume capability if specified
-		if mnt.MountFlags != nil {
-			mountOptions = mnt.GetMountFlags()
-		}
+		mountOptions = appendMountOptions(mountOptions, mnt.GetMountFlags()...)
 	}
 
 	// Add specific mount options for XFS
 	if fsType == "xfs" {
-		mountOptions = append(mountOptions, "nouuid")
+		mountOptions = appendMountOptions(mountOptions, "nouuid")
 		// Note: "rw" is typically the default and doesn't need to be specified
 	}
 
@@ -159,6 +181,16 @@ func getFSTypeAndMountOptions(ctx context.Context, volumeCapability *csi.VolumeC
 	return fsType, mountOptions
 }
 
+// appendMountOptions appends the options that are not in mountOptions yet.
+func appendMountOptions(mountOptions []string, options ...string) []string {
+	for _, option := range options {
+		if !slices.Contains(mountOptions, option) {
+			mountOptions = append(mountOptions, option)
+		}
+	}
+	return mountOptions
+}
+
 // findDevicePath locates the device path for a Linode Volume.
 //
 // It uses the provided LinodeVolumeKey and partition information to generate
@@ -177,6 +209,24 @@ func (ns *NodeServer) findDevicePath(ctx context.Context, key linodevolumes.Lino
 		return "", errInternal("Error verifying Linode Volume (%q) is attached: %v", key.GetVolumeLabel(), err)
 	}
 
+	// The volume handle keeps the label the volume was created with. Volumes
+	// renamed since then are attached under their current label.
+	if devicePath == "" && ns.client != nil {
+		volume, err := ns.client.GetVolume(ctx, key.VolumeID)
+		if err != nil {
+			return "", errInternal("get volume %d: %v", key.VolumeID, err)
+		}
+		current := linodevolumes.CreateLinodeVolumeKey(volume.ID, volume.Label)
+		if currentName := current.GetNormalizedLabel(); currentName != deviceName {
+			log.V(4).Info("Volume was renamed, looking for its device under its current label", "label", volume.Label)
+			deviceName = currentName
+			devicePaths = append(devicePaths, ns.deviceutils.GetDiskByIdPaths(deviceName, partition)...)
+			if devicePath, err = ns.deviceutils.VerifyDevicePath(devicePaths); err != nil {
+				return "", errInternal("Error verifying Linode Volume (%q) is attached: %v", volume.Label, err)
+			}
+		}
+	}
+
 	// If no device path is found, return an error.
 	if devicePath == "" {
 		return "", errInternal("Unable to find device path out of attempted paths: %v", devicePaths)
@@ -221,6 +271,16 @@ func (ns *NodeServer) ensureMountPoint(ctx context.Context, path string, fs file
 // The function creates the target directory, creates a file to bind mount the block device to,
 // and mounts the volume using the provided mount options.
 // It returns a CSI NodePublishVolumeResponse and an error if the operation fails.
+// publishMountOptions returns the options of the bind mount of the volume of
+// req to its target path.
+func publishMountOptions(req *csi.NodePublishVolumeRequest) []string {
+	options := []string{"bind"}
+	if req.GetReadonly() {
+		options = append(options, "ro")
+	}
+	return options
+}
+
 func (ns *NodeServer) nodePublishVolumeBlock(ctx context.Context, req *csi.NodePublishVolumeRequest, mountOptions []string, fs filesystem.FileSystem) (*csi.NodePublishVolumeResponse, error) {
 	log := logger.GetLogger(ctx)
 	log.V(4).Info("Entering nodePublishVolumeBlock", "req", req, "mountOptions", mountOptions)
@@ -234,6 +294,26 @@ func (ns *NodeServer) nodePublishVolumeBlock(ctx context.Context, req *csi.NodeP
 		return nil, errInternal("devicePath cannot be found")
 	}
 
+	// Encrypted volumes were opened when they were staged, publish the
+	// decrypted device instead
+	if luksContext := getLuksContext(nil, req.GetVolumeContext(), VolumeLifecycleNodePublishVolume); luksContext.EncryptionEnabled {
+		if luksContext.VolumeName == "" {
+			return nil, errInternal("LUKS volume name cannot be found")
+		}
+		devicePath = luksDevicePath(luksContext.VolumeName)
+	}
+
+	// Succeed if the device is already published at the target path, as
+	// the file system volumes do
+	published, err := ns.blockDevicePublished(devicePath, targetPath)
+	if err != nil {
+		return nil, err
+	}
+	if published {
+		log.V(4).Info("Block device is already published", "devicePath", devicePath, "targetPath", targetPath)
+		return &csi.NodePublishVolumeResponse{}, nil
+	}
+
 	// Create directory at the directory level of given path
 	log.V(4).Info("Making targetPathDir", "targetPathDir", targetPathDir)
 	if err := fs.MkdirAll(targetPathDir, rwPermission); err != nil {
@@ -269,42 +349,108 @@ func (ns *NodeServer) nodePublishVolumeBlock(ctx context.Context, req *csi.NodeP
 	return &csi.NodePublishVolumeResponse{}, nil
 }
 
-// mountVolume formats and mounts a volume to the staging target path.
-//
-// It handles both encrypted (LUKS) and non-encrypted volumes. For LUKS volumes,
-// it prepares the encrypted volume before mounting. The function determines
-// the filesystem type and mount options from the volume capability.
-func (ns *NodeServer) mountVolume(ctx context.Context, devicePath string, req *csi.NodeStageVolumeRequest) error {
-	log := logger.GetLogger(ctx)
-	log.V(4).Info("Entering mountVolume", "devicePath", devicePath, "req", req)
+// blockDevicePublished reports whether devicePath is bind mounted at
+// targetPath, and fails with AlreadyExists if another device is.
+func (ns *NodeServer) blockDevicePublished(devicePath, targetPath string) (bool, error) {
+	notMnt, err := ns.mounter.IsLikelyNotMountPoint(targetPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return false, nil
+	case err != nil:
+		return false, errInternal("Failed to check if %q is a mount point: %v", targetPath, err)
+	case notMnt:
+		return false, nil
+	}
+
+	same, err := sameBlockDevice(devicePath, targetPath)
+	if err != nil {
+		return false, errInternal("Failed to compare %q with the device mounted at %q: %v", devicePath, targetPath, err)
+	}
+	if !same {
+		return false, errAlreadyExists("another device than %q is mounted at %q", devicePath, targetPath)
+	}
+	return true, nil
+}
+
+// driverFSType returns the file system type configured for the driver with
+// [Options.DefaultFSType], if any.
+func (ns *NodeServer) driverFSType() string {
+	if ns.driver == nil {
+		return ""
+	}
+	return ns.driver.opts.DefaultFSType
+}
+
+// driverMountOptions returns the mount options configured for the driver
+// with [Options.DefaultMountOptions], if any.
+func (ns *NodeServer) driverMountOptions() []string {
+	if ns.driver == nil {
+		return nil
+	}
+	return ns.driver.opts.DefaultMountOptions
+}
 
-	stagingTargetPath := req.GetStagingTargetPath()
-	volumeCapability := req.GetVolumeCapability()
+// driverReadOnlyNoRecovery reports whether volumes staged read-only are
+// mounted with norecovery, as set with [Options.ReadOnlyNoRecovery].
+func (ns *NodeServer) driverReadOnlyNoRecovery() bool {
+	return ns.driver != nil && ns.driver.opts.ReadOnlyNoRecovery
+}
 
-	// Retrieve the file system type and mount options from the volume capability
-	fsType, mountOptions := getFSTypeAndMountOptions(ctx, volumeCapability)
+// stagedReadOnly reports whether a volume with volumeCapability, mounted with
+// mountOptions, is staged read-only: its access mode only allows reading, or
+// it is mounted with "ro".
+func stagedReadOnly(volumeCapability *csi.VolumeCapability, mountOptions []string) bool {
+	switch volumeCapability.GetAccessMode().GetMode() {
+	case csi.VolumeCapability_AccessMode_SINGLE_NODE_READER_ONLY, csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY:
+		return true
+	}
+	return slices.Contains(mountOptions, "ro")
+}
 
-	fmtAndMountSource := devicePath
+// kubeletPublishDirs are in the target paths the kubelet publishes the CSI
+// volumes of pods to: "volumes/kubernetes.io~csi" in the directory of the
+// pod for file system volumes, and "kubernetes.io/csi/volumeDevices" in the
+// plugins directory for block volumes.
+var kubeletPublishDirs = []string{"/volumes/kubernetes.io~csi/", "/kubernetes.io/csi/volumeDevices/"}
+
+// checkPublishPropagation checks that the volume published to targetPath by
+// the kubelet will be visible to the pod, unless the driver runs in
+// [EnforcementWarn] mode. The mount propagation of target paths the kubelet
+// did not create is not checked.
+func (ns *NodeServer) checkPublishPropagation(ctx context.Context, volumeID, targetPath string) error {
+	if !slices.ContainsFunc(kubeletPublishDirs, func(dir string) bool { return strings.Contains(targetPath, dir) }) {
+		return nil
+	}
+	err := checkMountPropagation(ctx, filepath.Dir(targetPath))
+	return ns.driver.enforce(ctx, validationMountPropagation, err, "volumeID", volumeID, "targetPath", targetPath)
+}
+
+// openLUKSBlockVolume opens the LUKS device of an encrypted raw block volume
+// at devicePath, formatting it first if needed. The device mapper device is
+// bind mounted to the target path by [NodeServer.nodePublishVolumeBlock] and
+// closed when the volume is unstaged. Volumes that are not encrypted are left
+// untouched.
+func (ns *NodeServer) openLUKSBlockVolume(ctx context.Context, devicePath string, req *csi.NodeStageVolumeRequest) error {
+	log := logger.GetLogger(ctx)
+	log.V(4).Info("Entering openLUKSBlockVolume", "devicePath", devicePath)
+	defer log.V(4).Info("Exiting openLUKSBlockVolume")
 
-	// Check if LUKS encryption is enabled and prepare the LUKS volume if needed
 	luksContext := getLuksContext(req.GetSecrets(), req.GetVolumeContext(), VolumeLifecycleNodeStageVolume)
-	if luksContext.EncryptionEnabled {
-		var err error
-		log.V(4).Info("preparing luks volume", "devicePath", devicePath)
-		fmtAndMountSource, err = ns.formatLUKSVolume(ctx, devicePath, &luksContext)
-		if err != nil {
+	if !luksContext.EncryptionEnabled {
+		return nil
+	}
+	if !ns.selfTest.supportsEncryption() {
+		err := errMissingNodeDependencies("LUKS encryption", dmCryptDependency)
+		if err := ns.driver.enforce(ctx, validationNodeDependencies, err, "volume_id", req.GetVolumeId()); err != nil {
 			return err
 		}
 	}
 
-	// Format and mount the drive
-	log.V(4).Info("formatting and mounting the volume")
-	if err := ns.mounter.FormatAndMount(fmtAndMountSource, stagingTargetPath, fsType, mountOptions); err != nil {
-		return errInternal("Failed to format and mount device from (%q)---(%q) to (%q) with fstype (%q) and options (%q): %v",
-			fmtAndMountSource, devicePath, stagingTargetPath, fsType, mountOptions, err)
+	luksSource, err := ns.formatLUKSVolume(ctx, devicePath, &luksContext)
+	if err != nil {
+		return err
 	}
-
-	log.V(4).Info("Exiting mountVolume")
+	log.V(4).Info("LUKS block volume opened", "luksSource", luksSource)
 	return nil
 }
 
@@ -336,7 +482,14 @@ func (ns *NodeServer) formatLUKSVolume(ctx context.Context, devicePath string, l
 		if luksSource, err = ns.encrypt.luksFormat(ctx, luksContext, devicePath); err != nil {
 			return "", errInternal("Failed to luks format (%q): %v", devicePath, err)
 		}
+		ns.backupLUKSHeader(ctx, luksContext.VolumeName, devicePath)
 	} else {
+		// Replace the key of the source of a clone staged with both keys
+		if luksContext.PreviousEncryptionKey != "" {
+			if err = ns.rekeyLUKSClone(ctx, devicePath, luksContext); err != nil {
+				return "", err
+			}
+		}
 		// If device is already formatted, perform a luks open and activation to use volume
 		if luksSource, err = ns.encrypt.luksOpen(ctx, luksContext, devicePath); err != nil {
 			return "", errInternal("Failed to luks open (%q): %v", devicePath, err)
@@ -4,7 +4,6 @@
 package driver
 
 import (
-	"context"
 	"fmt"
 	"testing"
 
@@ -38,13 +37,18 @@ func TestNodeServer_mountVolume_linux(t *testing.T) {
 				m.EXPECT().MountSensitive("/tmp/test_success_noluks", "", "ext4", []string{"defaults"}, emptyStringArray).Return(nil)
 			},
 			expectExecCalls: func(m *mocks.MockExecutor, c *mocks.MockCommand) {
-				// Mount_linux: Check disk format. Disk is not formatted.
+				// Check disk format, which is not probed again once it is formatted.
 				m.EXPECT().Command(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(c)
-				c.EXPECT().CombinedOutput().Return([]byte(""), exec.CodeExitError{Code: 2, Err: fmt.Errorf("not formatted")})
-
-				// Mount_linux: Format disk
 				m.EXPECT().Command("mkfs.ext4", "-F", "-m0", "/tmp/test_success_noluks").Return(c)
-				c.EXPECT().CombinedOutput().Return([]byte("Formatted successfully"), nil)
+				m.EXPECT().Command("fsck", "-a", "/tmp/test_success_noluks").Return(c)
+				gomock.InOrder(
+					// Disk is not formatted
+					c.EXPECT().CombinedOutput().Return([]byte(""), exec.CodeExitError{Code: 2, Err: fmt.Errorf("not formatted")}),
+					// Format disk
+					c.EXPECT().CombinedOutput().Return([]byte("Formatted successfully"), nil),
+					// Check the new file system
+					c.EXPECT().CombinedOutput().Return(nil, nil),
+				)
 			},
 			wantErr: false,
 		},
@@ -58,13 +62,18 @@ func TestNodeServer_mountVolume_linux(t *testing.T) {
 				m.EXPECT().MountSensitive("/tmp/test_error_noluks", "", "ext4", []string{"defaults"}, emptyStringArray).Return(fmt.Errorf("Couldn't mount."))
 			},
 			expectExecCalls: func(m *mocks.MockExecutor, c *mocks.MockCommand) {
-				// Mount_linux: Check disk format. Disk is not formatted.
+				// Check disk format, which is not probed again once it is formatted.
 				m.EXPECT().Command(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(c)
-				c.EXPECT().CombinedOutput().Return([]byte(""), exec.CodeExitError{Code: 2, Err: fmt.Errorf("not formatted")})
-
-				// Mount_linux: Format disk
 				m.EXPECT().Command("mkfs.ext4", "-F", "-m0", "/tmp/test_error_noluks").Return(c)
-				c.EXPECT().CombinedOutput().Return([]byte("Formatted successfully"), nil)
+				m.EXPECT().Command("fsck", "-a", "/tmp/test_error_noluks").Return(c)
+				gomock.InOrder(
+					// Disk is not formatted
+					c.EXPECT().CombinedOutput().Return([]byte(""), exec.CodeExitError{Code: 2, Err: fmt.Errorf("not formatted")}),
+					// Format disk
+					c.EXPECT().CombinedOutput().Return([]byte("Formatted successfully"), nil),
+					// Check the new file system
+					c.EXPECT().CombinedOutput().Return(nil, nil),
+				)
 			},
 			wantErr: true,
 		},
@@ -102,9 +111,87 @@ func TestNodeServer_mountVolume_linux(t *testing.T) {
 				},
 				encrypt: NewLuksEncryption(mockExec, mockFileSystem, mockCryptSetup),
 			}
-			if err := ns.mountVolume(context.Background(), tt.devicePath, tt.req); (err != nil) != tt.wantErr {
+			if err := mountVolume(ns, tt.devicePath, tt.req); (err != nil) != tt.wantErr {
 				t.Errorf("NodeServer.mountVolume() mountvolume error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
+
+func TestNodeServer_mountVolume_readOnly_linux(t *testing.T) {
+	tests := []struct {
+		name               string
+		accessMode         csi.VolumeCapability_AccessMode_Mode
+		mountFlags         []string
+		noRecovery         bool
+		blkidOutput        string
+		wantMountOptions   []string
+		wantMountAttempted bool
+		wantErr            bool
+	}{
+		{
+			name:               "ro mount flag",
+			accessMode:         csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+			mountFlags:         []string{"ro"},
+			blkidOutput:        "TYPE=ext4",
+			wantMountOptions:   []string{"ro", "defaults"},
+			wantMountAttempted: true,
+		},
+		{
+			name:               "Read-only access mode with norecovery",
+			accessMode:         csi.VolumeCapability_AccessMode_SINGLE_NODE_READER_ONLY,
+			noRecovery:         true,
+			blkidOutput:        "TYPE=ext4",
+			wantMountOptions:   []string{"ro", "norecovery", "defaults"},
+			wantMountAttempted: true,
+		},
+		{
+			name:       "Blank device",
+			accessMode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+			mountFlags: []string{"ro"},
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockMounter := mocks.NewMockMounter(ctrl)
+			mockExec := mocks.NewMockExecutor(ctrl)
+			mockCommand := mocks.NewMockCommand(ctrl)
+
+			// The disk format is checked once before mounting, which
+			// neither runs fsck nor mkfs
+			if tt.wantMountAttempted {
+				mockMounter.EXPECT().MountSensitive("/dev/sdb", "/staging", "ext4", tt.wantMountOptions, gomock.Any()).Return(nil)
+			}
+			mockExec.EXPECT().Command("blkid", "-p", "-s", "TYPE", "-s", "PTTYPE", "-o", "export", "/dev/sdb").Return(mockCommand)
+			if tt.blkidOutput == "" {
+				mockCommand.EXPECT().CombinedOutput().Return(nil, exec.CodeExitError{Code: 2, Err: fmt.Errorf("not formatted")})
+			} else {
+				mockCommand.EXPECT().CombinedOutput().Return([]byte(tt.blkidOutput), nil)
+			}
+
+			ns := &NodeServer{
+				driver: &LinodeDriver{opts: Options{ReadOnlyNoRecovery: tt.noRecovery}},
+				mounter: &mount.SafeFormatAndMount{
+					Interface: mockMounter,
+					Exec:      mockExec,
+				},
+			}
+			req := &csi.NodeStageVolumeRequest{
+				VolumeId:          "1001-test",
+				StagingTargetPath: "/staging",
+				VolumeCapability: &csi.VolumeCapability{
+					AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{MountFlags: tt.mountFlags}},
+					AccessMode: &csi.VolumeCapability_AccessMode{Mode: tt.accessMode},
+				},
+			}
+			if err := mountVolume(ns, "/dev/sdb", req); (err != nil) != tt.wantErr {
+				t.Errorf("mountVolume() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
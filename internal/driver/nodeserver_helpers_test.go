@@ -8,11 +8,13 @@ import (
 	"testing"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/linode/linodego"
 	"go.uber.org/mock/gomock"
 	"google.golang.org/grpc/status"
 	"k8s.io/mount-utils"
 
 	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+	linodeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-client"
 	linodevolumes "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-volumes"
 )
 
@@ -151,10 +153,13 @@ func Test_validateNodeUnstageVolumeRequest(t *testing.T) {
 
 func Test_getFSTypeAndMountOptions(t *testing.T) {
 	tests := []struct {
-		name             string
-		volumeCapability *csi.VolumeCapability
-		wantFsType       string
-		wantMountOptions []string
+		name               string
+		volumeCapability   *csi.VolumeCapability
+		volumeContext      map[string]string
+		driverFSType       string
+		driverMountOptions []string
+		wantFsType         string
+		wantMountOptions   []string
 	}{
 		{
 			name:             "Valid request - no volume capability set",
@@ -162,6 +167,35 @@ func Test_getFSTypeAndMountOptions(t *testing.T) {
 			wantFsType:       "ext4",
 			wantMountOptions: []string(nil),
 		},
+		{
+			name:             "Valid request - driver default file system type",
+			volumeCapability: nil,
+			driverFSType:     "ext3",
+			wantFsType:       "ext3",
+			wantMountOptions: []string(nil),
+		},
+		{
+			name:             "Valid request - storage class file system type overrides driver default",
+			volumeCapability: &csi.VolumeCapability{AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}}},
+			volumeContext:    map[string]string{FilesystemTypeAttribute: "xfs"},
+			driverFSType:     "ext3",
+			wantFsType:       "xfs",
+			wantMountOptions: []string{"nouuid"},
+		},
+		{
+			name: "Valid request - volume capability file system type overrides storage class",
+			volumeCapability: &csi.VolumeCapability{
+				AccessType: &csi.VolumeCapability_Mount{
+					Mount: &csi.VolumeCapability_MountVolume{
+						FsType: "ext4",
+					},
+				},
+			},
+			volumeContext:    map[string]string{FilesystemTypeAttribute: "xfs"},
+			driverFSType:     "ext3",
+			wantFsType:       "ext4",
+			wantMountOptions: []string(nil),
+		},
 		{
 			name: "Valid request - volume capability set",
 			volumeCapability: &csi.VolumeCapability{
@@ -179,10 +213,31 @@ func Test_getFSTypeAndMountOptions(t *testing.T) {
 				"noatime",
 			},
 		},
+		{
+			name:               "Valid request - driver default mount options",
+			volumeCapability:   &csi.VolumeCapability{AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}}},
+			driverMountOptions: []string{"noatime", "discard"},
+			wantFsType:         "ext4",
+			wantMountOptions:   []string{"noatime", "discard"},
+		},
+		{
+			name: "Valid request - driver default mount options merged with volume capability",
+			volumeCapability: &csi.VolumeCapability{
+				AccessType: &csi.VolumeCapability_Mount{
+					Mount: &csi.VolumeCapability_MountVolume{
+						FsType:     "xfs",
+						MountFlags: []string{"discard", "nodiratime", "nouuid"},
+					},
+				},
+			},
+			driverMountOptions: []string{"noatime", "discard"},
+			wantFsType:         "xfs",
+			wantMountOptions:   []string{"noatime", "discard", "nodiratime", "nouuid"},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			fsType, mountOptions := getFSTypeAndMountOptions(context.Background(), tt.volumeCapability)
+			fsType, mountOptions := getFSTypeAndMountOptions(context.Background(), tt.volumeCapability, tt.volumeContext, tt.driverFSType, tt.driverMountOptions)
 			if fsType != tt.wantFsType {
 				t.Errorf("getFSTypeAndMountOptions() fsType = %v, want %v", fsType, tt.wantFsType)
 			}
@@ -198,6 +253,7 @@ func TestNodeServer_findDevicePath(t *testing.T) {
 		name           string
 		key            linodevolumes.LinodeVolumeKey
 		expects        func(dUtils *mocks.MockDeviceUtils)
+		clientExpects  func(m *mocks.MockLinodeClient)
 		wantDevicePath string
 		wantErr        error
 	}{
@@ -240,6 +296,42 @@ func TestNodeServer_findDevicePath(t *testing.T) {
 			wantDevicePath: "/dev/test",
 			wantErr:        nil,
 		},
+		{
+			name: "Success - Renamed volume",
+			key: linodevolumes.LinodeVolumeKey{
+				VolumeID: 123,
+				Label:    "test",
+			},
+			expects: func(dUtils *mocks.MockDeviceUtils) {
+				gomock.InOrder(
+					dUtils.EXPECT().GetDiskByIdPaths("test", "test").Return([]string{"/dev/disk/by-id/scsi-0Linode_Volume_test"}),
+					dUtils.EXPECT().VerifyDevicePath([]string{"/dev/disk/by-id/scsi-0Linode_Volume_test"}).Return("", nil),
+					dUtils.EXPECT().GetDiskByIdPaths("restored", "test").Return([]string{"/dev/disk/by-id/scsi-0Linode_Volume_restored"}),
+					dUtils.EXPECT().VerifyDevicePath([]string{"/dev/disk/by-id/scsi-0Linode_Volume_test", "/dev/disk/by-id/scsi-0Linode_Volume_restored"}).Return("/dev/sdb", nil),
+				)
+			},
+			clientExpects: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 123).Return(&linodego.Volume{ID: 123, Label: "restored"}, nil)
+			},
+			wantDevicePath: "/dev/sdb",
+			wantErr:        nil,
+		},
+		{
+			name: "Error - Volume not renamed",
+			key: linodevolumes.LinodeVolumeKey{
+				VolumeID: 123,
+				Label:    "test",
+			},
+			expects: func(dUtils *mocks.MockDeviceUtils) {
+				dUtils.EXPECT().GetDiskByIdPaths(gomock.Any(), gomock.Any()).Return([]string{"some/path"})
+				dUtils.EXPECT().VerifyDevicePath(gomock.Any()).Return("", nil)
+			},
+			clientExpects: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().GetVolume(gomock.Any(), 123).Return(&linodego.Volume{ID: 123, Label: "test"}, nil)
+			},
+			wantDevicePath: "",
+			wantErr:        errInternal("Unable to find device path out of attempted paths: [some/path]"),
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -255,13 +347,20 @@ func TestNodeServer_findDevicePath(t *testing.T) {
 				tt.expects(mockDeviceUtils)
 			}
 
+			var client linodeclient.LinodeClient
+			if tt.clientExpects != nil {
+				mockClient := mocks.NewMockLinodeClient(ctrl)
+				tt.clientExpects(mockClient)
+				client = mockClient
+			}
+
 			// Create a new NodeServer with the mocked DeviceUtils
 			// No need to set other fields as the function we are testing doesn't use them
 			ns := &NodeServer{
 				driver:      nil,
 				mounter:     nil,
 				deviceutils: mockDeviceUtils,
-				client:      nil,
+				client:      client,
 				metadata:    Metadata{},
 			}
 
@@ -534,6 +633,7 @@ func TestNodeServer_nodePublishVolumeBlock(t *testing.T) {
 		expectFsCalls      func(m *mocks.MockFileSystem, f *mocks.MockFileInterface)
 		expectMounterCalls func(m *mocks.MockMounter)
 		expectFileCalls    func(m *mocks.MockFileInterface)
+		sameDevice         bool
 		want               *csi.NodePublishVolumeResponse
 		wantErr            bool
 	}{
@@ -554,6 +654,7 @@ func TestNodeServer_nodePublishVolumeBlock(t *testing.T) {
 				m.EXPECT().OpenFile("/mnt/target", os.O_CREATE, ownerGroupReadWritePermissions).Return(f, nil)
 			},
 			expectMounterCalls: func(m *mocks.MockMounter) {
+				m.EXPECT().IsLikelyNotMountPoint("/mnt/target").Return(true, os.ErrNotExist)
 				m.EXPECT().Mount("/dev/sda", "/mnt/target", "", []string{"bind"}).Return(nil)
 			},
 			expectFileCalls: func(m *mocks.MockFileInterface) {
@@ -562,6 +663,36 @@ func TestNodeServer_nodePublishVolumeBlock(t *testing.T) {
 			want:    &csi.NodePublishVolumeResponse{},
 			wantErr: false,
 		},
+		{
+			name: "Valid request - LUKS encrypted",
+			req: &csi.NodePublishVolumeRequest{
+				VolumeId:          "vol-123",
+				StagingTargetPath: "/mnt/staging",
+				TargetPath:        "/mnt/target",
+				PublishContext: map[string]string{
+					"devicePath": "/dev/sda",
+				},
+				VolumeContext: map[string]string{
+					LuksEncryptedAttribute: "true",
+					PublishInfoVolumeName:  "pvc-123",
+				},
+				VolumeCapability: &csi.VolumeCapability{},
+			},
+			mountOptions: []string{"bind"},
+			expectFsCalls: func(m *mocks.MockFileSystem, f *mocks.MockFileInterface) {
+				m.EXPECT().MkdirAll("/mnt", rwPermission).Return(nil)
+				m.EXPECT().OpenFile("/mnt/target", os.O_CREATE, ownerGroupReadWritePermissions).Return(f, nil)
+			},
+			expectMounterCalls: func(m *mocks.MockMounter) {
+				m.EXPECT().IsLikelyNotMountPoint("/mnt/target").Return(true, os.ErrNotExist)
+				m.EXPECT().Mount("/dev/mapper/pvc-123", "/mnt/target", "", []string{"bind"}).Return(nil)
+			},
+			expectFileCalls: func(m *mocks.MockFileInterface) {
+				m.EXPECT().Close().Return(nil)
+			},
+			want:    &csi.NodePublishVolumeResponse{},
+			wantErr: false,
+		},
 		{
 			name: "Error - devicePath missing",
 			req: &csi.NodePublishVolumeRequest{
@@ -595,10 +726,12 @@ func TestNodeServer_nodePublishVolumeBlock(t *testing.T) {
 			expectFsCalls: func(m *mocks.MockFileSystem, f *mocks.MockFileInterface) {
 				m.EXPECT().MkdirAll("/mnt", rwPermission).Return(fmt.Errorf("unable to create targetPathDir..."))
 			},
-			expectMounterCalls: nil,
-			expectFileCalls:    nil,
-			want:               nil,
-			wantErr:            true,
+			expectMounterCalls: func(m *mocks.MockMounter) {
+				m.EXPECT().IsLikelyNotMountPoint("/mnt/target").Return(true, os.ErrNotExist)
+			},
+			expectFileCalls: nil,
+			want:            nil,
+			wantErr:         true,
 		},
 		{
 			name: "Error - unable to create file at targetPath",
@@ -617,10 +750,12 @@ func TestNodeServer_nodePublishVolumeBlock(t *testing.T) {
 				m.EXPECT().OpenFile("/mnt/target", os.O_CREATE, ownerGroupReadWritePermissions).Return(nil, fmt.Errorf("unable to create file..."))
 				m.EXPECT().Remove("/mnt/target").Return(nil)
 			},
-			expectMounterCalls: nil,
-			expectFileCalls:    nil,
-			want:               nil,
-			wantErr:            true,
+			expectMounterCalls: func(m *mocks.MockMounter) {
+				m.EXPECT().IsLikelyNotMountPoint("/mnt/target").Return(true, os.ErrNotExist)
+			},
+			expectFileCalls: nil,
+			want:            nil,
+			wantErr:         true,
 		},
 		{
 			name: "Error - unable to create file at targetPath and remove targetPath fails",
@@ -639,10 +774,12 @@ func TestNodeServer_nodePublishVolumeBlock(t *testing.T) {
 				m.EXPECT().OpenFile("/mnt/target", os.O_CREATE, ownerGroupReadWritePermissions).Return(nil, fmt.Errorf("unable to create file..."))
 				m.EXPECT().Remove("/mnt/target").Return(fmt.Errorf("unable to remove %s...", "/mnt/target"))
 			},
-			expectMounterCalls: nil,
-			expectFileCalls:    nil,
-			want:               nil,
-			wantErr:            true,
+			expectMounterCalls: func(m *mocks.MockMounter) {
+				m.EXPECT().IsLikelyNotMountPoint("/mnt/target").Return(true, os.ErrNotExist)
+			},
+			expectFileCalls: nil,
+			want:            nil,
+			wantErr:         true,
 		},
 		{
 			name: "Error - unable to mount the block device to targetPath",
@@ -662,6 +799,7 @@ func TestNodeServer_nodePublishVolumeBlock(t *testing.T) {
 				m.EXPECT().Remove("/mnt/target").Return(nil)
 			},
 			expectMounterCalls: func(m *mocks.MockMounter) {
+				m.EXPECT().IsLikelyNotMountPoint("/mnt/target").Return(true, os.ErrNotExist)
 				m.EXPECT().Mount("/dev/sda", "/mnt/target", "", []string{"bind"}).Return(fmt.Errorf("unable to mount..."))
 			},
 			expectFileCalls: func(f *mocks.MockFileInterface) {
@@ -688,6 +826,7 @@ func TestNodeServer_nodePublishVolumeBlock(t *testing.T) {
 				m.EXPECT().Remove("/mnt/target").Return(fmt.Errorf("unable to remove %s...", "/mnt/target"))
 			},
 			expectMounterCalls: func(m *mocks.MockMounter) {
+				m.EXPECT().IsLikelyNotMountPoint("/mnt/target").Return(true, os.ErrNotExist)
 				m.EXPECT().Mount("/dev/sda", "/mnt/target", "", []string{"bind"}).Return(fmt.Errorf("unable to mount the block device at %s...", "/mnt/target"))
 			},
 			expectFileCalls: func(f *mocks.MockFileInterface) {
@@ -696,7 +835,65 @@ func TestNodeServer_nodePublishVolumeBlock(t *testing.T) {
 			want:    nil,
 			wantErr: true,
 		},
+		{
+			name: "Already published",
+			req: &csi.NodePublishVolumeRequest{
+				VolumeId:          "vol-123",
+				StagingTargetPath: "/mnt/staging",
+				TargetPath:        "/mnt/target",
+				PublishContext: map[string]string{
+					"devicePath": "/dev/sda",
+				},
+				VolumeCapability: &csi.VolumeCapability{},
+			},
+			mountOptions: []string{"bind"},
+			expectMounterCalls: func(m *mocks.MockMounter) {
+				m.EXPECT().IsLikelyNotMountPoint("/mnt/target").Return(false, nil)
+			},
+			sameDevice: true,
+			want:       &csi.NodePublishVolumeResponse{},
+			wantErr:    false,
+		},
+		{
+			name: "Error - another device is published",
+			req: &csi.NodePublishVolumeRequest{
+				VolumeId:          "vol-123",
+				StagingTargetPath: "/mnt/staging",
+				TargetPath:        "/mnt/target",
+				PublishContext: map[string]string{
+					"devicePath": "/dev/sda",
+				},
+				VolumeCapability: &csi.VolumeCapability{},
+			},
+			mountOptions: []string{"bind"},
+			expectMounterCalls: func(m *mocks.MockMounter) {
+				m.EXPECT().IsLikelyNotMountPoint("/mnt/target").Return(false, nil)
+			},
+			sameDevice: false,
+			want:       nil,
+			wantErr:    true,
+		},
+		{
+			name: "Error - unable to check the target path",
+			req: &csi.NodePublishVolumeRequest{
+				VolumeId:          "vol-123",
+				StagingTargetPath: "/mnt/staging",
+				TargetPath:        "/mnt/target",
+				PublishContext: map[string]string{
+					"devicePath": "/dev/sda",
+				},
+				VolumeCapability: &csi.VolumeCapability{},
+			},
+			mountOptions: []string{"bind"},
+			expectMounterCalls: func(m *mocks.MockMounter) {
+				m.EXPECT().IsLikelyNotMountPoint("/mnt/target").Return(true, fmt.Errorf("permission denied"))
+			},
+			want:    nil,
+			wantErr: true,
+		},
 	}
+	defaultSameBlockDevice := sameBlockDevice
+	defer func() { sameBlockDevice = defaultSameBlockDevice }()
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			ctrl := gomock.NewController(t)
@@ -716,6 +913,13 @@ func TestNodeServer_nodePublishVolumeBlock(t *testing.T) {
 				tt.expectMounterCalls(mockMounter)
 			}
 
+			sameBlockDevice = func(devicePath, targetPath string) (bool, error) {
+				if devicePath != "/dev/sda" || targetPath != "/mnt/target" {
+					t.Errorf("sameBlockDevice(%q, %q) called for another device or target", devicePath, targetPath)
+				}
+				return tt.sameDevice, nil
+			}
+
 			ns := &NodeServer{
 				mounter: &mount.SafeFormatAndMount{
 					Interface: mockMounter,
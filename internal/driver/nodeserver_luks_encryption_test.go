@@ -258,7 +258,7 @@ func TestNodeServer_mountVolume_luks(t *testing.T) {
 				},
 				encrypt: NewLuksEncryption(mockExec, mockFileSystem, mockCryptSetupClient),
 			}
-			if err := ns.mountVolume(context.Background(), tt.devicePath, tt.req); (err != nil) != tt.wantErr {
+			if err := mountVolume(ns, tt.devicePath, tt.req); (err != nil) != tt.wantErr {
 				t.Errorf("NodeServer.mountVolume() mountvolume error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -336,6 +336,8 @@ func TestNodeServer_closeLuksMountSource(t *testing.T) {
 			},
 			expectCryptDeviceCalls: func(m *mocks.MockDevice) {
 				m.EXPECT().Deactivate(gomock.Any()).Return(fmt.Errorf("failed to deactivate")).AnyTimes()
+				// The device, and its lock, are released anyway
+				m.EXPECT().Free().Return(true)
 			},
 			volumeID: "3232-pvc1234",
 			wantErr:  true,
@@ -446,3 +448,116 @@ func TestNodeServer_formatLUKSVolume(t *testing.T) {
 		})
 	}
 }
+
+func TestNodeServer_openLUKSBlockVolume(t *testing.T) {
+	luksVolumeContext := map[string]string{
+		LuksEncryptedAttribute: "true",
+		LuksCipherAttribute:    "aes-xts-plain64",
+		LuksKeySizeAttribute:   "512",
+		PublishInfoVolumeName:  "test",
+	}
+
+	tests := []struct {
+		name                   string
+		req                    *csi.NodeStageVolumeRequest
+		selfTest               *nodeSelfTest
+		expectExecCalls        func(m *mocks.MockExecutor, c *mocks.MockCommand)
+		expectCryptDeviceCalls func(m *mocks.MockDevice)
+		expectCryptSetUpCalls  func(mc *mocks.MockCryptSetupClient, md *mocks.MockDevice)
+		wantErr                bool
+	}{
+		{
+			name: "Success - not encrypted",
+			req: &csi.NodeStageVolumeRequest{
+				VolumeId:      "test",
+				VolumeContext: map[string]string{},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success - format and open LUKS volume",
+			req: &csi.NodeStageVolumeRequest{
+				VolumeId:      "test",
+				VolumeContext: luksVolumeContext,
+				Secrets:       map[string]string{LuksKeyAttribute: "test"},
+			},
+			expectExecCalls: func(m *mocks.MockExecutor, c *mocks.MockCommand) {
+				m.EXPECT().LookPath("blkid").Return("/bin/blkid", nil)
+				m.EXPECT().Command("blkid", "/tmp/test").Return(c)
+				c.EXPECT().Run().Return(exec.CodeExitError{Code: 2, Err: fmt.Errorf("test")})
+			},
+			expectCryptSetUpCalls: func(mc *mocks.MockCryptSetupClient, md *mocks.MockDevice) {
+				mc.EXPECT().Init("/tmp/test").Return(md, nil)
+			},
+			expectCryptDeviceCalls: func(m *mocks.MockDevice) {
+				m.EXPECT().Format(gomock.Any(), gomock.Any()).Return(nil)
+				m.EXPECT().KeyslotAddByVolumeKey(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				m.EXPECT().ActivateByPassphrase("test", gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				m.EXPECT().Free().Return(true)
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success - open formatted LUKS volume",
+			req: &csi.NodeStageVolumeRequest{
+				VolumeId:      "test",
+				VolumeContext: luksVolumeContext,
+				Secrets:       map[string]string{LuksKeyAttribute: "test"},
+			},
+			expectExecCalls: func(m *mocks.MockExecutor, c *mocks.MockCommand) {
+				m.EXPECT().LookPath("blkid").Return("/bin/blkid", nil)
+				m.EXPECT().Command("blkid", "/tmp/test").Return(c)
+				c.EXPECT().Run().Return(nil)
+			},
+			expectCryptSetUpCalls: func(mc *mocks.MockCryptSetupClient, md *mocks.MockDevice) {
+				mc.EXPECT().Init("/tmp/test").Return(md, nil)
+			},
+			expectCryptDeviceCalls: func(m *mocks.MockDevice) {
+				m.EXPECT().Load(gomock.Any()).Return(nil)
+				m.EXPECT().ActivateByPassphrase("test", gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				m.EXPECT().Free().Return(true)
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error - dm_crypt missing",
+			req: &csi.NodeStageVolumeRequest{
+				VolumeId:      "test",
+				VolumeContext: luksVolumeContext,
+				Secrets:       map[string]string{LuksKeyAttribute: "test"},
+			},
+			selfTest: &nodeSelfTest{available: map[string]bool{"blkid": true}},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockFileSystem := mocks.NewMockFileSystem(ctrl)
+			mockExec := mocks.NewMockExecutor(ctrl)
+			mockCommand := mocks.NewMockCommand(ctrl)
+			mockDevice := mocks.NewMockDevice(ctrl)
+			mockCryptSetupClient := mocks.NewMockCryptSetupClient(ctrl)
+
+			if tt.expectExecCalls != nil {
+				tt.expectExecCalls(mockExec, mockCommand)
+			}
+			if tt.expectCryptSetUpCalls != nil {
+				tt.expectCryptSetUpCalls(mockCryptSetupClient, mockDevice)
+			}
+			if tt.expectCryptDeviceCalls != nil {
+				tt.expectCryptDeviceCalls(mockDevice)
+			}
+
+			ns := &NodeServer{
+				encrypt:  NewLuksEncryption(mockExec, mockFileSystem, mockCryptSetupClient),
+				selfTest: tt.selfTest,
+			}
+			if err := ns.openLUKSBlockVolume(context.Background(), "/tmp/test", tt.req); (err != nil) != tt.wantErr {
+				t.Errorf("NodeServer.openLUKSBlockVolume() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -146,7 +146,6 @@ func TestNodeStageVolume(t *testing.T) {
 			expectedError: nil,
 			expectFSCalls: func(m *mocks.MockFileSystem) {
 				m.EXPECT().Glob("/dev/sd*").Return([]string{"/dev/sda", "/dev/sdb"}, nil).AnyTimes()
-				m.EXPECT().Stat("/dev/disk/by-id/linode-stagehappypath").Return(nil, nil)
 			},
 		},
 	}
@@ -2,6 +2,7 @@ package driver
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
@@ -9,6 +10,34 @@ import (
 	"google.golang.org/grpc/status"
 )
 
+// volumeStatsSupported is false since NodeGetVolumeStats is not implemented
+// on Windows, so the node does not advertise the GET_VOLUME_STATS and
+// VOLUME_CONDITION capabilities.
+const volumeStatsSupported = false
+
+// sameBlockDevice is not implemented on Windows, which has no block volumes.
+var sameBlockDevice = func(string, string) (bool, error) {
+	return false, errors.New("block devices are not supported on Windows")
+}
+
+// blockDeviceSize is not implemented on Windows, which has no block
+// volumes.
+var blockDeviceSize = func(string) (int64, error) {
+	return 0, errors.New("block devices are not supported on Windows")
+}
+
+// deviceNumber is not implemented on Windows, which has no cgroups to
+// throttle devices with.
+var deviceNumber = func(string) (string, error) {
+	return "", errors.New("device numbers are not supported on Windows")
+}
+
 func nodeGetVolumeStats(ctx context.Context, req *csi.NodeGetVolumeStatsRequest) (*csi.NodeGetVolumeStatsResponse, error) {
 	return nil, status.Error(codes.Unimplemented, fmt.Sprintf("NodeGetVolumeStats is not yet implemented on Windows"))
 }
+
+// checkMountPropagation does nothing on Windows, which has no mount
+// propagation.
+func checkMountPropagation(context.Context, string) error {
+	return nil
+}
@@ -0,0 +1,201 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/mount-utils"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+// OrphanCleanupMode is what the node plugin does at startup with the staging
+// mounts and LUKS mappings of the driver whose volumes are no longer
+// attached to the node, as left behind when the node crashed. They keep the
+// devices busy, and make staging the volumes again fail with EBUSY.
+type OrphanCleanupMode string
+
+const (
+	// OrphanCleanupOff does not look for orphans. It is the default.
+	OrphanCleanupOff OrphanCleanupMode = "off"
+
+	// OrphanCleanupReport logs the orphans and counts them in the
+	// csi_node_orphans_total metric, without changing them.
+	OrphanCleanupReport OrphanCleanupMode = "report"
+
+	// OrphanCleanupFix unmounts the orphaned staging mounts and closes the
+	// orphaned LUKS mappings.
+	OrphanCleanupFix OrphanCleanupMode = "fix"
+)
+
+// ParseOrphanCleanupMode parses an orphan cleanup mode. The empty string is
+// [OrphanCleanupOff].
+func ParseOrphanCleanupMode(s string) (OrphanCleanupMode, error) {
+	switch mode := OrphanCleanupMode(s); mode {
+	case "":
+		return OrphanCleanupOff, nil
+	case OrphanCleanupOff, OrphanCleanupReport, OrphanCleanupFix:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid orphan cleanup mode %q, must be %q, %q or %q", s, OrphanCleanupOff, OrphanCleanupReport, OrphanCleanupFix)
+	}
+}
+
+const (
+	// kubeletCSIPluginsDir is the directory of the kubelet, relative to its
+	// root directory, holding the staging target paths of the volumes of
+	// each CSI driver, in a directory named after the driver.
+	kubeletCSIPluginsDir = "/plugins/kubernetes.io/csi/"
+
+	// stagingTargetBase is the base name of the staging target paths.
+	stagingTargetBase = "globalmount"
+
+	// provisionedVolumeNamePrefix starts the names of the volumes created
+	// by the external provisioner, which are the names of their LUKS
+	// mappings.
+	provisionedVolumeNamePrefix = "pvc-"
+
+	// sysBlockDir has a directory per block device, listing the devices a
+	// device-mapper device is built on in its slaves directory.
+	sysBlockDir = "/sys/block/"
+)
+
+// Kinds of orphans, used as the "kind" label of the csi_node_orphans_total
+// metric.
+const (
+	orphanStagingMount = "staging_mount"
+	orphanLUKSMapping  = "luks_mapping"
+)
+
+// cleanupOrphans looks for the staging mounts and LUKS mappings of the
+// driver whose devices are not Linode volumes attached to the node, and
+// reports or cleans them up according to mode.
+//
+// Staging mounts are recognized by their path in the directory of the
+// driver in the kubelet plugins directory, and LUKS mappings by their name,
+// the name of a volume created by the external provisioner.
+func (ns *NodeServer) cleanupOrphans(ctx context.Context, mode OrphanCleanupMode) {
+	log := logger.GetLogger(ctx)
+	log.V(4).Info("Entering cleanupOrphans()", "mode", mode)
+	defer log.V(4).Info("Exiting cleanupOrphans()")
+
+	if mode != OrphanCleanupReport && mode != OrphanCleanupFix {
+		return
+	}
+
+	attached, err := ns.attachedVolumeDevices()
+	if err != nil {
+		log.Error(err, "Failed to list the attached volumes, not looking for orphans")
+		return
+	}
+
+	mounts, err := ns.mounter.List()
+	if err != nil {
+		log.Error(err, "Failed to list mounts, not looking for orphaned staging mounts")
+	}
+	for _, mp := range mounts {
+		if !ns.isStagingTargetPath(mp.Path) || ns.backedByAttachedVolume(mp.Device, attached) {
+			continue
+		}
+		ns.handleOrphan(ctx, mode, orphanStagingMount, mp.Path, func() error {
+			if err := mount.CleanupMountPoint(mp.Path, ns.mounter.Interface, true /* bind mount */); err != nil {
+				return err
+			}
+			newStageMarker(mp.Path, ns.encrypt.FileSystem).remove(ctx)
+			return nil
+		})
+	}
+
+	mappings, err := ns.encrypt.FileSystem.Glob(luksDevicePath(provisionedVolumeNamePrefix + "*"))
+	if err != nil {
+		log.Error(err, "Failed to list LUKS mappings, not looking for orphaned mappings")
+	}
+	for _, mapping := range mappings {
+		if ns.backedByAttachedVolume(mapping, attached) {
+			continue
+		}
+		ns.handleOrphan(ctx, mode, orphanLUKSMapping, mapping, func() error {
+			return ns.encrypt.luksClose(ctx, filepath.Base(mapping))
+		})
+	}
+}
+
+// attachedVolumeDevices returns the resolved paths of the devices, and
+// partitions, of the Linode volumes attached to the node.
+func (ns *NodeServer) attachedVolumeDevices() (map[string]bool, error) {
+	fs := ns.encrypt.FileSystem
+	attached := make(map[string]bool)
+	for _, pattern := range ns.deviceutils.GetDiskByIdPaths("*", "") {
+		links, err := fs.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, link := range links {
+			if resolved, err := fs.EvalSymlinks(link); err == nil {
+				attached[resolved] = true
+			}
+		}
+	}
+	return attached, nil
+}
+
+// isStagingTargetPath reports whether path is a staging target path of a
+// volume of the driver.
+func (ns *NodeServer) isStagingTargetPath(path string) bool {
+	if ns.driver == nil || ns.driver.name == "" {
+		return false
+	}
+	return strings.Contains(path, kubeletCSIPluginsDir+ns.driver.name+"/") && filepath.Base(path) == stagingTargetBase
+}
+
+// backedByAttachedVolume reports whether device is one of the attached
+// devices, or a device-mapper device built on one of them. Devices that
+// cannot be resolved for another reason than not existing are reported as
+// attached, so that they are left alone.
+func (ns *NodeServer) backedByAttachedVolume(device string, attached map[string]bool) bool {
+	fs := ns.encrypt.FileSystem
+	resolved, err := fs.EvalSymlinks(device)
+	if err != nil {
+		return !fs.IsNotExist(err)
+	}
+	if attached[resolved] {
+		return true
+	}
+
+	name := filepath.Base(resolved)
+	if !strings.HasPrefix(name, "dm-") {
+		return false
+	}
+	slaves, err := fs.Glob(sysBlockDir + name + "/slaves/*")
+	if err != nil {
+		return true
+	}
+	for _, slave := range slaves {
+		if attached["/dev/"+filepath.Base(slave)] {
+			return true
+		}
+	}
+	return false
+}
+
+// handleOrphan reports the orphan of kind at path, and cleans it up with
+// clean in [OrphanCleanupFix] mode.
+func (ns *NodeServer) handleOrphan(ctx context.Context, mode OrphanCleanupMode, kind, path string, clean func() error) {
+	log := logger.GetLogger(ctx)
+
+	if mode != OrphanCleanupFix {
+		observability.NodeOrphansTotal.WithLabelValues(kind, "reported").Inc()
+		log.V(0).Info("Found orphan, not cleaned up in report mode", "kind", kind, "path", path)
+		return
+	}
+	if err := clean(); err != nil {
+		observability.NodeOrphansTotal.WithLabelValues(kind, "failed").Inc()
+		log.Error(err, "Failed to clean up orphan", "kind", kind, "path", path)
+		return
+	}
+	observability.NodeOrphansTotal.WithLabelValues(kind, "cleaned").Inc()
+	log.V(2).Info("Cleaned up orphan", "kind", kind, "path", path)
+}
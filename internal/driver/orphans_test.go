@@ -0,0 +1,144 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/mock/gomock"
+	"k8s.io/mount-utils"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+func TestParseOrphanCleanupMode(t *testing.T) {
+	for s, want := range map[string]OrphanCleanupMode{
+		"":       OrphanCleanupOff,
+		"off":    OrphanCleanupOff,
+		"report": OrphanCleanupReport,
+		"fix":    OrphanCleanupFix,
+	} {
+		if got, err := ParseOrphanCleanupMode(s); err != nil || got != want {
+			t.Errorf("ParseOrphanCleanupMode(%q) = %q, %v, want %q", s, got, err, want)
+		}
+	}
+	if _, err := ParseOrphanCleanupMode("clean"); err == nil {
+		t.Error("ParseOrphanCleanupMode(\"clean\") succeeded, want error")
+	}
+}
+
+func TestNodeServer_cleanupOrphans(t *testing.T) {
+	const stagingDir = "/var/lib/kubelet/plugins/kubernetes.io/csi/" + Name + "/"
+
+	tests := []struct {
+		name        string
+		mode        OrphanCleanupMode
+		wantResult  string
+		expectClean func(*mocks.MockFileSystem, *mocks.MockCryptSetupClient, *gomock.Controller)
+	}{
+		{
+			name:       "Report",
+			mode:       OrphanCleanupReport,
+			wantResult: "reported",
+		},
+		{
+			name:       "Fix",
+			mode:       OrphanCleanupFix,
+			wantResult: "cleaned",
+			expectClean: func(fs *mocks.MockFileSystem, crypt *mocks.MockCryptSetupClient, ctrl *gomock.Controller) {
+				fs.EXPECT().Remove(stagingDir + "detached/.globalmount.stage").Return(nil)
+				device := mocks.NewMockDevice(ctrl)
+				crypt.EXPECT().InitByName("pvc-detached").Return(device, nil)
+				device.EXPECT().Deactivate("pvc-detached").Return(nil)
+				device.EXPECT().Free().Return(true)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mounter := mocks.NewMockMounter(ctrl)
+			deviceUtils := mocks.NewMockDeviceUtils(ctrl)
+			fs := mocks.NewMockFileSystem(ctrl)
+			crypt := mocks.NewMockCryptSetupClient(ctrl)
+
+			// sda is attached, with the LUKS mapping of pvc-attached on it
+			deviceUtils.EXPECT().GetDiskByIdPaths("*", "").Return([]string{"/dev/disk/by-id/linode-*", "/dev/disk/by-id/scsi-0Linode_Volume_*"})
+			fs.EXPECT().Glob("/dev/disk/by-id/linode-*").Return(nil, nil)
+			fs.EXPECT().Glob("/dev/disk/by-id/scsi-0Linode_Volume_*").Return([]string{"/dev/disk/by-id/scsi-0Linode_Volume_attached"}, nil)
+			fs.EXPECT().IsNotExist(gomock.Any()).DoAndReturn(os.IsNotExist).AnyTimes()
+			fs.EXPECT().EvalSymlinks("/dev/disk/by-id/scsi-0Linode_Volume_attached").Return("/dev/sda", nil)
+			fs.EXPECT().EvalSymlinks("/dev/sda").Return("/dev/sda", nil)
+			fs.EXPECT().EvalSymlinks("/dev/sdb").Return("", os.ErrNotExist)
+			fs.EXPECT().EvalSymlinks("/dev/mapper/pvc-attached").Return("/dev/dm-0", nil).Times(2)
+			fs.EXPECT().Glob("/sys/block/dm-0/slaves/*").Return([]string{"/sys/block/dm-0/slaves/sda"}, nil).Times(2)
+			fs.EXPECT().EvalSymlinks("/dev/mapper/pvc-detached").Return("/dev/dm-1", nil)
+			fs.EXPECT().Glob("/sys/block/dm-1/slaves/*").Return(nil, nil)
+
+			mounter.EXPECT().List().Return([]mount.MountPoint{
+				{Device: "/dev/sda", Path: stagingDir + "attached/globalmount"},
+				{Device: "/dev/mapper/pvc-attached", Path: stagingDir + "encrypted/globalmount"},
+				{Device: "/dev/sdb", Path: stagingDir + "detached/globalmount"},
+				{Device: "/dev/sdc", Path: "/var/lib/kubelet/plugins/kubernetes.io/csi/other.csi.example.com/other/globalmount"},
+			}, nil)
+			fs.EXPECT().Glob("/dev/mapper/pvc-*").Return([]string{"/dev/mapper/pvc-attached", "/dev/mapper/pvc-detached"}, nil)
+
+			if tt.expectClean != nil {
+				tt.expectClean(fs, crypt, ctrl)
+			}
+
+			ns := &NodeServer{
+				driver:      &LinodeDriver{name: Name},
+				mounter:     &mount.SafeFormatAndMount{Interface: mounter},
+				deviceutils: deviceUtils,
+				encrypt:     Encryption{FileSystem: fs, CryptSetup: crypt},
+			}
+
+			mounts := observability.NodeOrphansTotal.WithLabelValues(orphanStagingMount, tt.wantResult)
+			mappings := observability.NodeOrphansTotal.WithLabelValues(orphanLUKSMapping, tt.wantResult)
+			mountsBefore, mappingsBefore := testutil.ToFloat64(mounts), testutil.ToFloat64(mappings)
+
+			ns.cleanupOrphans(context.Background(), tt.mode)
+
+			if got := testutil.ToFloat64(mounts) - mountsBefore; got != 1 {
+				t.Errorf("orphaned staging mounts %s = %v, want 1", tt.wantResult, got)
+			}
+			if got := testutil.ToFloat64(mappings) - mappingsBefore; got != 1 {
+				t.Errorf("orphaned LUKS mappings %s = %v, want 1", tt.wantResult, got)
+			}
+		})
+	}
+}
+
+func TestNodeServer_cleanupOrphans_off(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// No mock expectations: nothing is looked at
+	ns := &NodeServer{
+		mounter:     &mount.SafeFormatAndMount{Interface: mocks.NewMockMounter(ctrl)},
+		deviceutils: mocks.NewMockDeviceUtils(ctrl),
+		encrypt:     Encryption{FileSystem: mocks.NewMockFileSystem(ctrl)},
+	}
+	ns.cleanupOrphans(context.Background(), OrphanCleanupOff)
+}
+
+func TestNodeServer_backedByAttachedVolume_unresolved(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fs := mocks.NewMockFileSystem(ctrl)
+	fs.EXPECT().EvalSymlinks("/dev/sdb").Return("", errors.New("permission denied"))
+	fs.EXPECT().IsNotExist(gomock.Any()).DoAndReturn(os.IsNotExist)
+
+	ns := &NodeServer{encrypt: Encryption{FileSystem: fs}}
+	if !ns.backedByAttachedVolume("/dev/sdb", map[string]bool{}) {
+		t.Error("backedByAttachedVolume() = false for a device that could not be resolved, want true")
+	}
+}
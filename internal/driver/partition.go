@@ -0,0 +1,118 @@
+package driver
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	linodevolumes "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-volumes"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+const (
+	// PartitionAttribute is the StorageClass parameter, and volume context,
+	// key of the partition of the device of volumes holding their file
+	// system: the number of an existing partition, or [PartitionAuto]. The
+	// whole device is used when it is not set.
+	PartitionAttribute = "partition"
+
+	// PartitionAuto partitions blank volumes the first time they are
+	// staged, with a GPT partition table holding a single partition
+	// spanning the volume, and uses that partition.
+	PartitionAuto = "auto"
+
+	// autoPartition is the number of the partition created for
+	// [PartitionAuto].
+	autoPartition = "1"
+
+	// sfdiskDependency is the executable partitioning volumes.
+	sfdiskDependency = "sfdisk"
+
+	// partitionTableFormat is the disk format reported for devices holding a
+	// partition table.
+	partitionTableFormat = "unknown data, probably partitions"
+)
+
+var (
+	// partitionWaitTimeout is how long the device of a partition is waited
+	// for after its disk is partitioned.
+	partitionWaitTimeout = 30 * time.Second
+
+	// partitionPollInterval is how often the device of a partition is
+	// looked for while it is waited for.
+	partitionPollInterval = time.Second
+)
+
+// validatePartition checks the partition requested for a volume, if any.
+func validatePartition(parameters map[string]string) error {
+	partition := parameters[PartitionAttribute]
+	if partition == "" || partition == PartitionAuto {
+		return nil
+	}
+	if n, err := strconv.ParseUint(partition, 10, 8); err != nil || n == 0 {
+		return errInvalidPartition(partition)
+	}
+	return nil
+}
+
+// findAutoPartition returns the device of the partition holding the file
+// system of a [PartitionAuto] volume, creating it if the volume is blank.
+// Volumes holding anything but a partition table are refused, so that their
+// data is neither overwritten nor mounted at the wrong offset.
+func (ns *NodeServer) findAutoPartition(ctx context.Context, key linodevolumes.LinodeVolumeKey, readOnly bool) (string, error) {
+	log := logger.GetLogger(ctx)
+
+	disk, err := ns.findDevicePath(ctx, key, "")
+	if err != nil {
+		return "", err
+	}
+	// The disk itself is never staged, so its format is not cached
+	format, err := ns.mounter.GetDiskFormat(disk)
+	if err != nil {
+		return "", errInternal("get disk format of %s: %v", disk, err)
+	}
+
+	switch format {
+	case partitionTableFormat:
+		log.V(4).Info("Device already partitioned", "disk", disk)
+		return ns.findDevicePath(ctx, key, autoPartition)
+	case "":
+		if readOnly {
+			return "", errInternal("cannot partition blank device %s of a volume staged read-only", disk)
+		}
+		if !ns.selfTest.has(sfdiskDependency) {
+			return "", errMissingNodeDependencies("automatic partitioning", sfdiskDependency)
+		}
+		log.V(2).Info("Partitioning blank device", "volume_id", key.VolumeID, "disk", disk)
+		if err := ns.deviceutils.PartitionDisk(disk); err != nil {
+			return "", errInternal("partition %s: %v", disk, err)
+		}
+	default:
+		return "", errUnpartitionedDevice(disk, format)
+	}
+
+	return ns.waitForPartition(ctx, key)
+}
+
+// waitForPartition waits for the device of the [autoPartition] of the volume
+// of key, which udev creates once the new partition table is read.
+func (ns *NodeServer) waitForPartition(ctx context.Context, key linodevolumes.LinodeVolumeKey) (string, error) {
+	devicePaths := ns.deviceutils.GetDiskByIdPaths(key.GetNormalizedLabel(), autoPartition)
+
+	var devicePath string
+	err := wait.PollUntilContextTimeout(ctx, partitionPollInterval, partitionWaitTimeout, true, func(context.Context) (bool, error) {
+		var err error
+		devicePath, err = ns.deviceutils.VerifyDevicePath(devicePaths)
+		return devicePath != "", err
+	})
+	if devicePath != "" {
+		return devicePath, nil
+	}
+	if err != nil && !wait.Interrupted(err) {
+		return "", errInternal("Error verifying Linode Volume (%q) is attached: %v", key.GetVolumeLabel(), err)
+	}
+	// The device of renamed volumes is found under their current label
+	return ns.findDevicePath(ctx, key, autoPartition)
+}
@@ -0,0 +1,146 @@
+//go:build linux
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/mount-utils"
+	"k8s.io/utils/exec"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+	linodevolumes "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-volumes"
+)
+
+func TestValidatePartition(t *testing.T) {
+	for partition, wantErr := range map[string]bool{
+		"":     false,
+		"auto": false,
+		"1":    false,
+		"15":   false,
+		"0":    true,
+		"-1":   true,
+		"p1":   true,
+	} {
+		err := validatePartition(map[string]string{PartitionAttribute: partition})
+		if (err != nil) != wantErr {
+			t.Errorf("validatePartition(%q) error = %v, wantErr %v", partition, err, wantErr)
+		}
+	}
+	if err := validatePartition(nil); err != nil {
+		t.Errorf("validatePartition(nil) error = %v, want nil", err)
+	}
+}
+
+func TestFindAutoPartition(t *testing.T) {
+	const (
+		disk      = "/dev/disk/by-id/linode-pvc1"
+		partition = "/dev/disk/by-id/linode-pvc1-part1"
+	)
+	diskPaths := []string{disk, "/dev/disk/by-id/scsi-0Linode_Volume_pvc1"}
+	partitionPaths := []string{partition, "/dev/disk/by-id/scsi-0Linode_Volume_pvc1-part1"}
+
+	tests := []struct {
+		name        string
+		blkidOutput string
+		blkidErr    error
+		readOnly    bool
+		noSfdisk    bool
+		setupMocks  func(*mocks.MockDeviceUtils)
+		wantCode    codes.Code
+	}{
+		{
+			name:     "Blank device",
+			blkidErr: exec.CodeExitError{Code: 2, Err: fmt.Errorf("not formatted")},
+			setupMocks: func(m *mocks.MockDeviceUtils) {
+				m.EXPECT().PartitionDisk(disk).Return(nil)
+				// The partition appears once udev handled it
+				gomock.InOrder(
+					m.EXPECT().VerifyDevicePath(partitionPaths).Return("", nil),
+					m.EXPECT().VerifyDevicePath(partitionPaths).Return(partition, nil),
+				)
+			},
+		},
+		{
+			name:        "Partitioned device",
+			blkidOutput: "DEVNAME=/dev/sdb\nPTTYPE=gpt\n",
+			setupMocks: func(m *mocks.MockDeviceUtils) {
+				m.EXPECT().VerifyDevicePath(partitionPaths).Return(partition, nil)
+			},
+		},
+		{
+			name:        "File system",
+			blkidOutput: "DEVNAME=/dev/sdb\nTYPE=ext4\n",
+			wantCode:    codes.FailedPrecondition,
+		},
+		{
+			name:     "Blank device staged read-only",
+			blkidErr: exec.CodeExitError{Code: 2, Err: fmt.Errorf("not formatted")},
+			readOnly: true,
+			wantCode: codes.Internal,
+		},
+		{
+			name:     "Blank device without sfdisk",
+			blkidErr: exec.CodeExitError{Code: 2, Err: fmt.Errorf("not formatted")},
+			noSfdisk: true,
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			name:     "Partitioning failed",
+			blkidErr: exec.CodeExitError{Code: 2, Err: fmt.Errorf("not formatted")},
+			setupMocks: func(m *mocks.MockDeviceUtils) {
+				m.EXPECT().PartitionDisk(disk).Return(fmt.Errorf("sfdisk failed"))
+			},
+			wantCode: codes.Internal,
+		},
+	}
+
+	defer func(interval time.Duration) { partitionPollInterval = interval }(partitionPollInterval)
+	partitionPollInterval = time.Millisecond
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockExec := mocks.NewMockExecutor(ctrl)
+			mockCommand := mocks.NewMockCommand(ctrl)
+			mockExec.EXPECT().Command("blkid", "-p", "-s", "TYPE", "-s", "PTTYPE", "-o", "export", disk).Return(mockCommand)
+			mockCommand.EXPECT().CombinedOutput().Return([]byte(tt.blkidOutput), tt.blkidErr)
+
+			mockDevices := mocks.NewMockDeviceUtils(ctrl)
+			mockDevices.EXPECT().GetDiskByIdPaths("pvc1", "").Return(diskPaths).AnyTimes()
+			mockDevices.EXPECT().GetDiskByIdPaths("pvc1", autoPartition).Return(partitionPaths).AnyTimes()
+			mockDevices.EXPECT().VerifyDevicePath(diskPaths).Return(disk, nil)
+			if tt.setupMocks != nil {
+				tt.setupMocks(mockDevices)
+			}
+
+			ns := &NodeServer{
+				mounter: &mount.SafeFormatAndMount{
+					Interface: mocks.NewMockMounter(ctrl),
+					Exec:      mockExec,
+				},
+				deviceutils: mockDevices,
+			}
+			if tt.noSfdisk {
+				ns.selfTest = &nodeSelfTest{available: map[string]bool{blkidDependency: true}}
+			}
+
+			key := linodevolumes.CreateLinodeVolumeKey(1001, "pvc1")
+			got, err := ns.findAutoPartition(context.Background(), key, tt.readOnly)
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("findAutoPartition() error = %v, want code %v", err, tt.wantCode)
+			}
+			if tt.wantCode == codes.OK && got != partition {
+				t.Errorf("findAutoPartition() = %q, want %q", got, partition)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/linode/linodego"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+// PersistedAttachmentMode is what ControllerPublishVolume does with the
+// volumes already attached to an instance that persist across its boots,
+// because they were attached by an earlier release of the driver, with
+// PersistAcrossBoots. The driver now attaches volumes without it, so that
+// more volumes can be attached to the instance, and so that volumes are not
+// attached again when the instance boots.
+type PersistedAttachmentMode string
+
+const (
+	// PersistedAttachmentsOff does not look for persisted attachments. It
+	// is the default.
+	PersistedAttachmentsOff PersistedAttachmentMode = "off"
+
+	// PersistedAttachmentsReport logs the persisted attachments and counts
+	// them in the csi_persisted_attachments_total metric.
+	PersistedAttachmentsReport PersistedAttachmentMode = "report"
+
+	// PersistedAttachmentsFix removes the persisted attachments from the
+	// configuration profiles of the instance, which leaves the volumes
+	// attached until the instance boots again.
+	PersistedAttachmentsFix PersistedAttachmentMode = "fix"
+)
+
+// ParsePersistedAttachmentMode parses a persisted attachment mode. The empty
+// string is [PersistedAttachmentsOff].
+func ParsePersistedAttachmentMode(s string) (PersistedAttachmentMode, error) {
+	switch mode := PersistedAttachmentMode(s); mode {
+	case "":
+		return PersistedAttachmentsOff, nil
+	case PersistedAttachmentsOff, PersistedAttachmentsReport, PersistedAttachmentsFix:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid persisted attachment mode %q, must be %q, %q or %q", s, PersistedAttachmentsOff, PersistedAttachmentsReport, PersistedAttachmentsFix)
+	}
+}
+
+// checkPersistedAttachment looks for volumeID, attached to instance, in the
+// devices of the configuration profiles of the instance, and reports or
+// removes it as set with [Options.PersistedAttachments]. The volume is
+// published either way, so errors are only logged.
+func (cs *ControllerServer) checkPersistedAttachment(ctx context.Context, volumeID int, instance *linodego.Instance) {
+	if cs.driver == nil || cs.driver.opts.PersistedAttachments == "" || cs.driver.opts.PersistedAttachments == PersistedAttachmentsOff {
+		return
+	}
+	log := logger.GetLogger(ctx)
+	log.V(4).Info("Entering checkPersistedAttachment()", "volume_id", volumeID, "node_id", instance.ID)
+	defer log.V(4).Info("Exiting checkPersistedAttachment()")
+
+	configs, err := cs.linodeClient(ctx).ListInstanceConfigs(ctx, instance.ID, nil)
+	if err != nil {
+		log.Error(err, "Failed to list the configuration profiles of the instance", "node_id", instance.ID)
+		return
+	}
+
+	for _, config := range configs {
+		if config.Devices == nil {
+			continue
+		}
+		devices := *config.Devices
+		slot := volumeDeviceSlot(&devices, volumeID)
+		if slot == nil {
+			continue
+		}
+
+		if cs.driver.opts.PersistedAttachments == PersistedAttachmentsReport {
+			log.V(2).Info("Volume attachment persists across boots", "volume_id", volumeID, "node_id", instance.ID, "config_id", config.ID)
+			observability.PersistedAttachmentsTotal.WithLabelValues("reported").Inc()
+			continue
+		}
+
+		// Update the configuration profile as it is, except for the device
+		// of the volume
+		*slot = nil
+		opts := config.GetUpdateOptions()
+		opts.Devices = &devices
+		if _, err := cs.linodeClient(ctx).UpdateInstanceConfig(ctx, instance.ID, config.ID, opts); err != nil {
+			log.Error(err, "Failed to remove the volume from the configuration profile", "volume_id", volumeID, "node_id", instance.ID, "config_id", config.ID)
+			observability.PersistedAttachmentsTotal.WithLabelValues("failed").Inc()
+			continue
+		}
+		log.V(2).Info("Removed the volume from the configuration profile, it no longer persists across boots", "volume_id", volumeID, "node_id", instance.ID, "config_id", config.ID)
+		observability.PersistedAttachmentsTotal.WithLabelValues("fixed").Inc()
+	}
+}
+
+// volumeDeviceSlot returns the device of devices that volumeID is attached
+// as, or nil if it is not one of them.
+func volumeDeviceSlot(devices *linodego.InstanceConfigDeviceMap, volumeID int) **linodego.InstanceConfigDevice {
+	for _, slot := range []**linodego.InstanceConfigDevice{
+		&devices.SDA, &devices.SDB, &devices.SDC, &devices.SDD,
+		&devices.SDE, &devices.SDF, &devices.SDG, &devices.SDH,
+	} {
+		if *slot != nil && (*slot).VolumeID == volumeID {
+			return slot
+		}
+	}
+	return nil
+}
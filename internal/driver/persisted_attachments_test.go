@@ -0,0 +1,108 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/linode/linodego"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/mock/gomock"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+func TestControllerServer_checkPersistedAttachment(t *testing.T) {
+	configs := []linodego.InstanceConfig{
+		{ID: 1, Label: "boot", Kernel: "linode/grub2", Devices: &linodego.InstanceConfigDeviceMap{
+			SDA: &linodego.InstanceConfigDevice{DiskID: 10},
+			SDC: &linodego.InstanceConfigDevice{VolumeID: 1001},
+		}},
+		{ID: 2, Label: "rescue", Devices: &linodego.InstanceConfigDeviceMap{
+			SDA: &linodego.InstanceConfigDevice{DiskID: 10},
+		}},
+	}
+
+	tests := []struct {
+		name       string
+		mode       PersistedAttachmentMode
+		expect     func(m *mocks.MockLinodeClient)
+		wantResult string
+	}{
+		{
+			name:   "Off",
+			mode:   PersistedAttachmentsOff,
+			expect: func(m *mocks.MockLinodeClient) {},
+		},
+		{
+			name: "Report",
+			mode: PersistedAttachmentsReport,
+			expect: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().ListInstanceConfigs(gomock.Any(), 1003, gomock.Any()).Return(configs, nil)
+			},
+			wantResult: "reported",
+		},
+		{
+			name: "Fix",
+			mode: PersistedAttachmentsFix,
+			expect: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().ListInstanceConfigs(gomock.Any(), 1003, gomock.Any()).Return(configs, nil)
+				m.EXPECT().UpdateInstanceConfig(gomock.Any(), 1003, 1, gomock.Any()).DoAndReturn(
+					func(_ context.Context, _, _ int, opts linodego.InstanceConfigUpdateOptions) (*linodego.InstanceConfig, error) {
+						if opts.Label != "boot" || opts.Kernel != "linode/grub2" {
+							t.Errorf("UpdateInstanceConfig() changed the settings of the configuration profile: %+v", opts)
+						}
+						if opts.Devices.SDA == nil || opts.Devices.SDA.DiskID != 10 || opts.Devices.SDC != nil {
+							t.Errorf("UpdateInstanceConfig() devices = %+v, want only the disk", opts.Devices)
+						}
+						return &linodego.InstanceConfig{}, nil
+					})
+			},
+			wantResult: "fixed",
+		},
+		{
+			name: "Fix failed",
+			mode: PersistedAttachmentsFix,
+			expect: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().ListInstanceConfigs(gomock.Any(), 1003, gomock.Any()).Return(configs, nil)
+				m.EXPECT().UpdateInstanceConfig(gomock.Any(), 1003, 1, gomock.Any()).Return(nil, errors.New("forbidden"))
+			},
+			wantResult: "failed",
+		},
+		{
+			name: "Configuration profiles cannot be listed",
+			mode: PersistedAttachmentsFix,
+			expect: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().ListInstanceConfigs(gomock.Any(), 1003, gomock.Any()).Return(nil, errors.New("internal error"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockClient := mocks.NewMockLinodeClient(ctrl)
+			tt.expect(mockClient)
+			cs := &ControllerServer{client: mockClient, driver: &LinodeDriver{opts: Options{PersistedAttachments: tt.mode}}}
+
+			var before float64
+			if tt.wantResult != "" {
+				before = testutil.ToFloat64(observability.PersistedAttachmentsTotal.WithLabelValues(tt.wantResult))
+			}
+			cs.checkPersistedAttachment(context.Background(), 1001, &linodego.Instance{ID: 1003})
+			if tt.wantResult != "" {
+				if got := testutil.ToFloat64(observability.PersistedAttachmentsTotal.WithLabelValues(tt.wantResult)) - before; got != 1 {
+					t.Errorf("persisted attachments %s = %v, want 1", tt.wantResult, got)
+				}
+			}
+
+			// The configuration profiles listed are left unchanged
+			if configs[0].Devices.SDC == nil {
+				t.Fatal("checkPersistedAttachment() changed the listed configuration profile")
+			}
+		})
+	}
+}
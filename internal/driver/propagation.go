@@ -0,0 +1,54 @@
+//go:build !windows
+
+package driver
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/mount-utils"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+// mountInfoPath lists the mounts of the mount namespace of the node plugin.
+var mountInfoPath = "/proc/self/mountinfo"
+
+// checkMountPropagation returns an error if the mount holding path does not
+// propagate the mounts made under it to the other mount namespaces, such as
+// those of the kubelet and the pods. The volumes mounted under path would
+// then only be visible to the node plugin. Failing to read the mounts is
+// only logged.
+func checkMountPropagation(ctx context.Context, path string) error {
+	log := logger.GetLogger(ctx)
+
+	mounts, err := mount.ParseMountInfo(mountInfoPath)
+	if err != nil {
+		log.Error(err, "Failed to read mounts, not checking mount propagation", "path", path)
+		return nil
+	}
+
+	// Later mounts on the same mount point hide the earlier ones
+	var holder *mount.MountInfo
+	for i, m := range mounts {
+		if pathWithin(path, m.MountPoint) && (holder == nil || len(m.MountPoint) >= len(holder.MountPoint)) {
+			holder = &mounts[i]
+		}
+	}
+	if holder == nil {
+		return nil
+	}
+	for _, field := range holder.OptionalFields {
+		if strings.HasPrefix(field, "shared:") {
+			return nil
+		}
+	}
+	return errMountPropagation(path, holder.MountPoint, holder.OptionalFields)
+}
+
+// pathWithin reports whether path is dir or is in dir.
+func pathWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
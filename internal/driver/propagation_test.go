@@ -0,0 +1,92 @@
+//go:build !windows
+
+package driver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestNodeServer_checkPublishPropagation(t *testing.T) {
+	const targetPath = "/var/lib/kubelet/pods/4f2c/volumes/kubernetes.io~csi/pvc-1/mount"
+
+	tests := []struct {
+		name       string
+		mountInfo  string
+		targetPath string
+		mode       EnforcementMode
+		wantCode   codes.Code
+	}{
+		{
+			name: "Shared",
+			mountInfo: `1 0 8:1 / / rw,relatime - ext4 /dev/sda1 rw
+2 1 8:1 /var/lib/kubelet /var/lib/kubelet rw,relatime shared:1 - ext4 /dev/sda1 rw
+`,
+			targetPath: targetPath,
+		},
+		{
+			name: "Private",
+			mountInfo: `1 0 8:1 / / rw,relatime - ext4 /dev/sda1 rw
+2 1 8:1 /var/lib/kubelet /var/lib/kubelet rw,relatime - ext4 /dev/sda1 rw
+`,
+			targetPath: targetPath,
+			wantCode:   codes.FailedPrecondition,
+		},
+		{
+			name: "Slave of a nested mount",
+			mountInfo: `1 0 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw
+2 1 8:1 /var/lib/kubelet /var/lib/kubelet rw,relatime shared:2 - ext4 /dev/sda1 rw
+3 2 0:5 / /var/lib/kubelet/pods rw,relatime master:2 - tmpfs tmpfs rw
+`,
+			targetPath: targetPath,
+			wantCode:   codes.FailedPrecondition,
+		},
+		{
+			name: "Mount with a prefix of the path as name",
+			mountInfo: `1 0 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw
+2 1 0:5 / /var/lib/kube rw,relatime - tmpfs tmpfs rw
+`,
+			targetPath: targetPath,
+		},
+		{
+			name: "Warn mode",
+			mountInfo: `1 0 8:1 / / rw,relatime - ext4 /dev/sda1 rw
+`,
+			targetPath: targetPath,
+			mode:       EnforcementWarn,
+		},
+		{
+			name: "Not a kubelet path",
+			mountInfo: `1 0 8:1 / / rw,relatime - ext4 /dev/sda1 rw
+`,
+			targetPath: "/mnt/target",
+		},
+		{
+			name:       "Unreadable mounts",
+			targetPath: targetPath,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(path string) { mountInfoPath = path }(mountInfoPath)
+			mountInfoPath = filepath.Join(t.TempDir(), "mountinfo")
+			if tt.mountInfo != "" {
+				if err := os.WriteFile(mountInfoPath, []byte(tt.mountInfo), 0o600); err != nil {
+					t.Fatalf("write mountinfo: %v", err)
+				}
+			}
+
+			ns := &NodeServer{driver: &LinodeDriver{opts: Options{EnforcementMode: tt.mode}}}
+			err := ns.checkPublishPropagation(context.Background(), "1001-pvc1", tt.targetPath)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("checkPublishPropagation() error = %v, want code %v", err, tt.wantCode)
+			}
+		})
+	}
+}
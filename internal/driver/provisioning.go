@@ -0,0 +1,57 @@
+package driver
+
+import (
+	"context"
+
+	"github.com/linode/linodego"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+// ProvisioningTag is the tag of the volumes created by CreateVolume until
+// they are active. It lets a CreateVolume request retried after an earlier
+// one timed out, before the volume it created was active, resume waiting for
+// the volume instead of mistaking it for an existing volume.
+const ProvisioningTag = AttributeTagPrefix + string(attributeProvisioning)
+
+// isProvisioning reports whether vol was created by CreateVolume and not
+// seen active since.
+func isProvisioning(vol *linodego.Volume) bool {
+	_, ok := getAttribute(vol.Tags, attributeProvisioning)
+	return ok
+}
+
+// waitForNewVolume waits for vol, created by CreateVolume, to be active, and
+// removes its [ProvisioningTag]. As [ControllerServer.waitForClone] does, it
+// stops waiting shortly before the deadline of ctx, and returns
+// [errVolumeProvisioning] if the volume is not active by then, so that
+// CreateVolume is retried until it is.
+func (cs *ControllerServer) waitForNewVolume(ctx context.Context, vol *linodego.Volume) (*linodego.Volume, error) {
+	log := logger.GetLogger(ctx)
+	log.V(4).Info("Entering waitForNewVolume()", "volume_id", vol.ID)
+	defer log.V(4).Info("Exiting waitForNewVolume()")
+
+	waitCtx := ctx
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithDeadline(ctx, deadline.Add(-cloneWaitMargin))
+		defer cancel()
+	}
+
+	log.V(4).Info("Waiting for volume to be active", "volumeID", vol.ID)
+	setRPCPhase(ctx, rpcPhaseWaitForVolume)
+	active, err := cs.waitForVolumeActive(waitCtx, vol.ID, waitTimeout())
+	if err != nil {
+		if waitCtx.Err() != nil {
+			log.V(2).Info("Volume is still being created", "volume_id", vol.ID, "status", vol.Status)
+			return nil, errVolumeProvisioning(vol.ID)
+		}
+		return nil, errInternal("Timed out waiting for volume %d to be active: %v", vol.ID, err)
+	}
+
+	// The tag only matters until the volume is active
+	if err := cs.updateVolumeAttributes(ctx, active, nil, attributeProvisioning); err != nil {
+		log.Error(err, "Failed to remove the provisioning tag of the volume", "volume_id", active.ID)
+	}
+	return active, nil
+}
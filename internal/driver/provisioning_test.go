@@ -0,0 +1,88 @@
+package driver
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/linode/linodego"
+	"go.uber.org/mock/gomock"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+)
+
+func TestCreateAndWaitForVolume_provisioning(t *testing.T) {
+	tests := []struct {
+		name           string
+		setupMocks     func(*mocks.MockLinodeClient)
+		expectedVolume *linodego.Volume
+		expectedError  error
+	}{
+		{
+			name: "New volume still being created",
+			setupMocks: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().ListVolumes(gomock.Any(), gomock.Any()).Return(nil, nil)
+				m.EXPECT().CreateVolume(gomock.Any(), linodego.VolumeCreateOptions{Region: "us-east", Label: "pvc-1", Size: 10, Tags: []string{ProvisioningTag}}).
+					Return(&linodego.Volume{ID: 3, Size: 10, Status: linodego.VolumeCreating, Tags: []string{ProvisioningTag}}, nil)
+				m.EXPECT().WaitForVolumeStatus(gomock.Any(), 3, linodego.VolumeActive, gomock.Any()).DoAndReturn(
+					func(ctx context.Context, _ int, _ linodego.VolumeStatus, _ int) (*linodego.Volume, error) {
+						<-ctx.Done()
+						return nil, ctx.Err()
+					})
+			},
+			expectedError: errVolumeProvisioning(3),
+		},
+		{
+			name: "Retried while the volume is being created",
+			setupMocks: func(m *mocks.MockLinodeClient) {
+				// The size of volumes being created is not final
+				m.EXPECT().ListVolumes(gomock.Any(), gomock.Any()).Return([]linodego.Volume{{ID: 3, Status: linodego.VolumeCreating, Tags: []string{"team", ProvisioningTag}}}, nil)
+				m.EXPECT().WaitForVolumeStatus(gomock.Any(), 3, linodego.VolumeActive, gomock.Any()).
+					Return(&linodego.Volume{ID: 3, Size: 10, Status: linodego.VolumeActive, Tags: []string{"team", ProvisioningTag}}, nil)
+				m.EXPECT().UpdateVolume(gomock.Any(), 3, linodego.VolumeUpdateOptions{Tags: &[]string{"team"}}).Return(&linodego.Volume{}, nil)
+			},
+			expectedVolume: &linodego.Volume{ID: 3, Size: 10, Status: linodego.VolumeActive, Tags: []string{"team"}},
+		},
+		{
+			name: "Retried volume active with another size",
+			setupMocks: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().ListVolumes(gomock.Any(), gomock.Any()).Return([]linodego.Volume{{ID: 3, Status: linodego.VolumeCreating, Tags: []string{ProvisioningTag}}}, nil)
+				m.EXPECT().WaitForVolumeStatus(gomock.Any(), 3, linodego.VolumeActive, gomock.Any()).
+					Return(&linodego.Volume{ID: 3, Size: 20, Status: linodego.VolumeActive, Tags: []string{ProvisioningTag}}, nil)
+				m.EXPECT().UpdateVolume(gomock.Any(), 3, linodego.VolumeUpdateOptions{Tags: &[]string{}}).Return(&linodego.Volume{}, nil)
+			},
+			expectedError: errAlreadyExists("volume 3 already exists with size 20"),
+		},
+		{
+			name: "Existing volume with another size",
+			setupMocks: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().ListVolumes(gomock.Any(), gomock.Any()).Return([]linodego.Volume{{ID: 3, Size: 20, Status: linodego.VolumeActive}}, nil)
+			},
+			expectedError: errAlreadyExists("volume 3 already exists with size 20"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockClient := mocks.NewMockLinodeClient(ctrl)
+			tt.setupMocks(mockClient)
+			cs := &ControllerServer{client: mockClient, driver: &LinodeDriver{}}
+
+			// Leave the volumes a fraction of a second past the wait margin
+			ctx, cancel := context.WithTimeout(context.Background(), cloneWaitMargin+100*time.Millisecond)
+			defer cancel()
+
+			vol, err := cs.createAndWaitForVolume(ctx, "pvc-1", nil, "", 10, nil, "us-east")
+			if !reflect.DeepEqual(err, tt.expectedError) {
+				t.Errorf("createAndWaitForVolume() error = %v, want %v", err, tt.expectedError)
+			}
+			if !reflect.DeepEqual(vol, tt.expectedVolume) {
+				t.Errorf("createAndWaitForVolume() = %+v, want %+v", vol, tt.expectedVolume)
+			}
+		})
+	}
+}
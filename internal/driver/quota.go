@@ -0,0 +1,217 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	utilexec "k8s.io/utils/exec"
+
+	linodevolumes "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-volumes"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+const (
+	// ProjectQuotaAttribute is the StorageClass parameter key used to cap the
+	// usage of file system volumes to the capacity requested for them with a
+	// project quota, when it is below the size of the Linode volume. It is
+	// passed to the node plugin through the volume context.
+	ProjectQuotaAttribute = Name + "/project-quota"
+
+	// ProjectQuotaLimitAttribute is the volume context key holding the
+	// limit in bytes of the project quota of a volume.
+	ProjectQuotaLimitAttribute = Name + "/project-quota-limit"
+)
+
+// projectQuota sets up project quotas on the file systems of one type.
+type projectQuota interface {
+	// dependencies returns the executables used to set up the quotas.
+	dependencies() []string
+
+	// formatOptions returns the options of mkfs enabling project quotas on
+	// new file systems.
+	formatOptions() []string
+
+	// mountOptions returns the mount options enforcing project quotas.
+	mountOptions() []string
+
+	// enable turns on project quotas on the unmounted file system at
+	// source, which was formatted without them.
+	enable(exec utilexec.Interface, source string) error
+
+	// setLimit assigns the directory at path, and everything created in it,
+	// to project, and limits the usage of project to limitBytes.
+	setLimit(exec utilexec.Interface, path string, project int, limitBytes int64) error
+}
+
+// projectQuotas are the file system types supporting project quotas.
+var projectQuotas = map[string]projectQuota{
+	"ext4": ext4ProjectQuota{},
+}
+
+// projectQuotaDependencies returns the executables used to set up the
+// project quotas of every file system type, sorted by name.
+func projectQuotaDependencies() []string {
+	var dependencies []string
+	for _, quota := range projectQuotas {
+		dependencies = append(dependencies, quota.dependencies()...)
+	}
+	slices.Sort(dependencies)
+	return slices.Compact(dependencies)
+}
+
+// projectQuotaLimit returns the limit in bytes of the project quota
+// requested for the volume with volumeContext, or false if the usage of the
+// volume is not capped.
+func projectQuotaLimit(volumeContext map[string]string) (int64, bool, error) {
+	if volumeContext[ProjectQuotaAttribute] != True {
+		return 0, false, nil
+	}
+	limit, err := strconv.ParseInt(volumeContext[ProjectQuotaLimitAttribute], 10, 64)
+	if err != nil || limit <= 0 {
+		return 0, false, errInvalidProjectQuotaLimit(volumeContext[ProjectQuotaLimitAttribute])
+	}
+	return limit, true, nil
+}
+
+// projectQuotaContext returns the limit of the project quota to pass to the
+// node plugin for a volume created with capRange: the capacity required for
+// the volume, or its capacity limit if none is required.
+func projectQuotaContext(capRange *csi.CapacityRange) string {
+	limit := capRange.GetRequiredBytes()
+	if limit == 0 {
+		limit = capRange.GetLimitBytes()
+	}
+	if limit == 0 {
+		limit = MinVolumeSizeBytes
+	}
+	return strconv.FormatInt(limit, 10)
+}
+
+// volumeProjectQuota returns the project quotas of fsType if the usage of
+// the volume with volumeContext is capped, or false if it is not.
+func (ns *NodeServer) volumeProjectQuota(fsType string, volumeContext map[string]string) (projectQuota, bool, error) {
+	if _, ok, err := projectQuotaLimit(volumeContext); err != nil || !ok {
+		return nil, false, err
+	}
+	quota, ok := projectQuotas[fsType]
+	if !ok {
+		return nil, false, errUnsupportedProjectQuota(fsType)
+	}
+	if !ns.selfTest.supportsProjectQuota(fsType) {
+		return nil, false, errMissingNodeDependencies("project quotas on "+fsType, quota.dependencies()...)
+	}
+	return quota, true, nil
+}
+
+// projectQuotaMountOptions returns the mount options enforcing the project
+// quota of the volume with volumeContext formatted with fsType, if any.
+func projectQuotaMountOptions(fsType string, volumeContext map[string]string) ([]string, error) {
+	if _, ok, err := projectQuotaLimit(volumeContext); err != nil || !ok {
+		return nil, err
+	}
+	quota, ok := projectQuotas[fsType]
+	if !ok {
+		return nil, errUnsupportedProjectQuota(fsType)
+	}
+	return quota.mountOptions(), nil
+}
+
+// prepareProjectQuota enables project quotas on the file system at source,
+// before it is mounted, for volumes whose usage is capped. It returns the
+// options to format and mount the file system with.
+func (ns *NodeServer) prepareProjectQuota(ctx context.Context, source, fsType string, volumeContext map[string]string) (formatOptions, mountOptions []string, err error) {
+	log := logger.GetLogger(ctx)
+	log.V(4).Info("Entering prepareProjectQuota()", "source", source, "fsType", fsType)
+	defer log.V(4).Info("Exiting prepareProjectQuota()")
+
+	quota, ok, err := ns.volumeProjectQuota(fsType, volumeContext)
+	if err != nil || !ok {
+		return nil, nil, err
+	}
+
+	// Blank devices get project quotas when they are formatted, the file
+	// systems created before the quota was requested get them now
+	format, err := ns.diskFormat(ctx, source)
+	if err != nil {
+		return nil, nil, errInternal("get disk format of %s: %v", source, err)
+	}
+	if format == fsType {
+		log.V(4).Info("Enabling project quotas", "source", source, "fsType", fsType)
+		if err := quota.enable(ns.mounter.Exec, source); err != nil {
+			return nil, nil, errInternal("enable project quotas on %s: %v", source, err)
+		}
+	}
+	return quota.formatOptions(), quota.mountOptions(), nil
+}
+
+// applyProjectQuota caps the usage of the file system mounted at path for
+// the volume with volumeID, if requested. The project of the volume is its
+// ID. NodeGetVolumeStats then reports the usage of the project and its
+// limit, as statfs does for directories assigned to a project.
+func (ns *NodeServer) applyProjectQuota(ctx context.Context, path, fsType, volumeID string, volumeContext map[string]string) error {
+	log := logger.GetLogger(ctx)
+	log.V(4).Info("Entering applyProjectQuota()", "path", path, "fsType", fsType)
+	defer log.V(4).Info("Exiting applyProjectQuota()")
+
+	limit, ok, err := projectQuotaLimit(volumeContext)
+	if err != nil || !ok {
+		return err
+	}
+	quota, ok := projectQuotas[fsType]
+	if !ok {
+		return errUnsupportedProjectQuota(fsType)
+	}
+	key, err := linodevolumes.ParseLinodeVolumeKey(volumeID)
+	if err != nil {
+		return err
+	}
+
+	if err := quota.setLimit(ns.mounter.Exec, path, key.VolumeID, limit); err != nil {
+		return errInternal("set project quota of volume %s at %s: %v", volumeID, path, err)
+	}
+	log.V(2).Info("Project quota set", "volume_id", volumeID, "path", path, "limit", limit)
+	return nil
+}
+
+// ext4ProjectQuota sets up project quotas on ext4 file systems with the
+// tools of e2fsprogs and quota-tools.
+type ext4ProjectQuota struct{}
+
+func (ext4ProjectQuota) dependencies() []string {
+	return []string{"chattr", "setquota", "tune2fs"}
+}
+
+func (ext4ProjectQuota) formatOptions() []string {
+	return []string{"-O", "quota,project"}
+}
+
+func (ext4ProjectQuota) mountOptions() []string {
+	return []string{"prjquota"}
+}
+
+func (ext4ProjectQuota) enable(exec utilexec.Interface, source string) error {
+	return runQuotaCommand(exec, "tune2fs", "-O", "quota,project", "-Q", "prjquota", source)
+}
+
+func (ext4ProjectQuota) setLimit(exec utilexec.Interface, path string, project int, limitBytes int64) error {
+	id := strconv.Itoa(project)
+	if err := runQuotaCommand(exec, "chattr", "+P", "-p", id, path); err != nil {
+		return err
+	}
+	// setquota counts blocks in KiB
+	blocks := strconv.FormatInt((limitBytes+1023)/1024, 10)
+	return runQuotaCommand(exec, "setquota", "-P", id, "0", blocks, "0", "0", path)
+}
+
+// runQuotaCommand runs cmd with args, and returns its output with the error
+// if it fails.
+func runQuotaCommand(exec utilexec.Interface, cmd string, args ...string) error {
+	if output, err := exec.Command(cmd, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s %s: %w: %s", cmd, strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+//go:build linux
+
+package driver
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/mount-utils"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+)
+
+func TestProjectQuotaLimit(t *testing.T) {
+	tests := []struct {
+		name          string
+		volumeContext map[string]string
+		wantLimit     int64
+		wantOK        bool
+		wantCode      codes.Code
+	}{
+		{
+			name:          "Not requested",
+			volumeContext: map[string]string{},
+		},
+		{
+			name:          "Requested",
+			volumeContext: map[string]string{ProjectQuotaAttribute: True, ProjectQuotaLimitAttribute: "1073741824"},
+			wantLimit:     1 << 30,
+			wantOK:        true,
+		},
+		{
+			name:          "Missing limit",
+			volumeContext: map[string]string{ProjectQuotaAttribute: True},
+			wantCode:      codes.InvalidArgument,
+		},
+		{
+			name:          "Negative limit",
+			volumeContext: map[string]string{ProjectQuotaAttribute: True, ProjectQuotaLimitAttribute: "-1"},
+			wantCode:      codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit, ok, err := projectQuotaLimit(tt.volumeContext)
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("projectQuotaLimit() error = %v, want code %v", err, tt.wantCode)
+			}
+			if limit != tt.wantLimit || ok != tt.wantOK {
+				t.Errorf("projectQuotaLimit() = %d, %v, want %d, %v", limit, ok, tt.wantLimit, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestPrepareProjectQuota(t *testing.T) {
+	quotaContext := map[string]string{ProjectQuotaAttribute: True, ProjectQuotaLimitAttribute: "1073741824"}
+
+	tests := []struct {
+		name              string
+		fsType            string
+		volumeContext     map[string]string
+		blkidOutput       string
+		expectEnable      bool
+		wantFormatOptions []string
+		wantMountOptions  []string
+		wantCode          codes.Code
+	}{
+		{
+			name:          "Not requested",
+			fsType:        "ext4",
+			volumeContext: map[string]string{},
+		},
+		{
+			name:              "Blank device",
+			fsType:            "ext4",
+			volumeContext:     quotaContext,
+			wantFormatOptions: []string{"-O", "quota,project"},
+			wantMountOptions:  []string{"prjquota"},
+		},
+		{
+			name:              "Existing file system",
+			fsType:            "ext4",
+			volumeContext:     quotaContext,
+			blkidOutput:       "DEVNAME=/dev/sdb\nTYPE=ext4\n",
+			expectEnable:      true,
+			wantFormatOptions: []string{"-O", "quota,project"},
+			wantMountOptions:  []string{"prjquota"},
+		},
+		{
+			name:          "Unsupported file system type",
+			fsType:        "xfs",
+			volumeContext: quotaContext,
+			wantCode:      codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockExec := mocks.NewMockExecutor(ctrl)
+			if tt.wantFormatOptions != nil {
+				blkid := mocks.NewMockCommand(ctrl)
+				mockExec.EXPECT().Command("blkid", "-p", "-s", "TYPE", "-s", "PTTYPE", "-o", "export", "/dev/sdb").Return(blkid)
+				blkid.EXPECT().CombinedOutput().Return([]byte(tt.blkidOutput), nil)
+			}
+			if tt.expectEnable {
+				tune2fs := mocks.NewMockCommand(ctrl)
+				mockExec.EXPECT().Command("tune2fs", "-O", "quota,project", "-Q", "prjquota", "/dev/sdb").Return(tune2fs)
+				tune2fs.EXPECT().CombinedOutput().Return(nil, nil)
+			}
+
+			ns := &NodeServer{
+				mounter: &mount.SafeFormatAndMount{
+					Interface: mocks.NewMockMounter(ctrl),
+					Exec:      mockExec,
+				},
+			}
+			formatOptions, mountOptions, err := ns.prepareProjectQuota(context.Background(), "/dev/sdb", tt.fsType, tt.volumeContext)
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("prepareProjectQuota() error = %v, want code %v", err, tt.wantCode)
+			}
+			if !reflect.DeepEqual(formatOptions, tt.wantFormatOptions) || !reflect.DeepEqual(mountOptions, tt.wantMountOptions) {
+				t.Errorf("prepareProjectQuota() = %v, %v, want %v, %v", formatOptions, mountOptions, tt.wantFormatOptions, tt.wantMountOptions)
+			}
+		})
+	}
+}
+
+func TestApplyProjectQuota(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockExec := mocks.NewMockExecutor(ctrl)
+	chattr := mocks.NewMockCommand(ctrl)
+	mockExec.EXPECT().Command("chattr", "+P", "-p", "1001", "/staging").Return(chattr)
+	chattr.EXPECT().CombinedOutput().Return(nil, nil)
+	setquota := mocks.NewMockCommand(ctrl)
+	mockExec.EXPECT().Command("setquota", "-P", "1001", "0", "1048576", "0", "0", "/staging").Return(setquota)
+	setquota.EXPECT().CombinedOutput().Return(nil, nil)
+
+	ns := &NodeServer{
+		mounter: &mount.SafeFormatAndMount{
+			Interface: mocks.NewMockMounter(ctrl),
+			Exec:      mockExec,
+		},
+	}
+	volumeContext := map[string]string{ProjectQuotaAttribute: True, ProjectQuotaLimitAttribute: "1073741824"}
+	if err := ns.applyProjectQuota(context.Background(), "/staging", "ext4", "1001-test", volumeContext); err != nil {
+		t.Errorf("applyProjectQuota() error = %v", err)
+	}
+}
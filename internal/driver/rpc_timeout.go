@@ -0,0 +1,165 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+// defaultRPCTimeouts are the maximum durations of the requests of the
+// methods that wait for the Linode API or the node, by method name. They
+// leave room for the waits of the handlers, e.g. [WaitTimeout] for
+// attachments, and CreateVolume resumes the volumes it stopped waiting for
+// when it is retried.
+var defaultRPCTimeouts = map[string]time.Duration{
+	"CreateVolume":              10 * time.Minute,
+	"DeleteVolume":              5 * time.Minute,
+	"ControllerPublishVolume":   6 * time.Minute,
+	"ControllerUnpublishVolume": 6 * time.Minute,
+	"ControllerExpandVolume":    6 * time.Minute,
+	"ListVolumes":               2 * time.Minute,
+	"NodeStageVolume":           2 * time.Minute,
+	"NodeUnstageVolume":         2 * time.Minute,
+	"NodeExpandVolume":          2 * time.Minute,
+}
+
+// defaultRPCTimeout is the maximum duration of the requests of the methods
+// without a default in [defaultRPCTimeouts].
+const defaultRPCTimeout = time.Minute
+
+// Phases of a request reported when it exceeds its maximum duration, used
+// as the "phase" label of the csi_rpc_timeouts_total metric. The steps of
+// NodeStageVolume are phases too.
+const (
+	rpcPhaseHandler       = "handler"
+	rpcPhaseWaitForVolume = "wait_for_volume"
+	rpcPhaseWaitForClone  = "wait_for_clone"
+	rpcPhaseWaitForAttach = "wait_for_attach"
+	rpcPhaseWaitForDetach = "wait_for_detach"
+	rpcPhaseWaitForResize = "wait_for_resize"
+)
+
+// ParseRPCTimeouts parses a comma-separated list of maximum request
+// durations by CSI method name, e.g. "CreateVolume=15m,NodeStageVolume=5m".
+// A duration of 0 lets the requests of the method run until the deadline
+// set by the caller.
+func ParseRPCTimeouts(s string) (map[string]time.Duration, error) {
+	methods := csiMethods()
+	timeouts := make(map[string]time.Duration)
+	for _, entry := range strings.Split(s, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		method, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid RPC timeout %q, must be <method>=<duration>", entry)
+		}
+		method = strings.TrimSpace(method)
+		if !slices.Contains(methods, method) {
+			return nil, fmt.Errorf("invalid RPC timeout %q, %q is not a CSI method", entry, method)
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("invalid RPC timeout %q, the duration must be positive or 0", entry)
+		}
+		timeouts[method] = timeout
+	}
+	return timeouts, nil
+}
+
+// csiMethods returns the names of the methods of the CSI services served by
+// the driver.
+func csiMethods() []string {
+	var methods []string
+	for _, desc := range []grpc.ServiceDesc{csi.Identity_ServiceDesc, csi.Controller_ServiceDesc, csi.Node_ServiceDesc} {
+		for _, method := range desc.Methods {
+			methods = append(methods, method.MethodName)
+		}
+	}
+	return methods
+}
+
+// rpcTimeout returns the maximum duration of the requests of method, from
+// overrides or the defaults. It is 0 if the requests are not limited.
+func rpcTimeout(overrides map[string]time.Duration, method string) time.Duration {
+	if timeout, ok := overrides[method]; ok {
+		return timeout
+	}
+	if timeout, ok := defaultRPCTimeouts[method]; ok {
+		return timeout
+	}
+	return defaultRPCTimeout
+}
+
+// rpcPhase is the phase reached by a request, updated by its handler with
+// [setRPCPhase].
+type rpcPhase struct {
+	mu   sync.Mutex
+	name string
+}
+
+type rpcPhaseKey struct{}
+
+// setRPCPhase records that the request of ctx reached phase, before a step
+// that may take long.
+func setRPCPhase(ctx context.Context, phase string) {
+	if p, ok := ctx.Value(rpcPhaseKey{}).(*rpcPhase); ok {
+		p.mu.Lock()
+		p.name = phase
+		p.mu.Unlock()
+	}
+}
+
+func (p *rpcPhase) get() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.name
+}
+
+// rpcTimeoutInterceptor limits the duration of the requests to the maximum
+// of their method, from overrides or the defaults, whatever the deadline set
+// by the caller, so that sidecars sending no deadline or very long ones do
+// not keep requests running forever. The handlers stop when their context
+// is done; requests failing after their maximum fail with DeadlineExceeded,
+// logging and counting the phase they reached.
+func rpcTimeoutInterceptor(overrides map[string]time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		method := path.Base(info.FullMethod)
+		maximum := rpcTimeout(overrides, method)
+		if maximum <= 0 {
+			return handler(ctx, req)
+		}
+		deadline := time.Now().Add(maximum)
+		if callerDeadline, ok := ctx.Deadline(); ok && !callerDeadline.After(deadline) {
+			return handler(ctx, req)
+		}
+
+		parent := ctx
+		ctx, cancel := context.WithDeadline(ctx, deadline)
+		defer cancel()
+		phase := &rpcPhase{name: rpcPhaseHandler}
+		ctx = context.WithValue(ctx, rpcPhaseKey{}, phase)
+
+		resp, err := handler(ctx, req)
+		if err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) || parent.Err() != nil {
+			return resp, err
+		}
+		reached := phase.get()
+		observability.RPCTimeoutsTotal.WithLabelValues(method, reached).Inc()
+		logger.GetLogger(ctx).Error(err, "Request exceeded its maximum duration", "method", method, "maximum", maximum, "phase", reached)
+		return nil, status.Errorf(codes.DeadlineExceeded, "%s did not complete within %s, in phase %s: %s", method, maximum, reached, status.Convert(err).Message())
+	}
+}
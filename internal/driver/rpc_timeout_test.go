@@ -0,0 +1,155 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseRPCTimeouts(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]time.Duration
+		wantErr bool
+	}{
+		{
+			name:  "Empty",
+			input: "",
+			want:  map[string]time.Duration{},
+		},
+		{
+			name:  "Controller and node methods",
+			input: "CreateVolume=15m, NodeStageVolume = 5m,ListVolumes=0",
+			want: map[string]time.Duration{
+				"CreateVolume":    15 * time.Minute,
+				"NodeStageVolume": 5 * time.Minute,
+				"ListVolumes":     0,
+			},
+		},
+		{
+			name:    "Unknown method",
+			input:   "CreateVolumes=15m",
+			wantErr: true,
+		},
+		{
+			name:    "Missing duration",
+			input:   "CreateVolume",
+			wantErr: true,
+		},
+		{
+			name:    "Negative duration",
+			input:   "CreateVolume=-1m",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRPCTimeouts(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRPCTimeouts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !maps.Equal(got, tt.want) {
+				t.Errorf("ParseRPCTimeouts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRPCTimeoutInterceptor(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/csi.v1.Node/NodeStageVolume"}
+	// waitForDeadline reaches the mount phase and fails when its context is
+	// done, as the handlers waiting for the Linode API or the node do.
+	waitForDeadline := func(ctx context.Context, _ any) (any, error) {
+		setRPCPhase(ctx, stageStepMount)
+		<-ctx.Done()
+		return nil, errInternal("mount: %v", ctx.Err())
+	}
+
+	tests := []struct {
+		name          string
+		overrides     map[string]time.Duration
+		callerTimeout time.Duration
+		handler       grpc.UnaryHandler
+		wantCode      codes.Code
+		wantMessage   string
+	}{
+		{
+			name:        "Maximum exceeded",
+			overrides:   map[string]time.Duration{"NodeStageVolume": 10 * time.Millisecond},
+			handler:     waitForDeadline,
+			wantCode:    codes.DeadlineExceeded,
+			wantMessage: "NodeStageVolume did not complete within 10ms, in phase mount",
+		},
+		{
+			name:          "Shorter deadline of the caller",
+			overrides:     map[string]time.Duration{"NodeStageVolume": time.Hour},
+			callerTimeout: 10 * time.Millisecond,
+			handler:       waitForDeadline,
+			wantCode:      codes.Internal,
+			wantMessage:   "mount: context deadline exceeded",
+		},
+		{
+			name:      "Completed within the maximum",
+			overrides: map[string]time.Duration{"NodeStageVolume": time.Hour},
+			handler: func(ctx context.Context, _ any) (any, error) {
+				return "staged", nil
+			},
+			wantCode: codes.OK,
+		},
+		{
+			name:      "Not limited",
+			overrides: map[string]time.Duration{"NodeStageVolume": 0},
+			handler: func(ctx context.Context, _ any) (any, error) {
+				if _, ok := ctx.Deadline(); ok {
+					return nil, errors.New("unexpected deadline")
+				}
+				return "staged", nil
+			},
+			wantCode: codes.OK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.callerTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.callerTimeout)
+				defer cancel()
+			}
+
+			resp, err := rpcTimeoutInterceptor(tt.overrides)(ctx, nil, info, tt.handler)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("interceptor error = %v, want code %v", err, tt.wantCode)
+			}
+			if err != nil && !strings.HasPrefix(status.Convert(err).Message(), tt.wantMessage) {
+				t.Errorf("interceptor error = %q, want prefix %q", status.Convert(err).Message(), tt.wantMessage)
+			}
+			if err == nil && resp != "staged" {
+				t.Errorf("interceptor response = %v, want the response of the handler", resp)
+			}
+		})
+	}
+}
+
+func TestRPCTimeout(t *testing.T) {
+	overrides := map[string]time.Duration{"CreateVolume": 15 * time.Minute}
+	for method, want := range map[string]time.Duration{
+		"CreateVolume":      15 * time.Minute,
+		"NodeStageVolume":   2 * time.Minute,
+		"NodePublishVolume": defaultRPCTimeout,
+	} {
+		if got := rpcTimeout(overrides, method); got != want {
+			t.Errorf("rpcTimeout(%q) = %v, want %v", method, got, want)
+		}
+	}
+}
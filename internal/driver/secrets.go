@@ -0,0 +1,133 @@
+package driver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	linodeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-client"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+// LinodeTokenSecretKey is the key of the Linode API token in the secrets of
+// CreateVolume, DeleteVolume and ControllerExpandVolume requests, set with
+// the csi.storage.k8s.io/provisioner-secret-* and
+// csi.storage.k8s.io/controller-expand-secret-* StorageClass parameters.
+// The requests with a token are sent to the Linode API with it, instead of
+// the token of the controller.
+const LinodeTokenSecretKey = "linodeToken"
+
+// requestClientKey is the context key of the Linode client of a request.
+type requestClientKey struct{}
+
+// requestTokenKey is the context key of the hash of the Linode token of a
+// request, see [requestTokenHash].
+type requestTokenKey struct{}
+
+// withSecrets returns ctx carrying the Linode client for the token in
+// secrets, which [ControllerServer.linodeClient] then returns, or ctx if
+// secrets have no token.
+func (cs *ControllerServer) withSecrets(ctx context.Context, secrets map[string]string) (context.Context, error) {
+	token, ok := secrets[LinodeTokenSecretKey]
+	if !ok {
+		return ctx, nil
+	}
+	if token == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "secret %s is empty", LinodeTokenSecretKey)
+	}
+
+	client, err := cs.tokenClients.get(token, cs.newTokenClient)
+	if err != nil {
+		return nil, err
+	}
+	logger.GetLogger(ctx).V(4).Info("Using the Linode token of the request secrets")
+	sum := sha256.Sum256([]byte(token))
+	ctx = context.WithValue(ctx, requestTokenKey{}, hex.EncodeToString(sum[:]))
+	return context.WithValue(ctx, requestClientKey{}, client), nil
+}
+
+// requestTokenHash returns the SHA-256 hash of the Linode token of the
+// request of ctx, set with [ControllerServer.withSecrets], or "" if it uses
+// the token of the controller. It tells apart the requests made with
+// different tokens without keeping the tokens.
+func requestTokenHash(ctx context.Context) string {
+	hash, _ := ctx.Value(requestTokenKey{}).(string)
+	return hash
+}
+
+// linodeClient returns the Linode client of the request of ctx, set with
+// [ControllerServer.withSecrets], or the client of the controller.
+func (cs *ControllerServer) linodeClient(ctx context.Context) linodeclient.LinodeClient {
+	if client, ok := ctx.Value(requestClientKey{}).(linodeclient.LinodeClient); ok {
+		return client
+	}
+	return cs.client
+}
+
+// newTokenClient creates a Linode client using token, which shares the
+// maintenance breaker of the controller.
+func (cs *ControllerServer) newTokenClient(token string) (linodeclient.LinodeClient, error) {
+	if cs.driver == nil || cs.driver.opts.NewLinodeClient == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "secret %s is not supported by this controller", LinodeTokenSecretKey)
+	}
+	client, err := cs.driver.opts.NewLinodeClient(token)
+	if err != nil {
+		return nil, errInternal("create Linode client for the request secrets: %v", err)
+	}
+	return &maintenanceClient{LinodeClient: client, breaker: &cs.maintenance}, nil
+}
+
+// tokenClientCache holds the Linode clients created for the tokens of
+// request secrets, so that their connections are reused across requests.
+type tokenClientCache struct {
+	mu      sync.Mutex
+	clients map[string]linodeclient.LinodeClient // By token
+}
+
+// get returns the client for token, created with create if there is none.
+func (c *tokenClientCache) get(token string, create func(string) (linodeclient.LinodeClient, error)) (linodeclient.LinodeClient, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if client, ok := c.clients[token]; ok {
+		return client, nil
+	}
+	client, err := create(token)
+	if err != nil {
+		return nil, err
+	}
+	if c.clients == nil {
+		c.clients = make(map[string]linodeclient.LinodeClient)
+	}
+	c.clients[token] = client
+	return client, nil
+}
+
+// redactedSecret replaces the values of the secrets of logged requests.
+const redactedSecret = "REDACTED"
+
+// redactSecrets returns a copy of the request req, with the values of its
+// secrets replaced, to be logged.
+func redactSecrets(req proto.Message) proto.Message {
+	m := proto.Clone(req).ProtoReflect()
+	field := m.Descriptor().Fields().ByName("secrets")
+	if field == nil || !field.IsMap() || !m.Has(field) {
+		return m.Interface()
+	}
+	secrets := m.Mutable(field).Map()
+	var keys []protoreflect.MapKey
+	secrets.Range(func(key protoreflect.MapKey, _ protoreflect.Value) bool {
+		keys = append(keys, key)
+		return true
+	})
+	for _, key := range keys {
+		secrets.Set(key, protoreflect.ValueOfString(redactedSecret))
+	}
+	return m.Interface()
+}
@@ -0,0 +1,116 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/linode/linodego"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+	linodeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-client"
+)
+
+func TestControllerServer_withSecrets(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	defaultClient := mocks.NewMockLinodeClient(ctrl)
+	tokenClient := mocks.NewMockLinodeClient(ctrl)
+	created := 0
+	cs := &ControllerServer{
+		driver: &LinodeDriver{opts: Options{NewLinodeClient: func(token string) (linodeclient.LinodeClient, error) {
+			if token == "bad" {
+				return nil, errors.New("invalid token")
+			}
+			created++
+			return tokenClient, nil
+		}}},
+		client: defaultClient,
+	}
+
+	ctx, err := cs.withSecrets(context.Background(), map[string]string{LuksKeyAttribute: "key"})
+	if err != nil {
+		t.Fatalf("withSecrets() without token error = %v", err)
+	}
+	if got := cs.linodeClient(ctx); got != defaultClient {
+		t.Errorf("linodeClient() without token = %v, want the client of the controller", got)
+	}
+
+	for range 2 {
+		ctx, err = cs.withSecrets(context.Background(), map[string]string{LinodeTokenSecretKey: "token"})
+		if err != nil {
+			t.Fatalf("withSecrets() error = %v", err)
+		}
+		if _, ok := cs.linodeClient(ctx).(*maintenanceClient); !ok {
+			t.Errorf("linodeClient() = %T, want the client for the token", cs.linodeClient(ctx))
+		}
+	}
+	if created != 1 {
+		t.Errorf("created %d clients for the same token, want 1", created)
+	}
+
+	for _, tt := range []struct {
+		token string
+		code  codes.Code
+	}{
+		{token: "", code: codes.InvalidArgument},
+		{token: "bad", code: codes.Internal},
+	} {
+		if _, err := cs.withSecrets(context.Background(), map[string]string{LinodeTokenSecretKey: tt.token}); status.Code(err) != tt.code {
+			t.Errorf("withSecrets(%q) error = %v, want code %v", tt.token, err, tt.code)
+		}
+	}
+
+	cs = &ControllerServer{client: defaultClient}
+	if _, err := cs.withSecrets(context.Background(), map[string]string{LinodeTokenSecretKey: "token"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("withSecrets() without NewLinodeClient error = %v, want code %v", err, codes.InvalidArgument)
+	}
+}
+
+func TestDeleteVolume_secrets(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The client of the controller is not used
+	defaultClient := mocks.NewMockLinodeClient(ctrl)
+	tokenClient := mocks.NewMockLinodeClient(ctrl)
+	tokenClient.EXPECT().GetVolume(gomock.Any(), 1001).Return(&linodego.Volume{ID: 1001}, nil)
+	tokenClient.EXPECT().DeleteVolume(gomock.Any(), 1001).Return(nil)
+
+	cs := &ControllerServer{
+		driver: &LinodeDriver{opts: Options{NewLinodeClient: func(token string) (linodeclient.LinodeClient, error) {
+			return tokenClient, nil
+		}}},
+		client: defaultClient,
+	}
+	_, err := cs.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{
+		VolumeId: "1001-pvc",
+		Secrets:  map[string]string{LinodeTokenSecretKey: "token"},
+	})
+	if err != nil {
+		t.Fatalf("DeleteVolume() error = %v", err)
+	}
+}
+
+func TestRedactSecrets(t *testing.T) {
+	req := &csi.CreateVolumeRequest{Name: "pvc", Secrets: map[string]string{LinodeTokenSecretKey: "token"}}
+	got, ok := redactSecrets(req).(*csi.CreateVolumeRequest)
+	if !ok {
+		t.Fatalf("redactSecrets() = %T, want *csi.CreateVolumeRequest", redactSecrets(req))
+	}
+	if got.GetName() != "pvc" || got.GetSecrets()[LinodeTokenSecretKey] != redactedSecret {
+		t.Errorf("redactSecrets() = %v, want the request with redacted secrets", got)
+	}
+	if req.GetSecrets()[LinodeTokenSecretKey] != "token" {
+		t.Errorf("redactSecrets() changed the secrets of the request")
+	}
+
+	if _, ok := redactSecrets(&csi.ListVolumesRequest{}).(*csi.ListVolumesRequest); !ok {
+		t.Error("redactSecrets() of a request without secrets did not return it")
+	}
+}
@@ -0,0 +1,170 @@
+package driver
+
+import (
+	"cmp"
+	"context"
+	"path"
+	"slices"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"golang.org/x/sys/unix"
+
+	filesystem "github.com/linode/linode-blockstorage-csi-driver/pkg/filesystem"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+	mountmanager "github.com/linode/linode-blockstorage-csi-driver/pkg/mount-manager"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+// Dependencies of the node plugin checked by [runNodeSelfTest].
+const (
+	blkidDependency   = "blkid"
+	dmCryptDependency = "dm_crypt"
+)
+
+// mkfsDependency returns the name of the executable used to format volumes
+// with fsType.
+func mkfsDependency(fsType string) string {
+	return "mkfs." + fsType
+}
+
+const (
+	// sysModulePath lists the kernel modules that are loaded or built into
+	// the kernel.
+	sysModulePath = "/sys/module"
+
+	// libModulesPath holds the kernel modules that can be loaded.
+	libModulesPath = "/lib/modules"
+)
+
+// nodeSelfTest records which of the tools and kernel features used to stage
+// volumes were found on the node when the driver started.
+//
+// A nil *nodeSelfTest reports every dependency as available.
+type nodeSelfTest struct {
+	available map[string]bool
+}
+
+// runNodeSelfTest looks for the executables and kernel modules the node
+// plugin needs, and reports the results through the
+// csi_node_dependency_available metric.
+func runNodeSelfTest(ctx context.Context, executor mountmanager.Executor, fs filesystem.FileSystem) *nodeSelfTest {
+	log := logger.GetLogger(ctx)
+	log.V(4).Info("Entering runNodeSelfTest()")
+	defer log.V(4).Info("Exiting runNodeSelfTest()")
+
+	st := &nodeSelfTest{available: make(map[string]bool)}
+
+	executables := []string{blkidDependency, sfdiskDependency}
+	for _, fsType := range supportedFSTypes {
+		executables = append(executables, mkfsDependency(fsType))
+	}
+	executables = append(executables, projectQuotaDependencies()...)
+	for _, executable := range executables {
+		_, err := executor.LookPath(executable)
+		st.available[executable] = err == nil
+	}
+	st.available[dmCryptDependency] = dmCryptAvailable(fs)
+
+	for dependency, available := range st.available {
+		value := 0.0
+		if available {
+			value = 1
+		} else {
+			log.V(2).Info("Node dependency is missing", "dependency", dependency)
+		}
+		observability.NodeDependencyAvailable.WithLabelValues(dependency).Set(value)
+	}
+	return st
+}
+
+// dmCryptAvailable reports whether the dm_crypt kernel module is loaded,
+// built into the kernel, or can be loaded.
+func dmCryptAvailable(fs filesystem.FileSystem) bool {
+	if _, err := fs.Stat(path.Join(sysModulePath, dmCryptDependency)); err == nil {
+		return true
+	}
+
+	var uname unix.Utsname
+	if err := unix.Uname(&uname); err != nil {
+		return false
+	}
+	release := unix.ByteSliceToString(uname.Release[:])
+	modules, err := fs.Glob(path.Join(libModulesPath, release, "kernel/drivers/md/dm-crypt.ko*"))
+	return err == nil && len(modules) > 0
+}
+
+// missing returns the dependencies that were not found, sorted by name.
+func (st *nodeSelfTest) missing() []string {
+	if st == nil {
+		return nil
+	}
+
+	var missing []string
+	for dependency, available := range st.available {
+		if !available {
+			missing = append(missing, dependency)
+		}
+	}
+	slices.Sort(missing)
+	return missing
+}
+
+// canStage reports whether volumes can be staged with the default file
+// system fsType.
+func (st *nodeSelfTest) canStage(fsType string) bool {
+	return st.has(blkidDependency) && st.supportsFSType(fsType)
+}
+
+// supportsFSType reports whether volumes can be formatted with fsType. File
+// systems other than [supportedFSTypes] are not checked.
+func (st *nodeSelfTest) supportsFSType(fsType string) bool {
+	return !supportedFSType(fsType) || st.has(mkfsDependency(fsType))
+}
+
+// supportsEncryption reports whether LUKS encrypted volumes can be staged.
+func (st *nodeSelfTest) supportsEncryption() bool {
+	return st.has(dmCryptDependency)
+}
+
+// supportsProjectQuota reports whether the usage of volumes formatted with
+// fsType can be capped with a project quota.
+func (st *nodeSelfTest) supportsProjectQuota(fsType string) bool {
+	quota, ok := projectQuotas[fsType]
+	if !ok {
+		return false
+	}
+	for _, dependency := range quota.dependencies() {
+		if !st.has(dependency) {
+			return false
+		}
+	}
+	return true
+}
+
+func (st *nodeSelfTest) has(dependency string) bool {
+	return st == nil || st.available[dependency]
+}
+
+// canStage reports whether volumes can be staged on the node with the
+// driver's default file system.
+func (ns *NodeServer) canStage() bool {
+	return ns.selfTest.canStage(cmp.Or(ns.driverFSType(), defaultFSType))
+}
+
+// applyNodeSelfTest stops advertising the STAGE_UNSTAGE_VOLUME capability
+// when volumes cannot be staged on the node with the default file system,
+// so that the kubelet does not stage them, as the driver is then not ready
+// either.
+func (linodeDriver *LinodeDriver) applyNodeSelfTest(ctx context.Context) {
+	log := logger.GetLogger(ctx)
+	if !linodeDriver.ns.canStage() {
+		log.Error(errMissingNodeDependencies("volume staging", linodeDriver.ns.selfTest.missing()...), "Volumes cannot be staged on this node, not advertising the STAGE_UNSTAGE_VOLUME capability")
+		linodeDriver.nscap = slices.DeleteFunc(linodeDriver.nscap, func(c *csi.NodeServiceCapability) bool {
+			return c.GetRpc().GetType() == csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME
+		})
+		return
+	}
+	if !linodeDriver.ns.selfTest.supportsEncryption() {
+		log.V(2).Info("LUKS encrypted volumes cannot be staged on this node", "missing", dmCryptDependency)
+	}
+}
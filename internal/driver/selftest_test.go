@@ -0,0 +1,195 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/mount-utils"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+)
+
+func TestRunNodeSelfTest(t *testing.T) {
+	tests := []struct {
+		name               string
+		missing            []string
+		dmCryptLoaded      bool
+		wantCanStage       bool
+		wantXFS            bool
+		wantEncryption     bool
+		expectedMissingDep []string
+	}{
+		{
+			name:           "All dependencies available",
+			dmCryptLoaded:  true,
+			wantCanStage:   true,
+			wantXFS:        true,
+			wantEncryption: true,
+		},
+		{
+			name:               "No xfs tools or dm_crypt",
+			missing:            []string{"mkfs.xfs"},
+			wantCanStage:       true,
+			expectedMissingDep: []string{"dm_crypt", "mkfs.xfs"},
+		},
+		{
+			name:               "No blkid",
+			missing:            []string{"blkid"},
+			dmCryptLoaded:      true,
+			wantXFS:            true,
+			wantEncryption:     true,
+			expectedMissingDep: []string{"blkid"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockExec := mocks.NewMockExecutor(ctrl)
+			mockFS := mocks.NewMockFileSystem(ctrl)
+
+			mockExec.EXPECT().LookPath(gomock.Any()).DoAndReturn(func(file string) (string, error) {
+				for _, missing := range tt.missing {
+					if file == missing {
+						return "", errors.New("executable file not found in $PATH")
+					}
+				}
+				return "/usr/sbin/" + file, nil
+			}).Times(8)
+			if tt.dmCryptLoaded {
+				mockFS.EXPECT().Stat("/sys/module/dm_crypt").Return(nil, nil)
+			} else {
+				mockFS.EXPECT().Stat("/sys/module/dm_crypt").Return(nil, os.ErrNotExist)
+				mockFS.EXPECT().Glob(gomock.Any()).Return(nil, nil)
+			}
+
+			st := runNodeSelfTest(context.Background(), mockExec, mockFS)
+			if got := st.canStage("ext4"); got != tt.wantCanStage {
+				t.Errorf("canStage() = %v, want %v", got, tt.wantCanStage)
+			}
+			if got := st.supportsFSType("xfs"); got != tt.wantXFS {
+				t.Errorf("supportsFSType(xfs) = %v, want %v", got, tt.wantXFS)
+			}
+			if got := st.supportsEncryption(); got != tt.wantEncryption {
+				t.Errorf("supportsEncryption() = %v, want %v", got, tt.wantEncryption)
+			}
+			if got := st.missing(); !reflect.DeepEqual(got, tt.expectedMissingDep) {
+				t.Errorf("missing() = %v, want %v", got, tt.expectedMissingDep)
+			}
+		})
+	}
+}
+
+func TestApplyNodeSelfTest(t *testing.T) {
+	tests := []struct {
+		name      string
+		selfTest  *nodeSelfTest
+		wantStage bool
+	}{
+		{
+			name:      "All dependencies",
+			selfTest:  &nodeSelfTest{available: map[string]bool{"blkid": true, "mkfs.ext4": true, "dm_crypt": true}},
+			wantStage: true,
+		},
+		{
+			name:      "Missing dm_crypt",
+			selfTest:  &nodeSelfTest{available: map[string]bool{"blkid": true, "mkfs.ext4": true}},
+			wantStage: true,
+		},
+		{
+			name:     "Missing blkid",
+			selfTest: &nodeSelfTest{available: map[string]bool{"mkfs.ext4": true, "dm_crypt": true}},
+		},
+		{
+			name:     "Missing mkfs for the default file system",
+			selfTest: &nodeSelfTest{available: map[string]bool{"blkid": true, "mkfs.xfs": true, "dm_crypt": true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			linodeDriver := &LinodeDriver{nscap: NodeServiceCapabilities(), ns: &NodeServer{selfTest: tt.selfTest}}
+			linodeDriver.ns.driver = linodeDriver
+			linodeDriver.applyNodeSelfTest(context.Background())
+
+			stage := false
+			for _, c := range linodeDriver.nscap {
+				if c.GetRpc().GetType() == csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME {
+					stage = true
+				}
+			}
+			if stage != tt.wantStage {
+				t.Errorf("STAGE_UNSTAGE_VOLUME advertised = %v, want %v", stage, tt.wantStage)
+			}
+			if got := linodeDriver.ns.canStage(); got != tt.wantStage {
+				t.Errorf("canStage() = %v, want %v", got, tt.wantStage)
+			}
+		})
+	}
+}
+
+func TestNodeServer_mountVolume_missingDependencies(t *testing.T) {
+	tests := []struct {
+		name     string
+		selfTest *nodeSelfTest
+		req      *csi.NodeStageVolumeRequest
+	}{
+		{
+			name:     "Missing blkid",
+			selfTest: &nodeSelfTest{available: map[string]bool{"mkfs.ext4": true, "dm_crypt": true}},
+			req: &csi.NodeStageVolumeRequest{
+				VolumeId: "1003-vol",
+			},
+		},
+		{
+			name:     "Missing mkfs for the file system",
+			selfTest: &nodeSelfTest{available: map[string]bool{"blkid": true, "mkfs.ext4": true, "dm_crypt": true}},
+			req: &csi.NodeStageVolumeRequest{
+				VolumeId:      "1003-vol",
+				VolumeContext: map[string]string{FilesystemTypeAttribute: "xfs"},
+			},
+		},
+		{
+			name:     "Missing dm_crypt for a LUKS volume",
+			selfTest: &nodeSelfTest{available: map[string]bool{"blkid": true, "mkfs.ext4": true}},
+			req: &csi.NodeStageVolumeRequest{
+				VolumeId: "1003-vol",
+				VolumeContext: map[string]string{
+					LuksEncryptedAttribute: True,
+					LuksCipherAttribute:    "aes-xts-plain64",
+					LuksKeySizeAttribute:   "512",
+					PublishInfoVolumeName:  "vol",
+				},
+				Secrets: map[string]string{LuksKeyAttribute: "secret"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			ns := &NodeServer{
+				mounter: &mount.SafeFormatAndMount{
+					Interface: mocks.NewMockMounter(ctrl),
+					Exec:      mocks.NewMockExecutor(ctrl),
+				},
+				selfTest: tt.selfTest,
+			}
+			err := mountVolume(ns, "/dev/sdb", tt.req)
+			if status.Code(err) != codes.FailedPrecondition {
+				t.Errorf("mountVolume() error = %v, want code %v", err, codes.FailedPrecondition)
+			}
+		})
+	}
+}
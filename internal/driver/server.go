@@ -17,16 +17,17 @@ package driver
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	csi "github.com/container-storage-interface/spec/lib/go/csi"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"k8s.io/klog/v2"
@@ -47,6 +48,18 @@ type NonBlockingGRPCServer interface {
 	ForceStop()
 	// Setter to set the observability http server config
 	SetMetricsConfig(enableMetrics, metricsPort string)
+	// Setter to set the maximum durations of the requests, by method name
+	SetRPCTimeouts(timeouts map[string]time.Duration)
+	// Setter to set the interceptor of the requests handled by the
+	// controller server, after the other interceptors
+	SetControllerInterceptor(interceptor grpc.UnaryServerInterceptor)
+}
+
+// debugServer is implemented by the CSI servers exposing their state on the
+// observability server.
+type debugServer interface {
+	// DebugHandlers returns the handlers of the state, by path.
+	DebugHandlers() map[string]http.Handler
 }
 
 func NewNonBlockingGRPCServer() NonBlockingGRPCServer {
@@ -55,13 +68,21 @@ func NewNonBlockingGRPCServer() NonBlockingGRPCServer {
 
 // NonBlocking server
 type nonBlockingGRPCServer struct {
-	wg            sync.WaitGroup
+	wg sync.WaitGroup
+
+	mu            sync.Mutex // protects the servers, set once they start
 	server        *grpc.Server
 	metricsServer *http.Server
 
 	// fields to set up metricsServer
 	enableMetrics string
 	metricsPort   string
+
+	// overrides of the maximum durations of the requests
+	rpcTimeouts map[string]time.Duration
+
+	// interceptor of the requests of the controller server, or nil
+	controllerInterceptor grpc.UnaryServerInterceptor
 }
 
 // SetMetricsConfig sets the enableMetrics and metricsPort fields from environment variables
@@ -70,9 +91,24 @@ func (s *nonBlockingGRPCServer) SetMetricsConfig(enableMetrics, metricsPort stri
 	s.metricsPort = metricsPort
 }
 
+// SetRPCTimeouts sets the maximum durations of the requests overriding the
+// defaults, by method name
+func (s *nonBlockingGRPCServer) SetRPCTimeouts(timeouts map[string]time.Duration) {
+	s.rpcTimeouts = timeouts
+}
+
+// SetControllerInterceptor sets the interceptor of the requests handled by
+// the controller server, called after the other interceptors
+func (s *nonBlockingGRPCServer) SetControllerInterceptor(interceptor grpc.UnaryServerInterceptor) {
+	s.controllerInterceptor = interceptor
+}
+
 func (s *nonBlockingGRPCServer) Start(endpoint string, ids csi.IdentityServer, cs csi.ControllerServer, ns csi.NodeServer) {
 	s.wg.Add(1)
-	go s.serve(endpoint, ids, cs, ns)
+	go func() {
+		defer s.wg.Done()
+		s.serve(endpoint, ids, cs, ns)
+	}()
 
 	// Parse the enableMetrics string into a boolean
 	enableMetrics, err := strconv.ParseBool(s.enableMetrics)
@@ -85,7 +121,8 @@ func (s *nonBlockingGRPCServer) Start(endpoint string, ids csi.IdentityServer, c
 	// Start observability server if enableMetrics is true
 	if enableMetrics {
 		port := ":" + s.metricsPort
-		go s.startMetricsServer(port)
+		s.wg.Add(1)
+		go s.startMetricsServer(port, cs)
 	}
 }
 
@@ -94,10 +131,19 @@ func (s *nonBlockingGRPCServer) Wait() {
 }
 
 func (s *nonBlockingGRPCServer) Stop() {
-	s.server.GracefulStop()
-	err := s.metricsServer.Shutdown(context.Background())
-	if err != nil {
-		klog.Errorf("Failed to stop observability server: %v", err)
+	s.mu.Lock()
+	server, metricsServer := s.server, s.metricsServer
+	s.mu.Unlock()
+
+	// The requests in progress complete, and the unix socket is removed
+	// once its listener is closed
+	if server != nil {
+		server.GracefulStop()
+	}
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(context.Background()); err != nil {
+			klog.Errorf("Failed to stop observability server: %v", err)
+		}
 	}
 
 	if observability.TracerProvider != nil {
@@ -109,9 +155,17 @@ func (s *nonBlockingGRPCServer) Stop() {
 }
 
 func (s *nonBlockingGRPCServer) ForceStop() {
-	s.server.Stop()
-	if err := s.metricsServer.Close(); err != nil {
-		klog.Errorf("Failed to force stop observability server: %v", err)
+	s.mu.Lock()
+	server, metricsServer := s.server, s.metricsServer
+	s.mu.Unlock()
+
+	if server != nil {
+		server.Stop()
+	}
+	if metricsServer != nil {
+		if err := metricsServer.Close(); err != nil {
+			klog.Errorf("Failed to force stop observability server: %v", err)
+		}
 	}
 }
 
@@ -119,40 +173,35 @@ func (s *nonBlockingGRPCServer) serve(endpoint string, ids csi.IdentityServer, c
 	// Create otel gRPC ServerHandler
 	serverHandler := otelgrpc.NewServerHandler()
 
+	interceptors := []grpc.UnaryServerInterceptor{
+		logger.LogGRPC, // Existing logging interceptor
+		observability.UnaryServerInterceptorWithParams(), // This gets params being passed into a grpc func
+		rpcTimeoutInterceptor(s.rpcTimeouts),             // Caps the deadline of the requests
+	}
+	if s.controllerInterceptor != nil && cs != nil {
+		interceptors = append(interceptors, s.controllerInterceptor)
+	}
 	opts := []grpc.ServerOption{
 		grpc.StatsHandler(serverHandler), // Stats handler for otel
-		grpc.ChainUnaryInterceptor(
-			logger.LogGRPC, // Existing logging interceptor
-			observability.UnaryServerInterceptorWithParams(), // This gets params being passed into a grpc func
-		),
-	}
-
-	urlObj, err := url.Parse(endpoint)
-	if err != nil {
-		klog.Fatal(err.Error())
+		grpc.ChainUnaryInterceptor(interceptors...),
 	}
 
-	var addr string
-	switch scheme := urlObj.Scheme; scheme {
-	case "unix":
-		addr = urlObj.Path
-		if err = os.Remove(addr); err != nil && !os.IsNotExist(err) {
-			klog.Fatalf("Failed to remove %s, error: %s", addr, err.Error())
+	// Several endpoints are served to debug the driver, e.g. with csc or
+	// grpcurl, so their tcp endpoints may only be reached from the node
+	endpoints := strings.Split(endpoint, ",")
+	var listeners []net.Listener
+	for _, endpoint := range endpoints {
+		listener, err := listen(strings.TrimSpace(endpoint), len(endpoints) > 1)
+		if err != nil {
+			klog.Fatalf("Failed to listen: %v", err)
 		}
-	case "tcp":
-		addr = urlObj.Host
-	default:
-		klog.Fatalf("%v endpoint scheme not supported", urlObj.Scheme)
-	}
-
-	klog.V(4).Infof("Start listening with scheme %v, addr %v", urlObj.Scheme, addr)
-	listener, err := net.Listen(urlObj.Scheme, addr)
-	if err != nil {
-		klog.Fatalf("Failed to listen: %v", err)
+		listeners = append(listeners, listener)
 	}
 
 	server := grpc.NewServer(opts...)
+	s.mu.Lock()
 	s.server = server
+	s.mu.Unlock()
 
 	if ids != nil {
 		csi.RegisterIdentityServer(server, ids)
@@ -164,22 +213,71 @@ func (s *nonBlockingGRPCServer) serve(endpoint string, ids csi.IdentityServer, c
 		csi.RegisterNodeServer(server, ns)
 	}
 
-	klog.V(4).Infof("Listening for connections on address: %#v", listener.Addr())
+	var wg sync.WaitGroup
+	for _, listener := range listeners {
+		klog.V(4).Infof("Listening for connections on address: %#v", listener.Addr())
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := server.Serve(listener); err != nil {
+				klog.Fatalf("Failed to serve: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// listen listens on endpoint, a unix:// or tcp:// URL. The tcp endpoints must
+// listen on a loopback address if loopbackOnly is set.
+func listen(endpoint string, loopbackOnly bool) (net.Listener, error) {
+	urlObj, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	var addr string
+	switch scheme := urlObj.Scheme; scheme {
+	case "unix":
+		addr = urlObj.Path
+		if err = os.Remove(addr); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove %s: %w", addr, err)
+		}
+	case "tcp":
+		addr = urlObj.Host
+		if loopbackOnly && !isLoopback(urlObj.Hostname()) {
+			return nil, fmt.Errorf("endpoint %s must listen on a loopback address, such as 127.0.0.1", endpoint)
+		}
+	default:
+		return nil, fmt.Errorf("%v endpoint scheme not supported", urlObj.Scheme)
+	}
+
+	klog.V(4).Infof("Start listening with scheme %v, addr %v", urlObj.Scheme, addr)
+	return net.Listen(urlObj.Scheme, addr)
+}
 
-	if err := server.Serve(listener); err != nil {
-		klog.Fatalf("Failed to serve: %v", err)
+// isLoopback reports whether host is localhost or a loopback IP address.
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
 	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
 }
 
-func (s *nonBlockingGRPCServer) startMetricsServer(addr string) {
+func (s *nonBlockingGRPCServer) startMetricsServer(addr string, cs csi.ControllerServer) {
 	defer s.wg.Done()
 
 	mux := http.NewServeMux()
-	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/metrics", observability.MetricsHandler())
+	if debug, ok := cs.(debugServer); ok {
+		for path, handler := range debug.DebugHandlers() {
+			mux.Handle(path, handler)
+		}
+	}
 
 	klog.Infof("Port %v", addr)
 
-	s.metricsServer = &http.Server{
+	metricsServer := &http.Server{
 		Addr:              addr,
 		Handler:           mux,
 		ReadTimeout:       10 * time.Second,
@@ -187,9 +285,12 @@ func (s *nonBlockingGRPCServer) startMetricsServer(addr string) {
 		IdleTimeout:       15 * time.Second,
 		ReadHeaderTimeout: 5 * time.Second,
 	}
+	s.mu.Lock()
+	s.metricsServer = metricsServer
+	s.mu.Unlock()
 
 	klog.V(4).Infof("Starting observability server at %s", addr)
-	if err := s.metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+	if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		klog.Fatalf("Failed to serve observability: %v", err)
 	}
 }
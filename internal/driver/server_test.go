@@ -0,0 +1,54 @@
+package driver
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestListen(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "csi.sock")
+
+	tests := []struct {
+		name         string
+		endpoint     string
+		loopbackOnly bool
+		wantErr      bool
+	}{
+		{name: "Unix socket", endpoint: "unix://" + socket, loopbackOnly: true},
+		{name: "Loopback address", endpoint: "tcp://127.0.0.1:0", loopbackOnly: true},
+		{name: "Localhost", endpoint: "tcp://localhost:0", loopbackOnly: true},
+		{name: "Any address", endpoint: "tcp://0.0.0.0:0"},
+		{name: "Any address restricted to loopback", endpoint: "tcp://0.0.0.0:0", loopbackOnly: true, wantErr: true},
+		{name: "No address restricted to loopback", endpoint: "tcp://:0", loopbackOnly: true, wantErr: true},
+		{name: "Unsupported scheme", endpoint: "http://127.0.0.1:0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener, err := listen(tt.endpoint, tt.loopbackOnly)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("listen(%q) error = %v, wantErr %v", tt.endpoint, err, tt.wantErr)
+			}
+			if listener != nil {
+				listener.Close()
+			}
+		})
+	}
+}
+
+func TestIsLoopback(t *testing.T) {
+	for host, want := range map[string]bool{
+		"localhost":   true,
+		"127.0.0.1":   true,
+		"127.1.2.3":   true,
+		"::1":         true,
+		"":            false,
+		"0.0.0.0":     false,
+		"192.168.0.1": false,
+		"example.com": false,
+	} {
+		if got := isLoopback(host); got != want {
+			t.Errorf("isLoopback(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
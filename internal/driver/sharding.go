@@ -0,0 +1,458 @@
+package driver
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	kubeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/kube-client"
+	linodevolumes "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-volumes"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+// ShardingMode is how the replicas of the controller share the volumes, for
+// clusters with more volume operations than a single controller can handle.
+// Each replica holds a Lease naming it, and the replicas with a live Lease
+// own the volumes by consistent hashing of their IDs, or of the name of the
+// volume for CreateVolume. The RPCs of the volumes a replica does not own
+// are handled as the mode requests.
+//
+// There is no mode failing the RPCs of the volumes of other replicas for
+// their sidecars to handle: the external attacher and provisioner would
+// then run without leader election on every replica, and act on the same
+// VolumeAttachments and PersistentVolumeClaims.
+//
+// Membership changes are only seen when the Leases are renewed, so two
+// replicas may handle the RPCs of the same volume for up to a Lease
+// duration, which the idempotent RPCs allow.
+type ShardingMode string
+
+const (
+	// ShardingOff handles the RPCs of all the volumes. It is the default.
+	ShardingOff ShardingMode = "off"
+
+	// ShardingProxy forwards the RPCs of the volumes owned by other replicas
+	// to them, at the [Options.ShardAddress] of their Lease, over mutual
+	// TLS with the [Options.ShardTLS], and returns their response. It is
+	// meant for replicas whose sidecars run with leader election, so that
+	// the volume operations of the leader are spread across the replicas.
+	ShardingProxy ShardingMode = "proxy"
+)
+
+// ParseShardingMode parses a sharding mode. The empty string is
+// [ShardingOff].
+func ParseShardingMode(s string) (ShardingMode, error) {
+	switch mode := ShardingMode(s); mode {
+	case "":
+		return ShardingOff, nil
+	case ShardingOff, ShardingProxy:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid sharding mode %q, must be %q or %q", s, ShardingOff, ShardingProxy)
+	}
+}
+
+const (
+	// ShardTLSServerName is the DNS name the certificates of the replicas
+	// must be valid for, which the replicas verify instead of the address
+	// of the Lease, a pod IP.
+	ShardTLSServerName = Name
+
+	// shardTokenHeader is the gRPC header of the RPCs forwarded by
+	// [ShardingProxy], with the [Options.ShardToken].
+	shardTokenHeader = "x-linode-csi-shard-token"
+
+	// shardLeaseLabel labels the Leases of the replicas, with the name of
+	// the driver.
+	shardLeaseLabel = Name + "/controller-shard"
+
+	// shardAddressAnnotation is the annotation of the Leases with the
+	// address the replica serves the forwarded RPCs at.
+	shardAddressAnnotation = Name + "/shard-address"
+
+	// defaultShardLeaseDuration is the duration of the Leases when
+	// [Options.ShardLeaseDuration] is not set. They are renewed every third
+	// of it.
+	defaultShardLeaseDuration = 15 * time.Second
+
+	// shardVirtualNodes is the number of points of each replica on the hash
+	// ring, which spread the volumes evenly across the replicas.
+	shardVirtualNodes = 64
+)
+
+// Results of the sharded RPCs, used as the "result" label of the
+// csi_sharded_requests_total metric. The RPCs of the volumes of replicas
+// without an address are rejected.
+const (
+	shardResultLocal    = "local"
+	shardResultProxied  = "proxied"
+	shardResultRejected = "rejected"
+)
+
+// shardResponses creates the responses of the sharded controller methods,
+// which are the ones forwarded by [ShardingProxy].
+var shardResponses = map[string]func() any{
+	csi.Controller_CreateVolume_FullMethodName:               func() any { return &csi.CreateVolumeResponse{} },
+	csi.Controller_DeleteVolume_FullMethodName:               func() any { return &csi.DeleteVolumeResponse{} },
+	csi.Controller_ControllerPublishVolume_FullMethodName:    func() any { return &csi.ControllerPublishVolumeResponse{} },
+	csi.Controller_ControllerUnpublishVolume_FullMethodName:  func() any { return &csi.ControllerUnpublishVolumeResponse{} },
+	csi.Controller_ValidateVolumeCapabilities_FullMethodName: func() any { return &csi.ValidateVolumeCapabilitiesResponse{} },
+	csi.Controller_CreateSnapshot_FullMethodName:             func() any { return &csi.CreateSnapshotResponse{} },
+	csi.Controller_ControllerExpandVolume_FullMethodName:     func() any { return &csi.ControllerExpandVolumeResponse{} },
+	csi.Controller_ControllerGetVolume_FullMethodName:        func() any { return &csi.ControllerGetVolumeResponse{} },
+	csi.Controller_ControllerModifyVolume_FullMethodName:     func() any { return &csi.ControllerModifyVolumeResponse{} },
+}
+
+// shardKey returns the key the volume of req is sharded by: the ID of the
+// volume, or its name for CreateVolume. ok is false if req is not sharded,
+// e.g. ListVolumes, or has no valid volume ID.
+func shardKey(req any) (key string, ok bool) {
+	var volumeID string
+	switch r := req.(type) {
+	case *csi.CreateVolumeRequest:
+		if r.GetName() == "" {
+			return "", false
+		}
+		return "name/" + r.GetName(), true
+	case *csi.CreateSnapshotRequest:
+		volumeID = r.GetSourceVolumeId()
+	case interface{ GetVolumeId() string }:
+		volumeID = r.GetVolumeId()
+	default:
+		return "", false
+	}
+	volumeKey, err := linodevolumes.ParseLinodeVolumeKey(volumeID)
+	if err != nil {
+		return "", false
+	}
+	return "volume/" + strconv.Itoa(volumeKey.VolumeID), true
+}
+
+// shardMember is a replica of the controller.
+type shardMember struct {
+	identity string
+	address  string
+}
+
+// shardRing is a consistent hash ring of the replicas, which moves only the
+// volumes of the replicas joining or leaving.
+type shardRing struct {
+	points  []uint64
+	members []shardMember // by point
+}
+
+func shardHash(s string) uint64 {
+	sum := sha256.Sum256([]byte(s))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+func newShardRing(members []shardMember) *shardRing {
+	type point struct {
+		hash   uint64
+		member shardMember
+	}
+	points := make([]point, 0, len(members)*shardVirtualNodes)
+	for _, member := range members {
+		for i := range shardVirtualNodes {
+			points = append(points, point{hash: shardHash(member.identity + "#" + strconv.Itoa(i)), member: member})
+		}
+	}
+	slices.SortFunc(points, func(a, b point) int {
+		if a.hash != b.hash {
+			return cmp.Compare(a.hash, b.hash)
+		}
+		return strings.Compare(a.member.identity, b.member.identity)
+	})
+
+	ring := &shardRing{points: make([]uint64, len(points)), members: make([]shardMember, len(points))}
+	for i, p := range points {
+		ring.points[i], ring.members[i] = p.hash, p.member
+	}
+	return ring
+}
+
+// owner returns the replica owning key, the first one clockwise of its hash
+// on the ring.
+func (r *shardRing) owner(key string) shardMember {
+	i, _ := slices.BinarySearch(r.points, shardHash(key))
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.members[i]
+}
+
+// volumeShards shares the volumes between the replicas of the controller as
+// the [ShardingMode] requests.
+type volumeShards struct {
+	kube      kubeclient.KubeClient
+	namespace string
+	driver    string
+	mode      ShardingMode
+	self      shardMember
+	duration  time.Duration
+	token     string
+	tls       *tls.Config
+
+	mu      sync.RWMutex // protects members and ring
+	members []shardMember
+	ring    *shardRing
+
+	connsMu sync.Mutex // protects conns
+	conns   map[string]*grpc.ClientConn
+}
+
+func newVolumeShards(kube kubeclient.KubeClient, driver string, opts Options) *volumeShards {
+	duration := opts.ShardLeaseDuration
+	if duration <= 0 {
+		duration = defaultShardLeaseDuration
+	}
+	self := shardMember{identity: opts.ShardIdentity, address: opts.ShardAddress}
+	return &volumeShards{
+		kube:      kube,
+		namespace: opts.ShardNamespace,
+		driver:    driver,
+		mode:      opts.Sharding,
+		self:      self,
+		duration:  duration,
+		token:     opts.ShardToken,
+		tls:       opts.ShardTLS,
+		// Until the Leases are listed, the replica owns all the volumes
+		members: []shardMember{self},
+		ring:    newShardRing([]shardMember{self}),
+		conns:   make(map[string]*grpc.ClientConn),
+	}
+}
+
+// leaseName returns the name of the Lease of the replica named identity.
+func (s *volumeShards) leaseName(identity string) string {
+	return s.driver + "-shard-" + identity
+}
+
+// run renews the Lease of the replica and refreshes the replicas owning the
+// volumes every third of the Lease duration, until ctx is canceled. In
+// [ShardingProxy] mode, it also serves the RPCs forwarded by the other
+// replicas with cs.
+func (s *volumeShards) run(ctx context.Context, cs csi.ControllerServer) {
+	log := logger.GetLogger(ctx)
+	if s.mode == ShardingProxy {
+		go s.serve(ctx, cs)
+	}
+
+	ticker := time.NewTicker(s.duration / 3)
+	defer ticker.Stop()
+	for {
+		if err := s.refresh(ctx); err != nil {
+			log.Error(err, "Failed to refresh the controller shards")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh renews the Lease of the replica, and rebuilds the ring from the
+// live Leases of the replicas.
+func (s *volumeShards) refresh(ctx context.Context) error {
+	now := time.Now()
+	err := s.kube.ApplyLease(ctx, s.namespace, kubeclient.Lease{
+		Name:           s.leaseName(s.self.identity),
+		Labels:         map[string]string{shardLeaseLabel: s.driver},
+		Annotations:    map[string]string{shardAddressAnnotation: s.self.address},
+		HolderIdentity: s.self.identity,
+		RenewTime:      now,
+		Duration:       s.duration,
+	})
+	if err != nil {
+		return err
+	}
+	leases, err := s.kube.ListLeases(ctx, s.namespace, shardLeaseLabel+"="+s.driver)
+	if err != nil {
+		return err
+	}
+
+	members := []shardMember{s.self}
+	for _, lease := range leases {
+		if lease.HolderIdentity == "" || lease.HolderIdentity == s.self.identity || lease.Expired(now) {
+			continue
+		}
+		members = append(members, shardMember{identity: lease.HolderIdentity, address: lease.Annotations[shardAddressAnnotation]})
+	}
+	slices.SortFunc(members, func(a, b shardMember) int { return strings.Compare(a.identity, b.identity) })
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !slices.Equal(members, s.members) {
+		logger.GetLogger(ctx).V(2).Info("Controller shards changed", "replicas", len(members))
+		s.members, s.ring = members, newShardRing(members)
+	}
+	return nil
+}
+
+// owner returns the replica owning key, and whether it is this replica.
+func (s *volumeShards) owner(key string) (shardMember, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	owner := s.ring.owner(key)
+	return owner, owner.identity == s.self.identity
+}
+
+// interceptor handles the controller RPCs of the volumes owned by other
+// replicas as the [ShardingMode] requests.
+func (s *volumeShards) interceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	newResponse, sharded := shardResponses[info.FullMethod]
+	if !sharded {
+		return handler(ctx, req)
+	}
+	key, ok := shardKey(req)
+	if !ok {
+		return handler(ctx, req)
+	}
+	method := info.FullMethod[strings.LastIndex(info.FullMethod, "/")+1:]
+	owner, self := s.owner(key)
+	if self {
+		observability.ShardedRequestsTotal.WithLabelValues(method, shardResultLocal).Inc()
+		return handler(ctx, req)
+	}
+
+	log := logger.GetLogger(ctx)
+	if s.mode == ShardingProxy && owner.address != "" {
+		log.V(4).Info("Forwarding the request to the controller replica owning the volume", "key", key, "owner", owner.identity, "address", owner.address)
+		resp := newResponse()
+		if err := s.forward(ctx, owner, info.FullMethod, req, resp); err != nil {
+			return nil, err
+		}
+		observability.ShardedRequestsTotal.WithLabelValues(method, shardResultProxied).Inc()
+		return resp, nil
+	}
+
+	log.V(4).Info("Rejecting the request of a volume owned by a controller replica without an address", "key", key, "owner", owner.identity)
+	observability.ShardedRequestsTotal.WithLabelValues(method, shardResultRejected).Inc()
+	return nil, status.Errorf(codes.Unavailable, "%s is owned by controller replica %s, which has no address", key, owner.identity)
+}
+
+// forward sends the RPC method of req to owner, and decodes its response
+// into resp. The errors of the owner are returned as is. RPCs are never
+// forwarded without TLS, since they carry the secrets of the volumes and the
+// [Options.ShardToken].
+func (s *volumeShards) forward(ctx context.Context, owner shardMember, method string, req, resp any) error {
+	if s.tls == nil {
+		return status.Errorf(codes.Unavailable, "not forwarding the request to controller replica %s without TLS", owner.identity)
+	}
+	conn, err := s.conn(owner.address)
+	if err != nil {
+		return status.Errorf(codes.Unavailable, "connect to controller replica %s: %v", owner.identity, err)
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, shardTokenHeader, s.token)
+	return conn.Invoke(ctx, method, req, resp)
+}
+
+// conn returns the connection to the replica at address, created on first
+// use.
+func (s *volumeShards) conn(address string) (*grpc.ClientConn, error) {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+	if conn, ok := s.conns[address]; ok {
+		return conn, nil
+	}
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(credentials.NewTLS(s.tls)))
+	if err != nil {
+		return nil, err
+	}
+	s.conns[address] = conn
+	return conn, nil
+}
+
+// authenticate rejects the forwarded RPCs without the [Options.ShardToken].
+func (s *volumeShards) authenticate(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	tokens := md.Get(shardTokenHeader)
+	if len(tokens) != 1 || subtle.ConstantTimeCompare([]byte(tokens[0]), []byte(s.token)) != 1 {
+		return nil, status.Error(codes.Unauthenticated, "invalid shard token")
+	}
+	return handler(ctx, req)
+}
+
+// serve serves the RPCs forwarded by the other replicas with cs on the port
+// of the [Options.ShardAddress], over mutual TLS, until ctx is canceled. They are handled by
+// this replica whichever replica it sees owning the volume, so that
+// replicas disagreeing on the owners never forward RPCs in circles.
+func (s *volumeShards) serve(ctx context.Context, cs csi.ControllerServer) {
+	log := logger.GetLogger(ctx)
+	if s.tls == nil {
+		log.Error(nil, "No shard TLS configuration, not serving forwarded requests")
+		return
+	}
+	_, port, err := net.SplitHostPort(s.self.address)
+	if err != nil {
+		log.Error(err, "Invalid shard address, not serving forwarded requests", "address", s.self.address)
+		return
+	}
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		log.Error(err, "Failed to listen for forwarded requests", "port", port)
+		return
+	}
+
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(s.tls)), grpc.ChainUnaryInterceptor(logger.LogGRPC, s.authenticate))
+	csi.RegisterControllerServer(server, cs)
+	go func() {
+		<-ctx.Done()
+		server.GracefulStop()
+	}()
+	log.V(2).Info("Serving the requests forwarded by the other controller replicas", "address", listener.Addr().String())
+	if err := server.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		log.Error(err, "Failed to serve forwarded requests")
+	}
+}
+
+// LoadShardTLS returns the TLS configuration of a replica in [ShardingProxy]
+// mode, from the PEM files of its certificate and key, valid for
+// [ShardTLSServerName] as a server and as a client, and of the CA the
+// certificates of all the replicas are signed by. The replicas share them,
+// e.g. from a kubernetes.io/tls Secret with a ca.crt key.
+func LoadShardTLS(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(filepath.Clean(certFile), filepath.Clean(keyFile))
+	if err != nil {
+		return nil, fmt.Errorf("load shard certificate: %w", err)
+	}
+	ca, err := os.ReadFile(filepath.Clean(caFile))
+	if err != nil {
+		return nil, fmt.Errorf("read shard CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificate found in %s", caFile)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ServerName:   ShardTLSServerName,
+		MinVersion:   tls.VersionTLS13,
+	}, nil
+}
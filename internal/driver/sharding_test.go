@@ -0,0 +1,279 @@
+package driver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+	kubeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/kube-client"
+)
+
+func TestParseShardingMode(t *testing.T) {
+	for s, want := range map[string]ShardingMode{
+		"":      ShardingOff,
+		"off":   ShardingOff,
+		"proxy": ShardingProxy,
+	} {
+		if got, err := ParseShardingMode(s); err != nil || got != want {
+			t.Errorf("ParseShardingMode(%q) = %q, %v, want %q", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"hash", "reject"} {
+		if _, err := ParseShardingMode(s); err == nil {
+			t.Errorf("ParseShardingMode(%q) succeeded, want error", s)
+		}
+	}
+}
+
+func TestShardKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     any
+		wantKey string
+		wantOK  bool
+	}{
+		{name: "CreateVolume", req: &csi.CreateVolumeRequest{Name: "pvc-1"}, wantKey: "name/pvc-1", wantOK: true},
+		{name: "CreateVolume without a name", req: &csi.CreateVolumeRequest{}},
+		{name: "DeleteVolume", req: &csi.DeleteVolumeRequest{VolumeId: "1001-pvc1"}, wantKey: "volume/1001", wantOK: true},
+		{name: "ControllerPublishVolume", req: &csi.ControllerPublishVolumeRequest{VolumeId: "1001-pvc1", NodeId: "12345"}, wantKey: "volume/1001", wantOK: true},
+		{name: "CreateSnapshot", req: &csi.CreateSnapshotRequest{SourceVolumeId: "1005-pvc5"}, wantKey: "volume/1005", wantOK: true},
+		{name: "Invalid volume ID", req: &csi.DeleteVolumeRequest{VolumeId: "pvc1"}},
+		{name: "ListVolumes", req: &csi.ListVolumesRequest{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, ok := shardKey(tt.req)
+			if key != tt.wantKey || ok != tt.wantOK {
+				t.Errorf("shardKey() = %q, %v, want %q, %v", key, ok, tt.wantKey, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestShardRing(t *testing.T) {
+	members := []shardMember{{identity: "controller-0"}, {identity: "controller-1"}, {identity: "controller-2"}}
+	ring := newShardRing(members)
+	shrunk := newShardRing(members[:2])
+
+	owned := make(map[string]int)
+	for i := range 3000 {
+		key := fmt.Sprintf("volume/%d", i)
+		owner := ring.owner(key)
+		owned[owner.identity]++
+
+		// Only the volumes of the replica leaving move
+		if owner.identity != "controller-2" && shrunk.owner(key) != owner {
+			t.Errorf("%s moved from %s to %s", key, owner.identity, shrunk.owner(key).identity)
+		}
+	}
+	for _, member := range members {
+		if n := owned[member.identity]; n < 700 || n > 1300 {
+			t.Errorf("%s owns %d of 3000 volumes, want about 1000", member.identity, n)
+		}
+	}
+}
+
+func TestVolumeShardsRefresh(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Now()
+	mockKube := mocks.NewMockKubeClient(ctrl)
+	mockKube.EXPECT().ApplyLease(gomock.Any(), "kube-system", gomock.Any()).DoAndReturn(func(_ context.Context, _ string, lease kubeclient.Lease) error {
+		if lease.Name != Name+"-shard-controller-0" || lease.HolderIdentity != "controller-0" || lease.Annotations[shardAddressAnnotation] != "10.0.0.1:10010" {
+			t.Errorf("lease = %+v, want the lease of controller-0", lease)
+		}
+		return nil
+	})
+	mockKube.EXPECT().ListLeases(gomock.Any(), "kube-system", shardLeaseLabel+"="+Name).Return([]kubeclient.Lease{
+		{HolderIdentity: "controller-0", RenewTime: now, Duration: time.Minute},
+		{HolderIdentity: "controller-1", RenewTime: now, Duration: time.Minute, Annotations: map[string]string{shardAddressAnnotation: "10.0.0.2:10010"}},
+		{HolderIdentity: "controller-2", RenewTime: now.Add(-2 * time.Minute), Duration: time.Minute},
+	}, nil)
+
+	s := newVolumeShards(mockKube, Name, Options{
+		Sharding:       ShardingProxy,
+		ShardNamespace: "kube-system",
+		ShardIdentity:  "controller-0",
+		ShardAddress:   "10.0.0.1:10010",
+	})
+	if _, self := s.owner("volume/1001"); !self {
+		t.Error("owner() = another replica before the refresh, want this replica")
+	}
+	if err := s.refresh(context.Background()); err != nil {
+		t.Fatalf("refresh() error = %v", err)
+	}
+
+	want := []shardMember{{identity: "controller-0", address: "10.0.0.1:10010"}, {identity: "controller-1", address: "10.0.0.2:10010"}}
+	if fmt.Sprint(s.members) != fmt.Sprint(want) {
+		t.Errorf("members = %v, want %v without the expired lease", s.members, want)
+	}
+}
+
+// shardTestServer deletes the volumes, as the replica owning them.
+type shardTestServer struct {
+	csi.UnimplementedControllerServer
+	deleted []string
+}
+
+func (s *shardTestServer) DeleteVolume(_ context.Context, req *csi.DeleteVolumeRequest) (*csi.DeleteVolumeResponse, error) {
+	s.deleted = append(s.deleted, req.GetVolumeId())
+	return &csi.DeleteVolumeResponse{}, nil
+}
+
+func TestVolumeShardsInterceptor(t *testing.T) {
+	// The owner serves the forwarded requests
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ownerTLS := newShardTestTLS(t, "ca")
+	otherTLS := newShardTestTLS(t, "other-ca")
+	owner := &volumeShards{token: "secret"}
+	ownerServer := &shardTestServer{}
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(ownerTLS)), grpc.UnaryInterceptor(owner.authenticate))
+	csi.RegisterControllerServer(server, ownerServer)
+	go func() { _ = server.Serve(listener) }()
+	defer server.Stop()
+
+	info := &grpc.UnaryServerInfo{FullMethod: csi.Controller_DeleteVolume_FullMethodName}
+	local := func(context.Context, any) (any, error) {
+		return nil, status.Error(codes.Internal, "handled locally")
+	}
+	members := []shardMember{{identity: "controller-0"}, {identity: "controller-1", address: listener.Addr().String()}}
+	tests := []struct {
+		name        string
+		token       string
+		tls         *tls.Config
+		noAddress   bool
+		volumeID    string
+		wantCode    codes.Code
+		wantDeleted bool
+	}{
+		{name: "Owned", token: "secret", tls: ownerTLS, volumeID: "1001-pvc1", wantCode: codes.Internal},
+		{name: "Proxied", token: "secret", tls: ownerTLS, volumeID: "1005-pvc5", wantCode: codes.OK, wantDeleted: true},
+		{name: "Proxied with a wrong token", token: "wrong", tls: ownerTLS, volumeID: "1005-pvc5", wantCode: codes.Unauthenticated},
+		{name: "Not proxied without TLS", token: "secret", volumeID: "1005-pvc5", wantCode: codes.Unavailable},
+		{name: "Not proxied with an untrusted certificate", token: "secret", tls: otherTLS, volumeID: "1005-pvc5", wantCode: codes.Unavailable},
+		{name: "Rejected without the address of the owner", token: "secret", tls: ownerTLS, noAddress: true, volumeID: "1005-pvc5", wantCode: codes.Unavailable},
+		{name: "Not sharded", token: "secret", tls: ownerTLS, volumeID: "pvc5", wantCode: codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ownerServer.deleted = nil
+			members := members
+			if tt.noAddress {
+				members = []shardMember{members[0], {identity: members[1].identity}}
+			}
+			s := &volumeShards{
+				mode:    ShardingProxy,
+				self:    members[0],
+				token:   tt.token,
+				tls:     tt.tls,
+				members: members,
+				ring:    newShardRing(members),
+				conns:   make(map[string]*grpc.ClientConn),
+			}
+			// The test volumes are owned by the expected replicas
+			if _, self := s.owner("volume/1001"); !self {
+				t.Fatal("volume 1001 is owned by controller-1, want controller-0")
+			}
+			if _, self := s.owner("volume/1005"); self {
+				t.Fatal("volume 1005 is owned by controller-0, want controller-1")
+			}
+
+			_, err := s.interceptor(context.Background(), &csi.DeleteVolumeRequest{VolumeId: tt.volumeID}, info, local)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("interceptor() error = %v, want code %v", err, tt.wantCode)
+			}
+			if deleted := len(ownerServer.deleted) > 0; deleted != tt.wantDeleted {
+				t.Errorf("deleted by the owner = %v, want %v", deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
+
+// newShardTestTLS returns the TLS configuration of a replica, with a
+// certificate signed by a new CA named caName, loaded from files.
+func newShardTestTLS(t *testing.T, caName string) *tls.Config {
+	t.Helper()
+	dir := t.TempDir()
+	writePEM := func(name, blockType string, der []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: caName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: ShardTLSServerName},
+		DNSNames:     []string{ShardTLSServerName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadShardTLS(writePEM("tls.crt", "CERTIFICATE", der), writePEM("tls.key", "EC PRIVATE KEY", keyDER), writePEM("ca.crt", "CERTIFICATE", caDER))
+	if err != nil {
+		t.Fatalf("LoadShardTLS() error = %v", err)
+	}
+	return config
+}
@@ -0,0 +1,30 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+// shutdown stops s once the driver is terminated. The node plugin first
+// removes the registration socket of node-driver-registrar, if
+// [Options.RegistrationSocket] is set, so that the kubelet deregisters the
+// driver and stops calling it, instead of calling its socket until the
+// plugin replacing it registers, e.g. during a rolling update of the node
+// DaemonSet. The requests in progress then complete before s stops.
+func (linodeDriver *LinodeDriver) shutdown(ctx context.Context, s NonBlockingGRPCServer) {
+	log := logger.GetLogger(ctx)
+
+	if socket := linodeDriver.opts.RegistrationSocket; socket != "" {
+		if err := os.Remove(socket); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Error(err, "Failed to deregister the node plugin from the kubelet", "socket", socket)
+		} else {
+			log.V(2).Info("Deregistered the node plugin from the kubelet", "socket", socket)
+		}
+	}
+
+	log.V(2).Info("Stopping GRPC server once the requests in progress complete")
+	s.Stop()
+}
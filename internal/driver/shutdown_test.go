@@ -0,0 +1,54 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// stopRecorder is a NonBlockingGRPCServer recording whether it was stopped.
+type stopRecorder struct {
+	NonBlockingGRPCServer
+	socket             string
+	stopped            bool
+	socketRemovedFirst bool
+}
+
+func (s *stopRecorder) Stop() {
+	_, err := os.Stat(s.socket)
+	s.socketRemovedFirst = errors.Is(err, os.ErrNotExist)
+	s.stopped = true
+}
+
+func TestShutdown(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "linodebs.csi.linode.com-reg.sock")
+	if err := os.WriteFile(socket, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	linodeDriver := &LinodeDriver{opts: Options{RegistrationSocket: socket}}
+	s := &stopRecorder{socket: socket}
+	linodeDriver.shutdown(context.Background(), s)
+	if !s.stopped {
+		t.Error("shutdown() did not stop the server")
+	}
+	if !s.socketRemovedFirst {
+		t.Error("shutdown() did not remove the registration socket before stopping the server")
+	}
+
+	// The socket may already be gone, e.g. if the registrar was restarted
+	s = &stopRecorder{socket: socket}
+	linodeDriver.shutdown(context.Background(), s)
+	if !s.stopped {
+		t.Error("shutdown() did not stop the server without a registration socket")
+	}
+}
+
+func TestStopNotStarted(t *testing.T) {
+	s := NewNonBlockingGRPCServer()
+	// Terminated before serving, e.g. while the driver set up
+	s.Stop()
+	s.ForceStop()
+}
@@ -0,0 +1,123 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"k8s.io/mount-utils"
+	utilexec "k8s.io/utils/exec"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+// signatureCacheTTL is how long the file system signature found on a device
+// is used without probing the device again.
+const signatureCacheTTL = 30 * time.Second
+
+// signatureCache holds the file system signatures probed with blkid on the
+// devices of the volumes being staged, so that the steps of NodeStageVolume,
+// and the requests retried shortly after, do not run it again. Only the
+// devices holding a file system are cached: a blank device is always probed
+// before it is formatted, so that nothing is formatted over.
+//
+// The zero value is ready to use.
+type signatureCache struct {
+	mu      sync.Mutex
+	entries map[string]signatureEntry // By device path
+}
+
+type signatureEntry struct {
+	format string
+	probed time.Time
+}
+
+// get returns the signature of the device at source, if it was probed less
+// than [signatureCacheTTL] ago.
+func (c *signatureCache) get(source string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[source]
+	if !ok || time.Since(entry.probed) > signatureCacheTTL {
+		return "", false
+	}
+	return entry.format, true
+}
+
+// set records the signature of the device at source, forgetting it if it is
+// blank.
+func (c *signatureCache) set(source, format string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for path, entry := range c.entries {
+		if now.Sub(entry.probed) > signatureCacheTTL {
+			delete(c.entries, path)
+		}
+	}
+	if format == "" {
+		delete(c.entries, source)
+		return
+	}
+	if c.entries == nil {
+		c.entries = make(map[string]signatureEntry)
+	}
+	c.entries[source] = signatureEntry{format: format, probed: now}
+}
+
+// forget drops the signature of the device at source, which must be probed
+// again.
+func (c *signatureCache) forget(source string) {
+	c.set(source, "")
+}
+
+// diskFormat returns the file system signature of the device at source, as
+// [mount.SafeFormatAndMount.GetDiskFormat] does, from the cache if it was
+// probed recently.
+func (ns *NodeServer) diskFormat(ctx context.Context, source string) (string, error) {
+	if format, ok := ns.signatures.get(source); ok {
+		logger.GetLogger(ctx).V(4).Info("Using cached file system signature", "source", source, "format", format)
+		return format, nil
+	}
+	format, err := ns.mounter.GetDiskFormat(source)
+	if err != nil {
+		return "", err
+	}
+	ns.signatures.set(source, format)
+	return format, nil
+}
+
+// mountFormatted mounts the device at source, known to hold a fsType file
+// system, to target with options. It does what FormatAndMount does with a
+// formatted device, without probing it again: the file system is checked
+// and repaired with fsck, unless it is mounted read-only.
+func (ns *NodeServer) mountFormatted(ctx context.Context, source, target, fsType string, options []string) error {
+	log := logger.GetLogger(ctx)
+	log.V(4).Info("Mounting formatted device", "source", source, "fsType", fsType)
+
+	if !slices.Contains(options, "ro") {
+		out, err := ns.mounter.Exec.Command("fsck", "-a", source).CombinedOutput()
+		var exitErr utilexec.ExitError
+		switch {
+		case err == nil:
+		case errors.Is(err, utilexec.ErrExecutableNotFound):
+			log.V(0).Info("fsck not found, mounting without checking the file system", "source", source)
+		case errors.As(err, &exitErr) && exitErr.ExitStatus() == e2fsckErrorsCorrected:
+			log.V(2).Info("fsck corrected errors", "source", source)
+		case errors.As(err, &exitErr) && exitErr.ExitStatus() == e2fsckErrorsUncorrected:
+			return mount.NewMountError(mount.HasFilesystemErrors, "'fsck' found errors on device %s but could not correct them: %s", source, string(out))
+		default:
+			log.V(0).Info("fsck failed, mounting anyway", "source", source, "error", err.Error(), "output", string(out))
+		}
+	}
+
+	options = append(slices.Clone(options), "defaults")
+	if err := ns.mounter.MountSensitive(source, target, fsType, options, nil); err != nil {
+		return mount.NewMountError(mount.UnknownMountError, "%s", err.Error())
+	}
+	return nil
+}
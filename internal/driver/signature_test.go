@@ -0,0 +1,92 @@
+package driver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/mock/gomock"
+	"k8s.io/mount-utils"
+	"k8s.io/utils/exec"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+)
+
+func TestNodeServer_diskFormat(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Blank devices are probed every time, formatted ones once
+	mockExec := mocks.NewMockExecutor(ctrl)
+	blank := mocks.NewMockCommand(ctrl)
+	formatted := mocks.NewMockCommand(ctrl)
+	mockExec.EXPECT().Command("blkid", "-p", "-s", "TYPE", "-s", "PTTYPE", "-o", "export", "/dev/sdb").Return(blank).Times(2)
+	blank.EXPECT().CombinedOutput().Return(nil, exec.CodeExitError{Code: 2}).Times(2)
+	mockExec.EXPECT().Command("blkid", "-p", "-s", "TYPE", "-s", "PTTYPE", "-o", "export", "/dev/sdc").Return(formatted)
+	formatted.EXPECT().CombinedOutput().Return([]byte("DEVNAME=/dev/sdc\nTYPE=xfs\n"), nil)
+
+	ns := &NodeServer{mounter: &mount.SafeFormatAndMount{Interface: mocks.NewMockMounter(ctrl), Exec: mockExec}}
+	ctx := context.Background()
+	for _, tt := range []struct{ source, want string }{
+		{source: "/dev/sdb", want: ""},
+		{source: "/dev/sdb", want: ""},
+		{source: "/dev/sdc", want: "xfs"},
+		{source: "/dev/sdc", want: "xfs"},
+	} {
+		if format, err := ns.diskFormat(ctx, tt.source); err != nil || format != tt.want {
+			t.Errorf("diskFormat(%s) = %q, %v, want %q", tt.source, format, err, tt.want)
+		}
+	}
+
+	// Signatures expire
+	ns.signatures.entries["/dev/sdc"] = signatureEntry{format: "xfs", probed: time.Now().Add(-2 * signatureCacheTTL)}
+	if _, ok := ns.signatures.get("/dev/sdc"); ok {
+		t.Error("get() returned an expired signature")
+	}
+	ns.signatures.set("/dev/sdd", "ext4")
+	if _, ok := ns.signatures.entries["/dev/sdc"]; ok {
+		t.Error("set() did not prune the expired signature")
+	}
+	ns.signatures.forget("/dev/sdd")
+	if _, ok := ns.signatures.get("/dev/sdd"); ok {
+		t.Error("get() returned a forgotten signature")
+	}
+}
+
+func TestNodeServer_mountFormatted(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  []string
+		fsckErr  error
+		wantFsck bool
+		wantErr  bool
+	}{
+		{name: "Clean file system", wantFsck: true},
+		{name: "Corrected errors", fsckErr: exec.CodeExitError{Code: e2fsckErrorsCorrected}, wantFsck: true},
+		{name: "Uncorrected errors", fsckErr: exec.CodeExitError{Code: e2fsckErrorsUncorrected}, wantFsck: true, wantErr: true},
+		{name: "Read-only", options: []string{"ro"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockExec := mocks.NewMockExecutor(ctrl)
+			mockMounter := mocks.NewMockMounter(ctrl)
+			if tt.wantFsck {
+				fsck := mocks.NewMockCommand(ctrl)
+				mockExec.EXPECT().Command("fsck", "-a", "/dev/sdb").Return(fsck)
+				fsck.EXPECT().CombinedOutput().Return(nil, tt.fsckErr)
+			}
+			if !tt.wantErr {
+				mockMounter.EXPECT().MountSensitive("/dev/sdb", "/staging", "ext4", append(tt.options, "defaults"), nil).Return(nil)
+			}
+
+			ns := &NodeServer{mounter: &mount.SafeFormatAndMount{Interface: mockMounter, Exec: mockExec}}
+			if err := ns.mountFormatted(context.Background(), "/dev/sdb", "/staging", "ext4", tt.options); (err != nil) != tt.wantErr {
+				t.Errorf("mountFormatted() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
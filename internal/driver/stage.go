@@ -0,0 +1,527 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"k8s.io/mount-utils"
+
+	filesystem "github.com/linode/linode-blockstorage-csi-driver/pkg/filesystem"
+	linodevolumes "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-volumes"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+// Steps of NodeStageVolume, in the order they run.
+const (
+	stageStepDiscover = "discover"
+	stageStepTune     = "tune"
+	stageStepOpenLUKS = "open-luks"
+	stageStepFormat   = "format"
+	stageStepMount    = "mount"
+	stageStepResize   = "resize"
+)
+
+// stageMarkerSuffix is appended to the staging target path of a volume to
+// get the path of its [stageMarker].
+const stageMarkerSuffix = ".stage"
+
+// stageState is what the steps of NodeStageVolume pass on to the following
+// ones.
+type stageState struct {
+	req  *csi.NodeStageVolumeRequest
+	luks LuksContext
+
+	// fsType and mountOptions are the file system and mount options of
+	// volumes staged as file systems.
+	fsType       string
+	mountOptions []string
+
+	// readOnly is true if the file system is mounted read-only, in which
+	// case nothing is written to the device.
+	readOnly bool
+
+	// verifyClone is true if the contents of the volume must be verified
+	// before it is mounted.
+	verifyClone bool
+
+	// devicePath is the device of the volume, and source the one holding
+	// its file system: devicePath, or the LUKS device opened on it.
+	devicePath string
+	source     string
+
+	// formatted is true if the file system was created by this request,
+	// so that it already has the size of its device.
+	formatted bool
+
+	// format is the file system signature found on source, or created on
+	// it, by the format step. The device is mounted without being probed
+	// again when it is fsType.
+	format string
+
+	// releaseDevice releases devicePath, held by the discover step for the
+	// following steps, so that the operations of other requests on the
+	// device do not run between them.
+	releaseDevice func()
+}
+
+// stageStep is one step of NodeStageVolume. Steps are idempotent, so that a
+// retried request can run the step that failed again.
+type stageStep struct {
+	name string
+
+	// run performs the step.
+	run func(ns *NodeServer, ctx context.Context, st *stageState) error
+
+	// resume restores the state the following steps need, when a retried
+	// request skips the step because it was completed. It fails if the
+	// step must run again, e.g. because the node rebooted since. Steps
+	// without it always run.
+	resume func(ns *NodeServer, ctx context.Context, st *stageState) error
+}
+
+// filesystemStageSteps stage volumes mounted as file systems.
+var filesystemStageSteps = []stageStep{
+	{name: stageStepDiscover, run: (*NodeServer).discoverStageDevice},
+	{name: stageStepTune, run: (*NodeServer).tuneStageDevice},
+	{name: stageStepOpenLUKS, run: (*NodeServer).openStageLUKS, resume: (*NodeServer).resumeStageLUKS},
+	{name: stageStepFormat, run: (*NodeServer).formatStageDevice, resume: (*NodeServer).resumeStageFormat},
+	{name: stageStepMount, run: (*NodeServer).mountStageDevice, resume: (*NodeServer).resumeStageMount},
+	{name: stageStepResize, run: (*NodeServer).resizeStageFilesystem},
+}
+
+// blockStageSteps stage raw block volumes, which are bind mounted to their
+// target path by NodePublishVolume.
+var blockStageSteps = []stageStep{
+	{name: stageStepDiscover, run: (*NodeServer).discoverStageDevice},
+	{name: stageStepTune, run: (*NodeServer).tuneStageDevice},
+	{name: stageStepOpenLUKS, run: (*NodeServer).openStageLUKSBlock},
+}
+
+// stageSteps returns the steps staging the volume of req.
+func stageSteps(req *csi.NodeStageVolumeRequest) []stageStep {
+	if req.GetVolumeCapability().GetBlock() != nil {
+		return blockStageSteps
+	}
+	return filesystemStageSteps
+}
+
+// newStageState returns the state of the steps staging the volume of req.
+// It fails early if the tools needed to stage the volume are missing.
+func (ns *NodeServer) newStageState(ctx context.Context, req *csi.NodeStageVolumeRequest) (*stageState, error) {
+	st := &stageState{
+		req:  req,
+		luks: getLuksContext(req.GetSecrets(), req.GetVolumeContext(), VolumeLifecycleNodeStageVolume),
+	}
+	if req.GetVolumeCapability().GetBlock() != nil {
+		return st, nil
+	}
+
+	// Retrieve the file system type and mount options from the volume capability
+	st.fsType, st.mountOptions = getFSTypeAndMountOptions(ctx, req.GetVolumeCapability(), req.GetVolumeContext(), ns.driverFSType(), ns.driverMountOptions())
+
+	// Refuse early when the tools needed to format the volume are missing
+	var missing error
+	switch {
+	case !ns.selfTest.has(blkidDependency):
+		missing = errMissingNodeDependencies("volume staging", blkidDependency)
+	case !ns.selfTest.supportsFSType(st.fsType):
+		missing = errMissingNodeDependencies("file system "+st.fsType, mkfsDependency(st.fsType))
+	case st.luks.EncryptionEnabled && !ns.selfTest.supportsEncryption():
+		missing = errMissingNodeDependencies("LUKS encryption", dmCryptDependency)
+	}
+	if err := ns.driver.enforce(ctx, validationNodeDependencies, missing, "volume_id", req.GetVolumeId()); err != nil {
+		return nil, err
+	}
+
+	// Volumes staged read-only are never written to: they are neither
+	// formatted, repaired, resized nor given a project quota
+	if st.readOnly = stagedReadOnly(req.GetVolumeCapability(), st.mountOptions); st.readOnly {
+		st.mountOptions = appendMountOptions(st.mountOptions, "ro")
+		if ns.driverReadOnlyNoRecovery() {
+			st.mountOptions = appendMountOptions(st.mountOptions, "norecovery")
+		}
+	}
+
+	st.verifyClone = ns.needsCloneVerification(ctx, req.GetVolumeContext())
+	return st, nil
+}
+
+// runStageSteps runs steps with st. The steps an earlier request for the
+// same volume completed, as recorded by marker, are resumed rather than run
+// again, up to the first one that cannot be resumed. The steps completed
+// since are recorded in marker, which is removed once every step completed.
+// A nil marker runs every step without recording them.
+func (ns *NodeServer) runStageSteps(ctx context.Context, st *stageState, steps []stageStep, marker *stageMarker) error {
+	log := logger.GetLogger(ctx)
+	volumeID := st.req.GetVolumeId()
+	defer func() {
+		if st.releaseDevice != nil {
+			st.releaseDevice()
+			st.releaseDevice = nil
+		}
+	}()
+
+	completed := marker.load(ctx, volumeID)
+	resuming := slices.ContainsFunc(steps, func(step stageStep) bool { return step.name == completed })
+	for _, step := range steps {
+		if resuming && step.resume != nil {
+			err := step.resume(ns, ctx, st)
+			if err == nil {
+				log.V(4).Info("Resuming completed stage step", "volumeID", volumeID, "step", step.name)
+				resuming = step.name != completed
+				continue
+			}
+			log.V(2).Info("Running completed stage step again", "volumeID", volumeID, "step", step.name, "reason", err.Error())
+			resuming = false
+		}
+
+		log.V(4).Info("Running stage step", "volumeID", volumeID, "step", step.name)
+		setRPCPhase(ctx, step.name)
+		if err := step.run(ns, ctx, st); err != nil {
+			log.V(2).Info("Stage step failed", "volumeID", volumeID, "step", step.name)
+			return err
+		}
+		// Steps run again while resuming were already recorded
+		if resuming {
+			resuming = step.name != completed
+			continue
+		}
+		marker.record(ctx, volumeID, step.name)
+	}
+
+	marker.remove(ctx)
+	return nil
+}
+
+// discoverStageDevice finds the device of the volume, or of its partition,
+// partitioning blank [PartitionAuto] volumes. It always runs, since the
+// device may change when the volume is attached again.
+func (ns *NodeServer) discoverStageDevice(ctx context.Context, st *stageState) error {
+	key, err := linodevolumes.ParseLinodeVolumeKey(st.req.GetVolumeId())
+	if err != nil {
+		return err
+	}
+
+	volumeContext := st.req.GetVolumeContext()
+	if err := validatePartition(volumeContext); err != nil {
+		return err
+	}
+	if partition := volumeContext[PartitionAttribute]; partition == PartitionAuto {
+		st.devicePath, err = ns.findAutoPartition(ctx, *key, st.readOnly)
+	} else {
+		st.devicePath, err = ns.findDevicePath(ctx, *key, partition)
+	}
+	if err != nil {
+		return err
+	}
+	if err := ns.checkDeviceSize(ctx, *key, st.devicePath, volumeContext[PartitionAttribute]); err != nil {
+		return err
+	}
+	st.source = st.devicePath
+
+	// Hold the device until the volume is staged
+	if st.releaseDevice == nil {
+		if st.releaseDevice, err = ns.devices.acquire(ctx, st.devicePath, st.req.GetVolumeId(), deviceOpStage); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openStageLUKS opens the LUKS device of encrypted volumes, formatting it
+// first if needed.
+func (ns *NodeServer) openStageLUKS(ctx context.Context, st *stageState) error {
+	// Make sure cloned volumes are not formatted over
+	if st.verifyClone {
+		if err := ns.checkCloneDevice(ctx, st.devicePath); err != nil {
+			ns.recordCloneVerification(ctx, st.req.GetVolumeContext(), err)
+			return err
+		}
+	}
+
+	if !st.luks.EncryptionEnabled {
+		return nil
+	}
+	if st.readOnly {
+		formatted, err := ns.encrypt.blkidValid(ctx, st.devicePath)
+		if err != nil {
+			return errInternal("Failed to validate blkid (%q): %v", st.devicePath, err)
+		}
+		if !formatted {
+			return errInternal("cannot format blank device %s of a volume staged read-only", st.devicePath)
+		}
+	}
+	log := logger.GetLogger(ctx)
+	log.V(4).Info("preparing luks volume", "devicePath", st.devicePath)
+	source, err := ns.formatLUKSVolume(ctx, st.devicePath, &st.luks)
+	if err != nil {
+		return err
+	}
+	st.source = source
+	return nil
+}
+
+// resumeStageLUKS uses the LUKS device opened by an earlier request, if it is
+// still open.
+func (ns *NodeServer) resumeStageLUKS(_ context.Context, st *stageState) error {
+	if !st.luks.EncryptionEnabled {
+		return nil
+	}
+	if st.luks.VolumeName == "" {
+		return errors.New("LUKS volume name cannot be found")
+	}
+	source := luksDevicePath(st.luks.VolumeName)
+	if _, err := ns.encrypt.FileSystem.Stat(source); err != nil {
+		return err
+	}
+	st.source = source
+	return nil
+}
+
+// openStageLUKSBlock opens the LUKS device of encrypted raw block volumes.
+func (ns *NodeServer) openStageLUKSBlock(ctx context.Context, st *stageState) error {
+	return ns.openLUKSBlockVolume(ctx, st.devicePath, st.req)
+}
+
+// formatStageDevice checks the contents of the device holding the file
+// system, and formats it if it is blank.
+func (ns *NodeServer) formatStageDevice(ctx context.Context, st *stageState) error {
+	// Check the file system of cloned volumes before it is repaired by
+	// FormatAndMount. Volumes staged read-only are not repaired, and their
+	// journal must not be replayed by the check
+	if st.verifyClone && !st.readOnly {
+		err := ns.checkCloneFilesystem(ctx, st.source, st.fsType)
+		ns.recordCloneVerification(ctx, st.req.GetVolumeContext(), err)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Make sure a device holding unexpected data is not formatted over
+	format, err := ns.checkDeviceFormat(ctx, st.source, st.fsType, st.req.GetVolumeId())
+	if err != nil {
+		return err
+	}
+	blank := format == ""
+	st.format = format
+	if !blank && format != st.fsType && ns.allowsFSMismatch(format) {
+		st.fsType = format
+	}
+
+	if st.readOnly {
+		if blank {
+			return errInternal("cannot format blank device %s of a volume staged read-only", st.source)
+		}
+		return nil
+	}
+
+	// Enable project quotas if the usage of the volume is capped
+	formatOptions, quotaMountOptions, err := ns.prepareProjectQuota(ctx, st.source, st.fsType, st.req.GetVolumeContext())
+	if err != nil {
+		return err
+	}
+	st.mountOptions = appendMountOptions(st.mountOptions, quotaMountOptions...)
+
+	if !blank {
+		return nil
+	}
+	if err := ns.formatDevice(ctx, st.source, st.fsType, st.req.GetVolumeId(), st.mountOptions, formatOptions); err != nil {
+		return err
+	}
+	st.formatted = true
+	st.format = st.fsType
+	ns.signatures.set(st.source, st.fsType)
+	return nil
+}
+
+// resumeStageFormat checks the device still holds the file system formatted
+// by an earlier request.
+func (ns *NodeServer) resumeStageFormat(ctx context.Context, st *stageState) error {
+	format, err := ns.diskFormat(ctx, st.source)
+	if err != nil {
+		return err
+	}
+	switch {
+	case format == st.fsType:
+	case ns.allowsFSMismatch(format):
+		st.fsType = format
+	default:
+		return errUnexpectedDeviceFormat(st.source, format, st.fsType)
+	}
+	st.format = format
+	if st.readOnly {
+		return nil
+	}
+
+	quotaMountOptions, err := projectQuotaMountOptions(st.fsType, st.req.GetVolumeContext())
+	if err != nil {
+		return err
+	}
+	st.mountOptions = appendMountOptions(st.mountOptions, quotaMountOptions...)
+	return nil
+}
+
+// mountStageDevice mounts the file system to the staging target path, and
+// caps its usage if requested.
+func (ns *NodeServer) mountStageDevice(ctx context.Context, st *stageState) error {
+	stagingTargetPath := st.req.GetStagingTargetPath()
+
+	log := logger.GetLogger(ctx)
+	log.V(4).Info("mounting the volume")
+	// Devices known to hold the file system are not probed again
+	var err error
+	if st.format != "" && st.format == st.fsType {
+		err = ns.mountFormatted(ctx, st.source, stagingTargetPath, st.fsType, st.mountOptions)
+	} else {
+		err = ns.auditedMounter(ctx, st.req.GetVolumeId()).FormatAndMount(st.source, stagingTargetPath, st.fsType, st.mountOptions)
+	}
+	if err != nil {
+		ns.signatures.forget(st.source)
+		return errInternal("Failed to format and mount device from (%q)---(%q) to (%q) with fstype (%q) and options (%q): %v",
+			st.source, st.devicePath, stagingTargetPath, st.fsType, st.mountOptions, err)
+	}
+
+	// Cap the usage of the file system, which the volume is published from
+	if st.readOnly {
+		return nil
+	}
+	return ns.applyProjectQuota(ctx, stagingTargetPath, st.fsType, st.req.GetVolumeId(), st.req.GetVolumeContext())
+}
+
+// resumeStageMount checks the file system mounted by an earlier request is
+// still mounted.
+func (ns *NodeServer) resumeStageMount(_ context.Context, st *stageState) error {
+	notMnt, err := ns.mounter.IsLikelyNotMountPoint(st.req.GetStagingTargetPath())
+	if err != nil {
+		return err
+	}
+	if notMnt {
+		return errors.New("staging target path is not mounted")
+	}
+	return nil
+}
+
+// resizeStageFilesystem grows the file system to the size of its device, in
+// case the volume was expanded while it was not staged. New file systems and
+// the ones mounted read-only are left as they are.
+func (ns *NodeServer) resizeStageFilesystem(ctx context.Context, st *stageState) error {
+	if st.formatted || st.readOnly {
+		return nil
+	}
+
+	stagingTargetPath := st.req.GetStagingTargetPath()
+	resizer := mount.NewResizeFs(ns.mounter.Exec)
+	needResize, err := resizer.NeedResize(st.source, stagingTargetPath)
+	if err != nil {
+		return errInternal("check if %s needs to be resized: %v", st.source, err)
+	}
+	if !needResize {
+		return nil
+	}
+	if _, err := resizer.Resize(st.source, stagingTargetPath); err != nil {
+		return errInternal("resize file system of %s: %v", st.source, err)
+	}
+	logger.GetLogger(ctx).V(2).Info("Resized file system", "volumeID", st.req.GetVolumeId(), "source", st.source)
+	return nil
+}
+
+// stageMarker records the last step of NodeStageVolume completed for a
+// volume, so that a retried request resumes after it. It is a file next to
+// the staging target path, in the directory the kubelet creates for the
+// volume, as the staging target path itself is hidden once mounted.
+//
+// A nil *stageMarker records nothing.
+type stageMarker struct {
+	path string
+	fs   filesystem.FileSystem
+}
+
+// stageMarkerRecord is the contents of a [stageMarker].
+type stageMarkerRecord struct {
+	VolumeID string `json:"volumeID"`
+	Step     string `json:"step"`
+}
+
+func newStageMarker(stagingTargetPath string, fs filesystem.FileSystem) *stageMarker {
+	dir, base := filepath.Split(filepath.Clean(stagingTargetPath))
+	return &stageMarker{path: filepath.Join(dir, "."+base+stageMarkerSuffix), fs: fs}
+}
+
+// load returns the last step completed for volumeID, or an empty string if
+// none was recorded.
+func (m *stageMarker) load(ctx context.Context, volumeID string) string {
+	if m == nil {
+		return ""
+	}
+
+	f, err := m.fs.Open(m.path)
+	if err != nil {
+		if !m.fs.IsNotExist(err) {
+			logger.GetLogger(ctx).Error(err, "Failed to open stage marker", "path", m.path)
+		}
+		return ""
+	}
+	defer f.Close()
+
+	var record stageMarkerRecord
+	data, err := io.ReadAll(f)
+	if err == nil {
+		err = json.Unmarshal(data, &record)
+	}
+	if err != nil {
+		logger.GetLogger(ctx).Error(err, "Ignoring invalid stage marker", "path", m.path)
+		return ""
+	}
+	if record.VolumeID != volumeID {
+		return ""
+	}
+	return record.Step
+}
+
+// record records step as the last step completed for volumeID. Failing to
+// record it only means a retried request runs the step again, so errors are
+// logged.
+func (m *stageMarker) record(ctx context.Context, volumeID, step string) {
+	if m == nil {
+		return
+	}
+
+	err := m.write(stageMarkerRecord{VolumeID: volumeID, Step: step})
+	if err != nil {
+		logger.GetLogger(ctx).Error(err, "Failed to record stage step", "path", m.path, "step", step)
+	}
+}
+
+func (m *stageMarker) write(record stageMarkerRecord) (err error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	f, err := m.fs.OpenFile(m.path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, ownerGroupReadWritePermissions)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, f.Close())
+	}()
+	_, err = f.Write(data)
+	return err
+}
+
+// remove removes the marker once the volume is staged or unstaged, so that
+// the kubelet can remove the directory of the volume.
+func (m *stageMarker) remove(ctx context.Context) {
+	if m == nil {
+		return
+	}
+
+	if err := m.fs.Remove(m.path); err != nil && !m.fs.IsNotExist(err) {
+		logger.GetLogger(ctx).Error(err, "Failed to remove stage marker", "path", m.path)
+	}
+}
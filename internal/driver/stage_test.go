@@ -0,0 +1,283 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+	filesystem "github.com/linode/linode-blockstorage-csi-driver/pkg/filesystem"
+)
+
+// mountVolume runs the steps of NodeStageVolume that open, format and mount
+// the volume at devicePath, without recording them.
+func mountVolume(ns *NodeServer, devicePath string, req *csi.NodeStageVolumeRequest) error {
+	ctx := context.Background()
+	st, err := ns.newStageState(ctx, req)
+	if err != nil {
+		return err
+	}
+	st.devicePath, st.source = devicePath, devicePath
+	return ns.runStageSteps(ctx, st, filesystemStageSteps[2:5], nil)
+}
+
+// faultySteps returns steps named after filesystemStageSteps, which append
+// their name to ran when they run, or to resumed when they are resumed. The
+// step named failing fails when it runs, as does resuming the one named
+// stale.
+func faultySteps(ran, resumed *[]string, failing, stale string) []stageStep {
+	steps := make([]stageStep, 0, len(filesystemStageSteps))
+	for _, step := range filesystemStageSteps {
+		name := step.name
+		fake := stageStep{
+			name: name,
+			run: func(*NodeServer, context.Context, *stageState) error {
+				*ran = append(*ran, name)
+				if name == failing {
+					return errors.New("injected fault")
+				}
+				return nil
+			},
+		}
+		if step.resume != nil {
+			fake.resume = func(*NodeServer, context.Context, *stageState) error {
+				if name == stale {
+					return errors.New("injected stale step")
+				}
+				*resumed = append(*resumed, name)
+				return nil
+			}
+		}
+		steps = append(steps, fake)
+	}
+	return steps
+}
+
+func TestRunStageStepsResume(t *testing.T) {
+	all := []string{stageStepDiscover, stageStepTune, stageStepOpenLUKS, stageStepFormat, stageStepMount, stageStepResize}
+
+	tests := []struct {
+		name        string
+		failing     string
+		stale       string
+		wantRan     []string
+		wantResumed []string
+	}{
+		{
+			name:    "Discover fails",
+			failing: stageStepDiscover,
+			wantRan: all,
+		},
+		{
+			name:    "Open LUKS fails",
+			failing: stageStepOpenLUKS,
+			wantRan: all,
+		},
+		{
+			name:        "Format fails",
+			failing:     stageStepFormat,
+			wantRan:     []string{stageStepDiscover, stageStepTune, stageStepFormat, stageStepMount, stageStepResize},
+			wantResumed: []string{stageStepOpenLUKS},
+		},
+		{
+			name:        "Mount fails",
+			failing:     stageStepMount,
+			wantRan:     []string{stageStepDiscover, stageStepTune, stageStepMount, stageStepResize},
+			wantResumed: []string{stageStepOpenLUKS, stageStepFormat},
+		},
+		{
+			name:        "Resize fails",
+			failing:     stageStepResize,
+			wantRan:     []string{stageStepDiscover, stageStepTune, stageStepResize},
+			wantResumed: []string{stageStepOpenLUKS, stageStepFormat, stageStepMount},
+		},
+		{
+			name:        "Completed step cannot be resumed",
+			failing:     stageStepResize,
+			stale:       stageStepFormat,
+			wantRan:     []string{stageStepDiscover, stageStepTune, stageStepFormat, stageStepMount, stageStepResize},
+			wantResumed: []string{stageStepOpenLUKS},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			stagingTargetPath := filepath.Join(t.TempDir(), "globalmount")
+			marker := newStageMarker(stagingTargetPath, filesystem.NewFileSystem())
+			st := &stageState{req: &csi.NodeStageVolumeRequest{VolumeId: "1001-test"}}
+			ns := &NodeServer{}
+
+			// The first request fails at the injected fault
+			var ran, resumed []string
+			if err := ns.runStageSteps(ctx, st, faultySteps(&ran, &resumed, tt.failing, ""), marker); err == nil {
+				t.Fatal("runStageSteps() succeeded, want the injected fault")
+			}
+
+			// The retried request resumes after the last completed step
+			ran, resumed = nil, nil
+			if err := ns.runStageSteps(ctx, st, faultySteps(&ran, &resumed, "", tt.stale), marker); err != nil {
+				t.Fatalf("runStageSteps() error = %v", err)
+			}
+			if !reflect.DeepEqual(ran, tt.wantRan) {
+				t.Errorf("ran steps = %v, want %v", ran, tt.wantRan)
+			}
+			if !reflect.DeepEqual(resumed, tt.wantResumed) {
+				t.Errorf("resumed steps = %v, want %v", resumed, tt.wantResumed)
+			}
+
+			// The marker is removed once every step completed
+			if _, err := os.Stat(marker.path); !os.IsNotExist(err) {
+				t.Errorf("stage marker still exists: %v", err)
+			}
+		})
+	}
+}
+
+func TestStageMarker(t *testing.T) {
+	ctx := context.Background()
+	stagingTargetPath := filepath.Join(t.TempDir(), "globalmount")
+	marker := newStageMarker(stagingTargetPath, filesystem.NewFileSystem())
+
+	if got := marker.load(ctx, "1001-test"); got != "" {
+		t.Errorf("load() without marker = %q, want no step", got)
+	}
+
+	marker.record(ctx, "1001-test", stageStepFormat)
+	if got := marker.load(ctx, "1001-test"); got != stageStepFormat {
+		t.Errorf("load() = %q, want %q", got, stageStepFormat)
+	}
+	// Markers left by another volume are ignored
+	if got := marker.load(ctx, "1002-other"); got != "" {
+		t.Errorf("load() for another volume = %q, want no step", got)
+	}
+
+	// Invalid markers are ignored
+	if err := os.WriteFile(marker.path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got := marker.load(ctx, "1001-test"); got != "" {
+		t.Errorf("load() of invalid marker = %q, want no step", got)
+	}
+
+	marker.remove(ctx)
+	marker.remove(ctx)
+	if _, err := os.Stat(marker.path); !os.IsNotExist(err) {
+		t.Errorf("stage marker still exists: %v", err)
+	}
+}
+
+func TestResumeStageFormatMismatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		allowFS    bool
+		wantErr    bool
+		wantFSType string
+	}{
+		{name: "Refused", wantErr: true},
+		{name: "Mounted with the file system found", allowFS: true, wantFSType: "xfs"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := &NodeServer{driver: &LinodeDriver{opts: Options{AllowFSMismatchMount: tt.allowFS}}}
+			ns.signatures.set("/dev/sdb", "xfs")
+			st := &stageState{
+				req:      &csi.NodeStageVolumeRequest{VolumeId: "1001-test"},
+				source:   "/dev/sdb",
+				fsType:   "ext4",
+				readOnly: true,
+			}
+
+			err := ns.resumeStageFormat(context.Background(), st)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resumeStageFormat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (st.fsType != tt.wantFSType || st.format != tt.wantFSType) {
+				t.Errorf("fsType, format = %q, %q, want %q", st.fsType, st.format, tt.wantFSType)
+			}
+		})
+	}
+}
+
+func TestResumeStageLUKS(t *testing.T) {
+	tests := []struct {
+		name       string
+		statErr    error
+		wantErr    bool
+		wantSource string
+	}{
+		{name: "Still open", wantSource: "/dev/mapper/pvc-test"},
+		{name: "Closed", statErr: os.ErrNotExist, wantErr: true, wantSource: "/dev/sdb"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			fs := mocks.NewMockFileSystem(ctrl)
+			fs.EXPECT().Stat("/dev/mapper/pvc-test").Return(nil, tt.statErr)
+			ns := &NodeServer{encrypt: Encryption{FileSystem: fs}}
+			st := &stageState{
+				source: "/dev/sdb",
+				luks:   LuksContext{EncryptionEnabled: true, VolumeName: "pvc-test"},
+			}
+
+			err := ns.resumeStageLUKS(context.Background(), st)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resumeStageLUKS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if st.source != tt.wantSource {
+				t.Errorf("source = %q, want %q", st.source, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestRunStageStepsHoldDevice(t *testing.T) {
+	defer func(timeout time.Duration) { deviceLockTimeout = timeout }(deviceLockTimeout)
+	deviceLockTimeout = 10 * time.Millisecond
+
+	ctx := context.Background()
+	ns := &NodeServer{}
+	// The device held by the first step stays held by the following ones,
+	// and until the last one completed
+	held := func(step string) func(*NodeServer, context.Context, *stageState) error {
+		return func(ns *NodeServer, ctx context.Context, st *stageState) error {
+			if _, err := ns.devices.acquire(ctx, "/dev/sdb", "1002-other", deviceOpUnstage); status.Code(err) != codes.Aborted {
+				t.Errorf("device acquired by another volume during step %s, error = %v", step, err)
+			}
+			return nil
+		}
+	}
+	steps := []stageStep{
+		{name: stageStepDiscover, run: func(ns *NodeServer, ctx context.Context, st *stageState) (err error) {
+			st.devicePath = "/dev/sdb"
+			st.releaseDevice, err = ns.devices.acquire(ctx, st.devicePath, st.req.GetVolumeId(), deviceOpStage)
+			return err
+		}},
+		{name: stageStepFormat, run: held(stageStepFormat)},
+		{name: stageStepMount, run: held(stageStepMount)},
+		{name: stageStepResize, run: held(stageStepResize)},
+	}
+	st := &stageState{req: &csi.NodeStageVolumeRequest{VolumeId: "1001-test"}}
+	if err := ns.runStageSteps(ctx, st, steps, nil); err != nil {
+		t.Fatalf("runStageSteps() error = %v", err)
+	}
+
+	release, err := ns.devices.acquire(ctx, "/dev/sdb", "1002-other", deviceOpStage)
+	if err != nil {
+		t.Fatalf("device still held once staged: %v", err)
+	}
+	release()
+}
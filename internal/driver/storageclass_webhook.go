@@ -0,0 +1,249 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/linode/linodego"
+	"google.golang.org/grpc/status"
+
+	linodeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-client"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+// StorageClassWebhookPath is the path the [StorageClassValidator] serves
+// the admission reviews of StorageClasses on.
+const StorageClassWebhookPath = "/validate-storageclass"
+
+// nodeStageSecretNameParameter is the StorageClass parameter naming the
+// Secret passed to NodeStageVolume, which holds the key of LUKS volumes.
+const nodeStageSecretNameParameter = "csi.storage.k8s.io/node-stage-secret-name"
+
+// StorageClassValidator is a validating admission webhook checking the
+// parameters of the StorageClasses of the driver when they are created, so
+// that misconfigured classes are refused when they are applied rather than
+// when their first volume is provisioned.
+//
+// The parameters are checked like CreateVolume does, against the
+// capabilities of the regions of the allowed topologies of the class and the
+// [Options.AllowedRegions]. In [EnforcementWarn] mode the classes are
+// admitted, with the problems as warnings.
+type StorageClassValidator struct {
+	client       linodeclient.LinodeClient
+	capabilities *linodeclient.Capabilities
+	opts         Options
+}
+
+// NewStorageClassValidator returns a validator requesting the capabilities
+// of the regions with client. They are discovered once, and the regions
+// added since are requested when they are found in a StorageClass.
+func NewStorageClassValidator(ctx context.Context, client linodeclient.LinodeClient, opts Options) *StorageClassValidator {
+	log := logger.GetLogger(ctx)
+
+	capabilities, err := linodeclient.DiscoverCapabilities(ctx, client)
+	if err != nil {
+		log.Error(err, "Failed to discover capabilities")
+	}
+	return &StorageClassValidator{client: client, capabilities: capabilities, opts: opts}
+}
+
+// storageClass holds the fields of a StorageClass that are validated.
+type storageClass struct {
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Provisioner       string            `json:"provisioner"`
+	Parameters        map[string]string `json:"parameters"`
+	AllowedTopologies []struct {
+		MatchLabelExpressions []struct {
+			Key    string   `json:"key"`
+			Values []string `json:"values"`
+		} `json:"matchLabelExpressions"`
+	} `json:"allowedTopologies"`
+}
+
+// regions returns the regions the allowed topologies of sc restrict its
+// volumes to, sorted, or none if it is not restricted.
+func (sc *storageClass) regions() []string {
+	var regions []string
+	for _, term := range sc.AllowedTopologies {
+		for _, expression := range term.MatchLabelExpressions {
+			if expression.Key == VolumeTopologyRegion {
+				regions = append(regions, expression.Values...)
+			}
+		}
+	}
+	slices.Sort(regions)
+	return slices.Compact(regions)
+}
+
+// admissionReview is an admission.k8s.io/v1 AdmissionReview, with the
+// fields of the requests and responses the validator uses.
+type admissionReview struct {
+	APIVersion string             `json:"apiVersion"`
+	Kind       string             `json:"kind"`
+	Request    *admissionRequest  `json:"request,omitempty"`
+	Response   *admissionResponse `json:"response,omitempty"`
+}
+
+type admissionRequest struct {
+	UID       string          `json:"uid"`
+	Operation string          `json:"operation"`
+	Object    json.RawMessage `json:"object"`
+}
+
+type admissionResponse struct {
+	UID      string           `json:"uid"`
+	Allowed  bool             `json:"allowed"`
+	Result   *admissionStatus `json:"status,omitempty"`
+	Warnings []string         `json:"warnings,omitempty"`
+}
+
+type admissionStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// ServeHTTP answers the AdmissionReview of the creation of a StorageClass.
+// The classes of other provisioners are always admitted.
+func (v *StorageClassValidator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.GetLogger(ctx)
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var review admissionReview
+	if err := json.NewDecoder(r.Body).Decode(&review); err != nil || review.Request == nil {
+		http.Error(w, "invalid AdmissionReview", http.StatusBadRequest)
+		return
+	}
+
+	response := &admissionResponse{UID: review.Request.UID, Allowed: true}
+	var sc storageClass
+	if err := json.Unmarshal(review.Request.Object, &sc); err != nil {
+		response.Allowed = false
+		response.Result = &admissionStatus{Code: http.StatusBadRequest, Message: fmt.Sprintf("decode StorageClass: %v", err)}
+	} else if sc.Provisioner == Name {
+		problems, warnings := v.validate(ctx, sc.Parameters, sc.regions())
+		response.Warnings = warnings
+		if len(problems) > 0 {
+			mode := EnforcementEnforce
+			if v.opts.EnforcementMode == EnforcementWarn {
+				mode = EnforcementWarn
+			}
+			observability.ValidationFailuresTotal.WithLabelValues(validationStorageClass, string(mode)).Inc()
+			log.V(2).Info("StorageClass failed validation", "storageClass", sc.Metadata.Name, "problems", problems, "mode", mode)
+
+			if mode == EnforcementWarn {
+				response.Warnings = append(response.Warnings, problems...)
+			} else {
+				response.Allowed = false
+				response.Result = &admissionStatus{
+					Code:    http.StatusUnprocessableEntity,
+					Message: fmt.Sprintf("invalid parameters for %s: %s", Name, strings.Join(problems, "; ")),
+				}
+			}
+		}
+	}
+
+	review.Request = nil
+	review.Response = response
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(review); err != nil {
+		log.Error(err, "Failed to write AdmissionReview response")
+	}
+}
+
+// validate returns the reasons CreateVolume or NodeStageVolume would fail
+// for the volumes of a StorageClass with parameters, restricted to regions
+// when there are any. The checks that could not be made, e.g. because the
+// Linode API could not be reached, are returned as warnings.
+func (v *StorageClassValidator) validate(ctx context.Context, parameters map[string]string, regions []string) (problems, warnings []string) {
+	problem := func(err error) {
+		problems = append(problems, status.Convert(err).Message())
+	}
+
+	fsType, hasFSType := parameters[FilesystemTypeAttribute]
+	if hasFSType && !supportedFSType(fsType) {
+		problem(errUnsupportedFSType(fsType))
+	}
+	if parameters[ProjectQuotaAttribute] == True && hasFSType {
+		if _, ok := projectQuotas[fsType]; !ok {
+			problem(errUnsupportedProjectQuota(fsType))
+		}
+	}
+
+	if err := validateDeviceTuning(parameters); err != nil {
+		problem(err)
+	}
+	if err := validatePartition(parameters); err != nil {
+		problem(err)
+	}
+	if err := validateIOThrottle(parameters); err != nil {
+		problem(err)
+	}
+
+	if tags := parameters[VolumeTags]; tags != "" {
+		for _, tag := range strings.Split(tags, ",") {
+			switch {
+			case isAttributeTag(tag):
+				problem(errReservedVolumeTag(tag))
+			case len(tag) < minTagLength || len(tag) > maxTagLength:
+				problems = append(problems, fmt.Sprintf("volume tag %q must have %d to %d characters", tag, minTagLength, maxTagLength))
+			}
+		}
+	}
+
+	if parameters[LuksEncryptedAttribute] == True {
+		if parameters[nodeStageSecretNameParameter] == "" {
+			problems = append(problems, fmt.Sprintf("LUKS encrypted volumes need the Secret holding their key in the %s parameter", nodeStageSecretNameParameter))
+		}
+		if keySize, ok := parameters[LuksKeySizeAttribute]; ok {
+			if n, err := strconv.Atoi(keySize); err != nil || n <= 0 {
+				problems = append(problems, fmt.Sprintf("invalid LUKS key size %q, must be a positive number of bits", keySize))
+			}
+		}
+	}
+
+	encrypted := parameters[VolumeEncryption] == True
+	for _, region := range regions {
+		if allowed := v.opts.AllowedRegions; len(allowed) > 0 && !slices.Contains(allowed, region) {
+			problem(errRegionNotAllowed(region, allowed))
+			continue
+		}
+		capabilities, err := v.regionCapabilities(ctx, region)
+		switch {
+		case linodego.ErrHasStatus(err, http.StatusNotFound):
+			problems = append(problems, fmt.Sprintf("region %q does not exist", region))
+		case err != nil:
+			warnings = append(warnings, fmt.Sprintf("the capabilities of region %q could not be checked: %v", region, err))
+		case encrypted && !slices.Contains(capabilities, linodego.CapabilityBlockStorageEncryption):
+			problem(errEncryptionNotSupported(region, v.capabilities.RegionsSupporting(linodego.CapabilityBlockStorageEncryption, region, maxSuggestedRegions)))
+		}
+	}
+	return problems, warnings
+}
+
+// regionCapabilities returns the capabilities of region, from those
+// discovered when the validator was created, or requested if they do not
+// include the region.
+func (v *StorageClassValidator) regionCapabilities(ctx context.Context, region string) ([]string, error) {
+	if v.capabilities != nil {
+		if capabilities, ok := v.capabilities.Regions[region]; ok {
+			return capabilities, nil
+		}
+	}
+	details, err := v.client.GetRegion(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+	return details.Capabilities, nil
+}
@@ -0,0 +1,226 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/linode/linodego"
+	"go.uber.org/mock/gomock"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+	linodeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-client"
+)
+
+func TestStorageClassValidator_validate(t *testing.T) {
+	capabilities := &linodeclient.Capabilities{
+		Regions: map[string][]string{
+			"us-east":    {linodego.CapabilityBlockStorage, linodego.CapabilityBlockStorageEncryption},
+			"us-central": {linodego.CapabilityBlockStorage},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		parameters   map[string]string
+		regions      []string
+		opts         Options
+		expectCalls  func(*mocks.MockLinodeClient)
+		wantProblems []string
+		wantWarnings []string
+	}{
+		{
+			name: "Valid parameters",
+			parameters: map[string]string{
+				FilesystemTypeAttribute: "ext4",
+				ProjectQuotaAttribute:   True,
+				VolumeEncryption:        True,
+				VolumeTags:              "team-a,prod",
+			},
+			regions: []string{"us-east"},
+		},
+		{
+			name:         "Unsupported file system",
+			parameters:   map[string]string{FilesystemTypeAttribute: "btrfs"},
+			wantProblems: []string{`unsupported file system type "btrfs"`},
+		},
+		{
+			name:         "Invalid read-ahead",
+			parameters:   map[string]string{ReadAheadKBAttribute: "16k"},
+			wantProblems: []string{`invalid read-ahead "16k"`},
+		},
+		{
+			name:         "Invalid partition",
+			parameters:   map[string]string{PartitionAttribute: "first"},
+			wantProblems: []string{`invalid partition "first"`},
+		},
+		{
+			name:         "Invalid I/O throttle",
+			parameters:   map[string]string{ReadBPSAttribute: "10M"},
+			wantProblems: []string{`invalid linodebs.csi.linode.com/rbps "10M": must be a positive integer`},
+		},
+		{
+			name:         "Project quota on xfs",
+			parameters:   map[string]string{FilesystemTypeAttribute: "xfs", ProjectQuotaAttribute: True},
+			wantProblems: []string{`project quotas are not supported on file system type "xfs"`},
+		},
+		{
+			name:         "Reserved and short tags",
+			parameters:   map[string]string{VolumeTags: "csi-cluster:abc,ok"},
+			wantProblems: []string{`volume tag "csi-cluster:abc" is reserved`, `volume tag "ok" must have 3 to 50 characters`},
+		},
+		{
+			name:         "LUKS without secret and with invalid key size",
+			parameters:   map[string]string{LuksEncryptedAttribute: True, LuksKeySizeAttribute: "large"},
+			wantProblems: []string{"LUKS encrypted volumes need the Secret", `invalid LUKS key size "large"`},
+		},
+		{
+			name:         "Encryption not supported in region",
+			parameters:   map[string]string{VolumeEncryption: True},
+			regions:      []string{"us-central", "us-east"},
+			wantProblems: []string{"Volume encryption is not supported in the us-central region, it is supported in: us-east"},
+		},
+		{
+			name:         "Region not allowed",
+			parameters:   map[string]string{},
+			regions:      []string{"us-central"},
+			opts:         Options{AllowedRegions: []string{"us-east"}},
+			wantProblems: []string{`volumes cannot be created in region "us-central"`},
+		},
+		{
+			name:       "Region added since discovery",
+			parameters: map[string]string{VolumeEncryption: True},
+			regions:    []string{"us-west"},
+			expectCalls: func(client *mocks.MockLinodeClient) {
+				client.EXPECT().GetRegion(gomock.Any(), "us-west").Return(&linodego.Region{
+					ID:           "us-west",
+					Capabilities: []string{linodego.CapabilityBlockStorageEncryption},
+				}, nil)
+			},
+		},
+		{
+			name:       "Unknown region",
+			parameters: map[string]string{},
+			regions:    []string{"us-nowhere"},
+			expectCalls: func(client *mocks.MockLinodeClient) {
+				client.EXPECT().GetRegion(gomock.Any(), "us-nowhere").Return(nil, &linodego.Error{Code: http.StatusNotFound})
+			},
+			wantProblems: []string{`region "us-nowhere" does not exist`},
+		},
+		{
+			name:       "Linode API unreachable",
+			parameters: map[string]string{VolumeEncryption: True},
+			regions:    []string{"us-west"},
+			expectCalls: func(client *mocks.MockLinodeClient) {
+				client.EXPECT().GetRegion(gomock.Any(), "us-west").Return(nil, errors.New("connection refused"))
+			},
+			wantWarnings: []string{`the capabilities of region "us-west" could not be checked: connection refused`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			client := mocks.NewMockLinodeClient(ctrl)
+			if tt.expectCalls != nil {
+				tt.expectCalls(client)
+			}
+			v := &StorageClassValidator{client: client, capabilities: capabilities, opts: tt.opts}
+
+			problems, warnings := v.validate(context.Background(), tt.parameters, tt.regions)
+			if !matchPrefixes(problems, tt.wantProblems) {
+				t.Errorf("validate() problems = %q, want prefixes %q", problems, tt.wantProblems)
+			}
+			if !matchPrefixes(warnings, tt.wantWarnings) {
+				t.Errorf("validate() warnings = %q, want prefixes %q", warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+// matchPrefixes reports whether got has as many strings as prefixes, each
+// starting with the prefix at the same index.
+func matchPrefixes(got, prefixes []string) bool {
+	return slices.EqualFunc(got, prefixes, strings.HasPrefix)
+}
+
+func TestStorageClassValidator_ServeHTTP(t *testing.T) {
+	const encryptedClass = `{
+		"metadata": {"name": "encrypted"},
+		"provisioner": "linodebs.csi.linode.com",
+		"parameters": {"linodebs.csi.linode.com/encrypted": "true"},
+		"allowedTopologies": [{"matchLabelExpressions": [{"key": "topology.linode.com/region", "values": ["us-central"]}]}]
+	}`
+	capabilities := &linodeclient.Capabilities{
+		Regions: map[string][]string{"us-central": {linodego.CapabilityBlockStorage}},
+	}
+
+	tests := []struct {
+		name         string
+		object       string
+		mode         EnforcementMode
+		wantAllowed  bool
+		wantWarnings int
+	}{
+		{
+			name:        "Invalid class is refused",
+			object:      encryptedClass,
+			mode:        EnforcementEnforce,
+			wantAllowed: false,
+		},
+		{
+			name:         "Invalid class is admitted with warnings in warn mode",
+			object:       encryptedClass,
+			mode:         EnforcementWarn,
+			wantAllowed:  true,
+			wantWarnings: 1,
+		},
+		{
+			name:        "Class of another provisioner is admitted",
+			object:      `{"provisioner": "example.com/other", "parameters": {"linodebs.csi.linode.com/fs-type": "btrfs"}}`,
+			mode:        EnforcementEnforce,
+			wantAllowed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &StorageClassValidator{capabilities: capabilities, opts: Options{EnforcementMode: tt.mode}}
+
+			body := `{"apiVersion": "admission.k8s.io/v1", "kind": "AdmissionReview", "request": {"uid": "42", "operation": "CREATE", "object": ` + tt.object + `}}`
+			rec := httptest.NewRecorder()
+			v.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, StorageClassWebhookPath, strings.NewReader(body)))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+			}
+
+			var review admissionReview
+			if err := json.NewDecoder(rec.Body).Decode(&review); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if review.APIVersion != "admission.k8s.io/v1" || review.Kind != "AdmissionReview" || review.Request != nil {
+				t.Errorf("response is not an AdmissionReview response: %+v", review)
+			}
+			if review.Response == nil {
+				t.Fatal("response is missing")
+			}
+			if review.Response.UID != "42" {
+				t.Errorf("uid = %q, want %q", review.Response.UID, "42")
+			}
+			if review.Response.Allowed != tt.wantAllowed {
+				t.Errorf("allowed = %v, want %v (status %+v)", review.Response.Allowed, tt.wantAllowed, review.Response.Result)
+			}
+			if !tt.wantAllowed && (review.Response.Result == nil || !strings.Contains(review.Response.Result.Message, "us-central")) {
+				t.Errorf("status = %+v, want the reason of the refusal", review.Response.Result)
+			}
+			if len(review.Response.Warnings) != tt.wantWarnings {
+				t.Errorf("warnings = %q, want %d", review.Response.Warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
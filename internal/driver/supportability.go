@@ -0,0 +1,88 @@
+package driver
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/linode/linodego"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+// maxDriverVersionLength is the length the version of the driver is
+// truncated to in the [attributeDriver] of volumes, so that the attribute
+// fits in a tag with the hash of the features.
+const maxDriverVersionLength = maxTagLength - len(AttributeTagPrefix+string(attributeDriver)+":/") - shortHashLength
+
+// enabledFeatures returns the optional behaviors of the controller enabled
+// by its options, sorted, as their names or, for modes, "<name>=<mode>".
+func (d *LinodeDriver) enabledFeatures() []string {
+	opts := d.opts
+	var features []string
+	for _, f := range []struct {
+		name    string
+		enabled bool
+	}{
+		{"async-controller-unpublish", opts.AsyncControllerUnpublish},
+		{"delete-volume-detach-wait", opts.DeleteVolumeDetachWait > 0},
+		{"attach-config-from-node-annotation", opts.AttachConfigFromNodeAnnotation},
+		{"deleted-node-detach", opts.DeletedNodeDetachGracePeriod > 0},
+		{"volume-failure-backoff", opts.VolumeFailureBackoff > 0},
+		{"allowed-regions", len(opts.AllowedRegions) > 0},
+		{"reject-legacy-volume-ids", opts.RejectLegacyVolumeIDs},
+		{"account-volume-limit", opts.AccountVolumeLimit > 0},
+		{"cluster-name", opts.ClusterName != ""},
+		{"volume-name-template", opts.VolumeNameTemplate != nil},
+		{"volume-label-sync", opts.VolumeLabelSyncInterval > 0},
+		{"account-events", opts.AccountEventsInterval > 0 || opts.AccountEventsAddress != ""},
+		{"token-secrets", opts.NewLinodeClient != nil},
+	} {
+		if f.enabled {
+			features = append(features, f.name)
+		}
+	}
+	if opts.EnforcementMode != "" {
+		features = append(features, "enforcement="+string(opts.EnforcementMode))
+	}
+	if opts.CrossNamespaceClones != "" && opts.CrossNamespaceClones != CrossNamespaceClonesAllow {
+		features = append(features, "cross-namespace-clones="+string(opts.CrossNamespaceClones))
+	}
+	if opts.Sharding != "" && opts.Sharding != ShardingOff {
+		features = append(features, "sharding="+string(opts.Sharding))
+	}
+	slices.Sort(features)
+	return features
+}
+
+// featuresHash returns the short hash of the enabled features, logged when
+// the controller starts so that it can be matched with the features.
+func (d *LinodeDriver) featuresHash() string {
+	return shortHash(strings.Join(d.enabledFeatures(), ","))
+}
+
+// driverAttribute returns the attribute of the volumes created or modified
+// by the driver, with its version and the hash of its enabled features, or
+// false if the version of the driver is not known.
+func (d *LinodeDriver) driverAttribute() (attribute, bool) {
+	if d == nil || d.vendorVersion == "" {
+		return attribute{}, false
+	}
+	version := strings.ReplaceAll(d.vendorVersion, ",", "-")
+	if len(version) > maxDriverVersionLength {
+		version = version[:maxDriverVersionLength]
+	}
+	return attribute{key: attributeDriver, value: version + "/" + d.featuresHash()}, true
+}
+
+// stampVolume sets the [attributeDriver] of vol after it was changed. It
+// only logs the errors, since the volume was changed anyway.
+func (cs *ControllerServer) stampVolume(ctx context.Context, vol *linodego.Volume) {
+	stamp, ok := cs.driver.driverAttribute()
+	if !ok {
+		return
+	}
+	if err := cs.updateVolumeAttributes(ctx, vol, []attribute{stamp}); err != nil {
+		logger.GetLogger(ctx).Error(err, "Failed to stamp the volume with the driver", "volume_id", vol.ID, "stamp", stamp.value)
+	}
+}
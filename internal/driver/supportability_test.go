@@ -0,0 +1,76 @@
+package driver
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEnabledFeatures(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{
+			name: "Defaults",
+		},
+		{
+			name: "Enabled features",
+			opts: Options{
+				ClusterName:              "prod",
+				AsyncControllerUnpublish: true,
+				EnforcementMode:          EnforcementWarn,
+				CrossNamespaceClones:     CrossNamespaceClonesDeny,
+			},
+			want: []string{"async-controller-unpublish", "cluster-name", "cross-namespace-clones=deny", "enforcement=warn"},
+		},
+		{
+			name: "Cross-namespace clones allowed",
+			opts: Options{CrossNamespaceClones: CrossNamespaceClonesAllow},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &LinodeDriver{opts: tt.opts}
+			if got := d.enabledFeatures(); !slices.Equal(got, tt.want) {
+				t.Errorf("enabledFeatures() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDriverAttribute(t *testing.T) {
+	tests := []struct {
+		name        string
+		version     string
+		wantVersion string
+		wantOK      bool
+	}{
+		{name: "No version"},
+		{name: "Version", version: "v1.2.3", wantVersion: "v1.2.3", wantOK: true},
+		{name: "Comma", version: "v1.2.3,dirty", wantVersion: "v1.2.3-dirty", wantOK: true},
+		{name: "Long version", version: "v1.2.3-0.20241016123456-0123456789ab-dirty", wantVersion: "v1.2.3-0.20241016123456-01234567", wantOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &LinodeDriver{vendorVersion: tt.version, opts: Options{ClusterName: "prod"}}
+			a, ok := d.driverAttribute()
+			if ok != tt.wantOK {
+				t.Fatalf("driverAttribute() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			version, hash, _ := strings.Cut(a.value, "/")
+			if version != tt.wantVersion || hash != d.featuresHash() {
+				t.Errorf("driverAttribute() = %q, want %q/%s", a.value, tt.wantVersion, d.featuresHash())
+			}
+			if _, err := a.tag(); err != nil {
+				t.Errorf("tag() error = %v", err)
+			}
+		})
+	}
+}
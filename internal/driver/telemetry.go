@@ -0,0 +1,65 @@
+package driver
+
+import (
+	"cmp"
+	"context"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+// Features counted by the csi_feature_usage_total metric. File systems are
+// counted as "fs_" followed by the file system type.
+const (
+	featureLUKSEncryption   = "luks_encryption"
+	featureLinodeEncryption = "linode_encryption"
+	featureClone            = "clone"
+	featureBlock            = "block"
+	featureFSPrefix         = "fs_"
+)
+
+// volumeFeatures returns the features used by the volume provisioned for req,
+// with the Linode encryption status encryptionStatus.
+func (cs *ControllerServer) volumeFeatures(req *csi.CreateVolumeRequest, encryptionStatus string) []string {
+	var features []string
+	if req.GetParameters()[LuksEncryptedAttribute] == True {
+		features = append(features, featureLUKSEncryption)
+	}
+	if encryptionStatus == "enabled" {
+		features = append(features, featureLinodeEncryption)
+	}
+	if req.GetVolumeContentSource() != nil {
+		features = append(features, featureClone)
+	}
+
+	var fsType string
+	for _, volCap := range req.GetVolumeCapabilities() {
+		if volCap.GetBlock() != nil {
+			return append(features, featureBlock)
+		}
+		fsType = cmp.Or(fsType, volCap.GetMount().GetFsType())
+	}
+	// Same precedence as the node plugin when it formats the volume
+	driverFSType := ""
+	if cs.driver != nil {
+		driverFSType = cs.driver.opts.DefaultFSType
+	}
+	fsType = cmp.Or(fsType, req.GetParameters()[FilesystemTypeAttribute], driverFSType, defaultFSType)
+	return append(features, featureFSPrefix+fsType)
+}
+
+// recordFeatureUsage counts the features used by the volume provisioned for
+// req, when [Options.FeatureTelemetry] is enabled.
+func (cs *ControllerServer) recordFeatureUsage(ctx context.Context, req *csi.CreateVolumeRequest, encryptionStatus string) {
+	if cs.driver == nil || !cs.driver.opts.FeatureTelemetry {
+		return
+	}
+
+	features := cs.volumeFeatures(req, encryptionStatus)
+	logger.GetLogger(ctx).V(4).Info("Recording feature usage", "features", features)
+	for _, feature := range features {
+		observability.FeatureUsageTotal.WithLabelValues(feature).Inc()
+	}
+}
@@ -0,0 +1,73 @@
+package driver
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+)
+
+func TestVolumeFeatures(t *testing.T) {
+	mountCap := func(fsType string) []*csi.VolumeCapability {
+		return []*csi.VolumeCapability{{
+			AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{FsType: fsType}},
+		}}
+	}
+
+	tests := []struct {
+		name             string
+		req              *csi.CreateVolumeRequest
+		encryptionStatus string
+		defaultFSType    string
+		want             []string
+	}{
+		{
+			name: "Default file system",
+			req:  &csi.CreateVolumeRequest{VolumeCapabilities: mountCap("")},
+			want: []string{"fs_ext4"},
+		},
+		{
+			name:          "Driver default file system",
+			req:           &csi.CreateVolumeRequest{VolumeCapabilities: mountCap("")},
+			defaultFSType: "xfs",
+			want:          []string{"fs_xfs"},
+		},
+		{
+			name: "StorageClass file system",
+			req: &csi.CreateVolumeRequest{
+				VolumeCapabilities: mountCap(""),
+				Parameters:         map[string]string{FilesystemTypeAttribute: "ext3"},
+			},
+			defaultFSType: "xfs",
+			want:          []string{"fs_ext3"},
+		},
+		{
+			name: "Encrypted clone",
+			req: &csi.CreateVolumeRequest{
+				VolumeCapabilities:  mountCap("xfs"),
+				Parameters:          map[string]string{LuksEncryptedAttribute: True},
+				VolumeContentSource: &csi.VolumeContentSource{},
+			},
+			encryptionStatus: "enabled",
+			want:             []string{"luks_encryption", "linode_encryption", "clone", "fs_xfs"},
+		},
+		{
+			name: "Block volume",
+			req: &csi.CreateVolumeRequest{
+				VolumeCapabilities: []*csi.VolumeCapability{{
+					AccessType: &csi.VolumeCapability_Block{Block: &csi.VolumeCapability_BlockVolume{}},
+				}},
+			},
+			want: []string{"block"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := &ControllerServer{driver: &LinodeDriver{opts: Options{DefaultFSType: tt.defaultFSType}}}
+			if got := cs.volumeFeatures(tt.req, tt.encryptionStatus); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("volumeFeatures() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
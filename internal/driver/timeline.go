@@ -0,0 +1,67 @@
+package driver
+
+import (
+	"context"
+	"time"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+const (
+	// VolumeCreatedAtAttribute is the volume context key holding when the
+	// Linode API created the volume, in RFC 3339 format, so that the node
+	// plugin can measure the time until it first mounts it.
+	VolumeCreatedAtAttribute = Name + "/created-at"
+
+	// attachedAtKey is the publish context key holding when
+	// ControllerPublishVolume attached the volume, in RFC 3339 format, so
+	// that the node plugin can measure the time until it mounts it.
+	attachedAtKey = "attachedAt"
+)
+
+// formatTimestamp formats t for the volume and publish contexts.
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// observeMountLatency records, once st staged a file system volume, the time
+// since the volume was attached and, if st formatted it, the time since it
+// was created. The timestamps are taken by the Linode API and the controller,
+// so the durations are only as accurate as the clocks of the nodes are.
+func observeMountLatency(ctx context.Context, st *stageState) {
+	if st.req.GetVolumeCapability().GetBlock() != nil {
+		return
+	}
+	log := logger.GetLogger(ctx)
+	region := st.req.GetVolumeContext()[VolumeTopologyRegion]
+	now := time.Now()
+
+	// Only the first mount of a volume formats it
+	if created, ok := parseTimestamp(ctx, st.req.GetVolumeContext()[VolumeCreatedAtAttribute]); ok && st.formatted {
+		if d := now.Sub(created); d >= 0 {
+			log.V(4).Info("Volume mounted for the first time", "volumeID", st.req.GetVolumeId(), "sinceCreated", d)
+			observability.VolumeCreateToMountDuration.WithLabelValues(region).Observe(d.Seconds())
+		}
+	}
+	if attached, ok := parseTimestamp(ctx, st.req.GetPublishContext()[attachedAtKey]); ok {
+		if d := now.Sub(attached); d >= 0 {
+			log.V(4).Info("Volume mounted", "volumeID", st.req.GetVolumeId(), "sinceAttached", d)
+			observability.VolumeAttachToMountDuration.WithLabelValues(region).Observe(d.Seconds())
+		}
+	}
+}
+
+// parseTimestamp parses a timestamp of the volume or publish context, which
+// volumes created or attached by earlier releases do not have.
+func parseTimestamp(ctx context.Context, value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		logger.GetLogger(ctx).Error(err, "Ignoring invalid timestamp", "timestamp", value)
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,89 @@
+package driver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+func TestObserveMountLatency(t *testing.T) {
+	now := time.Now()
+	filesystem := &csi.VolumeCapability{AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}}}
+	block := &csi.VolumeCapability{AccessType: &csi.VolumeCapability_Block{Block: &csi.VolumeCapability_BlockVolume{}}}
+
+	tests := []struct {
+		name              string
+		capability        *csi.VolumeCapability
+		createdAt         string
+		attachedAt        string
+		formatted         bool
+		wantCreateToMount bool
+		wantAttachToMount bool
+	}{
+		{
+			name:              "First mount",
+			capability:        filesystem,
+			createdAt:         formatTimestamp(now.Add(-2 * time.Minute)),
+			attachedAt:        formatTimestamp(now.Add(-time.Minute)),
+			formatted:         true,
+			wantCreateToMount: true,
+			wantAttachToMount: true,
+		},
+		{
+			name:              "Mounted again",
+			capability:        filesystem,
+			createdAt:         formatTimestamp(now.Add(-2 * time.Hour)),
+			attachedAt:        formatTimestamp(now.Add(-time.Minute)),
+			wantAttachToMount: true,
+		},
+		{
+			name:       "Attached by an earlier release",
+			capability: filesystem,
+			formatted:  true,
+		},
+		{
+			name:       "Invalid or future timestamps",
+			capability: filesystem,
+			createdAt:  "yesterday",
+			attachedAt: formatTimestamp(now.Add(time.Hour)),
+			formatted:  true,
+		},
+		{
+			name:       "Block volume",
+			capability: block,
+			attachedAt: formatTimestamp(now.Add(-time.Minute)),
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Each test case observes its own region
+			region := "test-region-" + string(rune('a'+i))
+			st := &stageState{
+				req: &csi.NodeStageVolumeRequest{
+					VolumeId:         "1001-vol",
+					VolumeCapability: tt.capability,
+					VolumeContext:    map[string]string{VolumeTopologyRegion: region, VolumeCreatedAtAttribute: tt.createdAt},
+					PublishContext:   map[string]string{attachedAtKey: tt.attachedAt},
+				},
+				formatted: tt.formatted,
+			}
+
+			createBefore := testutil.CollectAndCount(observability.VolumeCreateToMountDuration)
+			attachBefore := testutil.CollectAndCount(observability.VolumeAttachToMountDuration)
+			observeMountLatency(context.Background(), st)
+
+			if got := testutil.CollectAndCount(observability.VolumeCreateToMountDuration) > createBefore; got != tt.wantCreateToMount {
+				t.Errorf("create to mount duration observed = %v, want %v", got, tt.wantCreateToMount)
+			}
+			if got := testutil.CollectAndCount(observability.VolumeAttachToMountDuration) > attachBefore; got != tt.wantAttachToMount {
+				t.Errorf("attach to mount duration observed = %v, want %v", got, tt.wantAttachToMount)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package driver
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/linode/linodego"
+
+	linodeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-client"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+// tokenExpiryWarning is how long before the token of the controller expires
+// the token check starts logging a warning at each check.
+const tokenExpiryWarning = 7 * 24 * time.Hour
+
+// tokenCheck periodically checks that the Linode API accepts the token of
+// the controller, and when it expires, so that the volume operations failing
+// once it is revoked or expired can be alerted on before they fail.
+type tokenCheck struct {
+	client   linodeclient.LinodeClient
+	token    string
+	interval time.Duration
+}
+
+func newTokenCheck(client linodeclient.LinodeClient, token string, interval time.Duration) *tokenCheck {
+	return &tokenCheck{
+		client:   client,
+		token:    token,
+		interval: interval,
+	}
+}
+
+// run checks the token every interval until ctx is canceled.
+func (c *tokenCheck) run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		c.check(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check exports whether the token is accepted by the Linode API, from a
+// request for its profile, and how long until it expires. The metrics are
+// left as they are when the API cannot be reached.
+func (c *tokenCheck) check(ctx context.Context) {
+	log := logger.GetLogger(ctx)
+	log.V(4).Info("Entering tokenCheck.check()")
+	defer log.V(4).Info("Exiting tokenCheck.check()")
+
+	checkCtx, cancel := context.WithTimeout(ctx, apiCheckTimeout)
+	defer cancel()
+
+	if _, err := c.client.GetProfile(checkCtx); err != nil {
+		if !linodego.ErrHasStatus(err, http.StatusUnauthorized) {
+			log.Error(err, "Failed to check the Linode API token")
+			return
+		}
+		log.Error(err, "Linode API token is rejected")
+		observability.TokenValid.WithLabelValues().Set(0)
+		observability.TokenExpirySeconds.Reset()
+		return
+	}
+	observability.TokenValid.WithLabelValues().Set(1)
+
+	expiry, err := c.expiry(checkCtx)
+	if err != nil {
+		log.V(4).Info("Failed to find the expiry of the Linode API token", "error", err.Error())
+	}
+	if expiry == nil {
+		observability.TokenExpirySeconds.Reset()
+		return
+	}
+	remaining := time.Until(*expiry)
+	observability.TokenExpirySeconds.WithLabelValues().Set(remaining.Seconds())
+	if remaining < tokenExpiryWarning {
+		log.Error(nil, "Linode API token expires soon", "expiry", expiry.Format(time.RFC3339), "remaining", remaining.Round(time.Minute).String())
+	}
+}
+
+// expiry returns when the token expires, or nil if it never does or is not
+// listed among the tokens of its profile. Only the first characters of the
+// tokens are listed, which the token is matched on.
+func (c *tokenCheck) expiry(ctx context.Context) (*time.Time, error) {
+	if c.token == "" {
+		return nil, nil
+	}
+	tokens, err := c.client.ListTokens(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		if token.Token != "" && strings.HasPrefix(c.token, token.Token) {
+			return token.Expiry, nil
+		}
+	}
+	return nil, nil
+}
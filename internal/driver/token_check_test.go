@@ -0,0 +1,103 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/linode/linodego"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/mock/gomock"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+func TestTokenCheck(t *testing.T) {
+	const token = "0123456789abcdef0123456789abcdef"
+	expiry := time.Now().Add(30 * 24 * time.Hour)
+
+	tests := []struct {
+		name       string
+		profileErr error
+		tokens     []linodego.Token
+		tokensErr  error
+		// wantValid is the value of csi_token_valid, or -1 if it is not set
+		wantValid float64
+		// wantExpiry is whether csi_token_expiry_seconds is set
+		wantExpiry bool
+	}{
+		{
+			name: "Expiring token",
+			tokens: []linodego.Token{
+				{Token: "fedcba9876543210", Expiry: &expiry},
+				{Token: token[:16], Expiry: &expiry},
+			},
+			wantValid:  1,
+			wantExpiry: true,
+		},
+		{
+			name:      "Token never expiring",
+			tokens:    []linodego.Token{{Token: token[:16]}},
+			wantValid: 1,
+		},
+		{
+			name:      "Token not listed",
+			tokens:    []linodego.Token{{Token: "fedcba9876543210", Expiry: &expiry}},
+			wantValid: 1,
+		},
+		{
+			name:      "Tokens not listable",
+			tokensErr: &linodego.Error{Code: http.StatusUnauthorized, Message: "Your OAuth token is not authorized to use this endpoint."},
+			wantValid: 1,
+		},
+		{
+			name:       "Rejected token",
+			profileErr: &linodego.Error{Code: http.StatusUnauthorized, Message: "Invalid Token"},
+			wantValid:  0,
+		},
+		{
+			name:       "Unreachable API",
+			profileErr: fmt.Errorf("connection refused"),
+			wantValid:  -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			observability.TokenValid.Reset()
+			observability.TokenExpirySeconds.Reset()
+
+			mockClient := mocks.NewMockLinodeClient(ctrl)
+			mockClient.EXPECT().GetProfile(gomock.Any()).Return(&linodego.Profile{}, tt.profileErr)
+			if tt.profileErr == nil {
+				mockClient.EXPECT().ListTokens(gomock.Any(), gomock.Any()).Return(tt.tokens, tt.tokensErr)
+			}
+
+			newTokenCheck(mockClient, token, time.Hour).check(context.Background())
+
+			if tt.wantValid < 0 {
+				if n := testutil.CollectAndCount(observability.TokenValid); n != 0 {
+					t.Errorf("csi_token_valid is set, want it unset")
+				}
+			} else if got := testutil.ToFloat64(observability.TokenValid.WithLabelValues()); got != tt.wantValid {
+				t.Errorf("csi_token_valid = %v, want %v", got, tt.wantValid)
+			}
+
+			if n := testutil.CollectAndCount(observability.TokenExpirySeconds); (n != 0) != tt.wantExpiry {
+				t.Fatalf("csi_token_expiry_seconds is set = %v, want %v", n != 0, tt.wantExpiry)
+			}
+			if tt.wantExpiry {
+				got := testutil.ToFloat64(observability.TokenExpirySeconds.WithLabelValues())
+				if want := time.Until(expiry).Seconds(); got < want-60 || got > want+60 {
+					t.Errorf("csi_token_expiry_seconds = %v, want about %v", got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+// TopologyMode is whether the controller creates volumes in the region of
+// the topology requirements of the CreateVolume requests.
+type TopologyMode string
+
+const (
+	// TopologyOn advertises the VOLUME_ACCESSIBILITY_CONSTRAINTS capability,
+	// and creates volumes in the region of the topology requirements of
+	// their request, or in the region of the controller when they have
+	// none. It is the default.
+	TopologyOn TopologyMode = "on"
+
+	// TopologyRequired is [TopologyOn], but fails the CreateVolume requests
+	// without a region in their topology requirements with InvalidArgument
+	// instead of creating their volume in the region of the controller,
+	// which is not the region of the nodes when the controller runs
+	// elsewhere.
+	TopologyRequired TopologyMode = "required"
+
+	// TopologyOff does not advertise the VOLUME_ACCESSIBILITY_CONSTRAINTS
+	// capability, so that the provisioner sends no topology requirements,
+	// and creates all the volumes in the region of the controller.
+	TopologyOff TopologyMode = "off"
+)
+
+// ParseTopologyMode parses a topology mode. The empty string is
+// [TopologyOn].
+func ParseTopologyMode(s string) (TopologyMode, error) {
+	switch mode := TopologyMode(s); mode {
+	case "":
+		return TopologyOn, nil
+	case TopologyOn, TopologyRequired, TopologyOff:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid topology mode %q, must be %q, %q or %q", s, TopologyOn, TopologyRequired, TopologyOff)
+	}
+}
+
+// volumeRegion returns the region to create a volume in for the topology
+// requirements of its request, as set by the [Options.Topology] of the
+// driver.
+func (cs *ControllerServer) volumeRegion(ctx context.Context, requirements *csi.TopologyRequirement) (string, error) {
+	if cs.driver.opts.Topology == TopologyOff {
+		return cs.metadata.Region, nil
+	}
+
+	region, err := getRegionFromTopology(requirements, cs.supportsBlockStorage)
+	if err != nil {
+		return "", err
+	}
+	if region != "" {
+		logger.GetLogger(ctx).V(4).Info("Using region from topology", "region", region)
+		return region, nil
+	}
+	if cs.driver.opts.Topology == TopologyRequired {
+		return "", errNoTopologyRegion(cs.metadata.Region)
+	}
+	return cs.metadata.Region, nil
+}
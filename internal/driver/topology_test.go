@@ -0,0 +1,62 @@
+package driver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseTopologyMode(t *testing.T) {
+	for s, want := range map[string]TopologyMode{
+		"":         TopologyOn,
+		"on":       TopologyOn,
+		"required": TopologyRequired,
+		"off":      TopologyOff,
+	} {
+		if got, err := ParseTopologyMode(s); err != nil || got != want {
+			t.Errorf("ParseTopologyMode(%q) = %q, %v, want %q", s, got, err, want)
+		}
+	}
+	if _, err := ParseTopologyMode("strict"); err == nil {
+		t.Error("ParseTopologyMode(\"strict\") succeeded, want error")
+	}
+}
+
+func TestVolumeRegion(t *testing.T) {
+	withRegion := &csi.TopologyRequirement{
+		Preferred: []*csi.Topology{{Segments: map[string]string{VolumeTopologyRegion: "us-ord"}}},
+	}
+
+	tests := []struct {
+		name         string
+		topology     TopologyMode
+		requirements *csi.TopologyRequirement
+		wantRegion   string
+		wantCode     codes.Code
+	}{
+		{name: "Topology region", requirements: withRegion, wantRegion: "us-ord"},
+		{name: "No topology region", wantRegion: "us-east"},
+		{name: "Required topology region", topology: TopologyRequired, requirements: withRegion, wantRegion: "us-ord"},
+		{name: "Required topology region missing", topology: TopologyRequired, requirements: &csi.TopologyRequirement{}, wantCode: codes.InvalidArgument},
+		{name: "Topology off", topology: TopologyOff, requirements: withRegion, wantRegion: "us-east"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := &ControllerServer{
+				driver:   &LinodeDriver{opts: Options{Topology: tt.topology}},
+				metadata: Metadata{Region: "us-east"},
+			}
+			region, err := cs.volumeRegion(context.Background(), tt.requirements)
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("volumeRegion() error = %v, want code %v", err, tt.wantCode)
+			}
+			if region != tt.wantRegion {
+				t.Errorf("volumeRegion() = %q, want %q", region, tt.wantRegion)
+			}
+		})
+	}
+}
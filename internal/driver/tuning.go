@@ -0,0 +1,224 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+const (
+	// ReadAheadKBAttribute is the StorageClass parameter key setting how
+	// many kilobytes the kernel reads ahead on the device of volumes, e.g.
+	// lower for the random reads of databases. It is passed to the node
+	// plugin through the volume context.
+	ReadAheadKBAttribute = Name + "/readAheadKB"
+
+	// IOSchedulerAttribute is the StorageClass parameter key setting the
+	// I/O scheduler of the device of volumes, one of those the kernel of the
+	// node offers for it, e.g. "none" or "mq-deadline". It is passed to the
+	// node plugin through the volume context.
+	IOSchedulerAttribute = Name + "/ioScheduler"
+)
+
+// sysClassBlockPath lists the block devices of the node and their
+// partitions, whose queue settings are tuned.
+var sysClassBlockPath = "/sys/class/block"
+
+// deviceTuningSuffix is appended to the staging target path of a volume to
+// get the path of its [deviceTuning] record.
+const deviceTuningSuffix = ".tuning"
+
+// deviceTuning records the queue settings of the device of a volume that
+// NodeStageVolume changed, with the values they had before, so that
+// NodeUnstageVolume restores them. It is kept next to the staging target
+// path, as the [stageMarker].
+type deviceTuning struct {
+	// Queue is the sysfs directory of the queue of the device.
+	Queue string `json:"queue"`
+
+	// ReadAheadKB and Scheduler are the previous read-ahead and I/O
+	// scheduler of the device, empty if they were not changed.
+	ReadAheadKB string `json:"readAheadKB,omitempty"`
+	Scheduler   string `json:"scheduler,omitempty"`
+}
+
+func deviceTuningPath(stagingTargetPath string) string {
+	dir, base := filepath.Split(filepath.Clean(stagingTargetPath))
+	return filepath.Join(dir, "."+base+deviceTuningSuffix)
+}
+
+// validateDeviceTuning checks the tuning parameters of a volume that do not
+// depend on its node.
+func validateDeviceTuning(parameters map[string]string) error {
+	if readAheadKB, ok := parameters[ReadAheadKBAttribute]; ok {
+		if _, err := strconv.ParseUint(readAheadKB, 10, 32); err != nil {
+			return errInvalidReadAhead(readAheadKB)
+		}
+	}
+	return nil
+}
+
+// tuneStageDevice sets the read-ahead and I/O scheduler requested in the
+// volume context on the device of the volume, recording their previous
+// values first. It runs again when a request is retried, keeping the values
+// recorded by the first one.
+func (ns *NodeServer) tuneStageDevice(ctx context.Context, st *stageState) error {
+	volumeContext := st.req.GetVolumeContext()
+	readAheadKB, scheduler := volumeContext[ReadAheadKBAttribute], volumeContext[IOSchedulerAttribute]
+	if readAheadKB == "" && scheduler == "" {
+		return nil
+	}
+	if err := validateDeviceTuning(volumeContext); err != nil {
+		return err
+	}
+
+	queue, err := deviceQueuePath(st.devicePath)
+	if err != nil {
+		return errInternal("find the queue of device %s: %v", st.devicePath, err)
+	}
+	if scheduler != "" {
+		_, available, err := readIOSchedulers(queue)
+		if err != nil {
+			return errInternal("read the I/O schedulers of device %s: %v", st.devicePath, err)
+		}
+		if !slices.Contains(available, scheduler) {
+			return errUnavailableIOScheduler(scheduler, st.devicePath, available)
+		}
+	}
+
+	recordPath := deviceTuningPath(st.req.GetStagingTargetPath())
+	tuning, err := loadDeviceTuning(recordPath)
+	if err != nil || tuning.Queue != queue {
+		tuning = &deviceTuning{Queue: queue}
+		if readAheadKB != "" {
+			if tuning.ReadAheadKB, err = readQueueSetting(queue, "read_ahead_kb"); err != nil {
+				return errInternal("read the read-ahead of device %s: %v", st.devicePath, err)
+			}
+		}
+		if scheduler != "" {
+			if tuning.Scheduler, _, err = readIOSchedulers(queue); err != nil {
+				return errInternal("read the I/O scheduler of device %s: %v", st.devicePath, err)
+			}
+		}
+		if err := writeDeviceTuning(recordPath, tuning); err != nil {
+			return errInternal("record the queue settings of device %s: %v", st.devicePath, err)
+		}
+	}
+
+	if readAheadKB != "" {
+		if err := writeQueueSetting(queue, "read_ahead_kb", readAheadKB); err != nil {
+			return errInternal("set the read-ahead of device %s: %v", st.devicePath, err)
+		}
+	}
+	if scheduler != "" {
+		if err := writeQueueSetting(queue, "scheduler", scheduler); err != nil {
+			return errInternal("set the I/O scheduler of device %s: %v", st.devicePath, err)
+		}
+	}
+	logger.GetLogger(ctx).V(2).Info("Tuned device queue", "volumeID", st.req.GetVolumeId(), "device", st.devicePath, "readAheadKB", readAheadKB, "ioScheduler", scheduler)
+	return nil
+}
+
+// restoreDeviceTuning restores the queue settings of the device of the
+// volume staged at stagingTargetPath changed by [NodeServer.tuneStageDevice],
+// if any. The settings are lost anyway when the volume is detached, so
+// failures are only logged.
+func restoreDeviceTuning(ctx context.Context, stagingTargetPath string) {
+	log := logger.GetLogger(ctx)
+
+	recordPath := deviceTuningPath(stagingTargetPath)
+	tuning, err := loadDeviceTuning(recordPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		log.Error(err, "Ignoring invalid device tuning record", "path", recordPath)
+	} else {
+		if tuning.ReadAheadKB != "" {
+			if err := writeQueueSetting(tuning.Queue, "read_ahead_kb", tuning.ReadAheadKB); err != nil {
+				log.Error(err, "Failed to restore the read-ahead of device", "queue", tuning.Queue)
+			}
+		}
+		if tuning.Scheduler != "" {
+			if err := writeQueueSetting(tuning.Queue, "scheduler", tuning.Scheduler); err != nil {
+				log.Error(err, "Failed to restore the I/O scheduler of device", "queue", tuning.Queue)
+			}
+		}
+	}
+	if err := os.Remove(recordPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Error(err, "Failed to remove device tuning record", "path", recordPath)
+	}
+}
+
+// deviceQueuePath returns the sysfs directory of the queue of devicePath,
+// which is the one of its disk for partitions.
+func deviceQueuePath(devicePath string) (string, error) {
+	device, err := filepath.EvalSymlinks(devicePath)
+	if err != nil {
+		return "", err
+	}
+	dir, err := filepath.EvalSymlinks(filepath.Join(sysClassBlockPath, filepath.Base(device)))
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(filepath.Join(dir, "partition")); err == nil {
+		dir = filepath.Dir(dir)
+	}
+	return filepath.Join(dir, "queue"), nil
+}
+
+// readIOSchedulers returns the current and available I/O schedulers of the
+// device of queue. The kernel lists them as "mq-deadline [none]", with the
+// current one in brackets.
+func readIOSchedulers(queue string) (current string, available []string, err error) {
+	value, err := readQueueSetting(queue, "scheduler")
+	if err != nil {
+		return "", nil, err
+	}
+	for _, name := range strings.Fields(value) {
+		if trimmed := strings.TrimSuffix(strings.TrimPrefix(name, "["), "]"); trimmed != name {
+			current, name = trimmed, trimmed
+		}
+		available = append(available, name)
+	}
+	return current, available, nil
+}
+
+func readQueueSetting(queue, name string) (string, error) {
+	value, err := os.ReadFile(filepath.Join(queue, name))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(value)), nil
+}
+
+func writeQueueSetting(queue, name, value string) error {
+	return os.WriteFile(filepath.Join(queue, name), []byte(value), 0)
+}
+
+func loadDeviceTuning(path string) (*deviceTuning, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tuning deviceTuning
+	if err := json.Unmarshal(data, &tuning); err != nil {
+		return nil, err
+	}
+	return &tuning, nil
+}
+
+func writeDeviceTuning(path string, tuning *deviceTuning) error {
+	data, err := json.Marshal(tuning)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, ownerGroupReadWritePermissions)
+}
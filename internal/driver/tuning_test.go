@@ -0,0 +1,150 @@
+package driver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeBlockDevice creates the sysfs entries of disk sdc, with partition
+// sdc1, under a temporary sysClassBlockPath, and returns the path of a
+// symbolic link to device, "sdc" or "sdc1", and the queue of sdc.
+func fakeBlockDevice(t *testing.T, device string) (devicePath, queue string) {
+	t.Helper()
+	dir := t.TempDir()
+
+	disk := filepath.Join(dir, "devices", "sdc")
+	queue = filepath.Join(disk, "queue")
+	partition := filepath.Join(disk, "sdc1")
+	for _, path := range []string{queue, partition, filepath.Join(dir, "class", "block"), filepath.Join(dir, "dev")} {
+		if err := os.MkdirAll(path, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		filepath.Join(queue, "read_ahead_kb"): "128\n",
+		filepath.Join(queue, "scheduler"):     "[mq-deadline] kyber none\n",
+		filepath.Join(partition, "partition"): "1\n",
+		filepath.Join(dir, "dev", device):     "",
+	}
+	for path, contents := range files {
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		filepath.Join(dir, "class", "block", "sdc"):  disk,
+		filepath.Join(dir, "class", "block", "sdc1"): partition,
+		filepath.Join(dir, "dev", "by-id"):           filepath.Join(dir, "dev", device),
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	previous := sysClassBlockPath
+	sysClassBlockPath = filepath.Join(dir, "class", "block")
+	t.Cleanup(func() { sysClassBlockPath = previous })
+	return filepath.Join(dir, "dev", "by-id"), queue
+}
+
+func readSetting(t *testing.T, queue, name string) string {
+	t.Helper()
+	value, err := readQueueSetting(queue, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+func TestTuneStageDevice(t *testing.T) {
+	for _, device := range []string{"sdc", "sdc1"} {
+		t.Run(device, func(t *testing.T) {
+			ctx := context.Background()
+			devicePath, queue := fakeBlockDevice(t, device)
+			stagingTargetPath := filepath.Join(t.TempDir(), "globalmount")
+			st := &stageState{
+				req: &csi.NodeStageVolumeRequest{
+					VolumeId:          "1001-test",
+					StagingTargetPath: stagingTargetPath,
+					VolumeContext: map[string]string{
+						ReadAheadKBAttribute: "16",
+						IOSchedulerAttribute: "none",
+					},
+				},
+				devicePath: devicePath,
+			}
+			ns := &NodeServer{}
+
+			if err := ns.tuneStageDevice(ctx, st); err != nil {
+				t.Fatalf("tuneStageDevice() error = %v", err)
+			}
+			if got := readSetting(t, queue, "read_ahead_kb"); got != "16" {
+				t.Errorf("read_ahead_kb = %q, want %q", got, "16")
+			}
+			if got := readSetting(t, queue, "scheduler"); got != "none" {
+				t.Errorf("scheduler = %q, want %q", got, "none")
+			}
+
+			// A retried request keeps the settings found by the first one
+			if err := ns.tuneStageDevice(ctx, st); err != nil {
+				t.Fatalf("tuneStageDevice() retry error = %v", err)
+			}
+
+			restoreDeviceTuning(ctx, stagingTargetPath)
+			if got := readSetting(t, queue, "read_ahead_kb"); got != "128" {
+				t.Errorf("restored read_ahead_kb = %q, want %q", got, "128")
+			}
+			if got := readSetting(t, queue, "scheduler"); got != "mq-deadline" {
+				t.Errorf("restored scheduler = %q, want %q", got, "mq-deadline")
+			}
+			if _, err := os.Stat(deviceTuningPath(stagingTargetPath)); !os.IsNotExist(err) {
+				t.Errorf("device tuning record still exists: %v", err)
+			}
+		})
+	}
+}
+
+func TestTuneStageDeviceInvalid(t *testing.T) {
+	tests := []struct {
+		name          string
+		volumeContext map[string]string
+	}{
+		{
+			name:          "Invalid read-ahead",
+			volumeContext: map[string]string{ReadAheadKBAttribute: "-1"},
+		},
+		{
+			name:          "Unavailable scheduler",
+			volumeContext: map[string]string{IOSchedulerAttribute: "bfq"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			devicePath, queue := fakeBlockDevice(t, "sdc")
+			stagingTargetPath := filepath.Join(t.TempDir(), "globalmount")
+			st := &stageState{
+				req:        &csi.NodeStageVolumeRequest{StagingTargetPath: stagingTargetPath, VolumeContext: tt.volumeContext},
+				devicePath: devicePath,
+			}
+
+			err := (&NodeServer{}).tuneStageDevice(context.Background(), st)
+			if status.Code(err) != codes.InvalidArgument {
+				t.Errorf("tuneStageDevice() error = %v, want InvalidArgument", err)
+			}
+			if got := readSetting(t, queue, "scheduler"); got != "[mq-deadline] kyber none" {
+				t.Errorf("scheduler = %q, want it unchanged", got)
+			}
+			if _, err := os.Stat(deviceTuningPath(stagingTargetPath)); !os.IsNotExist(err) {
+				t.Errorf("device tuning record was written: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,461 @@
+//go:build linux
+// +build linux
+
+package driver
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/linode/linodego"
+	"github.com/martinjungblut/go-cryptsetup"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"k8s.io/mount-utils"
+	"k8s.io/utils/exec"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+	devicemanager "github.com/linode/linode-blockstorage-csi-driver/pkg/device-manager"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+// The tests in this file check the contract between the controller and the
+// node plugin that a rolling upgrade relies on, when they run different
+// releases of the driver: the node plugin must stage volumes from the
+// contexts the previous release of the controller produced, and the
+// controller must keep producing every key the previous release of the node
+// plugin reads. The previous release is not run; its contexts are pinned
+// below instead. Requests go through a real gRPC socket, so that the
+// contexts are (de)serialized the way they are in a cluster.
+
+// The keys below are spelled out rather than taken from the driver's
+// constants: they are a contract with controllers and node plugins that are
+// already deployed, and must not change.
+const (
+	legacyDevicePathKey    = "devicePath"
+	legacyLuksEncryptedKey = "linodebs.csi.linode.com/luks-encrypted"
+	legacyLuksCipherKey    = "linodebs.csi.linode.com/luks-cipher"
+	legacyLuksKeySizeKey   = "linodebs.csi.linode.com/luks-key-size"
+	legacyVolumeNameKey    = "linodebs.csi.linode.com/volume-name"
+	legacyLuksSecretKey    = "luksKey"
+)
+
+// The contexts the previous release of the controller returned from
+// CreateVolume and ControllerPublishVolume for the volume created by
+// TestControllerKeepsPreviousReleaseContexts, recorded by running that
+// release with the same mocks. The volume has no region in the mocks, hence
+// the empty topology value.
+var (
+	previousReleasePublishContext = map[string]string{
+		legacyDevicePathKey: "/dev/disk/by-id/scsi-0Linode_Volume_pvc123",
+	}
+	previousReleaseVolumeContext = map[string]string{
+		"topology.linode.com/region": "",
+	}
+	previousReleaseLuksVolumeContext = map[string]string{
+		legacyLuksCipherKey:          "aes-xts-plain64",
+		legacyLuksEncryptedKey:       "true",
+		legacyLuksKeySizeKey:         "512",
+		legacyVolumeNameKey:          "pvc123",
+		"topology.linode.com/region": "",
+	}
+)
+
+// upgradeTimeout bounds the requests the tests send, so that a mock failing
+// inside a handler fails the test instead of hanging it.
+const upgradeTimeout = 30 * time.Second
+
+const (
+	upgradeVolumeID   = "1003-pvc123"
+	upgradeVolumeName = "pvc123"
+	upgradeNodeID     = "1003"
+	upgradeDiskByID   = "/dev/disk/by-id/linode-pvc123"
+	upgradeLuksKey    = "secret"
+)
+
+// serveCSI serves cs and ns on a temporary unix socket, and returns a
+// connection to it.
+func serveCSI(t *testing.T, cs csi.ControllerServer, ns csi.NodeServer) *grpc.ClientConn {
+	t.Helper()
+
+	socketPath := filepath.Join(t.TempDir(), "csi.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(logger.LogGRPC))
+	if cs != nil {
+		csi.RegisterControllerServer(server, cs)
+	}
+	if ns != nil {
+		csi.RegisterNodeServer(server, ns)
+	}
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			t.Errorf("serve: %v", err)
+		}
+	}()
+
+	conn, err := grpc.NewClient("unix://"+socketPath, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := conn.Close(); err != nil {
+			t.Errorf("close connection: %v", err)
+		}
+		server.Stop()
+	})
+	return conn
+}
+
+// nodeStageSequence describes the contexts a node plugin receives for a
+// volume, as produced by some version of the controller.
+type nodeStageSequence struct {
+	volumeContext  map[string]string
+	publishContext map[string]string
+	secrets        map[string]string
+}
+
+// expectNodeLifecycle sets up the mocks for staging, publishing, unpublishing
+// and unstaging a new, unformatted volume. If mapperName is not empty, the
+// volume is expected to be LUKS encrypted and opened under that name.
+func expectNodeLifecycle(ctrl *gomock.Controller, mounter *mocks.MockMounter, executor *mocks.MockExecutor, fs *mocks.MockFileSystem, crypt *mocks.MockCryptSetupClient, stagingPath, targetPath, mapperName string) {
+	// Find the attached device.
+	fs.EXPECT().Glob("/dev/sd*").Return([]string{"/dev/sda"}, nil).AnyTimes()
+	fs.EXPECT().Stat(upgradeDiskByID).Return(nil, nil)
+
+	// NodeStageVolume
+	mounter.EXPECT().IsLikelyNotMountPoint(stagingPath).Return(true, nil)
+	source := upgradeDiskByID
+	if mapperName != "" {
+		source = "/dev/mapper/" + mapperName
+
+		luksCheck := mocks.NewMockCommand(ctrl)
+		executor.EXPECT().LookPath("blkid").Return("/sbin/blkid", nil)
+		executor.EXPECT().Command("blkid", upgradeDiskByID).Return(luksCheck)
+		luksCheck.EXPECT().Run().Return(exec.CodeExitError{Code: 2})
+
+		device := mocks.NewMockDevice(ctrl)
+		crypt.EXPECT().Init(upgradeDiskByID).Return(device, nil)
+		device.EXPECT().Format(cryptsetup.LUKS2{SectorSize: 512}, cryptsetup.GenericParams{
+			Cipher:        "aes",
+			CipherMode:    "xts-plain64",
+			VolumeKey:     upgradeLuksKey,
+			VolumeKeySize: 512 / 8,
+		}).Return(nil)
+		device.EXPECT().KeyslotAddByVolumeKey(0, "", upgradeLuksKey).Return(nil)
+		device.EXPECT().ActivateByPassphrase(mapperName, 0, upgradeLuksKey, 0).Return(nil)
+		device.EXPECT().Free().Return(true)
+	}
+	// The blank device is formatted, then checked and mounted without being
+	// probed again
+	blankCheck := mocks.NewMockCommand(ctrl)
+	executor.EXPECT().Command("blkid", "-p", "-s", "TYPE", "-s", "PTTYPE", "-o", "export", source).Return(blankCheck)
+	blankCheck.EXPECT().CombinedOutput().Return(nil, exec.CodeExitError{Code: 2})
+	mkfs := mocks.NewMockCommand(ctrl)
+	executor.EXPECT().Command("mkfs.ext4", "-F", "-m0", source).Return(mkfs)
+	mkfs.EXPECT().CombinedOutput().Return(nil, nil)
+	fsck := mocks.NewMockCommand(ctrl)
+	executor.EXPECT().Command("fsck", "-a", source).Return(fsck)
+	fsck.EXPECT().CombinedOutput().Return(nil, nil)
+	mounter.EXPECT().MountSensitive(source, stagingPath, "ext4", []string{"defaults"}, nil).Return(nil)
+
+	// NodePublishVolume
+	mounter.EXPECT().IsLikelyNotMountPoint(targetPath).Return(true, nil)
+	mounter.EXPECT().Mount(stagingPath, targetPath, "ext4", []string{"bind"}).Return(nil)
+
+	// NodeUnpublishVolume and NodeUnstageVolume
+	mounter.EXPECT().CanSafelySkipMountPointCheck().Return(false).AnyTimes()
+	for _, path := range []string{targetPath, stagingPath} {
+		gomock.InOrder(
+			mounter.EXPECT().IsMountPoint(path).Return(true, nil),
+			mounter.EXPECT().Unmount(path).Return(nil),
+			mounter.EXPECT().IsMountPoint(path).Return(false, nil),
+		)
+	}
+	if mapperName != "" {
+		device := mocks.NewMockDevice(ctrl)
+		crypt.EXPECT().InitByName(mapperName).Return(device, nil)
+		device.EXPECT().Deactivate(mapperName).Return(nil)
+		device.EXPECT().Free().Return(true)
+	} else {
+		crypt.EXPECT().InitByName(upgradeVolumeName).Return(nil, os.ErrNotExist)
+	}
+}
+
+// runNodeLifecycle stages, publishes, unpublishes and unstages a volume
+// through client, using the contexts in seq.
+func runNodeLifecycle(t *testing.T, client csi.NodeClient, seq nodeStageSequence, stagingPath, targetPath string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), upgradeTimeout)
+	defer cancel()
+
+	capability := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+	}
+
+	if _, err := client.NodeStageVolume(ctx, &csi.NodeStageVolumeRequest{
+		VolumeId:          upgradeVolumeID,
+		StagingTargetPath: stagingPath,
+		VolumeCapability:  capability,
+		PublishContext:    seq.publishContext,
+		VolumeContext:     seq.volumeContext,
+		Secrets:           seq.secrets,
+	}); err != nil {
+		t.Fatalf("NodeStageVolume() error = %v", err)
+	}
+	if _, err := client.NodePublishVolume(ctx, &csi.NodePublishVolumeRequest{
+		VolumeId:          upgradeVolumeID,
+		StagingTargetPath: stagingPath,
+		TargetPath:        targetPath,
+		VolumeCapability:  capability,
+		PublishContext:    seq.publishContext,
+		VolumeContext:     seq.volumeContext,
+	}); err != nil {
+		t.Fatalf("NodePublishVolume() error = %v", err)
+	}
+	if _, err := client.NodeUnpublishVolume(ctx, &csi.NodeUnpublishVolumeRequest{
+		VolumeId:   upgradeVolumeID,
+		TargetPath: targetPath,
+	}); err != nil {
+		t.Fatalf("NodeUnpublishVolume() error = %v", err)
+	}
+	if _, err := client.NodeUnstageVolume(ctx, &csi.NodeUnstageVolumeRequest{
+		VolumeId:          upgradeVolumeID,
+		StagingTargetPath: stagingPath,
+	}); err != nil {
+		t.Fatalf("NodeUnstageVolume() error = %v", err)
+	}
+}
+
+// newUpgradeNodeServer returns a node server using the given mocks, and the
+// staging and target paths to use with it.
+func newUpgradeNodeServer(t *testing.T, mounter *mocks.MockMounter, executor *mocks.MockExecutor, fs *mocks.MockFileSystem, crypt *mocks.MockCryptSetupClient) (ns *NodeServer, stagingPath, targetPath string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	stagingPath = filepath.Join(dir, "staging")
+	targetPath = filepath.Join(dir, "target")
+	for _, path := range []string{stagingPath, targetPath} {
+		if err := os.Mkdir(path, rwPermission); err != nil {
+			t.Fatalf("create %s: %v", path, err)
+		}
+	}
+
+	ns = &NodeServer{
+		driver: &LinodeDriver{},
+		mounter: &mount.SafeFormatAndMount{
+			Interface: mounter,
+			Exec:      executor,
+		},
+		deviceutils: devicemanager.NewDeviceUtils(fs, executor),
+		encrypt:     NewLuksEncryption(executor, fs, crypt),
+	}
+	return ns, stagingPath, targetPath
+}
+
+// TestNodeStagesPreviousReleaseContexts checks that the node plugin stages
+// volumes from the contexts the previous release of the controller produced.
+func TestNodeStagesPreviousReleaseContexts(t *testing.T) {
+	tests := []struct {
+		name       string
+		seq        nodeStageSequence
+		mapperName string
+	}{
+		{
+			name: "Unencrypted volume",
+			seq: nodeStageSequence{
+				publishContext: previousReleasePublishContext,
+				volumeContext:  previousReleaseVolumeContext,
+			},
+		},
+		{
+			name: "LUKS volume",
+			seq: nodeStageSequence{
+				publishContext: previousReleasePublishContext,
+				volumeContext:  previousReleaseLuksVolumeContext,
+				secrets: map[string]string{
+					legacyLuksSecretKey: upgradeLuksKey,
+				},
+			},
+			mapperName: upgradeVolumeName,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockMounter := mocks.NewMockMounter(ctrl)
+			mockExec := mocks.NewMockExecutor(ctrl)
+			mockFS := mocks.NewMockFileSystem(ctrl)
+			mockCrypt := mocks.NewMockCryptSetupClient(ctrl)
+
+			ns, stagingPath, targetPath := newUpgradeNodeServer(t, mockMounter, mockExec, mockFS, mockCrypt)
+			expectNodeLifecycle(ctrl, mockMounter, mockExec, mockFS, mockCrypt, stagingPath, targetPath, tt.mapperName)
+
+			conn := serveCSI(t, nil, ns)
+			runNodeLifecycle(t, csi.NewNodeClient(conn), tt.seq, stagingPath, targetPath)
+		})
+	}
+}
+
+// TestControllerKeepsPreviousReleaseContexts checks that the controller
+// returns every context key the previous release did, with the same value,
+// and that the volume can be staged from those keys alone, the only ones the
+// previous release of the node plugin reads.
+func TestControllerKeepsPreviousReleaseContexts(t *testing.T) {
+	tests := []struct {
+		name       string
+		parameters map[string]string
+		// wantVolumeContext is the volume context the previous release
+		// returned.
+		wantVolumeContext map[string]string
+		mapperName        string
+	}{
+		{
+			name: "Unencrypted volume",
+			parameters: map[string]string{
+				FilesystemTypeAttribute: "ext4",
+				PVCNameParameter:        "data",
+				PVCNamespaceParameter:   "default",
+			},
+			wantVolumeContext: previousReleaseVolumeContext,
+		},
+		{
+			name: "LUKS volume",
+			parameters: map[string]string{
+				LuksEncryptedAttribute:  "true",
+				LuksCipherAttribute:     "aes-xts-plain64",
+				LuksKeySizeAttribute:    "512",
+				FilesystemTypeAttribute: "ext4",
+				PVCNameParameter:        "data",
+				PVCNamespaceParameter:   "default",
+			},
+			wantVolumeContext: previousReleaseLuksVolumeContext,
+			mapperName:        upgradeVolumeName,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			ctx, cancel := context.WithTimeout(context.Background(), upgradeTimeout)
+			defer cancel()
+
+			mockClient := mocks.NewMockLinodeClient(ctrl)
+			volume := &linodego.Volume{
+				ID:             1003,
+				Label:          upgradeVolumeName,
+				Size:           10,
+				Status:         linodego.VolumeActive,
+				FilesystemPath: "/dev/disk/by-id/scsi-0Linode_Volume_pvc123",
+			}
+			attached := *volume
+			attached.LinodeID = createLinodeID(1003)
+			mockClient.EXPECT().ListVolumes(gomock.Any(), gomock.Any()).Return(nil, nil)
+			mockClient.EXPECT().CreateVolume(gomock.Any(), gomock.Any()).Return(volume, nil)
+			mockClient.EXPECT().WaitForVolumeStatus(gomock.Any(), 1003, linodego.VolumeActive, gomock.Any()).Return(volume, nil)
+			mockClient.EXPECT().GetInstance(gomock.Any(), 1003).Return(&linodego.Instance{ID: 1003, Specs: &linodego.InstanceSpec{Memory: 16 << 10}}, nil)
+			mockClient.EXPECT().GetVolume(gomock.Any(), 1003).Return(&attached, nil)
+
+			conn := serveCSI(t, &ControllerServer{client: mockClient, driver: &LinodeDriver{}}, nil)
+			client := csi.NewControllerClient(conn)
+
+			capability := &csi.VolumeCapability{
+				AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+				AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+			}
+			created, err := client.CreateVolume(ctx, &csi.CreateVolumeRequest{
+				Name:               upgradeVolumeName,
+				VolumeCapabilities: []*csi.VolumeCapability{capability},
+				Parameters:         tt.parameters,
+			})
+			if err != nil {
+				t.Fatalf("CreateVolume() error = %v", err)
+			}
+			if got := created.GetVolume().GetVolumeId(); got != upgradeVolumeID {
+				t.Fatalf("CreateVolume() volume ID = %q, want %q", got, upgradeVolumeID)
+			}
+			volumeContext := created.GetVolume().GetVolumeContext()
+			for key, want := range tt.wantVolumeContext {
+				if got, ok := volumeContext[key]; !ok || got != want {
+					t.Errorf("CreateVolume() volume context[%q] = %q, want %q", key, got, want)
+				}
+			}
+
+			published, err := client.ControllerPublishVolume(ctx, &csi.ControllerPublishVolumeRequest{
+				VolumeId:         upgradeVolumeID,
+				NodeId:           upgradeNodeID,
+				VolumeCapability: capability,
+				VolumeContext:    volumeContext,
+			})
+			if err != nil {
+				t.Fatalf("ControllerPublishVolume() error = %v", err)
+			}
+			publishContext := published.GetPublishContext()
+			for key, want := range previousReleasePublishContext {
+				if got, ok := publishContext[key]; !ok || got != want {
+					t.Errorf("ControllerPublishVolume() publish context[%q] = %q, want %q", key, got, want)
+				}
+			}
+
+			// The previous release of the node plugin only reads the keys
+			// the previous release of the controller returned, so the
+			// volume must stage with nothing else.
+			legacyVolumeContext := make(map[string]string)
+			for key := range tt.wantVolumeContext {
+				legacyVolumeContext[key] = volumeContext[key]
+			}
+			seqs := []struct {
+				name string
+				seq  nodeStageSequence
+			}{
+				{
+					name: "Legacy keys only",
+					seq: nodeStageSequence{
+						volumeContext:  legacyVolumeContext,
+						publishContext: map[string]string{legacyDevicePathKey: publishContext[legacyDevicePathKey]},
+						secrets:        map[string]string{legacyLuksSecretKey: upgradeLuksKey},
+					},
+				},
+				{
+					name: "All keys",
+					seq: nodeStageSequence{
+						volumeContext:  volumeContext,
+						publishContext: publishContext,
+						secrets:        map[string]string{legacyLuksSecretKey: upgradeLuksKey},
+					},
+				},
+			}
+			for _, st := range seqs {
+				t.Run(st.name, func(t *testing.T) {
+					nodeCtrl := gomock.NewController(t)
+					defer nodeCtrl.Finish()
+
+					mockMounter := mocks.NewMockMounter(nodeCtrl)
+					mockExec := mocks.NewMockExecutor(nodeCtrl)
+					mockFS := mocks.NewMockFileSystem(nodeCtrl)
+					mockCrypt := mocks.NewMockCryptSetupClient(nodeCtrl)
+
+					ns, stagingPath, targetPath := newUpgradeNodeServer(t, mockMounter, mockExec, mockFS, mockCrypt)
+					expectNodeLifecycle(nodeCtrl, mockMounter, mockExec, mockFS, mockCrypt, stagingPath, targetPath, tt.mapperName)
+
+					conn := serveCSI(t, nil, ns)
+					runNodeLifecycle(t, csi.NewNodeClient(conn), st.seq, stagingPath, targetPath)
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+
+	kubeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/kube-client"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+const (
+	// VolumeUsageAnnotation is the PersistentVolumeClaim annotation the
+	// node plugin writes the observed usage of a volume to, as a percentage
+	// of its capacity.
+	VolumeUsageAnnotation = Name + "/usage-percent"
+
+	// PVCNameParameter and PVCNamespaceParameter are set by the external
+	// provisioner when it runs with --extra-create-metadata. They are passed
+	// to the node plugin through the volume context, so it knows which claim
+	// to annotate.
+	PVCNameParameter      = "csi.storage.k8s.io/pvc/name"
+	PVCNamespaceParameter = "csi.storage.k8s.io/pvc/namespace"
+)
+
+// volumeUsage is the usage of a staged volume, and the claim it belongs to.
+type volumeUsage struct {
+	// namespace and name are empty if the volume has no claim, in which
+	// case its usage is not reported.
+	namespace string
+	name      string
+
+	// percent is the last observed usage, and reported the last one written
+	// to the claim. Both are -1 until known.
+	percent  int
+	reported int
+}
+
+// usageReporter periodically writes the usage observed by NodeGetVolumeStats
+// to the PersistentVolumeClaims of the volumes staged on the node.
+type usageReporter struct {
+	client   kubeclient.KubeClient
+	interval time.Duration
+
+	mu      sync.Mutex // protects volumes
+	volumes map[string]*volumeUsage
+}
+
+func newUsageReporter(client kubeclient.KubeClient, interval time.Duration) *usageReporter {
+	return &usageReporter{
+		client:   client,
+		interval: interval,
+		volumes:  make(map[string]*volumeUsage),
+	}
+}
+
+// track starts reporting the usage of volumeID, if volumeContext identifies
+// its PersistentVolumeClaim.
+func (u *usageReporter) track(volumeID string, volumeContext map[string]string) {
+	namespace, name := volumeContext[PVCNamespaceParameter], volumeContext[PVCNameParameter]
+	if namespace == "" || name == "" {
+		return
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if _, ok := u.volumes[volumeID]; !ok {
+		u.volumes[volumeID] = &volumeUsage{namespace: namespace, name: name, percent: -1, reported: -1}
+	}
+}
+
+// trackPublished starts reporting the usage of volumeID, published at
+// volumePath, unless it is already tracked. The node plugin only learns the
+// claim of a volume when it is staged, so this recovers the volumes staged
+// before it restarted: their claim is that of the PersistentVolume the
+// kubelet published at volumePath.
+func (u *usageReporter) trackPublished(ctx context.Context, volumeID, volumePath string) {
+	log := logger.GetLogger(ctx)
+
+	u.mu.Lock()
+	_, ok := u.volumes[volumeID]
+	u.mu.Unlock()
+	if ok {
+		return
+	}
+
+	var namespace, name string
+	if pvName, ok := persistentVolumeName(volumePath); ok {
+		var err error
+		namespace, name, err = u.client.GetPersistentVolumeClaimRef(ctx, pvName)
+		if err != nil && !errors.Is(err, kubeclient.ErrNotFound) {
+			// Try again on the next stats request.
+			log.Error(err, "Failed to find the claim of a volume", "volumeID", volumeID, "pv", pvName)
+			return
+		}
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if _, ok := u.volumes[volumeID]; !ok {
+		log.V(4).Info("Tracking the usage of a published volume", "volumeID", volumeID, "pvc", namespace+"/"+name)
+		u.volumes[volumeID] = &volumeUsage{namespace: namespace, name: name, percent: -1, reported: -1}
+	}
+}
+
+// persistentVolumeName returns the name of the PersistentVolume the kubelet
+// published at volumePath, of the form
+// <kubelet dir>/pods/<pod UID>/volumes/kubernetes.io~csi/<PV name>/mount.
+// It returns false for other paths, such as those of block volumes.
+func persistentVolumeName(volumePath string) (string, bool) {
+	volumeDir, mount := filepath.Split(filepath.Clean(volumePath))
+	if mount != "mount" {
+		return "", false
+	}
+	pluginDir, pvName := filepath.Split(filepath.Clean(volumeDir))
+	if filepath.Base(pluginDir) != "kubernetes.io~csi" || pvName == "" {
+		return "", false
+	}
+	return pvName, true
+}
+
+// untrack stops reporting the usage of volumeID.
+func (u *usageReporter) untrack(volumeID string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	delete(u.volumes, volumeID)
+}
+
+// observe records the usage of volumeID from the stats returned by
+// NodeGetVolumeStats.
+func (u *usageReporter) observe(volumeID string, stats *csi.NodeGetVolumeStatsResponse) {
+	for _, usage := range stats.GetUsage() {
+		if usage.GetUnit() != csi.VolumeUsage_BYTES || usage.GetTotal() <= 0 {
+			continue
+		}
+		// Block volumes only report their size
+		if usage.GetUsed() == 0 && usage.GetAvailable() == 0 {
+			return
+		}
+
+		u.mu.Lock()
+		if vol, ok := u.volumes[volumeID]; ok {
+			vol.percent = int(usage.GetUsed() * 100 / usage.GetTotal())
+		}
+		u.mu.Unlock()
+		return
+	}
+}
+
+// run reports usage every interval until ctx is canceled.
+func (u *usageReporter) run(ctx context.Context) {
+	ticker := time.NewTicker(u.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			u.report(ctx)
+		}
+	}
+}
+
+// report writes the usage of every volume that changed since the last report.
+func (u *usageReporter) report(ctx context.Context) {
+	log := logger.GetLogger(ctx)
+
+	type pending struct {
+		volumeID string
+		usage    volumeUsage
+	}
+	var changed []pending
+	u.mu.Lock()
+	for volumeID, vol := range u.volumes {
+		if vol.name != "" && vol.percent >= 0 && vol.percent != vol.reported {
+			changed = append(changed, pending{volumeID: volumeID, usage: *vol})
+		}
+	}
+	u.mu.Unlock()
+
+	for _, p := range changed {
+		annotations := map[string]string{VolumeUsageAnnotation: strconv.Itoa(p.usage.percent)}
+		if err := u.client.PatchPersistentVolumeClaimAnnotations(ctx, p.usage.namespace, p.usage.name, annotations); err != nil {
+			log.Error(err, "Failed to report volume usage", "volumeID", p.volumeID, "pvc", p.usage.namespace+"/"+p.usage.name)
+			continue
+		}
+
+		u.mu.Lock()
+		if vol, ok := u.volumes[p.volumeID]; ok {
+			vol.reported = p.usage.percent
+		}
+		u.mu.Unlock()
+	}
+}
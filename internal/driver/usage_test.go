@@ -0,0 +1,120 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"go.uber.org/mock/gomock"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+	kubeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/kube-client"
+)
+
+func volumeStats(used, total int64) *csi.NodeGetVolumeStatsResponse {
+	return &csi.NodeGetVolumeStatsResponse{
+		Usage: []*csi.VolumeUsage{
+			{Used: 10, Total: 100, Unit: csi.VolumeUsage_INODES},
+			{Used: used, Total: total, Unit: csi.VolumeUsage_BYTES},
+		},
+	}
+}
+
+func TestUsageReporter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockClient := mocks.NewMockKubeClient(ctrl)
+	ctx := context.Background()
+
+	u := newUsageReporter(mockClient, 0)
+	u.track("1001-vol", map[string]string{PVCNamespaceParameter: "default", PVCNameParameter: "data"})
+	u.track("1002-vol", map[string]string{}) // no claim, never reported
+
+	// Nothing is reported before usage is observed.
+	u.report(ctx)
+
+	u.observe("1001-vol", volumeStats(25, 100))
+	u.observe("1002-vol", volumeStats(50, 100))
+	mockClient.EXPECT().PatchPersistentVolumeClaimAnnotations(gomock.Any(), "default", "data", map[string]string{VolumeUsageAnnotation: "25"}).Return(nil)
+	u.report(ctx)
+
+	// Unchanged usage is not reported again.
+	u.report(ctx)
+
+	// The size of block volumes is not usage.
+	u.observe("1001-vol", &csi.NodeGetVolumeStatsResponse{Usage: []*csi.VolumeUsage{{Total: 100, Unit: csi.VolumeUsage_BYTES}}})
+	u.report(ctx)
+
+	// Failed reports are retried.
+	u.observe("1001-vol", volumeStats(60, 100))
+	gomock.InOrder(
+		mockClient.EXPECT().PatchPersistentVolumeClaimAnnotations(gomock.Any(), "default", "data", map[string]string{VolumeUsageAnnotation: "60"}).Return(errors.New("forbidden")),
+		mockClient.EXPECT().PatchPersistentVolumeClaimAnnotations(gomock.Any(), "default", "data", map[string]string{VolumeUsageAnnotation: "60"}).Return(nil),
+	)
+	u.report(ctx)
+	u.report(ctx)
+
+	// Unstaged volumes are no longer reported.
+	u.untrack("1001-vol")
+	u.observe("1001-vol", volumeStats(70, 100))
+	u.report(ctx)
+}
+
+func TestUsageReporterTrackPublished(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockClient := mocks.NewMockKubeClient(ctrl)
+	ctx := context.Background()
+
+	const podDir = "/var/lib/kubelet/pods/8d6c1b5e/volumes/kubernetes.io~csi/"
+	u := newUsageReporter(mockClient, 0)
+
+	// Volumes staged before a restart are tracked from their published path.
+	mockClient.EXPECT().GetPersistentVolumeClaimRef(gomock.Any(), "pvc-1001").Return("default", "data", nil)
+	u.trackPublished(ctx, "1001-vol", podDir+"pvc-1001/mount")
+	u.observe("1001-vol", volumeStats(25, 100))
+	mockClient.EXPECT().PatchPersistentVolumeClaimAnnotations(gomock.Any(), "default", "data", map[string]string{VolumeUsageAnnotation: "25"}).Return(nil)
+	u.report(ctx)
+
+	// Tracked volumes are not looked up again.
+	u.trackPublished(ctx, "1001-vol", podDir+"pvc-1001/mount")
+
+	// Failed lookups are retried, and volumes without a claim are never
+	// reported.
+	gomock.InOrder(
+		mockClient.EXPECT().GetPersistentVolumeClaimRef(gomock.Any(), "pvc-1002").Return("", "", errors.New("forbidden")),
+		mockClient.EXPECT().GetPersistentVolumeClaimRef(gomock.Any(), "pvc-1002").Return("", "", kubeclient.ErrNotFound),
+	)
+	u.trackPublished(ctx, "1002-vol", podDir+"pvc-1002/mount")
+	u.trackPublished(ctx, "1002-vol", podDir+"pvc-1002/mount")
+	u.trackPublished(ctx, "1002-vol", podDir+"pvc-1002/mount")
+	u.observe("1002-vol", volumeStats(50, 100))
+	u.report(ctx)
+
+	// Block volumes are not looked up.
+	u.trackPublished(ctx, "1003-vol", "/var/lib/kubelet/plugins/kubernetes.io/csi/volumeDevices/publish/pvc-1003/8d6c1b5e")
+	u.observe("1003-vol", volumeStats(50, 100))
+	u.report(ctx)
+}
+
+func TestPersistentVolumeName(t *testing.T) {
+	tests := []struct {
+		path   string
+		want   string
+		wantOK bool
+	}{
+		{path: "/var/lib/kubelet/pods/8d6c1b5e/volumes/kubernetes.io~csi/pvc-1001/mount", want: "pvc-1001", wantOK: true},
+		{path: "/var/lib/kubelet/pods/8d6c1b5e/volumes/kubernetes.io~csi/pvc-1001/mount/", want: "pvc-1001", wantOK: true},
+		{path: "/var/lib/kubelet/plugins/kubernetes.io/csi/linodebs.csi.linode.com/0123abcd/globalmount"},
+		{path: "/var/lib/kubelet/pods/8d6c1b5e/volumes/kubernetes.io~empty-dir/cache/mount"},
+		{path: ""},
+	}
+
+	for _, tt := range tests {
+		got, ok := persistentVolumeName(tt.path)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("persistentVolumeName(%q) = %q, %v, want %q, %v", tt.path, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package driver
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	linodevolumes "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-volumes"
+)
+
+// VolumeNameTemplateData is the data the [Options.VolumeNameTemplate] is
+// executed with, for the volume created by a CreateVolume request.
+type VolumeNameTemplateData struct {
+	// Prefix is the volume label prefix of the driver.
+	Prefix string
+	// ClusterName is the [Options.ClusterName], and ClusterID its short
+	// hash. Both are empty if it is not set.
+	ClusterName string
+	ClusterID   string
+	// PVName is the name of the PersistentVolume, and PVCName and
+	// PVCNamespace identify its claim. They are empty unless the external
+	// provisioner runs with --extra-create-metadata.
+	PVName       string
+	PVCName      string
+	PVCNamespace string
+}
+
+// ParseVolumeNameTemplate parses a template of the labels of the volumes
+// created by the controller, executed with a [VolumeNameTemplateData], e.g.
+// "{{.ClusterID}}-{{.PVCNamespace}}-{{.PVCName}}".
+func ParseVolumeNameTemplate(s string) (*template.Template, error) {
+	tmpl, err := template.New("volume-name").Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid volume name template: %w", err)
+	}
+	// Fields that do not exist only fail when the template is executed
+	if err := tmpl.Execute(&bytes.Buffer{}, VolumeNameTemplateData{}); err != nil {
+		return nil, fmt.Errorf("invalid volume name template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// volumeNameHashLength is the length of the hash of the name of the
+// PersistentVolume ending the labels made from the volume name template,
+// with the "-" before it.
+const volumeNameHashLength = shortHashLength + 1
+
+var (
+	// invalidLabelChars are the characters not allowed in volume labels.
+	invalidLabelChars = regexp.MustCompile(`[^0-9A-Za-z_-]`)
+	// repeatedLabelSeparators are the runs of dashes and underscores, which
+	// are not allowed in volume labels.
+	repeatedLabelSeparators = regexp.MustCompile(`[-_]{2,}`)
+)
+
+// templateVolumeLabel returns the label of the volume created for the
+// PersistentVolume name of the claim pvcNamespace/pvcName, made from the
+// [Options.VolumeNameTemplate] with the volume label prefix.
+//
+// The label is made valid: characters not allowed are replaced with "-",
+// and "pv-" is prepended if it does not start with a letter. Since templates may give the same label to
+// several volumes, e.g. for claims recreated with the same name, and the
+// label is truncated, it always ends with "-" and the short hash of name,
+// which is unique.
+func (d *LinodeDriver) templateVolumeLabel(prefix, name, pvcNamespace, pvcName string) (string, error) {
+	data := VolumeNameTemplateData{
+		Prefix:       prefix,
+		ClusterName:  d.opts.ClusterName,
+		PVName:       name,
+		PVCName:      pvcName,
+		PVCNamespace: pvcNamespace,
+	}
+	if cluster, ok := d.clusterAttribute(); ok {
+		data.ClusterID = cluster.value
+	}
+	var buf bytes.Buffer
+	if err := d.opts.VolumeNameTemplate.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	label := invalidLabelChars.ReplaceAllString(buf.String(), "-")
+	label = repeatedLabelSeparators.ReplaceAllString(label, "-")
+	label = strings.TrimLeft(label, "_-")
+	if label == "" {
+		label = "pv"
+	} else if c := label[0]; c < 'A' || c > 'z' || (c > 'Z' && c < 'a') {
+		label = "pv-" + label
+	}
+	if maxLength := linodevolumes.LinodeVolumeLabelLength - volumeNameHashLength; len(label) > maxLength {
+		label = label[:maxLength]
+	}
+	return strings.TrimRight(label, "_-") + "-" + shortHash(name), nil
+}
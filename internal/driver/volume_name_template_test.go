@@ -0,0 +1,116 @@
+package driver
+
+import (
+	"testing"
+
+	linodevolumes "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-volumes"
+)
+
+func TestParseVolumeNameTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		wantErr  bool
+	}{
+		{name: "Fields", template: "{{.Prefix}}{{.ClusterID}}-{{.PVCNamespace}}-{{.PVCName}}-{{.PVName}}-{{.ClusterName}}"},
+		{name: "Functions", template: `{{printf "%.8s" .PVCName}}`},
+		{name: "Invalid syntax", template: "{{.PVCName", wantErr: true},
+		{name: "Unknown field", template: "{{.Namespace}}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseVolumeNameTemplate(tt.template)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseVolumeNameTemplate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTemplateVolumeLabel(t *testing.T) {
+	const volumeName = "pvc-0a1b2c3d-4e5f-6789-abcd-ef0123456789"
+	hash := shortHash(volumeName)
+
+	tests := []struct {
+		name         string
+		template     string
+		clusterName  string
+		pvcNamespace string
+		pvcName      string
+		wantLabel    string
+	}{
+		{
+			name:         "Claim",
+			template:     "{{.ClusterID}}-{{.PVCNamespace}}-{{.PVCName}}",
+			clusterName:  "cluster-a",
+			pvcNamespace: "web",
+			pvcName:      "data",
+			wantLabel:    "pv-34ab3e-web-data-" + hash,
+		},
+		{
+			name:         "Claim with prefix",
+			template:     "{{.Prefix}}{{.ClusterID}}-{{.PVCNamespace}}-{{.PVCName}}",
+			clusterName:  "cluster-a",
+			pvcNamespace: "web",
+			pvcName:      "data",
+			wantLabel:    "prod-34ab3e-web-data-" + hash,
+		},
+		{
+			name:         "Invalid characters",
+			template:     "{{.PVCNamespace}}.{{.PVCName}}",
+			pvcNamespace: "web",
+			pvcName:      "data.www",
+			wantLabel:    "web-data-www-" + hash,
+		},
+		{
+			name:         "Repeated separators",
+			template:     "{{.PVCNamespace}}--{{.ClusterID}}_-{{.PVCName}}",
+			pvcNamespace: "web",
+			pvcName:      "data",
+			wantLabel:    "web-data-" + hash,
+		},
+		{
+			name:         "Truncated label",
+			template:     "{{.PVCNamespace}}-{{.PVCName}}",
+			pvcNamespace: "monitoring",
+			pvcName:      "prometheus-data-0",
+			wantLabel:    "monitoring-prometheus-dat-" + hash,
+		},
+		{
+			name:         "Truncated at a separator",
+			template:     "{{.PVCNamespace}}-{{.PVCName}}",
+			pvcNamespace: "monitoring",
+			pvcName:      "prometheus-db_data",
+			wantLabel:    "monitoring-prometheus-db-" + hash,
+		},
+		{
+			name:      "Leading digit",
+			template:  "{{.PVCName}}",
+			pvcName:   "0-data",
+			wantLabel: "pv-0-data-" + hash,
+		},
+		{
+			name:      "Empty claim",
+			template:  "{{.PVCNamespace}}-{{.PVCName}}",
+			wantLabel: "pv-" + hash,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := ParseVolumeNameTemplate(tt.template)
+			if err != nil {
+				t.Fatalf("ParseVolumeNameTemplate() error = %v", err)
+			}
+			d := &LinodeDriver{volumeLabelPrefix: "prod-", opts: Options{ClusterName: tt.clusterName, VolumeNameTemplate: tmpl}}
+			label, legacy := d.volumeLabels(volumeName, tt.pvcNamespace, tt.pvcName)
+			if label != tt.wantLabel || legacy != "" {
+				t.Errorf("volumeLabels() = %q, %q, want %q, %q", label, legacy, tt.wantLabel, "")
+			}
+			if len(label) > linodevolumes.LinodeVolumeLabelLength {
+				t.Errorf("label %q is longer than %d characters", label, linodevolumes.LinodeVolumeLabelLength)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package driver
+
+import (
+	"context"
+	"sync"
+
+	"github.com/linode/linodego"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+// maxVolumePolls is the number of volumes the controller polls the Linode
+// API for at once. The requests needing another poll fail with Unavailable
+// until one of them completes.
+const maxVolumePolls = 64
+
+// Kinds of polls, used as the "kind" label of the csi_volume_polls_active
+// and csi_volume_waiters_active metrics.
+const (
+	volumePollActive = "active"
+	volumePollAttach = "attach"
+	volumePollDetach = "detach"
+)
+
+// volumePollKey identifies what a poll waits for: a volume to be active, or
+// to be attached to linodeID, or detached, and the Linode token it polls
+// with, so that requests only wait for the polls made with their own token.
+type volumePollKey struct {
+	kind     string
+	volumeID int
+	linodeID int
+	// token is the hash of the Linode token of the requests, see
+	// [requestTokenHash].
+	token string
+}
+
+// volumePoll is a poll of the Linode API shared by the requests waiting for
+// the same volume.
+type volumePoll struct {
+	// done is closed when the poll completed, with vol and err set.
+	done chan struct{}
+	vol  *linodego.Volume
+	err  error
+
+	// waiters is the number of requests waiting for the poll, which is
+	// stopped with cancel when it drops to zero.
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// volumePoller runs the long waits of the controller for the Linode API, in
+// goroutines it owns, so that the requests retried by the sidecars while the
+// API is slow wait for the poll started by the first one instead of starting
+// their own, and the number of polls is bounded by [maxVolumePolls].
+//
+// A poll runs until it completes or the last request waiting for it is
+// done, whichever comes first.
+//
+// The zero value is ready to use.
+type volumePoller struct {
+	mu    sync.Mutex // protects polls
+	polls map[volumePollKey]*volumePoll
+}
+
+// wait waits for the poll of key, starting it with poll if no other request
+// is waiting for it. It returns the error of ctx if ctx is done first.
+func (p *volumePoller) wait(ctx context.Context, key volumePollKey, poll func(context.Context) (*linodego.Volume, error)) (*linodego.Volume, error) {
+	vp, err := p.join(ctx, key, poll)
+	if err != nil {
+		return nil, err
+	}
+	defer p.leave(key, vp)
+
+	select {
+	case <-vp.done:
+		return vp.vol, vp.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// join adds a waiter to the poll of key, starting it if needed.
+func (p *volumePoller) join(ctx context.Context, key volumePollKey, poll func(context.Context) (*linodego.Volume, error)) (*volumePoll, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	vp, ok := p.polls[key]
+	if !ok {
+		if len(p.polls) >= maxVolumePolls {
+			return nil, errTooManyVolumePolls(key.volumeID)
+		}
+		if p.polls == nil {
+			p.polls = make(map[volumePollKey]*volumePoll)
+		}
+		// The poll outlives the request starting it when others join it
+		pollCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		vp = &volumePoll{done: make(chan struct{}), cancel: cancel}
+		p.polls[key] = vp
+		observability.VolumePollsActive.WithLabelValues(key.kind).Inc()
+		go p.run(pollCtx, key, vp, poll)
+	} else {
+		logger.GetLogger(ctx).V(4).Info("Waiting for the poll of another request", "volume_id", key.volumeID, "kind", key.kind)
+	}
+	vp.waiters++
+	observability.VolumeWaitersActive.WithLabelValues(key.kind).Inc()
+	return vp, nil
+}
+
+// leave removes a waiter from the poll of key, stopping it if it was the
+// last one.
+func (p *volumePoller) leave(key volumePollKey, vp *volumePoll) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	observability.VolumeWaitersActive.WithLabelValues(key.kind).Dec()
+	if vp.waiters--; vp.waiters == 0 {
+		vp.cancel()
+		p.forget(key, vp)
+	}
+}
+
+func (p *volumePoller) run(ctx context.Context, key volumePollKey, vp *volumePoll, poll func(context.Context) (*linodego.Volume, error)) {
+	defer vp.cancel()
+	vol, err := poll(ctx)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	vp.vol, vp.err = vol, err
+	close(vp.done)
+	p.forget(key, vp)
+	observability.VolumePollsActive.WithLabelValues(key.kind).Dec()
+}
+
+// forget removes vp from the polls, so that the next requests start a new
+// one, unless it was already replaced.
+func (p *volumePoller) forget(key volumePollKey, vp *volumePoll) {
+	if p.polls[key] == vp {
+		delete(p.polls, key)
+	}
+}
+
+// waitForVolumeActive waits for volumeID to be active, sharing the poll with
+// the other requests waiting for it. The statuses the volume goes through
+// are recorded in the span of the poll, see [volumeStatusHistory].
+func (cs *ControllerServer) waitForVolumeActive(ctx context.Context, volumeID, timeoutSeconds int) (*linodego.Volume, error) {
+	client := cs.linodeClient(ctx)
+	return cs.poller.wait(ctx, volumePollKey{kind: volumePollActive, volumeID: volumeID, token: requestTokenHash(ctx)}, func(ctx context.Context) (*linodego.Volume, error) {
+		return cs.waitForVolumeStatus(ctx, client, volumeID, linodego.VolumeActive, timeoutSeconds)
+	})
+}
+
+// waitForVolumeLinodeID waits for volumeID to be attached to linodeID, or
+// detached if it is nil, sharing the poll with the other requests waiting
+// for it.
+func (cs *ControllerServer) waitForVolumeLinodeID(ctx context.Context, volumeID int, linodeID *int, timeoutSeconds int) (*linodego.Volume, error) {
+	client := cs.linodeClient(ctx)
+	key := volumePollKey{kind: volumePollDetach, volumeID: volumeID, token: requestTokenHash(ctx)}
+	if linodeID != nil {
+		key.kind, key.linodeID = volumePollAttach, *linodeID
+	}
+	return cs.poller.wait(ctx, key, func(ctx context.Context) (*linodego.Volume, error) {
+		if cs.events != nil {
+			return cs.events.waitForVolume(ctx, client, volumeID, timeoutSeconds, func(vol *linodego.Volume) bool {
+				if linodeID == nil || vol.LinodeID == nil {
+					return linodeID == vol.LinodeID
+				}
+				return *vol.LinodeID == *linodeID
+			})
+		}
+		return client.WaitForVolumeLinodeID(ctx, volumeID, linodeID, timeoutSeconds)
+	})
+}
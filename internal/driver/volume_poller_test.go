@@ -0,0 +1,185 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/linode/linodego"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+	linodeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-client"
+)
+
+func TestVolumePollerShared(t *testing.T) {
+	var p volumePoller
+	key := volumePollKey{kind: volumePollAttach, volumeID: 1001, linodeID: 1003}
+
+	var polls atomic.Int32
+	release := make(chan struct{})
+	poll := func(ctx context.Context) (*linodego.Volume, error) {
+		polls.Add(1)
+		<-release
+		return &linodego.Volume{ID: 1001}, nil
+	}
+
+	// Retried requests wait for the poll of the first one
+	const waiters = 3
+	var wg sync.WaitGroup
+	results := make(chan *linodego.Volume, waiters)
+	for range waiters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			vol, err := p.wait(context.Background(), key, poll)
+			if err != nil {
+				t.Errorf("wait() error = %v", err)
+			}
+			results <- vol
+		}()
+	}
+	waitFor(t, func() bool {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return p.polls[key] != nil && p.polls[key].waiters == waiters
+	})
+	close(release)
+	wg.Wait()
+	close(results)
+
+	if got := polls.Load(); got != 1 {
+		t.Errorf("polls = %d, want 1", got)
+	}
+	for vol := range results {
+		if vol == nil || vol.ID != 1001 {
+			t.Errorf("wait() = %+v, want volume 1001", vol)
+		}
+	}
+	if len(p.polls) != 0 {
+		t.Errorf("polls left = %v", p.polls)
+	}
+}
+
+func TestVolumePollerCanceled(t *testing.T) {
+	var p volumePoller
+	key := volumePollKey{kind: volumePollActive, volumeID: 1001}
+
+	stopped := make(chan struct{})
+	poll := func(ctx context.Context) (*linodego.Volume, error) {
+		<-ctx.Done()
+		close(stopped)
+		return nil, ctx.Err()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.wait(ctx, key, poll); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("wait() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// The poll stops once no request waits for it
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("poll was not stopped")
+	}
+}
+
+func TestVolumePollerBounded(t *testing.T) {
+	var p volumePoller
+	release := make(chan struct{})
+	defer close(release)
+	poll := func(ctx context.Context) (*linodego.Volume, error) {
+		<-release
+		return &linodego.Volume{}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for volumeID := range maxVolumePolls {
+		go func() {
+			_, _ = p.wait(ctx, volumePollKey{kind: volumePollActive, volumeID: volumeID}, poll)
+		}()
+	}
+	waitFor(t, func() bool {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return len(p.polls) == maxVolumePolls
+	})
+
+	_, err := p.wait(ctx, volumePollKey{kind: volumePollActive, volumeID: maxVolumePolls}, poll)
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("wait() error = %v, want Unavailable", err)
+	}
+}
+
+// waitFor waits up to 5 seconds for cond to be true.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWaitForVolumeActiveTokens(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The poll made with the first token only completes once the request
+	// with the second token polls with its own
+	secondPolled := make(chan struct{})
+	clients := map[string]*mocks.MockLinodeClient{"first": mocks.NewMockLinodeClient(ctrl), "second": mocks.NewMockLinodeClient(ctrl)}
+	clients["first"].EXPECT().WaitForVolumeStatus(gomock.Any(), 1001, linodego.VolumeActive, 10).DoAndReturn(
+		func(ctx context.Context, volumeID int, _ linodego.VolumeStatus, _ int) (*linodego.Volume, error) {
+			select {
+			case <-secondPolled:
+				return &linodego.Volume{ID: volumeID, Status: linodego.VolumeActive}, nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		})
+	clients["second"].EXPECT().WaitForVolumeStatus(gomock.Any(), 1001, linodego.VolumeActive, 10).DoAndReturn(
+		func(_ context.Context, volumeID int, _ linodego.VolumeStatus, _ int) (*linodego.Volume, error) {
+			close(secondPolled)
+			return &linodego.Volume{ID: volumeID, Status: linodego.VolumeActive}, nil
+		})
+	cs := &ControllerServer{
+		driver: &LinodeDriver{opts: Options{NewLinodeClient: func(token string) (linodeclient.LinodeClient, error) {
+			return clients[token], nil
+		}}},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, token := range []string{"first", "second"} {
+		reqCtx, err := cs.withSecrets(ctx, map[string]string{LinodeTokenSecretKey: token})
+		if err != nil {
+			t.Fatalf("withSecrets(%q) error = %v", token, err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cs.waitForVolumeActive(reqCtx, 1001, 10); err != nil {
+				t.Errorf("waitForVolumeActive() with token %q error = %v", token, err)
+			}
+		}()
+		// The second request starts while the first one is polling
+		waitFor(t, func() bool {
+			cs.poller.mu.Lock()
+			defer cs.poller.mu.Unlock()
+			return len(cs.poller.polls) > 0
+		})
+	}
+	wg.Wait()
+}
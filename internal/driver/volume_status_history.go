@@ -0,0 +1,140 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/linode/linodego"
+	otelattribute "go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	linodeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-client"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+// volumeStatusPollInterval is how often the controller gets a volume it
+// waits for while tracing is enabled, like [linodego.Client.WaitForVolumeStatus]
+// does.
+var volumeStatusPollInterval = linodego.APISecondsPerPoll * time.Second
+
+// volumeStatusHistory records the statuses a volume goes through while the
+// controller waits for it, with the ID of the latest Linode event of the
+// volume, as events of the span of the wait and debug logs, so that the
+// trace of a slow provisioning shows how long the volume spent in each
+// status.
+type volumeStatusHistory struct {
+	volumeID int
+	// events are the account events the IDs of the Linode events are taken
+	// from, or nil if they are disabled.
+	events *accountEvents
+	span   trace.Span
+
+	start   time.Time
+	since   time.Time
+	status  linodego.VolumeStatus
+	eventID int
+}
+
+func newVolumeStatusHistory(ctx context.Context, volumeID int, events *accountEvents) *volumeStatusHistory {
+	now := time.Now()
+	return &volumeStatusHistory{
+		volumeID: volumeID,
+		events:   events,
+		span:     trace.SpanFromContext(ctx),
+		start:    now,
+		since:    now,
+	}
+}
+
+// observe records the status of vol and the latest Linode event of the
+// volume if either changed since the last time vol was observed.
+func (h *volumeStatusHistory) observe(ctx context.Context, vol *linodego.Volume) {
+	eventID := h.eventID
+	if h.events != nil {
+		if event := h.events.latestEvent(h.volumeID); event != nil {
+			eventID = event.ID
+		}
+	}
+	if vol.Status == h.status && eventID == h.eventID {
+		return
+	}
+
+	now := time.Now()
+	attrs := []otelattribute.KeyValue{
+		otelattribute.Int("volume_id", h.volumeID),
+		otelattribute.String("status", string(vol.Status)),
+		otelattribute.String("previous_status", string(h.status)),
+		otelattribute.Float64("previous_status_seconds", now.Sub(h.since).Seconds()),
+		otelattribute.Float64("elapsed_seconds", now.Sub(h.start).Seconds()),
+	}
+	if eventID != 0 {
+		attrs = append(attrs, otelattribute.Int("linode_event_id", eventID))
+	}
+	h.span.AddEvent("volume status", trace.WithAttributes(attrs...))
+	logger.GetLogger(ctx).V(4).Info("Volume status observed", "volume_id", h.volumeID, "status", vol.Status, "previous_status", h.status,
+		"previous_status_duration", now.Sub(h.since), "elapsed", now.Sub(h.start), "linode_event_id", eventID)
+
+	if vol.Status != h.status {
+		h.status, h.since = vol.Status, now
+	}
+	h.eventID = eventID
+}
+
+// pollVolumeStatus gets volumeID with client every
+// [volumeStatusPollInterval], recording its statuses in h, until it has
+// status or timeoutSeconds elapsed. It replaces
+// [linodego.Client.WaitForVolumeStatus] when tracing is enabled, since the
+// statuses it gets are not returned.
+func pollVolumeStatus(ctx context.Context, client linodeclient.LinodeClient, volumeID int, status linodego.VolumeStatus, timeoutSeconds int, h *volumeStatusHistory) (*linodego.Volume, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeoutSeconds)*time.Second)
+	defer cancel()
+
+	ticker := time.NewTicker(volumeStatusPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			vol, err := client.GetVolume(ctx, volumeID)
+			if err != nil {
+				return vol, err
+			}
+			h.observe(ctx, vol)
+			if vol.Status == status {
+				return vol, nil
+			}
+		case <-ctx.Done():
+			return nil, fmt.Errorf("wait for volume %d to be %s, still %s: %w", volumeID, status, h.status, ctx.Err())
+		}
+	}
+}
+
+// waitForVolumeStatus waits for volumeID to have status, recording the
+// statuses it goes through in a span when tracing is enabled. The wait is fed
+// by the account events, if enabled.
+func (cs *ControllerServer) waitForVolumeStatus(ctx context.Context, client linodeclient.LinodeClient, volumeID int, status linodego.VolumeStatus, timeoutSeconds int) (*linodego.Volume, error) {
+	if !observability.SkipObservability {
+		var span trace.Span
+		ctx, span = observability.Tracer.Start(ctx, "waitForVolumeStatus", trace.WithAttributes(
+			otelattribute.Int("volume_id", volumeID), otelattribute.String("status", string(status))))
+		defer span.End()
+	}
+	h := newVolumeStatusHistory(ctx, volumeID, cs.events)
+
+	if cs.events != nil {
+		return cs.events.waitForVolume(ctx, client, volumeID, timeoutSeconds, func(vol *linodego.Volume) bool {
+			h.observe(ctx, vol)
+			return vol.Status == status
+		})
+	}
+	if !observability.SkipObservability {
+		return pollVolumeStatus(ctx, client, volumeID, status, timeoutSeconds, h)
+	}
+	vol, err := client.WaitForVolumeStatus(ctx, volumeID, status, timeoutSeconds)
+	if err == nil {
+		h.observe(ctx, vol)
+	}
+	return vol, err
+}
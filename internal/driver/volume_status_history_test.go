@@ -0,0 +1,112 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/linode/linodego"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/mock/gomock"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+func TestWaitForVolumeStatusHistory(t *testing.T) {
+	// The volume is resized after being created, as its Linode events tell
+	gets := []struct {
+		status linodego.VolumeStatus
+		event  *linodego.Event
+	}{
+		{linodego.VolumeCreating, &linodego.Event{ID: 501, Action: linodego.ActionVolumeCreate}},
+		{linodego.VolumeCreating, nil},
+		{linodego.VolumeResizing, &linodego.Event{ID: 502, Action: linodego.ActionVolumeResize}},
+		{linodego.VolumeActive, nil},
+	}
+
+	tests := []struct {
+		name         string
+		events       bool
+		wantStatuses string
+		wantEventIDs string
+	}{
+		{
+			name:         "Polled",
+			wantStatuses: "[creating resizing active]",
+			wantEventIDs: "[0 0 0]",
+		},
+		{
+			name:         "Fed by account events",
+			events:       true,
+			wantStatuses: "[creating resizing active]",
+			wantEventIDs: "[501 502 502]",
+		},
+	}
+
+	defer func(skip bool, interval time.Duration, tracer trace.Tracer) {
+		observability.SkipObservability, volumeStatusPollInterval, observability.Tracer = skip, interval, tracer
+	}(observability.SkipObservability, volumeStatusPollInterval, observability.Tracer)
+	observability.SkipObservability, volumeStatusPollInterval = false, time.Millisecond
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			recorder := tracetest.NewSpanRecorder()
+			observability.Tracer = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+			mockClient := mocks.NewMockLinodeClient(ctrl)
+			cs := &ControllerServer{}
+			if tt.events {
+				cs.events = newAccountEvents(mockClient)
+			}
+			calls := 0
+			mockClient.EXPECT().GetVolume(gomock.Any(), 1001).DoAndReturn(func(context.Context, int) (*linodego.Volume, error) {
+				get := gets[calls]
+				calls++
+				if cs.events != nil {
+					// Wakes the wait up for the next get
+					event := get.event
+					if event == nil {
+						event = &linodego.Event{ID: 1, Action: linodego.ActionVolumeUpdate}
+					}
+					event.Entity = &linodego.EventEntity{ID: 1001, Type: linodego.EntityVolume}
+					cs.events.publish(event, accountEventSourcePush)
+				}
+				return &linodego.Volume{ID: 1001, Status: get.status}, nil
+			}).Times(len(gets))
+
+			vol, err := cs.waitForVolumeStatus(context.Background(), mockClient, 1001, linodego.VolumeActive, 10)
+			if err != nil || vol.Status != linodego.VolumeActive {
+				t.Fatalf("waitForVolumeStatus() = %v, %v, want an active volume", vol, err)
+			}
+
+			spans := recorder.Ended()
+			if len(spans) != 1 {
+				t.Fatalf("got %d spans, want 1", len(spans))
+			}
+			var statuses []string
+			var eventIDs []int64
+			for _, event := range spans[0].Events() {
+				var eventID int64
+				for _, attr := range event.Attributes {
+					switch attr.Key {
+					case "status":
+						statuses = append(statuses, attr.Value.AsString())
+					case "linode_event_id":
+						eventID = attr.Value.AsInt64()
+					}
+				}
+				eventIDs = append(eventIDs, eventID)
+			}
+			if fmt.Sprint(statuses) != tt.wantStatuses || fmt.Sprint(eventIDs) != tt.wantEventIDs {
+				t.Errorf("span events = %v with event IDs %v, want %v with %v", statuses, eventIDs, tt.wantStatuses, tt.wantEventIDs)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package driver
+
+import "sync"
+
+// volumeLocks serializes node operations on the same volume, while letting
+// operations on different volumes run concurrently.
+//
+// Operations on encrypted volumes are additionally serialized on the device
+// itself by the cryptsetup client.
+//
+// The zero value is ready to use.
+type volumeLocks struct {
+	mu     sync.Mutex // protects locked
+	locked map[string]struct{}
+}
+
+// tryAcquire locks volumeID, and returns false if it is already locked.
+func (v *volumeLocks) tryAcquire(volumeID string) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if _, ok := v.locked[volumeID]; ok {
+		return false
+	}
+	if v.locked == nil {
+		v.locked = make(map[string]struct{})
+	}
+	v.locked[volumeID] = struct{}{}
+	return true
+}
+
+// release unlocks volumeID.
+func (v *volumeLocks) release(volumeID string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	delete(v.locked, volumeID)
+}
@@ -0,0 +1,58 @@
+package driver
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestVolumeLocks(t *testing.T) {
+	var locks volumeLocks
+
+	if !locks.tryAcquire("vol-1") {
+		t.Fatal("tryAcquire(vol-1) = false, want true")
+	}
+	if locks.tryAcquire("vol-1") {
+		t.Error("tryAcquire(vol-1) = true while it is locked, want false")
+	}
+	if !locks.tryAcquire("vol-2") {
+		t.Error("tryAcquire(vol-2) = false while only vol-1 is locked, want true")
+	}
+
+	locks.release("vol-1")
+	if !locks.tryAcquire("vol-1") {
+		t.Error("tryAcquire(vol-1) = false after it was released, want true")
+	}
+
+	// Releasing a volume that is not locked is a no-op.
+	locks.release("vol-3")
+}
+
+func TestVolumeLocksConcurrent(t *testing.T) {
+	var locks volumeLocks
+
+	const goroutines = 50
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		acquired int
+	)
+	start := make(chan struct{})
+	for range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if locks.tryAcquire("vol-1") {
+				mu.Lock()
+				acquired++
+				mu.Unlock()
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if acquired != 1 {
+		t.Errorf("%d goroutines acquired the lock, want 1", acquired)
+	}
+}
@@ -0,0 +1,259 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+
+	filesystem "github.com/linode/linode-blockstorage-csi-driver/pkg/filesystem"
+	kubeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/kube-client"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+// Reasons of the events the mount watchdog records on the
+// PersistentVolumeClaims of the volumes whose mounts vanished.
+const (
+	mountLostReason          = "VolumeMountLost"
+	mountRestoredReason      = "VolumeMountRestored"
+	mountRestoreFailedReason = "VolumeMountRestoreFailed"
+)
+
+// Kinds of mounts, used as the "kind" label of the
+// csi_node_mount_recoveries_total metric.
+const (
+	mountKindStaging = "staging"
+	mountKindTarget  = "target"
+)
+
+// watchedVolume is what the mount watchdog needs to mount a volume again:
+// the requests that staged and published it.
+type watchedVolume struct {
+	stage     *csi.NodeStageVolumeRequest
+	publishes map[string]*csi.NodePublishVolumeRequest // By target path
+}
+
+// mountWatchdog periodically checks that the file system volumes staged and
+// published on the node are still mounted, and stages and publishes again
+// the volumes whose mounts vanished while they were in use, e.g. because the
+// mount helper was killed or udev plugged the device again.
+//
+// The requests are kept in memory, secrets included, so the volumes staged
+// before the node plugin started are not watched. Block volumes are not
+// watched either.
+type mountWatchdog struct {
+	// client records events on the PersistentVolumeClaims of the volumes,
+	// if it is set.
+	client   kubeclient.KubeClient
+	interval time.Duration
+
+	mu      sync.Mutex // protects volumes
+	volumes map[string]*watchedVolume
+}
+
+func newMountWatchdog(client kubeclient.KubeClient, interval time.Duration) *mountWatchdog {
+	return &mountWatchdog{
+		client:   client,
+		interval: interval,
+		volumes:  make(map[string]*watchedVolume),
+	}
+}
+
+// trackStage starts watching the staging mount of the volume staged by req.
+func (w *mountWatchdog) trackStage(req *csi.NodeStageVolumeRequest) {
+	if req.GetVolumeCapability().GetBlock() != nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if vol, ok := w.volumes[req.GetVolumeId()]; ok {
+		vol.stage = req
+		return
+	}
+	w.volumes[req.GetVolumeId()] = &watchedVolume{stage: req, publishes: make(map[string]*csi.NodePublishVolumeRequest)}
+}
+
+// trackPublish starts watching the mount of the volume published by req, if
+// its staging mount is watched.
+func (w *mountWatchdog) trackPublish(req *csi.NodePublishVolumeRequest) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if vol, ok := w.volumes[req.GetVolumeId()]; ok {
+		vol.publishes[req.GetTargetPath()] = req
+	}
+}
+
+// untrackPublish stops watching the mount of volumeID at targetPath.
+func (w *mountWatchdog) untrackPublish(volumeID, targetPath string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if vol, ok := w.volumes[volumeID]; ok {
+		delete(vol.publishes, targetPath)
+	}
+}
+
+// untrack stops watching the mounts of volumeID.
+func (w *mountWatchdog) untrack(volumeID string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.volumes, volumeID)
+}
+
+// watched returns a copy of the requests of volumeID, if it is watched.
+func (w *mountWatchdog) watched(volumeID string) (watchedVolume, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	vol, ok := w.volumes[volumeID]
+	if !ok {
+		return watchedVolume{}, false
+	}
+	return watchedVolume{stage: vol.stage, publishes: maps.Clone(vol.publishes)}, true
+}
+
+// volumeIDs returns the IDs of the watched volumes.
+func (w *mountWatchdog) volumeIDs() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return slices.Sorted(maps.Keys(w.volumes))
+}
+
+// watchMounts checks the mounts of the watched volumes every interval of the
+// watchdog until ctx is canceled.
+func (ns *NodeServer) watchMounts(ctx context.Context) {
+	ticker := time.NewTicker(ns.watchdog.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			var errs []error
+			for _, volumeID := range ns.watchdog.volumeIDs() {
+				if err := ns.checkVolumeMounts(ctx, volumeID); err != nil {
+					errs = append(errs, fmt.Errorf("volume %s: %w", volumeID, err))
+				}
+			}
+			ns.driver.setCondition(ctx, ConditionMountsHealthy, errors.Join(errs...))
+		}
+	}
+}
+
+// checkVolumeMounts mounts volumeID again where its mounts vanished, and
+// returns the error mounting it again. The volume is skipped while a request
+// for it is in progress, and is checked again on the next interval.
+func (ns *NodeServer) checkVolumeMounts(ctx context.Context, volumeID string) error {
+	log := logger.GetLogger(ctx)
+	log.V(4).Info("Entering checkVolumeMounts()", "volumeID", volumeID)
+	defer log.V(4).Info("Exiting checkVolumeMounts()")
+
+	if !ns.volumeLocks.tryAcquire(volumeID) {
+		return nil
+	}
+	defer ns.volumeLocks.release(volumeID)
+
+	// The volume is untracked under its lock when it is unstaged, so it is
+	// still staged if it is still watched
+	vol, ok := ns.watchdog.watched(volumeID)
+	if !ok {
+		return nil
+	}
+
+	stagingLost := ns.mountLost(ctx, vol.stage.GetStagingTargetPath())
+	var lostTargets []string
+	for _, targetPath := range slices.Sorted(maps.Keys(vol.publishes)) {
+		if ns.mountLost(ctx, targetPath) {
+			lostTargets = append(lostTargets, targetPath)
+		}
+	}
+	if !stagingLost && len(lostTargets) == 0 {
+		return nil
+	}
+
+	log.V(0).Info("Volume mounts vanished, mounting the volume again", "volumeID", volumeID, "staging", stagingLost, "targetPaths", lostTargets)
+	ns.recordMountEvent(ctx, vol.stage, "Warning", mountLostReason, fmt.Sprintf("Mounts of volume %s vanished, mounting it again", volumeID))
+	if err := ns.restoreMounts(ctx, vol, stagingLost, lostTargets); err != nil {
+		log.Error(err, "Failed to mount the volume again", "volumeID", volumeID)
+		ns.recordMountEvent(ctx, vol.stage, "Warning", mountRestoreFailedReason, fmt.Sprintf("Failed to mount volume %s again: %v", volumeID, err))
+		return err
+	}
+	log.V(2).Info("Mounted the volume again", "volumeID", volumeID)
+	ns.recordMountEvent(ctx, vol.stage, "Normal", mountRestoredReason, fmt.Sprintf("Mounted volume %s again", volumeID))
+	return nil
+}
+
+// mountLost reports whether path is no longer a mount point. Paths that
+// cannot be checked are assumed to be mounted.
+func (ns *NodeServer) mountLost(ctx context.Context, path string) bool {
+	notMnt, err := ns.mounter.IsLikelyNotMountPoint(path)
+	if err != nil {
+		logger.GetLogger(ctx).Error(err, "Failed to check mount point", "path", path)
+		return false
+	}
+	return notMnt
+}
+
+// restoreMounts stages vol again if stagingLost, then publishes it again to
+// targetPaths, as NodeStageVolume and NodePublishVolume did.
+func (ns *NodeServer) restoreMounts(ctx context.Context, vol watchedVolume, stagingLost bool, targetPaths []string) error {
+	fs := filesystem.NewFileSystem()
+	if stagingLost {
+		err := ns.restageVolume(ctx, vol.stage, fs)
+		recordMountRecovery(mountKindStaging, err)
+		if err != nil {
+			for range targetPaths {
+				recordMountRecovery(mountKindTarget, err)
+			}
+			return err
+		}
+	}
+
+	for _, targetPath := range targetPaths {
+		req := vol.publishes[targetPath]
+		err := ns.mounter.Mount(req.GetStagingTargetPath(), targetPath, "ext4", publishMountOptions(req))
+		recordMountRecovery(mountKindTarget, err)
+		if err != nil {
+			return errInternal("could not mount %s at %s: %v", req.GetStagingTargetPath(), targetPath, err)
+		}
+	}
+	return nil
+}
+
+// restageVolume runs the steps of NodeStageVolume for req again.
+func (ns *NodeServer) restageVolume(ctx context.Context, req *csi.NodeStageVolumeRequest, fs filesystem.FileSystem) error {
+	if _, err := ns.ensureMountPoint(ctx, req.GetStagingTargetPath(), fs); err != nil {
+		return err
+	}
+	st, err := ns.newStageState(ctx, req)
+	if err != nil {
+		return err
+	}
+	return ns.runStageSteps(ctx, st, stageSteps(req), newStageMarker(req.GetStagingTargetPath(), fs))
+}
+
+// recordMountEvent records an event on the PersistentVolumeClaim of the
+// volume staged by req, if it is known. Failing to record it is logged.
+func (ns *NodeServer) recordMountEvent(ctx context.Context, req *csi.NodeStageVolumeRequest, eventType, reason, message string) {
+	namespace, name := req.GetVolumeContext()[PVCNamespaceParameter], req.GetVolumeContext()[PVCNameParameter]
+	if ns.watchdog.client == nil || namespace == "" || name == "" {
+		return
+	}
+	if err := ns.watchdog.client.CreatePersistentVolumeClaimEvent(ctx, namespace, name, eventType, reason, message); err != nil {
+		logger.GetLogger(ctx).Error(err, "Failed to record event", "pvc", namespace+"/"+name, "reason", reason)
+	}
+}
+
+func recordMountRecovery(kind string, err error) {
+	result := "restored"
+	if err != nil {
+		result = "failed"
+	}
+	observability.NodeMountRecoveriesTotal.WithLabelValues(kind, result).Inc()
+}
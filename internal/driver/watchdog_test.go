@@ -0,0 +1,139 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/mock/gomock"
+	"k8s.io/mount-utils"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+func TestMountWatchdog_track(t *testing.T) {
+	w := newMountWatchdog(nil, 0)
+	filesystemCapability := &csi.VolumeCapability{AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}}}
+	blockCapability := &csi.VolumeCapability{AccessType: &csi.VolumeCapability_Block{Block: &csi.VolumeCapability_BlockVolume{}}}
+
+	w.trackPublish(&csi.NodePublishVolumeRequest{VolumeId: "1001-vol", TargetPath: "/pod-1"}) // Not staged since the plugin started
+	w.trackStage(&csi.NodeStageVolumeRequest{VolumeId: "1001-vol", VolumeCapability: filesystemCapability})
+	w.trackStage(&csi.NodeStageVolumeRequest{VolumeId: "1002-vol", VolumeCapability: blockCapability})
+	w.trackPublish(&csi.NodePublishVolumeRequest{VolumeId: "1001-vol", TargetPath: "/pod-2"})
+	w.trackPublish(&csi.NodePublishVolumeRequest{VolumeId: "1001-vol", TargetPath: "/pod-3"})
+	w.untrackPublish("1001-vol", "/pod-3")
+
+	vol, ok := w.watched("1001-vol")
+	if !ok || len(vol.publishes) != 1 || vol.publishes["/pod-2"] == nil {
+		t.Errorf("watched(1001-vol) = %+v, %v, want published to /pod-2", vol, ok)
+	}
+	if ids := w.volumeIDs(); len(ids) != 1 || ids[0] != "1001-vol" {
+		t.Errorf("volumeIDs() = %v, want [1001-vol]", ids)
+	}
+
+	w.untrack("1001-vol")
+	if _, ok := w.watched("1001-vol"); ok {
+		t.Error("watched(1001-vol) after untrack() = true")
+	}
+}
+
+func TestNodeServer_checkVolumeMounts(t *testing.T) {
+	volumeContext := map[string]string{PVCNamespaceParameter: "default", PVCNameParameter: "data"}
+	stage := &csi.NodeStageVolumeRequest{
+		VolumeId:          "1001-vol",
+		StagingTargetPath: "/staging",
+		VolumeCapability:  &csi.VolumeCapability{AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}}},
+		VolumeContext:     volumeContext,
+	}
+	publish := &csi.NodePublishVolumeRequest{VolumeId: "1001-vol", StagingTargetPath: "/staging", TargetPath: "/target", Readonly: true}
+
+	tests := []struct {
+		name        string
+		locked      bool
+		expect      func(m *mocks.MockMounter, k *mocks.MockKubeClient)
+		wantResults map[string]string // Recovery result by kind of mount
+	}{
+		{
+			name: "Mounted",
+			expect: func(m *mocks.MockMounter, k *mocks.MockKubeClient) {
+				m.EXPECT().IsLikelyNotMountPoint("/staging").Return(false, nil)
+				m.EXPECT().IsLikelyNotMountPoint("/target").Return(false, nil)
+			},
+		},
+		{
+			name:   "Request in progress",
+			locked: true,
+			expect: func(m *mocks.MockMounter, k *mocks.MockKubeClient) {},
+		},
+		{
+			name: "Target mount lost",
+			expect: func(m *mocks.MockMounter, k *mocks.MockKubeClient) {
+				m.EXPECT().IsLikelyNotMountPoint("/staging").Return(false, nil)
+				m.EXPECT().IsLikelyNotMountPoint("/target").Return(true, nil)
+				gomock.InOrder(
+					k.EXPECT().CreatePersistentVolumeClaimEvent(gomock.Any(), "default", "data", "Warning", mountLostReason, gomock.Any()).Return(nil),
+					m.EXPECT().Mount("/staging", "/target", "ext4", []string{"bind", "ro"}).Return(nil),
+					k.EXPECT().CreatePersistentVolumeClaimEvent(gomock.Any(), "default", "data", "Normal", mountRestoredReason, gomock.Any()).Return(nil),
+				)
+			},
+			wantResults: map[string]string{mountKindTarget: "restored"},
+		},
+		{
+			name: "Target mount cannot be checked",
+			expect: func(m *mocks.MockMounter, k *mocks.MockKubeClient) {
+				m.EXPECT().IsLikelyNotMountPoint("/staging").Return(false, nil)
+				m.EXPECT().IsLikelyNotMountPoint("/target").Return(false, errors.New("permission denied"))
+			},
+		},
+		{
+			name: "Staging mount cannot be restored",
+			expect: func(m *mocks.MockMounter, k *mocks.MockKubeClient) {
+				gomock.InOrder(
+					m.EXPECT().IsLikelyNotMountPoint("/staging").Return(true, nil),
+					m.EXPECT().IsLikelyNotMountPoint("/target").Return(true, nil),
+					k.EXPECT().CreatePersistentVolumeClaimEvent(gomock.Any(), "default", "data", "Warning", mountLostReason, gomock.Any()).Return(errors.New("forbidden")),
+					m.EXPECT().IsLikelyNotMountPoint("/staging").Return(true, errors.New("input/output error")),
+					k.EXPECT().CreatePersistentVolumeClaimEvent(gomock.Any(), "default", "data", "Warning", mountRestoreFailedReason, gomock.Any()).Return(nil),
+				)
+			},
+			wantResults: map[string]string{mountKindStaging: "failed", mountKindTarget: "failed"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockMounter := mocks.NewMockMounter(ctrl)
+			mockKubeClient := mocks.NewMockKubeClient(ctrl)
+			tt.expect(mockMounter, mockKubeClient)
+
+			ns := &NodeServer{
+				mounter:  &mount.SafeFormatAndMount{Interface: mockMounter},
+				watchdog: newMountWatchdog(mockKubeClient, 0),
+			}
+			ns.watchdog.trackStage(stage)
+			ns.watchdog.trackPublish(publish)
+			if tt.locked {
+				ns.volumeLocks.tryAcquire("1001-vol")
+			}
+
+			before := make(map[string]float64)
+			for kind, result := range tt.wantResults {
+				before[kind] = testutil.ToFloat64(observability.NodeMountRecoveriesTotal.WithLabelValues(kind, result))
+			}
+
+			ns.checkVolumeMounts(context.Background(), "1001-vol")
+
+			for kind, result := range tt.wantResults {
+				if got := testutil.ToFloat64(observability.NodeMountRecoveriesTotal.WithLabelValues(kind, result)) - before[kind]; got != 1 {
+					t.Errorf("%s mount recoveries %s = %v, want 1", kind, result, got)
+				}
+			}
+		})
+	}
+}
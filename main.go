@@ -18,26 +18,57 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/ianschenck/envflag"
 	"github.com/linode/linodego"
 	"go.uber.org/automaxprocs/maxprocs"
 	"k8s.io/klog/v2"
+	"k8s.io/mount-utils"
 
 	"github.com/linode/linode-blockstorage-csi-driver/internal/driver"
 	cryptsetupclient "github.com/linode/linode-blockstorage-csi-driver/pkg/cryptsetup-client"
 	devicemanager "github.com/linode/linode-blockstorage-csi-driver/pkg/device-manager"
 	filesystem "github.com/linode/linode-blockstorage-csi-driver/pkg/filesystem"
+	hosthelper "github.com/linode/linode-blockstorage-csi-driver/pkg/host-helper"
+	kubeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/kube-client"
 	linodeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-client"
 	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
 	mountmanager "github.com/linode/linode-blockstorage-csi-driver/pkg/mount-manager"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
 )
 
 var vendorVersion string // set by the linker
 
+// storageClassWebhookMode is the mode running the StorageClass validating
+// webhook instead of the CSI plugin.
+const storageClassWebhookMode = "storageclass-webhook"
+
+// luksHeaderBackupSecret backs up the LUKS headers of volumes in Secrets.
+const luksHeaderBackupSecret = "secret"
+
 type configuration struct {
-	// The UNIX socket to listen on for RPC requests.
+	// What the binary runs: the CSI plugin when empty, or the StorageClass
+	// validating webhook ("storageclass-webhook")
+	mode string
+
+	// Port of the StorageClass validating webhook, and paths of its TLS
+	// certificate and key
+	webhookPort    string
+	webhookTLSCert string
+	webhookTLSKey  string
+
+	// The UNIX socket to listen on for RPC requests. Several comma-separated
+	// endpoints may be given, e.g. a loopback tcp endpoint to debug the
+	// driver with csc or grpcurl, which are then all served.
 	csiEndpoint string
 
 	// Linode personal access token, used to make requests to the Linode
@@ -47,10 +78,34 @@ type configuration struct {
 	// Linode API URL.
 	linodeURL string
 
+	// Fraction of the requests to the Linode API logged with their bodies
+	// at verbosity 6, between 0 and 1. None are logged when empty
+	linodeAPIDebugSampleRate string
+
+	// Verbosity of the components of the driver, overriding the -v flag, as
+	// a comma-separated list of component=level (e.g. linode-client=6)
+	logComponentVerbosity string
+
+	// Loopback address the verbosity of the logs is served on and changed
+	// at. Not served when empty
+	logVerbosityAddress string
+
+	// URL of the HTTP or HTTPS proxy the requests to the Linode API are
+	// sent through, instead of the proxy of $HTTPS_PROXY
+	linodeAPIProxy string
+
+	// Path of a PEM bundle of CA certificates trusted for the Linode API in
+	// addition to the system roots, read again when it changes
+	linodeAPICABundle string
+
 	// Optional label prefix to use when creating new Linode Block Storage
 	// Volumes.
 	volumeLabelPrefix string
 
+	// Optional volume label prefix the driver had before it was changed,
+	// under which CreateVolume also looks for the volumes it was asked for.
+	previousVolumeLabelPrefix string
+
 	// Name of the current node, when running as the node plugin.
 	//
 	// Deprecated: This is not needed as the CSI driver now uses the Linode
@@ -64,25 +119,284 @@ type configuration struct {
 	// Flag to specify the port on which the metrics http server will run
 	metricsPort string
 
+	// Prefix of the metric names, and comma-separated list of metrics
+	// (named without the prefix) that are not exported
+	metricsNamespace string
+	disabledMetrics  string
+
 	// Flag to enable tracing
 	enableTracing string
 
 	// Flag to specify the port on which the tracing http server will run
 	tracingPort string
+
+	// Exporter of the traces ("otlp-http", "otlp-grpc", "stdout" or
+	// "none"), host:port of the OTLP collector overriding the tracing port,
+	// and ratio of the traces started by the driver that are sampled
+	tracingExporter      string
+	tracingEndpoint      string
+	tracingSamplingRatio string
+
+	// Optional path to the socket of a linode-host-helper. When set, the
+	// node plugin performs mounts, mkfs and cryptsetup operations through
+	// the helper, and does not need CAP_SYS_ADMIN itself.
+	hostHelperSocket string
+
+	// Optional path of the registration socket of node-driver-registrar,
+	// removed when the node plugin is terminated so that the kubelet
+	// deregisters the driver
+	registrationSocket string
+
+	// Flag to make ControllerUnpublishVolume return once the detach was
+	// accepted, instead of waiting for the volume to be detached
+	asyncControllerUnpublish string
+
+	// File system to format volumes with, when neither the volume
+	// capability nor the StorageClass specify one
+	defaultFSType string
+
+	// Comma-separated list of mount options added to those of every
+	// volume staged on the node
+	defaultMountOptions string
+
+	// Mount the volumes staged read-only with norecovery
+	readOnlyNoRecovery string
+
+	// Flag to make the node plugin mount the volumes holding another file
+	// system than requested with the file system they hold
+	allowFSMismatchMount string
+
+	// Flag to make the node plugin refuse to stage the volumes whose device
+	// is smaller than their size in the Linode API
+	strictDeviceSize string
+
+	// Whether the validations introduced by recent releases refuse
+	// requests ("enforce", the default) or only log them ("warn")
+	enforcementMode string
+
+	// Whether CreateVolume clones volumes across namespaces ("allow", the
+	// default), only when a ReferenceGrant allows it ("referencegrant"),
+	// or never ("deny")
+	crossNamespaceClones string
+
+	// Whether the node plugin leaves alone ("off", the default), reports
+	// ("report") or cleans up ("fix") the staging mounts and LUKS mappings
+	// of detached volumes at startup
+	orphanCleanup string
+
+	// Whether the controller leaves alone (off), reports (report), finishes
+	// (finish) or deletes (delete) the volumes created but never seen active
+	// at startup, and how long ago they must have been created. Off when
+	// empty
+	halfCreatedVolumes   string
+	halfCreatedVolumeAge string
+
+	// Whether the replicas of the controller share the volumes, rejecting
+	// (reject) or forwarding (proxy) the requests of the volumes owned by
+	// other replicas. The replica is named shardIdentity, serves the
+	// forwarded requests at shardAddress over mutual TLS with the
+	// shardTLSCert, shardTLSKey and shardTLSCA files, authenticated with
+	// shardToken, and renews its Lease every third of shardLeaseDuration.
+	// Off when empty
+	sharding           string
+	shardIdentity      string
+	shardAddress       string
+	shardToken         string
+	shardTLSCert       string
+	shardTLSKey        string
+	shardTLSCA         string
+	shardLeaseDuration string
+
+	// How often to write the observed usage of volumes to their
+	// PersistentVolumeClaims. Disabled when empty
+	volumeUsageReportInterval string
+
+	// How often the node plugin checks that the volumes it mounted are still
+	// mounted, and mounts them again. Disabled when empty
+	mountWatchdogInterval string
+
+	// How often the node plugin checks that the devices of the volumes it
+	// staged do not change, to debug the renumbering of devices by the host.
+	// Disabled when empty
+	deviceFlapSoakInterval string
+
+	// Comma-separated list of regions, and tag, restricting the volumes
+	// returned by ListVolumes. All volumes are listed when empty
+	listVolumesRegions string
+	listVolumesTag     string
+
+	// Whether the controller creates volumes in the region of the topology
+	// requirements of their request (on), also refusing the requests
+	// without one (required), or always in its own region (off)
+	topology string
+
+	// Comma-separated list of the regions volumes can be created in. Any
+	// region is allowed when empty
+	allowedRegions string
+
+	// Comma-separated lists of the labels and file systems of the instance
+	// disks not counted against the volume attachment limit
+	excludedDiskLabels      string
+	excludedDiskFilesystems string
+
+	// What the controller does with attachments persisted across boots by
+	// earlier releases: "off", "report" or "fix"
+	persistedAttachments string
+
+	// Attach volumes to the configuration profile set in the
+	// linodebs.csi.linode.com/attach-config-id annotation of nodes
+	attachConfigFromNodeAnnotation string
+
+	// Annotate PersistentVolumes with the result of clone verification
+	annotateCloneVerification string
+
+	// Count the features used by provisioned volumes in the metrics
+	featureTelemetry string
+
+	// Refuse volume IDs that are not volume keys, instead of hashing them
+	rejectLegacyVolumeIDs string
+
+	// Number of volumes the Linode account may have, enforced by
+	// CreateVolume. Not enforced when empty
+	accountVolumeLimit string
+
+	// How often to rename the Linode volumes of annotated
+	// PersistentVolumes after them. Disabled when empty
+	volumeLabelSyncInterval string
+
+	// How often the controller polls the volume events of the account, to
+	// wait for volumes without polling each of them. Disabled when empty
+	accountEventsInterval string
+
+	// Address the controller accepts the events of the account pushed to it
+	// on, from clients sending accountEventsToken as a bearer token.
+	// Disabled when empty
+	accountEventsAddress string
+	accountEventsToken   string
+
+	// How often the controller checks that the Linode API accepts its token,
+	// and when the token expires. Disabled when empty
+	tokenCheckInterval string
+
+	// Delay after which the controller retries a volume whose creation or
+	// attachment failed, doubling with each failure. Disabled when empty
+	volumeFailureBackoff string
+
+	// How long DeleteVolume waits for a volume being detached to be
+	// detached before failing. Disabled when empty
+	deleteVolumeDetachWait string
+
+	// How long after a node was deleted the controller detaches the volumes
+	// still attached to it. Disabled when empty
+	deletedNodeDetachGracePeriod string
+
+	// How long after a node was cordoned the controller starts detaching the
+	// read-only volumes no pod of the node uses. Disabled when empty
+	cordonedNodeReadOnlyDetachDelay string
+
+	// Comma-separated list of the maximum durations of the requests, by CSI
+	// method name, overriding the defaults (e.g. CreateVolume=15m)
+	rpcTimeouts string
+
+	// Where the node plugin backs up the LUKS headers of the volumes it
+	// formats: in Secrets ("secret"), in a directory (an absolute path), or
+	// nowhere when empty
+	luksHeaderBackup string
+
+	// Name of the cluster, appended as a short hash to the labels of the
+	// volumes it creates. Not appended when empty
+	clusterName string
+
+	// Mount point of the cgroup v2 hierarchy of the node in the node plugin,
+	// where the pods using throttled volumes are throttled. /sys/fs/cgroup
+	// when empty
+	cgroupRoot string
+
+	// Template of the labels of the volumes the controller creates (e.g.
+	// {{.ClusterID}}-{{.PVCNamespace}}-{{.PVCName}}). The volume label
+	// prefix and PV name are used when empty
+	volumeNameTemplate string
 }
 
 func loadConfig() configuration {
 	var cfg configuration
-	envflag.StringVar(&cfg.csiEndpoint, "CSI_ENDPOINT", "unix:/tmp/csi.sock", "Path to the CSI endpoint socket")
+	envflag.StringVar(&cfg.mode, "MODE", "", "What the binary runs: the CSI plugin when empty, or the StorageClass validating webhook (storageclass-webhook)")
+	envflag.StringVar(&cfg.webhookPort, "WEBHOOK_PORT", "9443", "Port of the StorageClass validating webhook")
+	envflag.StringVar(&cfg.webhookTLSCert, "WEBHOOK_TLS_CERT", "/etc/webhook/tls/tls.crt", "Path of the TLS certificate of the StorageClass validating webhook")
+	envflag.StringVar(&cfg.webhookTLSKey, "WEBHOOK_TLS_KEY", "/etc/webhook/tls/tls.key", "Path of the TLS key of the StorageClass validating webhook")
+	envflag.StringVar(&cfg.csiEndpoint, "CSI_ENDPOINT", "unix:/tmp/csi.sock", "Path to the CSI endpoint socket, or comma-separated endpoints (e.g. unix:///csi/csi.sock,tcp://127.0.0.1:10000) whose tcp endpoints listen on loopback addresses")
 	envflag.StringVar(&cfg.linodeToken, "LINODE_TOKEN", "", "Linode API token")
 	envflag.StringVar(&cfg.linodeURL, "LINODE_URL", linodego.APIHost, "Linode API URL")
+	envflag.StringVar(&cfg.linodeAPIDebugSampleRate, "LINODE_API_DEBUG_SAMPLE_RATE", "", "Fraction of the requests to the Linode API logged with their bodies at verbosity 6, between 0 and 1 (e.g. 0.1)")
+	envflag.StringVar(&cfg.logComponentVerbosity, "LOG_COMPONENT_VERBOSITY", "", "Comma-separated list of the verbosity of the components of the driver (controller, node, linode-client, cryptsetup), overriding the -v flag (e.g. linode-client=6)")
+	envflag.StringVar(&cfg.logVerbosityAddress, "LOG_VERBOSITY_ADDRESS", "", "Loopback address the verbosity of the logs is served on and changed at (e.g. 127.0.0.1:9810)")
+	envflag.StringVar(&cfg.linodeAPIProxy, "LINODE_API_PROXY", "", "URL of the HTTP or HTTPS proxy the requests to the Linode API are sent through, instead of the proxy of $HTTPS_PROXY")
+	envflag.StringVar(&cfg.linodeAPICABundle, "LINODE_API_CA_BUNDLE", "", "Path of a PEM bundle of CA certificates trusted for the Linode API in addition to the system roots")
 	envflag.StringVar(&cfg.volumeLabelPrefix, "LINODE_VOLUME_LABEL_PREFIX", "", "Linode Block Storage volume label prefix")
+	envflag.StringVar(&cfg.previousVolumeLabelPrefix, "PREVIOUS_VOLUME_LABEL_PREFIX", "", "Previous Linode Block Storage volume label prefix, under which CreateVolume also looks for existing volumes")
 	envflag.StringVar(&cfg.nodeName, "NODE_NAME", "", "Name of the current node") // deprecated
 	envflag.StringVar(&cfg.enableMetrics, "ENABLE_METRICS", "", "This flag conditionally runs the metrics servers")
 	envflag.StringVar(&cfg.metricsPort, "METRICS_PORT", "8081", "This flag specifies the port on which the metrics https server will run")
+	envflag.StringVar(&cfg.metricsNamespace, "METRICS_NAMESPACE", observability.DefaultMetricsNamespace, "Prefix of the names of the metrics")
+	envflag.StringVar(&cfg.disabledMetrics, "DISABLED_METRICS", "", "Comma-separated list of metrics, named without their prefix, that are not exported")
 	envflag.StringVar(&cfg.enableTracing, "OTEL_TRACING", "", "This flag conditionally enables tracing")
 	envflag.StringVar(&cfg.tracingPort, "OTEL_TRACING_PORT", "4318", "This flag specifies the port on which the tracing https server will run")
+	envflag.StringVar(&cfg.tracingExporter, "OTEL_TRACING_EXPORTER", "", "Exporter of the traces (otlp-http, otlp-grpc, stdout or none)")
+	envflag.StringVar(&cfg.tracingEndpoint, "OTEL_TRACING_ENDPOINT", "", "host:port of the OTLP collector the traces are exported to, overriding the tracing port")
+	envflag.StringVar(&cfg.tracingSamplingRatio, "OTEL_TRACING_SAMPLING_RATIO", "", "Ratio of the traces started by the driver that are sampled, between 0 and 1")
+	envflag.StringVar(&cfg.hostHelperSocket, "HOST_HELPER_SOCKET", "", "Path to the socket of the privileged host helper")
+	envflag.StringVar(&cfg.registrationSocket, "REGISTRATION_SOCKET", "", "Path of the registration socket of node-driver-registrar, removed when the node plugin is terminated so that the kubelet deregisters the driver")
+	envflag.StringVar(&cfg.asyncControllerUnpublish, "ASYNC_CONTROLLER_UNPUBLISH", "", "This flag makes ControllerUnpublishVolume confirm detaches in the background")
+	envflag.StringVar(&cfg.defaultFSType, "DEFAULT_FS_TYPE", "", "Default file system type for volumes (ext3, ext4 or xfs)")
+	envflag.StringVar(&cfg.defaultMountOptions, "DEFAULT_MOUNT_OPTIONS", "", "Comma-separated list of mount options added to those of every volume (e.g. noatime,discard)")
+	envflag.StringVar(&cfg.readOnlyNoRecovery, "READ_ONLY_NORECOVERY", "", "This flag makes the node plugin mount volumes staged read-only with norecovery, without replaying their journal")
+	envflag.StringVar(&cfg.allowFSMismatchMount, "ALLOW_FS_MISMATCH_MOUNT", "", "This flag makes the node plugin mount volumes holding another file system than requested with the file system they hold")
+	envflag.StringVar(&cfg.strictDeviceSize, "STRICT_DEVICE_SIZE", "", "This flag makes the node plugin refuse to stage volumes whose device is smaller than their size in the Linode API")
+	envflag.StringVar(&cfg.volumeUsageReportInterval, "VOLUME_USAGE_REPORT_INTERVAL", "", "How often to annotate PVCs with the usage of their volume (e.g. 5m)")
+	envflag.StringVar(&cfg.mountWatchdogInterval, "MOUNT_WATCHDOG_INTERVAL", "", "How often the node plugin checks that the volumes it mounted are still mounted, and mounts them again (e.g. 30s)")
+	envflag.StringVar(&cfg.listVolumesRegions, "LIST_VOLUMES_REGIONS", "", "Comma-separated list of regions to restrict ListVolumes to")
+	envflag.StringVar(&cfg.listVolumesTag, "LIST_VOLUMES_TAG", "", "Tag to restrict ListVolumes to")
+	envflag.StringVar(&cfg.topology, "TOPOLOGY", "", "Whether the controller creates volumes in the region of their topology requirements (on), also refusing the requests without one (required), or always in its own region (off)")
+	envflag.StringVar(&cfg.allowedRegions, "ALLOWED_REGIONS", "", "Comma-separated list of the regions volumes can be created in")
+	envflag.StringVar(&cfg.excludedDiskLabels, "EXCLUDED_DISK_LABELS", "", "Comma-separated list of the labels of the instance disks not counted against the volume attachment limit")
+	envflag.StringVar(&cfg.excludedDiskFilesystems, "EXCLUDED_DISK_FILESYSTEMS", "", "Comma-separated list of the file systems of the instance disks not counted against the volume attachment limit (e.g. swap)")
+	envflag.StringVar(&cfg.annotateCloneVerification, "ANNOTATE_CLONE_VERIFICATION", "", "This flag makes the node plugin annotate PersistentVolumes with the result of clone verification")
+	envflag.StringVar(&cfg.featureTelemetry, "FEATURE_TELEMETRY", "", "This flag makes the controller count the features used by provisioned volumes in its metrics")
+	envflag.StringVar(&cfg.rejectLegacyVolumeIDs, "REJECT_LEGACY_VOLUME_IDS", "", "This flag makes controller requests fail when their volume ID is not a volume key")
+	envflag.StringVar(&cfg.persistedAttachments, "PERSISTED_ATTACHMENTS", "", "What ControllerPublishVolume does with volume attachments persisted across boots: off, report or fix (remove them from the configuration profiles)")
+	envflag.StringVar(&cfg.attachConfigFromNodeAnnotation, "ATTACH_CONFIG_FROM_NODE_ANNOTATION", "", "This flag makes ControllerPublishVolume attach volumes to the configuration profile set in a node annotation")
+	envflag.StringVar(&cfg.clusterName, "CLUSTER_NAME", "", "Name of the cluster; a short hash of it is appended to volume labels and tags to tell apart the volumes of clusters sharing a Linode account")
+	envflag.StringVar(&cfg.volumeNameTemplate, "VOLUME_NAME_TEMPLATE", "", "Go template of the labels of the volumes the controller creates, ended with a short hash of the PV name (e.g. {{.ClusterID}}-{{.PVCNamespace}}-{{.PVCName}})")
+	envflag.StringVar(&cfg.accountVolumeLimit, "ACCOUNT_VOLUME_LIMIT", "", "Number of volumes the Linode account may have; CreateVolume fails with ResourceExhausted above it (e.g. 100)")
+	envflag.StringVar(&cfg.volumeLabelSyncInterval, "VOLUME_LABEL_SYNC_INTERVAL", "", "How often to rename the Linode volumes of annotated PVs after them (e.g. 10m)")
+	envflag.StringVar(&cfg.accountEventsInterval, "ACCOUNT_EVENTS_INTERVAL", "", "How often the controller polls the volume events of the account, to wait for volumes without polling each of them (e.g. 5s)")
+	envflag.StringVar(&cfg.accountEventsAddress, "ACCOUNT_EVENTS_ADDRESS", "", "Address the controller accepts the events of the account pushed to it on (e.g. :9444)")
+	envflag.StringVar(&cfg.accountEventsToken, "ACCOUNT_EVENTS_TOKEN", "", "Bearer token of the clients pushing the events of the account")
+	envflag.StringVar(&cfg.tokenCheckInterval, "TOKEN_CHECK_INTERVAL", "", "How often the controller checks that the Linode API accepts its token, and when the token expires (e.g. 1h)")
+	envflag.StringVar(&cfg.deleteVolumeDetachWait, "DELETE_VOLUME_DETACH_WAIT", "", "How long DeleteVolume waits for a volume still attached to be detached when a detach is in progress, instead of failing right away (e.g. 30s)")
+	envflag.StringVar(&cfg.cordonedNodeReadOnlyDetachDelay, "CORDONED_NODE_READ_ONLY_DETACH_DELAY", "", "How long after a Kubernetes node was cordoned the controller starts detaching the read-only volumes no pod of the node uses (e.g. 30s)")
+	envflag.StringVar(&cfg.deletedNodeDetachGracePeriod, "DELETED_NODE_DETACH_GRACE_PERIOD", "", "How long after a Kubernetes node was deleted the controller detaches the volumes its VolumeAttachments still attach to it (e.g. 2m)")
+	envflag.StringVar(&cfg.volumeFailureBackoff, "VOLUME_FAILURE_BACKOFF", "", "Delay before retrying a volume whose creation or attachment failed, doubling with each failure (e.g. 10s)")
+	envflag.StringVar(&cfg.enforcementMode, "ENFORCEMENT_MODE", "", "Whether recently introduced validations refuse requests (enforce) or only log and count them (warn)")
+	envflag.StringVar(&cfg.crossNamespaceClones, "CROSS_NAMESPACE_CLONES", "", "Whether CreateVolume clones volumes across namespaces (allow), only when a ReferenceGrant allows it (referencegrant), or never (deny)")
+	envflag.StringVar(&cfg.rpcTimeouts, "RPC_TIMEOUTS", "", "Comma-separated list of the maximum durations of the requests by CSI method name, overriding the defaults, 0 for none (e.g. CreateVolume=15m,NodeStageVolume=5m)")
+	envflag.StringVar(&cfg.cgroupRoot, "CGROUP_ROOT", "", "Mount point of the cgroup v2 hierarchy of the node, where the node plugin throttles the pods using volumes with throttling parameters (default /sys/fs/cgroup)")
+	envflag.StringVar(&cfg.luksHeaderBackup, "LUKS_HEADER_BACKUP", "", "Where the node plugin backs up the LUKS headers of the volumes it formats: in Secrets of its namespace (secret) or in a directory (an absolute path)")
+	envflag.StringVar(&cfg.halfCreatedVolumes, "HALF_CREATED_VOLUMES", "", "Whether the controller leaves alone (off), reports (report), finishes (finish) or deletes (delete) the volumes created but never seen active at startup")
+	envflag.StringVar(&cfg.sharding, "SHARDING", "", "Whether the controller replicas share the volumes, forwarding (proxy) the requests of the volumes of other replicas to them")
+	envflag.StringVar(&cfg.shardIdentity, "SHARD_IDENTITY", "", "Name of the controller replica sharing the volumes, e.g. its pod")
+	envflag.StringVar(&cfg.shardAddress, "SHARD_ADDRESS", "", "host:port the controller replica serves the requests forwarded by the other replicas at")
+	envflag.StringVar(&cfg.shardToken, "SHARD_TOKEN", "", "Token authenticating the requests forwarded between the controller replicas")
+	envflag.StringVar(&cfg.shardTLSCert, "SHARD_TLS_CERT", "", "Path to the PEM certificate of the controller replica, valid for "+driver.ShardTLSServerName+", for the requests forwarded between the replicas")
+	envflag.StringVar(&cfg.shardTLSKey, "SHARD_TLS_KEY", "", "Path to the PEM key of the SHARD_TLS_CERT certificate")
+	envflag.StringVar(&cfg.shardTLSCA, "SHARD_TLS_CA", "", "Path to the PEM certificate of the CA the certificates of all the controller replicas are signed by")
+	envflag.StringVar(&cfg.shardLeaseDuration, "SHARD_LEASE_DURATION", "", "Duration of the Leases of the controller replicas sharing the volumes (default 15s)")
+	envflag.StringVar(&cfg.halfCreatedVolumeAge, "HALF_CREATED_VOLUME_AGE", "", "How long ago the volumes created but never seen active must have been created to be handled at startup (default 1h)")
+	envflag.StringVar(&cfg.orphanCleanup, "ORPHAN_CLEANUP", "", "Whether the node plugin leaves alone (off), reports (report) or cleans up (fix) the staging mounts and LUKS mappings of detached volumes at startup")
 	envflag.Parse()
+
+	// Debugging knob, left out of the usage on purpose
+	cfg.deviceFlapSoakInterval = os.Getenv("DEVICE_FLAP_SOAK_INTERVAL")
 	return cfg
 }
 
@@ -127,25 +441,276 @@ func handle(ctx context.Context) error {
 	log.V(4).Info("Driver vendor version", "version", vendorVersion)
 
 	cfg := loadConfig()
+	if cfg.mode != "" && cfg.mode != storageClassWebhookMode {
+		return fmt.Errorf("invalid mode %q, must be empty or %q", cfg.mode, storageClassWebhookMode)
+	}
 	if cfg.linodeToken == "" {
 		return errors.New("linode token required")
 	}
 
+	if err := logger.SetComponentVerbosities(cfg.logComponentVerbosity); err != nil {
+		return err
+	}
+
 	linodeDriver := driver.GetLinodeDriver(ctx)
 
 	// Initialize Linode Driver (Move setup to main?)
 	uaPrefix := fmt.Sprintf("LinodeCSI/%s", vendorVersion)
-	cloudProvider, err := linodeclient.NewLinodeClient(cfg.linodeToken, uaPrefix, cfg.linodeURL)
+	transport := linodeclient.Transport{ProxyURL: cfg.linodeAPIProxy, CABundlePath: cfg.linodeAPICABundle}
+	var debugDump linodeclient.DebugDump
+	if cfg.linodeAPIDebugSampleRate != "" {
+		var err error
+		if debugDump.SampleRate, err = strconv.ParseFloat(cfg.linodeAPIDebugSampleRate, 64); err != nil || debugDump.SampleRate < 0 || debugDump.SampleRate > 1 {
+			return fmt.Errorf("invalid Linode API debug sample rate %q, must be between 0 and 1", cfg.linodeAPIDebugSampleRate)
+		}
+	}
+	cloudProvider, err := linodeclient.NewLinodeClient(cfg.linodeToken, uaPrefix, cfg.linodeURL, transport, debugDump)
 	if err != nil {
 		return fmt.Errorf("failed to set up linode client: %w", err)
 	}
 
 	mounter := mountmanager.NewSafeMounter()
+	var cryptSetup cryptsetupclient.CryptSetupClient = cryptsetupclient.NewCryptSetup()
+	if cfg.hostHelperSocket != "" {
+		log.V(2).Info("Using host helper for privileged operations", "socket", cfg.hostHelperSocket)
+		helper := hosthelper.NewClient(cfg.hostHelperSocket)
+		defer func() {
+			if err := helper.Close(); err != nil {
+				log.Error(err, "Failed to close host helper client")
+			}
+		}()
+		mounter = &mount.SafeFormatAndMount{
+			Interface: helper.Mounter(),
+			Exec:      helper.Executor(),
+		}
+		cryptSetup = helper.CryptSetup()
+	}
 	fileSystem := filesystem.NewFileSystem()
 	deviceUtils := devicemanager.NewDeviceUtils(fileSystem, mounter.Exec)
-	cryptSetup := cryptsetupclient.NewCryptSetup()
 	encrypt := driver.NewLuksEncryption(mounter.Exec, fileSystem, cryptSetup)
 
+	metricsCfg := observability.MetricsConfig{Namespace: cfg.metricsNamespace}
+	for _, name := range strings.Split(cfg.disabledMetrics, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			metricsCfg.Disabled = append(metricsCfg.Disabled, name)
+		}
+	}
+	if err := observability.ConfigureMetrics(metricsCfg); err != nil {
+		return fmt.Errorf("failed to configure metrics: %w", err)
+	}
+
+	opts := driver.Options{
+		AllowFSMismatchMount:           cfg.allowFSMismatchMount == driver.True,
+		StrictDeviceSize:               cfg.strictDeviceSize == driver.True,
+		RegistrationSocket:             cfg.registrationSocket,
+		LogVerbosityAddress:            cfg.logVerbosityAddress,
+		AnnotateCloneVerification:      cfg.annotateCloneVerification == driver.True,
+		AsyncControllerUnpublish:       cfg.asyncControllerUnpublish == driver.True,
+		AttachConfigFromNodeAnnotation: cfg.attachConfigFromNodeAnnotation == driver.True,
+		CgroupRoot:                     cfg.cgroupRoot,
+		ClusterName:                    cfg.clusterName,
+		PreviousVolumeLabelPrefix:      cfg.previousVolumeLabelPrefix,
+		DefaultFSType:                  cfg.defaultFSType,
+		FeatureTelemetry:               cfg.featureTelemetry == driver.True,
+		ListVolumesTag:                 cfg.listVolumesTag,
+		ReadOnlyNoRecovery:             cfg.readOnlyNoRecovery == driver.True,
+		RejectLegacyVolumeIDs:          cfg.rejectLegacyVolumeIDs == driver.True,
+		NewLinodeClient: func(token string) (linodeclient.LinodeClient, error) {
+			return linodeclient.NewLinodeClient(token, uaPrefix, cfg.linodeURL, transport, debugDump)
+		},
+	}
+	if opts.EnforcementMode, err = driver.ParseEnforcementMode(cfg.enforcementMode); err != nil {
+		return err
+	}
+	if opts.CrossNamespaceClones, err = driver.ParseCrossNamespaceClonePolicy(cfg.crossNamespaceClones); err != nil {
+		return err
+	}
+	if opts.OrphanCleanup, err = driver.ParseOrphanCleanupMode(cfg.orphanCleanup); err != nil {
+		return err
+	}
+	opts.Tracing.Endpoint = cfg.tracingEndpoint
+	if opts.Tracing.Exporter, err = observability.ParseTracingExporter(cfg.tracingExporter); err != nil {
+		return err
+	}
+	if cfg.tracingSamplingRatio != "" {
+		ratio, err := strconv.ParseFloat(cfg.tracingSamplingRatio, 64)
+		if err != nil || ratio < 0 || ratio > 1 {
+			return fmt.Errorf("invalid tracing sampling ratio %q, must be between 0 and 1", cfg.tracingSamplingRatio)
+		}
+		opts.Tracing.SamplingRatio = &ratio
+	}
+	if opts.HalfCreatedVolumes, err = driver.ParseHalfCreatedVolumeMode(cfg.halfCreatedVolumes); err != nil {
+		return err
+	}
+	if cfg.halfCreatedVolumeAge != "" {
+		if opts.HalfCreatedVolumeAge, err = time.ParseDuration(cfg.halfCreatedVolumeAge); err != nil {
+			return fmt.Errorf("invalid half-created volume age: %w", err)
+		}
+	}
+	if opts.PersistedAttachments, err = driver.ParsePersistedAttachmentMode(cfg.persistedAttachments); err != nil {
+		return err
+	}
+	if opts.RPCTimeouts, err = driver.ParseRPCTimeouts(cfg.rpcTimeouts); err != nil {
+		return err
+	}
+	if cfg.volumeNameTemplate != "" {
+		if opts.VolumeNameTemplate, err = driver.ParseVolumeNameTemplate(cfg.volumeNameTemplate); err != nil {
+			return err
+		}
+	}
+	for _, region := range strings.Split(cfg.listVolumesRegions, ",") {
+		if region = strings.TrimSpace(region); region != "" {
+			opts.ListVolumesRegions = append(opts.ListVolumesRegions, region)
+		}
+	}
+	if opts.Topology, err = driver.ParseTopologyMode(cfg.topology); err != nil {
+		return err
+	}
+	for _, region := range strings.Split(cfg.allowedRegions, ",") {
+		if region = strings.TrimSpace(region); region != "" {
+			opts.AllowedRegions = append(opts.AllowedRegions, region)
+		}
+	}
+	for _, label := range strings.Split(cfg.excludedDiskLabels, ",") {
+		if label = strings.TrimSpace(label); label != "" {
+			opts.ExcludedDiskLabels = append(opts.ExcludedDiskLabels, label)
+		}
+	}
+	for _, filesystem := range strings.Split(cfg.excludedDiskFilesystems, ",") {
+		if filesystem = strings.TrimSpace(filesystem); filesystem != "" {
+			opts.ExcludedDiskFilesystems = append(opts.ExcludedDiskFilesystems, filesystem)
+		}
+	}
+	for _, option := range strings.Split(cfg.defaultMountOptions, ",") {
+		if option = strings.TrimSpace(option); option != "" {
+			opts.DefaultMountOptions = append(opts.DefaultMountOptions, option)
+		}
+	}
+	if cfg.accountVolumeLimit != "" {
+		if opts.AccountVolumeLimit, err = strconv.Atoi(cfg.accountVolumeLimit); err != nil {
+			return fmt.Errorf("invalid account volume limit: %w", err)
+		}
+	}
+	if cfg.volumeUsageReportInterval != "" {
+		if opts.VolumeUsageReportInterval, err = time.ParseDuration(cfg.volumeUsageReportInterval); err != nil {
+			return fmt.Errorf("invalid volume usage report interval: %w", err)
+		}
+	}
+	if cfg.mountWatchdogInterval != "" {
+		if opts.MountWatchdogInterval, err = time.ParseDuration(cfg.mountWatchdogInterval); err != nil {
+			return fmt.Errorf("invalid mount watchdog interval: %w", err)
+		}
+	}
+	if cfg.deviceFlapSoakInterval != "" {
+		if opts.DeviceFlapCheckInterval, err = time.ParseDuration(cfg.deviceFlapSoakInterval); err != nil {
+			return fmt.Errorf("invalid device flap soak interval: %w", err)
+		}
+	}
+	if cfg.volumeLabelSyncInterval != "" {
+		if opts.VolumeLabelSyncInterval, err = time.ParseDuration(cfg.volumeLabelSyncInterval); err != nil {
+			return fmt.Errorf("invalid volume label sync interval: %w", err)
+		}
+	}
+	if cfg.accountEventsInterval != "" {
+		if opts.AccountEventsInterval, err = time.ParseDuration(cfg.accountEventsInterval); err != nil {
+			return fmt.Errorf("invalid account events interval: %w", err)
+		}
+	}
+	if cfg.accountEventsAddress != "" {
+		if cfg.accountEventsToken == "" {
+			return errors.New("ACCOUNT_EVENTS_TOKEN is required with ACCOUNT_EVENTS_ADDRESS")
+		}
+		opts.AccountEventsAddress, opts.AccountEventsToken = cfg.accountEventsAddress, cfg.accountEventsToken
+	}
+	if cfg.tokenCheckInterval != "" {
+		if opts.TokenCheckInterval, err = time.ParseDuration(cfg.tokenCheckInterval); err != nil {
+			return fmt.Errorf("invalid token check interval: %w", err)
+		}
+		opts.LinodeToken = cfg.linodeToken
+	}
+	if cfg.volumeFailureBackoff != "" {
+		if opts.VolumeFailureBackoff, err = time.ParseDuration(cfg.volumeFailureBackoff); err != nil {
+			return fmt.Errorf("invalid volume failure backoff: %w", err)
+		}
+	}
+	if cfg.deleteVolumeDetachWait != "" {
+		if opts.DeleteVolumeDetachWait, err = time.ParseDuration(cfg.deleteVolumeDetachWait); err != nil {
+			return fmt.Errorf("invalid delete volume detach wait: %w", err)
+		}
+	}
+	if cfg.deletedNodeDetachGracePeriod != "" {
+		if opts.DeletedNodeDetachGracePeriod, err = time.ParseDuration(cfg.deletedNodeDetachGracePeriod); err != nil {
+			return fmt.Errorf("invalid deleted node detach grace period: %w", err)
+		}
+	}
+	if cfg.cordonedNodeReadOnlyDetachDelay != "" {
+		if opts.CordonedNodeReadOnlyDetachDelay, err = time.ParseDuration(cfg.cordonedNodeReadOnlyDetachDelay); err != nil {
+			return fmt.Errorf("invalid cordoned node read-only detach delay: %w", err)
+		}
+	}
+
+	if opts.Sharding, err = driver.ParseShardingMode(cfg.sharding); err != nil {
+		return err
+	}
+	if opts.Sharding != driver.ShardingOff {
+		if cfg.shardIdentity == "" {
+			return errors.New("SHARD_IDENTITY is required with SHARDING")
+		}
+		if cfg.shardAddress == "" || cfg.shardToken == "" {
+			return errors.New("SHARD_ADDRESS and SHARD_TOKEN are required with SHARDING")
+		}
+		if cfg.shardTLSCert == "" || cfg.shardTLSKey == "" || cfg.shardTLSCA == "" {
+			return errors.New("SHARD_TLS_CERT, SHARD_TLS_KEY and SHARD_TLS_CA are required with SHARDING")
+		}
+		if opts.ShardTLS, err = driver.LoadShardTLS(cfg.shardTLSCert, cfg.shardTLSKey, cfg.shardTLSCA); err != nil {
+			return err
+		}
+		if cfg.shardLeaseDuration != "" {
+			if opts.ShardLeaseDuration, err = time.ParseDuration(cfg.shardLeaseDuration); err != nil {
+				return fmt.Errorf("invalid shard lease duration: %w", err)
+			}
+		}
+		opts.ShardIdentity, opts.ShardAddress, opts.ShardToken = cfg.shardIdentity, cfg.shardAddress, cfg.shardToken
+	}
+
+	if cfg.mode == storageClassWebhookMode {
+		return serveStorageClassWebhook(ctx, cfg, cloudProvider, opts)
+	}
+
+	if opts.VolumeUsageReportInterval > 0 || opts.MountWatchdogInterval > 0 || opts.VolumeLabelSyncInterval > 0 || opts.AttachConfigFromNodeAnnotation || opts.AnnotateCloneVerification || opts.AccountVolumeLimit > 0 || opts.CrossNamespaceClones != driver.CrossNamespaceClonesAllow || cfg.luksHeaderBackup == luksHeaderBackupSecret || opts.DeletedNodeDetachGracePeriod > 0 || opts.CordonedNodeReadOnlyDetachDelay > 0 || opts.HalfCreatedVolumes == driver.HalfCreatedVolumesDelete || opts.Sharding != driver.ShardingOff {
+		kubeClient, err := kubeclient.NewInClusterClient()
+		if err != nil {
+			return fmt.Errorf("failed to set up kubernetes client: %w", err)
+		}
+		opts.KubeClient = kubeClient
+
+		if opts.AttachConfigFromNodeAnnotation || opts.DeletedNodeDetachGracePeriod > 0 || opts.CordonedNodeReadOnlyDetachDelay > 0 {
+			nodeCache := kubeclient.NewNodeCache(kubeClient)
+			go nodeCache.Run(ctx, func(err error) {
+				log.Error(err, "Failed to watch nodes")
+			})
+			opts.NodeLookup, opts.NodeDeletions, opts.NodeCordons = nodeCache, nodeCache, nodeCache
+		}
+	}
+	if opts.Sharding != driver.ShardingOff {
+		if opts.ShardNamespace, err = kubeclient.InClusterNamespace(); err != nil {
+			return fmt.Errorf("failed to find the namespace of the shard leases: %w", err)
+		}
+	}
+	switch {
+	case cfg.luksHeaderBackup == "":
+	case cfg.luksHeaderBackup == luksHeaderBackupSecret:
+		namespace, err := kubeclient.InClusterNamespace()
+		if err != nil {
+			return fmt.Errorf("failed to find the namespace of the LUKS header backups: %w", err)
+		}
+		opts.LUKSHeaderBackup = driver.NewSecretLUKSHeaderSink(opts.KubeClient, namespace)
+	case filepath.IsAbs(cfg.luksHeaderBackup):
+		opts.LUKSHeaderBackup = driver.NewDirectoryLUKSHeaderSink(cfg.luksHeaderBackup)
+	default:
+		return fmt.Errorf("invalid LUKS header backup %q, must be %q or an absolute path", cfg.luksHeaderBackup, luksHeaderBackupSecret)
+	}
+
 	nodeMetadata, err := driver.GetNodeMetadata(ctx, cloudProvider, fileSystem)
 	if err != nil {
 		return fmt.Errorf("failed to get node metadata: %w", err)
@@ -165,10 +730,48 @@ func handle(ctx context.Context) error {
 		cfg.metricsPort,
 		cfg.enableTracing,
 		cfg.tracingPort,
+		opts,
 	); err != nil {
 		return fmt.Errorf("setup driver: %w", err)
 	}
 
+	// Stop serving once the requests in progress complete when the driver
+	// is interrupted or terminated
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 	linodeDriver.Run(ctx, cfg.csiEndpoint)
 	return nil
 }
+
+// serveStorageClassWebhook serves the StorageClass validating webhook over
+// TLS, until the process is interrupted or terminated.
+func serveStorageClassWebhook(ctx context.Context, cfg configuration, client linodeclient.LinodeClient, opts driver.Options) error {
+	log := logger.GetLogger(ctx)
+
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	mux := http.NewServeMux()
+	mux.Handle(driver.StorageClassWebhookPath, driver.NewStorageClassValidator(ctx, client, opts))
+	server := &http.Server{
+		Addr:              ":" + cfg.webhookPort,
+		Handler:           mux,
+		ReadTimeout:       10 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       60 * time.Second,
+		ReadHeaderTimeout: 5 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		if err := server.Shutdown(context.Background()); err != nil {
+			log.Error(err, "Failed to stop StorageClass webhook server")
+		}
+	}()
+
+	log.V(2).Info("Serving StorageClass validating webhook", "port", cfg.webhookPort, "path", driver.StorageClassWebhookPath)
+	if err := server.ListenAndServeTLS(cfg.webhookTLSCert, cfg.webhookTLSKey); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("serve StorageClass webhook: %w", err)
+	}
+	return nil
+}
@@ -139,6 +139,20 @@ func (mr *MockDeviceMockRecorder) KeyslotAddByVolumeKey(arg0, arg1, arg2 any) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "KeyslotAddByVolumeKey", reflect.TypeOf((*MockDevice)(nil).KeyslotAddByVolumeKey), arg0, arg1, arg2)
 }
 
+// KeyslotChangeByPassphrase mocks base method.
+func (m *MockDevice) KeyslotChangeByPassphrase(currentKeyslot, newKeyslot int, currentPassphrase, newPassphrase string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "KeyslotChangeByPassphrase", currentKeyslot, newKeyslot, currentPassphrase, newPassphrase)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// KeyslotChangeByPassphrase indicates an expected call of KeyslotChangeByPassphrase.
+func (mr *MockDeviceMockRecorder) KeyslotChangeByPassphrase(currentKeyslot, newKeyslot, currentPassphrase, newPassphrase any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "KeyslotChangeByPassphrase", reflect.TypeOf((*MockDevice)(nil).KeyslotChangeByPassphrase), currentKeyslot, newKeyslot, currentPassphrase, newPassphrase)
+}
+
 // Load mocks base method.
 func (m *MockDevice) Load(arg0 cryptsetup.DeviceType) error {
 	m.ctrl.T.Helper()
@@ -53,6 +53,20 @@ func (mr *MockDeviceUtilsMockRecorder) GetDiskByIdPaths(deviceName, partition an
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDiskByIdPaths", reflect.TypeOf((*MockDeviceUtils)(nil).GetDiskByIdPaths), deviceName, partition)
 }
 
+// PartitionDisk mocks base method.
+func (m *MockDeviceUtils) PartitionDisk(devicePath string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PartitionDisk", devicePath)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PartitionDisk indicates an expected call of PartitionDisk.
+func (mr *MockDeviceUtilsMockRecorder) PartitionDisk(devicePath any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PartitionDisk", reflect.TypeOf((*MockDeviceUtils)(nil).PartitionDisk), devicePath)
+}
+
 // VerifyDevicePath mocks base method.
 func (m *MockDeviceUtils) VerifyDevicePath(devicePaths []string) (string, error) {
 	m.ctrl.T.Helper()
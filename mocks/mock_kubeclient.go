@@ -0,0 +1,248 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: pkg/kube-client/kube_client.go
+//
+// Generated by this command:
+//
+//	mockgen -source=pkg/kube-client/kube_client.go -destination=mocks/mock_kubeclient.go -package=mocks
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	kubeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/kube-client"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockKubeClient is a mock of KubeClient interface.
+type MockKubeClient struct {
+	ctrl     *gomock.Controller
+	recorder *MockKubeClientMockRecorder
+	isgomock struct{}
+}
+
+// MockKubeClientMockRecorder is the mock recorder for MockKubeClient.
+type MockKubeClientMockRecorder struct {
+	mock *MockKubeClient
+}
+
+// NewMockKubeClient creates a new mock instance.
+func NewMockKubeClient(ctrl *gomock.Controller) *MockKubeClient {
+	mock := &MockKubeClient{ctrl: ctrl}
+	mock.recorder = &MockKubeClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockKubeClient) EXPECT() *MockKubeClientMockRecorder {
+	return m.recorder
+}
+
+// ApplyLease mocks base method.
+func (m *MockKubeClient) ApplyLease(ctx context.Context, namespace string, lease kubeclient.Lease) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ApplyLease", ctx, namespace, lease)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ApplyLease indicates an expected call of ApplyLease.
+func (mr *MockKubeClientMockRecorder) ApplyLease(ctx, namespace, lease any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApplyLease", reflect.TypeOf((*MockKubeClient)(nil).ApplyLease), ctx, namespace, lease)
+}
+
+// ApplySecret mocks base method.
+func (m *MockKubeClient) ApplySecret(ctx context.Context, namespace, name string, labels, annotations map[string]string, data map[string][]byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ApplySecret", ctx, namespace, name, labels, annotations, data)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ApplySecret indicates an expected call of ApplySecret.
+func (mr *MockKubeClientMockRecorder) ApplySecret(ctx, namespace, name, labels, annotations, data any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApplySecret", reflect.TypeOf((*MockKubeClient)(nil).ApplySecret), ctx, namespace, name, labels, annotations, data)
+}
+
+// CreatePersistentVolumeClaimEvent mocks base method.
+func (m *MockKubeClient) CreatePersistentVolumeClaimEvent(ctx context.Context, namespace, name, eventType, reason, message string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePersistentVolumeClaimEvent", ctx, namespace, name, eventType, reason, message)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreatePersistentVolumeClaimEvent indicates an expected call of CreatePersistentVolumeClaimEvent.
+func (mr *MockKubeClientMockRecorder) CreatePersistentVolumeClaimEvent(ctx, namespace, name, eventType, reason, message any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePersistentVolumeClaimEvent", reflect.TypeOf((*MockKubeClient)(nil).CreatePersistentVolumeClaimEvent), ctx, namespace, name, eventType, reason, message)
+}
+
+// GetNodeAnnotations mocks base method.
+func (m *MockKubeClient) GetNodeAnnotations(ctx context.Context, name string) (map[string]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetNodeAnnotations", ctx, name)
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetNodeAnnotations indicates an expected call of GetNodeAnnotations.
+func (mr *MockKubeClientMockRecorder) GetNodeAnnotations(ctx, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNodeAnnotations", reflect.TypeOf((*MockKubeClient)(nil).GetNodeAnnotations), ctx, name)
+}
+
+// GetPersistentVolumeAnnotations mocks base method.
+func (m *MockKubeClient) GetPersistentVolumeAnnotations(ctx context.Context, name string) (map[string]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPersistentVolumeAnnotations", ctx, name)
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPersistentVolumeAnnotations indicates an expected call of GetPersistentVolumeAnnotations.
+func (mr *MockKubeClientMockRecorder) GetPersistentVolumeAnnotations(ctx, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPersistentVolumeAnnotations", reflect.TypeOf((*MockKubeClient)(nil).GetPersistentVolumeAnnotations), ctx, name)
+}
+
+// GetPersistentVolumeClaimRef mocks base method.
+func (m *MockKubeClient) GetPersistentVolumeClaimRef(ctx context.Context, name string) (string, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPersistentVolumeClaimRef", ctx, name)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetPersistentVolumeClaimRef indicates an expected call of GetPersistentVolumeClaimRef.
+func (mr *MockKubeClientMockRecorder) GetPersistentVolumeClaimRef(ctx, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPersistentVolumeClaimRef", reflect.TypeOf((*MockKubeClient)(nil).GetPersistentVolumeClaimRef), ctx, name)
+}
+
+// GetSecret mocks base method.
+func (m *MockKubeClient) GetSecret(ctx context.Context, namespace, name string) (map[string][]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSecret", ctx, namespace, name)
+	ret0, _ := ret[0].(map[string][]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSecret indicates an expected call of GetSecret.
+func (mr *MockKubeClientMockRecorder) GetSecret(ctx, namespace, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSecret", reflect.TypeOf((*MockKubeClient)(nil).GetSecret), ctx, namespace, name)
+}
+
+// ListLeases mocks base method.
+func (m *MockKubeClient) ListLeases(ctx context.Context, namespace, labelSelector string) ([]kubeclient.Lease, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListLeases", ctx, namespace, labelSelector)
+	ret0, _ := ret[0].([]kubeclient.Lease)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListLeases indicates an expected call of ListLeases.
+func (mr *MockKubeClientMockRecorder) ListLeases(ctx, namespace, labelSelector any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListLeases", reflect.TypeOf((*MockKubeClient)(nil).ListLeases), ctx, namespace, labelSelector)
+}
+
+// ListNodePods mocks base method.
+func (m *MockKubeClient) ListNodePods(ctx context.Context, nodeName string) ([]kubeclient.Pod, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListNodePods", ctx, nodeName)
+	ret0, _ := ret[0].([]kubeclient.Pod)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListNodePods indicates an expected call of ListNodePods.
+func (mr *MockKubeClientMockRecorder) ListNodePods(ctx, nodeName any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListNodePods", reflect.TypeOf((*MockKubeClient)(nil).ListNodePods), ctx, nodeName)
+}
+
+// ListPersistentVolumes mocks base method.
+func (m *MockKubeClient) ListPersistentVolumes(ctx context.Context, driver string) ([]kubeclient.PersistentVolume, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPersistentVolumes", ctx, driver)
+	ret0, _ := ret[0].([]kubeclient.PersistentVolume)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPersistentVolumes indicates an expected call of ListPersistentVolumes.
+func (mr *MockKubeClientMockRecorder) ListPersistentVolumes(ctx, driver any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPersistentVolumes", reflect.TypeOf((*MockKubeClient)(nil).ListPersistentVolumes), ctx, driver)
+}
+
+// ListReferenceGrants mocks base method.
+func (m *MockKubeClient) ListReferenceGrants(ctx context.Context, namespace string) ([]kubeclient.ReferenceGrant, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListReferenceGrants", ctx, namespace)
+	ret0, _ := ret[0].([]kubeclient.ReferenceGrant)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListReferenceGrants indicates an expected call of ListReferenceGrants.
+func (mr *MockKubeClientMockRecorder) ListReferenceGrants(ctx, namespace any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListReferenceGrants", reflect.TypeOf((*MockKubeClient)(nil).ListReferenceGrants), ctx, namespace)
+}
+
+// ListVolumeAttachments mocks base method.
+func (m *MockKubeClient) ListVolumeAttachments(ctx context.Context, attacher string) ([]kubeclient.VolumeAttachment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListVolumeAttachments", ctx, attacher)
+	ret0, _ := ret[0].([]kubeclient.VolumeAttachment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListVolumeAttachments indicates an expected call of ListVolumeAttachments.
+func (mr *MockKubeClientMockRecorder) ListVolumeAttachments(ctx, attacher any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListVolumeAttachments", reflect.TypeOf((*MockKubeClient)(nil).ListVolumeAttachments), ctx, attacher)
+}
+
+// PatchPersistentVolumeAnnotations mocks base method.
+func (m *MockKubeClient) PatchPersistentVolumeAnnotations(ctx context.Context, name string, annotations map[string]string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PatchPersistentVolumeAnnotations", ctx, name, annotations)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PatchPersistentVolumeAnnotations indicates an expected call of PatchPersistentVolumeAnnotations.
+func (mr *MockKubeClientMockRecorder) PatchPersistentVolumeAnnotations(ctx, name, annotations any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PatchPersistentVolumeAnnotations", reflect.TypeOf((*MockKubeClient)(nil).PatchPersistentVolumeAnnotations), ctx, name, annotations)
+}
+
+// PatchPersistentVolumeClaimAnnotations mocks base method.
+func (m *MockKubeClient) PatchPersistentVolumeClaimAnnotations(ctx context.Context, namespace, name string, annotations map[string]string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PatchPersistentVolumeClaimAnnotations", ctx, namespace, name, annotations)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PatchPersistentVolumeClaimAnnotations indicates an expected call of PatchPersistentVolumeClaimAnnotations.
+func (mr *MockKubeClientMockRecorder) PatchPersistentVolumeClaimAnnotations(ctx, namespace, name, annotations any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PatchPersistentVolumeClaimAnnotations", reflect.TypeOf((*MockKubeClient)(nil).PatchPersistentVolumeClaimAnnotations), ctx, namespace, name, annotations)
+}
@@ -114,6 +114,21 @@ func (mr *MockLinodeClientMockRecorder) DetachVolume(arg0, arg1 any) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DetachVolume", reflect.TypeOf((*MockLinodeClient)(nil).DetachVolume), arg0, arg1)
 }
 
+// GetAccount mocks base method.
+func (m *MockLinodeClient) GetAccount(arg0 context.Context) (*linodego.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAccount", arg0)
+	ret0, _ := ret[0].(*linodego.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAccount indicates an expected call of GetAccount.
+func (mr *MockLinodeClientMockRecorder) GetAccount(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccount", reflect.TypeOf((*MockLinodeClient)(nil).GetAccount), arg0)
+}
+
 // GetInstance mocks base method.
 func (m *MockLinodeClient) GetInstance(arg0 context.Context, arg1 int) (*linodego.Instance, error) {
 	m.ctrl.T.Helper()
@@ -129,6 +144,21 @@ func (mr *MockLinodeClientMockRecorder) GetInstance(arg0, arg1 any) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInstance", reflect.TypeOf((*MockLinodeClient)(nil).GetInstance), arg0, arg1)
 }
 
+// GetProfile mocks base method.
+func (m *MockLinodeClient) GetProfile(arg0 context.Context) (*linodego.Profile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetProfile", arg0)
+	ret0, _ := ret[0].(*linodego.Profile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetProfile indicates an expected call of GetProfile.
+func (mr *MockLinodeClientMockRecorder) GetProfile(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProfile", reflect.TypeOf((*MockLinodeClient)(nil).GetProfile), arg0)
+}
+
 // GetRegion mocks base method.
 func (m *MockLinodeClient) GetRegion(ctx context.Context, regionID string) (*linodego.Region, error) {
 	m.ctrl.T.Helper()
@@ -159,6 +189,36 @@ func (mr *MockLinodeClientMockRecorder) GetVolume(arg0, arg1 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVolume", reflect.TypeOf((*MockLinodeClient)(nil).GetVolume), arg0, arg1)
 }
 
+// ListEvents mocks base method.
+func (m *MockLinodeClient) ListEvents(arg0 context.Context, arg1 *linodego.ListOptions) ([]linodego.Event, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListEvents", arg0, arg1)
+	ret0, _ := ret[0].([]linodego.Event)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListEvents indicates an expected call of ListEvents.
+func (mr *MockLinodeClientMockRecorder) ListEvents(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListEvents", reflect.TypeOf((*MockLinodeClient)(nil).ListEvents), arg0, arg1)
+}
+
+// ListInstanceConfigs mocks base method.
+func (m *MockLinodeClient) ListInstanceConfigs(ctx context.Context, instanceID int, options *linodego.ListOptions) ([]linodego.InstanceConfig, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListInstanceConfigs", ctx, instanceID, options)
+	ret0, _ := ret[0].([]linodego.InstanceConfig)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListInstanceConfigs indicates an expected call of ListInstanceConfigs.
+func (mr *MockLinodeClientMockRecorder) ListInstanceConfigs(ctx, instanceID, options any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListInstanceConfigs", reflect.TypeOf((*MockLinodeClient)(nil).ListInstanceConfigs), ctx, instanceID, options)
+}
+
 // ListInstanceDisks mocks base method.
 func (m *MockLinodeClient) ListInstanceDisks(ctx context.Context, instanceID int, options *linodego.ListOptions) ([]linodego.InstanceDisk, error) {
 	m.ctrl.T.Helper()
@@ -204,6 +264,36 @@ func (mr *MockLinodeClientMockRecorder) ListInstances(arg0, arg1 any) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListInstances", reflect.TypeOf((*MockLinodeClient)(nil).ListInstances), arg0, arg1)
 }
 
+// ListRegions mocks base method.
+func (m *MockLinodeClient) ListRegions(arg0 context.Context, arg1 *linodego.ListOptions) ([]linodego.Region, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListRegions", arg0, arg1)
+	ret0, _ := ret[0].([]linodego.Region)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListRegions indicates an expected call of ListRegions.
+func (mr *MockLinodeClientMockRecorder) ListRegions(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListRegions", reflect.TypeOf((*MockLinodeClient)(nil).ListRegions), arg0, arg1)
+}
+
+// ListTokens mocks base method.
+func (m *MockLinodeClient) ListTokens(arg0 context.Context, arg1 *linodego.ListOptions) ([]linodego.Token, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTokens", arg0, arg1)
+	ret0, _ := ret[0].([]linodego.Token)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTokens indicates an expected call of ListTokens.
+func (mr *MockLinodeClientMockRecorder) ListTokens(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTokens", reflect.TypeOf((*MockLinodeClient)(nil).ListTokens), arg0, arg1)
+}
+
 // ListVolumes mocks base method.
 func (m *MockLinodeClient) ListVolumes(arg0 context.Context, arg1 *linodego.ListOptions) ([]linodego.Volume, error) {
 	m.ctrl.T.Helper()
@@ -248,6 +338,36 @@ func (mr *MockLinodeClientMockRecorder) ResizeVolume(arg0, arg1, arg2 any) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResizeVolume", reflect.TypeOf((*MockLinodeClient)(nil).ResizeVolume), arg0, arg1, arg2)
 }
 
+// UpdateInstanceConfig mocks base method.
+func (m *MockLinodeClient) UpdateInstanceConfig(ctx context.Context, instanceID, configID int, opts linodego.InstanceConfigUpdateOptions) (*linodego.InstanceConfig, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateInstanceConfig", ctx, instanceID, configID, opts)
+	ret0, _ := ret[0].(*linodego.InstanceConfig)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateInstanceConfig indicates an expected call of UpdateInstanceConfig.
+func (mr *MockLinodeClientMockRecorder) UpdateInstanceConfig(ctx, instanceID, configID, opts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateInstanceConfig", reflect.TypeOf((*MockLinodeClient)(nil).UpdateInstanceConfig), ctx, instanceID, configID, opts)
+}
+
+// UpdateVolume mocks base method.
+func (m *MockLinodeClient) UpdateVolume(arg0 context.Context, arg1 int, arg2 linodego.VolumeUpdateOptions) (*linodego.Volume, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateVolume", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*linodego.Volume)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateVolume indicates an expected call of UpdateVolume.
+func (mr *MockLinodeClientMockRecorder) UpdateVolume(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateVolume", reflect.TypeOf((*MockLinodeClient)(nil).UpdateVolume), arg0, arg1, arg2)
+}
+
 // WaitForVolumeLinodeID mocks base method.
 func (m *MockLinodeClient) WaitForVolumeLinodeID(arg0 context.Context, arg1 int, arg2 *int, arg3 int) (*linodego.Volume, error) {
 	m.ctrl.T.Helper()
@@ -0,0 +1,129 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: pkg/kube-client/node_cache.go
+//
+// Generated by this command:
+//
+//	mockgen -source=pkg/kube-client/node_cache.go -destination=mocks/mock_nodecache.go -package=mocks
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	kubeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/kube-client"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockNodeLookup is a mock of NodeLookup interface.
+type MockNodeLookup struct {
+	ctrl     *gomock.Controller
+	recorder *MockNodeLookupMockRecorder
+	isgomock struct{}
+}
+
+// MockNodeLookupMockRecorder is the mock recorder for MockNodeLookup.
+type MockNodeLookupMockRecorder struct {
+	mock *MockNodeLookup
+}
+
+// NewMockNodeLookup creates a new mock instance.
+func NewMockNodeLookup(ctrl *gomock.Controller) *MockNodeLookup {
+	mock := &MockNodeLookup{ctrl: ctrl}
+	mock.recorder = &MockNodeLookupMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockNodeLookup) EXPECT() *MockNodeLookupMockRecorder {
+	return m.recorder
+}
+
+// LookupNode mocks base method.
+func (m *MockNodeLookup) LookupNode(ctx context.Context, linodeID int, label string) (kubeclient.Node, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LookupNode", ctx, linodeID, label)
+	ret0, _ := ret[0].(kubeclient.Node)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LookupNode indicates an expected call of LookupNode.
+func (mr *MockNodeLookupMockRecorder) LookupNode(ctx, linodeID, label any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LookupNode", reflect.TypeOf((*MockNodeLookup)(nil).LookupNode), ctx, linodeID, label)
+}
+
+// MockNodeDeletions is a mock of NodeDeletions interface.
+type MockNodeDeletions struct {
+	ctrl     *gomock.Controller
+	recorder *MockNodeDeletionsMockRecorder
+	isgomock struct{}
+}
+
+// MockNodeDeletionsMockRecorder is the mock recorder for MockNodeDeletions.
+type MockNodeDeletionsMockRecorder struct {
+	mock *MockNodeDeletions
+}
+
+// NewMockNodeDeletions creates a new mock instance.
+func NewMockNodeDeletions(ctrl *gomock.Controller) *MockNodeDeletions {
+	mock := &MockNodeDeletions{ctrl: ctrl}
+	mock.recorder = &MockNodeDeletionsMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockNodeDeletions) EXPECT() *MockNodeDeletionsMockRecorder {
+	return m.recorder
+}
+
+// OnNodeDeleted mocks base method.
+func (m *MockNodeDeletions) OnNodeDeleted(handler func(kubeclient.Node)) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "OnNodeDeleted", handler)
+}
+
+// OnNodeDeleted indicates an expected call of OnNodeDeleted.
+func (mr *MockNodeDeletionsMockRecorder) OnNodeDeleted(handler any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnNodeDeleted", reflect.TypeOf((*MockNodeDeletions)(nil).OnNodeDeleted), handler)
+}
+
+// MockNodeCordons is a mock of NodeCordons interface.
+type MockNodeCordons struct {
+	ctrl     *gomock.Controller
+	recorder *MockNodeCordonsMockRecorder
+	isgomock struct{}
+}
+
+// MockNodeCordonsMockRecorder is the mock recorder for MockNodeCordons.
+type MockNodeCordonsMockRecorder struct {
+	mock *MockNodeCordons
+}
+
+// NewMockNodeCordons creates a new mock instance.
+func NewMockNodeCordons(ctrl *gomock.Controller) *MockNodeCordons {
+	mock := &MockNodeCordons{ctrl: ctrl}
+	mock.recorder = &MockNodeCordonsMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockNodeCordons) EXPECT() *MockNodeCordonsMockRecorder {
+	return m.recorder
+}
+
+// OnNodeCordoned mocks base method.
+func (m *MockNodeCordons) OnNodeCordoned(handler func(kubeclient.Node)) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "OnNodeCordoned", handler)
+}
+
+// OnNodeCordoned indicates an expected call of OnNodeCordoned.
+func (mr *MockNodeCordonsMockRecorder) OnNodeCordoned(handler any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnNodeCordoned", reflect.TypeOf((*MockNodeCordons)(nil).OnNodeCordoned), handler)
+}
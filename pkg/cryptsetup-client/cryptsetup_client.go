@@ -1,7 +1,9 @@
 package cryptsetupclient
 
 import (
+	"errors"
 	"fmt"
+	"os"
 
 	"github.com/martinjungblut/go-cryptsetup"
 )
@@ -9,6 +11,7 @@ import (
 type Device interface {
 	Format(cryptsetup.DeviceType, cryptsetup.GenericParams) error
 	KeyslotAddByVolumeKey(int, string, string) error
+	KeyslotChangeByPassphrase(currentKeyslot int, newKeyslot int, currentPassphrase string, newPassphrase string) error
 	ActivateByVolumeKey(deviceName string, volumeKey string, volumeKeySize int, flags int) error
 	ActivateByPassphrase(deviceName string, keyslot int, passphrase string, flags int) error
 	VolumeKeyGet(keyslot int, passphrase string) ([]byte, int, error)
@@ -30,21 +33,50 @@ type CryptSetup struct {
 }
 
 // Init opens a crypt device by device path.
+//
+// The device node is locked until the returned device is freed, so that
+// concurrent callers, including other processes, cannot format or activate
+// the same device at the same time.
 func (c CryptSetup) Init(devicePath string) (Device, error) {
-	device, err := cryptsetup.Init(devicePath)
+	unlock, err := lockDevice(devicePath)
 	if err != nil {
 		return nil, fmt.Errorf("init cryptsetup by device path %q: %w", devicePath, err)
 	}
-	return device, nil
+	device, err := cryptsetup.Init(devicePath)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("init cryptsetup by device path %q: %w", devicePath, err), unlock())
+	}
+	return &lockedDevice{Device: device, unlock: unlock}, nil
 }
 
 // InitByName opens an active crypt device using its mapped name.
+//
+// Like [CryptSetup.Init], the device the crypt device is built on is locked
+// until the returned device is freed.
 func (c CryptSetup) InitByName(name string) (Device, error) {
+	var unlock func() error
+	devicePath, err := underlyingDevice(name)
+	if err == nil {
+		unlock, err = lockDevice(devicePath)
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		// The device is not active; let libcryptsetup report it.
+		unlock = nil
+	} else if err != nil {
+		return nil, fmt.Errorf("init cryptsetup by name %q: %w", name, err)
+	}
+
 	device, err := cryptsetup.InitByName(name)
 	if err != nil {
+		if unlock != nil {
+			err = errors.Join(err, unlock())
+		}
 		return nil, fmt.Errorf("init cryptsetup by name %q: %w", name, err)
 	}
-	return device, nil
+	if unlock == nil {
+		return device, nil
+	}
+	return &lockedDevice{Device: device, unlock: unlock}, nil
 }
 
 type LuksDevice struct {
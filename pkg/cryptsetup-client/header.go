@@ -0,0 +1,56 @@
+package cryptsetupclient
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	utilexec "k8s.io/utils/exec"
+)
+
+// headerMagic starts the LUKS headers, and their backups.
+var headerMagic = []byte("LUKS\xba\xbe")
+
+// BackupHeader returns a backup of the LUKS header of the device at
+// devicePath, with its keyslots, made with the cryptsetup command run by
+// exec. The backup does not hold the volume key, which can only be recovered
+// from it with the passphrase of a keyslot.
+func BackupHeader(exec utilexec.Interface, devicePath string) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "luks-header-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	// cryptsetup refuses to overwrite the backup file
+	path := filepath.Join(dir, "header")
+	if out, err := exec.Command("cryptsetup", "luksHeaderBackup", devicePath, "--header-backup-file", path).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("back up the LUKS header of %s: %w: %s", devicePath, err, bytes.TrimSpace(out))
+	}
+	return os.ReadFile(path)
+}
+
+// RestoreHeader replaces the LUKS header of the device at devicePath with
+// header, a backup made by [BackupHeader], with the cryptsetup command run
+// by exec. The device must not be open.
+func RestoreHeader(exec utilexec.Interface, devicePath string, header []byte) error {
+	if !bytes.HasPrefix(header, headerMagic) {
+		return errors.New("not a LUKS header backup")
+	}
+	dir, err := os.MkdirTemp("", "luks-header-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "header")
+	if err := os.WriteFile(path, header, 0o600); err != nil {
+		return err
+	}
+	if out, err := exec.Command("cryptsetup", "luksHeaderRestore", devicePath, "--header-backup-file", path, "--batch-mode").CombinedOutput(); err != nil {
+		return fmt.Errorf("restore the LUKS header of %s: %w: %s", devicePath, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package cryptsetupclient
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	utilexec "k8s.io/utils/exec"
+	testingexec "k8s.io/utils/exec/testing"
+)
+
+func TestRestoreHeader(t *testing.T) {
+	header := []byte("LUKS\xba\xbe header")
+	var restored []byte
+	fakeExec := &testingexec.FakeExec{
+		CommandScript: []testingexec.FakeCommandAction{
+			func(cmd string, args ...string) utilexec.Cmd {
+				if cmd != "cryptsetup" || args[0] != "luksHeaderRestore" || args[1] != "/dev/sdb" {
+					t.Errorf("command = %s %v, want cryptsetup luksHeaderRestore /dev/sdb", cmd, args)
+				}
+				return &testingexec.FakeCmd{
+					CombinedOutputScript: []testingexec.FakeAction{
+						func() ([]byte, []byte, error) {
+							var err error
+							restored, err = os.ReadFile(args[3])
+							return nil, nil, err
+						},
+					},
+				}
+			},
+		},
+	}
+	if err := RestoreHeader(fakeExec, "/dev/sdb", header); err != nil {
+		t.Fatalf("RestoreHeader() error = %v", err)
+	}
+	if !bytes.Equal(restored, header) {
+		t.Errorf("restored header = %q, want %q", restored, header)
+	}
+
+	// Other data, e.g. a compressed backup, is refused before running cryptsetup
+	if err := RestoreHeader(&testingexec.FakeExec{}, "/dev/sdb", []byte{0x1f, 0x8b}); err == nil {
+		t.Error("RestoreHeader() of an invalid header succeeded")
+	}
+}
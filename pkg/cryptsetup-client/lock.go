@@ -0,0 +1,166 @@
+package cryptsetupclient
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// mapperDir is the directory device-mapper creates the nodes of active
+// crypt devices in.
+const mapperDir = "/dev/mapper/"
+
+// sysDevBlockDir has a directory per block device, named after its device
+// numbers, which lists the devices a device-mapper device is built on.
+var sysDevBlockDir = "/sys/dev/block/"
+
+// lockTimeout is how long to wait for another process to release the lock
+// of a device.
+var lockTimeout = 2 * time.Minute
+
+// lockPollInterval is how often to try to take the lock of a device while
+// another process holds it.
+const lockPollInterval = 100 * time.Millisecond
+
+// errLockTimeout is returned when the lock of a device is held by another
+// process for longer than lockTimeout.
+var errLockTimeout = errors.New("timed out waiting for another process to release the device")
+
+// deviceLock is a flock(2) held by this process on a device node.
+type deviceLock struct {
+	file *os.File
+	// released is closed once the lock is released.
+	released chan struct{}
+}
+
+var (
+	deviceLocksMu sync.Mutex                 // protects deviceLocks
+	deviceLocks   = map[string]*deviceLock{} // By resolved device path
+)
+
+// lockDevice takes an exclusive flock(2) on the device node at path, waiting
+// up to lockTimeout for another process, or another goroutine of this
+// process, to release it. The returned function releases the lock.
+//
+// The lock is taken on the device node itself, so it is shared between
+// processes and follows symlinks such as /dev/disk/by-id/*. Since a flock(2)
+// does not exclude the other open files of the process holding it, the
+// locks held by the process are also tracked, so that its goroutines do not
+// format or open the same device at the same time either. Locking a device
+// the calling goroutine already locked therefore waits for itself until the
+// timeout.
+func lockDevice(path string) (func() error, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(resolved)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := time.NewTimer(lockTimeout)
+	defer timeout.Stop()
+	for {
+		deviceLocksMu.Lock()
+		if held, ok := deviceLocks[resolved]; ok {
+			deviceLocksMu.Unlock()
+			select {
+			case <-held.released:
+				continue
+			case <-timeout.C:
+				return nil, errors.Join(fmt.Errorf("lock %q: %w", path, errLockTimeout), file.Close())
+			}
+		}
+		err = unix.Flock(int(file.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+		if err == nil {
+			lock := &deviceLock{file: file, released: make(chan struct{})}
+			deviceLocks[resolved] = lock
+			deviceLocksMu.Unlock()
+			return func() error { return unlockDevice(resolved, lock) }, nil
+		}
+		deviceLocksMu.Unlock()
+
+		if !errors.Is(err, unix.EWOULDBLOCK) && !errors.Is(err, unix.EINTR) {
+			return nil, errors.Join(fmt.Errorf("lock %q: %w", path, err), file.Close())
+		}
+		select {
+		case <-time.After(lockPollInterval):
+		case <-timeout.C:
+			return nil, errors.Join(fmt.Errorf("lock %q: %w", path, errLockTimeout), file.Close())
+		}
+	}
+}
+
+// unlockDevice releases lock, taken with lockDevice on the resolved device
+// path. Releasing it again does nothing.
+func unlockDevice(resolved string, lock *deviceLock) error {
+	deviceLocksMu.Lock()
+	defer deviceLocksMu.Unlock()
+
+	if deviceLocks[resolved] != lock {
+		return nil
+	}
+	delete(deviceLocks, resolved)
+	close(lock.released)
+	// Closing the file releases the lock.
+	return lock.file.Close()
+}
+
+// underlyingDevice returns the path of the device the active crypt device
+// name is built on. The mapped device node itself is not locked, since an
+// open file on it makes deactivating the device fail with EBUSY.
+func underlyingDevice(name string) (string, error) {
+	var stat unix.Stat_t
+	if err := unix.Stat(mapperDir+name, &stat); err != nil {
+		return "", &os.PathError{Op: "stat", Path: mapperDir + name, Err: err}
+	}
+	devicePath, err := slaveDevice(unix.Major(stat.Rdev), unix.Minor(stat.Rdev))
+	if err != nil {
+		return "", fmt.Errorf("find the device of %q: %w", name, err)
+	}
+	return devicePath, nil
+}
+
+// slaveDevice returns the path of the only device the device-mapper device
+// with the given device numbers is built on.
+func slaveDevice(major, minor uint32) (string, error) {
+	slavesDir := fmt.Sprintf("%s%d:%d/slaves", sysDevBlockDir, major, minor)
+	slaves, err := os.ReadDir(slavesDir)
+	if err != nil {
+		// Not wrapped: a missing sysfs entry does not mean the crypt
+		// device is not active.
+		return "", fmt.Errorf("list %s: %v", slavesDir, err)
+	}
+	if len(slaves) != 1 {
+		return "", fmt.Errorf("found %d devices in %s, want 1", len(slaves), slavesDir)
+	}
+	return "/dev/" + slaves[0].Name(), nil
+}
+
+// lockedDevice is a [Device] that holds a lock on its device node until it
+// is freed.
+type lockedDevice struct {
+	Device
+
+	unlock     func() error
+	unlockOnce sync.Once
+}
+
+// Free releases the device, then its lock.
+func (d *lockedDevice) Free() bool {
+	freed := d.Device.Free()
+	d.unlockOnce.Do(func() {
+		// Report a failure to release the lock like a failure to free the
+		// device.
+		if err := d.unlock(); err != nil {
+			freed = false
+		}
+	})
+	return freed
+}
@@ -0,0 +1,171 @@
+package cryptsetupclient
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockFromOtherProcess takes the flock(2) of path on its own open file, like
+// another process would, and returns that file.
+func lockFromOtherProcess(t *testing.T, path string) *os.File {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open device: %v", err)
+	}
+	if err := unix.Flock(int(file.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
+		t.Fatalf("lock device: %v", err)
+	}
+	return file
+}
+
+// isLocked reports whether the flock(2) of path is held.
+func isLocked(t *testing.T, path string) bool {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open device: %v", err)
+	}
+	defer file.Close()
+	err = unix.Flock(int(file.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+	if errors.Is(err, unix.EWOULDBLOCK) {
+		return true
+	}
+	if err != nil {
+		t.Fatalf("lock device: %v", err)
+	}
+	return false
+}
+
+func createDevice(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "device")
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatalf("create device: %v", err)
+	}
+	return path
+}
+
+func TestLockDevice(t *testing.T) {
+	path := createDevice(t)
+	other := lockFromOtherProcess(t, path)
+
+	type result struct {
+		unlock func() error
+		err    error
+	}
+	locked := make(chan result)
+	go func() {
+		unlock, err := lockDevice(path)
+		locked <- result{unlock, err}
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("lockDevice() acquired a lock that is already held")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	if err := other.Close(); err != nil {
+		t.Fatalf("release lock: %v", err)
+	}
+	select {
+	case res := <-locked:
+		if res.err != nil {
+			t.Fatalf("lockDevice() error = %v", res.err)
+		}
+		if err := res.unlock(); err != nil {
+			t.Errorf("release lock: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("lockDevice() did not acquire the released lock")
+	}
+}
+
+func TestLockDeviceTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { lockTimeout = timeout }(lockTimeout)
+	lockTimeout = 200 * time.Millisecond
+
+	path := createDevice(t)
+	other := lockFromOtherProcess(t, path)
+	defer other.Close()
+
+	if _, err := lockDevice(path); !errors.Is(err, errLockTimeout) {
+		t.Errorf("lockDevice() error = %v, want %v", err, errLockTimeout)
+	}
+}
+
+func TestLockDeviceSameProcess(t *testing.T) {
+	defer func(timeout time.Duration) { lockTimeout = timeout }(lockTimeout)
+	lockTimeout = 200 * time.Millisecond
+
+	path := createDevice(t)
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(path, link); err != nil {
+		t.Fatalf("create symlink: %v", err)
+	}
+
+	unlock, err := lockDevice(path)
+	if err != nil {
+		t.Fatalf("lockDevice() error = %v", err)
+	}
+	// Another goroutine locking the same device, through a symlink, waits
+	// for the lock to be released
+	if _, err := lockDevice(link); !errors.Is(err, errLockTimeout) {
+		t.Errorf("lockDevice() of a device locked by the process error = %v, want %v", err, errLockTimeout)
+	}
+
+	locked := make(chan error)
+	go func() {
+		unlock, err := lockDevice(link)
+		if err == nil {
+			err = unlock()
+		}
+		locked <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if err := unlock(); err != nil {
+		t.Fatalf("release lock: %v", err)
+	}
+	if err := <-locked; err != nil {
+		t.Errorf("lockDevice() once released error = %v", err)
+	}
+	if isLocked(t, path) {
+		t.Error("device still locked after all locks were released")
+	}
+	if err := unlock(); err != nil {
+		t.Errorf("release lock again: %v", err)
+	}
+}
+
+func TestLockDeviceNotExist(t *testing.T) {
+	if _, err := lockDevice(filepath.Join(t.TempDir(), "missing")); !os.IsNotExist(err) {
+		t.Errorf("lockDevice() error = %v, want not exist", err)
+	}
+}
+
+func TestSlaveDevice(t *testing.T) {
+	defer func(dir string) { sysDevBlockDir = dir }(sysDevBlockDir)
+	sysDevBlockDir = t.TempDir() + "/"
+
+	if err := os.MkdirAll(filepath.Join(sysDevBlockDir, "253:0", "slaves", "sdb"), 0o755); err != nil {
+		t.Fatalf("create sysfs: %v", err)
+	}
+
+	got, err := slaveDevice(253, 0)
+	if err != nil {
+		t.Fatalf("slaveDevice() error = %v", err)
+	}
+	if want := "/dev/sdb"; got != want {
+		t.Errorf("slaveDevice() = %q, want %q", got, want)
+	}
+
+	if _, err := slaveDevice(253, 1); err == nil || errors.Is(err, os.ErrNotExist) {
+		t.Errorf("slaveDevice() error = %v, want an error not matching os.ErrNotExist", err)
+	}
+}
@@ -33,6 +33,10 @@ const (
 	diskPartitionSuffix  = "-part"
 	diskSDPath           = "/dev/sd"
 	diskSDPattern        = "/dev/sd*"
+
+	// diskPartitionScript is the sfdisk script creating a GPT partition
+	// table with a single Linux partition using all of the disk.
+	diskPartitionScript = "label: gpt\n,,L\n"
 )
 
 // DeviceUtils are a collection of methods that act on the devices attached
@@ -45,6 +49,10 @@ type DeviceUtils interface {
 	// VerifyDevicePath returns the first of the list of device paths that
 	// exists on the machine, or an empty string if none exists
 	VerifyDevicePath(devicePaths []string) (string, error)
+
+	// PartitionDisk creates a GPT partition table holding a single Linux
+	// partition spanning the whole disk at devicePath
+	PartitionDisk(devicePath string) error
 }
 
 type deviceUtils struct {
@@ -99,6 +107,23 @@ func (m *deviceUtils) VerifyDevicePath(devicePaths []string) (string, error) {
 	return "", nil
 }
 
+// Creates a GPT partition table with a single partition on the disk at
+// devicePath, and waits for udev to handle the partition, so that its
+// /dev/disk/by-id/*-part1 paths are created.
+func (m *deviceUtils) PartitionDisk(devicePath string) error {
+	cmd := m.exec.Command("sfdisk", "--wipe", "always", devicePath)
+	cmd.SetStdin(strings.NewReader(diskPartitionScript))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("sfdisk %q failed: %w: %s", devicePath, err, strings.TrimSpace(string(out)))
+	}
+
+	if _, err := m.exec.Command("udevadm", "settle").CombinedOutput(); err != nil {
+		// The partition is waited for by the caller, log and continue
+		klog.Errorf("udevadm settle failed after partitioning %q: %v", devicePath, err)
+	}
+	return nil
+}
+
 // Triggers the application of udev rules by calling "udevadm trigger
 // --action=change" for newly created "/dev/sd*" drives (exist only in
 // after set). This is workaround for Issue #7972. Once the underlying
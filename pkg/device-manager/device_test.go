@@ -16,6 +16,7 @@ package devicemanager
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"reflect"
 	"testing"
@@ -259,3 +260,67 @@ func Test_udevadmChangeToDrive(t *testing.T) {
 		})
 	}
 }
+
+func Test_deviceUtils_PartitionDisk(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr bool
+		setup   func(*mocks.MockExecutor, *mocks.MockCommand)
+	}{
+		{
+			name:    "Success case",
+			wantErr: false,
+			setup: func(mockExec *mocks.MockExecutor, mockCmd *mocks.MockCommand) {
+				mockExec.EXPECT().Command("sfdisk", "--wipe", "always", "/dev/disk/by-id/linode-vol-123").Return(mockCmd)
+				mockCmd.EXPECT().SetStdin(gomock.Any()).Do(func(stdin io.Reader) {
+					if script, _ := io.ReadAll(stdin); string(script) != diskPartitionScript {
+						t.Errorf("sfdisk script = %q, want %q", script, diskPartitionScript)
+					}
+				})
+				mockExec.EXPECT().Command("udevadm", "settle").Return(mockCmd)
+				mockCmd.EXPECT().CombinedOutput().Return([]byte(""), nil).Times(2)
+			},
+		},
+		{
+			name:    "udevadm settle error",
+			wantErr: false,
+			setup: func(mockExec *mocks.MockExecutor, mockCmd *mocks.MockCommand) {
+				mockExec.EXPECT().Command("sfdisk", "--wipe", "always", "/dev/disk/by-id/linode-vol-123").Return(mockCmd)
+				mockCmd.EXPECT().SetStdin(gomock.Any())
+				mockExec.EXPECT().Command("udevadm", "settle").Return(mockCmd)
+				gomock.InOrder(
+					mockCmd.EXPECT().CombinedOutput().Return([]byte(""), nil),
+					mockCmd.EXPECT().CombinedOutput().Return([]byte(""), fmt.Errorf("timeout")),
+				)
+			},
+		},
+		{
+			name:    "sfdisk error",
+			wantErr: true,
+			setup: func(mockExec *mocks.MockExecutor, mockCmd *mocks.MockCommand) {
+				mockExec.EXPECT().Command("sfdisk", "--wipe", "always", "/dev/disk/by-id/linode-vol-123").Return(mockCmd)
+				mockCmd.EXPECT().SetStdin(gomock.Any())
+				mockCmd.EXPECT().CombinedOutput().Return([]byte("device is busy"), fmt.Errorf("exit status 1"))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockFs := mocks.NewMockFileSystem(ctrl)
+			mockExec := mocks.NewMockExecutor(ctrl)
+			mockCmd := mocks.NewMockCommand(ctrl)
+
+			if tt.setup != nil {
+				tt.setup(mockExec, mockCmd)
+			}
+
+			m := NewDeviceUtils(mockFs, mockExec)
+			if err := m.PartitionDisk("/dev/disk/by-id/linode-vol-123"); (err != nil) != tt.wantErr {
+				t.Errorf("deviceUtils.PartitionDisk() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
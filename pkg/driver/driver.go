@@ -0,0 +1,242 @@
+// Package driver embeds the Linode Block Storage CSI driver in other
+// programs, such as test harnesses, configured with functional options
+// rather than the parameters of the driver binary.
+//
+//	d, err := driver.NewDriver(ctx,
+//		driver.WithLinodeClient(client),
+//		driver.WithMetadata(driver.Metadata{ID: 123, Label: "linode123", Region: "us-east"}),
+//		driver.WithFeatureGates(map[string]bool{"strict-device-size": true}),
+//	)
+//	if err != nil {
+//		return err
+//	}
+//	d.Run(ctx, "unix:///csi/csi.sock")
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"k8s.io/mount-utils"
+
+	internaldriver "github.com/linode/linode-blockstorage-csi-driver/internal/driver"
+	cryptsetupclient "github.com/linode/linode-blockstorage-csi-driver/pkg/cryptsetup-client"
+	devicemanager "github.com/linode/linode-blockstorage-csi-driver/pkg/device-manager"
+	filesystem "github.com/linode/linode-blockstorage-csi-driver/pkg/filesystem"
+	linodeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-client"
+	mountmanager "github.com/linode/linode-blockstorage-csi-driver/pkg/mount-manager"
+)
+
+// Name is the default name of the driver.
+const Name = internaldriver.Name
+
+type (
+	// Options are the options of the driver, set with [WithOptions].
+	Options = internaldriver.Options
+	// Metadata describes the Linode instance the driver runs on.
+	Metadata = internaldriver.Metadata
+	// Encryption formats and opens the LUKS volumes.
+	Encryption = internaldriver.Encryption
+)
+
+// featureGates are the boolean options of the driver set by
+// [WithFeatureGates], by name.
+var featureGates = map[string]func(*Options, bool){
+	"allow-fs-mismatch-mount":            func(o *Options, on bool) { o.AllowFSMismatchMount = on },
+	"annotate-clone-verification":        func(o *Options, on bool) { o.AnnotateCloneVerification = on },
+	"async-controller-unpublish":         func(o *Options, on bool) { o.AsyncControllerUnpublish = on },
+	"attach-config-from-node-annotation": func(o *Options, on bool) { o.AttachConfigFromNodeAnnotation = on },
+	"feature-telemetry":                  func(o *Options, on bool) { o.FeatureTelemetry = on },
+	"read-only-norecovery":               func(o *Options, on bool) { o.ReadOnlyNoRecovery = on },
+	"reject-legacy-volume-ids":           func(o *Options, on bool) { o.RejectLegacyVolumeIDs = on },
+	"strict-device-size":                 func(o *Options, on bool) { o.StrictDeviceSize = on },
+}
+
+// FeatureGates returns the names of the feature gates [WithFeatureGates]
+// accepts.
+func FeatureGates() []string {
+	return slices.Sorted(maps.Keys(featureGates))
+}
+
+// config is what the options of [NewDriver] set.
+type config struct {
+	client            linodeclient.LinodeClient
+	mounter           *mount.SafeFormatAndMount
+	deviceUtils       devicemanager.DeviceUtils
+	fileSystem        filesystem.FileSystem
+	encryption        *Encryption
+	metadata          *Metadata
+	name              string
+	vendorVersion     string
+	volumeLabelPrefix string
+	metricsPort       string
+	tracingPort       string
+	opts              Options
+	featureGates      map[string]bool
+}
+
+// newConfig returns the configuration set by opts.
+func newConfig(opts []Option) (*config, error) {
+	c := &config{name: Name}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.client == nil {
+		return nil, errors.New("a Linode client is required")
+	}
+	for name, on := range c.featureGates {
+		set, ok := featureGates[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown feature gate %q, must be one of %s", name, strings.Join(FeatureGates(), ", "))
+		}
+		set(&c.opts, on)
+	}
+	return c, nil
+}
+
+// Option configures the driver created by [NewDriver].
+type Option func(*config)
+
+// WithLinodeClient sets the client of the Linode API the driver uses. It is
+// required.
+func WithLinodeClient(client linodeclient.LinodeClient) Option {
+	return func(c *config) { c.client = client }
+}
+
+// WithMounter sets the mounter the node plugin mounts and formats volumes
+// with, instead of the mount and mkfs commands of the host.
+func WithMounter(mounter *mount.SafeFormatAndMount) Option {
+	return func(c *config) { c.mounter = mounter }
+}
+
+// WithDeviceUtils sets how the node plugin finds the devices of the volumes,
+// instead of their /dev/disk/by-id paths.
+func WithDeviceUtils(deviceUtils devicemanager.DeviceUtils) Option {
+	return func(c *config) { c.deviceUtils = deviceUtils }
+}
+
+// WithFileSystem sets the file system the driver reads the devices and the
+// metadata of the instance from, instead of the one of the host.
+func WithFileSystem(fileSystem filesystem.FileSystem) Option {
+	return func(c *config) { c.fileSystem = fileSystem }
+}
+
+// WithEncryption sets how the node plugin formats and opens LUKS volumes,
+// instead of with the cryptsetup library.
+func WithEncryption(encryption Encryption) Option {
+	return func(c *config) { c.encryption = &encryption }
+}
+
+// WithMetadata sets the Linode instance the driver runs on, instead of
+// finding it with the metadata service or the Linode API.
+func WithMetadata(metadata Metadata) Option {
+	return func(c *config) { c.metadata = &metadata }
+}
+
+// WithName sets the name of the driver, [Name] by default.
+func WithName(name string) Option {
+	return func(c *config) { c.name = name }
+}
+
+// WithVendorVersion sets the version the driver reports.
+func WithVendorVersion(version string) Option {
+	return func(c *config) { c.vendorVersion = version }
+}
+
+// WithVolumeLabelPrefix sets the prefix of the labels of the volumes the
+// driver creates.
+func WithVolumeLabelPrefix(prefix string) Option {
+	return func(c *config) { c.volumeLabelPrefix = prefix }
+}
+
+// WithMetrics serves the metrics of the driver on port.
+func WithMetrics(port string) Option {
+	return func(c *config) { c.metricsPort = port }
+}
+
+// WithTracing exports the traces of the driver to the OTLP collector on
+// port, as configured by the Tracing field of the options.
+func WithTracing(port string) Option {
+	return func(c *config) { c.tracingPort = port }
+}
+
+// WithOptions changes the options of the driver with set, for the options
+// without a dedicated [Option].
+func WithOptions(set func(*Options)) Option {
+	return func(c *config) { set(&c.opts) }
+}
+
+// WithFeatureGates enables or disables the features of the driver by the
+// names returned by [FeatureGates]. They are applied after the options set
+// with [WithOptions].
+func WithFeatureGates(gates map[string]bool) Option {
+	return func(c *config) {
+		if c.featureGates == nil {
+			c.featureGates = make(map[string]bool)
+		}
+		maps.Copy(c.featureGates, gates)
+	}
+}
+
+// Driver is a Linode Block Storage CSI driver, serving the identity,
+// controller and node services.
+type Driver struct {
+	driver *internaldriver.LinodeDriver
+}
+
+// NewDriver sets up a driver configured with opts. Only the Linode client is
+// required: the node plugin uses the commands, devices and cryptsetup
+// library of the host by default, and finds the instance it runs on with the
+// metadata service or the Linode API.
+func NewDriver(ctx context.Context, opts ...Option) (*Driver, error) {
+	c, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.fileSystem == nil {
+		c.fileSystem = filesystem.NewFileSystem()
+	}
+	if c.mounter == nil {
+		c.mounter = mountmanager.NewSafeMounter()
+	}
+	if c.deviceUtils == nil {
+		c.deviceUtils = devicemanager.NewDeviceUtils(c.fileSystem, c.mounter.Exec)
+	}
+	if c.encryption == nil {
+		encryption := internaldriver.NewLuksEncryption(c.mounter.Exec, c.fileSystem, cryptsetupclient.NewCryptSetup())
+		c.encryption = &encryption
+	}
+	if c.metadata == nil {
+		metadata, err := internaldriver.GetNodeMetadata(ctx, c.client, c.fileSystem)
+		if err != nil {
+			return nil, fmt.Errorf("get node metadata: %w", err)
+		}
+		c.metadata = &metadata
+	}
+
+	enableMetrics, enableTracing := "false", "false"
+	if c.metricsPort != "" {
+		enableMetrics = internaldriver.True
+	}
+	if c.tracingPort != "" {
+		enableTracing = internaldriver.True
+	}
+
+	d := internaldriver.GetLinodeDriver(ctx)
+	if err := d.SetupLinodeDriver(ctx, c.client, c.mounter, c.deviceUtils, *c.metadata, c.name, c.vendorVersion, c.volumeLabelPrefix,
+		*c.encryption, enableMetrics, c.metricsPort, enableTracing, c.tracingPort, c.opts); err != nil {
+		return nil, fmt.Errorf("setup driver: %w", err)
+	}
+	return &Driver{driver: d}, nil
+}
+
+// Run serves the CSI services of the driver on endpoint, a unix:// or
+// tcp:// URL or a comma-separated list of them, until ctx is canceled.
+func (d *Driver) Run(ctx context.Context, endpoint string) {
+	d.driver.Run(ctx, endpoint)
+}
@@ -0,0 +1,67 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.uber.org/mock/gomock"
+	"k8s.io/mount-utils"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+)
+
+func TestNewConfig(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mocks.NewMockLinodeClient(ctrl)
+
+	c, err := newConfig([]Option{
+		WithLinodeClient(client),
+		WithFeatureGates(map[string]bool{"strict-device-size": true, "async-controller-unpublish": true}),
+		// Gates win over the options, whatever their order
+		WithOptions(func(o *Options) { o.StrictDeviceSize = false; o.ClusterName = "lke123" }),
+		WithFeatureGates(map[string]bool{"async-controller-unpublish": false}),
+	})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if c.name != Name || !c.opts.StrictDeviceSize || c.opts.AsyncControllerUnpublish || c.opts.ClusterName != "lke123" {
+		t.Errorf("newConfig() = name %q, options %+v, want %q with the gates and options set", c.name, c.opts, Name)
+	}
+
+	if _, err := newConfig([]Option{WithLinodeClient(client), WithFeatureGates(map[string]bool{"topology": true})}); err == nil {
+		t.Error("newConfig() with an unknown feature gate succeeded")
+	}
+	if _, err := newConfig(nil); err == nil {
+		t.Error("newConfig() without a Linode client succeeded")
+	}
+}
+
+func TestNewDriver(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The capabilities of the regions and account are discovered when they
+	// are first needed if they cannot be listed
+	client := mocks.NewMockLinodeClient(ctrl)
+	client.EXPECT().ListRegions(gomock.Any(), gomock.Any()).Return(nil, errors.New("unavailable")).AnyTimes()
+	client.EXPECT().GetAccount(gomock.Any()).Return(nil, errors.New("unavailable")).AnyTimes()
+	mockExec := mocks.NewMockExecutor(ctrl)
+	mockExec.EXPECT().LookPath(gomock.Any()).Return("", nil).AnyTimes()
+	fileSystem := mocks.NewMockFileSystem(ctrl)
+	fileSystem.EXPECT().Stat(gomock.Any()).Return(nil, nil).AnyTimes()
+
+	d, err := NewDriver(context.Background(),
+		WithLinodeClient(client),
+		WithMounter(&mount.SafeFormatAndMount{Interface: mocks.NewMockMounter(ctrl), Exec: mockExec}),
+		WithDeviceUtils(mocks.NewMockDeviceUtils(ctrl)),
+		WithFileSystem(fileSystem),
+		WithMetadata(Metadata{ID: 123, Label: "linode123", Region: "us-east", Memory: 4 << 30}),
+		WithVendorVersion("test-vendor"),
+		WithFeatureGates(map[string]bool{"strict-device-size": true}),
+	)
+	if err != nil || d == nil {
+		t.Fatalf("NewDriver() = %v, %v", d, err)
+	}
+}
@@ -0,0 +1,161 @@
+package hosthelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/rpc"
+	"sync"
+
+	"k8s.io/klog/v2"
+	"k8s.io/mount-utils"
+	utilexec "k8s.io/utils/exec"
+
+	cryptsetupclient "github.com/linode/linode-blockstorage-csi-driver/pkg/cryptsetup-client"
+)
+
+// Client forwards host-level operations to a host helper [Server].
+//
+// If the connection to the helper is lost (e.g. because the helper was
+// restarted), the client transparently reconnects on the next call.
+type Client struct {
+	socketPath string
+
+	mu  sync.Mutex // protects rpc
+	rpc *rpc.Client
+}
+
+// NewClient returns a Client for the host helper listening on the unix socket
+// at socketPath. The connection is established on first use, so the helper
+// does not need to be running yet.
+func NewClient(socketPath string) *Client {
+	return &Client{socketPath: socketPath}
+}
+
+// Close closes the connection to the host helper.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.rpc == nil {
+		return nil
+	}
+	err := c.rpc.Close()
+	c.rpc = nil
+	return err
+}
+
+// conn returns the current connection to the helper, dialing a new one if
+// necessary.
+func (c *Client) conn() (*rpc.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.rpc != nil {
+		return c.rpc, nil
+	}
+	conn, err := rpc.Dial("unix", c.socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("dial host helper at %s: %w", c.socketPath, err)
+	}
+	c.rpc = conn
+	return conn, nil
+}
+
+// reset drops conn so the next call redials the helper.
+func (c *Client) reset(conn *rpc.Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.rpc == conn {
+		if err := c.rpc.Close(); err != nil {
+			klog.V(4).Infof("Closing host helper connection: %v", err)
+		}
+		c.rpc = nil
+	}
+}
+
+// call invokes method on the helper, retrying once on a fresh connection if
+// the current one was shut down.
+func (c *Client) call(ctx context.Context, method string, args, reply any) error {
+	for attempt := 0; ; attempt++ {
+		conn, err := c.conn()
+		if err != nil {
+			return err
+		}
+
+		call := conn.Go(method, args, reply, make(chan *rpc.Call, 1))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-call.Done:
+		}
+
+		if errors.Is(call.Error, rpc.ErrShutdown) && attempt == 0 {
+			c.reset(conn)
+			continue
+		}
+		return call.Error
+	}
+}
+
+// Mounter returns a [mount.Interface] that performs mounts and unmounts
+// through the helper. Read-only operations, such as listing mount points, do
+// not need elevated privileges and are performed locally.
+func (c *Client) Mounter() mount.Interface {
+	return &mounter{Interface: mount.New(""), client: c}
+}
+
+// Executor returns a [utilexec.Interface] that runs commands through the
+// helper. Only the commands allowed by the helper can be run.
+func (c *Client) Executor() utilexec.Interface {
+	return &executor{client: c}
+}
+
+// CryptSetup returns a [cryptsetupclient.CryptSetupClient] that manages
+// encrypted devices through the helper.
+func (c *Client) CryptSetup() cryptsetupclient.CryptSetupClient {
+	return &cryptSetup{client: c}
+}
+
+type mounter struct {
+	mount.Interface
+
+	client *Client
+}
+
+var _ mount.Interface = &mounter{}
+
+func (m *mounter) Mount(source, target, fstype string, options []string) error {
+	return m.MountSensitive(source, target, fstype, options, nil)
+}
+
+func (m *mounter) MountSensitive(source, target, fstype string, options, sensitiveOptions []string) error {
+	return m.client.call(context.Background(), mountServiceName+".Mount", &MountArgs{
+		Source:           source,
+		Target:           target,
+		FSType:           fstype,
+		Options:          options,
+		SensitiveOptions: sensitiveOptions,
+	}, &Empty{})
+}
+
+func (m *mounter) MountSensitiveWithoutSystemd(source, target, fstype string, options, sensitiveOptions []string) error {
+	return m.MountSensitiveWithoutSystemdWithMountFlags(source, target, fstype, options, sensitiveOptions, nil)
+}
+
+func (m *mounter) MountSensitiveWithoutSystemdWithMountFlags(source, target, fstype string, options, sensitiveOptions, mountFlags []string) error {
+	return m.client.call(context.Background(), mountServiceName+".Mount", &MountArgs{
+		Source:           source,
+		Target:           target,
+		FSType:           fstype,
+		Options:          options,
+		SensitiveOptions: sensitiveOptions,
+		MountFlags:       mountFlags,
+		WithoutSystemd:   true,
+	}, &Empty{})
+}
+
+func (m *mounter) Unmount(target string) error {
+	return m.client.call(context.Background(), mountServiceName+".Unmount", &UnmountArgs{Target: target}, &Empty{})
+}
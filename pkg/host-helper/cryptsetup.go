@@ -0,0 +1,184 @@
+package hosthelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/martinjungblut/go-cryptsetup"
+
+	cryptsetupclient "github.com/linode/linode-blockstorage-csi-driver/pkg/cryptsetup-client"
+)
+
+// CryptError is returned when a libcryptsetup function called by the helper
+// failed. It preserves the libcryptsetup return code, like
+// [cryptsetup.Error] does.
+type CryptError struct {
+	code    int
+	message string
+}
+
+func (e *CryptError) Error() string { return e.message }
+
+// Code returns the error code returned by the libcryptsetup function.
+func (e *CryptError) Code() int { return e.code }
+
+// errorFromResult converts a [Result] back into an error.
+func errorFromResult(r Result) error {
+	switch {
+	case r.Err == "":
+		return nil
+	case r.Code != 0:
+		return &CryptError{code: r.Code, message: r.Err}
+	default:
+		return errors.New(r.Err)
+	}
+}
+
+// luks2 returns deviceType as a LUKS2 device type. LUKS2 is the only device
+// type the driver uses, and so it is the only one the helper supports.
+func luks2(deviceType cryptsetup.DeviceType) (cryptsetup.LUKS2, error) {
+	switch v := deviceType.(type) {
+	case cryptsetup.LUKS2:
+		return v, nil
+	case *cryptsetup.LUKS2:
+		return *v, nil
+	}
+	return cryptsetup.LUKS2{}, fmt.Errorf("unsupported device type %T", deviceType)
+}
+
+type cryptSetup struct {
+	client *Client
+}
+
+var _ cryptsetupclient.CryptSetupClient = &cryptSetup{}
+
+func (c *cryptSetup) Init(devicePath string) (cryptsetupclient.Device, error) {
+	return c.init(devicePath, false)
+}
+
+func (c *cryptSetup) InitByName(name string) (cryptsetupclient.Device, error) {
+	return c.init(name, true)
+}
+
+func (c *cryptSetup) init(path string, byName bool) (cryptsetupclient.Device, error) {
+	var reply HandleReply
+	if err := c.client.call(context.Background(), cryptServiceName+".Init", &InitArgs{Path: path, ByName: byName}, &reply); err != nil {
+		return nil, err
+	}
+	if err := errorFromResult(reply.Result); err != nil {
+		return nil, err
+	}
+	return &device{client: c.client, handle: HandleArgs{Handle: reply.Handle}}, nil
+}
+
+// device is a crypt device opened on the helper.
+type device struct {
+	client *Client
+	handle HandleArgs
+}
+
+var _ cryptsetupclient.Device = &device{}
+
+func (d *device) call(method string, args any) error {
+	var reply Result
+	if err := d.client.call(context.Background(), cryptServiceName+"."+method, args, &reply); err != nil {
+		return err
+	}
+	return errorFromResult(reply)
+}
+
+func (d *device) value(method string) (ValueReply, error) {
+	var reply ValueReply
+	err := d.client.call(context.Background(), cryptServiceName+"."+method, &d.handle, &reply)
+	return reply, err
+}
+
+func (d *device) Format(deviceType cryptsetup.DeviceType, params cryptsetup.GenericParams) error {
+	luks, err := luks2(deviceType)
+	if err != nil {
+		return err
+	}
+	return d.call("Format", &FormatArgs{HandleArgs: d.handle, Type: luks, Params: params})
+}
+
+func (d *device) Load(deviceType cryptsetup.DeviceType) error {
+	luks, err := luks2(deviceType)
+	if err != nil {
+		return err
+	}
+	return d.call("Load", &LoadArgs{HandleArgs: d.handle, Type: luks})
+}
+
+func (d *device) KeyslotAddByVolumeKey(keyslot int, volumeKey, passphrase string) error {
+	return d.call("KeyslotAddByVolumeKey", &KeyslotArgs{
+		HandleArgs: d.handle,
+		Keyslot:    keyslot,
+		VolumeKey:  volumeKey,
+		Passphrase: passphrase,
+	})
+}
+
+func (d *device) KeyslotChangeByPassphrase(currentKeyslot, newKeyslot int, currentPassphrase, newPassphrase string) error {
+	return d.call("KeyslotChangeByPassphrase", &KeyslotChangeArgs{
+		HandleArgs:        d.handle,
+		CurrentKeyslot:    currentKeyslot,
+		NewKeyslot:        newKeyslot,
+		CurrentPassphrase: currentPassphrase,
+		NewPassphrase:     newPassphrase,
+	})
+}
+
+func (d *device) ActivateByVolumeKey(deviceName, volumeKey string, volumeKeySize, flags int) error {
+	return d.call("ActivateByVolumeKey", &ActivateArgs{
+		HandleArgs:    d.handle,
+		DeviceName:    deviceName,
+		VolumeKey:     volumeKey,
+		VolumeKeySize: volumeKeySize,
+		Flags:         flags,
+	})
+}
+
+func (d *device) ActivateByPassphrase(deviceName string, keyslot int, passphrase string, flags int) error {
+	return d.call("ActivateByPassphrase", &ActivateArgs{
+		HandleArgs: d.handle,
+		DeviceName: deviceName,
+		Keyslot:    keyslot,
+		Passphrase: passphrase,
+		Flags:      flags,
+	})
+}
+
+func (d *device) VolumeKeyGet(keyslot int, passphrase string) ([]byte, int, error) {
+	var reply VolumeKeyReply
+	if err := d.client.call(context.Background(), cryptServiceName+".VolumeKeyGet", &KeyslotArgs{
+		HandleArgs: d.handle,
+		Keyslot:    keyslot,
+		Passphrase: passphrase,
+	}, &reply); err != nil {
+		return nil, 0, err
+	}
+	return reply.VolumeKey, reply.Keyslot, errorFromResult(reply.Result)
+}
+
+func (d *device) Deactivate(name string) error {
+	return d.call("Deactivate", &DeactivateArgs{HandleArgs: d.handle, Name: name})
+}
+
+func (d *device) Dump() int {
+	reply, err := d.value("Dump")
+	if err != nil {
+		return -1
+	}
+	return reply.Int
+}
+
+func (d *device) Type() string {
+	reply, _ := d.value("Type")
+	return reply.String
+}
+
+func (d *device) Free() bool {
+	reply, err := d.value("Free")
+	return err == nil && reply.Bool
+}
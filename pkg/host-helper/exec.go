@@ -0,0 +1,125 @@
+package hosthelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	utilexec "k8s.io/utils/exec"
+)
+
+// errUnsupported is returned by [utilexec.Cmd] methods that cannot be
+// forwarded to the helper, such as streaming pipes.
+var errUnsupported = errors.New("not supported through the host helper")
+
+type executor struct {
+	client *Client
+}
+
+var _ utilexec.Interface = &executor{}
+
+func (e *executor) Command(cmd string, args ...string) utilexec.Cmd {
+	return e.CommandContext(context.Background(), cmd, args...)
+}
+
+func (e *executor) CommandContext(ctx context.Context, cmd string, args ...string) utilexec.Cmd {
+	return &command{
+		ctx:    ctx,
+		client: e.client,
+		args:   CommandArgs{Name: cmd, Args: args},
+	}
+}
+
+func (e *executor) LookPath(file string) (string, error) {
+	var reply LookPathReply
+	if err := e.client.call(context.Background(), execServiceName+".LookPath", &LookPathArgs{File: file}, &reply); err != nil {
+		return "", err
+	}
+	if reply.Err != "" {
+		return "", fmt.Errorf("%s: %w", reply.Err, utilexec.ErrExecutableNotFound)
+	}
+	return reply.Path, nil
+}
+
+// command is a [utilexec.Cmd] that runs to completion on the helper.
+type command struct {
+	ctx    context.Context
+	client *Client
+	args   CommandArgs
+
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+var _ utilexec.Cmd = &command{}
+
+func (c *command) run() (*CommandReply, error) {
+	if c.stdin != nil {
+		stdin, err := io.ReadAll(c.stdin)
+		if err != nil {
+			return nil, err
+		}
+		c.args.Stdin = stdin
+	}
+
+	var reply CommandReply
+	if err := c.client.call(c.ctx, execServiceName+".Run", &c.args, &reply); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case reply.Exited:
+		return &reply, utilexec.CodeExitError{Err: errors.New(reply.Err), Code: reply.Code}
+	case reply.Err != "":
+		return &reply, errors.New(reply.Err)
+	}
+	return &reply, nil
+}
+
+func (c *command) Run() error {
+	reply, err := c.run()
+	if reply == nil {
+		return err
+	}
+	if c.stdout != nil {
+		if _, werr := c.stdout.Write(reply.Stdout); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	if c.stderr != nil {
+		if _, werr := c.stderr.Write(reply.Stderr); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return err
+}
+
+func (c *command) CombinedOutput() ([]byte, error) {
+	reply, err := c.run()
+	if reply == nil {
+		return nil, err
+	}
+	return reply.Combined, err
+}
+
+func (c *command) Output() ([]byte, error) {
+	reply, err := c.run()
+	if reply == nil {
+		return nil, err
+	}
+	return reply.Stdout, err
+}
+
+func (c *command) SetDir(dir string)       { c.args.Dir = dir }
+func (c *command) SetStdin(in io.Reader)   { c.stdin = in }
+func (c *command) SetStdout(out io.Writer) { c.stdout = out }
+func (c *command) SetStderr(out io.Writer) { c.stderr = out }
+func (c *command) SetEnv(env []string)     { c.args.Env = env }
+
+func (c *command) StdoutPipe() (io.ReadCloser, error) { return nil, errUnsupported }
+func (c *command) StderrPipe() (io.ReadCloser, error) { return nil, errUnsupported }
+func (c *command) Start() error                       { return errUnsupported }
+func (c *command) Wait() error                        { return errUnsupported }
+func (c *command) Stop()                              {}
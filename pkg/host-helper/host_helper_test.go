@@ -0,0 +1,401 @@
+package hosthelper
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/martinjungblut/go-cryptsetup"
+	"go.uber.org/mock/gomock"
+	"k8s.io/mount-utils"
+	utilexec "k8s.io/utils/exec"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+	cryptsetupclient "github.com/linode/linode-blockstorage-csi-driver/pkg/cryptsetup-client"
+)
+
+// startServer serves server on a temporary socket and returns a client
+// connected to it.
+func startServer(t *testing.T, server *Server) *Client {
+	t.Helper()
+
+	socketPath := filepath.Join(t.TempDir(), "helper.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- server.Serve(listener) }()
+
+	client := NewClient(socketPath)
+	t.Cleanup(func() {
+		if err := client.Close(); err != nil {
+			t.Errorf("close client: %v", err)
+		}
+		if err := listener.Close(); err != nil {
+			t.Errorf("close listener: %v", err)
+		}
+		if err := <-done; err != nil {
+			t.Errorf("serve: %v", err)
+		}
+	})
+	return client
+}
+
+// newTestServer returns a server of kubeletDir with devices in a temporary
+// directory: the disk of the instance sda, the Linode volume sdc, and the
+// mappings "test", a LUKS mapping of sdc, "detached", a LUKS mapping of a
+// detached volume, "cryptroot", a LUKS mapping of sda1, and "vg-root", an
+// LVM volume of sda1. It returns the server and the directory of the
+// devices.
+func newTestServer(t *testing.T, mounter mount.Interface, crypt cryptsetupclient.CryptSetupClient, allowedCommands []string, kubeletDir string) (*Server, string) {
+	t.Helper()
+
+	devDir, sysBlockDir := t.TempDir(), t.TempDir()
+	for _, dir := range []string{"disk/by-id", "mapper"} {
+		if err := os.MkdirAll(filepath.Join(devDir, dir), 0o750); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+	for _, device := range []string{"sda", "sda1", "sdc", "dm-0", "dm-1", "dm-2", "dm-3"} {
+		if err := os.WriteFile(filepath.Join(devDir, device), nil, 0o600); err != nil {
+			t.Fatalf("create device: %v", err)
+		}
+	}
+	for link, device := range map[string]string{
+		"disk/by-id/scsi-0QEMU_QEMU_HARDDISK_drive-scsi-disk-0":       "sda",
+		"disk/by-id/scsi-0QEMU_QEMU_HARDDISK_drive-scsi-disk-0-part1": "sda1",
+		"disk/by-id/scsi-0Linode_Volume_test":                         "sdc",
+		"disk/by-id/dm-name-test":                                     "dm-0",
+		"mapper/test":                                                 "dm-0",
+		"mapper/cryptroot":                                            "dm-1",
+		"mapper/vg-root":                                              "dm-2",
+		"mapper/detached":                                             "dm-3",
+	} {
+		if err := os.Symlink(filepath.Join(devDir, device), filepath.Join(devDir, link)); err != nil {
+			t.Fatalf("symlink: %v", err)
+		}
+	}
+	for device, mapping := range map[string]struct{ uuid, slave string }{
+		"dm-0": {"CRYPT-LUKS2-0001-test", "sdc"},
+		"dm-1": {"CRYPT-LUKS2-0002-cryptroot", "sda1"},
+		"dm-2": {"LVM-0003", "sda1"},
+		"dm-3": {"CRYPT-LUKS2-0004-detached", "sdd"},
+	} {
+		dir := filepath.Join(sysBlockDir, device)
+		for _, sub := range []string{"dm", "slaves/" + mapping.slave} {
+			if err := os.MkdirAll(filepath.Join(dir, sub), 0o750); err != nil {
+				t.Fatalf("mkdir: %v", err)
+			}
+		}
+		if err := os.WriteFile(filepath.Join(dir, "dm", "uuid"), []byte(mapping.uuid+"\n"), 0o600); err != nil {
+			t.Fatalf("write uuid: %v", err)
+		}
+	}
+
+	server := NewServer(mounter, utilexec.New(), crypt, allowedCommands, kubeletDir)
+	server.devDir, server.sysBlockDir = devDir, sysBlockDir
+	return server, devDir
+}
+
+func TestMounter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	kubeletDir := t.TempDir()
+	stagingPath := filepath.Join(kubeletDir, "staging")
+	targetPath := filepath.Join(kubeletDir, "target")
+	for _, dir := range []string{stagingPath, targetPath} {
+		if err := os.Mkdir(dir, 0o750); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+	escapePath := filepath.Join(kubeletDir, "escape")
+	if err := os.Symlink(t.TempDir(), escapePath); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+
+	mockMounter := mocks.NewMockMounter(ctrl)
+	server, devDir := newTestServer(t, mockMounter, nil, nil, kubeletDir)
+	client := startServer(t, server)
+	volume := filepath.Join(devDir, "disk/by-id/scsi-0Linode_Volume_test")
+
+	for _, source := range []string{volume, filepath.Join(devDir, "mapper/test"), filepath.Join(devDir, "mapper/detached")} {
+		mockMounter.EXPECT().MountSensitive(source, stagingPath, "ext4", []string{"defaults"}, []string(nil)).Return(nil)
+		if err := client.Mounter().Mount(source, stagingPath, "ext4", []string{"defaults"}); err != nil {
+			t.Errorf("Mount(%q) error = %v", source, err)
+		}
+	}
+	mockMounter.EXPECT().MountSensitive(stagingPath, targetPath, "ext4", []string{"bind"}, []string(nil)).Return(nil)
+	if err := client.Mounter().Mount(stagingPath, targetPath, "ext4", []string{"bind"}); err != nil {
+		t.Errorf("Mount() bind error = %v", err)
+	}
+
+	mockMounter.EXPECT().Unmount(targetPath).Return(errors.New("target is busy"))
+	err := client.Mounter().Unmount(targetPath)
+	if err == nil || !strings.Contains(err.Error(), "target is busy") {
+		t.Errorf("Unmount() error = %v, want error containing %q", err, "target is busy")
+	}
+
+	notAllowed := []struct {
+		name   string
+		source string
+		target string
+	}{
+		{name: "Target outside of the kubelet directory", source: volume, target: "/etc"},
+		{name: "Target escaping through a symlink", source: volume, target: escapePath},
+		{name: "Target escaping with dots", source: volume, target: kubeletDir + "/../etc"},
+		{name: "Relative target", source: volume, target: "target"},
+		{name: "Source not a volume device", source: filepath.Join(devDir, "sda"), target: stagingPath},
+		{name: "Source escaping with dots", source: filepath.Join(devDir, "disk/by-id/../../sda"), target: stagingPath},
+		{name: "Source a disk of the instance", source: filepath.Join(devDir, "disk/by-id/scsi-0QEMU_QEMU_HARDDISK_drive-scsi-disk-0-part1"), target: stagingPath},
+		{name: "Source a missing volume", source: filepath.Join(devDir, "disk/by-id/scsi-0Linode_Volume_missing"), target: stagingPath},
+		{name: "Source a LUKS mapping of a disk of the instance", source: filepath.Join(devDir, "mapper/cryptroot"), target: stagingPath},
+		{name: "Source an LVM volume", source: filepath.Join(devDir, "mapper/vg-root"), target: stagingPath},
+		{name: "Bind source outside of the kubelet directory", source: "/etc", target: targetPath},
+	}
+	for _, tt := range notAllowed {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := client.Mounter().Mount(tt.source, tt.target, "ext4", nil); err == nil || !strings.Contains(err.Error(), errPathNotAllowed.Error()) {
+				t.Errorf("Mount() error = %v, want %v", err, errPathNotAllowed)
+			}
+		})
+	}
+	if err := client.Mounter().Unmount("/etc"); err == nil || !strings.Contains(err.Error(), errPathNotAllowed.Error()) {
+		t.Errorf("Unmount() error = %v, want %v", err, errPathNotAllowed)
+	}
+}
+
+func TestExecutor(t *testing.T) {
+	kubeletDir := t.TempDir()
+	server, devDir := newTestServer(t, nil, nil, []string{"echo", "sh"}, kubeletDir)
+	client := startServer(t, server)
+	executor := client.Executor()
+	volume := filepath.Join(devDir, "disk/by-id/scsi-0Linode_Volume_test")
+
+	tests := []struct {
+		name         string
+		cmd          string
+		args         []string
+		env          []string
+		expectedOut  string
+		expectedCode int
+		expectErr    bool
+	}{
+		{
+			name:        "Allowed command",
+			cmd:         "echo",
+			args:        []string{"hello"},
+			expectedOut: "hello\n",
+		},
+		{
+			name:         "Non-zero exit code",
+			cmd:          "sh",
+			args:         []string{"-c", "echo failed >&2; exit 2"},
+			expectedOut:  "failed\n",
+			expectedCode: 2,
+			expectErr:    true,
+		},
+		{
+			name:      "Command not allowed",
+			cmd:       "true",
+			expectErr: true,
+		},
+		{
+			name:        "Client environment ignored",
+			cmd:         "sh",
+			args:        []string{"-c", "echo \"$LD_PRELOAD\""},
+			env:         []string{"LD_PRELOAD=/tmp/evil.so"},
+			expectedOut: "\n",
+		},
+		{
+			name:        "Run in the kubelet directory",
+			cmd:         "sh",
+			args:        []string{"-c", "pwd"},
+			expectedOut: kubeletDir + "\n",
+		},
+		{
+			name:        "Volume device argument",
+			cmd:         "echo",
+			args:        []string{"-n", volume},
+			expectedOut: volume,
+		},
+		{
+			name:      "Disk of the instance argument",
+			cmd:       "echo",
+			args:      []string{filepath.Join(devDir, "disk/by-id/scsi-0QEMU_QEMU_HARDDISK_drive-scsi-disk-0")},
+			expectErr: true,
+		},
+		{
+			name:      "Path argument outside of the kubelet directory",
+			cmd:       "echo",
+			args:      []string{"/etc/shadow"},
+			expectErr: true,
+		},
+		{
+			name:      "Relative path argument",
+			cmd:       "echo",
+			args:      []string{"../etc"},
+			expectErr: true,
+		},
+		{
+			name:      "Parent of the kubelet directory argument",
+			cmd:       "echo",
+			args:      []string{".."},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := executor.Command(tt.cmd, tt.args...)
+			if tt.env != nil {
+				cmd.SetEnv(tt.env)
+			}
+			out, err := cmd.CombinedOutput()
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("CombinedOutput() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if string(out) != tt.expectedOut {
+				t.Errorf("CombinedOutput() = %q, want %q", out, tt.expectedOut)
+			}
+
+			var exitErr utilexec.ExitError
+			if tt.expectedCode != 0 {
+				if !errors.As(err, &exitErr) || exitErr.ExitStatus() != tt.expectedCode {
+					t.Errorf("CombinedOutput() error = %v, want exit code %d", err, tt.expectedCode)
+				}
+			} else if errors.As(err, &exitErr) {
+				t.Errorf("CombinedOutput() error = %v, want no exit error", err)
+			}
+		})
+	}
+}
+
+func TestCryptSetup(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockCrypt := mocks.NewMockCryptSetupClient(ctrl)
+	mockDevice := mocks.NewMockDevice(ctrl)
+	server, devDir := newTestServer(t, nil, mockCrypt, nil, t.TempDir())
+	client := startServer(t, server)
+	volume := filepath.Join(devDir, "disk/by-id/scsi-0Linode_Volume_test")
+
+	mockCrypt.EXPECT().Init(volume).Return(mockDevice, nil)
+	mockDevice.EXPECT().Load(cryptsetup.LUKS2{SectorSize: 512}).Return(&CryptError{code: -16, message: "device busy"})
+	mockDevice.EXPECT().Type().Return("LUKS2")
+	mockDevice.EXPECT().Free().Return(true)
+
+	dev, err := client.CryptSetup().Init(volume)
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	err = dev.Load(&cryptsetup.LUKS2{SectorSize: 512})
+	var coder interface{ Code() int }
+	if !errors.As(err, &coder) || coder.Code() != -16 {
+		t.Errorf("Load() error = %v, want an error with code -16", err)
+	}
+
+	if got := dev.Type(); got != "LUKS2" {
+		t.Errorf("Type() = %q, want %q", got, "LUKS2")
+	}
+	if !dev.Free() {
+		t.Error("Free() = false, want true")
+	}
+	if err := dev.Deactivate("volume"); err == nil {
+		t.Error("Deactivate() on a freed device succeeded, want error")
+	}
+
+	mockByName := mocks.NewMockDevice(ctrl)
+	mockCrypt.EXPECT().InitByName("test").Return(mockByName, nil)
+	mockByName.EXPECT().Free().Return(true)
+	byName, err := client.CryptSetup().InitByName("test")
+	if err != nil {
+		t.Fatalf("InitByName() error = %v", err)
+	}
+	byName.Free()
+
+	for _, path := range []string{filepath.Join(devDir, "sda"), filepath.Join(devDir, "disk/by-id/scsi-0QEMU_QEMU_HARDDISK_drive-scsi-disk-0"), "/etc/shadow"} {
+		if _, err := client.CryptSetup().Init(path); err == nil || !strings.Contains(err.Error(), errPathNotAllowed.Error()) {
+			t.Errorf("Init(%q) error = %v, want %v", path, err, errPathNotAllowed)
+		}
+	}
+	for _, name := range []string{"cryptroot", "vg-root", "../disk/by-id/scsi-0Linode_Volume_test"} {
+		if _, err := client.CryptSetup().InitByName(name); err == nil || !strings.Contains(err.Error(), errPathNotAllowed.Error()) {
+			t.Errorf("InitByName(%q) error = %v, want %v", name, err, errPathNotAllowed)
+		}
+	}
+}
+
+func TestCheckArgs(t *testing.T) {
+	server, devDir := newTestServer(t, nil, nil, nil, t.TempDir())
+	mountPath := filepath.Join(server.kubeletDir, "staging")
+	if err := os.Mkdir(mountPath, 0o750); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		cmd     string
+		args    []string
+		wantErr bool
+	}{
+		{name: "udevadm settle", cmd: "udevadm", args: []string{"settle"}},
+		{name: "udevadm trigger of a volume", cmd: "udevadm", args: []string{"trigger", "--action=change", "--property-match=DEVNAME=" + filepath.Join(devDir, "sdc")}},
+		{name: "udevadm trigger of a disk of the instance", cmd: "udevadm", args: []string{"trigger", "--action=change", "--property-match=DEVNAME=" + filepath.Join(devDir, "sda")}, wantErr: true},
+		{name: "udevadm trigger of all the devices", cmd: "udevadm", args: []string{"trigger", "--action=change"}, wantErr: true},
+		{name: "udevadm other subcommand", cmd: "udevadm", args: []string{"control", "--reload"}, wantErr: true},
+		{name: "xfs_io statfs", cmd: "xfs_io", args: []string{"-c", "statfs", mountPath}},
+		{name: "xfs_io statfs outside of the kubelet directory", cmd: "xfs_io", args: []string{"-c", "statfs", "/"}, wantErr: true},
+		{name: "xfs_io other subcommand", cmd: "xfs_io", args: []string{"-c", "pwrite 0 4096", mountPath}, wantErr: true},
+		{name: "mkfs of a volume", cmd: "mkfs.ext4", args: []string{"-F", "-m0", filepath.Join(devDir, "disk/by-id/scsi-0Linode_Volume_test")}},
+		{name: "mkfs of a disk of the instance", cmd: "mkfs.ext4", args: []string{"-F", filepath.Join(devDir, "disk/by-id/scsi-0QEMU_QEMU_HARDDISK_drive-scsi-disk-0")}, wantErr: true},
+		{name: "mkfs populated from a directory", cmd: "mkfs.ext4", args: []string{"-d", "/etc", filepath.Join(devDir, "disk/by-id/scsi-0Linode_Volume_test")}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := server.checkArgs(tt.cmd, tt.args); (err != nil) != tt.wantErr {
+				t.Errorf("checkArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCryptSetupFreedOnDisconnect(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockCrypt := mocks.NewMockCryptSetupClient(ctrl)
+	mockDevice := mocks.NewMockDevice(ctrl)
+	server, devDir := newTestServer(t, nil, mockCrypt, nil, t.TempDir())
+	client := startServer(t, server)
+	volume := filepath.Join(devDir, "disk/by-id/scsi-0Linode_Volume_test")
+
+	freed := make(chan struct{})
+	mockCrypt.EXPECT().Init(volume).Return(mockDevice, nil)
+	mockDevice.EXPECT().Free().DoAndReturn(func() bool {
+		close(freed)
+		return true
+	})
+
+	if _, err := client.CryptSetup().Init(volume); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	select {
+	case <-freed:
+	case <-time.After(5 * time.Second):
+		t.Error("device left open by the client was not freed")
+	}
+}
@@ -0,0 +1,172 @@
+package hosthelper
+
+import (
+	"github.com/martinjungblut/go-cryptsetup"
+)
+
+// Names of the RPC services exposed by the host helper.
+const (
+	mountServiceName = "Mount"
+	execServiceName  = "Exec"
+	cryptServiceName = "CryptSetup"
+)
+
+// Empty is used for RPC arguments and replies that carry no data.
+type Empty struct{}
+
+// Result is embedded in replies that need to carry an error back to the
+// caller without losing its type information.
+//
+// net/rpc only transports errors as strings, so errors that callers need to
+// inspect (e.g. libcryptsetup return codes) are encoded here instead of being
+// returned from the RPC method.
+type Result struct {
+	Err  string
+	Code int
+}
+
+// MountArgs are the arguments for the Mount.Mount RPC.
+type MountArgs struct {
+	Source           string
+	Target           string
+	FSType           string
+	Options          []string
+	SensitiveOptions []string
+	MountFlags       []string
+	WithoutSystemd   bool
+}
+
+// UnmountArgs are the arguments for the Mount.Unmount RPC.
+type UnmountArgs struct {
+	Target string
+}
+
+// CommandArgs are the arguments for the Exec.Run RPC.
+type CommandArgs struct {
+	Name string
+	Args []string
+
+	// Dir and Env are ignored by the helper, which runs commands in its own
+	// working directory and environment.
+	Dir string
+	Env []string
+
+	Stdin []byte
+}
+
+// CommandReply is the reply for the Exec.Run RPC.
+type CommandReply struct {
+	Result
+
+	Stdout   []byte
+	Stderr   []byte
+	Combined []byte
+
+	// Exited is true if the command ran and exited with a non-zero exit
+	// code, in which case Code holds the exit code.
+	Exited bool
+}
+
+// LookPathArgs are the arguments for the Exec.LookPath RPC.
+type LookPathArgs struct {
+	File string
+}
+
+// LookPathReply is the reply for the Exec.LookPath RPC.
+type LookPathReply struct {
+	Result
+
+	Path string
+}
+
+// InitArgs are the arguments for the CryptSetup.Init RPC.
+type InitArgs struct {
+	// Path is either a device path, or the name of an active crypt device
+	// when ByName is true.
+	Path   string
+	ByName bool
+}
+
+// HandleArgs identify a crypt device previously opened on the helper.
+type HandleArgs struct {
+	Handle uint64
+}
+
+// HandleReply is returned by RPCs that open a crypt device.
+type HandleReply struct {
+	Result
+
+	Handle uint64
+}
+
+// FormatArgs are the arguments for the CryptSetup.Format RPC.
+type FormatArgs struct {
+	HandleArgs
+
+	Type   cryptsetup.LUKS2
+	Params cryptsetup.GenericParams
+}
+
+// LoadArgs are the arguments for the CryptSetup.Load RPC.
+type LoadArgs struct {
+	HandleArgs
+
+	Type cryptsetup.LUKS2
+}
+
+// KeyslotArgs are the arguments for the CryptSetup.KeyslotAddByVolumeKey RPC.
+type KeyslotArgs struct {
+	HandleArgs
+
+	Keyslot    int
+	VolumeKey  string
+	Passphrase string
+}
+
+// KeyslotChangeArgs are the arguments for the
+// CryptSetup.KeyslotChangeByPassphrase RPC.
+type KeyslotChangeArgs struct {
+	HandleArgs
+
+	CurrentKeyslot    int
+	NewKeyslot        int
+	CurrentPassphrase string
+	NewPassphrase     string
+}
+
+// ActivateArgs are the arguments for the CryptSetup.ActivateByPassphrase and
+// CryptSetup.ActivateByVolumeKey RPCs.
+type ActivateArgs struct {
+	HandleArgs
+
+	DeviceName    string
+	Keyslot       int
+	Passphrase    string
+	VolumeKey     string
+	VolumeKeySize int
+	Flags         int
+}
+
+// VolumeKeyReply is the reply for the CryptSetup.VolumeKeyGet RPC.
+type VolumeKeyReply struct {
+	Result
+
+	VolumeKey []byte
+	Keyslot   int
+}
+
+// DeactivateArgs are the arguments for the CryptSetup.Deactivate RPC.
+type DeactivateArgs struct {
+	HandleArgs
+
+	Name string
+}
+
+// ValueReply is returned by RPCs that return a single scalar value.
+type ValueReply struct {
+	Result
+
+	Bool   bool
+	Int    int
+	String string
+}
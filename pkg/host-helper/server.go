@@ -0,0 +1,616 @@
+/*
+Package hosthelper implements a minimal privileged helper that performs the
+node plugin's host-level operations (mount, unmount, mkfs and friends, and
+cryptsetup) on its behalf.
+
+The helper listens on a local unix socket. When the node plugin is configured
+to use it, the plugin itself no longer needs CAP_SYS_ADMIN: every operation
+that requires elevated privileges is forwarded to the helper, which only
+exposes a fixed set of operations and an allowlist of executables. Volumes
+can only be mounted from their devices, or bind mounted from the kubelet
+directory, to a path in the kubelet directory, and the path arguments of the
+executables and crypt devices are held to the same rules.
+*/
+package hosthelper
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"k8s.io/klog/v2"
+	"k8s.io/mount-utils"
+	utilexec "k8s.io/utils/exec"
+
+	cryptsetupclient "github.com/linode/linode-blockstorage-csi-driver/pkg/cryptsetup-client"
+)
+
+// DefaultAllowedCommands is the list of executables the helper will run on
+// behalf of the node plugin. It covers everything used by
+// [mount.SafeFormatAndMount], [mount.ResizeFs] and the driver itself. udevadm
+// and xfs_io only run the subcommands they are used for, see
+// [Server.checkArgs].
+var DefaultAllowedCommands = []string{
+	"blkid",
+	"blockdev",
+	"chattr",
+	"dumpe2fs",
+	"e2fsck",
+	"fsck",
+	"mkfs.ext3",
+	"mkfs.ext4",
+	"mkfs.xfs",
+	"resize2fs",
+	"setquota",
+	"tune2fs",
+	"udevadm",
+	"xfs_growfs",
+	"xfs_io",
+	"xfs_repair",
+}
+
+// DefaultKubeletDir is the root directory of the kubelet, under which all
+// the staging and target paths of volumes are.
+const DefaultKubeletDir = "/var/lib/kubelet"
+
+const (
+	// defaultDevDir and defaultSysBlockDir are where the helper looks up
+	// the devices of the volumes, and the devices underlying their LUKS
+	// mappings.
+	defaultDevDir      = "/dev"
+	defaultSysBlockDir = "/sys/block"
+
+	// volumeLinkPrefix starts the names of the /dev/disk/by-id links of
+	// the attached Linode volumes, and of their partitions.
+	volumeLinkPrefix = "scsi-0Linode_Volume_"
+
+	// luksUUIDPrefix starts the device-mapper UUIDs of the LUKS mappings.
+	luksUUIDPrefix = "CRYPT-"
+)
+
+// errCommandNotAllowed is returned when the node plugin asks the helper to
+// run an executable that is not in its allowlist.
+var errCommandNotAllowed = errors.New("command not allowed")
+
+// errPathNotAllowed is returned when the node plugin asks the helper to
+// mount from or to a path outside of the device and kubelet directories.
+var errPathNotAllowed = errors.New("path not allowed")
+
+// Server serves host-level operations over RPC.
+type Server struct {
+	mounter mount.Interface
+	exec    utilexec.Interface
+	crypt   cryptsetupclient.CryptSetupClient
+	allowed map[string]struct{}
+
+	// kubeletDir is the directory volumes are mounted to.
+	kubeletDir string
+
+	// devDir and sysBlockDir are [defaultDevDir] and [defaultSysBlockDir]
+	// outside of tests.
+	devDir      string
+	sysBlockDir string
+}
+
+// NewServer returns a Server that performs mounts under kubeletDir with
+// mounter, runs the allowed commands with exec and manages encrypted devices
+// with crypt.
+func NewServer(mounter mount.Interface, exec utilexec.Interface, crypt cryptsetupclient.CryptSetupClient, allowedCommands []string, kubeletDir string) *Server {
+	allowed := make(map[string]struct{}, len(allowedCommands))
+	for _, c := range allowedCommands {
+		allowed[c] = struct{}{}
+	}
+
+	return &Server{
+		mounter:     mounter,
+		exec:        exec,
+		crypt:       crypt,
+		allowed:     allowed,
+		kubeletDir:  kubeletDir,
+		devDir:      defaultDevDir,
+		sysBlockDir: defaultSysBlockDir,
+	}
+}
+
+// Serve accepts connections on listener and serves requests until the
+// listener is closed.
+func (s *Server) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("accept: %w", err)
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// serveConn serves the requests received on conn until it is closed. The
+// handles of crypt devices are only valid on the connection they were opened
+// on, and the devices the client did not free are freed when it disconnects.
+func (s *Server) serveConn(conn net.Conn) {
+	crypt := &cryptService{s: s, devices: make(map[uint64]cryptsetupclient.Device)}
+	defer crypt.freeAll()
+
+	server := rpc.NewServer()
+	for name, service := range map[string]any{
+		mountServiceName: &mountService{s},
+		execServiceName:  &execService{s},
+		cryptServiceName: crypt,
+	} {
+		if err := server.RegisterName(name, service); err != nil {
+			klog.Errorf("Registering %s service: %v", name, err)
+			if err := conn.Close(); err != nil {
+				klog.V(4).Infof("Closing connection: %v", err)
+			}
+			return
+		}
+	}
+	server.ServeConn(conn)
+}
+
+// checkTarget returns an error unless target is in the kubelet directory,
+// once its symbolic links are resolved.
+func (s *Server) checkTarget(target string) error {
+	resolved, err := resolvePath(target)
+	if err != nil {
+		return fmt.Errorf("%w: %q: %w", errPathNotAllowed, target, err)
+	}
+	kubeletDir, err := filepath.EvalSymlinks(s.kubeletDir)
+	if err != nil {
+		return fmt.Errorf("resolve kubelet directory: %w", err)
+	}
+	if !inDir(resolved, kubeletDir) {
+		return fmt.Errorf("%w: %q is not in %s", errPathNotAllowed, target, s.kubeletDir)
+	}
+	return nil
+}
+
+// checkSource returns an error unless source is the device of a volume, as
+// [Server.volumeDevice] reports, or a path in the kubelet directory to bind
+// mount, once its symbolic links are resolved.
+func (s *Server) checkSource(source string) error {
+	if filepath.IsAbs(source) && inDir(filepath.Clean(source), s.devDir) && s.volumeDevice(source) {
+		return nil
+	}
+	if err := s.checkTarget(source); err != nil {
+		return fmt.Errorf("%w: %q is neither a volume device nor in %s", errPathNotAllowed, source, s.kubeletDir)
+	}
+	return nil
+}
+
+// volumeDevice reports whether device resolves to an attached Linode volume,
+// or one of its partitions, or to the LUKS mapping of one. The disks of the
+// instance, which /dev/disk/by-id also lists, are not volumes, and neither
+// are the mappings of other kinds or on top of them.
+func (s *Server) volumeDevice(device string) bool {
+	resolved, err := filepath.EvalSymlinks(device)
+	if err != nil {
+		return false
+	}
+	volumes, disks := s.blockDevices()
+	if disks[resolved] {
+		return false
+	}
+	if volumes[resolved] {
+		return true
+	}
+
+	// LUKS mappings of devices that are gone, e.g. volumes detached before
+	// they were unstaged, are volumes too, so that they can be closed
+	sysDir := filepath.Join(s.sysBlockDir, filepath.Base(resolved))
+	uuid, err := os.ReadFile(filepath.Join(sysDir, "dm", "uuid"))
+	if err != nil || !strings.HasPrefix(string(uuid), luksUUIDPrefix) {
+		return false
+	}
+	slaves, err := os.ReadDir(filepath.Join(sysDir, "slaves"))
+	if err != nil || len(slaves) == 0 {
+		return false
+	}
+	for _, slave := range slaves {
+		if disks[filepath.Join(filepath.Dir(resolved), slave.Name())] {
+			return false
+		}
+	}
+	return true
+}
+
+// blockDevices returns the devices the /dev/disk/by-id links resolve to: the
+// attached Linode volumes and their partitions, and the other devices, e.g.
+// the disks of the instance, except the device-mapper devices.
+func (s *Server) blockDevices() (volumes, disks map[string]bool) {
+	volumes, disks = make(map[string]bool), make(map[string]bool)
+	byIDDir := filepath.Join(s.devDir, "disk", "by-id")
+	entries, err := os.ReadDir(byIDDir)
+	if err != nil {
+		klog.V(4).Infof("Listing %s: %v", byIDDir, err)
+		return volumes, disks
+	}
+	for _, entry := range entries {
+		resolved, err := filepath.EvalSymlinks(filepath.Join(byIDDir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(entry.Name(), volumeLinkPrefix):
+			volumes[resolved] = true
+		case !strings.HasPrefix(filepath.Base(resolved), "dm-"):
+			disks[resolved] = true
+		}
+	}
+	// A volume is not a disk of the instance, whichever other links it has
+	for volume := range volumes {
+		delete(disks, volume)
+	}
+	return volumes, disks
+}
+
+// checkArgs returns an error unless the arguments of the command name that
+// contain a slash are absolute paths allowed by [Server.checkSource]. The
+// commands run in the kubelet directory, so that their other arguments can
+// only name files in it, but not the directory itself or its parent. udevadm and xfs_io only run the subcommands the
+// node plugin uses, as they can do much more than read or change volumes.
+func (s *Server) checkArgs(name string, args []string) error {
+	switch name {
+	case "udevadm":
+		return s.checkUdevadmArgs(args)
+	case "xfs_io":
+		// [mount.ResizeFs] reads the size of the mounted file system
+		if len(args) != 3 || args[0] != "-c" || args[1] != "statfs" {
+			return fmt.Errorf("%w: %s %v", errCommandNotAllowed, name, args)
+		}
+	}
+	for _, arg := range args {
+		if arg == "." || arg == ".." {
+			return fmt.Errorf("%w: %s %q", errPathNotAllowed, name, arg)
+		}
+		if !strings.Contains(arg, "/") {
+			continue
+		}
+		if err := s.checkSource(arg); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// checkUdevadmArgs returns an error unless args wait for the udev events, or
+// replay the change event of a device that is not a disk of the instance, as
+// the node plugin does for new volumes, whose /dev/disk/by-id links may be
+// missing.
+func (s *Server) checkUdevadmArgs(args []string) error {
+	if len(args) == 1 && args[0] == "settle" {
+		return nil
+	}
+	const devName = "--property-match=DEVNAME="
+	if len(args) != 3 || args[0] != "trigger" || args[1] != "--action=change" || !strings.HasPrefix(args[2], devName) {
+		return fmt.Errorf("%w: udevadm %v", errCommandNotAllowed, args)
+	}
+	device := strings.TrimPrefix(args[2], devName)
+	if !filepath.IsAbs(device) {
+		return fmt.Errorf("%w: %q", errPathNotAllowed, device)
+	}
+	resolved, err := filepath.EvalSymlinks(device)
+	if err != nil {
+		return fmt.Errorf("%w: %q: %w", errPathNotAllowed, device, err)
+	}
+	if _, disks := s.blockDevices(); disks[resolved] || filepath.Dir(resolved) != s.devDir {
+		return fmt.Errorf("%w: %q is not the device of a volume", errPathNotAllowed, device)
+	}
+	return nil
+}
+
+// resolvePath returns the absolute path with the symbolic links of path
+// resolved. If path itself cannot be resolved, e.g. because it is a mount
+// point whose file system is gone, only its parent directory is.
+func resolvePath(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		return "", errors.New("not an absolute path")
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved, nil
+	}
+	parent, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(parent, filepath.Base(path)), nil
+}
+
+// inDir reports whether the clean path is in dir, or one of its
+// subdirectories.
+func inDir(path, dir string) bool {
+	return strings.HasPrefix(path, strings.TrimSuffix(dir, "/")+"/")
+}
+
+// resultFromError converts err into a [Result], keeping the libcryptsetup
+// return code if there is one.
+func resultFromError(err error) Result {
+	if err == nil {
+		return Result{}
+	}
+	result := Result{Err: err.Error()}
+	var coder interface{ Code() int }
+	if errors.As(err, &coder) {
+		result.Code = coder.Code()
+	}
+	return result
+}
+
+type mountService struct {
+	s *Server
+}
+
+func (m *mountService) Mount(args *MountArgs, _ *Empty) error {
+	if err := m.s.checkSource(args.Source); err != nil {
+		return err
+	}
+	if err := m.s.checkTarget(args.Target); err != nil {
+		return err
+	}
+
+	klog.V(4).Infof("Mounting %s at %s (fstype=%q, options=%v)", args.Source, args.Target, args.FSType, args.Options)
+	if args.WithoutSystemd {
+		return m.s.mounter.MountSensitiveWithoutSystemdWithMountFlags(args.Source, args.Target, args.FSType, args.Options, args.SensitiveOptions, args.MountFlags)
+	}
+	return m.s.mounter.MountSensitive(args.Source, args.Target, args.FSType, args.Options, args.SensitiveOptions)
+}
+
+func (m *mountService) Unmount(args *UnmountArgs, _ *Empty) error {
+	if err := m.s.checkTarget(args.Target); err != nil {
+		return err
+	}
+
+	klog.V(4).Infof("Unmounting %s", args.Target)
+	return m.s.mounter.Unmount(args.Target)
+}
+
+type execService struct {
+	s *Server
+}
+
+// lockedWriter serializes writes to w, so stdout and stderr can both be
+// copied into the same combined output buffer.
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (l lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+func (e *execService) Run(args *CommandArgs, reply *CommandReply) error {
+	if _, ok := e.s.allowed[args.Name]; !ok {
+		return fmt.Errorf("%w: %q", errCommandNotAllowed, args.Name)
+	}
+	if err := e.s.checkArgs(args.Name, args.Args); err != nil {
+		return err
+	}
+
+	// The working directory and environment of the client are ignored, as
+	// they could make the allowed commands run arbitrary code (e.g. with
+	// LD_PRELOAD). Commands run with the helper's environment, in the
+	// kubelet directory.
+	klog.V(4).Infof("Running %s %v", args.Name, args.Args)
+	cmd := e.s.exec.Command(args.Name, args.Args...)
+	cmd.SetDir(e.s.kubeletDir)
+	if args.Stdin != nil {
+		cmd.SetStdin(bytes.NewReader(args.Stdin))
+	}
+
+	var stdout, stderr, combined bytes.Buffer
+	var mu sync.Mutex
+	cmd.SetStdout(io.MultiWriter(&stdout, lockedWriter{&mu, &combined}))
+	cmd.SetStderr(io.MultiWriter(&stderr, lockedWriter{&mu, &combined}))
+
+	err := cmd.Run()
+	reply.Stdout = stdout.Bytes()
+	reply.Stderr = stderr.Bytes()
+	reply.Combined = combined.Bytes()
+
+	var exitErr utilexec.ExitError
+	if errors.As(err, &exitErr) && exitErr.Exited() {
+		reply.Exited = true
+		reply.Result = Result{Err: err.Error(), Code: exitErr.ExitStatus()}
+		return nil
+	}
+	reply.Result = resultFromError(err)
+	return nil
+}
+
+func (e *execService) LookPath(args *LookPathArgs, reply *LookPathReply) error {
+	if _, ok := e.s.allowed[args.File]; !ok {
+		return fmt.Errorf("%w: %q", errCommandNotAllowed, args.File)
+	}
+
+	path, err := e.s.exec.LookPath(args.File)
+	reply.Path = path
+	reply.Result = resultFromError(err)
+	return nil
+}
+
+// cryptService manages the crypt devices opened on one connection.
+type cryptService struct {
+	s *Server
+
+	devicesMu  sync.Mutex // protects devices and nextHandle
+	devices    map[uint64]cryptsetupclient.Device
+	nextHandle uint64
+}
+
+func (c *cryptService) device(handle uint64) (cryptsetupclient.Device, error) {
+	c.devicesMu.Lock()
+	defer c.devicesMu.Unlock()
+
+	dev, ok := c.devices[handle]
+	if !ok {
+		return nil, fmt.Errorf("unknown crypt device handle %d", handle)
+	}
+	return dev, nil
+}
+
+func (c *cryptService) Init(args *InitArgs, reply *HandleReply) error {
+	// Crypt devices are opened by path, or by the name of their mapping
+	path := args.Path
+	if args.ByName {
+		if strings.Contains(args.Path, "/") {
+			return fmt.Errorf("%w: invalid mapping name %q", errPathNotAllowed, args.Path)
+		}
+		path = filepath.Join(c.s.devDir, "mapper", args.Path)
+	}
+	if err := c.s.checkSource(path); err != nil {
+		return err
+	}
+
+	var (
+		dev cryptsetupclient.Device
+		err error
+	)
+	if args.ByName {
+		dev, err = c.s.crypt.InitByName(args.Path)
+	} else {
+		dev, err = c.s.crypt.Init(args.Path)
+	}
+	if err != nil {
+		reply.Result = resultFromError(err)
+		return nil
+	}
+
+	c.devicesMu.Lock()
+	defer c.devicesMu.Unlock()
+	c.nextHandle++
+	c.devices[c.nextHandle] = dev
+	reply.Handle = c.nextHandle
+	return nil
+}
+
+func (c *cryptService) Format(args *FormatArgs, reply *Result) error {
+	dev, err := c.device(args.Handle)
+	if err != nil {
+		return err
+	}
+	*reply = resultFromError(dev.Format(args.Type, args.Params))
+	return nil
+}
+
+func (c *cryptService) Load(args *LoadArgs, reply *Result) error {
+	dev, err := c.device(args.Handle)
+	if err != nil {
+		return err
+	}
+	*reply = resultFromError(dev.Load(args.Type))
+	return nil
+}
+
+func (c *cryptService) KeyslotAddByVolumeKey(args *KeyslotArgs, reply *Result) error {
+	dev, err := c.device(args.Handle)
+	if err != nil {
+		return err
+	}
+	*reply = resultFromError(dev.KeyslotAddByVolumeKey(args.Keyslot, args.VolumeKey, args.Passphrase))
+	return nil
+}
+
+func (c *cryptService) KeyslotChangeByPassphrase(args *KeyslotChangeArgs, reply *Result) error {
+	dev, err := c.device(args.Handle)
+	if err != nil {
+		return err
+	}
+	*reply = resultFromError(dev.KeyslotChangeByPassphrase(args.CurrentKeyslot, args.NewKeyslot, args.CurrentPassphrase, args.NewPassphrase))
+	return nil
+}
+
+func (c *cryptService) ActivateByPassphrase(args *ActivateArgs, reply *Result) error {
+	dev, err := c.device(args.Handle)
+	if err != nil {
+		return err
+	}
+	*reply = resultFromError(dev.ActivateByPassphrase(args.DeviceName, args.Keyslot, args.Passphrase, args.Flags))
+	return nil
+}
+
+func (c *cryptService) ActivateByVolumeKey(args *ActivateArgs, reply *Result) error {
+	dev, err := c.device(args.Handle)
+	if err != nil {
+		return err
+	}
+	*reply = resultFromError(dev.ActivateByVolumeKey(args.DeviceName, args.VolumeKey, args.VolumeKeySize, args.Flags))
+	return nil
+}
+
+func (c *cryptService) VolumeKeyGet(args *KeyslotArgs, reply *VolumeKeyReply) error {
+	dev, err := c.device(args.Handle)
+	if err != nil {
+		return err
+	}
+	key, keyslot, err := dev.VolumeKeyGet(args.Keyslot, args.Passphrase)
+	reply.VolumeKey = key
+	reply.Keyslot = keyslot
+	reply.Result = resultFromError(err)
+	return nil
+}
+
+func (c *cryptService) Deactivate(args *DeactivateArgs, reply *Result) error {
+	dev, err := c.device(args.Handle)
+	if err != nil {
+		return err
+	}
+	*reply = resultFromError(dev.Deactivate(args.Name))
+	return nil
+}
+
+func (c *cryptService) Dump(args *HandleArgs, reply *ValueReply) error {
+	dev, err := c.device(args.Handle)
+	if err != nil {
+		return err
+	}
+	reply.Int = dev.Dump()
+	return nil
+}
+
+func (c *cryptService) Type(args *HandleArgs, reply *ValueReply) error {
+	dev, err := c.device(args.Handle)
+	if err != nil {
+		return err
+	}
+	reply.String = dev.Type()
+	return nil
+}
+
+// Free releases the device and forgets its handle.
+func (c *cryptService) Free(args *HandleArgs, reply *ValueReply) error {
+	dev, err := c.device(args.Handle)
+	if err != nil {
+		return err
+	}
+
+	c.devicesMu.Lock()
+	delete(c.devices, args.Handle)
+	c.devicesMu.Unlock()
+
+	reply.Bool = dev.Free()
+	return nil
+}
+
+// freeAll frees the devices that are still open.
+func (c *cryptService) freeAll() {
+	c.devicesMu.Lock()
+	defer c.devicesMu.Unlock()
+
+	for handle, dev := range c.devices {
+		klog.V(4).Infof("Freeing crypt device handle %d left open by the client", handle)
+		dev.Free()
+		delete(c.devices, handle)
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Package inventory collects the Block Storage inventory of a Linode account,
+the number and size of its volumes in each region, and exports it in the
+metrics of the driver.
+
+It is used by the csi-linode-exporter command, which exports the inventory
+without serving CSI requests:
+
+	inv, err := inventory.Collect(ctx, client, 24*time.Hour)
+	if err != nil {
+		// the volumes could not be listed
+	}
+	inventory.Record(inv)
+*/
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/linode/linodego"
+
+	linodeclient "github.com/linode/linode-blockstorage-csi-driver/pkg/linode-client"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+// Region is the inventory of the volumes of a region.
+type Region struct {
+	// Volumes is the number of volumes.
+	Volumes int
+
+	// SizeGB is the total size of the volumes, in GB.
+	SizeGB int
+
+	// OrphanCandidates is the number of volumes attached to no instance
+	// and not updated for the orphan age given to [Collect]. They may be
+	// volumes left behind by deleted clusters, or kept by a Retain policy.
+	OrphanCandidates int
+}
+
+// Inventory is the inventory of the volumes of an account, by region.
+type Inventory map[string]Region
+
+// Collect lists the volumes of the account of client and returns their
+// inventory. The detached volumes not updated for orphanAge are counted as
+// orphan candidates.
+func Collect(ctx context.Context, client linodeclient.LinodeClient, orphanAge time.Duration) (Inventory, error) {
+	log := logger.GetLogger(ctx)
+	log.V(4).Info("Entering inventory.Collect()", "orphanAge", orphanAge)
+	defer log.V(4).Info("Exiting inventory.Collect()")
+
+	volumes, err := client.ListVolumes(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("list volumes: %w", err)
+	}
+
+	now := time.Now()
+	inv := make(Inventory)
+	for _, vol := range volumes {
+		region := inv[vol.Region]
+		region.Volumes++
+		region.SizeGB += vol.Size
+		if isOrphanCandidate(&vol, now, orphanAge) {
+			log.V(4).Info("Volume is an orphan candidate", "volume_id", vol.ID, "label", vol.Label, "region", vol.Region)
+			region.OrphanCandidates++
+		}
+		inv[vol.Region] = region
+	}
+	log.V(2).Info("Collected volume inventory", "volumes", len(volumes), "regions", len(inv))
+	return inv, nil
+}
+
+// isOrphanCandidate reports whether vol is attached to no instance and was
+// last updated more than orphanAge before now.
+func isOrphanCandidate(vol *linodego.Volume, now time.Time, orphanAge time.Duration) bool {
+	if vol.LinodeID != nil || vol.Updated == nil {
+		return false
+	}
+	return now.Sub(*vol.Updated) > orphanAge
+}
+
+// Record exports inv in the inventory metrics, replacing the previous
+// inventory, and sets the time of the last collection to now.
+func Record(inv Inventory) {
+	observability.InventoryVolumes.Reset()
+	observability.InventoryVolumeSize.Reset()
+	observability.InventoryOrphanCandidates.Reset()
+	for region, r := range inv {
+		observability.InventoryVolumes.WithLabelValues(region).Set(float64(r.Volumes))
+		observability.InventoryVolumeSize.WithLabelValues(region).Set(float64(r.SizeGB))
+		observability.InventoryOrphanCandidates.WithLabelValues(region).Set(float64(r.OrphanCandidates))
+	}
+	observability.InventoryLastCollected.SetToCurrentTime()
+}
+
+// Run collects the inventory and records it every interval, until ctx is
+// done. Failed collections are logged, and leave the previous inventory
+// exported.
+func Run(ctx context.Context, client linodeclient.LinodeClient, interval, orphanAge time.Duration) {
+	log := logger.GetLogger(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if inv, err := Collect(ctx, client, orphanAge); err != nil {
+			log.Error(err, "Failed to collect the volume inventory")
+		} else {
+			Record(inv)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
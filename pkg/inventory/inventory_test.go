@@ -0,0 +1,66 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/linode/linodego"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/mock/gomock"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/observability"
+)
+
+func TestCollect(t *testing.T) {
+	now := time.Now()
+	lastWeek := now.Add(-7 * 24 * time.Hour)
+	linodeID := 1003
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mocks.NewMockLinodeClient(ctrl)
+	mockClient.EXPECT().ListVolumes(gomock.Any(), gomock.Any()).Return([]linodego.Volume{
+		{ID: 1, Region: "us-east", Size: 10, Updated: &lastWeek},
+		{ID: 2, Region: "us-east", Size: 20, Updated: &now},
+		{ID: 3, Region: "us-east", Size: 30, Updated: &lastWeek, LinodeID: &linodeID},
+		{ID: 4, Region: "eu-west", Size: 40},
+	}, nil)
+
+	inv, err := Collect(context.Background(), mockClient, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	want := Inventory{
+		"us-east": {Volumes: 3, SizeGB: 60, OrphanCandidates: 1},
+		"eu-west": {Volumes: 1, SizeGB: 40},
+	}
+	if !reflect.DeepEqual(inv, want) {
+		t.Errorf("Collect() = %+v, want %+v", inv, want)
+	}
+
+	mockClient.EXPECT().ListVolumes(gomock.Any(), gomock.Any()).Return(nil, errors.New("unauthorized"))
+	if _, err := Collect(context.Background(), mockClient, 24*time.Hour); err == nil {
+		t.Error("Collect() error = nil, want the error listing the volumes")
+	}
+}
+
+func TestRecord(t *testing.T) {
+	Record(Inventory{"us-east": {Volumes: 3, SizeGB: 60, OrphanCandidates: 1}, "eu-west": {Volumes: 1, SizeGB: 40}})
+	Record(Inventory{"us-east": {Volumes: 2, SizeGB: 30}})
+
+	// Regions without volumes are no longer exported
+	if got := testutil.CollectAndCount(observability.InventoryVolumes); got != 1 {
+		t.Errorf("inventory volumes series = %d, want 1", got)
+	}
+	if got := testutil.ToFloat64(observability.InventoryVolumeSize.WithLabelValues("us-east")); got != 30 {
+		t.Errorf("inventory volume size = %v, want 30", got)
+	}
+	if got := testutil.ToFloat64(observability.InventoryLastCollected); got == 0 {
+		t.Error("inventory last collected = 0, want the time of the collection")
+	}
+}
@@ -0,0 +1,695 @@
+// Package kubeclient is a minimal client for the parts of the Kubernetes API
+// the driver optionally uses.
+//
+// It only supports in-cluster authentication with the pod's service account,
+// which is all the driver needs, and avoids depending on client-go.
+package kubeclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount/"
+	tokenFile         = serviceAccountDir + "token"
+	caFile            = serviceAccountDir + "ca.crt"
+	namespaceFile     = serviceAccountDir + "namespace"
+
+	requestTimeout = 30 * time.Second
+
+	// eventComponent is the source of the events created by the driver.
+	eventComponent = "linodebs.csi.linode.com"
+)
+
+// errNotInCluster is returned by [NewInClusterClient] when the driver is not
+// running in a Kubernetes pod.
+var errNotInCluster = errors.New("not running in a kubernetes cluster: KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be set")
+
+// ErrNotFound is returned when the requested object does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrAlreadyExists is returned when the object to create already exists.
+var ErrAlreadyExists = errors.New("already exists")
+
+type KubeClient interface {
+	PatchPersistentVolumeClaimAnnotations(ctx context.Context, namespace, name string, annotations map[string]string) error
+	GetNodeAnnotations(ctx context.Context, name string) (map[string]string, error)
+	GetPersistentVolumeAnnotations(ctx context.Context, name string) (map[string]string, error)
+	PatchPersistentVolumeAnnotations(ctx context.Context, name string, annotations map[string]string) error
+	GetPersistentVolumeClaimRef(ctx context.Context, name string) (namespace, claimName string, err error)
+	CreatePersistentVolumeClaimEvent(ctx context.Context, namespace, name, eventType, reason, message string) error
+	ListPersistentVolumes(ctx context.Context, driver string) ([]PersistentVolume, error)
+	ListReferenceGrants(ctx context.Context, namespace string) ([]ReferenceGrant, error)
+	ApplySecret(ctx context.Context, namespace, name string, labels, annotations map[string]string, data map[string][]byte) error
+	GetSecret(ctx context.Context, namespace, name string) (map[string][]byte, error)
+	ListVolumeAttachments(ctx context.Context, attacher string) ([]VolumeAttachment, error)
+	ListNodePods(ctx context.Context, nodeName string) ([]Pod, error)
+	ApplyLease(ctx context.Context, namespace string, lease Lease) error
+	ListLeases(ctx context.Context, namespace, labelSelector string) ([]Lease, error)
+}
+
+// PersistentVolume is the part of a CSI PersistentVolume the driver uses.
+type PersistentVolume struct {
+	Name         string
+	Annotations  map[string]string
+	VolumeHandle string
+
+	// ClaimNamespace and ClaimName identify the PersistentVolumeClaim bound
+	// to the volume. They are empty if it is not bound.
+	ClaimNamespace string
+	ClaimName      string
+
+	// ReadOnly reports whether the volume is only staged read-only:
+	// ReadOnlyMany is its only access mode. A volume that is only read-only
+	// in its CSI source is not, since its other access modes stage it
+	// read-write.
+	ReadOnly bool
+}
+
+// VolumeAttachment is the part of a VolumeAttachment the driver uses.
+type VolumeAttachment struct {
+	Name string
+
+	// NodeName is the node the volume is attached to, and
+	// PersistentVolumeName the volume. It is empty for inline volumes.
+	NodeName             string
+	PersistentVolumeName string
+
+	// Attached reports whether the attacher attached the volume.
+	Attached bool
+}
+
+// Pod is the part of a Pod the driver uses.
+type Pod struct {
+	Namespace string
+	Name      string
+
+	// ClaimNames are the PersistentVolumeClaims the pod uses, in its
+	// namespace.
+	ClaimNames []string
+}
+
+// Lease is the part of a coordination.k8s.io Lease the driver uses.
+type Lease struct {
+	Name        string
+	Labels      map[string]string
+	Annotations map[string]string
+
+	// HolderIdentity holds the lease until RenewTime plus Duration.
+	HolderIdentity string
+	RenewTime      time.Time
+	Duration       time.Duration
+}
+
+// Expired reports whether the lease was not renewed in time at now.
+func (l Lease) Expired(now time.Time) bool {
+	return !l.RenewTime.Add(l.Duration).After(now)
+}
+
+// ReferenceGrant is a Gateway API ReferenceGrant, allowing the objects of
+// the kinds in From to refer to the objects of the kinds in To, in the
+// namespace of the grant.
+type ReferenceGrant struct {
+	Name string
+	From []ReferenceGrantFrom
+	To   []ReferenceGrantTo
+}
+
+// ReferenceGrantFrom is a kind of objects allowed to refer to the objects of
+// a [ReferenceGrant], in a namespace. The core API group is "".
+type ReferenceGrantFrom struct {
+	Group     string `json:"group"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+}
+
+// ReferenceGrantTo is a kind of objects that can be referred to with a
+// [ReferenceGrant], restricted to the object named Name if it is set. The
+// core API group is "".
+type ReferenceGrantTo struct {
+	Group string `json:"group"`
+	Kind  string `json:"kind"`
+	Name  string `json:"name,omitempty"`
+}
+
+// Client talks to the Kubernetes API server of the cluster the driver is
+// running in.
+type Client struct {
+	baseURL    string
+	tokenFile  string
+	httpClient *http.Client
+
+	// watchClient sends the requests watching objects, which last longer
+	// than the request timeout. httpClient is used if it is nil.
+	watchClient *http.Client
+}
+
+var _ KubeClient = &Client{}
+
+// NewInClusterClient returns a Client authenticated with the service account
+// of the pod it is running in.
+func NewInClusterClient() (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errNotInCluster
+	}
+
+	ca, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("read service account CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+
+	transport := &http.Transport{
+		TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+	}
+	return &Client{
+		baseURL:     "https://" + net.JoinHostPort(host, port),
+		tokenFile:   tokenFile,
+		httpClient:  &http.Client{Timeout: requestTimeout, Transport: transport},
+		watchClient: &http.Client{Transport: transport},
+	}, nil
+}
+
+// InClusterNamespace returns the namespace of the pod the driver is running
+// in, from its service account.
+func InClusterNamespace() (string, error) {
+	namespace, err := os.ReadFile(namespaceFile)
+	if err != nil {
+		return "", fmt.Errorf("read service account namespace: %w", err)
+	}
+	return strings.TrimSpace(string(namespace)), nil
+}
+
+// PatchPersistentVolumeClaimAnnotations sets annotations on a
+// PersistentVolumeClaim, leaving its other annotations untouched.
+func (c *Client) PatchPersistentVolumeClaimAnnotations(ctx context.Context, namespace, name string, annotations map[string]string) error {
+	patch := map[string]any{
+		"metadata": map[string]any{
+			"annotations": annotations,
+		},
+	}
+	path := fmt.Sprintf("/api/v1/namespaces/%s/persistentvolumeclaims/%s", url.PathEscape(namespace), url.PathEscape(name))
+	if err := c.patch(ctx, path, patch); err != nil {
+		return fmt.Errorf("patch persistentvolumeclaim %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
+
+// GetNodeAnnotations returns the annotations of a Node. It returns an error
+// wrapping [ErrNotFound] if the node does not exist.
+func (c *Client) GetNodeAnnotations(ctx context.Context, name string) (map[string]string, error) {
+	annotations, err := c.getAnnotations(ctx, "/api/v1/nodes/"+url.PathEscape(name))
+	if err != nil {
+		return nil, fmt.Errorf("get node %s: %w", name, err)
+	}
+	return annotations, nil
+}
+
+// GetPersistentVolumeAnnotations returns the annotations of a
+// PersistentVolume. It returns an error wrapping [ErrNotFound] if the
+// volume does not exist.
+func (c *Client) GetPersistentVolumeAnnotations(ctx context.Context, name string) (map[string]string, error) {
+	annotations, err := c.getAnnotations(ctx, "/api/v1/persistentvolumes/"+url.PathEscape(name))
+	if err != nil {
+		return nil, fmt.Errorf("get persistentvolume %s: %w", name, err)
+	}
+	return annotations, nil
+}
+
+// PatchPersistentVolumeAnnotations sets annotations on a PersistentVolume,
+// leaving its other annotations untouched.
+func (c *Client) PatchPersistentVolumeAnnotations(ctx context.Context, name string, annotations map[string]string) error {
+	patch := map[string]any{
+		"metadata": map[string]any{
+			"annotations": annotations,
+		},
+	}
+	if err := c.patch(ctx, "/api/v1/persistentvolumes/"+url.PathEscape(name), patch); err != nil {
+		return fmt.Errorf("patch persistentvolume %s: %w", name, err)
+	}
+	return nil
+}
+
+// GetPersistentVolumeClaimRef returns the namespace and name of the
+// PersistentVolumeClaim bound to a PersistentVolume. It returns an error
+// wrapping [ErrNotFound] if the volume does not exist or is not bound.
+func (c *Client) GetPersistentVolumeClaimRef(ctx context.Context, name string) (namespace, claimName string, err error) {
+	var object struct {
+		Spec struct {
+			ClaimRef *struct {
+				Namespace string `json:"namespace"`
+				Name      string `json:"name"`
+			} `json:"claimRef"`
+		} `json:"spec"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/persistentvolumes/"+url.PathEscape(name), nil, &object); err != nil {
+		return "", "", fmt.Errorf("get persistentvolume %s: %w", name, err)
+	}
+	if object.Spec.ClaimRef == nil {
+		return "", "", fmt.Errorf("claim of persistentvolume %s: %w", name, ErrNotFound)
+	}
+	return object.Spec.ClaimRef.Namespace, object.Spec.ClaimRef.Name, nil
+}
+
+// ListPersistentVolumes returns the PersistentVolumes provisioned by the CSI
+// driver named driver.
+func (c *Client) ListPersistentVolumes(ctx context.Context, driver string) ([]PersistentVolume, error) {
+	var list struct {
+		Items []struct {
+			Metadata struct {
+				Name        string            `json:"name"`
+				Annotations map[string]string `json:"annotations"`
+			} `json:"metadata"`
+			Spec struct {
+				CSI *struct {
+					Driver       string `json:"driver"`
+					VolumeHandle string `json:"volumeHandle"`
+				} `json:"csi"`
+				AccessModes []string `json:"accessModes"`
+				ClaimRef    *struct {
+					Namespace string `json:"namespace"`
+					Name      string `json:"name"`
+				} `json:"claimRef"`
+			} `json:"spec"`
+		} `json:"items"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/persistentvolumes", nil, &list); err != nil {
+		return nil, fmt.Errorf("list persistentvolumes: %w", err)
+	}
+
+	var volumes []PersistentVolume
+	for _, item := range list.Items {
+		if item.Spec.CSI == nil || item.Spec.CSI.Driver != driver {
+			continue
+		}
+		volume := PersistentVolume{
+			Name:         item.Metadata.Name,
+			Annotations:  item.Metadata.Annotations,
+			VolumeHandle: item.Spec.CSI.VolumeHandle,
+		}
+		if ref := item.Spec.ClaimRef; ref != nil {
+			volume.ClaimNamespace, volume.ClaimName = ref.Namespace, ref.Name
+		}
+		volume.ReadOnly = len(item.Spec.AccessModes) == 1 && item.Spec.AccessModes[0] == "ReadOnlyMany"
+		volumes = append(volumes, volume)
+	}
+	return volumes, nil
+}
+
+// ListVolumeAttachments returns the VolumeAttachments of the CSI driver named
+// attacher.
+func (c *Client) ListVolumeAttachments(ctx context.Context, attacher string) ([]VolumeAttachment, error) {
+	var list struct {
+		Items []struct {
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			Spec struct {
+				Attacher string `json:"attacher"`
+				NodeName string `json:"nodeName"`
+				Source   struct {
+					PersistentVolumeName string `json:"persistentVolumeName"`
+				} `json:"source"`
+			} `json:"spec"`
+			Status struct {
+				Attached bool `json:"attached"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/apis/storage.k8s.io/v1/volumeattachments", nil, &list); err != nil {
+		return nil, fmt.Errorf("list volumeattachments: %w", err)
+	}
+
+	var attachments []VolumeAttachment
+	for _, item := range list.Items {
+		if item.Spec.Attacher != attacher {
+			continue
+		}
+		attachments = append(attachments, VolumeAttachment{
+			Name:                 item.Metadata.Name,
+			NodeName:             item.Spec.NodeName,
+			PersistentVolumeName: item.Spec.Source.PersistentVolumeName,
+			Attached:             item.Status.Attached,
+		})
+	}
+	return attachments, nil
+}
+
+// ListNodePods returns the pods scheduled to the node named nodeName that
+// have not terminated, and so may still use their volumes.
+func (c *Client) ListNodePods(ctx context.Context, nodeName string) ([]Pod, error) {
+	var list struct {
+		Items []struct {
+			Metadata struct {
+				Namespace string `json:"namespace"`
+				Name      string `json:"name"`
+			} `json:"metadata"`
+			Spec struct {
+				Volumes []struct {
+					Name                  string `json:"name"`
+					PersistentVolumeClaim *struct {
+						ClaimName string `json:"claimName"`
+					} `json:"persistentVolumeClaim"`
+					Ephemeral *struct{} `json:"ephemeral"`
+				} `json:"volumes"`
+			} `json:"spec"`
+		} `json:"items"`
+	}
+	selector := "spec.nodeName=" + nodeName + ",status.phase!=Succeeded,status.phase!=Failed"
+	if err := c.do(ctx, http.MethodGet, "/api/v1/pods?fieldSelector="+url.QueryEscape(selector), nil, &list); err != nil {
+		return nil, fmt.Errorf("list pods of node %s: %w", nodeName, err)
+	}
+
+	pods := make([]Pod, 0, len(list.Items))
+	for _, item := range list.Items {
+		pod := Pod{Namespace: item.Metadata.Namespace, Name: item.Metadata.Name}
+		for _, volume := range item.Spec.Volumes {
+			switch {
+			case volume.PersistentVolumeClaim != nil:
+				pod.ClaimNames = append(pod.ClaimNames, volume.PersistentVolumeClaim.ClaimName)
+			case volume.Ephemeral != nil:
+				// The claims of generic ephemeral volumes are named after
+				// the pod and the volume
+				pod.ClaimNames = append(pod.ClaimNames, item.Metadata.Name+"-"+volume.Name)
+			}
+		}
+		pods = append(pods, pod)
+	}
+	return pods, nil
+}
+
+// ListReferenceGrants returns the Gateway API ReferenceGrants of namespace.
+// It returns an error wrapping [ErrNotFound] if the ReferenceGrant resource
+// is not installed in the cluster.
+func (c *Client) ListReferenceGrants(ctx context.Context, namespace string) ([]ReferenceGrant, error) {
+	var list struct {
+		Items []struct {
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			Spec struct {
+				From []ReferenceGrantFrom `json:"from"`
+				To   []ReferenceGrantTo   `json:"to"`
+			} `json:"spec"`
+		} `json:"items"`
+	}
+	path := fmt.Sprintf("/apis/gateway.networking.k8s.io/v1beta1/namespaces/%s/referencegrants", url.PathEscape(namespace))
+	if err := c.do(ctx, http.MethodGet, path, nil, &list); err != nil {
+		return nil, fmt.Errorf("list referencegrants in %s: %w", namespace, err)
+	}
+
+	grants := make([]ReferenceGrant, 0, len(list.Items))
+	for _, item := range list.Items {
+		grants = append(grants, ReferenceGrant{Name: item.Metadata.Name, From: item.Spec.From, To: item.Spec.To})
+	}
+	return grants, nil
+}
+
+// CreatePersistentVolumeClaimEvent records an event of eventType ("Normal" or
+// "Warning") on a PersistentVolumeClaim, shown by kubectl describe.
+func (c *Client) CreatePersistentVolumeClaimEvent(ctx context.Context, namespace, name, eventType, reason, message string) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	event := map[string]any{
+		"metadata": map[string]any{
+			"generateName": name + ".",
+			"namespace":    namespace,
+		},
+		"involvedObject": map[string]any{
+			"apiVersion": "v1",
+			"kind":       "PersistentVolumeClaim",
+			"namespace":  namespace,
+			"name":       name,
+		},
+		"type":           eventType,
+		"reason":         reason,
+		"message":        message,
+		"source":         map[string]any{"component": eventComponent},
+		"firstTimestamp": now,
+		"lastTimestamp":  now,
+		"count":          1,
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("/api/v1/namespaces/%s/events", url.PathEscape(namespace))
+	if err := c.do(ctx, http.MethodPost, path, body, nil); err != nil {
+		return fmt.Errorf("create event for persistentvolumeclaim %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
+
+// ApplySecret creates an Opaque Secret with labels, annotations and data, or
+// updates them if the Secret already exists, leaving its other labels,
+// annotations and keys untouched.
+func (c *Client) ApplySecret(ctx context.Context, namespace, name string, labels, annotations map[string]string, data map[string][]byte) error {
+	metadata := map[string]any{
+		"name":        name,
+		"namespace":   namespace,
+		"labels":      labels,
+		"annotations": annotations,
+	}
+	body, err := json.Marshal(map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   metadata,
+		"type":       "Opaque",
+		"data":       data,
+	})
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("/api/v1/namespaces/%s/secrets", url.PathEscape(namespace))
+	err = c.do(ctx, http.MethodPost, path, body, nil)
+	if errors.Is(err, ErrAlreadyExists) {
+		delete(metadata, "name")
+		delete(metadata, "namespace")
+		err = c.patch(ctx, path+"/"+url.PathEscape(name), map[string]any{"metadata": metadata, "data": data})
+	}
+	if err != nil {
+		return fmt.Errorf("apply secret %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
+
+// GetSecret returns the data of a Secret. It returns an error wrapping
+// [ErrNotFound] if the Secret does not exist.
+func (c *Client) GetSecret(ctx context.Context, namespace, name string) (map[string][]byte, error) {
+	var object struct {
+		Data map[string][]byte `json:"data"`
+	}
+	path := fmt.Sprintf("/api/v1/namespaces/%s/secrets/%s", url.PathEscape(namespace), url.PathEscape(name))
+	if err := c.do(ctx, http.MethodGet, path, nil, &object); err != nil {
+		return nil, fmt.Errorf("get secret %s/%s: %w", namespace, name, err)
+	}
+	return object.Data, nil
+}
+
+// leaseTimeFormat is the format of the MicroTime fields of Leases.
+const leaseTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// leaseObject is the JSON representation of a Lease.
+type leaseObject struct {
+	Metadata struct {
+		Name        string            `json:"name"`
+		Labels      map[string]string `json:"labels,omitempty"`
+		Annotations map[string]string `json:"annotations,omitempty"`
+	} `json:"metadata"`
+	Spec struct {
+		HolderIdentity       string `json:"holderIdentity"`
+		LeaseDurationSeconds int    `json:"leaseDurationSeconds"`
+		RenewTime            string `json:"renewTime"`
+	} `json:"spec"`
+}
+
+// ApplyLease creates lease, or updates it if it already exists, renewing it
+// at its RenewTime. The other labels and annotations of an existing Lease
+// are left untouched.
+func (c *Client) ApplyLease(ctx context.Context, namespace string, lease Lease) error {
+	metadata := map[string]any{
+		"name":        lease.Name,
+		"namespace":   namespace,
+		"labels":      lease.Labels,
+		"annotations": lease.Annotations,
+	}
+	spec := map[string]any{
+		"holderIdentity":       lease.HolderIdentity,
+		"leaseDurationSeconds": int(lease.Duration.Round(time.Second) / time.Second),
+		"renewTime":            lease.RenewTime.UTC().Format(leaseTimeFormat),
+	}
+	body, err := json.Marshal(map[string]any{
+		"apiVersion": "coordination.k8s.io/v1",
+		"kind":       "Lease",
+		"metadata":   metadata,
+		"spec":       spec,
+	})
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("/apis/coordination.k8s.io/v1/namespaces/%s/leases", url.PathEscape(namespace))
+	err = c.do(ctx, http.MethodPost, path, body, nil)
+	if errors.Is(err, ErrAlreadyExists) {
+		delete(metadata, "name")
+		delete(metadata, "namespace")
+		err = c.patch(ctx, path+"/"+url.PathEscape(lease.Name), map[string]any{"metadata": metadata, "spec": spec})
+	}
+	if err != nil {
+		return fmt.Errorf("apply lease %s/%s: %w", namespace, lease.Name, err)
+	}
+	return nil
+}
+
+// ListLeases returns the Leases of namespace matching labelSelector.
+func (c *Client) ListLeases(ctx context.Context, namespace, labelSelector string) ([]Lease, error) {
+	var list struct {
+		Items []leaseObject `json:"items"`
+	}
+	path := fmt.Sprintf("/apis/coordination.k8s.io/v1/namespaces/%s/leases?labelSelector=%s", url.PathEscape(namespace), url.QueryEscape(labelSelector))
+	if err := c.do(ctx, http.MethodGet, path, nil, &list); err != nil {
+		return nil, fmt.Errorf("list leases in %s: %w", namespace, err)
+	}
+
+	leases := make([]Lease, 0, len(list.Items))
+	for _, item := range list.Items {
+		lease := Lease{
+			Name:           item.Metadata.Name,
+			Labels:         item.Metadata.Labels,
+			Annotations:    item.Metadata.Annotations,
+			HolderIdentity: item.Spec.HolderIdentity,
+			Duration:       time.Duration(item.Spec.LeaseDurationSeconds) * time.Second,
+		}
+		// Leases never renewed are expired
+		if renewTime, err := time.Parse(time.RFC3339Nano, item.Spec.RenewTime); err == nil {
+			lease.RenewTime = renewTime
+		}
+		leases = append(leases, lease)
+	}
+	return leases, nil
+}
+
+// getAnnotations returns the annotations of the object at path.
+func (c *Client) getAnnotations(ctx context.Context, path string) (map[string]string, error) {
+	var object struct {
+		Metadata struct {
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+	}
+	if err := c.do(ctx, http.MethodGet, path, nil, &object); err != nil {
+		return nil, err
+	}
+	return object.Metadata.Annotations, nil
+}
+
+// patch sends a JSON merge patch to the API server.
+func (c *Client) patch(ctx context.Context, path string, patch any) error {
+	body, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, http.MethodPatch, path, body, nil)
+}
+
+// do sends a request to the API server, and decodes the JSON response into
+// out unless it is nil. A non-nil body is sent as a JSON merge patch with
+// PATCH requests, and as a JSON object otherwise.
+func (c *Client) do(ctx context.Context, method, path string, body []byte, out any) (err error) {
+	resp, err := c.send(ctx, c.httpClient, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, resp.Body.Close())
+	}()
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("decode response: %w", err)
+		}
+	}
+	return nil
+}
+
+// stream sends a GET request to the API server, and returns the body of the
+// response, to be read as it is received and closed by the caller. Unlike
+// [Client.do], the request is not subject to the request timeout, so it is
+// used to watch objects.
+func (c *Client) stream(ctx context.Context, path string) (io.ReadCloser, error) {
+	httpClient := c.watchClient
+	if httpClient == nil {
+		httpClient = c.httpClient
+	}
+	resp, err := c.send(ctx, httpClient, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// send sends a request to the API server with httpClient, and returns the
+// response if its status is successful. A non-nil body is sent as a JSON
+// merge patch with PATCH requests, and as a JSON object otherwise.
+func (c *Client) send(ctx context.Context, httpClient *http.Client, method, path string, body []byte) (*http.Response, error) {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case body != nil && method == http.MethodPatch:
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+	case body != nil:
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	// The token is read on every request, since projected service account
+	// tokens are rotated by the kubelet.
+	token, err := os.ReadFile(c.tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("read service account token: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	case http.StatusConflict:
+		if method == http.MethodPost {
+			return nil, ErrAlreadyExists
+		}
+	}
+	msg, readErr := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if readErr != nil {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil, fmt.Errorf("unexpected status %s: %s", resp.Status, bytes.TrimSpace(msg))
+}
@@ -0,0 +1,639 @@
+package kubeclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPatchPersistentVolumeClaimAnnotations(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		wantErr    bool
+	}{
+		{
+			name:       "Success",
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "Forbidden",
+			statusCode: http.StatusForbidden,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPatch {
+					t.Errorf("method = %s, want PATCH", r.Method)
+				}
+				if want := "/api/v1/namespaces/default/persistentvolumeclaims/data"; r.URL.Path != want {
+					t.Errorf("path = %s, want %s", r.URL.Path, want)
+				}
+				if got := r.Header.Get("Content-Type"); got != "application/merge-patch+json" {
+					t.Errorf("content type = %s, want application/merge-patch+json", got)
+				}
+				if got := r.Header.Get("Authorization"); got != "Bearer test-token" {
+					t.Errorf("authorization = %q, want %q", got, "Bearer test-token")
+				}
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					t.Errorf("read body: %v", err)
+				}
+				if want := `{"metadata":{"annotations":{"key":"value"}}}`; string(body) != want {
+					t.Errorf("body = %s, want %s", body, want)
+				}
+				w.WriteHeader(tt.statusCode)
+			}))
+			defer server.Close()
+
+			tokenPath := filepath.Join(t.TempDir(), "token")
+			if err := os.WriteFile(tokenPath, []byte("test-token\n"), 0o600); err != nil {
+				t.Fatalf("write token: %v", err)
+			}
+
+			client := &Client{baseURL: server.URL, tokenFile: tokenPath, httpClient: server.Client()}
+			err := client.PatchPersistentVolumeClaimAnnotations(context.Background(), "default", "data", map[string]string{"key": "value"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PatchPersistentVolumeClaimAnnotations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetNodeAnnotations(t *testing.T) {
+	tests := []struct {
+		name         string
+		statusCode   int
+		body         string
+		want         map[string]string
+		wantErr      bool
+		wantNotFound bool
+	}{
+		{
+			name:       "Success",
+			statusCode: http.StatusOK,
+			body:       `{"metadata":{"name":"node-1","annotations":{"key":"value"}}}`,
+			want:       map[string]string{"key": "value"},
+		},
+		{
+			name:       "No annotations",
+			statusCode: http.StatusOK,
+			body:       `{"metadata":{"name":"node-1"}}`,
+		},
+		{
+			name:         "Not found",
+			statusCode:   http.StatusNotFound,
+			wantErr:      true,
+			wantNotFound: true,
+		},
+		{
+			name:       "Forbidden",
+			statusCode: http.StatusForbidden,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodGet {
+					t.Errorf("method = %s, want GET", r.Method)
+				}
+				if want := "/api/v1/nodes/node-1"; r.URL.Path != want {
+					t.Errorf("path = %s, want %s", r.URL.Path, want)
+				}
+				if got := r.Header.Get("Authorization"); got != "Bearer test-token" {
+					t.Errorf("authorization = %q, want %q", got, "Bearer test-token")
+				}
+				w.WriteHeader(tt.statusCode)
+				if _, err := io.WriteString(w, tt.body); err != nil {
+					t.Errorf("write body: %v", err)
+				}
+			}))
+			defer server.Close()
+
+			tokenPath := filepath.Join(t.TempDir(), "token")
+			if err := os.WriteFile(tokenPath, []byte("test-token\n"), 0o600); err != nil {
+				t.Fatalf("write token: %v", err)
+			}
+
+			client := &Client{baseURL: server.URL, tokenFile: tokenPath, httpClient: server.Client()}
+			got, err := client.GetNodeAnnotations(context.Background(), "node-1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetNodeAnnotations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if errors.Is(err, ErrNotFound) != tt.wantNotFound {
+				t.Errorf("GetNodeAnnotations() error = %v, want not found %v", err, tt.wantNotFound)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetNodeAnnotations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewInClusterClientNotInCluster(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	if _, err := NewInClusterClient(); err == nil {
+		t.Error("NewInClusterClient() succeeded outside of a cluster")
+	}
+}
+
+func TestPersistentVolumeAnnotations(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if want := "/api/v1/persistentvolumes/pv-1"; r.URL.Path != want {
+			t.Errorf("path = %s, want %s", r.URL.Path, want)
+		}
+		switch r.Method {
+		case http.MethodGet:
+			if _, err := io.WriteString(w, `{"metadata":{"annotations":{"key":"value"}}}`); err != nil {
+				t.Errorf("write body: %v", err)
+			}
+		case http.MethodPatch:
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				t.Errorf("read body: %v", err)
+			}
+			if want := `{"metadata":{"annotations":{"key":"other"}}}`; string(body) != want {
+				t.Errorf("body = %s, want %s", body, want)
+			}
+		default:
+			t.Errorf("unexpected method %s", r.Method)
+		}
+	}))
+	defer server.Close()
+
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenPath, []byte("test-token\n"), 0o600); err != nil {
+		t.Fatalf("write token: %v", err)
+	}
+	client := &Client{baseURL: server.URL, tokenFile: tokenPath, httpClient: server.Client()}
+
+	got, err := client.GetPersistentVolumeAnnotations(context.Background(), "pv-1")
+	if err != nil {
+		t.Fatalf("GetPersistentVolumeAnnotations() error = %v", err)
+	}
+	if want := map[string]string{"key": "value"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetPersistentVolumeAnnotations() = %v, want %v", got, want)
+	}
+	if err := client.PatchPersistentVolumeAnnotations(context.Background(), "pv-1", map[string]string{"key": "other"}); err != nil {
+		t.Errorf("PatchPersistentVolumeAnnotations() error = %v", err)
+	}
+}
+
+func TestGetPersistentVolumeClaimRef(t *testing.T) {
+	tests := []struct {
+		name          string
+		statusCode    int
+		body          string
+		wantNamespace string
+		wantName      string
+		wantErr       bool
+		wantNotFound  bool
+	}{
+		{
+			name:          "Bound",
+			statusCode:    http.StatusOK,
+			body:          `{"spec":{"claimRef":{"kind":"PersistentVolumeClaim","namespace":"default","name":"data"}}}`,
+			wantNamespace: "default",
+			wantName:      "data",
+		},
+		{
+			name:         "Not bound",
+			statusCode:   http.StatusOK,
+			body:         `{"spec":{}}`,
+			wantErr:      true,
+			wantNotFound: true,
+		},
+		{
+			name:         "Not found",
+			statusCode:   http.StatusNotFound,
+			wantErr:      true,
+			wantNotFound: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if want := "/api/v1/persistentvolumes/pv-1"; r.URL.Path != want {
+					t.Errorf("path = %s, want %s", r.URL.Path, want)
+				}
+				w.WriteHeader(tt.statusCode)
+				if _, err := io.WriteString(w, tt.body); err != nil {
+					t.Errorf("write body: %v", err)
+				}
+			}))
+			defer server.Close()
+
+			tokenPath := filepath.Join(t.TempDir(), "token")
+			if err := os.WriteFile(tokenPath, []byte("test-token\n"), 0o600); err != nil {
+				t.Fatalf("write token: %v", err)
+			}
+
+			client := &Client{baseURL: server.URL, tokenFile: tokenPath, httpClient: server.Client()}
+			namespace, name, err := client.GetPersistentVolumeClaimRef(context.Background(), "pv-1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetPersistentVolumeClaimRef() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if errors.Is(err, ErrNotFound) != tt.wantNotFound {
+				t.Errorf("GetPersistentVolumeClaimRef() error = %v, want not found %v", err, tt.wantNotFound)
+			}
+			if namespace != tt.wantNamespace || name != tt.wantName {
+				t.Errorf("GetPersistentVolumeClaimRef() = %s/%s, want %s/%s", namespace, name, tt.wantNamespace, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestCreatePersistentVolumeClaimEvent(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+		if want := "/api/v1/namespaces/default/events"; r.URL.Path != want {
+			t.Errorf("path = %s, want %s", r.URL.Path, want)
+		}
+		if got := r.Header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("content type = %s, want application/json", got)
+		}
+		var event struct {
+			Metadata struct {
+				GenerateName string `json:"generateName"`
+			} `json:"metadata"`
+			InvolvedObject struct {
+				Kind      string `json:"kind"`
+				Namespace string `json:"namespace"`
+				Name      string `json:"name"`
+			} `json:"involvedObject"`
+			Type    string `json:"type"`
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+			Count   int    `json:"count"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("decode event: %v", err)
+		}
+		if event.Metadata.GenerateName != "data." {
+			t.Errorf("generateName = %q, want %q", event.Metadata.GenerateName, "data.")
+		}
+		if got := event.InvolvedObject; got.Kind != "PersistentVolumeClaim" || got.Namespace != "default" || got.Name != "data" {
+			t.Errorf("involvedObject = %+v, want PersistentVolumeClaim default/data", got)
+		}
+		if event.Type != "Warning" || event.Reason != "Reason" || event.Message != "message" || event.Count != 1 {
+			t.Errorf("event = %+v, want a Warning with reason Reason and message message", event)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenPath, []byte("test-token\n"), 0o600); err != nil {
+		t.Fatalf("write token: %v", err)
+	}
+
+	client := &Client{baseURL: server.URL, tokenFile: tokenPath, httpClient: server.Client()}
+	if err := client.CreatePersistentVolumeClaimEvent(context.Background(), "default", "data", "Warning", "Reason", "message"); err != nil {
+		t.Errorf("CreatePersistentVolumeClaimEvent() error = %v", err)
+	}
+}
+
+func TestApplySecret(t *testing.T) {
+	for _, exists := range []bool{false, true} {
+		t.Run(map[bool]string{false: "Created", true: "Updated"}[exists], func(t *testing.T) {
+			var methods []string
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				methods = append(methods, r.Method)
+				var secret struct {
+					Metadata struct {
+						Name   string            `json:"name"`
+						Labels map[string]string `json:"labels"`
+					} `json:"metadata"`
+					Data map[string][]byte `json:"data"`
+				}
+				if err := json.NewDecoder(r.Body).Decode(&secret); err != nil {
+					t.Errorf("decode secret: %v", err)
+				}
+				if string(secret.Data["key"]) != "value" || secret.Metadata.Labels["label"] != "true" {
+					t.Errorf("secret = %+v, want the data and labels", secret)
+				}
+				switch r.Method {
+				case http.MethodPost:
+					if want := "/api/v1/namespaces/kube-system/secrets"; r.URL.Path != want {
+						t.Errorf("path = %s, want %s", r.URL.Path, want)
+					}
+					if secret.Metadata.Name != "backup" {
+						t.Errorf("name = %q, want %q", secret.Metadata.Name, "backup")
+					}
+					if exists {
+						w.WriteHeader(http.StatusConflict)
+						return
+					}
+					w.WriteHeader(http.StatusCreated)
+				case http.MethodPatch:
+					if want := "/api/v1/namespaces/kube-system/secrets/backup"; r.URL.Path != want {
+						t.Errorf("path = %s, want %s", r.URL.Path, want)
+					}
+					w.WriteHeader(http.StatusOK)
+				}
+			}))
+			defer server.Close()
+
+			tokenPath := filepath.Join(t.TempDir(), "token")
+			if err := os.WriteFile(tokenPath, []byte("test-token\n"), 0o600); err != nil {
+				t.Fatalf("write token: %v", err)
+			}
+
+			client := &Client{baseURL: server.URL, tokenFile: tokenPath, httpClient: server.Client()}
+			err := client.ApplySecret(context.Background(), "kube-system", "backup", map[string]string{"label": "true"}, nil, map[string][]byte{"key": []byte("value")})
+			if err != nil {
+				t.Fatalf("ApplySecret() error = %v", err)
+			}
+			want := []string{http.MethodPost}
+			if exists {
+				want = append(want, http.MethodPatch)
+			}
+			if !reflect.DeepEqual(methods, want) {
+				t.Errorf("methods = %v, want %v", methods, want)
+			}
+		})
+	}
+}
+
+func TestGetSecret(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/namespaces/kube-system/secrets/backup":
+			_, _ = io.WriteString(w, `{"data": {"key": "dmFsdWU="}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenPath, []byte("test-token\n"), 0o600); err != nil {
+		t.Fatalf("write token: %v", err)
+	}
+
+	client := &Client{baseURL: server.URL, tokenFile: tokenPath, httpClient: server.Client()}
+	data, err := client.GetSecret(context.Background(), "kube-system", "backup")
+	if err != nil {
+		t.Fatalf("GetSecret() error = %v", err)
+	}
+	if got := string(data["key"]); got != "value" {
+		t.Errorf("GetSecret() key = %q, want %q", got, "value")
+	}
+	if _, err := client.GetSecret(context.Background(), "kube-system", "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetSecret() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestListPersistentVolumes(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if want := "/api/v1/persistentvolumes"; r.URL.Path != want {
+			t.Errorf("path = %s, want %s", r.URL.Path, want)
+		}
+		body := `{"items":[
+			{"metadata":{"name":"pv-1","annotations":{"key":"value"}},"spec":{"csi":{"driver":"linodebs.csi.linode.com","volumeHandle":"1001-pvc1"},"claimRef":{"namespace":"default","name":"data"}}},
+			{"metadata":{"name":"pv-2"},"spec":{"csi":{"driver":"other.csi.example.com","volumeHandle":"vol-2"}}},
+			{"metadata":{"name":"pv-3"},"spec":{"hostPath":{"path":"/data"}}},
+			{"metadata":{"name":"pv-4"},"spec":{"csi":{"driver":"linodebs.csi.linode.com","volumeHandle":"1004-pvc4","readOnly":true},"accessModes":["ReadWriteOnce"]}},
+			{"metadata":{"name":"pv-5"},"spec":{"csi":{"driver":"linodebs.csi.linode.com","volumeHandle":"1005-pvc5"},"accessModes":["ReadOnlyMany"]}}
+		]}`
+		if _, err := io.WriteString(w, body); err != nil {
+			t.Errorf("write body: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenPath, []byte("test-token\n"), 0o600); err != nil {
+		t.Fatalf("write token: %v", err)
+	}
+	client := &Client{baseURL: server.URL, tokenFile: tokenPath, httpClient: server.Client()}
+
+	got, err := client.ListPersistentVolumes(context.Background(), "linodebs.csi.linode.com")
+	if err != nil {
+		t.Fatalf("ListPersistentVolumes() error = %v", err)
+	}
+	want := []PersistentVolume{
+		{Name: "pv-1", Annotations: map[string]string{"key": "value"}, VolumeHandle: "1001-pvc1", ClaimNamespace: "default", ClaimName: "data"},
+		{Name: "pv-4", VolumeHandle: "1004-pvc4"},
+		{Name: "pv-5", VolumeHandle: "1005-pvc5", ReadOnly: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListPersistentVolumes() = %+v, want %+v", got, want)
+	}
+}
+
+func TestListVolumeAttachments(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if want := "/apis/storage.k8s.io/v1/volumeattachments"; r.URL.Path != want {
+			t.Errorf("path = %s, want %s", r.URL.Path, want)
+		}
+		body := `{"items":[
+			{"metadata":{"name":"csi-1"},"spec":{"attacher":"linodebs.csi.linode.com","nodeName":"node-1","source":{"persistentVolumeName":"pv-1"}},"status":{"attached":true}},
+			{"metadata":{"name":"csi-2"},"spec":{"attacher":"other.csi.example.com","nodeName":"node-1","source":{"persistentVolumeName":"pv-2"}}},
+			{"metadata":{"name":"csi-3"},"spec":{"attacher":"linodebs.csi.linode.com","nodeName":"node-2","source":{"inlineVolumeSpec":{}}}}
+		]}`
+		if _, err := io.WriteString(w, body); err != nil {
+			t.Errorf("write body: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenPath, []byte("test-token\n"), 0o600); err != nil {
+		t.Fatalf("write token: %v", err)
+	}
+	client := &Client{baseURL: server.URL, tokenFile: tokenPath, httpClient: server.Client()}
+
+	got, err := client.ListVolumeAttachments(context.Background(), "linodebs.csi.linode.com")
+	if err != nil {
+		t.Fatalf("ListVolumeAttachments() error = %v", err)
+	}
+	want := []VolumeAttachment{
+		{Name: "csi-1", NodeName: "node-1", PersistentVolumeName: "pv-1", Attached: true},
+		{Name: "csi-3", NodeName: "node-2"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListVolumeAttachments() = %+v, want %+v", got, want)
+	}
+}
+
+func TestListNodePods(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if want := "/api/v1/pods"; r.URL.Path != want {
+			t.Errorf("path = %s, want %s", r.URL.Path, want)
+		}
+		if got, want := r.URL.Query().Get("fieldSelector"), "spec.nodeName=node-1,status.phase!=Succeeded,status.phase!=Failed"; got != want {
+			t.Errorf("fieldSelector = %s, want %s", got, want)
+		}
+		body := `{"items":[
+			{"metadata":{"namespace":"default","name":"web"},"spec":{"volumes":[{"name":"cache","persistentVolumeClaim":{"claimName":"cache","readOnly":true}},{"name":"config","configMap":{"name":"web"}}]}},
+			{"metadata":{"namespace":"jobs","name":"build"},"spec":{"volumes":[{"name":"scratch","ephemeral":{"volumeClaimTemplate":{}}}]}},
+			{"metadata":{"namespace":"default","name":"sleep"},"spec":{}}
+		]}`
+		if _, err := io.WriteString(w, body); err != nil {
+			t.Errorf("write body: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenPath, []byte("test-token\n"), 0o600); err != nil {
+		t.Fatalf("write token: %v", err)
+	}
+	client := &Client{baseURL: server.URL, tokenFile: tokenPath, httpClient: server.Client()}
+
+	got, err := client.ListNodePods(context.Background(), "node-1")
+	if err != nil {
+		t.Fatalf("ListNodePods() error = %v", err)
+	}
+	want := []Pod{
+		{Namespace: "default", Name: "web", ClaimNames: []string{"cache"}},
+		{Namespace: "jobs", Name: "build", ClaimNames: []string{"build-scratch"}},
+		{Namespace: "default", Name: "sleep"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListNodePods() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLeases(t *testing.T) {
+	renewTime := time.Date(2024, 10, 16, 12, 0, 0, 123456000, time.UTC)
+	var methods []string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		switch r.Method {
+		case http.MethodPost:
+			var lease leaseObject
+			if err := json.NewDecoder(r.Body).Decode(&lease); err != nil {
+				t.Errorf("decode lease: %v", err)
+			}
+			if lease.Metadata.Name != "shard-0" || lease.Spec.HolderIdentity != "controller-0" || lease.Spec.LeaseDurationSeconds != 15 || lease.Spec.RenewTime != "2024-10-16T12:00:00.123456Z" {
+				t.Errorf("lease = %+v, want shard-0 held by controller-0 for 15s", lease)
+			}
+			w.WriteHeader(http.StatusConflict)
+		case http.MethodPatch:
+			if want := "/apis/coordination.k8s.io/v1/namespaces/kube-system/leases/shard-0"; r.URL.Path != want {
+				t.Errorf("path = %s, want %s", r.URL.Path, want)
+			}
+		case http.MethodGet:
+			if got, want := r.URL.Query().Get("labelSelector"), "shard=linodebs"; got != want {
+				t.Errorf("labelSelector = %q, want %q", got, want)
+			}
+			_, _ = io.WriteString(w, `{"items":[
+				{"metadata":{"name":"shard-0","annotations":{"address":"10.0.0.1:10000"}},"spec":{"holderIdentity":"controller-0","leaseDurationSeconds":15,"renewTime":"2024-10-16T12:00:00.123456Z"}},
+				{"metadata":{"name":"shard-1"},"spec":{"holderIdentity":"controller-1"}}
+			]}`)
+		}
+	}))
+	defer server.Close()
+
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenPath, []byte("test-token\n"), 0o600); err != nil {
+		t.Fatalf("write token: %v", err)
+	}
+	client := &Client{baseURL: server.URL, tokenFile: tokenPath, httpClient: server.Client()}
+
+	lease := Lease{
+		Name:           "shard-0",
+		Annotations:    map[string]string{"address": "10.0.0.1:10000"},
+		HolderIdentity: "controller-0",
+		RenewTime:      renewTime,
+		Duration:       15 * time.Second,
+	}
+	if err := client.ApplyLease(context.Background(), "kube-system", lease); err != nil {
+		t.Fatalf("ApplyLease() error = %v", err)
+	}
+	if want := []string{http.MethodPost, http.MethodPatch}; !reflect.DeepEqual(methods, want) {
+		t.Errorf("methods = %v, want %v", methods, want)
+	}
+
+	got, err := client.ListLeases(context.Background(), "kube-system", "shard=linodebs")
+	if err != nil {
+		t.Fatalf("ListLeases() error = %v", err)
+	}
+	want := []Lease{lease, {Name: "shard-1", HolderIdentity: "controller-1"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListLeases() = %+v, want %+v", got, want)
+	}
+	if got[0].Expired(renewTime.Add(10*time.Second)) || !got[0].Expired(renewTime.Add(15*time.Second)) || !got[1].Expired(renewTime) {
+		t.Error("Expired() = wrong expiry, want leases expired once not renewed for their duration")
+	}
+}
+
+func TestListReferenceGrants(t *testing.T) {
+	tests := []struct {
+		name         string
+		statusCode   int
+		body         string
+		want         []ReferenceGrant
+		wantErr      bool
+		wantNotFound bool
+	}{
+		{
+			name:       "Grants",
+			statusCode: http.StatusOK,
+			body: `{"items":[{"metadata":{"name":"allow-clones"},"spec":{
+				"from":[{"group":"","kind":"PersistentVolumeClaim","namespace":"dev"}],
+				"to":[{"group":"","kind":"PersistentVolumeClaim","name":"data"}]}}]}`,
+			want: []ReferenceGrant{{
+				Name: "allow-clones",
+				From: []ReferenceGrantFrom{{Kind: "PersistentVolumeClaim", Namespace: "dev"}},
+				To:   []ReferenceGrantTo{{Kind: "PersistentVolumeClaim", Name: "data"}},
+			}},
+		},
+		{
+			name:         "Resource not installed",
+			statusCode:   http.StatusNotFound,
+			wantErr:      true,
+			wantNotFound: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if want := "/apis/gateway.networking.k8s.io/v1beta1/namespaces/prod/referencegrants"; r.URL.Path != want {
+					t.Errorf("path = %s, want %s", r.URL.Path, want)
+				}
+				w.WriteHeader(tt.statusCode)
+				if _, err := io.WriteString(w, tt.body); err != nil {
+					t.Errorf("write body: %v", err)
+				}
+			}))
+			defer server.Close()
+
+			tokenPath := filepath.Join(t.TempDir(), "token")
+			if err := os.WriteFile(tokenPath, []byte("test-token\n"), 0o600); err != nil {
+				t.Fatalf("write token: %v", err)
+			}
+
+			client := &Client{baseURL: server.URL, tokenFile: tokenPath, httpClient: server.Client()}
+			got, err := client.ListReferenceGrants(context.Background(), "prod")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ListReferenceGrants() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if errors.Is(err, ErrNotFound) != tt.wantNotFound {
+				t.Errorf("ListReferenceGrants() error = %v, want not found %v", err, tt.wantNotFound)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ListReferenceGrants() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
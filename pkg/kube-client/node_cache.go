@@ -0,0 +1,371 @@
+package kubeclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// providerIDPrefix prefixes the provider ID of nodes running on Linode
+// instances, followed by the ID of the instance.
+const providerIDPrefix = "linode://"
+
+// watchTimeout is how long the API server keeps a watch of the nodes open,
+// after which the watch is restarted from the last seen resource version.
+const watchTimeout = 5 * time.Minute
+
+// Node is the part of a Kubernetes Node the driver uses.
+type Node struct {
+	Name        string
+	Annotations map[string]string
+
+	// LinodeID is the ID of the Linode instance the node runs on, parsed
+	// from its provider ID, or 0 if it is not set.
+	LinodeID int
+
+	// Unschedulable reports whether the node is cordoned.
+	Unschedulable bool
+}
+
+// NodeLookup finds the Kubernetes node running on a Linode instance.
+type NodeLookup interface {
+	// LookupNode returns the node whose provider ID is the Linode instance
+	// with linodeID or, if there is none, the node named label. It returns
+	// an error wrapping [ErrNotFound] if neither exists, or wrapping
+	// [ErrNotSynced] if the nodes are not known yet.
+	LookupNode(ctx context.Context, linodeID int, label string) (Node, error)
+}
+
+// NodeDeletions notifies the deletions of Kubernetes nodes.
+type NodeDeletions interface {
+	// OnNodeDeleted calls handler with each node deleted from then on, from
+	// the goroutine watching the nodes, so it must not block.
+	OnNodeDeleted(handler func(Node))
+}
+
+// NodeCordons notifies the cordons of Kubernetes nodes.
+type NodeCordons interface {
+	// OnNodeCordoned calls handler with each node cordoned from then on,
+	// and with the nodes already cordoned when they are first listed, from
+	// the goroutine watching the nodes, so it must not block.
+	OnNodeCordoned(handler func(Node))
+}
+
+// ParseProviderID returns the ID of the Linode instance in the provider ID
+// of a node, of the form "linode://<id>".
+func ParseProviderID(providerID string) (int, error) {
+	id, ok := strings.CutPrefix(providerID, providerIDPrefix)
+	if !ok {
+		return 0, fmt.Errorf("provider ID %q does not start with %q", providerID, providerIDPrefix)
+	}
+	linodeID, err := strconv.Atoi(id)
+	if err != nil || linodeID <= 0 {
+		return 0, fmt.Errorf("invalid Linode ID in provider ID %q", providerID)
+	}
+	return linodeID, nil
+}
+
+// ErrNotSynced is returned by [NodeCache.LookupNode] until the nodes were
+// listed once.
+var ErrNotSynced = errors.New("node cache not synced")
+
+// nodeObject is the JSON representation of the parts of a Node used to build
+// a [Node].
+type nodeObject struct {
+	Metadata struct {
+		Name            string            `json:"name"`
+		Annotations     map[string]string `json:"annotations"`
+		ResourceVersion string            `json:"resourceVersion"`
+	} `json:"metadata"`
+	Spec struct {
+		ProviderID    string `json:"providerID"`
+		Unschedulable bool   `json:"unschedulable"`
+	} `json:"spec"`
+}
+
+func (o *nodeObject) node() Node {
+	node := Node{Name: o.Metadata.Name, Annotations: o.Metadata.Annotations, Unschedulable: o.Spec.Unschedulable}
+	if linodeID, err := ParseProviderID(o.Spec.ProviderID); err == nil {
+		node.LinodeID = linodeID
+	}
+	return node
+}
+
+// NodeCache keeps a copy of the nodes of the cluster, kept up to date by
+// listing and then watching them, like an informer. It resolves nodes
+// without a request to the API server each time.
+type NodeCache struct {
+	client *Client
+
+	mu       sync.RWMutex
+	nodes    map[string]Node // By name
+	names    map[int]string  // Node names by Linode ID
+	deleted  []func(Node)    // Handlers of the deleted nodes
+	cordoned []func(Node)    // Handlers of the cordoned nodes
+	synced   chan struct{}
+	syncOnce sync.Once
+}
+
+var (
+	_ NodeLookup    = &NodeCache{}
+	_ NodeDeletions = &NodeCache{}
+	_ NodeCordons   = &NodeCache{}
+)
+
+// NewNodeCache returns a NodeCache of the nodes read with client. It is
+// empty until [NodeCache.Run] is started.
+func NewNodeCache(client *Client) *NodeCache {
+	return &NodeCache{
+		client: client,
+		nodes:  make(map[string]Node),
+		names:  make(map[int]string),
+		synced: make(chan struct{}),
+	}
+}
+
+// nodeCacheBackoff is how long Run waits before listing the nodes again
+// after an error.
+var nodeCacheBackoff = wait.Backoff{
+	Duration: time.Second,
+	Factor:   2,
+	Jitter:   0.1,
+	Steps:    8,
+	Cap:      time.Minute,
+}
+
+// Run lists and watches the nodes until ctx is done. Errors are reported to
+// onError, and the nodes are listed again after a backoff. A watch that
+// expired is not an error: the nodes are listed again right away.
+func (c *NodeCache) Run(ctx context.Context, onError func(error)) {
+	backoff := nodeCacheBackoff
+	for ctx.Err() == nil {
+		err := c.listAndWatch(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil || errors.Is(err, errWatchExpired) {
+			backoff = nodeCacheBackoff
+			continue
+		}
+		onError(err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff.Step()):
+		}
+	}
+}
+
+// WaitForSync waits until the nodes were listed once, or ctx is done.
+func (c *NodeCache) WaitForSync(ctx context.Context) error {
+	select {
+	case <-c.synced:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("wait for node cache sync: %w", ctx.Err())
+	}
+}
+
+// LookupNode implements [NodeLookup]. It does not wait until the nodes were
+// listed once, since listing them may keep failing, and returns an error
+// wrapping [ErrNotSynced] instead.
+func (c *NodeCache) LookupNode(_ context.Context, linodeID int, label string) (Node, error) {
+	select {
+	case <-c.synced:
+	default:
+		return Node{}, fmt.Errorf("node for linode %d (%s): %w", linodeID, label, ErrNotSynced)
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if name, ok := c.names[linodeID]; ok {
+		return c.nodes[name], nil
+	}
+	if node, ok := c.nodes[label]; ok {
+		return node, nil
+	}
+	return Node{}, fmt.Errorf("node for linode %d (%s): %w", linodeID, label, ErrNotFound)
+}
+
+// OnNodeDeleted implements [NodeDeletions]. Nodes missing from the nodes
+// listed again after a watch failed are also deleted.
+func (c *NodeCache) OnNodeDeleted(handler func(Node)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deleted = append(c.deleted, handler)
+}
+
+// OnNodeCordoned implements [NodeCordons].
+func (c *NodeCache) OnNodeCordoned(handler func(Node)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cordoned = append(c.cordoned, handler)
+}
+
+// listAndWatch lists the nodes, replaces the cached nodes with them, and
+// applies the changes to the nodes until the watch ends. It returns nil
+// when the watch ended normally and can be restarted.
+func (c *NodeCache) listAndWatch(ctx context.Context) error {
+	var list struct {
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+		Items []nodeObject `json:"items"`
+	}
+	if err := c.client.do(ctx, http.MethodGet, "/api/v1/nodes", nil, &list); err != nil {
+		return fmt.Errorf("list nodes: %w", err)
+	}
+
+	nodes := make(map[string]Node, len(list.Items))
+	names := make(map[int]string, len(list.Items))
+	for i := range list.Items {
+		node := list.Items[i].node()
+		nodes[node.Name] = node
+		if node.LinodeID != 0 {
+			names[node.LinodeID] = node.Name
+		}
+	}
+	c.mu.Lock()
+	var removed, cordoned []Node
+	for name, node := range c.nodes {
+		if _, ok := nodes[name]; !ok {
+			removed = append(removed, node)
+		}
+	}
+	for name, node := range nodes {
+		if node.Unschedulable && !c.nodes[name].Unschedulable {
+			cordoned = append(cordoned, node)
+		}
+	}
+	c.nodes, c.names = nodes, names
+	deletedHandlers, cordonedHandlers := c.deleted, c.cordoned
+	c.mu.Unlock()
+	c.syncOnce.Do(func() { close(c.synced) })
+	for _, node := range removed {
+		for _, handler := range deletedHandlers {
+			handler(node)
+		}
+	}
+	for _, node := range cordoned {
+		for _, handler := range cordonedHandlers {
+			handler(node)
+		}
+	}
+
+	resourceVersion := list.Metadata.ResourceVersion
+	for {
+		var err error
+		resourceVersion, err = c.watch(ctx, resourceVersion)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// errWatchExpired is returned when a watch cannot be resumed from its
+// resource version, and the nodes must be listed again.
+var errWatchExpired = errors.New("watch expired")
+
+// watch applies the changes to the nodes since resourceVersion, until the
+// API server ends the watch. It returns the last seen resource version.
+func (c *NodeCache) watch(ctx context.Context, resourceVersion string) (string, error) {
+	query := url.Values{
+		"watch":               {"true"},
+		"resourceVersion":     {resourceVersion},
+		"allowWatchBookmarks": {"true"},
+		"timeoutSeconds":      {strconv.Itoa(int(watchTimeout.Seconds()))},
+	}
+	body, err := c.client.stream(ctx, "/api/v1/nodes?"+query.Encode())
+	if err != nil {
+		return "", fmt.Errorf("watch nodes: %w", err)
+	}
+	defer body.Close()
+
+	decoder := json.NewDecoder(body)
+	for {
+		var event struct {
+			Type   string          `json:"type"`
+			Object json.RawMessage `json:"object"`
+		}
+		if err := decoder.Decode(&event); err != nil {
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			// The API server closed the watch
+			return resourceVersion, nil
+		}
+
+		if event.Type == "ERROR" {
+			var status struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			}
+			if err := json.Unmarshal(event.Object, &status); err == nil && status.Code == http.StatusGone {
+				return "", errWatchExpired
+			}
+			return "", fmt.Errorf("watch nodes: %s", event.Object)
+		}
+
+		var object nodeObject
+		if err := json.Unmarshal(event.Object, &object); err != nil {
+			return "", fmt.Errorf("decode node: %w", err)
+		}
+		resourceVersion = object.Metadata.ResourceVersion
+
+		switch event.Type {
+		case "ADDED", "MODIFIED":
+			c.set(object.node())
+		case "DELETED":
+			c.delete(object.Metadata.Name)
+		}
+	}
+}
+
+func (c *NodeCache) set(node Node) {
+	c.mu.Lock()
+	old, ok := c.nodes[node.Name]
+	if ok && old.LinodeID != 0 {
+		delete(c.names, old.LinodeID)
+	}
+	c.nodes[node.Name] = node
+	if node.LinodeID != 0 {
+		c.names[node.LinodeID] = node.Name
+	}
+	handlers := c.cordoned
+	c.mu.Unlock()
+
+	if !node.Unschedulable || old.Unschedulable {
+		return
+	}
+	for _, handler := range handlers {
+		handler(node)
+	}
+}
+
+func (c *NodeCache) delete(name string) {
+	c.mu.Lock()
+	old, ok := c.nodes[name]
+	if ok && old.LinodeID != 0 {
+		delete(c.names, old.LinodeID)
+	}
+	delete(c.nodes, name)
+	handlers := c.deleted
+	c.mu.Unlock()
+
+	if !ok {
+		return
+	}
+	for _, handler := range handlers {
+		handler(old)
+	}
+}
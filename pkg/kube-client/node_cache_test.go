@@ -0,0 +1,251 @@
+package kubeclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseProviderID(t *testing.T) {
+	tests := []struct {
+		name       string
+		providerID string
+		want       int
+		wantErr    bool
+	}{
+		{
+			name:       "Linode instance",
+			providerID: "linode://12345",
+			want:       12345,
+		},
+		{
+			name:       "Empty",
+			providerID: "",
+			wantErr:    true,
+		},
+		{
+			name:       "Other provider",
+			providerID: "aws:///us-east-1a/i-0123456789",
+			wantErr:    true,
+		},
+		{
+			name:       "Invalid ID",
+			providerID: "linode://abc",
+			wantErr:    true,
+		},
+		{
+			name:       "Zero ID",
+			providerID: "linode://0",
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseProviderID(tt.providerID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseProviderID() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseProviderID() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNodeCache(t *testing.T) {
+	const list = `{
+		"metadata": {"resourceVersion": "10"},
+		"items": [
+			{"metadata": {"name": "node-1", "annotations": {"key": "one"}}, "spec": {"providerID": "linode://101"}},
+			{"metadata": {"name": "node-2", "annotations": {"key": "two"}}, "spec": {"providerID": "linode://102"}},
+			{"metadata": {"name": "node-3"}, "spec": {"unschedulable": true}}
+		]
+	}`
+	const events = `{"type": "MODIFIED", "object": {"metadata": {"name": "node-1", "resourceVersion": "11", "annotations": {"key": "updated"}}, "spec": {"providerID": "linode://101", "unschedulable": true}}}
+{"type": "DELETED", "object": {"metadata": {"name": "node-2", "resourceVersion": "12"}, "spec": {"providerID": "linode://102"}}}
+{"type": "ADDED", "object": {"metadata": {"name": "node-4", "resourceVersion": "13"}, "spec": {"providerID": "linode://104"}}}
+`
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/nodes" {
+			t.Errorf("path = %s, want /api/v1/nodes", r.URL.Path)
+		}
+		if r.URL.Query().Get("watch") == "" {
+			fmt.Fprint(w, list)
+			return
+		}
+		if got := r.URL.Query().Get("resourceVersion"); got != "10" {
+			t.Errorf("resourceVersion = %s, want 10", got)
+		}
+		fmt.Fprint(w, events)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenPath, []byte("test-token\n"), 0o600); err != nil {
+		t.Fatalf("write token: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cache := NewNodeCache(&Client{baseURL: server.URL, tokenFile: tokenPath, httpClient: server.Client()})
+	deleted := make(chan Node, 3)
+	cache.OnNodeDeleted(func(node Node) {
+		deleted <- node
+	})
+	cordoned := make(chan Node, 3)
+	cache.OnNodeCordoned(func(node Node) {
+		cordoned <- node
+	})
+	go cache.Run(ctx, func(err error) {
+		t.Errorf("Run() error = %v", err)
+	})
+
+	// Wait for the events of the watch to be applied
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		node, err := cache.LookupNode(ctx, 104, "linode104")
+		if err == nil && node.Name == "node-4" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("node-4 was not added: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Handlers are called with the node as it was cached
+	select {
+	case got := <-deleted:
+		if want := (Node{Name: "node-2", LinodeID: 102, Annotations: map[string]string{"key": "two"}}); !reflect.DeepEqual(got, want) {
+			t.Errorf("deleted node = %+v, want %+v", got, want)
+		}
+	default:
+		t.Error("deletion of node-2 was not notified")
+	}
+	if len(deleted) > 0 {
+		t.Errorf("%d more deletions notified, want none", len(deleted))
+	}
+	// Nodes cordoned before the cache synced are notified too
+	for _, want := range []string{"node-3", "node-1"} {
+		select {
+		case got := <-cordoned:
+			if got.Name != want || !got.Unschedulable {
+				t.Errorf("cordoned node = %+v, want %s cordoned", got, want)
+			}
+		default:
+			t.Errorf("cordon of %s was not notified", want)
+		}
+	}
+	if len(cordoned) > 0 {
+		t.Errorf("%d more cordons notified, want none", len(cordoned))
+	}
+
+	tests := []struct {
+		name         string
+		linodeID     int
+		label        string
+		want         Node
+		wantNotFound bool
+	}{
+		{
+			name:     "By provider ID",
+			linodeID: 101,
+			label:    "linode101",
+			want:     Node{Name: "node-1", LinodeID: 101, Annotations: map[string]string{"key": "updated"}, Unschedulable: true},
+		},
+		{
+			name:     "By name",
+			linodeID: 103,
+			label:    "node-3",
+			want:     Node{Name: "node-3", Unschedulable: true},
+		},
+		{
+			name:         "Deleted",
+			linodeID:     102,
+			label:        "node-2",
+			wantNotFound: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cache.LookupNode(ctx, tt.linodeID, tt.label)
+			if errors.Is(err, ErrNotFound) != tt.wantNotFound {
+				t.Fatalf("LookupNode() error = %v, wantNotFound %v", err, tt.wantNotFound)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LookupNode() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNodeCacheLookupBeforeSync(t *testing.T) {
+	cache := NewNodeCache(&Client{})
+
+	if _, err := cache.LookupNode(context.Background(), 101, "node-1"); !errors.Is(err, ErrNotSynced) {
+		t.Errorf("LookupNode() error = %v, want %v", err, ErrNotSynced)
+	}
+}
+
+func TestNodeCacheWatchExpired(t *testing.T) {
+	const expired = `{"type": "ERROR", "object": {"kind": "Status", "code": 410, "message": "too old resource version"}}
+`
+
+	var listed atomic.Int32
+	lists := make(chan string, 2)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("watch") == "" {
+			version := strconv.Itoa(9 + int(listed.Add(1)))
+			lists <- version
+			fmt.Fprintf(w, `{"metadata": {"resourceVersion": %q}, "items": []}`, version)
+			return
+		}
+		if r.URL.Query().Get("resourceVersion") == "10" {
+			fmt.Fprint(w, expired)
+			return
+		}
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenPath, []byte("test-token\n"), 0o600); err != nil {
+		t.Fatalf("write token: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Listing again after a backoff would take longer than the deadline
+	cache := NewNodeCache(&Client{baseURL: server.URL, tokenFile: tokenPath, httpClient: server.Client()})
+	go cache.Run(ctx, func(err error) {
+		t.Errorf("Run() error = %v", err)
+	})
+
+	for _, want := range []string{"10", "11"} {
+		select {
+		case got := <-lists:
+			if got != want {
+				t.Errorf("listed at resource version %s, want %s", got, want)
+			}
+		case <-time.After(nodeCacheBackoff.Duration / 2):
+			t.Fatalf("nodes were not listed at resource version %s", want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package linodeclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/linode/linodego"
+)
+
+// Capabilities are the capabilities of the regions and of the account the
+// driver uses, discovered once at startup with [DiscoverCapabilities] instead
+// of being requested for every volume.
+type Capabilities struct {
+	// Regions maps the ID of each region to its capabilities, such as
+	// [linodego.CapabilityBlockStorageEncryption].
+	Regions map[string][]string
+
+	// Countries maps the ID of each region to the code of the country it
+	// is in.
+	Countries map[string]string
+
+	// Account lists the capabilities of the account. It is nil if the
+	// token is not allowed to read the account.
+	Account []string
+}
+
+// RegionSupports reports whether region has capability. known is false if
+// the region was not discovered, e.g. because it was added since, in which
+// case the region must be requested instead.
+func (c *Capabilities) RegionSupports(region, capability string) (supported, known bool) {
+	if c == nil {
+		return false, false
+	}
+	capabilities, ok := c.Regions[region]
+	if !ok {
+		return false, false
+	}
+	return slices.Contains(capabilities, capability), true
+}
+
+// RegionsSupporting returns the regions with capability, up to limit of
+// them. The regions in the same country as near come first, so that they can
+// be suggested in place of near. The regions are otherwise sorted by ID.
+func (c *Capabilities) RegionsSupporting(capability, near string, limit int) []string {
+	if c == nil {
+		return nil
+	}
+	var regions []string
+	for region, capabilities := range c.Regions {
+		if region != near && slices.Contains(capabilities, capability) {
+			regions = append(regions, region)
+		}
+	}
+	country, nearKnown := c.Countries[near]
+	slices.SortFunc(regions, func(a, b string) int {
+		aNear := nearKnown && c.Countries[a] == country
+		bNear := nearKnown && c.Countries[b] == country
+		switch {
+		case aNear && !bNear:
+			return -1
+		case bNear && !aNear:
+			return 1
+		}
+		return strings.Compare(a, b)
+	})
+	if len(regions) > limit {
+		regions = regions[:limit]
+	}
+	return regions
+}
+
+// AccountSupports reports whether the account has capability.
+func (c *Capabilities) AccountSupports(capability string) bool {
+	return c != nil && slices.Contains(c.Account, capability)
+}
+
+// DiscoverCapabilities lists the regions and reads the account with client,
+// to find their capabilities. The capabilities of the account are left out
+// if the token is not allowed to read it.
+func DiscoverCapabilities(ctx context.Context, client LinodeClient) (*Capabilities, error) {
+	regions, err := client.ListRegions(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("list regions: %w", err)
+	}
+	capabilities := &Capabilities{
+		Regions:   make(map[string][]string, len(regions)),
+		Countries: make(map[string]string, len(regions)),
+	}
+	for _, region := range regions {
+		capabilities.Regions[region.ID] = region.Capabilities
+		capabilities.Countries[region.ID] = region.Country
+	}
+
+	account, err := client.GetAccount(ctx)
+	switch {
+	case linodego.ErrHasStatus(err, http.StatusUnauthorized, http.StatusForbidden):
+	case err != nil:
+		return nil, fmt.Errorf("get account: %w", err)
+	default:
+		capabilities.Account = account.Capabilities
+	}
+	return capabilities, nil
+}
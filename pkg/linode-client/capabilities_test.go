@@ -0,0 +1,155 @@
+package linodeclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/linode/linodego"
+	"go.uber.org/mock/gomock"
+
+	"github.com/linode/linode-blockstorage-csi-driver/mocks"
+)
+
+func TestDiscoverCapabilities(t *testing.T) {
+	regions := []linodego.Region{
+		{ID: "us-east", Country: "us", Capabilities: []string{linodego.CapabilityBlockStorage, linodego.CapabilityBlockStorageEncryption}},
+		{ID: "us-west", Country: "us", Capabilities: []string{linodego.CapabilityBlockStorage}},
+	}
+	wantRegions := map[string][]string{
+		"us-east": {linodego.CapabilityBlockStorage, linodego.CapabilityBlockStorageEncryption},
+		"us-west": {linodego.CapabilityBlockStorage},
+	}
+	wantCountries := map[string]string{"us-east": "us", "us-west": "us"}
+
+	tests := []struct {
+		name       string
+		setupMocks func(*mocks.MockLinodeClient)
+		want       *Capabilities
+		wantErr    bool
+	}{
+		{
+			name: "Regions and account",
+			setupMocks: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().ListRegions(gomock.Any(), nil).Return(regions, nil)
+				m.EXPECT().GetAccount(gomock.Any()).Return(&linodego.Account{Capabilities: []string{linodego.CapabilityBlockStorage}}, nil)
+			},
+			want: &Capabilities{Regions: wantRegions, Countries: wantCountries, Account: []string{linodego.CapabilityBlockStorage}},
+		},
+		{
+			name: "Account forbidden",
+			setupMocks: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().ListRegions(gomock.Any(), nil).Return(regions, nil)
+				m.EXPECT().GetAccount(gomock.Any()).Return(nil, &linodego.Error{Code: http.StatusForbidden})
+			},
+			want: &Capabilities{Regions: wantRegions, Countries: wantCountries},
+		},
+		{
+			name: "Account error",
+			setupMocks: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().ListRegions(gomock.Any(), nil).Return(regions, nil)
+				m.EXPECT().GetAccount(gomock.Any()).Return(nil, errors.New("API error"))
+			},
+			wantErr: true,
+		},
+		{
+			name: "Regions error",
+			setupMocks: func(m *mocks.MockLinodeClient) {
+				m.EXPECT().ListRegions(gomock.Any(), nil).Return(nil, errors.New("API error"))
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockClient := mocks.NewMockLinodeClient(ctrl)
+			tt.setupMocks(mockClient)
+
+			got, err := DiscoverCapabilities(context.Background(), mockClient)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DiscoverCapabilities() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiscoverCapabilities() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCapabilitiesRegionSupports(t *testing.T) {
+	capabilities := &Capabilities{Regions: map[string][]string{
+		"us-east": {linodego.CapabilityBlockStorageEncryption},
+		"us-west": {},
+	}}
+
+	tests := []struct {
+		name          string
+		capabilities  *Capabilities
+		region        string
+		wantSupported bool
+		wantKnown     bool
+	}{
+		{name: "Supported", capabilities: capabilities, region: "us-east", wantSupported: true, wantKnown: true},
+		{name: "Not supported", capabilities: capabilities, region: "us-west", wantKnown: true},
+		{name: "Unknown region", capabilities: capabilities, region: "eu-west"},
+		{name: "Not discovered", region: "us-east"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			supported, known := tt.capabilities.RegionSupports(tt.region, linodego.CapabilityBlockStorageEncryption)
+			if supported != tt.wantSupported || known != tt.wantKnown {
+				t.Errorf("RegionSupports() = %v, %v, want %v, %v", supported, known, tt.wantSupported, tt.wantKnown)
+			}
+		})
+	}
+}
+
+func TestCapabilitiesRegionsSupporting(t *testing.T) {
+	capabilities := &Capabilities{
+		Regions: map[string][]string{
+			"ap-south":   {linodego.CapabilityBlockStorageEncryption},
+			"eu-central": {linodego.CapabilityBlockStorageEncryption},
+			"us-east":    {},
+			"us-ord":     {linodego.CapabilityBlockStorageEncryption},
+			"us-sea":     {linodego.CapabilityBlockStorageEncryption},
+			"us-west":    {},
+		},
+		Countries: map[string]string{
+			"ap-south":   "sg",
+			"eu-central": "de",
+			"us-east":    "us",
+			"us-ord":     "us",
+			"us-sea":     "us",
+			"us-west":    "us",
+		},
+	}
+
+	tests := []struct {
+		name         string
+		capabilities *Capabilities
+		near         string
+		limit        int
+		want         []string
+	}{
+		{name: "Same country first", capabilities: capabilities, near: "us-east", limit: 5, want: []string{"us-ord", "us-sea", "ap-south", "eu-central"}},
+		{name: "Limited", capabilities: capabilities, near: "us-east", limit: 1, want: []string{"us-ord"}},
+		{name: "Unknown region", capabilities: capabilities, near: "br-gru", limit: 5, want: []string{"ap-south", "eu-central", "us-ord", "us-sea"}},
+		{name: "Not discovered", near: "us-east", limit: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.capabilities.RegionsSupporting(linodego.CapabilityBlockStorageEncryption, tt.near, tt.limit)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RegionsSupporting() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package linodeclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+// DebugVerbosity is the verbosity at which [DebugDump] logs the requests to
+// the Linode API.
+const DebugVerbosity = 6
+
+// defaultDebugMaxBodyBytes is the number of bytes of each body logged by
+// [DebugDump] when MaxBodyBytes is not set.
+const defaultDebugMaxBodyBytes = 4096
+
+// redactedValue replaces the values of the secret fields of logged bodies.
+const redactedValue = "REDACTED"
+
+// secretFieldParts are the parts of the names of the JSON fields whose
+// values are redacted from logged bodies, compared in lower case: API
+// tokens, passwords, and LUKS keys and passphrases.
+var secretFieldParts = []string{"token", "pass", "secret", "luks", "key"}
+
+// DebugDump configures the logging of the requests to the Linode API and of
+// their responses, with their bodies, at [DebugVerbosity]. Only a sample of
+// the requests is logged, and bodies are truncated, so that debugging an
+// API mismatch on a busy driver does not flood the logs. The values of the
+// secret fields of the bodies are redacted, and headers are not logged.
+//
+// The zero value logs nothing.
+type DebugDump struct {
+	// SampleRate is the fraction of the requests logged, between 0 and 1.
+	SampleRate float64
+
+	// MaxBodyBytes is the number of bytes of each body logged, 4 KiB if
+	// it is not set.
+	MaxBodyBytes int
+}
+
+// enabled reports whether requests are logged.
+func (d DebugDump) enabled() bool {
+	return d.SampleRate > 0
+}
+
+// roundTripper returns base, logging the requests it sends.
+func (d DebugDump) roundTripper(base http.RoundTripper) http.RoundTripper {
+	maxBodyBytes := d.MaxBodyBytes
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = defaultDebugMaxBodyBytes
+	}
+	return &debugTransport{
+		base:         base,
+		sampleRate:   min(d.SampleRate, 1),
+		maxBodyBytes: maxBodyBytes,
+	}
+}
+
+// debugTransport logs a sample of the requests sent with base, with their
+// responses, when the logger of their context is enabled at
+// [DebugVerbosity].
+type debugTransport struct {
+	base         http.RoundTripper
+	sampleRate   float64
+	maxBodyBytes int
+
+	// sampled counts the requests that could have been logged.
+	sampled atomic.Uint64
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	log := logger.GetLogger(req.Context()).WithComponent(logger.ComponentLinodeClient).V(DebugVerbosity)
+	if !log.Enabled() || !t.sample() {
+		return t.base.RoundTrip(req)
+	}
+
+	requestBody := "<not logged>"
+	if req.Body == nil || req.Body == http.NoBody {
+		requestBody = ""
+	} else if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			data, err := io.ReadAll(body)
+			if err == nil {
+				requestBody = t.redactBody(data)
+			}
+			_ = body.Close()
+		}
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	keysAndValues := []any{"method", req.Method, "url", req.URL.String(), "requestBody", requestBody, "duration", time.Since(start)}
+	if err != nil {
+		log.Info("Linode API request failed", append(keysAndValues, "error", err.Error())...)
+		return resp, err
+	}
+
+	// The body is read to be logged, and replaced for the caller
+	data, err := io.ReadAll(resp.Body)
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(data), resp.Body), resp.Body}
+	keysAndValues = append(keysAndValues, "status", resp.StatusCode, "responseBody", t.redactBody(data))
+	if err != nil {
+		keysAndValues = append(keysAndValues, "responseBodyError", err.Error())
+	}
+	log.Info("Linode API request", keysAndValues...)
+	return resp, nil
+}
+
+// sample reports whether to log the next request, so that the fraction of
+// the requests logged is the sample rate.
+func (t *debugTransport) sample() bool {
+	n := float64(t.sampled.Add(1))
+	return math.Floor(n*t.sampleRate) != math.Floor((n-1)*t.sampleRate)
+}
+
+// redactBody returns the JSON body data with the values of its secret fields
+// redacted, truncated to the maximum body size. Bodies that are not JSON
+// are not logged, since their secrets cannot be found.
+func (t *debugTransport) redactBody(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	var body any
+	if err := json.Unmarshal(data, &body); err != nil {
+		return fmt.Sprintf("<%d bytes, not JSON>", len(data))
+	}
+	redacted, err := json.Marshal(redactSecretFields(body))
+	if err != nil {
+		return fmt.Sprintf("<%d bytes>", len(data))
+	}
+	if len(redacted) > t.maxBodyBytes {
+		return fmt.Sprintf("%s...<%d bytes truncated>", redacted[:t.maxBodyBytes], len(redacted)-t.maxBodyBytes)
+	}
+	return string(redacted)
+}
+
+// redactSecretFields replaces the values of the secret fields of the JSON
+// value v, at any depth.
+func redactSecretFields(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if isSecretField(key) {
+				v[key] = redactedValue
+			} else {
+				v[key] = redactSecretFields(value)
+			}
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = redactSecretFields(value)
+		}
+	}
+	return v
+}
+
+// isSecretField reports whether the JSON field name holds a secret.
+func isSecretField(name string) bool {
+	name = strings.ToLower(name)
+	for _, part := range secretFieldParts {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package linodeclient
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr/funcr"
+
+	"github.com/linode/linode-blockstorage-csi-driver/pkg/logger"
+)
+
+func TestDebugTransport(t *testing.T) {
+	const response = `{"id":1001,"label":"pvc-1","linode_token":"response-secret"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, response)
+	}))
+	defer server.Close()
+
+	var logs []string
+	ctx := context.WithValue(context.Background(), logger.LoggerKey{}, &logger.Logger{
+		Klogr: funcr.New(func(_, args string) { logs = append(logs, args) }, funcr.Options{Verbosity: DebugVerbosity}),
+	})
+
+	transport := &debugTransport{base: http.DefaultTransport, sampleRate: 0.5, maxBodyBytes: defaultDebugMaxBodyBytes}
+	client := &http.Client{Transport: transport}
+	for range 4 {
+		body := `{"label":"pvc-1","encryption":"enabled","root_pass":"request-secret","configs":[{"luks_key":"request-secret"}]}`
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/v4/volumes", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("new request: %v", err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		data, err := io.ReadAll(resp.Body)
+		if err != nil || string(data) != response {
+			t.Errorf("response body = %q, %v, want %q", data, err, response)
+		}
+		if err := resp.Body.Close(); err != nil {
+			t.Errorf("close response body: %v", err)
+		}
+	}
+
+	if len(logs) != 2 {
+		t.Fatalf("logged %d requests, want 2 with a sample rate of 0.5:\n%s", len(logs), strings.Join(logs, "\n"))
+	}
+	for _, log := range logs {
+		if strings.Contains(log, "secret") {
+			t.Errorf("logged secret: %s", log)
+		}
+		for _, want := range []string{`"label\":\"pvc-1\"`, `"status"=200`, `"root_pass\":\"REDACTED\"`, `"linode_token\":\"REDACTED\"`} {
+			if !strings.Contains(log, want) {
+				t.Errorf("log does not contain %s: %s", want, log)
+			}
+		}
+	}
+}
+
+func TestDebugTransport_disabled(t *testing.T) {
+	base := roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(nil))}, nil
+	})
+	var logs []string
+	ctx := context.WithValue(context.Background(), logger.LoggerKey{}, &logger.Logger{
+		Klogr: funcr.New(func(_, args string) { logs = append(logs, args) }, funcr.Options{Verbosity: DebugVerbosity - 1}),
+	})
+
+	transport := &debugTransport{base: base, sampleRate: 1, maxBodyBytes: defaultDebugMaxBodyBytes}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.linode.com/v4/volumes", nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+	if len(logs) != 0 {
+		t.Errorf("logged requests below verbosity %d: %v", DebugVerbosity, logs)
+	}
+}
+
+func TestDebugTransport_redactBody(t *testing.T) {
+	transport := &debugTransport{maxBodyBytes: 24}
+	tests := []struct {
+		body string
+		want string
+	}{
+		{body: "", want: ""},
+		{body: "token=secret", want: "<12 bytes, not JSON>"},
+		{body: `{"Token":"secret"}`, want: `{"Token":"REDACTED"}`},
+		{body: `{"label":"a-long-volume-label"}`, want: `{"label":"a-long-volume-...<7 bytes truncated>`},
+	}
+	for _, tt := range tests {
+		if got := transport.redactBody([]byte(tt.body)); got != tt.want {
+			t.Errorf("redactBody(%q) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -3,24 +3,41 @@ package linodeclient
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"net/url"
+	"os"
 	"strings"
 
 	"github.com/linode/linodego"
 )
 
+// APIVersion is the version of the Linode API the driver is written against.
+// Requests are sent to it unless the API URL or $LINODE_API_VERSION names
+// another version, so that upgrading linodego does not change the version in
+// use.
+const APIVersion = "v4"
+
+// LinodeClient is the subset of the Linode API used by the driver. It is
+// implemented by [linodego.Client], and by the mocks used in tests.
 type LinodeClient interface {
 	ListInstances(context.Context, *linodego.ListOptions) ([]linodego.Instance, error) // Needed for metadata
 	ListVolumes(context.Context, *linodego.ListOptions) ([]linodego.Volume, error)
 	ListInstanceVolumes(ctx context.Context, instanceID int, options *linodego.ListOptions) ([]linodego.Volume, error)
 	ListInstanceDisks(ctx context.Context, instanceID int, options *linodego.ListOptions) ([]linodego.InstanceDisk, error)
+	ListInstanceConfigs(ctx context.Context, instanceID int, options *linodego.ListOptions) ([]linodego.InstanceConfig, error)
 
+	ListRegions(context.Context, *linodego.ListOptions) ([]linodego.Region, error)
 	GetRegion(ctx context.Context, regionID string) (*linodego.Region, error)
+	GetAccount(context.Context) (*linodego.Account, error)
+	GetProfile(context.Context) (*linodego.Profile, error)
+	ListTokens(context.Context, *linodego.ListOptions) ([]linodego.Token, error)
 	GetInstance(context.Context, int) (*linodego.Instance, error)
 	GetVolume(context.Context, int) (*linodego.Volume, error)
 
 	CreateVolume(context.Context, linodego.VolumeCreateOptions) (*linodego.Volume, error)
 	CloneVolume(context.Context, int, string) (*linodego.Volume, error)
+	UpdateVolume(context.Context, int, linodego.VolumeUpdateOptions) (*linodego.Volume, error)
+	UpdateInstanceConfig(ctx context.Context, instanceID, configID int, opts linodego.InstanceConfigUpdateOptions) (*linodego.InstanceConfig, error)
 
 	AttachVolume(context.Context, int, *linodego.VolumeAttachOptions) (*linodego.Volume, error)
 	DetachVolume(context.Context, int) error
@@ -32,14 +49,36 @@ type LinodeClient interface {
 	ResizeVolume(context.Context, int, int) error
 
 	NewEventPoller(context.Context, any, linodego.EntityType, linodego.EventAction) (*linodego.EventPoller, error)
+	ListEvents(context.Context, *linodego.ListOptions) ([]linodego.Event, error)
 }
 
-func NewLinodeClient(token, ua, apiURL string) (*linodego.Client, error) {
-	// Use linodego built-in http client which supports setting root CA cert
-	linodeClient := linodego.NewClient(nil)
+var _ LinodeClient = &linodego.Client{}
+
+// NewLinodeClient creates a client of the Linode API at apiURL, or the
+// default API URL if it is empty, sending its requests as set with transport
+// and logging them as set with debug.
+func NewLinodeClient(token, ua, apiURL string, transport Transport, debug DebugDump) (*linodego.Client, error) {
+	// Use linodego built-in http client which supports setting root CA cert,
+	// unless the transport is configured or the requests are logged
+	var hc *http.Client
+	if transport.enabled() || debug.enabled() {
+		rt, err := transport.roundTripper()
+		if err != nil {
+			return nil, err
+		}
+		if debug.enabled() {
+			rt = debug.roundTripper(rt)
+		}
+		hc = &http.Client{Transport: rt}
+	}
+	linodeClient := linodego.NewClient(hc)
 	linodeClient.SetUserAgent(ua)
 	linodeClient.SetToken(token)
 
+	if _, ok := os.LookupEnv(linodego.APIVersionVar); !ok {
+		linodeClient.SetAPIVersion(APIVersion)
+	}
+
 	if apiURL != "" {
 		host, version, err := getAPIURLComponents(apiURL)
 		if err != nil {
@@ -51,7 +51,7 @@ func TestNewLinodeClient(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := NewLinodeClient(tt.args.token, tt.args.ua, tt.args.apiURL)
+			got, err := NewLinodeClient(tt.args.token, tt.args.ua, tt.args.apiURL, Transport{}, DebugDump{})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("NewLinodeClient() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -0,0 +1,153 @@
+package linodeclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/linode/linodego"
+)
+
+// Transport configures how the requests reach the Linode API, for clusters
+// in restricted networks that route them through an egress proxy, possibly
+// intercepting TLS. Connections use both IPv4 and IPv6 addresses of the API
+// as the default transport does.
+//
+// The zero value sends the requests through the proxy of $HTTPS_PROXY, unless
+// $NO_PROXY excludes the API, and trusts the system roots, or only the
+// certificate at $LINODE_CA if it is set, as linodego does.
+type Transport struct {
+	// ProxyURL is the URL of the HTTP or HTTPS proxy the requests are sent
+	// through, instead of the proxy of the environment.
+	ProxyURL string
+
+	// CABundlePath is the path of a PEM bundle of CA certificates trusted
+	// in addition to the system roots, e.g. the CA of a proxy intercepting
+	// TLS, mounted from a ConfigMap or Secret. The bundle is read again when
+	// the file changes, so that rotated certificates are trusted without a
+	// restart.
+	CABundlePath string
+}
+
+// enabled reports whether the transport differs from the one of linodego.
+func (t Transport) enabled() bool {
+	return t.ProxyURL != "" || t.CABundlePath != ""
+}
+
+// roundTripper returns the transport of the requests to the Linode API.
+func (t Transport) roundTripper() (http.RoundTripper, error) {
+	base, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return nil, errors.New("default HTTP transport is not an *http.Transport")
+	}
+	base = base.Clone()
+
+	if t.ProxyURL != "" {
+		proxy, err := url.Parse(t.ProxyURL)
+		if err != nil || (proxy.Scheme != "http" && proxy.Scheme != "https") || proxy.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q, must be an http or https URL", t.ProxyURL)
+		}
+		base.Proxy = http.ProxyURL(proxy)
+	}
+
+	if t.CABundlePath != "" {
+		transport := &caBundleTransport{base: base, path: filepath.Clean(t.CABundlePath)}
+		// Fail now rather than on the first request if the bundle cannot be
+		// read
+		if _, err := transport.current(); err != nil {
+			return nil, err
+		}
+		return transport, nil
+	}
+
+	// Like linodego, which cannot set it on a custom transport
+	if certPath, ok := os.LookupEnv(linodego.APIHostCert); ok {
+		cert, err := os.ReadFile(filepath.Clean(certPath))
+		if err != nil {
+			return nil, fmt.Errorf("read API root certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(cert) {
+			return nil, fmt.Errorf("no certificate found in %s", certPath)
+		}
+		base.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	return base, nil
+}
+
+// caBundleTransport sends requests with a copy of base trusting the CA bundle
+// at path, which it replaces when the file changes.
+type caBundleTransport struct {
+	base *http.Transport
+	path string
+
+	mu sync.Mutex // protects the fields below
+	// transport trusts the bundle last read, modified at modTime.
+	transport *http.Transport
+	modTime   time.Time
+}
+
+func (t *caBundleTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport, err := t.current()
+	if err != nil {
+		return nil, err
+	}
+	return transport.RoundTrip(req)
+}
+
+// current returns the transport trusting the bundle, reading it again if the
+// file changed. The previous transport is kept while the new bundle cannot
+// be read, e.g. while it is being replaced.
+func (t *caBundleTransport) current() (*http.Transport, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Stat follows the symbolic links that ConfigMap and Secret volumes
+	// replace when they are updated
+	info, err := os.Stat(t.path)
+	if err == nil && t.transport != nil && info.ModTime().Equal(t.modTime) {
+		return t.transport, nil
+	}
+	var pool *x509.CertPool
+	if err == nil {
+		pool, err = loadCABundle(t.path)
+	}
+	if err != nil {
+		if t.transport != nil {
+			return t.transport, nil
+		}
+		return nil, err
+	}
+
+	transport := t.base.Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	if t.transport != nil {
+		t.transport.CloseIdleConnections()
+	}
+	t.transport, t.modTime = transport, info.ModTime()
+	return transport, nil
+}
+
+// loadCABundle returns the system roots with the certificates of the PEM
+// bundle at path.
+func loadCABundle(path string) (*x509.CertPool, error) {
+	bundle, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read CA bundle: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(bundle) {
+		return nil, fmt.Errorf("no certificate found in %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,135 @@
+package linodeclient
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTransport_proxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		_, _ = io.WriteString(w, "{}")
+	}))
+	defer proxy.Close()
+
+	rt, err := Transport{ProxyURL: proxy.URL}.roundTripper()
+	if err != nil {
+		t.Fatalf("roundTripper() error = %v", err)
+	}
+	resp, err := (&http.Client{Transport: rt}).Get("http://api.linode.invalid/v4/volumes")
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	_ = resp.Body.Close()
+	if want := "http://api.linode.invalid/v4/volumes"; proxied != want {
+		t.Errorf("proxy received %q, want %q", proxied, want)
+	}
+
+	for _, proxyURL := range []string{"socks5://proxy:1080", "proxy:3128", "://"} {
+		if _, err := (Transport{ProxyURL: proxyURL}).roundTripper(); err == nil {
+			t.Errorf("roundTripper() with proxy URL %q succeeded, want an error", proxyURL)
+		}
+	}
+}
+
+func TestTransport_caBundle(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { _, _ = io.WriteString(w, "{}") })
+	first := newTLSServer(t, handler)
+	defer first.Close()
+	second := newTLSServer(t, handler)
+	defer second.Close()
+
+	bundlePath := filepath.Join(t.TempDir(), "ca.crt")
+	writeBundle := func(server *httptest.Server, modTime time.Time) {
+		t.Helper()
+		bundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+		if err := os.WriteFile(bundlePath, bundle, 0o600); err != nil {
+			t.Fatalf("write CA bundle: %v", err)
+		}
+		if err := os.Chtimes(bundlePath, modTime, modTime); err != nil {
+			t.Fatalf("set modification time of CA bundle: %v", err)
+		}
+	}
+	get := func(client *http.Client, server *httptest.Server) error {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	now := time.Now()
+	writeBundle(first, now.Add(-time.Hour))
+	rt, err := Transport{CABundlePath: bundlePath}.roundTripper()
+	if err != nil {
+		t.Fatalf("roundTripper() error = %v", err)
+	}
+	client := &http.Client{Transport: rt}
+	if err := get(client, first); err != nil {
+		t.Errorf("request to the server of the bundle: %v", err)
+	}
+	if err := get(client, second); err == nil {
+		t.Errorf("request to a server not in the bundle succeeded")
+	}
+
+	// The bundle is replaced, e.g. when its ConfigMap is updated
+	writeBundle(second, now)
+	if err := get(client, second); err != nil {
+		t.Errorf("request to the server of the new bundle: %v", err)
+	}
+
+	// The last bundle read is kept while the file cannot be read
+	if err := os.Remove(bundlePath); err != nil {
+		t.Fatalf("remove CA bundle: %v", err)
+	}
+	if err := get(client, second); err != nil {
+		t.Errorf("request while the bundle is missing: %v", err)
+	}
+
+	if _, err := (Transport{CABundlePath: bundlePath}).roundTripper(); err == nil {
+		t.Errorf("roundTripper() with a missing CA bundle succeeded, want an error")
+	}
+}
+
+// newTLSServer starts a server with its own self-signed certificate, unlike
+// httptest.NewTLSServer whose servers share theirs.
+func newTLSServer(t *testing.T, handler http.Handler) *httptest.Server {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(handler)
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{cert}, PrivateKey: key}}, MinVersion: tls.VersionTLS12}
+	server.StartTLS()
+	return server
+}
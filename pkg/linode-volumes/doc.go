@@ -0,0 +1,23 @@
+/*
+Package linodevolumes converts between the identifiers used for Linode Block
+Storage Volumes by the CSI driver.
+
+The driver identifies a volume, in a PersistentVolume's spec.csi.volumeHandle
+and in every CSI request, with a volume key of the form "<id>-<label>", where
+<id> is the numeric ID of the volume in the Linode API and <label> is the
+volume's label, truncated to [LinodeVolumeLabelLength] characters:
+
+	handle := linodevolumes.VolumeHandle(12345, "pvc-0a1b2c3d")
+	// handle == "12345-pvc-0a1b2c3d"
+
+	key, err := linodevolumes.ParseLinodeVolumeKey(handle)
+	if err != nil {
+		// handle was not created by the driver
+	}
+	fmt.Println(key.GetVolumeID(), key.GetVolumeLabel())
+
+Tools that need the same volume ID the driver derives from a handle,
+including for handles that are not volume keys (see [VolumeIDFromHandle]),
+should use this package instead of re-implementing the format.
+*/
+package linodevolumes
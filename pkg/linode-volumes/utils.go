@@ -1,8 +1,10 @@
 package linodevolumes
 
 import (
+	"errors"
 	"fmt"
 	"hash/fnv"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -20,9 +22,19 @@ type (
 	}
 )
 
+// LinodeVolumeLabelLength is the maximum length of a Linode Block Storage
+// Volume label.
+//
 // TODO: Rename this variable
 const LinodeVolumeLabelLength = 32
 
+// ErrInvalidVolumeKey is returned by [LinodeVolumeKey.Validate] and
+// [ValidateVolumeHandle] for malformed volume keys.
+var ErrInvalidVolumeKey = errors.New("invalid linode volume key")
+
+// labelPattern matches the characters allowed in a volume label.
+var labelPattern = regexp.MustCompile(`^[0-9A-Za-z_-]+$`)
+
 func hashStringToInt(b string) int {
 	algorithm := fnv.New32a()
 	_, _ = algorithm.Write([]byte(b))
@@ -30,6 +42,9 @@ func hashStringToInt(b string) int {
 	return int(i)
 }
 
+// VolumeIdAsInt returns the Linode volume ID for the volume ID of a CSI
+// request. See [VolumeIDFromHandle] for how the ID is derived. caller is
+// included in the error returned when the request has no volume ID.
 func VolumeIdAsInt(caller string, w withVolume) (int, error) {
 	strVolID := w.GetVolumeId()
 	if caller != "" {
@@ -40,17 +55,43 @@ func VolumeIdAsInt(caller string, w withVolume) (int, error) {
 		return 0, status.Errorf(codes.InvalidArgument, "%sVolume ID must be provided", caller)
 	}
 
-	volID := 0
-	if key, err := ParseLinodeVolumeKey(strVolID); err == nil {
-		volID = key.GetVolumeID()
-	} else {
-		// hack to permit csi-test to use ill-formatted volumeids
-		volID = hashStringToInt(strVolID)
+	return VolumeIDFromHandle(strVolID), nil
+}
+
+// VolumeIDFromHandle returns the Linode volume ID for a PersistentVolume's
+// volume handle.
+//
+// If handle is not a valid volume key, the ID is a hash of handle instead.
+// The driver does this so the CSI sanity tests, which use arbitrary volume
+// IDs, can run against it; such IDs never match an existing volume.
+func VolumeIDFromHandle(handle string) int {
+	if key, err := ParseLinodeVolumeKey(handle); err == nil {
+		return key.GetVolumeID()
 	}
+	// hack to permit csi-test to use ill-formatted volumeids
+	return hashStringToInt(handle)
+}
+
+// VolumeHandle returns the volume handle the driver uses for the volume with
+// the given ID and label.
+func VolumeHandle(id int, label string) string {
+	key := CreateLinodeVolumeKey(id, label)
+	return key.GetVolumeKey()
+}
 
-	return volID, nil
+// ValidateVolumeHandle returns an error wrapping [ErrInvalidVolumeKey] if
+// handle is not a volume key the driver could have created.
+func ValidateVolumeHandle(handle string) error {
+	key, err := ParseLinodeVolumeKey(handle)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidVolumeKey, err)
+	}
+	return key.Validate()
 }
 
+// NodeIdAsInt returns the Linode instance ID for the node ID of a CSI
+// request. Node IDs that are not numeric are hashed, like in
+// [VolumeIDFromHandle].
 func NodeIdAsInt(caller string, w withNode) (int, error) {
 	strNodeID := w.GetNodeId()
 	if caller != "" {
@@ -69,15 +110,22 @@ func NodeIdAsInt(caller string, w withNode) (int, error) {
 	return nodeID, nil
 }
 
+// LinodeVolumeKey identifies a Linode Block Storage Volume by its ID and
+// label. Its string form, returned by [LinodeVolumeKey.GetVolumeKey], is the
+// volume ID used by the driver.
 type LinodeVolumeKey struct {
 	VolumeID int
 	Label    string
 }
 
+// CreateLinodeVolumeKey returns the key of the volume with the given ID and
+// label.
 func CreateLinodeVolumeKey(id int, label string) LinodeVolumeKey {
 	return LinodeVolumeKey{id, label}
 }
 
+// ParseLinodeVolumeKey parses a volume key of the form "<id>-<label>". Use
+// [LinodeVolumeKey.Validate] to also check the ID and label are valid.
 func ParseLinodeVolumeKey(key string) (*LinodeVolumeKey, error) {
 	keys := strings.SplitN(key, "-", 2)
 	if len(keys) != 2 {
@@ -93,14 +141,33 @@ func ParseLinodeVolumeKey(key string) (*LinodeVolumeKey, error) {
 	return &lvk, nil
 }
 
+// Validate returns an error wrapping [ErrInvalidVolumeKey] if key does not
+// have a positive volume ID and a valid, non-empty label.
+func (key *LinodeVolumeKey) Validate() error {
+	if key.VolumeID <= 0 {
+		return fmt.Errorf("%w: volume id must be positive, got %d", ErrInvalidVolumeKey, key.VolumeID)
+	}
+	if !labelPattern.MatchString(key.Label) {
+		return fmt.Errorf("%w: label may only contain [A-Za-z0-9_-] and must not be empty, got %q", ErrInvalidVolumeKey, key.Label)
+	}
+	if len(key.Label) > LinodeVolumeLabelLength {
+		return fmt.Errorf("%w: label is longer than %d characters", ErrInvalidVolumeKey, LinodeVolumeLabelLength)
+	}
+	return nil
+}
+
+// GetVolumeID returns the ID of the volume in the Linode API.
 func (key *LinodeVolumeKey) GetVolumeID() int {
 	return key.VolumeID
 }
 
+// GetVolumeLabel returns the label of the volume, as stored in the key.
 func (key *LinodeVolumeKey) GetVolumeLabel() string {
 	return key.Label
 }
 
+// GetNormalizedLabel returns the label truncated to
+// [LinodeVolumeLabelLength] characters.
 func (key *LinodeVolumeKey) GetNormalizedLabel() string {
 	label := key.Label
 	if len(label) > LinodeVolumeLabelLength {
@@ -110,6 +177,8 @@ func (key *LinodeVolumeKey) GetNormalizedLabel() string {
 	return label
 }
 
+// GetNormalizedLabelWithPrefix returns prefix followed by the label,
+// truncated to [LinodeVolumeLabelLength] characters.
 func (key *LinodeVolumeKey) GetNormalizedLabelWithPrefix(prefix string) string {
 	label := prefix + key.GetNormalizedLabel()
 	if len(label) > LinodeVolumeLabelLength {
@@ -118,6 +187,8 @@ func (key *LinodeVolumeKey) GetNormalizedLabelWithPrefix(prefix string) string {
 	return label
 }
 
+// GetVolumeKey returns the key in its "<id>-<label>" string form, with the
+// label truncated to [LinodeVolumeLabelLength] characters.
 func (key *LinodeVolumeKey) GetVolumeKey() string {
 	volumeName := key.GetNormalizedLabel()
 	return fmt.Sprintf("%d-%s", key.VolumeID, volumeName)
@@ -1,7 +1,10 @@
 package linodevolumes
 
 import (
+	"errors"
 	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
 )
 
 func Test_hashStringToInt(t *testing.T) {
@@ -334,3 +337,124 @@ func TestGetVolumeKey(t *testing.T) {
 		})
 	}
 }
+
+func TestVolumeHandle(t *testing.T) {
+	handle := VolumeHandle(123, "pvc-0a1b2c3d")
+	if handle != "123-pvc-0a1b2c3d" {
+		t.Errorf("Expected '123-pvc-0a1b2c3d', got '%s'", handle)
+	}
+	if id := VolumeIDFromHandle(handle); id != 123 {
+		t.Errorf("Expected volume ID 123, got %d", id)
+	}
+}
+
+func TestVolumeIDFromHandle(t *testing.T) {
+	testCases := []struct {
+		name     string
+		handle   string
+		expected int
+	}{
+		{
+			name:     "Volume key",
+			handle:   "123-test-volume",
+			expected: 123,
+		},
+		{
+			name:     "Not a volume key",
+			handle:   "12345",
+			expected: hashStringToInt("12345"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := VolumeIDFromHandle(tc.handle); result != tc.expected {
+				t.Errorf("Expected %d, got %d", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestValidateVolumeHandle(t *testing.T) {
+	testCases := []struct {
+		name    string
+		handle  string
+		wantErr bool
+	}{
+		{
+			name:   "Valid handle",
+			handle: "123-pvc-0a1b2c3d",
+		},
+		{
+			name:    "Missing label",
+			handle:  "123",
+			wantErr: true,
+		},
+		{
+			name:    "Empty label",
+			handle:  "123-",
+			wantErr: true,
+		},
+		{
+			name:    "Non-numeric ID",
+			handle:  "abc-volume",
+			wantErr: true,
+		},
+		{
+			name:    "Zero ID",
+			handle:  "0-volume",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid label characters",
+			handle:  "123-my volume",
+			wantErr: true,
+		},
+		{
+			name:    "Label too long",
+			handle:  "123-this-label-is-definitely-longer-than-32-characters",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateVolumeHandle(tc.handle)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("ValidateVolumeHandle() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidVolumeKey) {
+				t.Errorf("Expected error to wrap ErrInvalidVolumeKey, got %v", err)
+			}
+		})
+	}
+}
+
+// BenchmarkParseLinodeVolumeKey parses the volume key of each request.
+func BenchmarkParseLinodeVolumeKey(b *testing.B) {
+	b.ReportAllocs()
+	for range b.N {
+		if _, err := ParseLinodeVolumeKey("1234567-pvc0b5a1b8e6c1d4b589a7d1f"); err != nil {
+			b.Fatalf("ParseLinodeVolumeKey() error = %v", err)
+		}
+	}
+}
+
+// BenchmarkVolumeIdAsInt gets the volume ID of requests with a volume key,
+// and with a legacy volume ID that is hashed.
+func BenchmarkVolumeIdAsInt(b *testing.B) {
+	for name, handle := range map[string]string{
+		"Key":    "1234567-pvc0b5a1b8e6c1d4b589a7d1f",
+		"Legacy": "pvc-0b5a1b8e-6c1d-4b58-9a7d-1f2e3d4c5b6a",
+	} {
+		b.Run(name, func(b *testing.B) {
+			req := &csi.NodeStageVolumeRequest{VolumeId: handle}
+			b.ReportAllocs()
+			for range b.N {
+				if _, err := VolumeIdAsInt("NodeStageVolume", req); err != nil {
+					b.Fatalf("VolumeIdAsInt() error = %v", err)
+				}
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package logger
 
 import (
 	"context"
+	"strings"
 
 	"github.com/go-logr/logr"
 	"github.com/google/uuid"
@@ -13,6 +14,10 @@ type LoggerKey struct{}
 
 type Logger struct {
 	Klogr logr.Logger
+
+	// component is the component of the driver the logs are about, whose
+	// verbosity can be set apart, or empty.
+	component string
 }
 
 // NewLogger creates a new Logger instance with a klogr logger.
@@ -27,7 +32,8 @@ func NewLogger(ctx context.Context) *Logger {
 func (l *Logger) WithMethod(method string) (*Logger, context.Context, func()) {
 	traceID := uuid.New().String()
 	newLogger := &Logger{
-		Klogr: klog.NewKlogr().WithValues("method", method, "traceID", traceID),
+		Klogr:     klog.NewKlogr().WithValues("method", method, "traceID", traceID),
+		component: l.component,
 	}
 	ctx := context.WithValue(context.Background(), LoggerKey{}, newLogger)
 
@@ -38,8 +44,22 @@ func (l *Logger) WithMethod(method string) (*Logger, context.Context, func()) {
 	}
 }
 
-// V returns a logr.Logger with the specified verbosity level.
+// WithComponent returns a new Logger logging about component, at its
+// verbosity if set apart with [SetComponentVerbosity].
+func (l *Logger) WithComponent(component string) *Logger {
+	return &Logger{Klogr: l.Klogr, component: component}
+}
+
+// V returns a logr.Logger with the specified verbosity level, enabled if the
+// verbosity of the component of the logger, or the one set with -v, is at
+// least level.
 func (l *Logger) V(level int) logr.Logger {
+	if verbosity, ok := ComponentVerbosity(l.component); ok {
+		if level > verbosity {
+			return logr.Discard()
+		}
+		return l.Klogr
+	}
 	return l.Klogr.V(level)
 }
 
@@ -56,8 +76,24 @@ func GetLogger(ctx context.Context) *Logger {
 	return NewLogger(ctx)
 }
 
+// grpcComponents are the components logging the requests of the CSI
+// services, by the prefix of their methods.
+var grpcComponents = map[string]string{
+	"/csi.v1.Controller/": ComponentController,
+	"/csi.v1.Node/":       ComponentNode,
+}
+
+// LogGRPC logs the gRPC requests and their responses, and passes the handler
+// a logger of the component of the request, if any.
 func LogGRPC(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 	logger := GetLogger(ctx)
+	for prefix, component := range grpcComponents {
+		if strings.HasPrefix(info.FullMethod, prefix) {
+			logger = logger.WithComponent(component)
+			ctx = context.WithValue(ctx, LoggerKey{}, logger)
+			break
+		}
+	}
 	logger.V(3).Info("GRPC call", "method", info.FullMethod)
 	logger.V(5).Info("GRPC request", "request", req)
 	resp, err := handler(ctx, req)
@@ -0,0 +1,184 @@
+package logger
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Components of the driver whose verbosity can be set apart from the
+// verbosity of the other logs, set with -v.
+const (
+	// ComponentController logs the requests of the CSI controller service.
+	ComponentController = "controller"
+	// ComponentNode logs the requests of the CSI node service.
+	ComponentNode = "node"
+	// ComponentLinodeClient logs the requests to the Linode API.
+	ComponentLinodeClient = "linode-client"
+	// ComponentCryptsetup logs the cryptsetup operations on LUKS volumes.
+	ComponentCryptsetup = "cryptsetup"
+)
+
+// Components lists the components whose verbosity can be set.
+var Components = []string{ComponentController, ComponentNode, ComponentLinodeClient, ComponentCryptsetup}
+
+var (
+	componentVerbosityMu sync.RWMutex
+	// componentVerbosity is the verbosity of the components it has, which
+	// log at it instead of at the verbosity set with -v.
+	componentVerbosity = map[string]int{}
+)
+
+// ComponentVerbosity returns the verbosity component logs at, if set apart
+// from the verbosity set with -v.
+func ComponentVerbosity(component string) (int, bool) {
+	componentVerbosityMu.RLock()
+	defer componentVerbosityMu.RUnlock()
+	level, ok := componentVerbosity[component]
+	return level, ok
+}
+
+// SetComponentVerbosity sets the verbosity component logs at, or makes it
+// log at the verbosity set with -v again if level is negative.
+func SetComponentVerbosity(component string, level int) error {
+	if !slices.Contains(Components, component) {
+		return fmt.Errorf("unknown log component %q, expected one of %s", component, strings.Join(Components, ", "))
+	}
+
+	componentVerbosityMu.Lock()
+	defer componentVerbosityMu.Unlock()
+	if level < 0 {
+		delete(componentVerbosity, component)
+	} else {
+		componentVerbosity[component] = level
+	}
+	return nil
+}
+
+// ParseComponentVerbosity parses a comma-separated list of component=level
+// pairs, e.g. "controller=4,linode-client=6".
+func ParseComponentVerbosity(spec string) (map[string]int, error) {
+	levels := map[string]int{}
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		component, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid log verbosity %q, expected component=level", pair)
+		}
+		component = strings.TrimSpace(component)
+		if !slices.Contains(Components, component) {
+			return nil, fmt.Errorf("unknown log component %q, expected one of %s", component, strings.Join(Components, ", "))
+		}
+		level, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || level < 0 {
+			return nil, fmt.Errorf("invalid log verbosity %q of component %s", value, component)
+		}
+		levels[component] = level
+	}
+	return levels, nil
+}
+
+// SetComponentVerbosities sets the verbosity of the components listed in
+// spec, as parsed by [ParseComponentVerbosity].
+func SetComponentVerbosities(spec string) error {
+	levels, err := ParseComponentVerbosity(spec)
+	if err != nil {
+		return err
+	}
+	for component, level := range levels {
+		if err := SetComponentVerbosity(component, level); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Verbosity is the verbosity of the logs, as returned and changed by
+// [VerbosityHandler].
+type Verbosity struct {
+	// Global is the verbosity set with -v.
+	Global string `json:"global"`
+	// Components is the verbosity of the components set apart from it.
+	Components map[string]int `json:"components"`
+}
+
+// CurrentVerbosity returns the verbosity of the logs.
+func CurrentVerbosity() Verbosity {
+	componentVerbosityMu.RLock()
+	defer componentVerbosityMu.RUnlock()
+	v := Verbosity{Components: maps.Clone(componentVerbosity)}
+	if f := flag.Lookup("v"); f != nil {
+		v.Global = f.Value.String()
+	}
+	return v
+}
+
+// SetGlobalVerbosity sets the verbosity set with -v, which klog must have
+// registered.
+func SetGlobalVerbosity(level int) error {
+	f := flag.Lookup("v")
+	if f == nil {
+		return fmt.Errorf("the -v flag of klog is not registered")
+	}
+	return f.Value.Set(strconv.Itoa(level))
+}
+
+// VerbosityHandler returns the verbosity of the logs as JSON on GET, and
+// changes it on PUT or POST with the query parameters v, the global
+// verbosity, and component and level, the verbosity of a component, e.g.
+// "?component=node&level=6". A negative level makes the component log at the
+// global verbosity again.
+//
+// The handler does not authenticate its callers: it must only be served on
+// the loopback interface.
+func VerbosityHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			if err := setVerbosity(r); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(CurrentVerbosity())
+	})
+}
+
+func setVerbosity(r *http.Request) error {
+	query := r.URL.Query()
+	if v := query.Get("v"); v != "" {
+		level, err := strconv.Atoi(v)
+		if err != nil || level < 0 {
+			return fmt.Errorf("invalid verbosity %q", v)
+		}
+		if err := SetGlobalVerbosity(level); err != nil {
+			return err
+		}
+	}
+	if component := query.Get("component"); component != "" {
+		level, err := strconv.Atoi(query.Get("level"))
+		if err != nil {
+			return fmt.Errorf("invalid verbosity %q of component %s", query.Get("level"), component)
+		}
+		if err := SetComponentVerbosity(component, level); err != nil {
+			return err
+		}
+	}
+	return nil
+}
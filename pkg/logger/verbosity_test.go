@@ -0,0 +1,139 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc"
+	"k8s.io/klog/v2"
+)
+
+func TestParseComponentVerbosity(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    map[string]int
+		wantErr bool
+	}{
+		{spec: "", want: map[string]int{}},
+		{spec: "controller=4, linode-client=6", want: map[string]int{ComponentController: 4, ComponentLinodeClient: 6}},
+		{spec: "node=2,", want: map[string]int{ComponentNode: 2}},
+		{spec: "node", wantErr: true},
+		{spec: "mounter=4", wantErr: true},
+		{spec: "node=-1", wantErr: true},
+		{spec: "node=high", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseComponentVerbosity(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseComponentVerbosity(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && len(got) != len(tt.want) {
+			t.Errorf("ParseComponentVerbosity(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+		for component, level := range tt.want {
+			if got[component] != level {
+				t.Errorf("ParseComponentVerbosity(%q) = %v, want %v", tt.spec, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestComponentVerbosity(t *testing.T) {
+	defer func() {
+		for _, component := range Components {
+			_ = SetComponentVerbosity(component, -1)
+		}
+	}()
+
+	log := NewLogger(context.Background()).WithComponent(ComponentCryptsetup)
+	if log.V(6).Enabled() {
+		t.Fatal("V(6) enabled at the default verbosity")
+	}
+	if err := SetComponentVerbosity(ComponentCryptsetup, 6); err != nil {
+		t.Fatalf("SetComponentVerbosity() error = %v", err)
+	}
+	if !log.V(6).Enabled() || log.V(7).Enabled() {
+		t.Error("V(6) not enabled, or V(7) enabled, at verbosity 6")
+	}
+	// Kept by the loggers of the methods, and only for the component
+	method, _, _ := log.WithMethod("NodeStageVolume")
+	if !method.V(6).Enabled() {
+		t.Error("V(6) not enabled for the logger of a method of the component")
+	}
+	if NewLogger(context.Background()).WithComponent(ComponentNode).V(6).Enabled() {
+		t.Error("V(6) enabled for another component")
+	}
+
+	if err := SetComponentVerbosity(ComponentCryptsetup, -1); err != nil {
+		t.Fatalf("SetComponentVerbosity() error = %v", err)
+	}
+	if log.V(6).Enabled() {
+		t.Error("V(6) enabled once the verbosity of the component was unset")
+	}
+	if err := SetComponentVerbosity("mounter", 4); err == nil {
+		t.Error("SetComponentVerbosity() of an unknown component succeeded")
+	}
+
+	// The requests of the CSI services are logged by their component
+	for method, want := range map[string]string{
+		"/csi.v1.Controller/CreateVolume": ComponentController,
+		"/csi.v1.Node/NodeStageVolume":    ComponentNode,
+		"/csi.v1.Identity/Probe":          "",
+	} {
+		_, _ = LogGRPC(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, _ interface{}) (interface{}, error) {
+			if got := GetLogger(ctx).component; got != want {
+				t.Errorf("component of %s = %q, want %q", method, got, want)
+			}
+			return nil, nil
+		})
+	}
+}
+
+func TestVerbosityHandler(t *testing.T) {
+	if flag.Lookup("v") == nil {
+		klog.InitFlags(nil)
+	}
+	defer func() {
+		_ = SetGlobalVerbosity(0)
+		_ = SetComponentVerbosity(ComponentNode, -1)
+	}()
+
+	handler := VerbosityHandler()
+	do := func(method, target string) (int, Verbosity) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+		var v Verbosity
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&v); err != nil {
+				t.Fatalf("decode %s %s: %v", method, target, err)
+			}
+		}
+		return rec.Code, v
+	}
+
+	if code, v := do(http.MethodPut, "/verbosity?v=3&component=node&level=6"); code != http.StatusOK || v.Global != "3" || v.Components[ComponentNode] != 6 {
+		t.Errorf("PUT = %d, %+v, want global verbosity 3 and node 6", code, v)
+	}
+	if !klog.V(3).Enabled() || klog.V(4).Enabled() {
+		t.Error("global verbosity not changed to 3")
+	}
+	if code, v := do(http.MethodGet, "/verbosity"); code != http.StatusOK || v.Global != "3" || v.Components[ComponentNode] != 6 {
+		t.Errorf("GET = %d, %+v, want global verbosity 3 and node 6", code, v)
+	}
+	if code, v := do(http.MethodPost, "/verbosity?component=node&level=-1"); code != http.StatusOK || len(v.Components) != 0 {
+		t.Errorf("POST = %d, %+v, want no component verbosity", code, v)
+	}
+	for _, target := range []string{"/verbosity?v=high", "/verbosity?component=mounter&level=4", "/verbosity?component=node"} {
+		if code, _ := do(http.MethodPut, target); code != http.StatusBadRequest {
+			t.Errorf("PUT %s = %d, want %d", target, code, http.StatusBadRequest)
+		}
+	}
+	if code, _ := do(http.MethodDelete, "/verbosity"); code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE = %d, want %d", code, http.StatusMethodNotAllowed)
+	}
+}
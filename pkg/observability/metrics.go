@@ -1,9 +1,15 @@
 package observability
 
 import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // Constants representing success or failure states as strings for the metrics labels.
@@ -12,200 +18,435 @@ const (
 	Failed    = "false" // Represents failed operation
 )
 
-// Metrics definitions for different CSI driver operations
+// DefaultMetricsNamespace is the prefix of the names of the metrics of the
+// driver, unless another one is set with [ConfigureMetrics].
+const DefaultMetricsNamespace = "csi"
 
-// NodePublishTotal counts the total number of NodePublishVolume calls.
-// It uses a label "functionStatus" to differentiate between successful and failed calls.
+// Metrics definitions for different CSI driver operations. They are created
+// by [ConfigureMetrics], with the names listed in [metricDefinitions].
 var (
-	NodePublishTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "csi_node_publish_total",
-			Help: "Total number of NodePublishVolume calls"},
-		[]string{"functionStatus"},
-	)
+	// NodePublishTotal counts the total number of NodePublishVolume calls.
+	// It uses a label "functionStatus" to differentiate between successful and failed calls.
+	NodePublishTotal *prometheus.CounterVec
 
 	// NodePublishDuration tracks the duration of NodePublishVolume calls.
 	// It also uses a "functionStatus" label to capture whether the call succeeded or failed.
-	NodePublishDuration = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "csi_node_publish_duration_seconds",
-			Help:    "Duration of NodePublishVolume calls",
-			Buckets: prometheus.DefBuckets,
-		},
-		[]string{"functionStatus"},
-	)
+	NodePublishDuration *prometheus.HistogramVec
 
 	// NodeUnpublishTotal counts the total number of NodeUnpublishVolume calls.
-	NodeUnpublishTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "csi_node_unpublish_total",
-			Help: "Total number of NodeUnpublishVolume calls",
-		},
-		[]string{"functionStatus"},
-	)
+	NodeUnpublishTotal *prometheus.CounterVec
 
 	// NodeUnpublishDuration tracks the duration of NodeUnpublishVolume calls.
-	NodeUnpublishDuration = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "csi_node_unpublish_duration_seconds",
-			Help:    "Duration of NodeUnpublishVolume calls",
-			Buckets: prometheus.DefBuckets,
-		},
-		[]string{"functionStatus"},
-	)
+	NodeUnpublishDuration *prometheus.HistogramVec
 
 	// NodeStageVolumeTotal counts the total number of NodeStageVolume calls.
-	NodeStageVolumeTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "csi_node_stage_volume_total",
-			Help: "Total number of NodeStageVolume calls",
-		},
-		[]string{"functionStatus"},
-	)
+	NodeStageVolumeTotal *prometheus.CounterVec
 
 	// NodeStageVolumeDuration tracks the duration of NodeStageVolume calls.
-	NodeStageVolumeDuration = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "csi_node_stage_volume_duration_seconds",
-			Help:    "Duration of NodeStageVolume calls",
-			Buckets: prometheus.DefBuckets,
-		},
-		[]string{"functionStatus"},
-	)
+	NodeStageVolumeDuration *prometheus.HistogramVec
 
 	// NodeUnstageVolumeTotal counts the total number of NodeUnstageVolume calls.
-	NodeUnstageVolumeTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "csi_node_unstage_volume_total",
-			Help: "Total number of NodeUnstageVolume calls",
-		},
-		[]string{"functionStatus"},
-	)
+	NodeUnstageVolumeTotal *prometheus.CounterVec
 
 	// NodeUnstageVolumeDuration tracks the duration of NodeUnstageVolume calls.
-	NodeUnstageVolumeDuration = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "csi_node_unstage_volume_duration_seconds",
-			Help:    "Duration of NodeUnstageVolume calls",
-			Buckets: prometheus.DefBuckets,
-		},
-		[]string{"functionStatus"},
-	)
+	NodeUnstageVolumeDuration *prometheus.HistogramVec
 
 	// NodeExpandTotal counts the total number of NodeExpandVolume calls.
-	NodeExpandTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "csi_node_expand_total",
-			Help: "Total number of NodeExpandVolume calls",
-		},
-		[]string{"functionStatus"},
-	)
+	NodeExpandTotal *prometheus.CounterVec
 
 	// NodeExpandDuration tracks the duration of NodeExpandVolume calls.
-	NodeExpandDuration = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "csi_node_expand_duration_seconds",
-			Help:    "Duration of NodeExpandVolume calls",
-			Buckets: prometheus.DefBuckets,
-		},
-		[]string{"functionStatus"},
-	)
+	NodeExpandDuration *prometheus.HistogramVec
 )
 
 var (
 	// ControllerCreateVolumeTotal counts the total number of create volume calls.
-	ControllerCreateVolumeTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "csi_controller_create_volume_total",
-			Help: "Total number of Create Volume calls",
-		},
-		[]string{"functionStatus"},
-	)
+	ControllerCreateVolumeTotal *prometheus.CounterVec
 
 	// ControllerCreateVolumeDuration tracks the duration of create volume calls.
-	ControllerCreateVolumeDuration = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name: "csi_controller_create_volume_duration_seconds",
-			Help: "Duration of Create Volume calls",
-		},
-		[]string{"functionStatus"},
-	)
+	ControllerCreateVolumeDuration *prometheus.HistogramVec
 
 	// ControllerDeleteVolumeTotal counts the total number of delete volume calls.
-	ControllerDeleteVolumeTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "csi_controller_delete_volume_total",
-			Help: "Total number of Delete Volume calls",
-		},
-		[]string{"functionStatus"},
-	)
+	ControllerDeleteVolumeTotal *prometheus.CounterVec
 
 	// ControllerDeleteVolumeDuration tracks the duration of delete volume calls.
-	ControllerDeleteVolumeDuration = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name: "csi_controller_delete_volume_duration_seconds",
-			Help: "Duration of Delete Volume calls",
-		},
-		[]string{"functionStatus"},
-	)
+	ControllerDeleteVolumeDuration *prometheus.HistogramVec
 
 	// ControllerPublishVolumeTotal counts the total number of publish volume calls.
-	ControllerPublishVolumeTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "csi_controller_publish_volume_total",
-			Help: "Total number of Publish Volume calls",
-		},
-		[]string{"functionStatus"},
-	)
+	ControllerPublishVolumeTotal *prometheus.CounterVec
 
 	// ControllerPublishVolumeDuration tracks the duration of publish volume calls.
-	ControllerPublishVolumeDuration = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name: "csi_controller_publish_volume_duration_seconds",
-			Help: "Duration of Publish Volume calls",
-		},
-		[]string{"functionStatus"},
-	)
+	ControllerPublishVolumeDuration *prometheus.HistogramVec
 
 	// ControllerUnpublishVolumeTotal counts the total number of unpublish volume calls.
-	ControllerUnpublishVolumeTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "csi_controller_unpublish_volume_total",
-			Help: "Total number of Unpublish Volume calls",
-		},
-		[]string{"functionStatus"},
-	)
+	ControllerUnpublishVolumeTotal *prometheus.CounterVec
 
 	// ControllerUnpublishVolumeDuration tracks the duration of unpublish volume calls.
-	ControllerUnpublishVolumeDuration = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name: "csi_controller_unpublish_volume_duration_seconds",
-			Help: "Duration of Unpublish Volume calls",
-		},
-		[]string{"functionStatus"},
-	)
+	ControllerUnpublishVolumeDuration *prometheus.HistogramVec
 )
 
-// The init function registers all the defined Prometheus metrics.
+var (
+	// NodeDependencyAvailable reports whether the tools and kernel modules the
+	// node plugin relies on were found when it started (1) or not (0).
+	NodeDependencyAvailable *prometheus.GaugeVec
+
+	// FeatureUsageTotal counts the volumes provisioned with each feature of the
+	// driver (encryption, cloning, block mode, file system type), when feature
+	// telemetry is enabled. It uses a "feature" label for the feature.
+	FeatureUsageTotal *prometheus.CounterVec
+
+	// LegacyVolumeIDTotal counts the requests whose volume ID is not a volume key,
+	// and was hashed into a Linode volume ID. It uses a "method" label for the
+	// CSI method of the request.
+	LegacyVolumeIDTotal *prometheus.CounterVec
+
+	// AccountVolumeLimitExceededTotal counts the CreateVolume calls refused
+	// because the account already had as many volumes as its configured limit.
+	AccountVolumeLimitExceededTotal prometheus.Counter
+
+	// AttachTimeoutTotal counts the ControllerPublishVolume calls that timed
+	// out waiting for the volume to be attached to the instance.
+	AttachTimeoutTotal prometheus.Counter
+
+	// APIMaintenanceTotal counts the requests refused by the Linode API
+	// because it was in read-only maintenance mode.
+	APIMaintenanceTotal prometheus.Counter
+
+	// NodeFormatTotal counts the devices probed before being formatted and
+	// mounted by NodeStageVolume. It uses a "result" label: "formatted" for
+	// blank devices formatted with mkfs, "mounted" for devices already holding
+	// the expected file system, "mismatch_mounted" for devices mounted with
+	// the other file system they hold, and "refused" for devices holding
+	// other data.
+	NodeFormatTotal *prometheus.CounterVec
+
+	// ValidationFailuresTotal counts the requests failing the validations
+	// subject to the enforcement mode of the driver. It uses a "validation"
+	// label for the validation, and a "mode" label, "enforce" if the
+	// requests were refused or "warn" if they were only logged.
+	ValidationFailuresTotal *prometheus.CounterVec
+
+	// NodeOrphansTotal counts the staging mounts and LUKS mappings of
+	// detached volumes found by the node plugin at startup. It uses a
+	// "kind" label, "staging_mount" or "luks_mapping", and a "result"
+	// label, "reported", "cleaned" or "failed".
+	NodeOrphansTotal *prometheus.CounterVec
+
+	// NodeMountRecoveriesTotal counts the mounts of volumes that vanished
+	// while they were in use, mounted again by the mount watchdog of the
+	// node plugin. It uses a "kind" label, "staging" or "target", and a
+	// "result" label, "restored" or "failed".
+	NodeMountRecoveriesTotal *prometheus.CounterVec
+
+	// NodeDeviceFlapsTotal counts the /dev/disk/by-id symlinks of the
+	// volumes staged by the node plugin that flapped, with the device flap
+	// soak test enabled. It uses a "kind" label: "changed" when the symlink
+	// resolves to another device, or "missing" when it no longer resolves.
+	NodeDeviceFlapsTotal *prometheus.CounterVec
+
+	// VolumeCreateToMountDuration tracks the time from the creation of a
+	// volume by the Linode API to its first mount by NodeStageVolume. It
+	// uses a "region" label for the region of the volume.
+	VolumeCreateToMountDuration *prometheus.HistogramVec
+
+	// VolumeAttachToMountDuration tracks the time from the attachment of a
+	// volume by ControllerPublishVolume to its mount by NodeStageVolume. It
+	// uses a "region" label for the region of the volume.
+	VolumeAttachToMountDuration *prometheus.HistogramVec
+
+	// VolumeAttachmentLimit reports the number of volumes that can be
+	// attached to each node, as computed by NodeGetInfo and
+	// ControllerPublishVolume from the memory and disks of its instance. It
+	// uses a "node_id" label for the Linode ID of the instance.
+	VolumeAttachmentLimit *prometheus.GaugeVec
+
+	// PersistedAttachmentsTotal counts the volumes found by
+	// ControllerPublishVolume to be attached with PersistAcrossBoots. It
+	// uses a "result" label, "reported", "fixed" or "failed".
+	PersistedAttachmentsTotal *prometheus.CounterVec
+
+	// PluginConditionMet reports whether the readiness conditions of the
+	// plugin reported by the identity service are met, 1, or not, 0. It
+	// uses a "condition" label for the name of the condition.
+	PluginConditionMet *prometheus.GaugeVec
+
+	// ListVolumesResponseBytes observes the size of the ListVolumes
+	// responses, which are bounded to 3 MiB.
+	ListVolumesResponseBytes prometheus.Histogram
+
+	// NodeMetadataDMIFallbackTotal counts the attempts to read the ID of
+	// the instance from its DMI serial number, when it was not written by
+	// the init container. It uses a "result" label: "found", "not_found",
+	// "permission_denied", "read_error" or "invalid".
+	NodeMetadataDMIFallbackTotal *prometheus.CounterVec
+
+	// RPCTimeoutsTotal counts the requests that failed after exceeding the
+	// maximum duration of their method set by the driver. It uses a
+	// "method" label, and a "phase" label for the step they were in.
+	RPCTimeoutsTotal *prometheus.CounterVec
+
+	// VolumePollsActive is the number of volumes the controller is polling
+	// the Linode API for, and VolumeWaitersActive the number of requests
+	// waiting for these polls. They use a "kind" label: "active", "attach"
+	// or "detach".
+	VolumePollsActive   *prometheus.GaugeVec
+	VolumeWaitersActive *prometheus.GaugeVec
+
+	// LUKSHeaderBackupsTotal counts the backups of the LUKS headers of the
+	// volumes formatted by the node plugin. It uses a "result" label:
+	// "stored" or "failed".
+	LUKSHeaderBackupsTotal *prometheus.CounterVec
+
+	// AccountEventsTotal counts the volume events of the Linode account fed
+	// to the controller. It uses a "source" label: "poll" for the events
+	// polled from the events API, or "push" for those pushed to it.
+	AccountEventsTotal *prometheus.CounterVec
+
+	// IOThrottlesTotal counts the volumes published by the node plugin with
+	// throttling parameters. It uses a "result" label: "applied",
+	// "unsupported" when the node or the pod cannot be throttled, or
+	// "failed".
+	IOThrottlesTotal *prometheus.CounterVec
+
+	// DeletedNodeDetachesTotal counts the volumes the controller detached
+	// from deleted Kubernetes nodes. It uses a "result" label: "detached" or
+	// "failed".
+	DeletedNodeDetachesTotal *prometheus.CounterVec
+
+	// CordonedNodeDetachesTotal counts the read-only volumes the controller
+	// detached from cordoned Kubernetes nodes. It uses a "result" label:
+	// "detached" or "failed".
+	CordonedNodeDetachesTotal *prometheus.CounterVec
+
+	// VolumeSizeRoundUpsTotal counts the volumes created or expanded larger
+	// than the capacity they were requested with. It uses a "method" label,
+	// and a "reason" label: "minimum" when rounded up to the minimum volume
+	// size, or "gib" when rounded up to a whole number of GiB.
+	VolumeSizeRoundUpsTotal *prometheus.CounterVec
+
+	// HalfCreatedVolumesTotal counts the volumes the controller found at
+	// startup that CreateVolume created but never saw active. It uses an
+	// "action" label: "reported", "finished", "deleted" or "failed".
+	HalfCreatedVolumesTotal *prometheus.CounterVec
+
+	// ShardedRequestsTotal counts the controller requests of volumes when
+	// the replicas of the controller share the volumes. It uses a "method"
+	// label, and a "result" label: "local" when handled by the replica
+	// owning the volume, "proxied" when forwarded to it, or "rejected" when
+	// the owner has no address.
+	ShardedRequestsTotal *prometheus.CounterVec
+
+	// TokenValid reports whether the Linode API accepted the token of the
+	// controller when it was last checked: 1 if it did, or 0 if it was
+	// rejected. It has no labels, and is only set once the token is checked.
+	TokenValid *prometheus.GaugeVec
+
+	// TokenExpirySeconds reports the number of seconds until the token of
+	// the controller expires, as of its last check. It has no labels, and is
+	// only set while the expiry of the token is known.
+	TokenExpirySeconds *prometheus.GaugeVec
+)
+
+// Metrics of the inventory exported by the csi-linode-exporter command. They
+// use a "region" label for the region of the volumes.
+var (
+	// InventoryVolumes reports the number of volumes of the account.
+	InventoryVolumes *prometheus.GaugeVec
+
+	// InventoryVolumeSize reports the total size of the volumes of the
+	// account, in GB.
+	InventoryVolumeSize *prometheus.GaugeVec
+
+	// InventoryOrphanCandidates reports the number of volumes of the
+	// account attached to no instance, and not updated for a while.
+	InventoryOrphanCandidates *prometheus.GaugeVec
+
+	// InventoryLastCollected reports when the inventory was last collected,
+	// in seconds since the epoch.
+	InventoryLastCollected prometheus.Gauge
+)
+
+// lifecycleBuckets are the buckets of the durations spanning several
+// requests, from one second to over half an hour.
+var lifecycleBuckets = prometheus.ExponentialBuckets(1, 2, 12)
+
+// metricDefinition describes a metric of the driver. Its name does not
+// include the namespace.
+type metricDefinition struct {
+	name string
+
+	// create creates the metric in namespace, and assigns it to the
+	// variable it is exported as.
+	create func(namespace string) prometheus.Collector
+}
+
+// metricDefinitions lists all the metrics of the driver.
+var metricDefinitions = []metricDefinition{
+	counterVec(&NodePublishTotal, "node_publish_total", "Total number of NodePublishVolume calls", "functionStatus"),
+	histogramVec(&NodePublishDuration, "node_publish_duration_seconds", "Duration of NodePublishVolume calls", "functionStatus"),
+	counterVec(&NodeUnpublishTotal, "node_unpublish_total", "Total number of NodeUnpublishVolume calls", "functionStatus"),
+	histogramVec(&NodeUnpublishDuration, "node_unpublish_duration_seconds", "Duration of NodeUnpublishVolume calls", "functionStatus"),
+	counterVec(&NodeStageVolumeTotal, "node_stage_volume_total", "Total number of NodeStageVolume calls", "functionStatus"),
+	histogramVec(&NodeStageVolumeDuration, "node_stage_volume_duration_seconds", "Duration of NodeStageVolume calls", "functionStatus"),
+	counterVec(&NodeUnstageVolumeTotal, "node_unstage_volume_total", "Total number of NodeUnstageVolume calls", "functionStatus"),
+	histogramVec(&NodeUnstageVolumeDuration, "node_unstage_volume_duration_seconds", "Duration of NodeUnstageVolume calls", "functionStatus"),
+	counterVec(&NodeExpandTotal, "node_expand_total", "Total number of NodeExpandVolume calls", "functionStatus"),
+	histogramVec(&NodeExpandDuration, "node_expand_duration_seconds", "Duration of NodeExpandVolume calls", "functionStatus"),
+
+	counterVec(&ControllerCreateVolumeTotal, "controller_create_volume_total", "Total number of Create Volume calls", "functionStatus"),
+	histogramVec(&ControllerCreateVolumeDuration, "controller_create_volume_duration_seconds", "Duration of Create Volume calls", "functionStatus"),
+	counterVec(&ControllerDeleteVolumeTotal, "controller_delete_volume_total", "Total number of Delete Volume calls", "functionStatus"),
+	histogramVec(&ControllerDeleteVolumeDuration, "controller_delete_volume_duration_seconds", "Duration of Delete Volume calls", "functionStatus"),
+	counterVec(&ControllerPublishVolumeTotal, "controller_publish_volume_total", "Total number of Publish Volume calls", "functionStatus"),
+	histogramVec(&ControllerPublishVolumeDuration, "controller_publish_volume_duration_seconds", "Duration of Publish Volume calls", "functionStatus"),
+	counterVec(&ControllerUnpublishVolumeTotal, "controller_unpublish_volume_total", "Total number of Unpublish Volume calls", "functionStatus"),
+	histogramVec(&ControllerUnpublishVolumeDuration, "controller_unpublish_volume_duration_seconds", "Duration of Unpublish Volume calls", "functionStatus"),
+
+	gaugeVec(&NodeDependencyAvailable, "node_dependency_available", "Whether a dependency of the node plugin is available", "dependency"),
+	counterVec(&FeatureUsageTotal, "feature_usage_total", "Total number of volumes provisioned with a feature of the driver", "feature"),
+	counterVec(&LegacyVolumeIDTotal, "legacy_volume_id_total", "Total number of requests with a volume ID that is not a volume key", "method"),
+	counter(&AccountVolumeLimitExceededTotal, "account_volume_limit_exceeded_total", "Total number of volumes not created because of the account volume limit"),
+	counter(&AttachTimeoutTotal, "attach_timeout_total", "Total number of volume attachments that timed out"),
+	counter(&APIMaintenanceTotal, "api_maintenance_total", "Total number of requests refused by the Linode API in maintenance mode"),
+	counterVec(&NodeFormatTotal, "node_format_total", "Total number of devices probed before being formatted and mounted", "result"),
+	counterVec(&ValidationFailuresTotal, "validation_failures_total", "Total number of requests failing a validation, enforced or not", "validation", "mode"),
+	counterVec(&NodeOrphansTotal, "node_orphans_total", "Total number of staging mounts and LUKS mappings of detached volumes found at startup", "kind", "result"),
+	histogramVecBuckets(&VolumeCreateToMountDuration, "volume_create_to_mount_seconds", "Time from the creation of volumes to their first mount", lifecycleBuckets, "region"),
+	histogramVecBuckets(&VolumeAttachToMountDuration, "volume_attach_to_mount_seconds", "Time from the attachment of volumes to their mount", lifecycleBuckets, "region"),
+	counterVec(&NodeMountRecoveriesTotal, "node_mount_recoveries_total", "Total number of vanished volume mounts mounted again by the mount watchdog", "kind", "result"),
+	counterVec(&NodeDeviceFlapsTotal, "node_device_flaps_total", "Total number of flaps of the devices of staged volumes", "kind"),
+	gaugeVec(&VolumeAttachmentLimit, "volume_attachment_limit", "Number of volumes that can be attached to a node", "node_id"),
+	counterVec(&PersistedAttachmentsTotal, "persisted_attachments_total", "Total number of volume attachments found to persist across boots", "result"),
+	gaugeVec(&PluginConditionMet, "plugin_condition_met", "Whether the readiness conditions of the plugin are met", "condition"),
+	histogram(&ListVolumesResponseBytes, "list_volumes_response_bytes", "Size of the ListVolumes responses", prometheus.ExponentialBuckets(1<<10, 4, 7)),
+	counterVec(&NodeMetadataDMIFallbackTotal, "node_metadata_dmi_fallback_total", "Total number of attempts to read the instance ID from the DMI serial number", "result"),
+	counterVec(&RPCTimeoutsTotal, "rpc_timeouts_total", "Total number of requests that exceeded the maximum duration of their method", "method", "phase"),
+	gaugeVec(&VolumePollsActive, "volume_polls_active", "Number of volumes the controller is polling the Linode API for", "kind"),
+	gaugeVec(&VolumeWaitersActive, "volume_waiters_active", "Number of requests waiting for a volume poll", "kind"),
+	counterVec(&LUKSHeaderBackupsTotal, "luks_header_backups_total", "Total number of backups of the LUKS headers of formatted volumes", "result"),
+	counterVec(&AccountEventsTotal, "account_events_total", "Total number of volume events of the account fed to the controller", "source"),
+	counterVec(&IOThrottlesTotal, "io_throttles_total", "Total number of published volumes with throttling parameters", "result"),
+	counterVec(&DeletedNodeDetachesTotal, "deleted_node_detaches_total", "Total number of volumes detached from deleted nodes", "result"),
+	counterVec(&CordonedNodeDetachesTotal, "cordoned_node_detaches_total", "Total number of read-only volumes detached from cordoned nodes", "result"),
+	counterVec(&VolumeSizeRoundUpsTotal, "volume_size_round_ups_total", "Total number of volumes created or expanded larger than requested", "method", "reason"),
+	counterVec(&HalfCreatedVolumesTotal, "half_created_volumes_total", "Total number of volumes created but never seen active found at startup", "action"),
+	counterVec(&ShardedRequestsTotal, "sharded_requests_total", "Total number of controller requests of volumes shared between the controller replicas", "method", "result"),
+	gaugeVec(&TokenValid, "token_valid", "Whether the Linode API accepted the token of the controller when last checked"),
+	gaugeVec(&TokenExpirySeconds, "token_expiry_seconds", "Number of seconds until the token of the controller expires"),
+
+	gaugeVec(&InventoryVolumes, "inventory_volumes", "Number of volumes of the account", "region"),
+	gaugeVec(&InventoryVolumeSize, "inventory_volume_size_gigabytes", "Total size of the volumes of the account", "region"),
+	gaugeVec(&InventoryOrphanCandidates, "inventory_orphan_candidate_volumes", "Number of volumes of the account attached to no instance and not updated for a while", "region"),
+	gauge(&InventoryLastCollected, "inventory_last_collected_timestamp_seconds", "Time the inventory was last collected"),
+}
+
+func counter(metric *prometheus.Counter, name, help string) metricDefinition {
+	return metricDefinition{name: name, create: func(namespace string) prometheus.Collector {
+		*metric = prometheus.NewCounter(prometheus.CounterOpts{Namespace: namespace, Name: name, Help: help})
+		return *metric
+	}}
+}
+
+func counterVec(metric **prometheus.CounterVec, name, help string, labels ...string) metricDefinition {
+	return metricDefinition{name: name, create: func(namespace string) prometheus.Collector {
+		*metric = prometheus.NewCounterVec(prometheus.CounterOpts{Namespace: namespace, Name: name, Help: help}, labels)
+		return *metric
+	}}
+}
+
+func gauge(metric *prometheus.Gauge, name, help string) metricDefinition {
+	return metricDefinition{name: name, create: func(namespace string) prometheus.Collector {
+		*metric = prometheus.NewGauge(prometheus.GaugeOpts{Namespace: namespace, Name: name, Help: help})
+		return *metric
+	}}
+}
+
+func histogram(metric *prometheus.Histogram, name, help string, buckets []float64) metricDefinition {
+	return metricDefinition{name: name, create: func(namespace string) prometheus.Collector {
+		*metric = prometheus.NewHistogram(prometheus.HistogramOpts{Namespace: namespace, Name: name, Help: help, Buckets: buckets})
+		return *metric
+	}}
+}
+
+func gaugeVec(metric **prometheus.GaugeVec, name, help string, labels ...string) metricDefinition {
+	return metricDefinition{name: name, create: func(namespace string) prometheus.Collector {
+		*metric = prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: namespace, Name: name, Help: help}, labels)
+		return *metric
+	}}
+}
+
+func histogramVec(metric **prometheus.HistogramVec, name, help string, labels ...string) metricDefinition {
+	return histogramVecBuckets(metric, name, help, prometheus.DefBuckets, labels...)
+}
+
+func histogramVecBuckets(metric **prometheus.HistogramVec, name, help string, buckets []float64, labels ...string) metricDefinition {
+	return metricDefinition{name: name, create: func(namespace string) prometheus.Collector {
+		*metric = prometheus.NewHistogramVec(prometheus.HistogramOpts{Namespace: namespace, Name: name, Help: help, Buckets: buckets}, labels)
+		return *metric
+	}}
+}
+
+// MetricsConfig configures the metrics exported by the driver.
+type MetricsConfig struct {
+	// Namespace prefixes the names of all the metrics, e.g.
+	// "csi_node_publish_total" in the default "csi" namespace. Drivers
+	// running side by side in one cluster can use different namespaces to
+	// tell their metrics apart. [DefaultMetricsNamespace] is used if empty.
+	Namespace string
+
+	// Disabled lists the metrics, named without their namespace (e.g.
+	// "node_publish_total"), that are not exported. They are still
+	// recorded, but never collected.
+	Disabled []string
+}
+
+// metricsNamespaceRegexp matches valid metric namespaces.
+var metricsNamespaceRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// registry holds the metrics exported by [MetricsHandler].
+var registry *prometheus.Registry
+
+// ConfigureMetrics creates the metrics of the driver as configured by cfg, and
+// replaces the ones exported by [MetricsHandler] with them. It must be called
+// before the metrics are recorded, as metrics recorded before are lost.
+func ConfigureMetrics(cfg MetricsConfig) error {
+	namespace := cfg.Namespace
+	if namespace == "" {
+		namespace = DefaultMetricsNamespace
+	}
+	if !metricsNamespaceRegexp.MatchString(namespace) {
+		return fmt.Errorf("invalid metrics namespace %q", namespace)
+	}
+	for _, name := range cfg.Disabled {
+		if !slices.ContainsFunc(metricDefinitions, func(def metricDefinition) bool { return def.name == name }) {
+			return fmt.Errorf("unknown metric %q", name)
+		}
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	for _, def := range metricDefinitions {
+		metric := def.create(namespace)
+		if slices.Contains(cfg.Disabled, def.name) {
+			continue
+		}
+		if err := reg.Register(metric); err != nil {
+			return fmt.Errorf("register metric %s: %w", def.name, err)
+		}
+	}
+	registry = reg
+	return nil
+}
+
+// MetricsHandler returns the HTTP handler exporting the metrics of the driver.
+func MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+}
+
+// The init function creates all the metrics in the default namespace.
 func init() {
-	prometheus.MustRegister(NodePublishTotal)
-	prometheus.MustRegister(NodePublishDuration)
-	prometheus.MustRegister(NodeUnpublishTotal)
-	prometheus.MustRegister(NodeUnpublishDuration)
-	prometheus.MustRegister(NodeStageVolumeTotal)
-	prometheus.MustRegister(NodeStageVolumeDuration)
-	prometheus.MustRegister(NodeUnstageVolumeTotal)
-	prometheus.MustRegister(NodeUnstageVolumeDuration)
-	prometheus.MustRegister(NodeExpandTotal)
-	prometheus.MustRegister(NodeExpandDuration)
-	prometheus.MustRegister(ControllerCreateVolumeTotal)
-	prometheus.MustRegister(ControllerCreateVolumeDuration)
-	prometheus.MustRegister(ControllerDeleteVolumeTotal)
-	prometheus.MustRegister(ControllerDeleteVolumeDuration)
-	prometheus.MustRegister(ControllerPublishVolumeTotal)
-	prometheus.MustRegister(ControllerPublishVolumeDuration)
-	prometheus.MustRegister(ControllerUnpublishVolumeTotal)
-	prometheus.MustRegister(ControllerUnpublishVolumeDuration)
+	if err := ConfigureMetrics(MetricsConfig{}); err != nil {
+		panic(err)
+	}
 }
 
 // RecordMetrics function is a helper to encapsulate metrics storage across function calls.
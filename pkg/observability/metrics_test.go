@@ -0,0 +1,84 @@
+package observability
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestConfigureMetrics(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         MetricsConfig
+		wantErr     bool
+		wantMetrics []string
+		wantMissing []string
+	}{
+		{
+			name:        "Default",
+			wantMetrics: []string{"csi_node_publish_total", "csi_legacy_volume_id_total", "go_goroutines"},
+		},
+		{
+			name:        "Namespace",
+			cfg:         MetricsConfig{Namespace: "csi_staging"},
+			wantMetrics: []string{"csi_staging_node_publish_total", "csi_staging_legacy_volume_id_total"},
+			wantMissing: []string{"csi_node_publish_total"},
+		},
+		{
+			name:        "Disabled metric",
+			cfg:         MetricsConfig{Disabled: []string{"legacy_volume_id_total"}},
+			wantMetrics: []string{"csi_node_publish_total"},
+			wantMissing: []string{"csi_legacy_volume_id_total"},
+		},
+		{
+			name:    "Unknown metric",
+			cfg:     MetricsConfig{Disabled: []string{"csi_node_publish_total"}},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid namespace",
+			cfg:     MetricsConfig{Namespace: "csi-staging"},
+			wantErr: true,
+		},
+	}
+
+	t.Cleanup(func() {
+		if err := ConfigureMetrics(MetricsConfig{}); err != nil {
+			t.Fatalf("ConfigureMetrics() error = %v", err)
+		}
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ConfigureMetrics(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ConfigureMetrics() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			// Vectors are only exported once they have a child
+			NodePublishTotal.WithLabelValues(Completed).Inc()
+			LegacyVolumeIDTotal.WithLabelValues("DeleteVolume").Inc()
+
+			rec := httptest.NewRecorder()
+			MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+			body, err := io.ReadAll(rec.Body)
+			if err != nil {
+				t.Fatalf("read metrics: %v", err)
+			}
+			for _, name := range tt.wantMetrics {
+				if !strings.Contains(string(body), "\n"+name) {
+					t.Errorf("metric %s is not exported", name)
+				}
+			}
+			for _, name := range tt.wantMissing {
+				if strings.Contains(string(body), "\n"+name+"{") {
+					t.Errorf("metric %s is exported", name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package observability
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// jsonSpan is how [jsonSpanExporter] writes a span.
+type jsonSpan struct {
+	Name          string            `json:"name"`
+	TraceID       string            `json:"traceID"`
+	SpanID        string            `json:"spanID"`
+	ParentSpanID  string            `json:"parentSpanID,omitempty"`
+	Start         time.Time         `json:"start"`
+	End           time.Time         `json:"end"`
+	Status        string            `json:"status"`
+	StatusMessage string            `json:"statusMessage,omitempty"`
+	Attributes    map[string]string `json:"attributes,omitempty"`
+}
+
+// jsonSpanExporter is the [TracingExporterStdout] exporter. It writes the
+// spans as JSON, one per line.
+type jsonSpanExporter struct {
+	mu  sync.Mutex // protects enc
+	enc *json.Encoder
+}
+
+func newJSONSpanExporter(w io.Writer) *jsonSpanExporter {
+	return &jsonSpanExporter{enc: json.NewEncoder(w)}
+}
+
+// ExportSpans writes spans.
+func (e *jsonSpanExporter) ExportSpans(_ context.Context, spans []trace.ReadOnlySpan) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, span := range spans {
+		out := jsonSpan{
+			Name:          span.Name(),
+			TraceID:       span.SpanContext().TraceID().String(),
+			SpanID:        span.SpanContext().SpanID().String(),
+			Start:         span.StartTime(),
+			End:           span.EndTime(),
+			Status:        span.Status().Code.String(),
+			StatusMessage: span.Status().Description,
+		}
+		if span.Parent().IsValid() {
+			out.ParentSpanID = span.Parent().SpanID().String()
+		}
+		for _, attr := range span.Attributes() {
+			if out.Attributes == nil {
+				out.Attributes = make(map[string]string)
+			}
+			out.Attributes[string(attr.Key)] = attr.Value.Emit()
+		}
+		if err := e.enc.Encode(out); err != nil {
+			return fmt.Errorf("write span %s: %w", out.SpanID, err)
+		}
+	}
+	return nil
+}
+
+// Shutdown does nothing, as the spans are written when exported.
+func (e *jsonSpanExporter) Shutdown(context.Context) error {
+	return nil
+}
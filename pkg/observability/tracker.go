@@ -4,12 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"os"
 	"runtime"
+	"slices"
 	"strings"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	"go.opentelemetry.io/otel/sdk/resource"
 	"go.opentelemetry.io/otel/sdk/trace"
@@ -25,24 +29,108 @@ var (
 	SkipObservability = true
 )
 
-// InitOtelTracing initializes the OpenTelemetry tracing and returns an exporter.
-func InitOtelTracing(ctx context.Context, serviceName, serviceVersion, tracingPort string) {
-	oltpEndpoint := fmt.Sprintf("otel-collector:%s", tracingPort)
+// TracingExporter is where the spans of the driver are exported to.
+type TracingExporter string
 
-	exporter, err := otlptracehttp.New(ctx,
-		otlptracehttp.WithEndpoint(oltpEndpoint),
-		otlptracehttp.WithInsecure(),
-	)
+const (
+	// TracingExporterOTLPHTTP exports the spans to an OTLP collector over
+	// HTTP. It is the default.
+	TracingExporterOTLPHTTP TracingExporter = "otlp-http"
+
+	// TracingExporterOTLPGRPC exports the spans to an OTLP collector over
+	// gRPC.
+	TracingExporterOTLPGRPC TracingExporter = "otlp-grpc"
+
+	// TracingExporterStdout writes the spans to the standard output as JSON,
+	// one per line, to debug the tracing without a collector.
+	TracingExporterStdout TracingExporter = "stdout"
+
+	// TracingExporterNone does not export the spans, as when tracing is
+	// disabled.
+	TracingExporterNone TracingExporter = "none"
+)
+
+// ParseTracingExporter parses a tracing exporter. The empty string is
+// [TracingExporterOTLPHTTP].
+func ParseTracingExporter(s string) (TracingExporter, error) {
+	switch exporter := TracingExporter(s); exporter {
+	case "":
+		return TracingExporterOTLPHTTP, nil
+	case TracingExporterOTLPHTTP, TracingExporterOTLPGRPC, TracingExporterStdout, TracingExporterNone:
+		return exporter, nil
+	default:
+		return "", fmt.Errorf("invalid tracing exporter %q, must be %q, %q, %q or %q", s, TracingExporterOTLPHTTP, TracingExporterOTLPGRPC, TracingExporterStdout, TracingExporterNone)
+	}
+}
+
+// TracingConfig configures the tracing of the driver.
+type TracingConfig struct {
+	// Exporter is where the spans are exported to. [TracingExporterOTLPHTTP]
+	// is used if empty.
+	Exporter TracingExporter
+
+	// Endpoint is the host:port of the OTLP collector the spans are exported
+	// to, without TLS. Only used by the OTLP exporters.
+	Endpoint string
+
+	// SamplingRatio is the ratio of the traces started by the driver that
+	// are sampled, between 0 and 1. The spans of the traces started by
+	// callers are sampled when their parent was. Traces are always sampled
+	// if it is 1, the default.
+	SamplingRatio *float64
+
+	// Attributes are added to the resource of all the spans, e.g. the name
+	// of the cluster.
+	Attributes map[string]string
+}
+
+// newSpanExporter returns the exporter of the spans configured by cfg.
+func newSpanExporter(ctx context.Context, cfg TracingConfig) (trace.SpanExporter, error) {
+	switch cfg.Exporter {
+	case "", TracingExporterOTLPHTTP:
+		return otlptracehttp.New(ctx,
+			otlptracehttp.WithEndpoint(cfg.Endpoint),
+			otlptracehttp.WithInsecure(),
+		)
+	case TracingExporterOTLPGRPC:
+		return otlptracegrpc.New(ctx,
+			otlptracegrpc.WithEndpoint(cfg.Endpoint),
+			otlptracegrpc.WithInsecure(),
+		)
+	case TracingExporterStdout:
+		return newJSONSpanExporter(os.Stdout), nil
+	default:
+		return nil, fmt.Errorf("unsupported tracing exporter %q", cfg.Exporter)
+	}
+}
+
+// newSampler returns the parent-based sampler of the traces configured by
+// cfg.
+func newSampler(cfg TracingConfig) trace.Sampler {
+	if cfg.SamplingRatio == nil || *cfg.SamplingRatio >= 1 {
+		return trace.ParentBased(trace.AlwaysSample())
+	}
+	return trace.ParentBased(trace.TraceIDRatioBased(*cfg.SamplingRatio))
+}
+
+// InitOtelTracing initializes the OpenTelemetry TracerProvider exporting the
+// spans as configured by cfg.
+func InitOtelTracing(ctx context.Context, serviceName, serviceVersion string, cfg TracingConfig) error {
+	exporter, err := newSpanExporter(ctx, cfg)
 	if err != nil {
-		klog.Errorf("Failed to create OTLP HTTP exporter: %v", err)
+		return fmt.Errorf("create %s exporter: %w", cfg.Exporter, err)
 	}
 
+	attributes := []attribute.KeyValue{
+		attribute.String("service.name", serviceName),
+		attribute.String("service.version", serviceVersion),
+	}
+	for _, key := range slices.Sorted(maps.Keys(cfg.Attributes)) {
+		attributes = append(attributes, attribute.String(key, cfg.Attributes[key]))
+	}
 	res, err := resource.New(ctx,
 		resource.WithFromEnv(),
-		resource.WithAttributes(
-			attribute.String("service.name", serviceName),
-			attribute.String("service.version", serviceVersion),
-		),
+		resource.WithAttributes(attributes...),
 		resource.WithProcess(),
 		resource.WithOS(),
 		resource.WithContainer(),
@@ -55,21 +143,33 @@ func InitOtelTracing(ctx context.Context, serviceName, serviceVersion, tracingPo
 	TracerProvider = trace.NewTracerProvider(
 		trace.WithBatcher(exporter),
 		trace.WithResource(res),
+		trace.WithSampler(newSampler(cfg)),
 	)
 
 	otel.SetTracerProvider(TracerProvider)
 
-	klog.Infof("OpenTelemetry tracing initialized for service: %s, version: %s, port: %s", serviceName, serviceVersion, tracingPort)
+	klog.Infof("OpenTelemetry tracing initialized for service: %s, version: %s, exporter: %s, endpoint: %s", serviceName, serviceVersion, cfg.Exporter, cfg.Endpoint)
+	return nil
 }
 
-// InitTracer initializes the global tracer.
-func InitTracer(ctx context.Context, serviceName, serviceVersion, tracingPort string) {
-	// Initialize the OTLP exporter and TracerProvider
-	InitOtelTracing(ctx, serviceName, serviceVersion, tracingPort)
+// InitTracer initializes the global tracer. It does nothing with
+// [TracingExporterNone].
+func InitTracer(ctx context.Context, serviceName, serviceVersion string, cfg TracingConfig) error {
+	if cfg.Exporter == TracingExporterNone {
+		klog.Infof("Tracing exporter is %s, not tracing service: %s", cfg.Exporter, serviceName)
+		return nil
+	}
+
+	// Initialize the exporter and TracerProvider
+	if err := InitOtelTracing(ctx, serviceName, serviceVersion, cfg); err != nil {
+		return err
+	}
 
 	// Set the global tracer
 	Tracer = otel.Tracer(serviceName)
-	klog.Infof("Tracing initialized successfully for service: %s, version: %s, port: %s", serviceName, serviceVersion, tracingPort)
+	SkipObservability = false
+	klog.Infof("Tracing initialized successfully for service: %s, version: %s", serviceName, serviceVersion)
+	return nil
 }
 
 // TraceFunctionData handles tracing for success, error, or subfunction calls.
@@ -0,0 +1,96 @@
+package observability
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace"
+	tracer "go.opentelemetry.io/otel/trace"
+)
+
+func TestParseTracingExporter(t *testing.T) {
+	for s, want := range map[string]TracingExporter{
+		"":          TracingExporterOTLPHTTP,
+		"otlp-http": TracingExporterOTLPHTTP,
+		"otlp-grpc": TracingExporterOTLPGRPC,
+		"stdout":    TracingExporterStdout,
+		"none":      TracingExporterNone,
+	} {
+		if got, err := ParseTracingExporter(s); err != nil || got != want {
+			t.Errorf("ParseTracingExporter(%q) = %q, %v, want %q", s, got, err, want)
+		}
+	}
+	if _, err := ParseTracingExporter("jaeger"); err == nil {
+		t.Error("ParseTracingExporter(\"jaeger\") succeeded, want error")
+	}
+}
+
+func TestSampler(t *testing.T) {
+	zero, half := 0.0, 0.5
+	tests := []struct {
+		name        string
+		ratio       *float64
+		parent      bool
+		wantSampled bool
+	}{
+		{name: "Default", wantSampled: true},
+		{name: "Never", ratio: &zero},
+		{name: "Never with a sampled parent", ratio: &zero, parent: true, wantSampled: true},
+		{name: "Ratio", ratio: &half},
+	}
+
+	// The trace ID is above half of the IDs, and not sampled at 0.5
+	traceID := tracer.TraceID{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.parent {
+				ctx = tracer.ContextWithSpanContext(ctx, tracer.NewSpanContext(tracer.SpanContextConfig{
+					TraceID:    traceID,
+					SpanID:     tracer.SpanID{1},
+					TraceFlags: tracer.FlagsSampled,
+				}))
+			}
+			result := newSampler(TracingConfig{SamplingRatio: tt.ratio}).ShouldSample(trace.SamplingParameters{
+				ParentContext: ctx,
+				TraceID:       traceID,
+				Name:          "CreateVolume",
+			})
+			if sampled := result.Decision == trace.RecordAndSample; sampled != tt.wantSampled {
+				t.Errorf("sampled = %v, want %v", sampled, tt.wantSampled)
+			}
+		})
+	}
+}
+
+func TestJSONSpanExporter(t *testing.T) {
+	var buf bytes.Buffer
+	provider := trace.NewTracerProvider(trace.WithSyncer(newJSONSpanExporter(&buf)))
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "CreateVolume")
+	_, child := provider.Tracer("test").Start(ctx, "attemptCreateLinodeVolume")
+	child.SetAttributes(attribute.String("volume_id", "1001"))
+	child.End()
+	parent.End()
+
+	dec := json.NewDecoder(&buf)
+	var spans []jsonSpan
+	for dec.More() {
+		var span jsonSpan
+		if err := dec.Decode(&span); err != nil {
+			t.Fatal(err)
+		}
+		spans = append(spans, span)
+	}
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	if spans[0].Name != "attemptCreateLinodeVolume" || spans[0].ParentSpanID != spans[1].SpanID || spans[0].Attributes["volume_id"] != "1001" {
+		t.Errorf("child span = %+v, want child of %s with volume_id 1001", spans[0], spans[1].SpanID)
+	}
+	if spans[1].Name != "CreateVolume" || spans[1].ParentSpanID != "" || spans[1].TraceID != spans[0].TraceID {
+		t.Errorf("parent span = %+v, want root of trace %s", spans[1], spans[0].TraceID)
+	}
+}
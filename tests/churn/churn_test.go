@@ -0,0 +1,322 @@
+//go:build e2e
+
+// Package churn creates, attaches, detaches and deletes many volumes at once
+// through the driver deployed in a test cluster, to catch regressions in the
+// way concurrent requests are handled before a release.
+//
+// The test runs kubectl against the cluster of $KUBECONFIG, and checks the
+// volumes with the Linode API using $LINODE_TOKEN. Run it with
+// `make churn-test`.
+package churn
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/linode/linodego"
+)
+
+// Settings of the test, overridden with environment variables.
+var (
+	// volumes is the number of volumes created at once.
+	volumes = envInt("CHURN_VOLUMES", 50)
+	// maxErrorRate is the highest ratio of failed to attempted operations
+	// reported in the events of the claims and pods.
+	maxErrorRate = envFloat("CHURN_MAX_ERROR_RATE", 0.1)
+	// timeout bounds each phase of the test.
+	timeout = envDuration("CHURN_TIMEOUT", 20*time.Minute)
+)
+
+// pollInterval is how often the volumes are listed while waiting for them.
+const pollInterval = 10 * time.Second
+
+// failureReasons are the reasons of the warning events reporting an error of
+// the driver, counted against maxErrorRate.
+var failureReasons = map[string]bool{
+	"ProvisioningFailed": true,
+	"FailedAttachVolume": true,
+	"FailedMount":        true,
+	"FailedDetach":       true,
+	"VolumeFailedDelete": true,
+}
+
+var manifests = template.Must(template.New("manifests").Parse(`
+{{- define "storageclass" -}}
+apiVersion: storage.k8s.io/v1
+kind: StorageClass
+metadata:
+  name: {{.Namespace}}
+provisioner: linodebs.csi.linode.com
+reclaimPolicy: Delete
+volumeBindingMode: Immediate
+parameters:
+  linodebs.csi.linode.com/volumeTags: {{.Namespace}}
+{{- end -}}
+
+{{- define "volume" -}}
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: churn-{{.Index}}
+  namespace: {{.Namespace}}
+spec:
+  accessModes:
+  - ReadWriteOnce
+  resources:
+    requests:
+      storage: 10Gi
+  storageClassName: {{.Namespace}}
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: churn-{{.Index}}
+  namespace: {{.Namespace}}
+spec:
+  containers:
+  - name: churn
+    image: busybox
+    command:
+    - sleep
+    - "1000000"
+    volumeMounts:
+    - mountPath: /data
+      name: volume
+  terminationGracePeriodSeconds: 0
+  tolerations:
+  - key: "node-role.kubernetes.io/control-plane"
+    operator: "Exists"
+    effect: "NoSchedule"
+  volumes:
+  - name: volume
+    persistentVolumeClaim:
+      claimName: churn-{{.Index}}
+{{- end -}}
+`))
+
+func TestVolumeChurn(t *testing.T) {
+	token := os.Getenv("LINODE_TOKEN")
+	if token == "" {
+		t.Fatal("LINODE_TOKEN must be set")
+	}
+	client := linodego.NewClient(http.DefaultClient)
+	client.SetToken(token)
+
+	namespace := fmt.Sprintf("churn-%d", time.Now().Unix())
+	kubectl(t, nil, "create", "namespace", namespace)
+	kubectl(t, render(t, "storageclass", map[string]any{"Namespace": namespace}), "apply", "-f", "-")
+	t.Cleanup(func() {
+		// Remove whatever the test left behind, so that a failed run does
+		// not leak volumes into the test account.
+		if err := runKubectl(nil, "delete", "namespace", namespace, "--wait=true", "--timeout=10m"); err != nil {
+			t.Error(err)
+		}
+		if err := runKubectl(nil, "delete", "storageclass", namespace, "--ignore-not-found"); err != nil {
+			t.Error(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if vols, err := waitForVolumes(ctx, client, namespace, func(vols []linodego.Volume) bool { return len(vols) == 0 }); err != nil {
+			t.Errorf("%d volumes tagged %s are left in the account: %v", len(vols), namespace, err)
+		}
+	})
+
+	t.Logf("Creating %d volumes in namespace %s", volumes, namespace)
+	volumeManifests := make([][]byte, volumes)
+	for i := range volumes {
+		volumeManifests[i] = render(t, "volume", map[string]any{"Namespace": namespace, "Index": i})
+	}
+	parallel(t, volumes, func(i int) error {
+		return runKubectl(volumeManifests[i], "apply", "-f", "-")
+	})
+	kubectl(t, nil, "wait", "pod", "--all", "-n", namespace, "--for=condition=Ready", "--timeout="+timeout.String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	vols, err := waitForVolumes(ctx, client, namespace, func(vols []linodego.Volume) bool {
+		return len(vols) == volumes && attached(vols) == volumes
+	})
+	if err != nil {
+		t.Fatalf("volumes were not all created and attached: %d volumes, %d attached: %v", len(vols), attached(vols), err)
+	}
+
+	t.Log("Detaching the volumes")
+	parallel(t, volumes, func(i int) error {
+		return runKubectl(nil, "delete", "pod", "-n", namespace, "churn-"+strconv.Itoa(i), "--wait=true")
+	})
+	ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if vols, err = waitForVolumes(ctx, client, namespace, func(vols []linodego.Volume) bool { return attached(vols) == 0 }); err != nil {
+		t.Errorf("%d volumes are stuck attached: %v", attached(vols), err)
+	}
+
+	t.Log("Deleting the volumes")
+	parallel(t, volumes, func(i int) error {
+		return runKubectl(nil, "delete", "pvc", "-n", namespace, "churn-"+strconv.Itoa(i), "--wait=true")
+	})
+	ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if vols, err = waitForVolumes(ctx, client, namespace, func(vols []linodego.Volume) bool { return len(vols) == 0 }); err != nil {
+		t.Errorf("%d volumes were leaked: %v", len(vols), err)
+	}
+
+	// Creating, attaching, detaching and deleting each volume
+	failures := countFailureEvents(t, namespace)
+	if rate := float64(failures) / float64(4*volumes); rate > maxErrorRate {
+		t.Errorf("%d of %d operations failed, above the maximum error rate %v", failures, 4*volumes, maxErrorRate)
+	}
+}
+
+// waitForVolumes lists the volumes tagged with tag until done returns true for
+// them, or ctx is done. It returns the volumes last listed.
+func waitForVolumes(ctx context.Context, client linodego.Client, tag string, done func([]linodego.Volume) bool) ([]linodego.Volume, error) {
+	filter, err := json.Marshal(map[string]string{"tags": tag})
+	if err != nil {
+		return nil, err
+	}
+	var vols []linodego.Volume
+	for {
+		if vols, err = client.ListVolumes(ctx, linodego.NewListOptions(0, string(filter))); err == nil && done(vols) {
+			return vols, nil
+		}
+		select {
+		case <-ctx.Done():
+			if err == nil {
+				err = ctx.Err()
+			}
+			return vols, err
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// attached returns the number of vols attached to an instance.
+func attached(vols []linodego.Volume) int {
+	count := 0
+	for _, vol := range vols {
+		if vol.LinodeID != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// countFailureEvents returns the number of times an event with one of the
+// failureReasons was reported in namespace.
+func countFailureEvents(t *testing.T, namespace string) int {
+	t.Helper()
+	var events struct {
+		Items []struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+			Count  int    `json:"count"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(kubectl(t, nil, "get", "events", "-n", namespace, "-o", "json"), &events); err != nil {
+		t.Fatalf("decode events: %v", err)
+	}
+	failures := 0
+	for _, event := range events.Items {
+		if event.Type == "Warning" && failureReasons[event.Reason] {
+			failures += max(event.Count, 1)
+		}
+	}
+	return failures
+}
+
+// parallel calls fn with 0 to n-1 at once, and fails the test if any call
+// failed.
+func parallel(t *testing.T, n int, fn func(i int) error) {
+	t.Helper()
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = fn(i)
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("volume %d: %v", i, err)
+		}
+	}
+	if t.Failed() {
+		t.FailNow()
+	}
+}
+
+// render renders the manifests defined as name in manifests.
+func render(t *testing.T, name string, data any) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := manifests.ExecuteTemplate(&buf, name, data); err != nil {
+		t.Fatalf("render %s: %v", name, err)
+	}
+	return buf.Bytes()
+}
+
+// kubectl runs kubectl with args and stdin, and returns its output. It fails
+// the test if kubectl fails.
+func kubectl(t *testing.T, stdin []byte, args ...string) []byte {
+	t.Helper()
+	out, err := outputKubectl(stdin, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// runKubectl runs kubectl with args and stdin.
+func runKubectl(stdin []byte, args ...string) error {
+	_, err := outputKubectl(stdin, args...)
+	return err
+}
+
+func outputKubectl(stdin []byte, args ...string) ([]byte, error) {
+	cmd := exec.Command("kubectl", args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("kubectl %s: %w: %s", strings.Join(args, " "), err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return out, nil
+}
+
+func envInt(name string, fallback int) int {
+	if value, err := strconv.Atoi(os.Getenv(name)); err == nil {
+		return value
+	}
+	return fallback
+}
+
+func envFloat(name string, fallback float64) float64 {
+	if value, err := strconv.ParseFloat(os.Getenv(name), 64); err == nil {
+		return value
+	}
+	return fallback
+}
+
+func envDuration(name string, fallback time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(name)); err == nil {
+		return value
+	}
+	return fallback
+}